@@ -0,0 +1,102 @@
+package objconv
+
+import (
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+type adapterID [4]byte
+
+type adapterOverride int
+
+func TestEncoderDecoderAdapters(t *testing.T) {
+	Install(reflect.TypeOf(adapterOverride(0)), Adapter{
+		Encode: func(e Encoder, v reflect.Value) error { return e.Encode("global") },
+		Decode: func(d Decoder, v reflect.Value) error { v.SetInt(-1); return d.Decode(nil) },
+	})
+
+	adapters := map[reflect.Type]Adapter{
+		reflect.TypeOf(adapterID{}): {
+			Encode: func(e Encoder, v reflect.Value) error {
+				id := v.Interface().(adapterID)
+				return e.Encode(hex.EncodeToString(id[:]))
+			},
+			Decode: func(d Decoder, v reflect.Value) error {
+				var s string
+				if err := d.Decode(&s); err != nil {
+					return err
+				}
+				b, err := hex.DecodeString(s)
+				if err != nil {
+					return err
+				}
+				copy(v.Addr().Interface().(*adapterID)[:], b)
+				return nil
+			},
+		},
+		reflect.TypeOf(adapterOverride(0)): {
+			Encode: func(e Encoder, v reflect.Value) error { return e.Encode("local") },
+			Decode: func(d Decoder, v reflect.Value) error { v.SetInt(42); return d.Decode(nil) },
+		},
+	}
+
+	type T struct {
+		ID       adapterID            `objconv:"id"`
+		IDs      []adapterID          `objconv:"ids"`
+		ByName   map[string]adapterID `objconv:"by_name"`
+		Ptr      *adapterID           `objconv:"ptr"`
+		Override adapterOverride      `objconv:"override"`
+	}
+
+	in := T{
+		ID:       adapterID{1, 2, 3, 4},
+		IDs:      []adapterID{{5, 6, 7, 8}},
+		ByName:   map[string]adapterID{"a": {9, 10, 11, 12}},
+		Ptr:      &adapterID{0xff, 0, 0, 0},
+		Override: 1,
+	}
+
+	e := NewValueEmitter()
+	enc := NewEncoder(e)
+	enc.Adapters = adapters
+
+	if err := enc.Encode(in); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(e.Value(), map[interface{}]interface{}{
+		"id":       "01020304",
+		"ids":      []interface{}{"05060708"},
+		"by_name":  map[interface{}]interface{}{"a": "090a0b0c"},
+		"ptr":      "ff000000",
+		"override": "local",
+	}) {
+		t.Errorf("bad encoding: %#v", e.Value())
+	}
+
+	var out T
+	dec := NewDecoder(NewValueParser(e.Value()))
+	dec.Adapters = adapters
+
+	if err := dec.Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	in.Override = 42
+
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("bad decoding: %#v", out)
+	}
+
+	// Encoders without adapters fall back to the global ones and reflection.
+	e = NewValueEmitter()
+
+	if err := NewEncoder(e).Encode(in); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := e.Value().(map[interface{}]interface{}); v["override"] != "global" || reflect.DeepEqual(v["id"], "01020304") {
+		t.Errorf("bad encoding without adapters: %#v", v)
+	}
+}
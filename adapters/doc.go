@@ -6,7 +6,9 @@
 package adapters
 
 import (
+	_ "github.com/segmentio/objconv/adapters/math/big"
 	_ "github.com/segmentio/objconv/adapters/net"
+	_ "github.com/segmentio/objconv/adapters/net/http"
 	_ "github.com/segmentio/objconv/adapters/net/mail"
 	_ "github.com/segmentio/objconv/adapters/net/url"
 )
@@ -0,0 +1,73 @@
+package big
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/segmentio/objconv"
+)
+
+func TestIntAdapter(t *testing.T) {
+	tests := []struct {
+		in  interface{}
+		hex bool
+		out string
+		err string
+	}{
+		{in: int64(-42), out: "-42"},
+		{in: uint64(18446744073709551615), out: "18446744073709551615"},
+		{in: objconv.Number("123456789012345678901234567890"), out: "123456789012345678901234567890"},
+		{in: "123456789012345678901234567890", out: "123456789012345678901234567890"},
+		{in: "+42", out: "42"},
+		{in: "-42", out: "-42"},
+		{in: "-0x2a", hex: true, out: "-42"},
+		{in: "0x2a", err: "objconv: bad big integer: 0x2a"},
+		{in: "+-42", err: "objconv: bad big integer: +-42"},
+		{in: "", err: "objconv: bad big integer: "},
+		{in: 1.5, err: "objconv: bad big integer: 1.5"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.out+test.err, func(t *testing.T) {
+			var i big.Int
+
+			err := NewIntAdapter(test.hex).Decode(*objconv.NewDecoder(objconv.NewValueParser(test.in)), reflect.ValueOf(&i).Elem())
+
+			switch {
+			case len(test.err) != 0:
+				if err == nil || err.Error() != test.err {
+					t.Error("bad error:", err)
+				}
+			case err != nil:
+				t.Error(err)
+			case i.String() != test.out:
+				t.Error(i.String())
+			}
+		})
+	}
+}
+
+func TestIntAdapterNull(t *testing.T) {
+	var v struct {
+		A *big.Int `objconv:"a"`
+		B *big.Int `objconv:"b"`
+		C *big.Int `objconv:"c"`
+	}
+
+	v.B = big.NewInt(1)
+
+	in := map[string]interface{}{"a": nil, "b": nil, "c": "42"}
+
+	if err := objconv.NewDecoder(objconv.NewValueParser(in)).Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	if v.A != nil || v.B != nil {
+		t.Errorf("null values must be decoded as nil pointers: %v %v", v.A, v.B)
+	}
+
+	if v.C == nil || v.C.String() != "42" {
+		t.Error("bad value:", v.C)
+	}
+}
@@ -0,0 +1,86 @@
+package big
+
+import (
+	"errors"
+	"math/big"
+	"reflect"
+	"strings"
+
+	"github.com/segmentio/objconv"
+)
+
+func makeDecodeInt(hex bool) func(objconv.Decoder, reflect.Value) error {
+	return func(d objconv.Decoder, to reflect.Value) error {
+		return decodeInt(d, to, hex)
+	}
+}
+
+func decodeInt(d objconv.Decoder, to reflect.Value, hex bool) (err error) {
+	var i big.Int
+
+	if err = d.Decode(objconv.ValueDecoderFunc(func(d objconv.Decoder) (err error) {
+		var t objconv.Type
+
+		if t, err = d.Parser.ParseType(); err != nil {
+			return
+		}
+
+		switch t {
+		case objconv.Nil:
+			err = d.Decode(nil)
+
+		case objconv.Int, objconv.Uint, objconv.Float:
+			// Numbers are decoded in their literal form so integers which
+			// don't fit in 64 bits are not truncated.
+			var n objconv.Number
+
+			if err = d.Decode(&n); err != nil {
+				return
+			}
+
+			if !parseInt(&i, string(n), false) {
+				err = errors.New("objconv: bad big integer: " + string(n))
+			}
+
+		default:
+			var s string
+
+			if err = d.Decode(&s); err != nil {
+				return
+			}
+
+			if !parseInt(&i, s, hex) {
+				err = errors.New("objconv: bad big integer: " + s)
+			}
+		}
+
+		return
+	})); err != nil {
+		return
+	}
+
+	if to.IsValid() {
+		to.Set(reflect.ValueOf(i))
+	}
+	return
+}
+
+func parseInt(i *big.Int, s string, hex bool) bool {
+	base := 10
+	sign := ""
+
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		sign, s = s[:1], s[1:]
+	}
+
+	if hex && (strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X")) {
+		base, s = 16, s[2:]
+	}
+
+	if len(s) == 0 || s[0] == '-' || s[0] == '+' {
+		return false
+	}
+
+	_, ok := i.SetString(sign+s, base)
+	return ok
+}
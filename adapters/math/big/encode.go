@@ -0,0 +1,13 @@
+package big
+
+import (
+	"math/big"
+	"reflect"
+
+	"github.com/segmentio/objconv"
+)
+
+func encodeInt(e objconv.Encoder, v reflect.Value) error {
+	i := v.Interface().(big.Int)
+	return e.Emitter.EmitString(i.String())
+}
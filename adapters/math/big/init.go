@@ -0,0 +1,33 @@
+package big
+
+import (
+	"math/big"
+	"reflect"
+
+	"github.com/segmentio/objconv"
+)
+
+func init() {
+	objconv.Install(reflect.TypeOf(big.Int{}), IntAdapter())
+}
+
+// IntAdapter returns the adapter to encode and decode big.Int values, it is
+// the adapter installed by this package and accepts hexadecimal strings, see
+// NewIntAdapter.
+func IntAdapter() objconv.Adapter {
+	return NewIntAdapter(true)
+}
+
+// NewIntAdapter returns an adapter to encode and decode big.Int values.
+//
+// The values are encoded as decimal strings, which makes them usable as map
+// keys (for example in map[*big.Int]T). The decoder accepts integers of any
+// size, and decimal strings optionally starting with a + or - sign. When hex
+// is true, strings prefixed with "0x" (after the sign) are also accepted and
+// parsed as hexadecimal integers.
+func NewIntAdapter(hex bool) objconv.Adapter {
+	return objconv.Adapter{
+		Encode: encodeInt,
+		Decode: makeDecodeInt(hex),
+	}
+}
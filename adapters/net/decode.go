@@ -108,6 +108,27 @@ func decodeIP(d objconv.Decoder, to reflect.Value) (err error) {
 	return
 }
 
+func decodeHardwareAddr(d objconv.Decoder, to reflect.Value) (err error) {
+	var a net.HardwareAddr
+	var s string
+
+	if err = d.Decode(&s); err != nil {
+		return
+	}
+
+	if len(s) != 0 {
+		if a, err = net.ParseMAC(s); err != nil {
+			err = errors.New("objconv: bad hardware address: " + s)
+			return
+		}
+	}
+
+	if to.IsValid() {
+		to.Set(reflect.ValueOf(a))
+	}
+	return
+}
+
 func parseNetAddr(s string) (ip net.IP, port int, zone string, err error) {
 	var h string
 	var p string
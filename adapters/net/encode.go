@@ -31,3 +31,8 @@ func encodeIP(e objconv.Encoder, v reflect.Value) error {
 	a := v.Interface().(net.IP)
 	return e.Emitter.EmitString(a.String())
 }
+
+func encodeHardwareAddr(e objconv.Encoder, v reflect.Value) error {
+	a := v.Interface().(net.HardwareAddr)
+	return e.Emitter.EmitString(a.String())
+}
@@ -0,0 +1,44 @@
+package http
+
+import (
+	"net/http"
+	"net/textproto"
+	"reflect"
+	"sort"
+
+	"github.com/segmentio/objconv"
+)
+
+func decodeHeader(d objconv.Decoder, to reflect.Value) (err error) {
+	var m map[string][]string
+
+	// Values may be strings or arrays of strings and repeated keys accumulate
+	// their values, which is what the MultiMap option of the decoder does.
+	d.MultiMap = true
+
+	if err = d.Decode(&m); err != nil {
+		return
+	}
+
+	// The keys are sorted so the values of keys differing only by their case
+	// are merged in a deterministic order.
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := make(http.Header, len(m))
+
+	for _, k := range keys {
+		if v := m[k]; len(v) != 0 { // null values add nothing
+			c := textproto.CanonicalMIMEHeaderKey(k)
+			h[c] = append(h[c], v...)
+		}
+	}
+
+	if to.IsValid() {
+		to.Set(reflect.ValueOf(h))
+	}
+	return
+}
@@ -0,0 +1,5 @@
+// Package http provides adapters for types in the standard net/http package.
+//
+// The types and functions in this package aren't usually used direction and
+// instead are used implicitly by installing adapters on objconv.
+package http
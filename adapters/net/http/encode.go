@@ -0,0 +1,21 @@
+package http
+
+import (
+	"net/http"
+	"net/textproto"
+	"reflect"
+
+	"github.com/segmentio/objconv"
+)
+
+func encodeHeader(e objconv.Encoder, v reflect.Value) error {
+	h := v.Interface().(http.Header)
+	m := make(map[string][]string, len(h))
+
+	for k, v := range h {
+		k = textproto.CanonicalMIMEHeaderKey(k)
+		m[k] = append(m[k], v...)
+	}
+
+	return e.Encode(m)
+}
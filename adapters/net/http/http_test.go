@@ -0,0 +1,31 @@
+package http
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/segmentio/objconv"
+)
+
+func TestHeaderAdapter(t *testing.T) {
+	in := map[string]interface{}{
+		"content-type":    "application/json",
+		"x-forwarded-for": []interface{}{"127.0.0.1", "127.0.0.2"},
+		"X-Forwarded-For": "127.0.0.3",
+		"x-empty":         nil,
+	}
+
+	var h http.Header
+
+	if err := HeaderAdapter().Decode(*objconv.NewDecoder(objconv.NewValueParser(in)), reflect.ValueOf(&h).Elem()); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(h, http.Header{
+		"Content-Type":    {"application/json"},
+		"X-Forwarded-For": {"127.0.0.3", "127.0.0.1", "127.0.0.2"},
+	}) {
+		t.Errorf("%#v", h)
+	}
+}
@@ -0,0 +1,24 @@
+package http
+
+import (
+	"net/http"
+	"reflect"
+
+	"github.com/segmentio/objconv"
+)
+
+func init() {
+	objconv.Install(reflect.TypeOf(http.Header(nil)), HeaderAdapter())
+}
+
+// HeaderAdapter returns the adapter to encode and decode http.Header values.
+//
+// The headers are represented as maps of arrays of strings, the decoder also
+// accepts single strings as values and repeated keys accumulate their values.
+// Header names are canonicalized on both encoding and decoding.
+func HeaderAdapter() objconv.Adapter {
+	return objconv.Adapter{
+		Encode: encodeHeader,
+		Decode: decodeHeader,
+	}
+}
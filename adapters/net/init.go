@@ -13,6 +13,7 @@ func init() {
 	objconv.Install(reflect.TypeOf(net.UnixAddr{}), UnixAddrAdapter())
 	objconv.Install(reflect.TypeOf(net.IPAddr{}), IPAddrAdapter())
 	objconv.Install(reflect.TypeOf(net.IP(nil)), IPAdapter())
+	objconv.Install(reflect.TypeOf(net.HardwareAddr(nil)), HardwareAddrAdapter())
 }
 
 // TCPAddrAdapter returns the adapter to encode and decode net.TCPAddr values.
@@ -54,3 +55,16 @@ func IPAdapter() objconv.Adapter {
 		Decode: decodeIP,
 	}
 }
+
+// HardwareAddrAdapter returns the adapter to encode and decode net.HardwareAddr
+// values.
+//
+// Hardware addresses are represented by their colon-separated string form (for
+// example "01:23:45:67:89:ab"), both 6 bytes and 8 bytes (EUI-64) addresses are
+// supported.
+func HardwareAddrAdapter() objconv.Adapter {
+	return objconv.Adapter{
+		Encode: encodeHardwareAddr,
+		Decode: decodeHardwareAddr,
+	}
+}
@@ -29,14 +29,43 @@ func decodeURL(d objconv.Decoder, to reflect.Value) (err error) {
 
 func decodeQuery(d objconv.Decoder, to reflect.Value) (err error) {
 	var v url.Values
-	var s string
 
-	if err = d.Decode(&s); err != nil {
+	// The values may be represented either as a query string or as a map of
+	// strings or arrays of strings, the parser is used to figure out which
+	// form is being decoded.
+	if err = d.Decode(objconv.ValueDecoderFunc(func(d objconv.Decoder) (err error) {
+		var t objconv.Type
+		var s string
 
-	}
+		if t, err = d.Parser.ParseType(); err != nil {
+			return
+		}
+
+		if t == objconv.Map {
+			// Values may be strings or arrays of strings and repeated keys
+			// accumulate their values, which is what MultiMap does.
+			d.MultiMap = true
+			v = make(url.Values)
 
-	if v, err = url.ParseQuery(s); err != nil {
-		err = errors.New("objconv: bad URL values: " + err.Error())
+			if err = d.Decode((*map[string][]string)(&v)); err == nil {
+				for k, x := range v {
+					if len(x) == 0 { // null values add nothing
+						delete(v, k)
+					}
+				}
+			}
+			return
+		}
+
+		if err = d.Decode(&s); err != nil {
+			return
+		}
+
+		if v, err = url.ParseQuery(s); err != nil {
+			err = errors.New("objconv: bad URL values: " + err.Error())
+		}
+		return
+	})); err != nil {
 		return
 	}
 
@@ -16,3 +16,7 @@ func encodeQuery(e objconv.Encoder, v reflect.Value) error {
 	q := v.Interface().(url.Values)
 	return e.Emitter.EmitString(q.Encode())
 }
+
+func encodeValues(e objconv.Encoder, v reflect.Value) error {
+	return e.Encode(map[string][]string(v.Interface().(url.Values)))
+}
@@ -21,9 +21,31 @@ func URLAdapter() objconv.Adapter {
 }
 
 // QueryAdapter returns the adapter to encode and decode url.Values values.
+//
+// The values are encoded as a query string, the decoder accepts either a query
+// string or a map of strings or arrays of strings (repeated keys accumulate
+// their values).
 func QueryAdapter() objconv.Adapter {
 	return objconv.Adapter{
 		Encode: encodeQuery,
 		Decode: decodeQuery,
 	}
 }
+
+// ValuesAdapter returns the adapter to encode and decode url.Values values as
+// maps of arrays of strings.
+//
+// The decoder accepts the same representations than the one returned by
+// QueryAdapter.
+//
+// The adapter isn't installed by default since QueryAdapter is, it can be set
+// on encoders and decoders with their Adapters field, or installed in place of
+// QueryAdapter with:
+//
+//	objconv.Install(reflect.TypeOf(url.Values(nil)), ValuesAdapter())
+func ValuesAdapter() objconv.Adapter {
+	return objconv.Adapter{
+		Encode: encodeValues,
+		Decode: decodeQuery,
+	}
+}
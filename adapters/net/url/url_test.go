@@ -0,0 +1,64 @@
+package url
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/segmentio/objconv"
+)
+
+func TestQueryAdapter(t *testing.T) {
+	tests := []struct {
+		in  interface{}
+		out url.Values
+	}{
+		{
+			in:  "answer=42&message=Hello+World&answer=43",
+			out: url.Values{"answer": {"42", "43"}, "message": {"Hello World"}},
+		},
+		{
+			in:  map[string]interface{}{"answer": []interface{}{"42", "43"}, "message": "Hello World", "empty": nil},
+			out: url.Values{"answer": {"42", "43"}, "message": {"Hello World"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			var v url.Values
+
+			if err := QueryAdapter().Decode(*objconv.NewDecoder(objconv.NewValueParser(test.in)), reflect.ValueOf(&v).Elem()); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(v, test.out) {
+				t.Errorf("%#v", v)
+			}
+		})
+	}
+}
+
+func TestValuesAdapter(t *testing.T) {
+	adapters := map[reflect.Type]objconv.Adapter{reflect.TypeOf(url.Values(nil)): ValuesAdapter()}
+	in := url.Values{"answer": {"42", "43"}, "message": {"Hello World"}}
+
+	e := objconv.NewValueEmitter()
+	enc := objconv.NewEncoder(e)
+	enc.Adapters = adapters
+
+	if err := enc.Encode(in); err != nil {
+		t.Fatal(err)
+	}
+
+	var out url.Values
+	dec := objconv.NewDecoder(objconv.NewValueParser(e.Value()))
+	dec.Adapters = adapters
+
+	if err := dec.Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("%#v", out)
+	}
+}
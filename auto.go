@@ -0,0 +1,129 @@
+package objconv
+
+import (
+	"fmt"
+	"io"
+)
+
+// DefaultFormatTags is the default association between format tags and the
+// mime types of codecs, used by AutoCodec when its Tags field is nil.
+var DefaultFormatTags = map[byte]string{
+	1: "application/json",
+	2: "application/msgpack",
+	3: "application/cbor",
+}
+
+// An AutoCodec creates decoders which select the format of their input from a
+// tag byte found at the beginning of the input, and encoders which write this
+// tag before the encoded values.
+//
+// Codecs are looked up in the global registry, which means that the packages
+// implementing the formats must be imported for their codecs to be available.
+type AutoCodec struct {
+	// Tags associates tag bytes with the mime types of codecs, when nil
+	// DefaultFormatTags is used.
+	Tags map[byte]string
+
+	// OmitTag disables reading and writing the tag byte, decoders then expect
+	// the input to be in the format of Format.
+	OmitTag bool
+
+	// Format is the mime type of the codec used by decoders when OmitTag is
+	// set.
+	Format string
+}
+
+// NewAutoDecoder returns a new decoder which reads the format tag from r and
+// takes the following input from r, using the default configuration of
+// AutoCodec.
+func NewAutoDecoder(r io.Reader) (*Decoder, error) {
+	return AutoCodec{}.NewDecoder(r)
+}
+
+// NewAutoEncoder returns a new encoder which writes the tag of the format of
+// mimetype to w before returning an encoder which outputs to w, using the
+// default configuration of AutoCodec.
+func NewAutoEncoder(w io.Writer, mimetype string) (*Encoder, error) {
+	return AutoCodec{}.NewEncoder(w, mimetype)
+}
+
+// NewDecoder reads the format tag from r and returns a new decoder taking input
+// from r with the codec of this format.
+//
+// Exactly one byte is read from r before creating the parser, so the input is
+// positioned on the encoded value even if the parser buffers its reads.
+func (c AutoCodec) NewDecoder(r io.Reader) (*Decoder, error) {
+	mimetype := c.Format
+
+	if !c.OmitTag {
+		var b [1]byte
+
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return nil, err
+		}
+
+		tag, ok := c.tags()[b[0]]
+
+		if !ok {
+			return nil, fmt.Errorf("objconv: unknown format tag: %#02x", b[0])
+		}
+
+		mimetype = tag
+	}
+
+	codec, err := c.lookup(mimetype)
+	if err != nil {
+		return nil, err
+	}
+
+	return codec.NewDecoder(r), nil
+}
+
+// NewEncoder writes the tag of the format of mimetype to w and returns a new
+// encoder which outputs to w with the codec of this format.
+func (c AutoCodec) NewEncoder(w io.Writer, mimetype string) (*Encoder, error) {
+	codec, err := c.lookup(mimetype)
+	if err != nil {
+		return nil, err
+	}
+
+	if !c.OmitTag {
+		tag, ok := c.tagOf(mimetype)
+
+		if !ok {
+			return nil, fmt.Errorf("objconv: no format tag for %s", mimetype)
+		}
+
+		if _, err := w.Write([]byte{tag}); err != nil {
+			return nil, err
+		}
+	}
+
+	return codec.NewEncoder(w), nil
+}
+
+func (c AutoCodec) tags() map[byte]string {
+	if c.Tags == nil {
+		return DefaultFormatTags
+	}
+	return c.Tags
+}
+
+func (c AutoCodec) tagOf(mimetype string) (byte, bool) {
+	for tag, m := range c.tags() {
+		if m == mimetype {
+			return tag, true
+		}
+	}
+	return 0, false
+}
+
+func (c AutoCodec) lookup(mimetype string) (Codec, error) {
+	codec, ok := Lookup(mimetype)
+
+	if !ok {
+		return Codec{}, fmt.Errorf("objconv: no codec registered for %s", mimetype)
+	}
+
+	return codec, nil
+}
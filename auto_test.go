@@ -0,0 +1,111 @@
+package objconv_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/segmentio/objconv"
+	_ "github.com/segmentio/objconv/cbor"
+	_ "github.com/segmentio/objconv/json"
+	_ "github.com/segmentio/objconv/msgpack"
+)
+
+func TestAutoCodec(t *testing.T) {
+	value := map[interface{}]interface{}{"answer": int64(42)}
+
+	tests := []struct {
+		mimetype string
+		tag      byte
+	}{
+		{"application/json", 1},
+		{"application/msgpack", 2},
+		{"application/cbor", 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.mimetype, func(t *testing.T) {
+			b := &bytes.Buffer{}
+			e, err := objconv.NewAutoEncoder(b, test.mimetype)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := e.Encode(value); err != nil {
+				t.Fatal(err)
+			}
+
+			if tag := b.Bytes()[0]; tag != test.tag {
+				t.Errorf("bad format tag: %#02x", tag)
+			}
+
+			d, err := objconv.NewAutoDecoder(b)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var v interface{}
+
+			if err := d.Decode(&v); err != nil {
+				t.Fatal(err)
+			}
+
+			if !objconv.Equal(v, value) {
+				t.Errorf("%#v != %#v", v, value)
+			}
+		})
+	}
+}
+
+func TestAutoCodecOmitTag(t *testing.T) {
+	c := objconv.AutoCodec{OmitTag: true, Format: "application/json"}
+	b := &bytes.Buffer{}
+	e, err := c.NewEncoder(b, "application/json")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.Encode(42); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := b.String(); s != "42" {
+		t.Error("bad output:", s)
+	}
+
+	d, err := c.NewDecoder(b)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var v int
+
+	if err := d.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	if v != 42 {
+		t.Error("bad value:", v)
+	}
+}
+
+func TestAutoCodecErrors(t *testing.T) {
+	if _, err := objconv.NewAutoDecoder(bytes.NewReader([]byte{0x42, '1'})); err == nil {
+		t.Error("expected an error for an unknown format tag")
+	} else if s := err.Error(); s != "objconv: unknown format tag: 0x42" {
+		t.Error(s)
+	}
+
+	if _, err := objconv.NewAutoEncoder(&bytes.Buffer{}, "application/yaml"); err == nil {
+		t.Error("expected an error for a format without a tag")
+	}
+
+	c := objconv.AutoCodec{Tags: map[byte]string{1: "application/unknown"}}
+
+	if _, err := c.NewEncoder(&bytes.Buffer{}, "application/unknown"); err == nil {
+		t.Error("expected an error for a format without a codec")
+	}
+}
@@ -0,0 +1,85 @@
+// Package avro implements a parser and an emitter for the binary encoding of
+// Apache Avro.
+//
+// Avro data carries no type information, values are always read and written
+// according to a schema, which is why this package has no global codec and the
+// functions creating parsers and emitters take a *Schema as argument.
+//
+// Records and maps are exposed as objconv maps, enums as strings, and fixed
+// values as byte slices. Values of union types are exposed as the value of the
+// branch that was selected, unless the TaggedUnions option of the parser or
+// emitter is set, in which case non-null values are represented by maps with
+// a single entry where the key is the name of the branch, the same way the
+// Avro JSON encoding represents unions.
+package avro
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// Names of the Avro types, used as values of the Type field of schemas.
+const (
+	Null    = "null"
+	Boolean = "boolean"
+	Int     = "int"
+	Long    = "long"
+	Float   = "float"
+	Double  = "double"
+	Bytes   = "bytes"
+	String  = "string"
+	Record  = "record"
+	Enum    = "enum"
+	Array   = "array"
+	Map     = "map"
+	Union   = "union"
+	Fixed   = "fixed"
+)
+
+// appendLong appends the zigzag varint representation of v to b.
+func appendLong(b []byte, v int64) []byte {
+	u := uint64((v << 1) ^ (v >> 63))
+
+	for u >= 0x80 {
+		b = append(b, byte(u)|0x80)
+		u >>= 7
+	}
+
+	return append(b, byte(u))
+}
+
+// readLong reads a zigzag varint from r, io.EOF is only returned if no bytes
+// could be read.
+func readLong(r io.ByteReader) (int64, error) {
+	var u uint64
+
+	for i := uint(0); i < 64; i += 7 {
+		c, err := r.ReadByte()
+
+		if err != nil {
+			if err == io.EOF && i != 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+
+		if u |= uint64(c&0x7F) << i; c < 0x80 {
+			return int64(u>>1) ^ -int64(u&1), nil
+		}
+	}
+
+	return 0, errVarintOverflow
+}
+
+func appendFloat(b []byte, f float32) []byte {
+	var x [4]byte
+	binary.LittleEndian.PutUint32(x[:], math.Float32bits(f))
+	return append(b, x[:]...)
+}
+
+func appendDouble(b []byte, f float64) []byte {
+	var x [8]byte
+	binary.LittleEndian.PutUint64(x[:], math.Float64bits(f))
+	return append(b, x[:]...)
+}
@@ -0,0 +1,301 @@
+package avro
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/segmentio/objconv"
+)
+
+var testSchema = MustParseSchema(`{
+	"type": "record",
+	"name": "User",
+	"namespace": "com.example",
+	"fields": [
+		{"name": "id", "type": "long"},
+		{"name": "name", "type": "string"},
+		{"name": "age", "type": "int"},
+		{"name": "score", "type": "double"},
+		{"name": "ratio", "type": "float"},
+		{"name": "admin", "type": "boolean"},
+		{"name": "email", "type": ["null", "string"]},
+		{"name": "tags", "type": {"type": "array", "items": "string"}},
+		{"name": "counts", "type": {"type": "map", "values": "long"}},
+		{"name": "status", "type": {"type": "enum", "name": "Status", "symbols": ["ACTIVE", "DISABLED"]}},
+		{"name": "hash", "type": {"type": "fixed", "name": "Hash", "size": 4}},
+		{"name": "data", "type": "bytes"},
+		{"name": "parent", "type": ["null", "User"]}
+	]
+}`)
+
+type testUser struct {
+	ID     int64            `objconv:"id"`
+	Name   string           `objconv:"name"`
+	Age    int              `objconv:"age"`
+	Score  float64          `objconv:"score"`
+	Ratio  float32          `objconv:"ratio"`
+	Admin  bool             `objconv:"admin"`
+	Email  *string          `objconv:"email"`
+	Tags   []string         `objconv:"tags"`
+	Counts map[string]int64 `objconv:"counts"`
+	Status string           `objconv:"status"`
+	Hash   []byte           `objconv:"hash"`
+	Data   []byte           `objconv:"data"`
+	Parent *testUser        `objconv:"parent"`
+}
+
+func TestLong(t *testing.T) {
+	tests := []struct {
+		v int64
+		s string
+	}{
+		{0, "00"},
+		{-1, "01"},
+		{1, "02"},
+		{-64, "7f"},
+		{64, "8001"},
+		{1 << 62, "80808080808080808001"},
+		{-1 << 63, "ffffffffffffffffff01"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.s, func(t *testing.T) {
+			if s := hex.EncodeToString(appendLong(nil, test.v)); s != test.s {
+				t.Error("bad encoding:", s)
+			}
+
+			b, _ := hex.DecodeString(test.s)
+			v, err := readLong(bytes.NewReader(b))
+
+			if err != nil {
+				t.Error(err)
+			} else if v != test.v {
+				t.Error("bad decoding:", v)
+			}
+		})
+	}
+}
+
+func TestMarshalUnmarshal(t *testing.T) {
+	email := "luke@example.com"
+
+	u1 := testUser{
+		ID:     42,
+		Name:   "Luke",
+		Age:    -19,
+		Score:  0.5,
+		Ratio:  0.25,
+		Admin:  true,
+		Email:  &email,
+		Tags:   []string{"a", "b", "c"},
+		Counts: map[string]int64{"x": 1, "y": -2},
+		Status: "DISABLED",
+		Hash:   []byte{1, 2, 3, 4},
+		Data:   []byte("hello"),
+		Parent: &testUser{
+			ID:     1,
+			Name:   "Anakin",
+			Tags:   []string{},
+			Counts: map[string]int64{},
+			Status: "ACTIVE",
+			Hash:   []byte{0, 0, 0, 0},
+			Data:   []byte{},
+		},
+	}
+
+	b, err := Marshal(testSchema, u1)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var u2 testUser
+
+	if err := Unmarshal(testSchema, b, &u2); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(u1, u2) {
+		t.Errorf("%#v != %#v", u1, u2)
+	}
+}
+
+func TestParseBlocks(t *testing.T) {
+	schema := MustParseSchema(`{"type": "array", "items": "long"}`)
+
+	// The first block has a negative count followed by its size in bytes,
+	// then a block of one item, and the terminating empty block.
+	b, _ := hex.DecodeString("03" + "04" + "02" + "04" + "02" + "06" + "00")
+
+	var v []int
+	if err := Unmarshal(schema, b, &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(v, []int{1, 2, 3}) {
+		t.Error(v)
+	}
+}
+
+func TestParseTruncatedLargeLength(t *testing.T) {
+	// The value declares a length of 2 GiB but the input ends after a few
+	// bytes, the parser must report the truncation without allocating it.
+	for _, schema := range []string{`"bytes"`, `"string"`, `{"type": "array", "items": "bytes"}`} {
+		b, _ := hex.DecodeString("feffffff0f" + "41")
+
+		if schema[0] == '{' {
+			b = append([]byte{0x02}, b...) // block of one item
+		}
+
+		var v interface{}
+		var m1, m2 runtime.MemStats
+
+		runtime.ReadMemStats(&m1)
+		err := Unmarshal(MustParseSchema(schema), b, &v)
+		runtime.ReadMemStats(&m2)
+
+		if err != io.ErrUnexpectedEOF {
+			t.Errorf("%s: expected io.ErrUnexpectedEOF but got %v", schema, err)
+		}
+
+		if n := m2.TotalAlloc - m1.TotalAlloc; n > 1<<20 {
+			t.Errorf("%s: too many bytes allocated: %d", schema, n)
+		}
+	}
+}
+
+func TestTaggedUnions(t *testing.T) {
+	schema := MustParseSchema(`{"type": "array", "items": ["null", "string", "long"]}`)
+	value := []interface{}{nil, map[interface{}]interface{}{"string": "A"}, map[interface{}]interface{}{"long": int64(1)}}
+
+	b := &bytes.Buffer{}
+	e := NewEmitter(b, schema)
+	e.TaggedUnions = true
+
+	if err := objconv.NewEncoder(e).Encode(value); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := hex.EncodeToString(b.Bytes()); s != "06"+"00"+"020241"+"0402"+"00" {
+		t.Error("bad encoding:", s)
+	}
+
+	p := NewParser(b, schema)
+	p.TaggedUnions = true
+
+	var v interface{}
+	if err := objconv.NewDecoder(p).Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(v, value) {
+		t.Errorf("%#v != %#v", v, value)
+	}
+}
+
+func TestDecodeSequence(t *testing.T) {
+	schema := MustParseSchema(`"string"`)
+	b := &bytes.Buffer{}
+	e := NewEncoder(b, schema)
+
+	for _, s := range []string{"A", "B", "C"} {
+		if err := e.Encode(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d := NewDecoder(b, schema)
+	var values []string
+	var s string
+	var err error
+
+	for err = d.Decode(&s); err == nil; err = d.Decode(&s) {
+		values = append(values, s)
+	}
+
+	if err != io.EOF {
+		t.Error(err)
+	}
+
+	if !reflect.DeepEqual(values, []string{"A", "B", "C"}) {
+		t.Error(values)
+	}
+}
+
+func TestStream(t *testing.T) {
+	schema := MustParseSchema(`{"type": "array", "items": "int"}`)
+	b := &bytes.Buffer{}
+	e := NewStreamEncoder(b, schema)
+
+	for i := 0; i != 3; i++ {
+		if err := e.Encode(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewStreamDecoder(b, schema)
+	var values []int
+	var v int
+
+	for d.Decode(&v) == nil {
+		values = append(values, v)
+	}
+
+	if err := d.Err(); err != nil {
+		t.Error(err)
+	}
+
+	if !reflect.DeepEqual(values, []int{0, 1, 2}) {
+		t.Error(values)
+	}
+}
+
+func TestSchemaErrors(t *testing.T) {
+	tests := []string{
+		`"unknown"`,
+		`["null", ["string"]]`,
+		`["string", "string"]`,
+		`{"type": "record", "fields": []}`,
+		`{"type": "fixed", "name": "F"}`,
+		`{"type": "record", "name": "R", "fields": [{"name": "a", "type": "R2"}]}`,
+	}
+
+	for _, test := range tests {
+		t.Run(test, func(t *testing.T) {
+			if _, err := ParseSchema([]byte(test)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestEmitErrors(t *testing.T) {
+	type missing struct {
+		ID int64 `objconv:"id"`
+	}
+
+	tests := []struct {
+		name string
+		v    interface{}
+	}{
+		{"missing field", missing{ID: 1}},
+		{"unknown field", map[string]interface{}{"nope": 1}},
+		{"wrong type", "hello"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := Marshal(testSchema, test.v); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package avro
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/segmentio/objconv"
+)
+
+// NewDecoder returns a new Avro decoder that parses values matching schema
+// from r.
+func NewDecoder(r io.Reader, schema *Schema) *objconv.Decoder {
+	return NewCodec(schema).NewDecoder(r)
+}
+
+// NewStreamDecoder returns a new Avro stream decoder that parses values
+// matching schema from r.
+func NewStreamDecoder(r io.Reader, schema *Schema) *objconv.StreamDecoder {
+	return NewCodec(schema).NewStreamDecoder(r)
+}
+
+// Unmarshal decodes the Avro representation of v from b, using schema.
+func Unmarshal(schema *Schema, b []byte, v interface{}) error {
+	return (objconv.Decoder{Parser: NewParser(bytes.NewReader(b), schema)}).Decode(v)
+}
@@ -0,0 +1,465 @@
+package avro
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/segmentio/objconv"
+	"github.com/segmentio/objconv/objutil"
+)
+
+// Emitter implements an emitter of the Avro binary encoding which satisfies
+// the objconv.Emitter interface.
+//
+// Records are written with their fields in the order of the schema, which may
+// be different from the order that they were emitted in, so the fields of
+// records and the items of arrays and maps are buffered until the end of each
+// container.
+type Emitter struct {
+	// TaggedUnions enables expecting non-null values of unions to be emitted
+	// as maps with a single entry, where the key is the name of the branch.
+	TaggedUnions bool
+
+	w      io.Writer
+	schema *Schema
+	stack  []*emitFrame
+	b      []byte // scratch buffer
+}
+
+// emitFrame represents a record, array, map, or tagged union being emitted.
+type emitFrame struct {
+	schema *Schema
+	index  int            // index of the current field of a record
+	fields []bytes.Buffer // encoded fields of a record
+	set    []bool         // which fields of a record were emitted
+	b      bytes.Buffer   // encoded items of an array or map
+	n      int64          // number of items of an array or map
+	key    bool           // the next value is a map key
+	union  bool           // the frame is a tagged union, schema is the union
+}
+
+// NewEmitter returns a new emitter writing values matching schema to w.
+func NewEmitter(w io.Writer, schema *Schema) *Emitter {
+	return &Emitter{w: w, schema: schema}
+}
+
+// Reset sets w as the new output of e, and clears its internal state.
+func (e *Emitter) Reset(w io.Writer) {
+	e.w = w
+	e.stack = e.stack[:0]
+}
+
+func (e *Emitter) top() *emitFrame {
+	if n := len(e.stack); n != 0 {
+		return e.stack[n-1]
+	}
+	return nil
+}
+
+// writer returns where the next value must be written.
+func (e *Emitter) writer() io.Writer {
+	for i := len(e.stack) - 1; i >= 0; i-- {
+		switch f := e.stack[i]; {
+		case f.union:
+			// Tagged unions are written where their parent writes values.
+		case f.schema.Type == Record:
+			return &f.fields[f.index]
+		default:
+			return &f.b
+		}
+	}
+	return e.w
+}
+
+// next returns the schema of the next value, key is true if a map key or the
+// name of a record field or union branch is expected.
+func (e *Emitter) next() (s *Schema, key bool) {
+	f := e.top()
+
+	switch {
+	case f == nil:
+		return e.schema, false
+	case f.key:
+		return nil, true
+	case f.union:
+		return f.schema.Types[f.index], false
+	case f.schema.Type == Record:
+		return f.schema.Fields[f.index].Type, false
+	case f.schema.Type == Map:
+		return f.schema.Values, false
+	default:
+		return f.schema.Items, false
+	}
+}
+
+// value returns the schema and the writer of the next value, which is of type
+// t. If the value is in a union, the branch that matches t is selected and its
+// index is written.
+func (e *Emitter) value(t objconv.Type) (s *Schema, w io.Writer, err error) {
+	var key bool
+
+	if s, key = e.next(); key {
+		err = fmt.Errorf("objconv/avro: map keys must be strings, found %s", t)
+		return
+	}
+
+	w = e.writer()
+
+	if s.Type == Union {
+		i := -1
+
+		if t == objconv.Nil || !e.TaggedUnions {
+			for j, b := range s.Types {
+				if accepts(b, t) {
+					i = j
+					break
+				}
+			}
+		}
+
+		if i < 0 {
+			err = fmt.Errorf("objconv/avro: no branch of union %s matches %s value", unionName(s), t)
+			return
+		}
+
+		if _, err = w.Write(appendLong(e.b[:0], int64(i))); err != nil {
+			return
+		}
+
+		s = s.Types[i]
+	}
+
+	if !accepts(s, t) {
+		err = fmt.Errorf("objconv/avro: cannot emit %s value as %s", t, s.typeName())
+	}
+	return
+}
+
+// accepts returns true if values of type t can be written with schema s.
+func accepts(s *Schema, t objconv.Type) bool {
+	switch s.Type {
+	case Null:
+		return t == objconv.Nil
+	case Boolean:
+		return t == objconv.Bool
+	case Int, Long:
+		return t == objconv.Int || t == objconv.Uint
+	case Float, Double:
+		return t == objconv.Int || t == objconv.Uint || t == objconv.Float
+	case String:
+		return t == objconv.String || t == objconv.Bytes || t == objconv.Time || t == objconv.Duration || t == objconv.Error
+	case Bytes, Fixed:
+		return t == objconv.String || t == objconv.Bytes
+	case Enum:
+		return t == objconv.String
+	case Array:
+		return t == objconv.Array
+	default: // Map, Record
+		return t == objconv.Map
+	}
+}
+
+func unionName(s *Schema) string {
+	names := make([]string, len(s.Types))
+	for i, t := range s.Types {
+		names[i] = t.typeName()
+	}
+	return fmt.Sprint(names)
+}
+
+// done is called after a value was written, it counts the items of arrays.
+func (e *Emitter) done() {
+	if f := e.top(); f != nil && !f.union && f.schema.Type == Array {
+		f.n++
+	}
+}
+
+func (e *Emitter) write(w io.Writer, b []byte) (err error) {
+	if _, err = w.Write(b); err == nil {
+		e.done()
+	}
+	return
+}
+
+func (e *Emitter) EmitNil() (err error) {
+	if _, _, err = e.value(objconv.Nil); err == nil {
+		e.done()
+	}
+	return
+}
+
+func (e *Emitter) EmitBool(v bool) (err error) {
+	var w io.Writer
+
+	if _, w, err = e.value(objconv.Bool); err != nil {
+		return
+	}
+
+	if v {
+		return e.write(w, append(e.b[:0], 1))
+	}
+	return e.write(w, append(e.b[:0], 0))
+}
+
+func (e *Emitter) EmitInt(v int64, _ int) (err error) {
+	var s *Schema
+	var w io.Writer
+
+	if s, w, err = e.value(objconv.Int); err != nil {
+		return
+	}
+
+	switch s.Type {
+	case Int:
+		if v < math.MinInt32 || v > math.MaxInt32 {
+			return fmt.Errorf("objconv/avro: %d overflows the range of int values", v)
+		}
+		return e.write(w, appendLong(e.b[:0], v))
+	case Long:
+		return e.write(w, appendLong(e.b[:0], v))
+	case Float:
+		return e.write(w, appendFloat(e.b[:0], float32(v)))
+	default:
+		return e.write(w, appendDouble(e.b[:0], float64(v)))
+	}
+}
+
+func (e *Emitter) EmitUint(v uint64, bitSize int) (err error) {
+	if v > math.MaxInt64 {
+		return fmt.Errorf("objconv/avro: %d overflows the range of long values", v)
+	}
+	return e.EmitInt(int64(v), bitSize)
+}
+
+func (e *Emitter) EmitFloat(v float64, _ int) (err error) {
+	var s *Schema
+	var w io.Writer
+
+	if s, w, err = e.value(objconv.Float); err != nil {
+		return
+	}
+
+	if s.Type == Float {
+		return e.write(w, appendFloat(e.b[:0], float32(v)))
+	}
+	return e.write(w, appendDouble(e.b[:0], v))
+}
+
+func (e *Emitter) EmitString(v string) error {
+	if _, key := e.next(); key {
+		return e.emitKey(v)
+	}
+	return e.emitString(objconv.String, v)
+}
+
+func (e *Emitter) EmitBytes(v []byte) error {
+	return e.emitString(objconv.Bytes, string(v))
+}
+
+func (e *Emitter) EmitTime(v time.Time) error {
+	return e.emitString(objconv.Time, v.Format(time.RFC3339Nano))
+}
+
+func (e *Emitter) EmitDuration(v time.Duration) error {
+	return e.emitString(objconv.Duration, string(objutil.AppendDuration(e.b[:0], v)))
+}
+
+func (e *Emitter) EmitError(v error) error {
+	return e.emitString(objconv.Error, v.Error())
+}
+
+func (e *Emitter) emitString(t objconv.Type, v string) (err error) {
+	var s *Schema
+	var w io.Writer
+
+	if s, w, err = e.value(t); err != nil {
+		return
+	}
+
+	switch s.Type {
+	case Enum:
+		i := s.symbolIndex(v)
+
+		if i < 0 {
+			return fmt.Errorf("objconv/avro: %q is not a symbol of enum %s", v, s.Name)
+		}
+
+		return e.write(w, appendLong(e.b[:0], int64(i)))
+
+	case Fixed:
+		if len(v) != s.Size {
+			return fmt.Errorf("objconv/avro: value of length %d cannot be emitted as fixed %s of size %d", len(v), s.Name, s.Size)
+		}
+
+		return e.write(w, append(e.b[:0], v...))
+
+	default:
+		b := appendLong(e.b[:0], int64(len(v)))
+		b = append(b, v...)
+		e.b = b[:0]
+		return e.write(w, b)
+	}
+}
+
+// emitKey is called when v is a map key, or the name of a record field or
+// union branch.
+func (e *Emitter) emitKey(v string) (err error) {
+	f := e.top()
+
+	switch {
+	case f.union:
+		i := f.schema.branchIndex(v)
+
+		if i < 0 {
+			return fmt.Errorf("objconv/avro: %s is not a branch of union %s", v, unionName(f.schema))
+		}
+
+		if _, err = e.writer().Write(appendLong(e.b[:0], int64(i))); err != nil {
+			return
+		}
+
+		f.index = i
+
+	case f.schema.Type == Record:
+		i := f.schema.fieldIndex(v)
+
+		if i < 0 {
+			return fmt.Errorf("objconv/avro: %s is not a field of record %s", v, f.schema.Name)
+		}
+
+		if f.set[i] {
+			return fmt.Errorf("objconv/avro: field %s of record %s was emitted twice", v, f.schema.Name)
+		}
+
+		f.index = i
+		f.set[i] = true
+
+	default:
+		b := appendLong(e.b[:0], int64(len(v)))
+		b = append(b, v...)
+		e.b = b[:0]
+		f.b.Write(b)
+		f.n++
+	}
+
+	return
+}
+
+func (e *Emitter) EmitArrayBegin(_ int) (err error) {
+	var s *Schema
+
+	if s, _, err = e.value(objconv.Array); err == nil {
+		e.stack = append(e.stack, &emitFrame{schema: s})
+	}
+
+	return
+}
+
+func (e *Emitter) EmitArrayEnd() error {
+	return e.endBlocks()
+}
+
+func (e *Emitter) EmitArrayNext() error {
+	return nil
+}
+
+func (e *Emitter) EmitMapBegin(_ int) (err error) {
+	var s *Schema
+
+	if s, _ = e.next(); s != nil && s.Type == Union && e.TaggedUnions {
+		e.stack = append(e.stack, &emitFrame{schema: s, key: true, union: true})
+		return
+	}
+
+	if s, _, err = e.value(objconv.Map); err != nil {
+		return
+	}
+
+	f := &emitFrame{schema: s, key: true}
+
+	if s.Type == Record {
+		f.fields = make([]bytes.Buffer, len(s.Fields))
+		f.set = make([]bool, len(s.Fields))
+	}
+
+	e.stack = append(e.stack, f)
+	return
+}
+
+func (e *Emitter) EmitMapEnd() (err error) {
+	switch f := e.top(); {
+	case f.union:
+		if f.key {
+			return fmt.Errorf("objconv/avro: no value was emitted for union %s", unionName(f.schema))
+		}
+		e.pop()
+		e.done()
+
+	case f.schema.Type == Record:
+		e.pop()
+		w := e.writer()
+
+		for i, field := range f.schema.Fields {
+			if !f.set[i] {
+				// Missing fields are only allowed if they can be null.
+				j := -1
+
+				if field.Type.Type == Union {
+					j = field.Type.branchIndex(Null)
+				}
+
+				if j < 0 {
+					return fmt.Errorf("objconv/avro: missing field %s of record %s", field.Name, f.schema.Name)
+				}
+
+				f.fields[i].Write(appendLong(e.b[:0], int64(j)))
+			}
+
+			if _, err = f.fields[i].WriteTo(w); err != nil {
+				return
+			}
+		}
+
+		e.done()
+
+	default:
+		err = e.endBlocks()
+	}
+
+	return
+}
+
+func (e *Emitter) EmitMapValue() error {
+	e.top().key = false
+	return nil
+}
+
+func (e *Emitter) EmitMapNext() error {
+	e.top().key = true
+	return nil
+}
+
+// endBlocks completes an array or map, which are written as a single block
+// followed by the empty block marking the end.
+func (e *Emitter) endBlocks() (err error) {
+	f := e.top()
+	e.pop()
+
+	b := e.b[:0]
+
+	if f.n != 0 {
+		b = appendLong(b, f.n)
+		b = append(b, f.b.Bytes()...)
+	}
+
+	b = appendLong(b, 0)
+	e.b = b[:0]
+	return e.write(e.writer(), b)
+}
+
+func (e *Emitter) pop() {
+	e.stack = e.stack[:len(e.stack)-1]
+}
@@ -0,0 +1,32 @@
+package avro
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/segmentio/objconv"
+)
+
+// NewEncoder returns a new Avro encoder that writes values matching schema
+// to w.
+func NewEncoder(w io.Writer, schema *Schema) *objconv.Encoder {
+	return objconv.NewEncoder(NewEmitter(w, schema))
+}
+
+// NewStreamEncoder returns a new Avro stream encoder that writes values
+// matching schema to w.
+func NewStreamEncoder(w io.Writer, schema *Schema) *objconv.StreamEncoder {
+	return objconv.NewStreamEncoder(NewEmitter(w, schema))
+}
+
+// Marshal writes the Avro representation of v to a byte slice returned in b,
+// using schema.
+func Marshal(schema *Schema, v interface{}) (b []byte, err error) {
+	var buf bytes.Buffer
+
+	if err = (objconv.Encoder{Emitter: NewEmitter(&buf, schema)}).Encode(v); err == nil {
+		b = buf.Bytes()
+	}
+
+	return
+}
@@ -0,0 +1,19 @@
+package avro
+
+import (
+	"io"
+
+	"github.com/segmentio/objconv"
+)
+
+// NewCodec returns a codec for the Avro binary encoding of values matching
+// schema.
+//
+// Because the codec is bound to a schema it isn't registered in the objconv
+// package, it can still be passed to functions that accept codecs.
+func NewCodec(schema *Schema) objconv.Codec {
+	return objconv.Codec{
+		NewEmitter: func(w io.Writer) objconv.Emitter { return NewEmitter(w, schema) },
+		NewParser:  func(r io.Reader) objconv.Parser { return NewParser(r, schema) },
+	}
+}
@@ -0,0 +1,499 @@
+package avro
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/segmentio/objconv"
+	"github.com/segmentio/objconv/objutil"
+)
+
+// Parser implements a parser of the Avro binary encoding which satisfies the
+// objconv.Parser interface.
+//
+// The parser reads a sequence of values matching its schema.
+type Parser struct {
+	// TaggedUnions enables exposing non-null values of unions as maps with a
+	// single entry, where the key is the name of the branch.
+	TaggedUnions bool
+
+	r      byteReader
+	schema *Schema
+	stack  []parseFrame
+	branch *Schema // branch of the union being parsed, once it was read
+	b      []byte  // string buffer
+}
+
+// parseFrame represents a record, array, map, or tagged union being parsed.
+type parseFrame struct {
+	schema *Schema
+	index  int   // index of the current field of a record
+	count  int64 // number of items left in the current block of an array or map
+	key    bool  // the next value is a map key
+	union  bool  // the frame is a tagged union, schema is its branch
+}
+
+type byteReader interface {
+	io.Reader
+	io.ByteScanner
+}
+
+// NewParser returns a new parser reading values matching schema from r.
+//
+// The reader is buffered unless it implements io.ByteScanner.
+func NewParser(r io.Reader, schema *Schema) *Parser {
+	p := &Parser{schema: schema}
+	p.Reset(r)
+	return p
+}
+
+// Reset sets r as the new input of p, and clears its internal state.
+func (p *Parser) Reset(r io.Reader) {
+	if br, ok := r.(byteReader); ok {
+		p.r = br
+	} else {
+		p.r = bufio.NewReader(r)
+	}
+	p.stack = p.stack[:0]
+	p.branch = nil
+}
+
+// keySchema is a placeholder returned by peek when the next value is the name
+// of a record field or union branch, which aren't part of the input.
+var keySchema = &Schema{Type: String}
+
+// mapKeySchema is returned by peek when the next value is the key of a map.
+var mapKeySchema = &Schema{Type: String}
+
+// peek returns the schema of the next value, reading the branch index if it
+// is in a union. The tagged return value is true if the value is a non-null
+// union branch that must be exposed as a tagged map.
+func (p *Parser) peek() (s *Schema, tagged bool, err error) {
+	if n := len(p.stack); n == 0 {
+		s = p.schema
+	} else {
+		f := &p.stack[n-1]
+
+		switch {
+		case f.union:
+			if f.key {
+				return keySchema, false, nil
+			}
+			return f.schema, false, nil
+
+		case f.schema.Type == Record:
+			if f.key {
+				return keySchema, false, nil
+			}
+			s = f.schema.Fields[f.index].Type
+
+		case f.schema.Type == Map:
+			if f.key {
+				return mapKeySchema, false, nil
+			}
+			s = f.schema.Values
+
+		default:
+			s = f.schema.Items
+		}
+	}
+
+	if s.Type != Union {
+		return
+	}
+
+	if p.branch == nil {
+		var i int64
+
+		if i, err = readLong(p.r); err != nil {
+			return
+		}
+
+		if i < 0 || i >= int64(len(s.Types)) {
+			err = fmt.Errorf("objconv/avro: union branch index %d out of range (%d branches)", i, len(s.Types))
+			return
+		}
+
+		p.branch = s.Types[i]
+	}
+
+	s = p.branch
+	tagged = p.TaggedUnions && s.Type != Null
+	return
+}
+
+// value returns the schema of the next value, which must be of one of the
+// given types, and marks it as consumed.
+func (p *Parser) value(t objconv.Type, types ...string) (s *Schema, err error) {
+	var tagged bool
+
+	if s, tagged, err = p.peek(); err != nil {
+		return
+	}
+
+	if !tagged {
+		for _, x := range types {
+			if s.Type == x {
+				p.branch = nil
+				return
+			}
+		}
+	}
+
+	err = fmt.Errorf("objconv/avro: cannot parse %s value as %s", s.typeName(), t)
+	return
+}
+
+func (p *Parser) ParseType() (objconv.Type, error) {
+	if len(p.stack) == 0 && p.branch == nil && p.schema.Type != Null {
+		// Detect the end of the input before the next top-level value,
+		// which is when decoding streams of values is expected to stop.
+		if _, err := p.r.ReadByte(); err != nil {
+			return objconv.Unknown, err
+		}
+		p.r.UnreadByte()
+	}
+
+	s, tagged, err := p.peek()
+
+	if err != nil {
+		return objconv.Unknown, err
+	}
+
+	if tagged {
+		return objconv.Map, nil
+	}
+
+	switch s.Type {
+	case Null:
+		return objconv.Nil, nil
+	case Boolean:
+		return objconv.Bool, nil
+	case Int, Long:
+		return objconv.Int, nil
+	case Float, Double:
+		return objconv.Float, nil
+	case Bytes, Fixed:
+		return objconv.Bytes, nil
+	case String, Enum:
+		return objconv.String, nil
+	case Array:
+		return objconv.Array, nil
+	default: // Map, Record
+		return objconv.Map, nil
+	}
+}
+
+func (p *Parser) ParseNil() (err error) {
+	_, err = p.value(objconv.Nil, Null)
+	return
+}
+
+func (p *Parser) ParseBool() (v bool, err error) {
+	var c byte
+
+	if _, err = p.value(objconv.Bool, Boolean); err != nil {
+		return
+	}
+
+	if c, err = p.readByte(); err != nil {
+		return
+	}
+
+	switch c {
+	case 0:
+	case 1:
+		v = true
+	default:
+		err = fmt.Errorf("objconv/avro: invalid boolean value: %d", c)
+	}
+	return
+}
+
+func (p *Parser) ParseInt() (v int64, err error) {
+	var s *Schema
+
+	if s, err = p.value(objconv.Int, Int, Long); err != nil {
+		return
+	}
+
+	if v, err = p.readLong(); err != nil {
+		return
+	}
+
+	if s.Type == Int && (v < math.MinInt32 || v > math.MaxInt32) {
+		err = fmt.Errorf("objconv/avro: %d overflows the range of int values", v)
+	}
+	return
+}
+
+func (p *Parser) ParseUint() (uint64, error) {
+	return 0, errors.New("objconv/avro: unsigned integers are not supported")
+}
+
+func (p *Parser) ParseFloat() (v float64, err error) {
+	var s *Schema
+	var b []byte
+
+	if s, err = p.value(objconv.Float, Float, Double); err != nil {
+		return
+	}
+
+	if s.Type == Float {
+		if b, err = p.read(4); err == nil {
+			v = float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+		}
+	} else {
+		if b, err = p.read(8); err == nil {
+			v = math.Float64frombits(binary.LittleEndian.Uint64(b))
+		}
+	}
+	return
+}
+
+func (p *Parser) ParseString() (v []byte, err error) {
+	var s *Schema
+	var i int64
+
+	if s, err = p.value(objconv.String, String, Enum); err != nil {
+		return
+	}
+
+	switch {
+	case s == keySchema:
+		f := &p.stack[len(p.stack)-1]
+
+		if f.union {
+			v = append(p.b[:0], f.schema.typeName()...)
+		} else {
+			v = append(p.b[:0], f.schema.Fields[f.index].Name...)
+		}
+
+	case s.Type == Enum:
+		if i, err = p.readLong(); err != nil {
+			return
+		}
+
+		if i < 0 || i >= int64(len(s.Symbols)) {
+			err = fmt.Errorf("objconv/avro: symbol index %d of enum %s out of range", i, s.Name)
+			return
+		}
+
+		v = append(p.b[:0], s.Symbols[i]...)
+
+	default:
+		v, err = p.readBytes()
+	}
+	return
+}
+
+func (p *Parser) ParseBytes() (v []byte, err error) {
+	var s *Schema
+
+	if s, err = p.value(objconv.Bytes, Bytes, Fixed); err != nil {
+		return
+	}
+
+	if s.Type == Fixed {
+		v, err = p.read(s.Size)
+	} else {
+		v, err = p.readBytes()
+	}
+	return
+}
+
+func (p *Parser) ParseTime() (time.Time, error) {
+	return time.Time{}, errors.New("objconv/avro: time values are not supported")
+}
+
+func (p *Parser) ParseDuration() (time.Duration, error) {
+	return 0, errors.New("objconv/avro: duration values are not supported")
+}
+
+func (p *Parser) ParseError() (error, error) {
+	return nil, errors.New("objconv/avro: error values are not supported")
+}
+
+func (p *Parser) ParseArrayBegin() (n int, err error) {
+	var s *Schema
+
+	if s, err = p.value(objconv.Array, Array); err != nil {
+		return
+	}
+
+	return p.beginBlocks(s)
+}
+
+func (p *Parser) ParseArrayEnd(n int) error {
+	p.pop()
+	return nil
+}
+
+func (p *Parser) ParseArrayNext(n int) error {
+	return p.nextItem(n)
+}
+
+func (p *Parser) ParseMapBegin() (n int, err error) {
+	var s *Schema
+	var tagged bool
+
+	if s, tagged, err = p.peek(); err != nil {
+		return
+	}
+
+	if tagged {
+		p.branch = nil
+		p.stack = append(p.stack, parseFrame{schema: s, key: true, union: true})
+		return 1, nil
+	}
+
+	if s, err = p.value(objconv.Map, Map, Record); err != nil {
+		return
+	}
+
+	if s.Type == Record {
+		p.stack = append(p.stack, parseFrame{schema: s, key: true})
+		return len(s.Fields), nil
+	}
+
+	return p.beginBlocks(s)
+}
+
+func (p *Parser) ParseMapEnd(n int) error {
+	p.pop()
+	return nil
+}
+
+func (p *Parser) ParseMapValue(n int) error {
+	p.stack[len(p.stack)-1].key = false
+	return nil
+}
+
+func (p *Parser) ParseMapNext(n int) error {
+	f := &p.stack[len(p.stack)-1]
+
+	if f.schema.Type == Record {
+		f.index = n
+		f.key = true
+		return nil
+	}
+
+	if err := p.nextItem(n); err != nil {
+		return err
+	}
+
+	f.key = true
+	return nil
+}
+
+// beginBlocks starts parsing an array or map, which are encoded as a series of
+// blocks. The length is only known after reading all the blocks so it is
+// reported as unknown, unless the first block is the empty block marking the
+// end.
+func (p *Parser) beginBlocks(s *Schema) (n int, err error) {
+	var count int64
+
+	if count, err = p.readBlock(); err != nil {
+		return
+	}
+
+	p.stack = append(p.stack, parseFrame{schema: s, count: count, key: true})
+
+	if count == 0 {
+		return 0, nil
+	}
+
+	return -1, nil
+}
+
+// nextItem is called before each item of an array or map, it reads the header
+// of the next block when the current one has no more items, and returns
+// objconv.End after the last block.
+func (p *Parser) nextItem(n int) (err error) {
+	f := &p.stack[len(p.stack)-1]
+
+	// The header of the first block was read by beginBlocks, there is nothing
+	// to do before the first item.
+	if n != 0 {
+		if f.count--; f.count == 0 {
+			f.count, err = p.readBlock()
+		}
+	}
+
+	if err == nil && f.count == 0 {
+		err = objconv.End
+	}
+	return
+}
+
+// readBlock reads the header of a block and returns the number of items it
+// contains, zero means there are no more blocks.
+func (p *Parser) readBlock() (n int64, err error) {
+	if n, err = p.readLong(); err != nil || n >= 0 {
+		return
+	}
+
+	// A negative count is followed by the size of the block in bytes, which
+	// is only useful to skip blocks.
+	if _, err = p.readLong(); err == nil {
+		n = -n
+	}
+	return
+}
+
+func (p *Parser) pop() {
+	p.stack = p.stack[:len(p.stack)-1]
+}
+
+func (p *Parser) readByte() (byte, error) {
+	c, err := p.r.ReadByte()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return c, err
+}
+
+func (p *Parser) readLong() (int64, error) {
+	v, err := readLong(p.r)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return v, err
+}
+
+func (p *Parser) readBytes() ([]byte, error) {
+	n, err := p.readLong()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if n < 0 || n > math.MaxInt32 {
+		return nil, fmt.Errorf("objconv/avro: invalid length of string or bytes: %d", n)
+	}
+
+	return p.read(int(n))
+}
+
+func (p *Parser) read(n int) ([]byte, error) {
+	// The length may come from the input, the buffer grows as bytes are read
+	// instead of being allocated upfront.
+	b, err := objutil.AppendFull(p.b[:0], p.r, n)
+	p.b = b
+
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	return b, nil
+}
+
+var errVarintOverflow = errors.New("objconv/avro: varint overflows a 64 bits integer")
@@ -0,0 +1,277 @@
+package avro
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/segmentio/objconv/json"
+)
+
+// Schema represents a parsed Avro schema.
+type Schema struct {
+	// Type is the name of the Avro type, one of the constants declared by this
+	// package (Null, Boolean, ..., Fixed).
+	Type string
+
+	// Name is the full name of records, enums and fixed types.
+	Name string
+
+	// Fields of records, in the order they are encoded.
+	Fields []Field
+
+	// Symbols of enums.
+	Symbols []string
+
+	// Items is the schema of array elements.
+	Items *Schema
+
+	// Values is the schema of map values.
+	Values *Schema
+
+	// Types is the list of branches of unions.
+	Types []*Schema
+
+	// Size is the number of bytes of fixed values.
+	Size int
+}
+
+// Field represents a field of a record schema.
+type Field struct {
+	Name string
+	Type *Schema
+}
+
+// typeName returns the name identifying s in a union.
+func (s *Schema) typeName() string {
+	if len(s.Name) != 0 {
+		return s.Name
+	}
+	return s.Type
+}
+
+func (s *Schema) fieldIndex(name string) int {
+	for i, f := range s.Fields {
+		if f.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Schema) symbolIndex(name string) int {
+	for i, x := range s.Symbols {
+		if x == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Schema) branchIndex(name string) int {
+	for i, t := range s.Types {
+		if t.typeName() == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// ParseSchema parses the JSON representation of an Avro schema.
+//
+// Logical types and default values are ignored, values are read and written
+// with their underlying types.
+func ParseSchema(b []byte) (*Schema, error) {
+	var v interface{}
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+
+	return (&schemaParser{names: make(map[string]*Schema)}).parse(v, "")
+}
+
+// MustParseSchema is like ParseSchema but panics if the schema is invalid, it
+// simplifies declaring schemas in global variables.
+func MustParseSchema(s string) *Schema {
+	schema, err := ParseSchema([]byte(s))
+	if err != nil {
+		panic(err)
+	}
+	return schema
+}
+
+type schemaParser struct {
+	names map[string]*Schema // named types that were already declared
+}
+
+func (p *schemaParser) parse(v interface{}, namespace string) (*Schema, error) {
+	switch x := v.(type) {
+	case string:
+		return p.parseName(x, namespace)
+
+	case []interface{}:
+		return p.parseUnion(x, namespace)
+
+	case map[interface{}]interface{}:
+		return p.parseObject(x, namespace)
+	}
+
+	return nil, fmt.Errorf("objconv/avro: invalid schema: %v", v)
+}
+
+func (p *schemaParser) parseName(name string, namespace string) (*Schema, error) {
+	switch name {
+	case Null, Boolean, Int, Long, Float, Double, Bytes, String:
+		return &Schema{Type: name}, nil
+	}
+
+	if s := p.names[fullName(name, namespace)]; s != nil {
+		return s, nil
+	}
+
+	if s := p.names[name]; s != nil {
+		return s, nil
+	}
+
+	return nil, fmt.Errorf("objconv/avro: unknown type in schema: %s", name)
+}
+
+func (p *schemaParser) parseUnion(types []interface{}, namespace string) (*Schema, error) {
+	s := &Schema{Type: Union, Types: make([]*Schema, len(types))}
+
+	for i, t := range types {
+		var err error
+
+		if s.Types[i], err = p.parse(t, namespace); err != nil {
+			return nil, err
+		}
+
+		if s.Types[i].Type == Union {
+			return nil, fmt.Errorf("objconv/avro: unions cannot be nested in other unions")
+		}
+
+		if s.branchIndex(s.Types[i].typeName()) != i {
+			return nil, fmt.Errorf("objconv/avro: duplicate type in union: %s", s.Types[i].typeName())
+		}
+	}
+
+	return s, nil
+}
+
+func (p *schemaParser) parseObject(obj map[interface{}]interface{}, namespace string) (s *Schema, err error) {
+	t, _ := obj["type"].(string)
+
+	switch t {
+	case Record, "error", Enum, Fixed:
+		if s, err = p.declare(obj, namespace); err != nil {
+			return
+		}
+		namespace = s.Name[:strings.LastIndexByte(s.Name, '.')+1]
+		namespace = strings.TrimSuffix(namespace, ".")
+	}
+
+	switch t {
+	case Record, "error":
+		s.Type = Record
+		err = p.parseFields(s, obj["fields"], namespace)
+
+	case Enum:
+		symbols, _ := obj["symbols"].([]interface{})
+		s.Symbols = make([]string, len(symbols))
+
+		for i, sym := range symbols {
+			if s.Symbols[i], _ = sym.(string); len(s.Symbols[i]) == 0 {
+				err = fmt.Errorf("objconv/avro: invalid symbol in enum %s: %v", s.Name, sym)
+				return
+			}
+		}
+
+	case Fixed:
+		size, ok := obj["size"].(int64)
+
+		if !ok || size < 0 {
+			err = fmt.Errorf("objconv/avro: invalid size of fixed %s: %v", s.Name, obj["size"])
+			return
+		}
+
+		s.Size = int(size)
+
+	case Array:
+		s = &Schema{Type: Array}
+		s.Items, err = p.parse(obj["items"], namespace)
+
+	case Map:
+		s = &Schema{Type: Map}
+		s.Values, err = p.parse(obj["values"], namespace)
+
+	default:
+		// Primitive types may be written as objects, for example when they
+		// have a logical type.
+		s, err = p.parse(obj["type"], namespace)
+	}
+
+	if err != nil {
+		s = nil
+	}
+	return
+}
+
+// declare registers the named type described by obj, it is registered before
+// being fully parsed so it can reference itself (in linked lists for example).
+func (p *schemaParser) declare(obj map[interface{}]interface{}, namespace string) (*Schema, error) {
+	name, _ := obj["name"].(string)
+
+	if len(name) == 0 {
+		return nil, fmt.Errorf("objconv/avro: missing name of %s type in schema", obj["type"])
+	}
+
+	if ns, ok := obj["namespace"].(string); ok {
+		namespace = ns
+	}
+
+	name = fullName(name, namespace)
+
+	if p.names[name] != nil {
+		return nil, fmt.Errorf("objconv/avro: type %s is declared twice in schema", name)
+	}
+
+	s := &Schema{Type: obj["type"].(string), Name: name}
+	p.names[name] = s
+	return s, nil
+}
+
+func (p *schemaParser) parseFields(s *Schema, fields interface{}, namespace string) error {
+	list, ok := fields.([]interface{})
+
+	if !ok {
+		return fmt.Errorf("objconv/avro: invalid fields of record %s: %v", s.Name, fields)
+	}
+
+	s.Fields = make([]Field, len(list))
+
+	for i, f := range list {
+		obj, _ := f.(map[interface{}]interface{})
+		name, _ := obj["name"].(string)
+
+		if len(name) == 0 {
+			return fmt.Errorf("objconv/avro: invalid field of record %s: %v", s.Name, f)
+		}
+
+		t, err := p.parse(obj["type"], namespace)
+
+		if err != nil {
+			return err
+		}
+
+		s.Fields[i] = Field{Name: name, Type: t}
+	}
+
+	return nil
+}
+
+func fullName(name string, namespace string) string {
+	if len(namespace) == 0 || strings.IndexByte(name, '.') >= 0 {
+		return name
+	}
+	return namespace + "." + name
+}
@@ -0,0 +1,148 @@
+package cbor
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"sort"
+	"sync"
+)
+
+// NewCanonicalEmitter returns a new emitter that outputs the deterministic
+// encoding of values defined in RFC 8949 section 4.2: integers, lengths and
+// floating point numbers use their shortest form, arrays and maps always have
+// a definite length, and map entries are sorted by the bytewise lexicographic
+// order of the encoding of their keys.
+//
+// The content of maps, and of arrays of unknown length, is cached in memory
+// until the end of the value is reached.
+func NewCanonicalEmitter(w io.Writer) *Emitter {
+	e := NewEmitter(w)
+	e.canonical = true
+	return e
+}
+
+// context is used by canonical emitters to cache the content of arrays and
+// maps until their length is known and the map entries can be sorted.
+type context struct {
+	b      bytes.Buffer // buffer where the elements are cached
+	w      io.Writer    // the previous writer where b will be flushed
+	n      int          // the number of elements written to an array
+	keys   []int        // offsets of the map keys in b
+	values []int        // offsets of the map values in b
+}
+
+func (e *Emitter) beginCanonical(m byte, n int) (err error) {
+	var c *context
+
+	if m == majorType5 || n < 0 {
+		c = contextPool.Get().(*context)
+		c.b.Truncate(0)
+		c.n = 0
+		c.keys = append(c.keys[:0], 0)
+		c.values = c.values[:0]
+		c.w = e.w
+		e.w = &c.b
+	} else {
+		err = e.emitUint(m, uint64(n))
+	}
+
+	e.cstack = append(e.cstack, c)
+	return
+}
+
+func (e *Emitter) endCanonical(m byte) (err error) {
+	i := len(e.cstack) - 1
+	c := e.cstack[i]
+	e.cstack = e.cstack[:i]
+
+	if c == nil {
+		return
+	}
+
+	e.w = c.w
+
+	if m == majorType5 {
+		err = e.flushMap(c)
+	} else {
+		if c.b.Len() != 0 {
+			c.n++
+		}
+		if err = e.emitUint(m, uint64(c.n)); err == nil {
+			_, err = c.b.WriteTo(e.w)
+		}
+	}
+
+	contextPool.Put(c)
+	return
+}
+
+func (e *Emitter) flushMap(c *context) (err error) {
+	b := c.b.Bytes()
+	n := len(c.values)
+	entries := make([]mapEntry, n)
+
+	for i := range entries {
+		end := len(b)
+		if i+1 < n {
+			end = c.keys[i+1]
+		}
+		entries[i] = mapEntry{
+			key:   b[c.keys[i]:c.values[i]],
+			entry: b[c.keys[i]:end],
+		}
+	}
+
+	sort.Slice(entries, func(i int, j int) bool {
+		return bytes.Compare(entries[i].key, entries[j].key) < 0
+	})
+
+	if err = e.emitUint(majorType5, uint64(n)); err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		if _, err = e.w.Write(entry.entry); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+type mapEntry struct {
+	key   []byte
+	entry []byte
+}
+
+// emitShortestFloat writes v using the smallest of the half, single, and double
+// precision representations which preserves its value, NaN values are always
+// encoded as the half precision quiet NaN (0xf97e00).
+func (e *Emitter) emitShortestFloat(v float64) (err error) {
+	n := 0
+
+	if math.IsNaN(v) {
+		n = 3
+		e.b[0] = majorByte(majorType7, svFloat16)
+		putUint16(e.b[1:], 0x7e00)
+	} else if f := float32(v); float64(f) != v {
+		n = 9
+		e.b[0] = majorByte(majorType7, svFloat64)
+		putUint64(e.b[1:], math.Float64bits(v))
+	} else if h, ok := f32tof16bits(math.Float32bits(f)); ok {
+		n = 3
+		e.b[0] = majorByte(majorType7, svFloat16)
+		putUint16(e.b[1:], h)
+	} else {
+		n = 5
+		e.b[0] = majorByte(majorType7, svFloat32)
+		putUint32(e.b[1:], math.Float32bits(f))
+	}
+
+	_, err = e.w.Write(e.b[:n])
+	return
+}
+
+var contextPool = sync.Pool{
+	New: func() interface{} { return &context{} },
+}
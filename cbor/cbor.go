@@ -1,3 +1,13 @@
+// Package cbor implements a parser and an emitter for the Concise Binary Object
+// Representation format (RFC 8949).
+//
+// CBOR major types are mapped onto the objconv types, text strings are parsed
+// as String values and byte strings as Bytes values. Items with the tags 0 and
+// 1 (standard date/time strings and epoch-based timestamps) are parsed as Time
+// values, other tags are ignored and the tagged items are parsed as their base
+// type. Indefinite-length arrays and maps have a length of -1, as expected by
+// the decoder, and the emitter writes them when the length of a value isn't
+// known in advance, unless it is a canonical emitter.
 package cbor
 
 import (
@@ -37,8 +47,10 @@ const ( // simple values
 )
 
 const ( // tags
-	tagDateTime  = 0
-	tagTimestamp = 1
+	tagDateTime           = 0
+	tagTimestamp          = 1
+	tagStringRef          = 25
+	tagStringRefNamespace = 256
 )
 
 const (
@@ -80,13 +92,6 @@ func getUint64(b []byte) uint64 {
 	return binary.BigEndian.Uint64(b)
 }
 
-func align(n int, a int) int {
-	if (n % a) == 0 {
-		return n
-	}
-	return ((n / a) + 1) * a
-}
-
 func f16tof32bits(yy uint16) (d uint32) {
 	y := uint32(yy)
 	s := (y >> 15) & 0x01
@@ -117,3 +122,35 @@ func f16tof32bits(yy uint16) (d uint32) {
 	m = m << 13
 	return (s << 31) | (e << 23) | m
 }
+
+// f32tof16bits converts the bits of a single precision floating point number
+// to half precision, ok is false if the conversion would lose precision. NaN
+// values are not supported.
+func f32tof16bits(f uint32) (h uint16, ok bool) {
+	s := uint16(f>>16) & 0x8000
+	e := int((f >> 23) & 0xff)
+	m := f & 0x007fffff
+
+	switch {
+	case e == 0xff: // Inf
+		return s | 0x7c00, m == 0
+
+	case e == 0 && m == 0: // +/- 0
+		return s, true
+
+	case e == 0: // denormalized numbers are too small for half precision
+		return 0, false
+	}
+
+	switch e -= 127; {
+	case e >= -14 && e <= 15:
+		return s | uint16(e+15)<<10 | uint16(m>>13), (m & 0x1fff) == 0
+
+	case e >= -24 && e < -14:
+		m |= 0x00800000
+		shift := uint(-e - 1)
+		return s | uint16(m>>shift), (m & (1<<shift - 1)) == 0
+	}
+
+	return 0, false
+}
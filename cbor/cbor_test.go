@@ -1,8 +1,17 @@
 package cbor
 
 import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"math"
+	"reflect"
+	"runtime"
+	"strconv"
 	"testing"
+	"time"
 
+	"github.com/segmentio/objconv"
 	"github.com/segmentio/objconv/objtests"
 )
 
@@ -25,3 +34,220 @@ func TestMajorType(t *testing.T) {
 		t.Error("bad info value:", b)
 	}
 }
+
+func TestCanonicalCodec(t *testing.T) {
+	objtests.TestCodec(t, CanonicalCodec)
+}
+
+func TestCanonicalFloat(t *testing.T) {
+	tests := []struct {
+		v float64
+		s string
+	}{
+		{0, "f90000"},
+		{1.5, "f93e00"},
+		{-4, "f9c400"},
+		{65504, "f97bff"},
+		{5.960464477539063e-8, "f90001"},
+		{100000, "fa47c35000"},
+		{3.4028234663852886e+38, "fa7f7fffff"},
+		{1.1, "fb3ff199999999999a"},
+		{math.Inf(1), "f97c00"},
+		{math.NaN(), "f97e00"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.s, func(t *testing.T) {
+			b := &bytes.Buffer{}
+
+			if err := NewCanonicalEmitter(b).EmitFloat(test.v, 64); err != nil {
+				t.Fatal(err)
+			}
+
+			if s := hex.EncodeToString(b.Bytes()); s != test.s {
+				t.Error(s)
+			}
+		})
+	}
+}
+
+func TestCanonicalMapKeys(t *testing.T) {
+	b := &bytes.Buffer{}
+	m := map[interface{}]interface{}{
+		false: 0,
+		"aa":  1,
+		"z":   2,
+		-1:    3,
+		100:   4,
+		10:    5,
+	}
+
+	// Streams are arrays of unknown length, which are converted to arrays of
+	// definite length.
+	e := objconv.NewStreamEncoder(NewCanonicalEmitter(b))
+
+	if err := e.Encode(m); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := hex.EncodeToString(b.Bytes()); s != "81"+"a6"+"0a05"+"186404"+"2003"+"617a02"+"62616101"+"f400" {
+		t.Error(s)
+	}
+}
+
+func TestStringRefCodec(t *testing.T) {
+	objtests.TestCodec(t, StringRefCodec)
+}
+
+func TestStringRef(t *testing.T) {
+	type Row struct {
+		Name  string    `objconv:"name"`
+		Kind  string    `objconv:"kind"`
+		Data  []byte    `objconv:"data"`
+		Time  time.Time `objconv:"time"`
+		Label string    `objconv:"label"`
+	}
+
+	date := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	rows := make([]Row, 300)
+
+	for i := range rows {
+		rows[i] = Row{
+			Name:  "row-" + strconv.Itoa(i%50),
+			Kind:  "kind-" + strconv.Itoa(i%3),
+			Data:  []byte("kind-0"), // same content as a string, but different type
+			Time:  date,
+			Label: "a",
+		}
+	}
+
+	b1 := &bytes.Buffer{}
+	b2 := &bytes.Buffer{}
+
+	if err := objconv.NewEncoder(NewEmitter(b1)).Encode(rows); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := objconv.NewEncoder(NewStringRefEmitter(b2)).Encode(rows); err != nil {
+		t.Fatal(err)
+	}
+
+	if b2.Len() >= (3*b1.Len())/4 {
+		t.Errorf("the string references didn't shrink the output enough: %d >= 3/4 x %d", b2.Len(), b1.Len())
+	}
+
+	var res []Row
+
+	if err := objconv.NewDecoder(NewParser(b2)).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(rows, res) {
+		t.Error("the values don't match after a round trip with string references")
+	}
+}
+
+func TestParser(t *testing.T) {
+	tests := []struct {
+		hex string
+		val interface{}
+	}{
+		// Examples from RFC 8949, appendix A.
+		{"00", uint64(0)},
+		{"1903e8", uint64(1000)},
+		{"3903e7", int64(-1000)},
+		{"f93e00", float64(1.5)},
+		{"f4", false},
+		{"f6", nil},
+		{"4401020304", []byte{1, 2, 3, 4}},
+		{"6449455446", "IETF"},
+		{"c074323031332d30332d32315432303a30343a30305a", time.Date(2013, 3, 21, 20, 4, 0, 0, time.UTC)},
+		{"c11a514b67b0", time.Unix(1363896240, 0)},
+		{"c1fb41d452d9ec200000", time.Unix(1363896240, 5e8)},
+		{"d74401020304", []byte{1, 2, 3, 4}}, // unsupported tag
+		{"5f42010243030405ff", []byte{1, 2, 3, 4, 5}},
+		{"7f657374726561646d696e67ff", "streaming"},
+		{"9fff", []interface{}{}},
+		{"9f018202039f0405ffff", []interface{}{uint64(1), []interface{}{uint64(2), uint64(3)}, []interface{}{uint64(4), uint64(5)}}},
+		{"bf61610161629f0203ffff", map[interface{}]interface{}{"a": uint64(1), "b": []interface{}{uint64(2), uint64(3)}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.hex, func(t *testing.T) {
+			b, _ := hex.DecodeString(test.hex)
+
+			var v interface{}
+
+			if err := objconv.NewDecoder(NewParser(bytes.NewReader(b))).Decode(&v); err != nil {
+				t.Fatal(err)
+			}
+
+			if tm, ok := v.(time.Time); ok {
+				v = tm.UTC()
+				test.val = test.val.(time.Time).UTC()
+			}
+
+			if !reflect.DeepEqual(v, test.val) {
+				t.Errorf("%#v != %#v", v, test.val)
+			}
+		})
+	}
+}
+
+func TestEmitterIndefiniteLength(t *testing.T) {
+	b := &bytes.Buffer{}
+	e := objconv.NewStreamEncoder(NewEmitter(b))
+
+	for _, v := range []interface{}{1, "a"} {
+		if err := e.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := hex.EncodeToString(b.Bytes()); s != "9f"+"01"+"6161"+"ff" {
+		t.Error(s)
+	}
+}
+
+func TestDecodeLargeLength(t *testing.T) {
+	// The byte string declares a length of 2 GiB, the parser must not allocate it
+	// before the bytes are read from the input.
+	header := []byte{0x5a, 0x7f, 0xff, 0xff, 0xff}
+
+	tests := []struct {
+		in  []byte
+		max int64
+		err error
+	}{
+		{in: append(header, 'A'), err: io.ErrUnexpectedEOF},
+		{in: append(header, make([]byte, 4096)...), max: 1024, err: objconv.ErrTooLarge},
+	}
+
+	for _, test := range tests {
+		var v []byte
+		var m1, m2 runtime.MemStats
+
+		d := NewDecoder(bytes.NewReader(test.in))
+		d.MaxBytes = test.max
+
+		runtime.ReadMemStats(&m1)
+		err := d.Decode(&v)
+		runtime.ReadMemStats(&m2)
+
+		if err != test.err {
+			t.Errorf("expected %v but got %v", test.err, err)
+		}
+
+		if n := m2.TotalAlloc - m1.TotalAlloc; n > 1<<20 {
+			t.Error("too many bytes allocated:", n)
+		}
+	}
+}
@@ -18,6 +18,17 @@ type Emitter struct {
 	// The sback array is the initial backend array for the stack.
 	stack []int
 	sback [16]int
+
+	// In canonical mode, this stack is used to cache the content of maps and
+	// arrays of unknown length, see NewCanonicalEmitter.
+	canonical bool
+	cstack    []*context
+
+	// When stringRefs is true, repeated strings are written as references to
+	// their first occurrence, see NewStringRefEmitter.
+	stringRefs bool
+	refs       map[stringRef]int
+	nrefs      int
 }
 
 func NewEmitter(w io.Writer) *Emitter {
@@ -29,6 +40,7 @@ func NewEmitter(w io.Writer) *Emitter {
 func (e *Emitter) Reset(w io.Writer) {
 	e.w = w
 	e.stack = e.stack[:0]
+	e.cstack = e.cstack[:0]
 }
 
 func (e *Emitter) EmitNil() (err error) {
@@ -61,6 +73,10 @@ func (e *Emitter) EmitUint(v uint64, _ int) (err error) {
 func (e *Emitter) EmitFloat(v float64, bitSize int) (err error) {
 	n := 0
 
+	if e.canonical {
+		return e.emitShortestFloat(v)
+	}
+
 	if bitSize == 32 {
 		n = 5
 		e.b[0] = majorByte(majorType7, svFloat32)
@@ -76,6 +92,12 @@ func (e *Emitter) EmitFloat(v float64, bitSize int) (err error) {
 }
 
 func (e *Emitter) EmitString(v string) (err error) {
+	if e.stringRefs {
+		if ok, err := e.emitStringRef(v, false); ok || err != nil {
+			return err
+		}
+	}
+
 	if err = e.emitUint(majorType3, uint64(len(v))); err != nil {
 		return
 	}
@@ -101,6 +123,12 @@ func (e *Emitter) EmitString(v string) (err error) {
 }
 
 func (e *Emitter) EmitBytes(v []byte) (err error) {
+	if e.stringRefs {
+		if ok, err := e.emitStringRef(string(v), true); ok || err != nil {
+			return err
+		}
+	}
+
 	if err = e.emitUint(majorType2, uint64(len(v))); err != nil {
 		return
 	}
@@ -120,6 +148,10 @@ func (e *Emitter) EmitTime(v time.Time) (err error) {
 	var a [64]byte
 	var b = v.AppendFormat(a[:0], time.RFC3339Nano)
 
+	if e.stringRefs && len(e.stack) != 0 {
+		e.registerStringRef(stringRef{s: string(b)})
+	}
+
 	if err = e.emitUint(majorType3, uint64(len(b))); err != nil {
 		return
 	}
@@ -137,6 +169,16 @@ func (e *Emitter) EmitError(v error) (err error) {
 }
 
 func (e *Emitter) EmitArrayBegin(n int) (err error) {
+	if e.canonical {
+		return e.beginCanonical(majorType4, n)
+	}
+
+	if e.stringRefs {
+		if err = e.beginStringRefNamespace(); err != nil {
+			return
+		}
+	}
+
 	e.stack = append(e.stack, n)
 
 	if n >= 0 {
@@ -149,6 +191,10 @@ func (e *Emitter) EmitArrayBegin(n int) (err error) {
 }
 
 func (e *Emitter) EmitArrayEnd() (err error) {
+	if e.canonical {
+		return e.endCanonical(majorType4)
+	}
+
 	i := len(e.stack) - 1
 	n := e.stack[i]
 	e.stack = e.stack[:i]
@@ -161,10 +207,25 @@ func (e *Emitter) EmitArrayEnd() (err error) {
 }
 
 func (e *Emitter) EmitArrayNext() (err error) {
+	if e.canonical {
+		if c := e.cstack[len(e.cstack)-1]; c != nil {
+			c.n++
+		}
+	}
 	return
 }
 
 func (e *Emitter) EmitMapBegin(n int) (err error) {
+	if e.canonical {
+		return e.beginCanonical(majorType5, n)
+	}
+
+	if e.stringRefs {
+		if err = e.beginStringRefNamespace(); err != nil {
+			return
+		}
+	}
+
 	e.stack = append(e.stack, n)
 
 	if n >= 0 {
@@ -177,6 +238,10 @@ func (e *Emitter) EmitMapBegin(n int) (err error) {
 }
 
 func (e *Emitter) EmitMapEnd() (err error) {
+	if e.canonical {
+		return e.endCanonical(majorType5)
+	}
+
 	i := len(e.stack) - 1
 	n := e.stack[i]
 	e.stack = e.stack[:i]
@@ -189,10 +254,18 @@ func (e *Emitter) EmitMapEnd() (err error) {
 }
 
 func (e *Emitter) EmitMapValue() (err error) {
+	if e.canonical {
+		c := e.cstack[len(e.cstack)-1]
+		c.values = append(c.values, c.b.Len())
+	}
 	return
 }
 
 func (e *Emitter) EmitMapNext() (err error) {
+	if e.canonical {
+		c := e.cstack[len(e.cstack)-1]
+		c.keys = append(c.keys, c.b.Len())
+	}
 	return
 }
 
@@ -12,6 +12,20 @@ var Codec = objconv.Codec{
 	NewParser:  func(r io.Reader) objconv.Parser { return NewParser(r) },
 }
 
+// CanonicalCodec for the deterministic encoding of the CBOR format, see
+// NewCanonicalEmitter.
+var CanonicalCodec = objconv.Codec{
+	NewEmitter: func(w io.Writer) objconv.Emitter { return NewCanonicalEmitter(w) },
+	NewParser:  func(r io.Reader) objconv.Parser { return NewParser(r) },
+}
+
+// StringRefCodec for the CBOR format with the stringref extension, see
+// NewStringRefEmitter.
+var StringRefCodec = objconv.Codec{
+	NewEmitter: func(w io.Writer) objconv.Emitter { return NewStringRefEmitter(w) },
+	NewParser:  func(r io.Reader) objconv.Parser { return NewParser(r) },
+}
+
 func init() {
 	for _, name := range [...]string{
 		"application/cbor",
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/segmentio/objconv"
+	"github.com/segmentio/objconv/objutil"
 )
 
 type Parser struct {
@@ -26,6 +27,14 @@ type Parser struct {
 	// The sback array is the initial backend array for the stack.
 	stack []int
 	sback [16]int
+
+	// Strings of the current stringref namespace, and index of the string
+	// referenced by the next item when tag is tagStringRef.
+	namespace bool
+	refs      []parsedStringRef
+	ref       int
+
+	raw objutil.RawRecorder // records raw bytes between BeginRaw and EndRaw
 }
 
 func NewParser(r io.Reader) *Parser {
@@ -41,12 +50,40 @@ func (p *Parser) Reset(r io.Reader) {
 	p.j = 0
 	p.tag = noTag
 	p.stack = p.stack[:0]
+	p.namespace = false
+	p.refs = p.refs[:0]
 }
 
 func (p *Parser) Buffered() io.Reader {
 	return bytes.NewReader(p.b[p.i:p.j])
 }
 
+// InMemory returns true if the parser reads from a buffer held in memory.
+func (p *Parser) InMemory() bool {
+	return objutil.InMemory(p.r)
+}
+
+// Remaining returns the bytes of the input that haven't been parsed yet, the
+// parser has reached the end of its input after the method returns.
+func (p *Parser) Remaining() (rest []byte, err error) {
+	if rest, err = objutil.Remaining(p.r, p.b[p.i:p.j]); err == nil {
+		p.Reset(p.r)
+	}
+	return
+}
+
+// BeginRaw starts recording the raw bytes of the values being parsed.
+func (p *Parser) BeginRaw() {
+	p.r = p.raw.Begin(p.r, p.b[p.i:p.j])
+}
+
+// EndRaw stops recording and returns the raw bytes of the values parsed since
+// BeginRaw was called.
+func (p *Parser) EndRaw() (raw []byte) {
+	raw, p.r = p.raw.End(p.j - p.i)
+	return
+}
+
 func (p *Parser) ParseType() (typ objconv.Type, err error) {
 	if p.tag != noTag {
 		typ = p.typ
@@ -96,8 +133,23 @@ func (p *Parser) ParseType() (typ objconv.Type, err error) {
 			switch p.tag {
 			case tagDateTime, tagTimestamp:
 				typ = objconv.Time
+			case tagStringRef:
+				if typ, err = p.parseStringRef(); err != nil {
+					return
+				}
+			case tagStringRefNamespace:
+				p.beginStringRefNamespace()
+				p.tag = noTag
+				if s, err = p.peek(1); err != nil {
+					return
+				}
+				continue
 			default: // unsupported tag, just fallback to use the base type
 				t = true
+				p.tag = noTag
+				if s, err = p.peek(1); err != nil {
+					return
+				}
 				continue
 			}
 			p.typ = typ
@@ -214,17 +266,27 @@ func (p *Parser) ParseFloat() (v float64, err error) {
 }
 
 func (p *Parser) ParseString() (v []byte, err error) {
+	if p.tag == tagStringRef {
+		v, p.tag = p.refs[p.ref].b, noTag
+		return
+	}
 	if v, err = p.parseBytes(majorType3); err != nil {
 		return
 	}
+	p.registerStringRef(v, objconv.String)
 	p.tag = noTag
 	return
 }
 
 func (p *Parser) ParseBytes() (v []byte, err error) {
+	if p.tag == tagStringRef {
+		v, p.tag = p.refs[p.ref].b, noTag
+		return
+	}
 	if v, err = p.parseBytes(majorType2); err != nil {
 		return
 	}
+	p.registerStringRef(v, objconv.Bytes)
 	p.tag = noTag
 	return
 }
@@ -494,15 +556,6 @@ func (p *Parser) parseType7() (b byte, err error) {
 }
 
 func (p *Parser) load(n int) (b []byte, err error) {
-	i := len(p.s)
-	j := i + n
-
-	if cap(p.s) < j {
-		p.s = make([]byte, j, align(j, 1024))
-	} else {
-		p.s = p.s[:j]
-	}
-
 	if p.i != p.j {
 		n1 := n
 		n2 := p.j - p.i
@@ -511,18 +564,23 @@ func (p *Parser) load(n int) (b []byte, err error) {
 			n1 = n2
 		}
 
-		copy(p.s[i:], p.b[p.i:p.i+n1])
+		p.s = append(p.s, p.b[p.i:p.i+n1]...)
 
 		if p.i += n1; p.i == p.j {
 			p.i = 0
 			p.j = 0
 		}
 
-		i += n1
+		n -= n1
 	}
 
-	if i != j {
-		if _, err = io.ReadFull(p.r, p.s[i:]); err != nil {
+	// The length comes from the input, the buffer grows as bytes are read
+	// instead of being allocated upfront.
+	if n != 0 {
+		if p.s, err = objutil.AppendFull(p.s, p.r, n); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
 			return
 		}
 	}
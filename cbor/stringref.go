@@ -0,0 +1,133 @@
+package cbor
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/segmentio/objconv"
+)
+
+// NewStringRefEmitter returns a new emitter which deduplicates repeated strings
+// using the stringref extension of CBOR (http://cbor.schmorp.de/stringref).
+//
+// Each array or map written at the top level is enclosed in a stringref
+// namespace (tag 256), text and byte strings repeated within the namespace are
+// written as references (tag 25) to the index of their first occurrence.
+// Strings are only registered when a reference would be shorter than the
+// string itself, so the output is never larger than without the extension.
+//
+// Parsers returned by NewParser support decoding references, other decoders
+// must implement the extension to read the output of this emitter.
+func NewStringRefEmitter(w io.Writer) *Emitter {
+	e := NewEmitter(w)
+	e.stringRefs = true
+	return e
+}
+
+type stringRef struct {
+	s     string
+	bytes bool
+}
+
+// stringRefMinLength returns the minimum length of strings registered in a
+// namespace which already contains n strings.
+func stringRefMinLength(n int) int {
+	switch {
+	case n < 24:
+		return 3
+	case n < 256:
+		return 4
+	case n < 65536:
+		return 5
+	case uint64(n) < 4294967296:
+		return 7
+	default:
+		return 11
+	}
+}
+
+func (e *Emitter) beginStringRefNamespace() (err error) {
+	if len(e.stack) != 0 {
+		return
+	}
+
+	if e.refs == nil {
+		e.refs = make(map[stringRef]int)
+	}
+
+	for k := range e.refs {
+		delete(e.refs, k)
+	}
+
+	e.nrefs = 0
+	return e.emitUint(majorType6, tagStringRefNamespace)
+}
+
+// emitStringRef writes a reference to s if it was already seen in the current
+// namespace, or registers it, ok is true if a reference was written.
+func (e *Emitter) emitStringRef(s string, bytes bool) (ok bool, err error) {
+	if len(e.stack) == 0 {
+		return // top-level strings are not enclosed in a namespace
+	}
+
+	k := stringRef{s: s, bytes: bytes}
+
+	if i, exists := e.refs[k]; exists {
+		if err = e.emitUint(majorType6, tagStringRef); err == nil {
+			err = e.emitUint(majorType0, uint64(i))
+		}
+		return true, err
+	}
+
+	e.registerStringRef(k)
+	return
+}
+
+// registerStringRef must be called for every string written in a namespace
+// (except references), the indexes must match the ones computed by parsers.
+func (e *Emitter) registerStringRef(k stringRef) {
+	if len(k.s) >= stringRefMinLength(e.nrefs) {
+		if _, exists := e.refs[k]; !exists {
+			e.refs[k] = e.nrefs
+		}
+		e.nrefs++
+	}
+}
+
+func (p *Parser) beginStringRefNamespace() {
+	p.namespace = true
+	p.refs = p.refs[:0]
+}
+
+// registerStringRef records the string v in the namespace of the parser.
+func (p *Parser) registerStringRef(v []byte, t objconv.Type) {
+	if p.namespace && len(v) >= stringRefMinLength(len(p.refs)) {
+		p.refs = append(p.refs, parsedStringRef{
+			b: append([]byte(nil), v...),
+			t: t,
+		})
+	}
+}
+
+func (p *Parser) parseStringRef() (t objconv.Type, err error) {
+	var u uint64
+	var indef bool
+
+	if u, indef, err = p.parseUint(); err != nil {
+		return
+	}
+
+	if indef || u >= uint64(len(p.refs)) {
+		err = fmt.Errorf("objconv/cbor: invalid string reference %d in a namespace of %d strings", u, len(p.refs))
+		return
+	}
+
+	p.ref = int(u)
+	t = p.refs[p.ref].t
+	return
+}
+
+type parsedStringRef struct {
+	b []byte
+	t objconv.Type
+}
@@ -1,6 +1,8 @@
 package objconv
 
 import (
+	"bytes"
+	"fmt"
 	"io"
 	"sync"
 )
@@ -18,7 +20,10 @@ func (c Codec) NewEncoder(w io.Writer) *Encoder {
 
 // NewDecoder returns a new decoder that takes input from r.
 func (c Codec) NewDecoder(r io.Reader) *Decoder {
-	return NewDecoder(c.NewParser(r))
+	l := &limitReader{r: r}
+	d := NewDecoder(c.NewParser(l))
+	d.input = l
+	return d
 }
 
 // NewStreamEncoder returns a new stream encoder that outputs to w.
@@ -28,7 +33,54 @@ func (c Codec) NewStreamEncoder(w io.Writer) *StreamEncoder {
 
 // NewStreamDecoder returns a new stream decoder that takes input from r.
 func (c Codec) NewStreamDecoder(r io.Reader) *StreamDecoder {
-	return NewStreamDecoder(c.NewParser(r))
+	l := &limitReader{r: r}
+	d := NewStreamDecoder(c.NewParser(l))
+	d.input = l
+	return d
+}
+
+// limitReader reads from r and fails with ErrTooLarge after more than max bytes
+// were read since it was last reset, see Decoder.MaxBytes.
+type limitReader struct {
+	r   io.Reader
+	n   int64 // bytes read since the last reset
+	max int64 // zero means no limit
+}
+
+func (l *limitReader) Read(b []byte) (int, error) {
+	if l.max <= 0 {
+		return l.r.Read(b)
+	}
+
+	left := l.max - l.n
+
+	if left < 0 {
+		return 0, ErrTooLarge
+	}
+
+	// One more byte than allowed is read so the end of the input can still be
+	// reached when exactly max bytes are left.
+	if int64(len(b)) > left+1 {
+		b = b[:left+1]
+	}
+
+	n, err := l.r.Read(b)
+
+	if l.n += int64(n); l.n > l.max {
+		return n - int(l.n-l.max), ErrTooLarge
+	}
+
+	return n, err
+}
+
+// Unwrap returns the reader that l wraps, which lets parsers find out whether
+// their input is held in memory.
+func (l *limitReader) Unwrap() io.Reader {
+	return l.r
+}
+
+func (l *limitReader) reset(max int64) {
+	l.n, l.max = 0, max
 }
 
 // A Registry associates mime types to codecs.
@@ -102,3 +154,17 @@ func Lookup(mimetype string) (Codec, bool) {
 func Codecs() map[string]Codec {
 	return registry.Codecs()
 }
+
+// Unmarshal decodes b into a new value of type T with the codec registered for
+// mimetype in the global registry, and returns it. The zero-value of T is
+// returned when decoding fails, see DecodeInto.
+func Unmarshal[T any](b []byte, mimetype string) (v T, err error) {
+	codec, ok := Lookup(mimetype)
+
+	if !ok {
+		err = fmt.Errorf("objconv: no codec registered for %s", mimetype)
+		return
+	}
+
+	return DecodeInto[T](codec.NewDecoder(bytes.NewReader(b)))
+}
@@ -0,0 +1,33 @@
+package objconv_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/segmentio/objconv"
+	_ "github.com/segmentio/objconv/json"
+)
+
+func TestUnmarshal(t *testing.T) {
+	type T struct {
+		A []int `objconv:"a"`
+	}
+
+	v, err := objconv.Unmarshal[T]([]byte(`{"a":[1,2]}`), "application/json")
+	if err != nil || !reflect.DeepEqual(v, T{A: []int{1, 2}}) {
+		t.Errorf("%#v %v", v, err)
+	}
+
+	p, err := objconv.Unmarshal[*T]([]byte(`{"a":[3]}`), "json")
+	if err != nil || p == nil || !reflect.DeepEqual(*p, T{A: []int{3}}) {
+		t.Errorf("%#v %v", p, err)
+	}
+
+	if v, err := objconv.Unmarshal[T]([]byte(`{"a":[1,"2"]}`), "application/json"); err == nil || v.A != nil {
+		t.Errorf("%#v %v", v, err)
+	}
+
+	if _, err := objconv.Unmarshal[T](nil, "application/unknown"); err == nil || err.Error() != "objconv: no codec registered for application/unknown" {
+		t.Error("bad error:", err)
+	}
+}
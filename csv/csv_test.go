@@ -0,0 +1,130 @@
+package csv
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/segmentio/objconv"
+)
+
+const document = `name,age,score,active,since
+Luke,19,1.5,true,2h
+Leia,,2,false,
+`
+
+func TestUntyped(t *testing.T) {
+	var rows []map[string]interface{}
+
+	if err := objconv.NewDecoder(NewParser(strings.NewReader(document))).Decode(&rows); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(rows, []map[string]interface{}{
+		{"name": "Luke", "age": "19", "score": "1.5", "active": "true", "since": "2h"},
+		{"name": "Leia", "age": "", "score": "2", "active": "false", "since": ""},
+	}) {
+		t.Errorf("%#v", rows)
+	}
+}
+
+func TestTyped(t *testing.T) {
+	var rows []map[string]interface{}
+
+	p := NewParser(strings.NewReader(document))
+	p.Types = map[string]objconv.Type{
+		"age":    objconv.Int,
+		"score":  objconv.Float,
+		"active": objconv.Bool,
+		"since":  objconv.Duration,
+	}
+
+	if err := objconv.NewDecoder(p).Decode(&rows); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(rows, []map[string]interface{}{
+		{"name": "Luke", "age": int64(19), "score": 1.5, "active": true, "since": 2 * time.Hour},
+		{"name": "Leia", "age": nil, "score": 2.0, "active": false, "since": nil},
+	}) {
+		t.Errorf("%#v", rows)
+	}
+
+	if !reflect.DeepEqual(p.Header(), []string{"name", "age", "score", "active", "since"}) {
+		t.Errorf("bad header: %#v", p.Header())
+	}
+}
+
+func TestStream(t *testing.T) {
+	type row struct {
+		Name string `objconv:"name"`
+		Age  int    `objconv:"age"`
+	}
+
+	p := NewParser(strings.NewReader("name,age\nLuke,19\nLeia,20\n"))
+	p.Types = map[string]objconv.Type{"age": objconv.Int}
+	d := objconv.NewStreamDecoder(p)
+
+	var rows []row
+
+	for {
+		var r row
+		if err := d.Decode(&r); err == objconv.End {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, r)
+	}
+
+	if !reflect.DeepEqual(rows, []row{{"Luke", 19}, {"Leia", 20}}) {
+		t.Errorf("%#v", rows)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		in    string
+		types map[string]objconv.Type
+		err   string
+	}{
+		{
+			in:    "a,b\n1,2\n3,x\n",
+			types: map[string]objconv.Type{"b": objconv.Uint},
+			err:   `objconv/csv: row 2, column "b": "x" is not a valid unsigned integer`,
+		},
+		{
+			in:    "a,b\n1,2\n3\n",
+			types: nil,
+			err:   "objconv/csv: row 2 has 1 values but the header has 2 columns",
+		},
+		{
+			in:    "a\n1\n",
+			types: map[string]objconv.Type{"a": objconv.Map},
+			err:   `objconv/csv: column "a" has the type map which is not supported`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.err, func(t *testing.T) {
+			var v interface{}
+
+			p := NewParser(strings.NewReader(test.in))
+			p.Types = test.types
+
+			if err := objconv.NewDecoder(p).Decode(&v); err == nil || err.Error() != test.err {
+				t.Error("bad error:", err)
+			}
+		})
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	var v interface{}
+
+	if err := objconv.NewDecoder(NewParser(strings.NewReader(""))).Decode(&v); err != io.EOF {
+		t.Error("expected io.EOF but got", err)
+	}
+}
@@ -0,0 +1,267 @@
+// Package csv implements a parser of CSV documents which satisfies the
+// objconv.Parser interface.
+//
+// The first record of a document is a header containing the names of the
+// columns, and each of the following records is parsed as a map of the column
+// names to the values of the record. The document itself is parsed as an array
+// of those maps, which means it can be decoded into a slice of structs or maps,
+// or streamed with an objconv.StreamDecoder.
+package csv
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/objconv"
+)
+
+// Parser implements a parser of CSV documents.
+//
+// Values are parsed as strings unless their column has an entry in Types,
+// which lets the parser expose numbers as numbers when decoding into values
+// that don't define the types of the columns, like map[string]interface{}.
+type Parser struct {
+	// Types maps column names to the types of their values, columns which
+	// aren't in the map are parsed as strings. The supported types are Bool,
+	// Int, Uint, Float, String, Bytes, Time (in the RFC 3339 format) and
+	// Duration (in the format of time.ParseDuration).
+	//
+	// Empty values of columns that have a type other than String or Bytes are
+	// parsed as nil.
+	Types map[string]objconv.Type
+
+	r      *csv.Reader
+	state  parserState
+	header []string
+	record []string
+	row    int // number of the current record, the header excluded
+	col    int // index of the current column
+}
+
+type parserState int
+
+const (
+	stateHeader parserState = iota // before the beginning of the document
+	stateRows                      // in the array of records
+	stateKey                       // on the name of a column
+	stateValue                     // on the value of a column
+	stateDone                      // after the end of the document
+)
+
+// NewParser returns a new parser which reads a CSV document from r.
+func NewParser(r io.Reader) *Parser {
+	return &Parser{r: newReader(r)}
+}
+
+// Reset resets the parser to read a new document from r.
+func (p *Parser) Reset(r io.Reader) {
+	p.r = newReader(r)
+	p.state = stateHeader
+	p.header = nil
+	p.record = nil
+	p.row = 0
+	p.col = 0
+}
+
+// Header returns the names of the columns of the document, or nil if the header
+// wasn't read yet.
+func (p *Parser) Header() []string {
+	return p.header
+}
+
+func (p *Parser) ParseType() (objconv.Type, error) {
+	switch p.state {
+	case stateHeader:
+		if p.header == nil {
+			header, err := p.r.Read()
+			if err != nil {
+				return objconv.Nil, err
+			}
+			p.header = append([]string(nil), header...)
+		}
+		return objconv.Array, nil
+
+	case stateRows:
+		if err := p.load(); err != nil {
+			return objconv.Nil, err
+		}
+		return objconv.Map, nil
+
+	case stateKey:
+		return objconv.String, nil
+
+	case stateValue:
+		return p.valueType()
+
+	default:
+		return objconv.Nil, io.EOF
+	}
+}
+
+func (p *Parser) valueType() (objconv.Type, error) {
+	t, ok := p.Types[p.header[p.col]]
+
+	if !ok {
+		return objconv.String, nil
+	}
+
+	switch t {
+	case objconv.String, objconv.Bytes:
+		return t, nil
+
+	case objconv.Bool, objconv.Int, objconv.Uint, objconv.Float, objconv.Time, objconv.Duration:
+		if len(p.value()) == 0 {
+			return objconv.Nil, nil
+		}
+		return t, nil
+
+	default:
+		return objconv.Nil, fmt.Errorf("objconv/csv: column %q has the type %s which is not supported", p.header[p.col], t)
+	}
+}
+
+func (p *Parser) ParseNil() (err error) {
+	return
+}
+
+func (p *Parser) ParseBool() (v bool, err error) {
+	if v, err = strconv.ParseBool(p.value()); err != nil {
+		err = p.errorf("%q is not a valid boolean", p.value())
+	}
+	return
+}
+
+func (p *Parser) ParseInt() (v int64, err error) {
+	if v, err = strconv.ParseInt(p.value(), 10, 64); err != nil {
+		err = p.errorf("%q is not a valid integer", p.value())
+	}
+	return
+}
+
+func (p *Parser) ParseUint() (v uint64, err error) {
+	if v, err = strconv.ParseUint(p.value(), 10, 64); err != nil {
+		err = p.errorf("%q is not a valid unsigned integer", p.value())
+	}
+	return
+}
+
+func (p *Parser) ParseFloat() (v float64, err error) {
+	if v, err = strconv.ParseFloat(p.value(), 64); err != nil {
+		err = p.errorf("%q is not a valid floating point number", p.value())
+	}
+	return
+}
+
+func (p *Parser) ParseString() (v []byte, err error) {
+	if p.state == stateKey {
+		v = []byte(p.header[p.col])
+	} else {
+		v = []byte(p.value())
+	}
+	return
+}
+
+func (p *Parser) ParseBytes() (v []byte, err error) {
+	v = []byte(p.value())
+	return
+}
+
+func (p *Parser) ParseTime() (v time.Time, err error) {
+	if v, err = time.Parse(time.RFC3339Nano, p.value()); err != nil {
+		err = p.errorf("%q is not a valid time", p.value())
+	}
+	return
+}
+
+func (p *Parser) ParseDuration() (v time.Duration, err error) {
+	if v, err = time.ParseDuration(p.value()); err != nil {
+		err = p.errorf("%q is not a valid duration", p.value())
+	}
+	return
+}
+
+func (p *Parser) ParseError() (v error, err error) {
+	panic("objconv/csv: ParseError should never be called because CSV has no error type, this is likely a bug in the decoder code")
+}
+
+func (p *Parser) ParseArrayBegin() (n int, err error) {
+	p.state = stateRows
+	n = -1
+	return
+}
+
+func (p *Parser) ParseArrayEnd(n int) (err error) {
+	p.state = stateDone
+	return
+}
+
+func (p *Parser) ParseArrayNext(n int) (err error) {
+	return p.load()
+}
+
+func (p *Parser) ParseMapBegin() (n int, err error) {
+	p.state = stateKey
+	p.col = 0
+	n = len(p.header)
+	return
+}
+
+func (p *Parser) ParseMapEnd(n int) (err error) {
+	p.state = stateRows
+	p.record = nil
+	return
+}
+
+func (p *Parser) ParseMapValue(n int) (err error) {
+	p.state = stateValue
+	return
+}
+
+func (p *Parser) ParseMapNext(n int) (err error) {
+	p.state = stateKey
+	p.col = n
+	return
+}
+
+// load reads the next record if the current one was already parsed, it returns
+// objconv.End when there are no more records to read.
+func (p *Parser) load() (err error) {
+	if p.record != nil {
+		return
+	}
+
+	var record []string
+
+	if record, err = p.r.Read(); err != nil {
+		if err == io.EOF {
+			err = objconv.End
+		}
+		return
+	}
+
+	if len(record) != len(p.header) {
+		return fmt.Errorf("objconv/csv: row %d has %d values but the header has %d columns", p.row+1, len(record), len(p.header))
+	}
+
+	p.record = record
+	p.row++
+	return
+}
+
+func (p *Parser) value() string {
+	return p.record[p.col]
+}
+
+func (p *Parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("objconv/csv: row %d, column %q: %s", p.row, p.header[p.col], fmt.Sprintf(format, args...))
+}
+
+func newReader(r io.Reader) *csv.Reader {
+	c := csv.NewReader(r)
+	c.ReuseRecord = true
+	c.FieldsPerRecord = -1 // checked by the parser to report the row number
+	return c
+}
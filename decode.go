@@ -1,11 +1,18 @@
 package objconv
 
 import (
+	"context"
+	"database/sql"
 	"encoding"
 	"errors"
 	"fmt"
+	"io"
+	"iter"
+	"math"
+	"math/big"
 	"reflect"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/segmentio/objconv/objutil"
@@ -23,7 +30,211 @@ type Decoder struct {
 	// there is not destination type (when decoding to an empty interface).
 	MapType reflect.Type
 
-	off int // offset of the value when decoding a map
+	// Adapters sets adapters used by this decoder for the types they are
+	// mapped to, in addition to the ones installed with Install, which they
+	// take precedence over. Adapters with a nil Decode function are ignored,
+	// so the same map can be shared with an encoder. The map must not be
+	// modified while values are being decoded.
+	Adapters map[reflect.Type]Adapter
+
+	// MaxAllocBytes sets a limit on the estimated number of bytes that a single
+	// call to Decode may allocate for strings, byte slices, slices, maps and
+	// pointers. When the limit is exceeded the decoder aborts and returns
+	// ErrMaxAllocBytes. Zero means no limit.
+	MaxAllocBytes int
+
+	// MaxDepth sets a limit on the nesting depth of the arrays and maps of a
+	// decoded value, which protects against inputs crafted to exhaust the
+	// stack of the goroutine. When the limit is exceeded the decoder aborts
+	// and returns ErrMaxDepth. Zero means DefaultMaxDepth, and a negative
+	// value means no limit.
+	MaxDepth int
+
+	// MaxBytes sets a limit on the number of bytes that a single call to
+	// Decode may read from the input. When the limit is exceeded the decoder
+	// aborts and returns ErrTooLarge. Zero means no limit.
+	//
+	// The limit is enforced on the io.Reader that the parser reads from, it
+	// only applies to decoders created by Codec.NewDecoder or the NewDecoder
+	// functions of the format packages. Since parsers buffer their input, the
+	// count includes the bytes that were read ahead of the decoded value.
+	// Parsers of binary formats grow their buffers as bytes are read rather
+	// than from the lengths declared in the input, so the memory used to load
+	// a value is bounded by the limit as well.
+	MaxBytes int64
+
+	// MSDates enables decoding time values from Microsoft JSON dates, which
+	// are strings of the form "/Date(1609459200000)/" or with a timezone
+	// offset like "/Date(1609459200000+0100)/".
+	MSDates bool
+
+	// TimeLayouts is the list of layouts that strings decoded into time values
+	// are parsed with, in order, until one succeeds. When empty, strings are
+	// parsed with time.RFC3339Nano.
+	//
+	// The list may contain TimeUnix or TimeUnixMilli, strings are then also
+	// parsed as numbers of seconds or milliseconds since the Unix epoch, and
+	// numbers are decoded into time values using the first of them in the
+	// list. Decoding numbers into time values fails when neither is listed.
+	TimeLayouts []string
+
+	// KeyPrefix is a prefix that is stripped from map keys before they are
+	// matched against the names of struct fields, for inputs where all keys
+	// are namespaced like "myapp_name". The prefix is compared exactly,
+	// including its case. Keys that don't start with the prefix are discarded
+	// unless MatchUnprefixedKeys is set, in which case they are matched as-is,
+	// and the last of the prefixed and unprefixed keys for the same field in
+	// the input wins. The keys of maps are never modified.
+	KeyPrefix string
+
+	// MatchUnprefixedKeys enables matching keys without the KeyPrefix against
+	// the names of struct fields.
+	MatchUnprefixedKeys bool
+
+	// MultiMap enables accumulating the values of keys repeated in the input
+	// when decoding maps with slice values (other than byte slices), like
+	// map[string][]string or map[string][]interface{}, instead of keeping the
+	// last one. A value which is an array has its elements appended to the
+	// slice of its key, null values append nothing, and any other value is
+	// appended as a single element, so {"a":"x","a":["y","z"]} decodes to
+	// {"a":{"x","y","z"}}. Maps with other value types, structs, and empty
+	// interfaces keep the last value of repeated keys.
+	MultiMap bool
+
+	// UintWraparound enables decoding negative integers into unsigned targets
+	// using two's-complement wraparound, the same way Go converts signed
+	// integers to unsigned types (-1 becomes the maximum value of the type).
+	// The negative value must fit in the signed type of the same size as the
+	// target. By default decoding negative values into unsigned targets fails.
+	UintWraparound bool
+
+	// SkipFunc is called before decoding values nested in arrays, maps and
+	// structs, with the path to the value (map keys, field names, and array
+	// indexes) and its type. When the function returns true the value is
+	// skipped without being loaded, map entries and struct fields are left
+	// untouched while array elements are set to their zero-value.
+	//
+	// The type is the one of the token in the input, not of the value it is
+	// decoded into, so the function can tell whether numbers were quoted: with
+	// Lenient set a string like "5" decodes into a numeric field but the
+	// function receives String, while an unquoted 5 is received as Int.
+	//
+	// The path slice must not be retained by the function.
+	SkipFunc func(path []string, t Type) bool
+
+	// CollectErrors enables collecting the errors that occur when decoding
+	// values nested in arrays, maps and structs instead of aborting on the
+	// first one. Values that failed to decode are skipped, and Decode returns
+	// a FieldErrors value listing the errors in the order the values appear
+	// in the input. Syntax errors reported by the parser still abort the
+	// decoding and are returned as-is.
+	//
+	// Values are fully loaded before being decoded when this option is set,
+	// which makes decoding slower and allocate more memory.
+	CollectErrors bool
+
+	// Partial enables a best-effort mode for recovering data from truncated
+	// inputs. When the end of the input is reached in the middle of an array,
+	// map or struct, the values decoded up to this point are retained in the
+	// destination, and Decode returns ErrTruncated.
+	Partial bool
+
+	// Lenient enables lossy conversions of values that would otherwise fail
+	// to decode, floats are truncated toward zero when decoded into integer
+	// types, and strings are parsed when decoded into booleans or numbers.
+	// Conversions that lose information are reported as warnings, see
+	// Warnings.
+	//
+	// The option can also be enabled for a single struct field with the
+	// lenient tag option.
+	Lenient bool
+
+	// IntegralFloats enables decoding floats without a fractional part into
+	// integer types, like 1e3 or 1000.0 which are decoded as 1000. The literal
+	// form of numbers is used when the parser exposes it (like the JSON parser
+	// does), so the conversion is exact even for large exponents. Floats with
+	// a fractional part still fail to decode unless Lenient is set, and floats
+	// out of the range of the integer type are reported as overflows.
+	IntegralFloats bool
+
+	// DurationObjects enables decoding durations from maps with a "value" and
+	// a "unit" key, like {"value":150,"unit":"ms"}. The supported units are
+	// ns, us, ms, s, m and h.
+	DurationObjects bool
+
+	// UnwrapArrays enables decoding arrays of one element into scalar values
+	// (booleans, numbers, strings, byte slices, times and durations), the
+	// element is then decoded in place of the array. Arrays of more than one
+	// element still fail to decode, and so do empty arrays unless
+	// EmptyArrayAsZero is set.
+	UnwrapArrays bool
+
+	// EmptyArrayAsZero enables decoding empty arrays into scalar values as
+	// their zero-value when UnwrapArrays is set.
+	EmptyArrayAsZero bool
+
+	// DurationUnit is the unit of numbers decoded into durations, like
+	// time.Millisecond or time.Second, zero means nanoseconds. Integers are
+	// converted exactly, and floats are rounded to the nearest nanosecond.
+	DurationUnit time.Duration
+
+	// RecordSpans enables recording the range of bytes that the values nested
+	// in arrays, maps and structs were decoded from, see FieldSpans. The
+	// parser must implement the Positioner interface.
+	//
+	// When CollectErrors is also set, values are loaded before being decoded
+	// and only the spans of the top-level fields are recorded.
+	RecordSpans bool
+
+	// UseNumber enables decoding numbers into empty interfaces as Number
+	// values instead of int64, uint64 or float64, which preserves integers
+	// that don't fit in 64 bits and the exact text of floats when the parser
+	// supports exposing the literal form of numbers (like the JSON parser
+	// does).
+	//
+	// Without this option, integers that don't fit in 64 bits fail to decode
+	// and floats are rounded to the nearest float64.
+	UseNumber bool
+
+	// MissingSliceAsEmpty enables initializing the slice and map fields of
+	// structs to non-nil empty values when their keys are absent from the
+	// decoded maps. Fields which are present with a null value are still
+	// decoded as nil, and fields which already hold a non-nil value are left
+	// untouched.
+	MissingSliceAsEmpty bool
+
+	// DisallowUnknownFields enables returning an error when a map decoded into
+	// a struct has a key that matches none of the struct fields, instead of
+	// discarding its value. Maps decoded into maps or empty interfaces are not
+	// affected.
+	DisallowUnknownFields bool
+
+	// Tee is a writer that the raw bytes of the values decoded by each call to
+	// Decode are written to, like an io.TeeReader placed in front of the
+	// parser. Unlike an io.TeeReader, only the bytes of the decoded value are
+	// written, not the bytes that the parser read ahead in its buffers, nor the
+	// bytes separating values of a stream. When decoding fails, the bytes that
+	// were consumed up to the error are written.
+	//
+	// The parser must support capturing raw bytes, see DecodeWithRaw.
+	Tee io.Writer
+
+	// Tag is the key of the struct tags that the decoder reads to configure
+	// the decoding of struct fields, "objconv" when empty. Setting it to
+	// "json" lets the decoder use the tags of types written for the standard
+	// encoding/json package, the string option has the same meaning with
+	// both.
+	Tag string
+
+	off   int                // offset of the value when decoding a map
+	depth int                // number of arrays and maps that the value is nested in
+	input *limitReader       // input of the parser when created by a codec, see MaxBytes
+	alloc *int               // estimated number of bytes allocated by the current decoding
+	errs  *FieldErrors       // errors collected by the current decoding
+	warns *[]warning         // warnings reported by lenient conversions
+	spans *map[string][2]int // spans recorded when RecordSpans is set
+	path  []string           // path to the value being decoded, only set with SkipFunc, CollectErrors or RecordSpans
+	ctx   context.Context    // context checked between the elements of arrays and maps, see DecodeContext
 }
 
 // NewDecoder returns a decoder object that uses p, will panic if p is nil.
@@ -31,7 +242,91 @@ func NewDecoder(p Parser) *Decoder {
 	if p == nil {
 		panic("objconv: the parser is nil")
 	}
-	return &Decoder{Parser: p}
+	return &Decoder{Parser: p, warns: new([]warning), spans: new(map[string][2]int)}
+}
+
+// Reset resets the decoder to parse values from p, the configuration of the
+// decoder is retained.
+//
+// The warnings and spans retained by the decoder are discarded but the memory
+// allocated to hold them is reused, which makes decoders suitable to be kept in
+// a sync.Pool to avoid allocating a new one for each input. References to the
+// previous parser and to the decoded values are released so they don't leak
+// through the pool.
+//
+// Like every other method of Decoder, Reset must not be called concurrently
+// with other uses of the decoder, a decoder obtained from a pool must only be
+// used by one goroutine until it is put back into the pool.
+func (d *Decoder) Reset(p Parser) {
+	if p == nil {
+		panic("objconv: the parser is nil")
+	}
+
+	d.Parser = p
+	d.off = 0
+	d.input = nil
+	d.alloc = nil
+	d.errs = nil
+	d.path = nil
+	d.ctx = nil
+
+	if d.warns == nil {
+		d.warns = new([]warning)
+	} else {
+		w := *d.warns
+		for i := range w {
+			w[i] = warning{}
+		}
+		*d.warns = w[:0]
+	}
+
+	if d.spans == nil {
+		d.spans = new(map[string][2]int)
+	} else {
+		for k := range *d.spans {
+			delete(*d.spans, k)
+		}
+	}
+}
+
+// Warnings returns the warnings reported by the lossy conversions performed
+// since the decoder was created when Lenient is set, each warning starts with
+// the path to the value that it was reported for.
+//
+// Warnings are retained across calls to Decode by decoders created with
+// NewDecoder or NewStreamDecoder. Other decoders, like the literals built by
+// the Unmarshal functions of the format packages, can't retain them since
+// Decode doesn't modify the decoder it is called on, their warnings are only
+// visible to the ValueDecoder implementations and adapters called by Decode.
+func (d Decoder) Warnings() []string {
+	if d.warns == nil || len(*d.warns) == 0 {
+		return nil
+	}
+	s := make([]string, len(*d.warns))
+	for i, w := range *d.warns {
+		s[i] = w.String()
+	}
+	return s
+}
+
+// FieldSpans returns the spans recorded since the decoder was created when
+// RecordSpans is set. The map is indexed by the paths of the decoded values,
+// which are their map keys, field names and array indexes joined with dots,
+// and the spans are the offsets of the first byte of each value and of the
+// byte following it in the input. Spans cover the values, not their keys.
+//
+// When the same path is decoded multiple times, the last span is retained.
+// Spans are only retained by decoders created with NewDecoder or
+// NewStreamDecoder.
+func (d Decoder) FieldSpans() map[string][2]int {
+	if d.spans == nil || len(*d.spans) == 0 {
+		return nil
+	}
+	m := make(map[string][2]int, len(*d.spans))
+	for k, v := range *d.spans {
+		m[k] = v
+	}
+	return m
 }
 
 // Decode expects v to be a pointer to a value in which the decoder will load
@@ -40,7 +335,28 @@ func NewDecoder(p Parser) *Decoder {
 // The method panics if v is neither a pointer type nor implements the
 // ValueDecoder interface, or if v is a nil pointer.
 func (d Decoder) Decode(v interface{}) (err error) {
+	d.limitInput()
+
+	if d.Lenient && d.warns == nil {
+		d.warns = new([]warning)
+	}
+
+	if d.Tee != nil {
+		return d.decodeWithTee(v)
+	}
+
 	to := reflect.ValueOf(v)
+	d.initAlloc()
+
+	if d.RecordSpans {
+		if _, ok := d.Parser.(Positioner); !ok {
+			return fmt.Errorf("objconv: %T doesn't support recording the spans of decoded values", d.Parser)
+		}
+	}
+
+	if d.initErrors() {
+		defer func() { err = d.collectedErrors(err) }()
+	}
 
 	if d.off != 0 {
 		if d.off, err = 0, d.Parser.ParseMapValue(d.off-1); err != nil {
@@ -68,8 +384,352 @@ func (d Decoder) Decode(v interface{}) (err error) {
 	return
 }
 
+// DecodeInto decodes the next value from d into a new value of type T and
+// returns it, which saves generic code from having to declare the value and
+// pass a pointer to it. T may be a pointer type, in which case the value it
+// points to is allocated by the decoder.
+//
+// The zero-value of T is returned when decoding fails, including when the
+// decoder has Partial set and the input was truncated.
+func DecodeInto[T any](d *Decoder) (v T, err error) {
+	if err = d.Decode(&v); err != nil {
+		var zero T
+		v = zero
+	}
+	return
+}
+
+// DecodeContext is like Decode but stops when ctx is cancelled, in which case
+// the error returned is ctx.Err().
+//
+// The context is checked before decoding the value and between the elements of
+// arrays and maps, so cancellation is detected promptly when decoding large
+// documents without slowing down the decoding of scalar values. Note that a
+// parser blocked reading its input isn't interrupted, the cancellation takes
+// effect when the next element is reached.
+func (d Decoder) DecodeContext(ctx context.Context, v interface{}) (err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	d.ctx = ctx
+
+	if err = d.Decode(v); err != nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	return
+}
+
+// done returns the error of the context of d if it was cancelled.
+func (d Decoder) done() error {
+	if d.ctx != nil {
+		return d.ctx.Err()
+	}
+	return nil
+}
+
+// DecodeTee decodes the next value into each of the targets, which must be
+// pointers like the argument to Decode.
+//
+// The value is parsed only once into an intermediate tree of generic values,
+// which is then decoded into each target. This is significantly more expensive
+// than a single call to Decode (the value is fully loaded in memory and decoded
+// multiple times), and should only be used when the input cannot be read
+// again. The configuration of d applies to each of the decodings.
+func (d Decoder) DecodeTee(targets ...interface{}) (err error) {
+	var v interface{}
+
+	if err = d.Decode(&v); err != nil {
+		return
+	}
+
+	for _, target := range targets {
+		t := d
+		t.Parser = NewValueParser(v)
+		t.Tee = nil
+
+		if err = t.Decode(target); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// DecodeOneOf decodes the next value into the first of the given types that it
+// successfully decodes into, and sets the value pointed by v to the result.
+// This is useful to decode schemas where a value may have one of several
+// shapes without having a field to tell them apart.
+//
+// Each type must be assignable to the type pointed by v, which would usually
+// be an interface. The value is loaded into an intermediate tree of generic
+// values like DecodeTee does, and each attempt decodes into a new value so the
+// target is left untouched when no types match. In this case, the error is a
+// *OneOfError holding the errors of each attempt.
+//
+// Maps decode into any struct type unless DisallowUnknownFields is set, which
+// is usually needed for the first match to be the expected one.
+func (d Decoder) DecodeOneOf(v interface{}, types []reflect.Type) (err error) {
+	to := reflect.ValueOf(v)
+
+	if to.Kind() != reflect.Ptr || to.IsNil() {
+		return fmt.Errorf("objconv: DecodeOneOf expects a non-nil pointer but received %T", v)
+	}
+
+	to = to.Elem()
+
+	if len(types) == 0 {
+		return fmt.Errorf("objconv: DecodeOneOf expects at least one candidate type")
+	}
+
+	for _, t := range types {
+		if !t.AssignableTo(to.Type()) {
+			return fmt.Errorf("objconv: %s is not assignable to %s", t, to.Type())
+		}
+	}
+
+	var x interface{}
+
+	if err = d.Decode(&x); err != nil {
+		return
+	}
+
+	errs := make([]error, 0, len(types))
+
+	for _, t := range types {
+		c := d
+		c.Parser = NewValueParser(x)
+		c.Tee = nil
+		r := reflect.New(t)
+
+		if err = c.Decode(r.Interface()); err == nil {
+			to.Set(r.Elem())
+			return
+		}
+
+		errs = append(errs, err)
+	}
+
+	return &OneOfError{Types: types, Errors: errs}
+}
+
+// DecodeWithRaw decodes the next value into v like Decode does, and returns
+// the raw bytes that the value was decoded from.
+//
+// The returned byte slice is a copy and is safe to retain after the decoder
+// was used to decode more values. Parsers that don't keep track of their input
+// (like the YAML parser) return the value re-encoded in their format instead.
+//
+// The method returns an error if the parser doesn't support capturing raw
+// bytes.
+func (d Decoder) DecodeWithRaw(v interface{}) (raw []byte, err error) {
+	d.Tee = nil
+
+	if raw, err = d.decodeRaw(v); err != nil {
+		raw = nil
+	}
+	return
+}
+
+// DecodeRemaining decodes the next value into v like Decode does, and returns
+// the bytes of the input that follow it.
+//
+// The bytes are returned as they appear in the input, including whitespace
+// that may separate the value from what follows in text formats. The input is
+// entirely consumed when the method returns, so the decoder must not be used
+// to decode more values, and the returned slice is owned by the caller.
+//
+// The method returns an error if the parser doesn't support it, or if its
+// input isn't held in memory (like when reading from a network connection),
+// in which case nothing is decoded.
+func (d Decoder) DecodeRemaining(v interface{}) (rest []byte, err error) {
+	p, ok := d.Parser.(remainderParser)
+
+	if !ok {
+		err = fmt.Errorf("objconv: %T doesn't support returning the bytes remaining after decoded values", d.Parser)
+		return
+	}
+
+	if !p.InMemory() {
+		err = fmt.Errorf("objconv: the bytes remaining after decoded values can only be returned for inputs held in memory but %T reads from a stream", d.Parser)
+		return
+	}
+
+	if err = d.Decode(v); err != nil {
+		return
+	}
+
+	return p.Remaining()
+}
+
+// decodeWithTee decodes v and writes the raw bytes that were consumed to d.Tee,
+// the write error is returned if decoding succeeded.
+func (d Decoder) decodeWithTee(v interface{}) (err error) {
+	w := d.Tee
+	d.Tee = nil
+
+	raw, err := d.decodeRaw(v)
+
+	if len(raw) != 0 {
+		if _, werr := w.Write(raw); err == nil {
+			err = werr
+		}
+	}
+	return
+}
+
+// decodeRaw decodes v and returns the raw bytes that were consumed, which are
+// the bytes parsed up to the error if decoding failed.
+func (d Decoder) decodeRaw(v interface{}) (raw []byte, err error) {
+	p, ok := d.Parser.(rawParser)
+
+	if !ok {
+		err = fmt.Errorf("objconv: %T doesn't support capturing the raw bytes of decoded values", d.Parser)
+		return
+	}
+
+	if d.off != 0 {
+		if d.off, err = 0, d.Parser.ParseMapValue(d.off-1); err != nil {
+			return
+		}
+	}
+
+	// Parsing the type first ensures that the parser has skipped any leading
+	// bytes that aren't part of the value (like spaces in text formats).
+	if _, err = d.Parser.ParseType(); err != nil {
+		return
+	}
+
+	p.BeginRaw()
+	err = d.Decode(v)
+	raw = p.EndRaw()
+	return
+}
+
 func (d Decoder) decode(to reflect.Value) (Type, error) {
-	return decodeFuncOf(to.Type())(d, to)
+	return d.decodeFuncOf(to.Type())(d, to)
+}
+
+func (d *Decoder) initAlloc() {
+	if d.MaxAllocBytes > 0 && d.alloc == nil {
+		d.alloc = new(int)
+	}
+}
+
+func (d *Decoder) initErrors() bool {
+	if d.CollectErrors && d.errs == nil {
+		d.errs = new(FieldErrors)
+		return true
+	}
+	return false
+}
+
+func (d Decoder) collectedErrors(err error) error {
+	if err == nil && len(*d.errs) != 0 {
+		err = *d.errs
+	}
+	return err
+}
+
+// nested returns true if values nested in arrays, maps and structs must be
+// decoded with decodeElem.
+func (d Decoder) nested() bool {
+	return d.SkipFunc != nil || d.errs != nil || d.warnings() || d.RecordSpans
+}
+
+// warnings returns true if the decoder is lenient and retains warnings, the
+// paths of nested values are then tracked to prefix the warnings.
+func (d Decoder) warnings() bool {
+	return d.Lenient && d.warns != nil
+}
+
+func (d Decoder) warn(format string, args ...interface{}) {
+	if d.warnings() {
+		*d.warns = append(*d.warns, warning{msg: fmt.Sprintf(format, args...)})
+	}
+}
+
+// prefixWarnings prepends elem to the path of the warnings reported after the
+// first n ones. Warnings are recorded without their path, which is built while
+// returning from the nested values, so the cost of tracking paths is only paid
+// when a warning is reported.
+func (d Decoder) prefixWarnings(n int, elem string) {
+	w := *d.warns
+	for i := n; i < len(w); i++ {
+		w[i].path = append([]string{elem}, w[i].path...)
+	}
+}
+
+func (d Decoder) withPath(elem string) Decoder {
+	n := len(d.path)
+	p := make([]string, n+1)
+	copy(p, d.path)
+	p[n] = elem
+	d.path = p
+	return d
+}
+
+// skip is called before decoding a value, it calls SkipFunc and discards the
+// value if it returns true.
+func (d Decoder) skip() (skip bool, err error) {
+	var t Type
+
+	if t, err = d.Parser.ParseType(); err != nil {
+		return
+	}
+
+	if skip = d.SkipFunc(d.path, t); skip {
+		err = d.decodeInterfaceFromType(t, reflect.Value{})
+	}
+	return
+}
+
+// truncated returns ErrTruncated if err indicates that the end of the input
+// was reached in the middle of a value, it is used when Partial is set.
+func (d Decoder) truncated(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = ErrTruncated
+	}
+	return err
+}
+
+// limitInput resets the count of bytes read from the input when d starts
+// decoding a top-level value, see MaxBytes. The input is then cleared from d so
+// the count isn't reset again by the calls to Decode made while decoding the
+// value.
+func (d *Decoder) limitInput() {
+	if d.input != nil && d.depth == 0 {
+		d.input.reset(d.MaxBytes)
+		d.input = nil
+	}
+}
+
+// DefaultMaxDepth is the nesting depth limit of decoders which don't set
+// MaxDepth.
+const DefaultMaxDepth = 10000
+
+// nest increments the depth of d before it decodes the elements of an array or
+// map, and returns ErrMaxDepth if the limit is exceeded.
+func (d *Decoder) nest() error {
+	max := d.MaxDepth
+
+	if max == 0 {
+		max = DefaultMaxDepth
+	}
+
+	if d.depth++; max > 0 && d.depth > max {
+		return ErrMaxDepth
+	}
+	return nil
+}
+
+func (d Decoder) allocate(n int) error {
+	if d.alloc != nil {
+		if *d.alloc += n; *d.alloc > d.MaxAllocBytes {
+			return ErrMaxAllocBytes
+		}
+	}
+	return nil
 }
 
 func (d Decoder) decodeBool(to reflect.Value) (t Type, err error) {
@@ -90,6 +750,12 @@ func (d Decoder) decodeBoolFromType(t Type, to reflect.Value) (err error) {
 		v, err = d.Parser.ParseBool()
 
 	default:
+		if t == Array && d.UnwrapArrays {
+			return d.decodeFromArray(Bool, Decoder.decodeBoolFromType, to)
+		}
+		if t == String && d.Lenient {
+			return d.decodeFromString(Bool, Decoder.decodeBoolFromType, to)
+		}
 		err = typeConversionError(t, Bool)
 	}
 
@@ -119,8 +785,14 @@ func (d Decoder) decodeIntFromType(t Type, to reflect.Value) (err error) {
 	case Nil:
 		err = d.Parser.ParseNil()
 
-	case Int:
-		if i, err = d.Parser.ParseInt(); err != nil {
+	case Int, Float:
+		if t == Int {
+			i, err = d.Parser.ParseInt()
+		} else {
+			i, err = d.parseTruncatedInt()
+		}
+
+		if err != nil {
 			return
 		}
 
@@ -160,6 +832,12 @@ func (d Decoder) decodeIntFromType(t Type, to reflect.Value) (err error) {
 		i = int64(u)
 
 	default:
+		if t == Array && d.UnwrapArrays {
+			return d.decodeFromArray(Int, Decoder.decodeIntFromType, to)
+		}
+		if t == String && d.Lenient {
+			return d.decodeFromString(Int, Decoder.decodeIntFromType, to)
+		}
 		err = typeConversionError(t, Int)
 	}
 
@@ -194,7 +872,21 @@ func (d Decoder) decodeUintFromType(t Type, to reflect.Value) (err error) {
 			return
 		}
 
-		if valid {
+		if valid && i < 0 && d.UintWraparound {
+			// Negative values are reinterpreted as the two's-complement of
+			// the unsigned target, which requires the value to fit in the
+			// signed integer type of the same size.
+			switch t := to.Type(); t.Kind() {
+			case reflect.Uint:
+				err = objutil.CheckInt64Bounds(i, int64(objutil.IntMin), uint64(objutil.IntMax), t)
+			case reflect.Uint8:
+				err = objutil.CheckInt64Bounds(i, objutil.Int8Min, objutil.Int8Max, t)
+			case reflect.Uint16:
+				err = objutil.CheckInt64Bounds(i, objutil.Int16Min, objutil.Int16Max, t)
+			case reflect.Uint32:
+				err = objutil.CheckInt64Bounds(i, objutil.Int32Min, objutil.Int32Max, t)
+			}
+		} else if valid {
 			switch t := to.Type(); t.Kind() {
 			case reflect.Uint:
 				err = objutil.CheckInt64Bounds(i, 0, uint64(objutil.UintMax), t)
@@ -211,8 +903,14 @@ func (d Decoder) decodeUintFromType(t Type, to reflect.Value) (err error) {
 
 		u = uint64(i)
 
-	case Uint:
-		if u, err = d.Parser.ParseUint(); err != nil {
+	case Uint, Float:
+		if t == Uint {
+			u, err = d.Parser.ParseUint()
+		} else {
+			u, err = d.parseTruncatedUint()
+		}
+
+		if err != nil {
 			return
 		}
 
@@ -230,6 +928,12 @@ func (d Decoder) decodeUintFromType(t Type, to reflect.Value) (err error) {
 		}
 
 	default:
+		if t == Array && d.UnwrapArrays {
+			return d.decodeFromArray(Uint, Decoder.decodeUintFromType, to)
+		}
+		if t == String && d.Lenient {
+			return d.decodeFromString(Uint, Decoder.decodeUintFromType, to)
+		}
 		err = typeConversionError(t, Uint)
 	}
 
@@ -243,6 +947,227 @@ func (d Decoder) decodeUintFromType(t Type, to reflect.Value) (err error) {
 	return
 }
 
+// parseTruncatedInt parses a float and truncates it to an integer, this is
+// only allowed when Lenient is set.
+func (d Decoder) parseTruncatedInt() (i int64, err error) {
+	var f float64
+
+	if d.IntegralFloats {
+		return d.parseIntegralInt()
+	}
+
+	if !d.Lenient {
+		err = typeConversionError(Float, Int)
+		return
+	}
+
+	if f, err = d.Parser.ParseFloat(); err != nil {
+		return
+	}
+
+	if !(f >= -(1<<63) && f < (1<<63)) {
+		err = fmt.Errorf("objconv: %g cannot be represented as a 64 bits integer", f)
+		return
+	}
+
+	if i = int64(f); float64(i) != f {
+		d.warn("float %g truncated to %d", f, i)
+	}
+	return
+}
+
+// parseTruncatedUint parses a float and truncates it to an unsigned integer,
+// this is only allowed when Lenient is set.
+func (d Decoder) parseTruncatedUint() (u uint64, err error) {
+	var f float64
+
+	if d.IntegralFloats {
+		return d.parseIntegralUint()
+	}
+
+	if !d.Lenient {
+		err = typeConversionError(Float, Uint)
+		return
+	}
+
+	if f, err = d.Parser.ParseFloat(); err != nil {
+		return
+	}
+
+	if !(f > -1 && f < (1<<64)) {
+		err = fmt.Errorf("objconv: %g cannot be represented as a 64 bits unsigned integer", f)
+		return
+	}
+
+	if u = uint64(f); float64(u) != f {
+		d.warn("float %g truncated to %d", f, u)
+	}
+	return
+}
+
+// parseIntegralInt parses a float without a fractional part as an integer, see
+// Decoder.IntegralFloats.
+func (d Decoder) parseIntegralInt() (i int64, err error) {
+	var f *big.Float
+	var s string
+
+	if f, s, err = d.parseBigFloat(); err != nil {
+		return
+	}
+
+	if f.Cmp(bigInt64Min) < 0 || f.Cmp(bigInt64Limit) >= 0 {
+		err = fmt.Errorf("objconv: %s cannot be represented as a 64 bits integer", s)
+		return
+	}
+
+	if i, _ = f.Int64(); f.Acc() != big.Exact || !f.IsInt() {
+		if !d.Lenient {
+			err = fmt.Errorf("objconv: %s has a fractional part and cannot be decoded into an integer", s)
+			return
+		}
+		d.warn("float %s truncated to %d", s, i)
+	}
+	return
+}
+
+// parseIntegralUint parses a float without a fractional part as an unsigned
+// integer, see Decoder.IntegralFloats.
+func (d Decoder) parseIntegralUint() (u uint64, err error) {
+	var f *big.Float
+	var s string
+
+	if f, s, err = d.parseBigFloat(); err != nil {
+		return
+	}
+
+	if f.Cmp(bigMinusOne) <= 0 || f.Cmp(bigUint64Limit) >= 0 {
+		err = fmt.Errorf("objconv: %s cannot be represented as a 64 bits unsigned integer", s)
+		return
+	}
+
+	if u, _ = f.Uint64(); f.Acc() != big.Exact || !f.IsInt() {
+		if !d.Lenient {
+			err = fmt.Errorf("objconv: %s has a fractional part and cannot be decoded into an unsigned integer", s)
+			return
+		}
+		d.warn("float %s truncated to %d", s, u)
+	}
+	return
+}
+
+// parseBigFloat parses a float, from its literal form when the parser exposes
+// it. The value is rounded toward zero to 64 bits of precision, which is exact
+// for integers in the range of int64 and uint64, and the accuracy of the
+// returned value tells whether the rounding lost information. The formatted
+// value is returned as well to be used in error messages.
+func (d Decoder) parseBigFloat() (f *big.Float, s string, err error) {
+	if np, ok := d.Parser.(numberParser); ok {
+		var b []byte
+
+		if b, err = np.ParseNumber(); err != nil {
+			return
+		}
+
+		s = string(b)
+
+		if f, _, err = big.ParseFloat(s, 10, 64, big.ToZero); err != nil {
+			err = fmt.Errorf("objconv: invalid number %q", s)
+		}
+		return
+	}
+
+	var x float64
+
+	if x, err = d.Parser.ParseFloat(); err != nil {
+		return
+	}
+
+	s = strconv.FormatFloat(x, 'g', -1, 64)
+
+	if math.IsNaN(x) {
+		err = fmt.Errorf("objconv: %s cannot be represented as an integer", s)
+		return
+	}
+
+	f = new(big.Float).SetMode(big.ToZero).SetFloat64(x)
+	return
+}
+
+var (
+	bigMinusOne    = big.NewFloat(-1)
+	bigInt64Min    = new(big.Float).SetInt64(math.MinInt64)
+	bigInt64Limit  = new(big.Float).SetMantExp(big.NewFloat(1), 63)
+	bigUint64Limit = new(big.Float).SetMantExp(big.NewFloat(1), 64)
+)
+
+// decodeFromString parses a string as a value of type t, which is then decoded
+// with f, this is only allowed when Lenient is set.
+func (d Decoder) decodeFromString(t Type, f func(Decoder, Type, reflect.Value) error, to reflect.Value) (err error) {
+	var b []byte
+
+	if b, err = d.Parser.ParseString(); err != nil {
+		return
+	}
+
+	return d.decodeFromStringValue(string(b), t, f, to)
+}
+
+func (d Decoder) decodeFromStringValue(s string, t Type, f func(Decoder, Type, reflect.Value) error, to reflect.Value) (err error) {
+	var v interface{}
+
+	if t == Bool {
+		v, err = strconv.ParseBool(s)
+	} else if v, err = strconv.ParseInt(s, 10, 64); err != nil {
+		if v, err = strconv.ParseUint(s, 10, 64); err != nil {
+			v, err = strconv.ParseFloat(s, 64)
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("objconv: cannot convert string %q to %s", s, t)
+	}
+
+	d.Parser = NewValueParser(v)
+
+	if t, err = d.Parser.ParseType(); err != nil {
+		return
+	}
+
+	return f(d, t, to)
+}
+
+// decodeFromArray decodes the single element of an array as a value of type t
+// with f, it is used when UnwrapArrays is set.
+func (d Decoder) decodeFromArray(t Type, f func(Decoder, Type, reflect.Value) error, to reflect.Value) (err error) {
+	n := 0
+
+	if err = d.decodeArrayImpl(Array, func(d Decoder) (err error) {
+		var typ Type
+
+		if n++; n > 1 {
+			return fmt.Errorf("objconv: cannot decode array of more than one element into %s", t)
+		}
+
+		if typ, err = d.Parser.ParseType(); err != nil {
+			return
+		}
+
+		return f(d, typ, to)
+	}); err != nil {
+		return
+	}
+
+	if n == 0 {
+		if !d.EmptyArrayAsZero {
+			return fmt.Errorf("objconv: cannot decode empty array into %s", t)
+		}
+		if to.IsValid() {
+			to.Set(reflect.Zero(to.Type()))
+		}
+	}
+	return
+}
+
 func (d Decoder) decodeFloat(to reflect.Value) (t Type, err error) {
 	if t, err = d.Parser.ParseType(); err == nil {
 		err = d.decodeFloatFromType(t, to)
@@ -277,6 +1202,12 @@ func (d Decoder) decodeFloatFromType(t Type, to reflect.Value) (err error) {
 		f, err = d.Parser.ParseFloat()
 
 	default:
+		if t == Array && d.UnwrapArrays {
+			return d.decodeFromArray(Float, Decoder.decodeFloatFromType, to)
+		}
+		if t == String && d.Lenient {
+			return d.decodeFromString(Float, Decoder.decodeFloatFromType, to)
+		}
 		err = typeConversionError(t, Float)
 	}
 
@@ -290,6 +1221,68 @@ func (d Decoder) decodeFloatFromType(t Type, to reflect.Value) (err error) {
 	return
 }
 
+func (d Decoder) decodeNumber(to reflect.Value) (t Type, err error) {
+	if t, err = d.Parser.ParseType(); err == nil {
+		err = d.decodeNumberFromType(t, to)
+	}
+	return
+}
+
+func (d Decoder) decodeNumberFromType(t Type, to reflect.Value) (err error) {
+	var a [64]byte
+	var b []byte
+
+	switch t {
+	case Nil:
+		err = d.Parser.ParseNil()
+
+	case Int, Uint, Float:
+		if p, ok := d.Parser.(numberParser); ok {
+			b, err = p.ParseNumber()
+			break
+		}
+
+		switch t {
+		case Int:
+			var v int64
+			if v, err = d.Parser.ParseInt(); err == nil {
+				b = strconv.AppendInt(a[:0], v, 10)
+			}
+
+		case Uint:
+			var v uint64
+			if v, err = d.Parser.ParseUint(); err == nil {
+				b = strconv.AppendUint(a[:0], v, 10)
+			}
+
+		default:
+			var v float64
+			if v, err = d.Parser.ParseFloat(); err == nil {
+				b = strconv.AppendFloat(a[:0], v, 'g', -1, 64)
+			}
+		}
+
+	default:
+		if t == Array && d.UnwrapArrays {
+			return d.decodeFromArray(Float, Decoder.decodeNumberFromType, to)
+		}
+		err = fmt.Errorf("objconv: cannot convert from %s to number", t)
+	}
+
+	if err != nil {
+		return
+	}
+
+	if err = d.allocate(len(b)); err != nil {
+		return
+	}
+
+	if to.IsValid() {
+		to.SetString(string(b))
+	}
+	return
+}
+
 func (d Decoder) decodeString(to reflect.Value) (t Type, err error) {
 	if t, err = d.Parser.ParseType(); err == nil {
 		err = d.decodeStringFromType(t, to)
@@ -358,6 +1351,9 @@ func (d Decoder) decodeStringFromType(t Type, to reflect.Value) (err error) {
 		}
 
 	default:
+		if t == Array && d.UnwrapArrays {
+			return d.decodeFromArray(String, Decoder.decodeStringFromType, to)
+		}
 		err = typeConversionError(t, String)
 	}
 
@@ -366,6 +1362,9 @@ func (d Decoder) decodeStringFromType(t Type, to reflect.Value) (err error) {
 	}
 
 	if to.IsValid() {
+		if err = d.allocate(len(b)); err != nil {
+			return
+		}
 		to.SetString(string(b))
 	}
 	return
@@ -393,6 +1392,9 @@ func (d Decoder) decodeBytesFromType(t Type, to reflect.Value) (err error) {
 		b, err = d.Parser.ParseBytes()
 
 	default:
+		if t == Array && d.UnwrapArrays {
+			return d.decodeFromArray(Bytes, Decoder.decodeBytesFromType, to)
+		}
 		err = typeConversionError(t, String)
 	}
 
@@ -407,6 +1409,9 @@ func (d Decoder) decodeBytesFromType(t Type, to reflect.Value) (err error) {
 	}
 
 	if t != Nil {
+		if err = d.allocate(len(b)); err != nil {
+			return
+		}
 		v = make([]byte, len(b))
 		copy(v, b)
 	}
@@ -432,6 +1437,14 @@ func (d Decoder) decodeTimeFromType(t Type, to reflect.Value) (err error) {
 	case Nil:
 		err = d.Parser.ParseNil()
 
+	case Int, Uint, Float:
+		layout := numericTimeLayout(d.TimeLayouts)
+		if len(layout) == 0 {
+			err = typeConversionError(t, Time)
+			break
+		}
+		v, err = d.decodeUnixTime(t, layout)
+
 	case String:
 		s, err = d.Parser.ParseString()
 
@@ -440,6 +1453,12 @@ func (d Decoder) decodeTimeFromType(t Type, to reflect.Value) (err error) {
 
 	case Time:
 		v, err = d.Parser.ParseTime()
+
+	case Array:
+		if d.UnwrapArrays {
+			return d.decodeFromArray(Time, Decoder.decodeTimeFromType, to)
+		}
+		err = typeConversionError(t, Time)
 	}
 
 	if err != nil {
@@ -448,13 +1467,121 @@ func (d Decoder) decodeTimeFromType(t Type, to reflect.Value) (err error) {
 
 	if to.IsValid() {
 		if t == String || t == Bytes {
-			v, err = time.Parse(time.RFC3339Nano, string(s))
+			if d.MSDates && objutil.IsMSDate(s) {
+				v, err = objutil.ParseMSDate(s)
+			} else {
+				v, err = parseTime(string(s), d.TimeLayouts)
+			}
 		}
 		*(to.Addr().Interface().(*time.Time)) = v
 	}
 	return
 }
 
+func (d Decoder) decodeUnixTime(t Type, layout string) (v time.Time, err error) {
+	switch t {
+	case Int:
+		var i int64
+		if i, err = d.Parser.ParseInt(); err == nil {
+			v = unixTime(layout, i)
+		}
+
+	case Uint:
+		var u uint64
+		if u, err = d.Parser.ParseUint(); err == nil {
+			if u > math.MaxInt64 {
+				err = fmt.Errorf("objconv: %d overflows the range of time values", u)
+			} else {
+				v = unixTime(layout, int64(u))
+			}
+		}
+
+	case Float:
+		var f float64
+		if f, err = d.Parser.ParseFloat(); err == nil {
+			v, err = unixTimeFloat(layout, f)
+		}
+	}
+	return
+}
+
+// parseTime parses s with each of the layouts until one succeeds, returning the
+// error of the first layout when none does. The string is parsed with
+// time.RFC3339Nano when the list of layouts is empty.
+func parseTime(s string, layouts []string) (v time.Time, err error) {
+	if len(layouts) == 0 {
+		return time.Parse(time.RFC3339Nano, s)
+	}
+
+	for i, layout := range layouts {
+		var t time.Time
+		var e error
+
+		switch layout {
+		case TimeUnix, TimeUnixMilli:
+			var n int64
+			if n, e = strconv.ParseInt(s, 10, 64); e == nil {
+				t = unixTime(layout, n)
+			} else {
+				var f float64
+				if f, e = strconv.ParseFloat(s, 64); e == nil {
+					t, e = unixTimeFloat(layout, f)
+				}
+			}
+			if e != nil {
+				e = fmt.Errorf("objconv: cannot parse %q as a number of %s since the Unix epoch", s, unixTimeUnit(layout))
+			}
+		default:
+			t, e = time.Parse(layout, s)
+		}
+
+		if e == nil {
+			return t, nil
+		}
+		if i == 0 {
+			err = e
+		}
+	}
+
+	return
+}
+
+// numericTimeLayout returns the first of TimeUnix and TimeUnixMilli found in
+// layouts, or an empty string if there are none.
+func numericTimeLayout(layouts []string) string {
+	for _, layout := range layouts {
+		if layout == TimeUnix || layout == TimeUnixMilli {
+			return layout
+		}
+	}
+	return ""
+}
+
+func unixTime(layout string, n int64) time.Time {
+	if layout == TimeUnixMilli {
+		return time.Unix(n/1000, (n%1000)*1e6).UTC()
+	}
+	return time.Unix(n, 0).UTC()
+}
+
+func unixTimeFloat(layout string, f float64) (time.Time, error) {
+	if layout == TimeUnixMilli {
+		f /= 1000
+	}
+	if math.IsNaN(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return time.Time{}, fmt.Errorf("objconv: %g overflows the range of time values", f)
+	}
+	sec := math.Floor(f)
+	return time.Unix(int64(sec), int64(math.Round((f-sec)*1e9))).UTC(), nil
+}
+
+func unixTimeUnit(layout string) string {
+	if layout == TimeUnixMilli {
+		return "milliseconds"
+	}
+	return "seconds"
+}
+
 func (d Decoder) decodeDuration(to reflect.Value) (t Type, err error) {
 	if t, err = d.Parser.ParseType(); err == nil {
 		err = d.decodeDurationFromType(t, to)
@@ -478,6 +1605,22 @@ func (d Decoder) decodeDurationFromType(t Type, to reflect.Value) (err error) {
 
 	case Duration:
 		v, err = d.Parser.ParseDuration()
+
+	case Int, Uint, Float:
+		v, err = d.decodeDurationNumber(t)
+
+	case Map:
+		if !d.DurationObjects {
+			err = typeConversionError(t, Duration)
+		} else {
+			v, err = d.decodeDurationObject()
+		}
+
+	case Array:
+		if d.UnwrapArrays {
+			return d.decodeFromArray(Duration, Decoder.decodeDurationFromType, to)
+		}
+		err = typeConversionError(t, Duration)
 	}
 
 	if err != nil {
@@ -494,6 +1637,129 @@ func (d Decoder) decodeDurationFromType(t Type, to reflect.Value) (err error) {
 	return
 }
 
+// decodeDurationNumber decodes a duration from a number of DurationUnit.
+func (d Decoder) decodeDurationNumber(t Type) (v time.Duration, err error) {
+	unit := d.DurationUnit
+
+	if unit <= 0 {
+		unit = time.Nanosecond
+	}
+
+	switch t {
+	case Int:
+		var i int64
+
+		if i, err = d.Parser.ParseInt(); err != nil {
+			return
+		}
+
+		if i > math.MaxInt64/int64(unit) || i < math.MinInt64/int64(unit) {
+			err = fmt.Errorf("objconv: %d times %s overflows the range of durations", i, unit)
+			return
+		}
+
+		v = time.Duration(i) * unit
+
+	case Uint:
+		var u uint64
+
+		if u, err = d.Parser.ParseUint(); err != nil {
+			return
+		}
+
+		if u > uint64(math.MaxInt64/int64(unit)) {
+			err = fmt.Errorf("objconv: %d times %s overflows the range of durations", u, unit)
+			return
+		}
+
+		v = time.Duration(u) * unit
+
+	default: // Float
+		var f float64
+
+		if f, err = d.Parser.ParseFloat(); err != nil {
+			return
+		}
+
+		x := math.Round(f * float64(unit))
+
+		if !(x >= math.MinInt64 && x < math.MaxInt64) {
+			err = fmt.Errorf("objconv: %g times %s overflows the range of durations", f, unit)
+			return
+		}
+
+		v = time.Duration(x)
+	}
+
+	return
+}
+
+// decodeDurationObject decodes a duration from a map with a value and a unit,
+// it is used when DurationObjects is set.
+func (d Decoder) decodeDurationObject() (v time.Duration, err error) {
+	var value float64
+	var unit string
+	var hasValue bool
+	var hasUnit bool
+
+	if err = d.decodeMapImpl(Map, func(kd Decoder, vd Decoder) (err error) {
+		var b []byte
+
+		if _, b, err = kd.decodeTypeAndString(); err != nil {
+			return
+		}
+
+		switch string(b) {
+		case "value":
+			hasValue = true
+			err = vd.Decode(&value)
+		case "unit":
+			hasUnit = true
+			err = vd.Decode(&unit)
+		default:
+			err = vd.Decode(nil)
+		}
+		return
+	}); err != nil {
+		return
+	}
+
+	if !hasValue || !hasUnit {
+		err = errors.New("objconv: durations decoded from maps must have a value and a unit")
+		return
+	}
+
+	var scale time.Duration
+
+	switch unit {
+	case "ns":
+		scale = time.Nanosecond
+	case "us", "µs":
+		scale = time.Microsecond
+	case "ms":
+		scale = time.Millisecond
+	case "s":
+		scale = time.Second
+	case "m":
+		scale = time.Minute
+	case "h":
+		scale = time.Hour
+	default:
+		err = fmt.Errorf("objconv: unknown duration unit %q, expected one of ns, us, ms, s, m or h", unit)
+		return
+	}
+
+	f := math.Round(value * float64(scale))
+
+	if !(f >= math.MinInt64 && f < math.MaxInt64) {
+		err = fmt.Errorf("objconv: %g%s overflows the range of durations", value, unit)
+		return
+	}
+
+	v = time.Duration(f)
+	return
+}
+
 func (d Decoder) decodeError(to reflect.Value) (t Type, err error) {
 	if t, err = d.Parser.ParseType(); err == nil {
 		err = d.decodeErrorFromType(t, to)
@@ -527,13 +1793,25 @@ func (d Decoder) decodeErrorFromType(t Type, to reflect.Value) (err error) {
 		if t == String || t == Bytes {
 			v = errors.New(string(s))
 		}
-		to.Set(reflect.ValueOf(v))
+
+		if v == nil {
+			to.Set(reflect.Zero(to.Type()))
+			return
+		}
+
+		// Parsers may return errors of concrete types, which can only be
+		// stored in targets of compatible types.
+		if x := reflect.ValueOf(v); x.Type().AssignableTo(to.Type()) {
+			to.Set(x)
+		} else {
+			err = fmt.Errorf("objconv: cannot decode error value of type %T into %s", v, to.Type())
+		}
 	}
 	return
 }
 
 func (d Decoder) decodeSlice(to reflect.Value) (t Type, err error) {
-	return d.decodeSliceWith(to, decodeFuncOf(to.Type().Elem()))
+	return d.decodeSliceWith(to, d.decodeFuncOf(to.Type().Elem()))
 }
 
 func (d Decoder) decodeSliceWith(to reflect.Value, f decodeFunc) (t Type, err error) {
@@ -546,7 +1824,7 @@ func (d Decoder) decodeSliceWith(to reflect.Value, f decodeFunc) (t Type, err er
 func (d Decoder) decodeSliceFromType(typ Type, to reflect.Value) (err error) {
 	f := Decoder.decodeInterface
 	if to.IsValid() {
-		f = decodeFuncOf(to.Type().Elem())
+		f = d.decodeFuncOf(to.Type().Elem())
 	}
 	return d.decodeSliceFromTypeWith(typ, to, f)
 }
@@ -560,41 +1838,181 @@ func (d Decoder) decodeSliceFromTypeWith(typ Type, to reflect.Value, f decodeFun
 	}
 
 	t := to.Type()
-	s := reflect.MakeSlice(t, 0, 0)
+
+	if decode := numericDecodeFuncOf(t.Elem()); decode != nil && len(d.Adapters) == 0 {
+		return d.decodeNumericSliceFromType(typ, to, decode)
+	}
+
+	if typ == Map {
+		if key := mapKeyFieldOf(t.Elem(), d.Tag); key != nil {
+			return d.decodeSliceFromMap(to, key)
+		}
+	}
+
+	// The backing array of the destination slice is reused when it has some
+	// capacity. Elements are zeroed before being decoded so values that they
+	// pointed to, which may still be referenced by the program, aren't
+	// modified.
+	var s, z reflect.Value
+	reuse := to.Cap() != 0
+
+	if reuse {
+		s = to.Slice(0, to.Cap())
+		z = reflect.Zero(t.Elem())
+	} else {
+		s = reflect.MakeSlice(t, 0, 0)
+	}
+
+	i := 0
+	n := s.Len()
+
+	if err = d.decodeArrayImpl(typ, func(d Decoder) (err error) {
+		if i == n {
+			if n *= 5; n == 0 {
+				n = 10
+			}
+			if err = d.allocate(n * int(t.Elem().Size())); err != nil {
+				return
+			}
+			sc := reflect.MakeSlice(t, n, n)
+			reflect.Copy(sc, s.Slice(0, i))
+			s = sc
+		}
+		e := s.Index(i)
+		if reuse {
+			e.Set(z)
+		}
+		if _, err = f(d, e); err != nil {
+			return
+		}
+		i++
+		return
+	}); err != nil && err != ErrTruncated {
+		return
+	}
+
+	switch {
+	case typ == Nil:
+		to.Set(zeroValueOf(t))
+
+	case !reuse:
+		if i != n {
+			s = s.Slice(0, i)
+		}
+		to.Set(s)
+
+	default:
+		// Elements which were part of the destination slice but aren't part of
+		// the decoded one are zeroed so they don't retain stale pointers.
+		for j, k := i, to.Len(); j < k; j++ {
+			s.Index(j).Set(z)
+		}
+		to.Set(s.Slice(0, i))
+	}
+	return
+}
+
+// decodeSliceFromMap decodes a map into a slice of structs which have a field
+// with the mapkey tag option, each value of the map becomes an element of the
+// slice, and its key is decoded into the field. Keys are decoded leniently so
+// string keys can be converted to numeric or boolean fields.
+func (d Decoder) decodeSliceFromMap(to reflect.Value, key *structField) (err error) {
+	t := to.Type()
+	s := reflect.MakeSlice(t, 0, 0)
+
+	if err = d.decodeMapImpl(Map, func(kd Decoder, vd Decoder) (err error) {
+		var k interface{}
+
+		if err = kd.Decode(&k); err != nil {
+			return
+		}
+
+		if err = d.allocate(int(t.Elem().Size())); err != nil {
+			return
+		}
+
+		elem := reflect.New(t.Elem())
+
+		if err = vd.Decode(elem.Interface()); err != nil {
+			return
+		}
+
+		elem = elem.Elem()
+		v := elem
+
+		if v.Kind() == reflect.Ptr {
+			v = v.Elem()
+		}
+
+		if v.IsValid() {
+			kd.Parser = NewValueParser(k)
+			kd.Lenient = true
+
+			if _, err = key.decode(kd, v.FieldByIndex(key.index)); err != nil {
+				return fmt.Errorf("objconv: cannot decode map key %v into field %s: %s", k, key.name, strings.TrimPrefix(err.Error(), "objconv: "))
+			}
+		}
+
+		s = reflect.Append(s, elem)
+		return
+	}); err != nil && err != ErrTruncated {
+		return
+	}
+
+	to.Set(s)
+	return
+}
+
+// decodeNumericSliceFromType is a fast path for decoding arrays of numbers,
+// values are written directly to the slice elements, and the memory already
+// allocated by the destination slice is reused.
+func (d Decoder) decodeNumericSliceFromType(typ Type, to reflect.Value, decode func(Decoder, Type, reflect.Value) error) (err error) {
+	t := to.Type()
+	s := to.Slice(0, to.Cap())
 	i := 0
-	n := 0
+	n := s.Len()
 
 	if err = d.decodeArrayImpl(typ, func(d Decoder) (err error) {
+		var t Type
+
 		if i == n {
-			if n *= 5; n == 0 {
+			if n *= 2; n == 0 {
 				n = 10
 			}
-			sc := reflect.MakeSlice(t, n, n)
+			if err = d.allocate(n * int(s.Type().Elem().Size())); err != nil {
+				return
+			}
+			sc := reflect.MakeSlice(s.Type(), n, n)
 			reflect.Copy(sc, s)
 			s = sc
 		}
-		if _, err = f(d, s.Index(i)); err != nil {
+
+		if t, err = d.Parser.ParseType(); err != nil {
+			return
+		}
+
+		if err = decode(d, t, s.Index(i)); err != nil {
 			return
 		}
+
 		i++
 		return
-	}); err != nil {
+	}); err != nil && err != ErrTruncated {
 		return
 	}
 
 	if typ == Nil {
 		to.Set(zeroValueOf(t))
+	} else if s.IsNil() {
+		to.Set(reflect.MakeSlice(t, 0, 0))
 	} else {
-		if i != n {
-			s = s.Slice(0, i)
-		}
-		to.Set(s)
+		to.Set(s.Slice(0, i))
 	}
 	return
 }
 
 func (d Decoder) decodeArray(to reflect.Value) (t Type, err error) {
-	return d.decodeArrayWith(to, decodeFuncOf(to.Type().Elem()))
+	return d.decodeArrayWith(to, d.decodeFuncOf(to.Type().Elem()))
 }
 
 func (d Decoder) decodeArrayWith(to reflect.Value, f decodeFunc) (t Type, err error) {
@@ -639,7 +2057,7 @@ func (d Decoder) decodeArrayFromTypeWith(typ Type, to reflect.Value, f decodeFun
 
 func (d Decoder) decodeMap(to reflect.Value) (Type, error) {
 	t := to.Type()
-	return d.decodeMapWith(to, decodeFuncOf(t.Key()), decodeFuncOf(t.Elem()))
+	return d.decodeMapWith(to, d.decodeFuncOf(t.Key()), d.decodeFuncOf(t.Elem()))
 }
 
 func (d Decoder) decodeMapWith(to reflect.Value, kf decodeFunc, vf decodeFunc) (t Type, err error) {
@@ -654,15 +2072,15 @@ func (d Decoder) decodeMapFromType(typ Type, to reflect.Value) (err error) {
 	vf := Decoder.decodeInterface
 	if to.IsValid() {
 		t := to.Type()
-		kf = decodeFuncOf(t.Key())
-		vf = decodeFuncOf(t.Elem())
+		kf = d.decodeFuncOf(t.Key())
+		vf = d.decodeFuncOf(t.Elem())
 	}
 	return d.decodeMapFromTypeWith(typ, to, kf, vf)
 }
 
 func (d Decoder) decodeMapFromTypeWith(typ Type, to reflect.Value, kf decodeFunc, vf decodeFunc) (err error) {
 	if !to.IsValid() {
-		return d.decodeMapImpl(typ, func(kd Decoder, vd Decoder) (err error) {
+		return d.decodeMapImpl(typ, func(d Decoder, vd Decoder) (err error) {
 			if _, err = d.decodeInterface(reflect.Value{}); err != nil {
 				return
 			}
@@ -676,15 +2094,19 @@ func (d Decoder) decodeMapFromTypeWith(typ Type, to reflect.Value, kf decodeFunc
 
 	t := to.Type() // map[K]V
 
-	switch t {
-	case mapInterfaceInterfaceType:
-		return d.decodeMapInterfaceInterface(typ, to)
+	// The fast paths don't support SkipFunc, CollectErrors or Adapters, the
+	// generic algorithm is used instead when any of them is set.
+	if !d.nested() && len(d.Adapters) == 0 {
+		switch t {
+		case mapInterfaceInterfaceType:
+			return d.decodeMapInterfaceInterface(typ, to)
 
-	case mapStringInterfaceType:
-		return d.decodeMapStringInterface(typ, to)
+		case mapStringInterfaceType:
+			return d.decodeMapStringInterface(typ, to)
 
-	case mapStringStringType:
-		return d.decodeMapStringString(typ, to)
+		case mapStringStringType:
+			return d.decodeMapStringString(typ, to)
+		}
 	}
 
 	m := reflect.MakeMap(t) // make(map[K]V)
@@ -697,21 +2119,37 @@ func (d Decoder) decodeMapFromTypeWith(typ Type, to reflect.Value, kf decodeFunc
 	vz := zeroValueOf(vt)        // V{}
 	vv := reflect.New(vt).Elem() // &V{}
 
-	if err = d.decodeMapImpl(typ, func(kd Decoder, vd Decoder) (err error) {
+	es := int(kt.Size() + vt.Size()) // estimated size of a map entry
+
+	if d.MultiMap && vt.Kind() == reflect.Slice && vt.Elem().Kind() != reflect.Uint8 {
+		vf = d.multiMapValueFunc(m, kv, vf)
+	}
+
+	if err = d.decodeMapImpl(typ, func(d Decoder, vd Decoder) (err error) {
+		if err = d.allocate(es); err != nil {
+			return
+		}
 		kv.Set(kz) // reset the key to its zero-value
 		vv.Set(vz) // reset the value to its zero-value
-		if _, err = kf(d, kv); err != nil {
+		if err = d.decodeMapKey(kf, kv); err != nil {
 			return
 		}
 		if err = d.Parser.ParseMapValue(vd.off - 1); err != nil {
 			return
 		}
-		if _, err = vf(d, vv); err != nil {
+		if !d.nested() {
+			if _, err = vf(d, vv); err == nil || err == ErrTruncated {
+				m.SetMapIndex(kv, vv)
+			}
 			return
 		}
-		m.SetMapIndex(kv, vv)
-		return
-	}); err != nil {
+		return d.decodeElem(fmt.Sprint(kv.Interface()), false, func(d Decoder) (err error) {
+			if _, err = vf(d, vv); err == nil || err == ErrTruncated {
+				m.SetMapIndex(kv, vv)
+			}
+			return
+		})
+	}); err != nil && err != ErrTruncated {
 		return
 	}
 
@@ -723,6 +2161,220 @@ func (d Decoder) decodeMapFromTypeWith(typ Type, to reflect.Value, kf decodeFunc
 	return
 }
 
+// multiMapValueFunc returns a decoding function for the slice values of m which
+// appends the decoded elements to the value already stored in m for the key
+// held by k, see Decoder.MultiMap. Arrays and nil values are decoded with f,
+// other values are decoded as a single element of the slice.
+func (d Decoder) multiMapValueFunc(m reflect.Value, k reflect.Value, f decodeFunc) decodeFunc {
+	et := m.Type().Elem().Elem()
+	ef := d.decodeFuncOf(et)
+
+	return func(d Decoder, to reflect.Value) (t Type, err error) {
+		if t, err = d.Parser.ParseType(); err != nil {
+			return
+		}
+
+		if t == Array || t == Nil {
+			t, err = f(d, to)
+		} else {
+			e := reflect.New(et).Elem()
+			if t, err = ef(d, e); err == nil || err == ErrTruncated {
+				to.Set(reflect.Append(to, e))
+			}
+		}
+
+		if err == nil || err == ErrTruncated {
+			if prev := m.MapIndex(k); prev.IsValid() {
+				to.Set(reflect.AppendSlice(prev, to))
+			}
+		}
+		return
+	}
+}
+
+// decodeMapKey decodes a map key into to with f.
+//
+// Some formats like JSON only support string keys, so keys of boolean and
+// numeric types are parsed from strings when the decoding function of the key
+// type is one of the default ones. Other keys are decoded like values, and an
+// error is returned if the key can't be used in a map.
+func (d Decoder) decodeMapKey(f decodeFunc, to reflect.Value) (err error) {
+	var t Type
+
+	if t, err = d.Parser.ParseType(); err != nil {
+		return
+	}
+
+	if t == String {
+		switch kt := to.Type(); {
+		case !parsesKeysFromStrings(kt):
+		case kt.Kind() == reflect.Bool:
+			return d.decodeFromString(Bool, Decoder.decodeBoolFromType, to)
+		case kt.Kind() >= reflect.Int && kt.Kind() <= reflect.Int64:
+			return d.decodeIntKey(to)
+		case kt.Kind() >= reflect.Uint && kt.Kind() <= reflect.Uintptr:
+			return d.decodeUintKey(to)
+		case kt.Kind() == reflect.Float32 || kt.Kind() == reflect.Float64:
+			return d.decodeFromString(Float, Decoder.decodeFloatFromType, to)
+		}
+	}
+
+	if _, err = f(d, to); err != nil {
+		return
+	}
+
+	if to.Kind() == reflect.Interface && !to.IsNil() {
+		err = checkMapKey(to.Elem().Interface())
+	}
+	return
+}
+
+// decodeIntKey decodes a map key of a signed integer type from a string, which
+// must be a decimal integer in the range of the type. Strings which aren't
+// integers are only decoded when Lenient is set.
+func (d Decoder) decodeIntKey(to reflect.Value) (err error) {
+	var b []byte
+
+	if b, err = d.Parser.ParseString(); err != nil {
+		return
+	}
+
+	s := string(b)
+	t := to.Type()
+	i, err := strconv.ParseInt(s, 10, t.Bits())
+
+	switch {
+	case err == nil:
+		to.SetInt(i)
+
+	case errors.Is(err, strconv.ErrRange) && strings.HasPrefix(s, "-"):
+		err = fmt.Errorf("objconv: map key %q overflows the minimum value of %d for %s", s, int64(-1)<<uint(t.Bits()-1), t)
+
+	case errors.Is(err, strconv.ErrRange):
+		err = fmt.Errorf("objconv: map key %q overflows the maximum value of %d for %s", s, int64(1)<<uint(t.Bits()-1)-1, t)
+
+	case d.Lenient:
+		err = d.decodeFromStringValue(s, Int, Decoder.decodeIntFromType, to)
+
+	default:
+		err = fmt.Errorf("objconv: malformed map key %q, expected an integer for %s", s, t)
+	}
+
+	return
+}
+
+// decodeUintKey decodes a map key of an unsigned integer type from a string,
+// which must be a decimal integer in the range of the type. Negative integers
+// are only decoded when UintWraparound is set, and strings which aren't
+// integers when Lenient is set.
+func (d Decoder) decodeUintKey(to reflect.Value) (err error) {
+	var b []byte
+
+	if b, err = d.Parser.ParseString(); err != nil {
+		return
+	}
+
+	s := string(b)
+	t := to.Type()
+	u, err := strconv.ParseUint(strings.TrimPrefix(s, "+"), 10, t.Bits())
+
+	switch {
+	case err == nil:
+		to.SetUint(u)
+
+	case errors.Is(err, strconv.ErrRange):
+		err = fmt.Errorf("objconv: map key %q overflows the maximum value of %d for %s", s, ^uint64(0)>>uint(64-t.Bits()), t)
+
+	case strings.HasPrefix(s, "-") && isDecimalInteger(s[1:]):
+		if d.UintWraparound {
+			err = d.decodeFromStringValue(s, Uint, Decoder.decodeUintFromType, to)
+		} else {
+			err = fmt.Errorf("objconv: map key %q overflows the minimum value of 0 for %s", s, t)
+		}
+
+	case d.Lenient:
+		err = d.decodeFromStringValue(s, Uint, Decoder.decodeUintFromType, to)
+
+	default:
+		err = fmt.Errorf("objconv: malformed map key %q, expected an integer for %s", s, t)
+	}
+
+	return
+}
+
+// isDecimalInteger returns true if s is a non-empty sequence of decimal digits.
+func isDecimalInteger(s string) bool {
+	for i := 0; i != len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return len(s) != 0
+}
+
+// parsesKeysFromStrings returns true if map keys of type t are parsed from
+// strings by decodeMapKey, which is the case of boolean and numeric types that
+// don't have custom decoding functions.
+func parsesKeysFromStrings(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+	default:
+		return false
+	}
+
+	if _, ok := AdapterOf(t); ok {
+		return false
+	}
+
+	switch p := reflect.PtrTo(t); {
+	case t == durationType,
+		t.Implements(valueDecoderInterface),
+		p.Implements(valueDecoderInterface),
+		p.Implements(textUnmarshalerInterface):
+		return false
+	}
+
+	return true
+}
+
+// checkMapKey returns an error if k can't be used as a map key, which is the
+// case of the slices and maps decoded into interface{} values.
+func checkMapKey(k interface{}) error {
+	if k != nil && !reflect.TypeOf(k).Comparable() {
+		return fmt.Errorf("objconv: cannot use a value of type %T as a map key", k)
+	}
+	return nil
+}
+
+// decodeStringKey decodes a map key into a byte slice, keys of scalar types
+// other than strings are formatted like they would be when decoded into a
+// string value.
+func (d Decoder) decodeStringKey() (b []byte, err error) {
+	var t Type
+
+	if t, err = d.Parser.ParseType(); err != nil {
+		return
+	}
+
+	switch t {
+	case Nil, String, Bytes:
+		_, b, err = d.decodeTypeAndString()
+		return
+	}
+
+	// Declared here because it escapes to the heap, keys that are strings
+	// don't pay for the allocation.
+	var s string
+
+	if err = d.decodeStringFromType(t, reflect.ValueOf(&s).Elem()); err == nil {
+		b = []byte(s)
+	}
+	return
+}
+
 func (d Decoder) decodeMapInterfaceInterface(typ Type, to reflect.Value) error {
 	m := to.Interface().(map[interface{}]interface{})
 
@@ -739,10 +2391,16 @@ func (d Decoder) decodeMapInterfaceInterface(typ Type, to reflect.Value) error {
 		var k interface{}
 		var v interface{}
 
+		if err = d.allocate(2 * int(emptyInterface.Size())); err != nil {
+			return
+		}
 		if err = kd.Decode(&k); err != nil {
 			return
 		}
-		if err = vd.Decode(&v); err != nil {
+		if err = checkMapKey(k); err != nil {
+			return
+		}
+		if err = vd.Decode(&v); err != nil && err != ErrTruncated {
 			return
 		}
 
@@ -768,12 +2426,15 @@ func (d Decoder) decodeMapStringInterface(typ Type, to reflect.Value) (err error
 		var k string
 		var v interface{}
 
-		if _, b, err = d.decodeTypeAndString(); err != nil {
+		if b, err = d.decodeStringKey(); err != nil {
+			return
+		}
+		if err = d.allocate(len(b) + int(stringType.Size()+emptyInterface.Size())); err != nil {
 			return
 		}
 		k = string(b)
 
-		if err = vd.Decode(&v); err != nil {
+		if err = vd.Decode(&v); err != nil && err != ErrTruncated {
 			return
 		}
 
@@ -799,7 +2460,10 @@ func (d Decoder) decodeMapStringString(typ Type, to reflect.Value) (err error) {
 		var k string
 		var v string
 
-		if _, b, err = d.decodeTypeAndString(); err != nil {
+		if b, err = d.decodeStringKey(); err != nil {
+			return
+		}
+		if err = d.allocate(len(b) + 2*int(stringType.Size())); err != nil {
 			return
 		}
 		k = string(b)
@@ -811,6 +2475,9 @@ func (d Decoder) decodeMapStringString(typ Type, to reflect.Value) (err error) {
 		if _, b, err = d.decodeTypeAndString(); err != nil {
 			return
 		}
+		if err = d.allocate(len(b)); err != nil {
+			return
+		}
 		v = string(b)
 
 		m[k] = v
@@ -819,7 +2486,7 @@ func (d Decoder) decodeMapStringString(typ Type, to reflect.Value) (err error) {
 }
 
 func (d Decoder) decodeStruct(to reflect.Value) (Type, error) {
-	return d.decodeStructWith(to, structCache.lookup(to.Type()))
+	return d.decodeStructWith(to, structCache.lookup(to.Type(), d.Tag, len(d.Adapters) != 0))
 }
 
 func (d Decoder) decodeStructWith(to reflect.Value, s *structType) (t Type, err error) {
@@ -830,8 +2497,26 @@ func (d Decoder) decodeStructWith(to reflect.Value, s *structType) (t Type, err
 }
 
 func (d Decoder) decodeStructFromTypeWith(typ Type, to reflect.Value, s *structType) (err error) {
-	if err = d.decodeMapImpl(typ, func(kd Decoder, vd Decoder) (err error) {
+	var parts map[*structField]*timeParts
+	var seen map[*structField]bool
+
+	if typ == Array && len(s.positional) != 0 {
+		return d.decodeStructFromArray(to, s)
+	}
+
+	for _, f := range s.defaults {
+		if f.dflt.err != nil {
+			return f.dflt.err
+		}
+	}
+
+	if len(s.defaults) != 0 || (d.MissingSliceAsEmpty && len(s.containers) != 0) {
+		seen = make(map[*structField]bool)
+	}
+
+	if err = d.decodeMapImpl(typ, func(d Decoder, vd Decoder) (err error) {
 		var b []byte
+		var f *structField
 
 		if _, b, err = d.decodeTypeAndString(); err != nil {
 			return
@@ -841,22 +2526,241 @@ func (d Decoder) decodeStructFromTypeWith(typ Type, to reflect.Value, s *structT
 			return
 		}
 
-		f := s.fieldsByName[string(b)]
-		if f == nil {
-			_, err = d.decodeInterface(reflect.Value{}) // discard
-			return
+		if k, ok := d.stripKeyPrefix(b); ok {
+			if f = s.fieldsByName[string(k)]; f == nil {
+				if f = s.partsByName[string(k)]; f != nil {
+					if parts == nil {
+						parts = make(map[*structField]*timeParts)
+					}
+					return d.decodeTimePart(f, string(k), parts)
+				}
+			}
+		}
+
+		if f == nil || f.readonly {
+			if f == nil && d.DisallowUnknownFields {
+				return fmt.Errorf("objconv: unknown field %q in %s", b, to.Type())
+			}
+			_, err = d.decodeInterface(reflect.Value{}) // discard
+			return
+		}
+
+		if seen != nil {
+			seen[f] = true
+		}
+
+		if !d.nested() {
+			fv := fieldByIndexAlloc(to, f.index)
+			_, err = f.decode(d, fv)
+			return prefixScanError(d.adapterFieldError(err, f.name, fv.Type()), f.name)
+		}
+
+		return prefixScanError(d.decodeElem(f.name, false, func(d Decoder) (err error) {
+			fv := fieldByIndexAlloc(to, f.index)
+			_, err = f.decode(d, fv)
+			return d.adapterFieldError(err, f.name, fv.Type())
+		}), f.name)
+	}); err == nil {
+		for f, p := range parts {
+			if err = p.compose(f, fieldByIndexAlloc(to, f.index)); err != nil {
+				break
+			}
+		}
+	}
+
+	if err == nil {
+		for _, f := range s.defaults {
+			if v := fieldByIndexAlloc(to, f.index); !seen[f] || (f.dflt.empty && objutil.IsEmptyValue(v)) {
+				f.dflt.assign(v)
+			}
+		}
+
+		if d.MissingSliceAsEmpty && typ != Nil {
+			for _, f := range s.containers {
+				if v, ok := fieldByIndex(to, f.index); ok && !seen[f] && v.IsNil() {
+					v.Set(makeEmptyContainer(v.Type()))
+				}
+			}
+		}
+	}
+
+	if err != nil && err != ErrTruncated {
+		to.Set(zeroValueOf(to.Type()))
+	}
+	return
+}
+
+// stripKeyPrefix returns the key b without the KeyPrefix of the decoder, and
+// false if the key doesn't have the prefix and must not be matched against the
+// names of struct fields.
+func (d Decoder) stripKeyPrefix(b []byte) ([]byte, bool) {
+	if len(d.KeyPrefix) == 0 {
+		return b, true
+	}
+	if len(b) >= len(d.KeyPrefix) && string(b[:len(d.KeyPrefix)]) == d.KeyPrefix {
+		return b[len(d.KeyPrefix):], true
+	}
+	return b, d.MatchUnprefixedKeys
+}
+
+// makeEmptyContainer returns a non-nil empty value of t, which must be a slice
+// or map type.
+func makeEmptyContainer(t reflect.Type) reflect.Value {
+	if t.Kind() == reflect.Map {
+		return reflect.MakeMap(t)
+	}
+	return reflect.MakeSlice(t, 0, 0)
+}
+
+// decodeStructFromArray decodes an array into a struct which has fields with
+// the positional tag option. The leading elements of the array are decoded
+// into the positional fields, and the following ones are key/value pairs
+// decoded as a map into the field with the rest tag option.
+func (d Decoder) decodeStructFromArray(to reflect.Value, s *structType) (err error) {
+	var rest []interface{}
+	var i int
+
+	if err = d.decodeArrayImpl(Array, func(d Decoder) (err error) {
+		if i < len(s.positional) {
+			f := s.positional[i]
+			i++
+
+			if _, err = f.decode(d, fieldByIndexAlloc(to, f.index)); err != nil {
+				err = fmt.Errorf("objconv: bad value for positional field %s: %s", f.name, strings.TrimPrefix(err.Error(), "objconv: "))
+			}
+			return
+		}
+
+		var v interface{}
+
+		if _, err = d.decodeInterface(reflect.ValueOf(&v).Elem()); err == nil {
+			rest = append(rest, v)
+		}
+		return
+	}); err != nil {
+		to.Set(zeroValueOf(to.Type()))
+		return
+	}
+
+	switch {
+	case i < len(s.positional):
+		err = fmt.Errorf("objconv: cannot decode array of %d elements into %s which has %d positional fields", i, to.Type(), len(s.positional))
+
+	case len(rest) == 0:
+
+	case s.rest == nil:
+		err = fmt.Errorf("objconv: cannot decode array of %d elements into %s which has %d positional fields and no field with the rest tag option", i+len(rest), to.Type(), len(s.positional))
+
+	case len(rest)%2 != 0:
+		err = fmt.Errorf("objconv: cannot decode the %d elements following the positional fields of %s into field %s, they must be key/value pairs", len(rest), to.Type(), s.rest.name)
+
+	default:
+		m := make(map[interface{}]interface{}, len(rest)/2)
+
+		for j := 0; j != len(rest); j += 2 {
+			k := rest[j]
+
+			if b, ok := k.([]byte); ok {
+				k = string(b) // byte slices can't be used as map keys
+			}
+
+			if k != nil && !reflect.TypeOf(k).Comparable() {
+				err = fmt.Errorf("objconv: cannot decode the elements following the positional fields of %s into field %s, a value of type %T cannot be used as a key", to.Type(), s.rest.name, k)
+				break
+			}
+
+			m[k] = rest[j+1]
+		}
+
+		if err != nil {
+			break
+		}
+
+		rd := d
+		rd.Parser = NewValueParser(m)
+		rd.off = 0
+
+		if _, err = s.rest.decode(rd, to.FieldByIndex(s.rest.index)); err != nil {
+			err = fmt.Errorf("objconv: bad value for field %s: %s", s.rest.name, strings.TrimPrefix(err.Error(), "objconv: "))
+		}
+	}
+
+	if err != nil {
+		to.Set(zeroValueOf(to.Type()))
+	}
+	return
+}
+
+func layoutOr(layout string, defaultLayout string) string {
+	if len(layout) == 0 {
+		layout = defaultLayout
+	}
+	return layout
+}
+
+// timeParts holds the date and time decoded for fields composed from two
+// parts, see the datefield and timefield tag options.
+type timeParts struct {
+	date string
+	time string
+}
+
+func (d Decoder) decodeTimePart(f *structField, key string, parts map[*structField]*timeParts) (err error) {
+	var v string
+
+	if err = d.Decode(&v); err != nil {
+		return
+	}
+
+	p := parts[f]
+	if p == nil {
+		p = &timeParts{}
+		parts[f] = p
+	}
+
+	if key == f.dateField {
+		p.date = v
+	} else {
+		p.time = v
+	}
+	return
+}
+
+func (p *timeParts) compose(f *structField, to reflect.Value) (err error) {
+	var date time.Time
+	var clock time.Time
+
+	if to.Type() != timeType {
+		return fmt.Errorf("objconv: the datefield and timefield options are only supported on fields of type time.Time, %s is %s", f.name, to.Type())
+	}
+
+	if len(p.date) == 0 {
+		return fmt.Errorf("objconv: missing date in field %s to decode %s", f.dateField, f.name)
+	}
+
+	if date, err = time.Parse(layoutOr(f.dateLayout, "2006-01-02"), p.date); err != nil {
+		return fmt.Errorf("objconv: bad date in field %s to decode %s: %s", f.dateField, f.name, err)
+	}
+
+	// When the time is missing the value is set to midnight.
+	clock = time.Date(0, 1, 1, 0, 0, 0, 0, date.Location())
+
+	if len(p.time) != 0 {
+		if clock, err = time.Parse(layoutOr(f.timeLayout, "15:04:05"), p.time); err != nil {
+			return fmt.Errorf("objconv: bad time in field %s to decode %s: %s", f.timeField, f.name, err)
 		}
-
-		_, err = f.decode(d, to.FieldByIndex(f.index))
-		return
-	}); err != nil {
-		to.Set(zeroValueOf(to.Type()))
 	}
+
+	to.Set(reflect.ValueOf(time.Date(
+		date.Year(), date.Month(), date.Day(),
+		clock.Hour(), clock.Minute(), clock.Second(), clock.Nanosecond(),
+		clock.Location(),
+	)))
 	return
 }
 
 func (d Decoder) decodePointer(to reflect.Value) (Type, error) {
-	return d.decodePointerWith(to, decodeFuncOf(to.Type().Elem()))
+	return d.decodePointerWith(to, d.decodeFuncOf(to.Type().Elem()))
 }
 
 func (d Decoder) decodePointerWith(to reflect.Value, f decodeFunc) (typ Type, err error) {
@@ -864,12 +2768,15 @@ func (d Decoder) decodePointerWith(to reflect.Value, f decodeFunc) (typ Type, er
 	var v reflect.Value
 
 	if to.IsNil() {
+		if err = d.allocate(int(t.Elem().Size())); err != nil {
+			return
+		}
 		v = reflect.New(t.Elem())
 	} else {
 		v = to
 	}
 
-	if typ, err = f(d, v.Elem()); err != nil {
+	if typ, err = f(d, v.Elem()); err != nil && err != ErrTruncated {
 		return
 	}
 
@@ -891,15 +2798,120 @@ func (d Decoder) decodeDecoder(to reflect.Value) (Type, error) {
 	return Unknown /* just needs to not be Nil */, to.Interface().(ValueDecoder).DecodeValue(d)
 }
 
+// decodeTextUnmarshaler decodes a string into a type implementing
+// encoding.TextUnmarshaler with a pointer receiver. The text is passed to
+// UnmarshalText as it was parsed, without the transformations that parsers may
+// apply to byte slices (like base64 decoding in JSON).
 func (d Decoder) decodeTextUnmarshaler(to reflect.Value) (t Type, err error) {
+	if t, err = d.Parser.ParseType(); err == nil {
+		err = d.decodeTextUnmarshalerFromType(t, to)
+	}
+	return
+}
+
+func (d Decoder) decodeTextUnmarshalerFromType(t Type, to reflect.Value) (err error) {
 	var b []byte
-	var v = reflect.ValueOf(&b).Elem()
 
-	if t, err = d.decodeBytes(v); err != nil {
+	switch t {
+	case Nil:
+		if err = d.Parser.ParseNil(); err == nil {
+			to.Set(zeroValueOf(to.Type()))
+		}
+		return
+
+	case String:
+		b, err = d.Parser.ParseString()
+
+	case Bytes:
+		b, err = d.Parser.ParseBytes()
+
+	default:
+		if t == Array && d.UnwrapArrays {
+			return d.decodeFromArray(String, Decoder.decodeTextUnmarshalerFromType, to)
+		}
+		err = typeConversionError(t, String)
+	}
+
+	if err == nil {
+		err = d.allocate(len(b))
+	}
+
+	if err == nil {
+		err = to.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText(b)
+	}
+
+	return
+}
+
+// decodeScanner decodes a scalar value into a type implementing sql.Scanner,
+// the value passed to Scan has one of the types of driver.Value, except for
+// unsigned integers which don't fit in an int64, and durations which are
+// passed as time.Duration values.
+func (d Decoder) decodeScanner(to reflect.Value) (t Type, err error) {
+	var v interface{}
+
+	if t, err = d.Parser.ParseType(); err != nil {
+		return
+	}
+
+	switch t {
+	case Nil:
+		err = d.Parser.ParseNil()
+
+	case Bool:
+		var b bool
+		b, err = d.Parser.ParseBool()
+		v = b
+
+	case Int:
+		var i int64
+		i, err = d.Parser.ParseInt()
+		v = i
+
+	case Uint:
+		var u uint64
+		if u, err = d.Parser.ParseUint(); u <= math.MaxInt64 {
+			v = int64(u)
+		} else {
+			v = u
+		}
+
+	case Float:
+		var f float64
+		f, err = d.Parser.ParseFloat()
+		v = f
+
+	case String:
+		var s string
+		err = d.decodeStringFromType(t, reflect.ValueOf(&s).Elem())
+		v = s
+
+	case Bytes:
+		var b []byte
+		err = d.decodeBytesFromType(t, reflect.ValueOf(&b).Elem())
+		v = b
+
+	case Time:
+		var tm time.Time
+		tm, err = d.Parser.ParseTime()
+		v = tm
+
+	case Duration:
+		var dur time.Duration
+		dur, err = d.Parser.ParseDuration()
+		v = dur
+
+	default:
+		err = fmt.Errorf("objconv: cannot decode %s into %s, only scalar values can be scanned", t, to.Type())
+	}
+
+	if err != nil {
 		return
 	}
 
-	err = to.Interface().(encoding.TextUnmarshaler).UnmarshalText(b)
+	if e := to.Addr().Interface().(sql.Scanner).Scan(v); e != nil {
+		err = &ScanError{Type: to.Type(), Err: e}
+	}
 	return
 }
 
@@ -916,12 +2928,19 @@ func (d Decoder) decodeInterfaceFromType(t Type, to reflect.Value) (err error) {
 		err = d.decodeInterfaceFromNil(to)
 	case Bool:
 		err = d.decodeInterfaceFrom(boolType, t, to, Decoder.decodeBoolFromType)
-	case Int:
-		err = d.decodeInterfaceFrom(int64Type, t, to, Decoder.decodeIntFromType)
-	case Uint:
-		err = d.decodeInterfaceFrom(uint64Type, t, to, Decoder.decodeUintFromType)
-	case Float:
-		err = d.decodeInterfaceFrom(float64Type, t, to, Decoder.decodeFloatFromType)
+	case Int, Uint, Float:
+		if d.UseNumber {
+			err = d.decodeInterfaceFrom(numberType, t, to, Decoder.decodeNumberFromType)
+			break
+		}
+		switch t {
+		case Int:
+			err = d.decodeInterfaceFrom(int64Type, t, to, Decoder.decodeIntFromType)
+		case Uint:
+			err = d.decodeInterfaceFrom(uint64Type, t, to, Decoder.decodeUintFromType)
+		default:
+			err = d.decodeInterfaceFrom(float64Type, t, to, Decoder.decodeFloatFromType)
+		}
 	case String:
 		err = d.decodeInterfaceFrom(stringType, t, to, Decoder.decodeStringFromType)
 	case Bytes:
@@ -964,7 +2983,7 @@ func (d Decoder) decodeInterfaceFrom(from reflect.Type, t Type, to reflect.Value
 
 	v := reflect.New(from).Elem()
 
-	if err = decode(d, t, v); err != nil {
+	if err = decode(d, t, v); err != nil && err != ErrTruncated {
 		return
 	}
 
@@ -996,8 +3015,20 @@ func (d Decoder) decodeTypeAndString() (t Type, b []byte, err error) {
 
 // DecodeArray provides the implementation of the algorithm for decoding arrays,
 // where f is called to decode each element of the array.
+//
+// The elements are parsed one at a time as f decodes them, so arrays can be
+// processed in constant memory regardless of their size. This works with
+// parsers that know the length of arrays as well as those that only detect
+// the end when they reach it (like the JSON parser). The decoder passed to f
+// must be used to decode exactly one value, and iteration stops when f
+// returns a non-nil error which is then returned by DecodeArray.
 func (d Decoder) DecodeArray(f func(Decoder) error) (err error) {
 	var typ Type
+	d.initAlloc()
+
+	if d.initErrors() {
+		defer func() { err = d.collectedErrors(err) }()
+	}
 
 	if d.off != 0 {
 		if d.off, err = 0, d.Parser.ParseMapValue(d.off-1); err != nil {
@@ -1022,7 +3053,9 @@ func (d Decoder) decodeArrayImpl(t Type, f func(Decoder) error) (err error) {
 		return
 
 	case Array:
-		n, err = d.Parser.ParseArrayBegin()
+		if err = d.nest(); err == nil {
+			n, err = d.Parser.ParseArrayBegin()
+		}
 
 	default:
 		err = typeConversionError(t, Array)
@@ -1032,9 +3065,16 @@ func (d Decoder) decodeArrayImpl(t Type, f func(Decoder) error) (err error) {
 		return
 	}
 
+	if d.Partial {
+		defer func() { err = d.truncated(err) }()
+	}
+
 	i := 0
 
 	for n < 0 || i < n {
+		if err = d.done(); err != nil {
+			return
+		}
 		if n < 0 || i != 0 {
 			if err = d.Parser.ParseArrayNext(i); err != nil {
 				if err == End {
@@ -1044,7 +3084,12 @@ func (d Decoder) decodeArrayImpl(t Type, f func(Decoder) error) (err error) {
 				return
 			}
 		}
-		if err = f(d); err != nil {
+		if !d.nested() {
+			err = f(d)
+		} else {
+			err = d.decodeElem(strconv.Itoa(i), true, f)
+		}
+		if err != nil {
 			return
 		}
 		i++
@@ -1054,6 +3099,135 @@ func (d Decoder) decodeArrayImpl(t Type, f func(Decoder) error) (err error) {
 	return
 }
 
+// decodeElem decodes a value nested in an array, map or struct with f when
+// SkipFunc, CollectErrors or RecordSpans are set or warnings are tracked, elem
+// is the path element of the value.
+//
+// Array elements are always passed to f so the array decoding algorithm can
+// keep track of their position, when they are skipped or failed to decode
+// they are decoded from a nil value which sets them to their zero-value.
+func (d Decoder) decodeElem(elem string, array bool, f func(Decoder) error) (err error) {
+	if d.warnings() {
+		defer d.prefixWarnings(len(*d.warns), elem)
+	}
+
+	if d.SkipFunc == nil && d.errs == nil && !d.RecordSpans {
+		return f(d)
+	}
+
+	d = d.withPath(elem)
+
+	if d.SkipFunc != nil {
+		var skip bool
+
+		if skip, err = d.skip(); err != nil {
+			return
+		}
+
+		if skip {
+			if array {
+				err = d.decodeZero(f)
+			}
+			return
+		}
+	}
+
+	start := -1
+
+	if d.RecordSpans {
+		if start, err = d.spanStart(); err != nil {
+			return
+		}
+	}
+
+	if d.errs == nil {
+		if err = f(d); err == nil {
+			d.spanEnd(start)
+		}
+		return
+	}
+
+	// The value is loaded before being decoded, so when decoding fails the
+	// parser isn't left in the middle of the value and can continue with the
+	// next one. Errors occurring at this stage come from the parser and can't
+	// be recovered from.
+	var v interface{}
+
+	if _, err = d.decodeInterface(reflect.ValueOf(&v).Elem()); err != nil {
+		return
+	}
+
+	e := d
+	e.Parser = newValueParserFor(d.Parser, v)
+
+	if err = f(e); err != nil {
+		if err == ErrMaxAllocBytes || err == ErrMaxDepth {
+			return
+		}
+		d.errs.add(d.path, err)
+		err = nil
+
+		if array {
+			err = d.decodeZero(f)
+		}
+	} else {
+		d.spanEnd(start)
+	}
+
+	return
+}
+
+// spanStart returns the offset of the first byte of the next value, or -1 if
+// its span can't be recorded. The type is parsed first so the parser skips the
+// bytes preceding the value.
+func (d Decoder) spanStart() (int, error) {
+	p, ok := d.Parser.(Positioner)
+
+	if !ok || d.spans == nil {
+		return -1, nil
+	}
+
+	if _, err := d.Parser.ParseType(); err != nil {
+		return -1, err
+	}
+
+	return p.Offset(), nil
+}
+
+// spanEnd records the span of the value that was decoded after a call to
+// spanStart returned start.
+func (d Decoder) spanEnd(start int) {
+	if start < 0 {
+		return
+	}
+	if *d.spans == nil {
+		*d.spans = make(map[string][2]int)
+	}
+	(*d.spans)[strings.Join(d.path, ".")] = [2]int{start, d.Parser.(Positioner).Offset()}
+}
+
+func (d Decoder) decodeZero(f func(Decoder) error) error {
+	d.Parser = NewValueParser(nil)
+	d.SkipFunc = nil
+	d.errs = nil
+	return f(d)
+}
+
+// valueBytesDecoderParser is a value parser which applies the byte decoding
+// of another parser, it is used to decode values loaded from parsers that
+// implement the bytesDecoder interface.
+type valueBytesDecoderParser struct {
+	*ValueParser
+	bytesDecoder
+}
+
+func newValueParserFor(p Parser, v interface{}) Parser {
+	if bd, ok := p.(bytesDecoder); ok {
+		return valueBytesDecoderParser{NewValueParser(v), bd}
+	}
+	return NewValueParser(v)
+}
+
 // DecodeMap provides the implementation of the algorithm for decoding maps,
 // where f is called to decode each pair of key and value.
 //
@@ -1062,6 +3236,11 @@ func (d Decoder) decodeArrayImpl(t Type, f func(Decoder) error) (err error) {
 // used to decode the key, the second one for the value.
 func (d Decoder) DecodeMap(f func(Decoder, Decoder) error) (err error) {
 	var typ Type
+	d.initAlloc()
+
+	if d.initErrors() {
+		defer func() { err = d.collectedErrors(err) }()
+	}
 
 	if d.off != 0 {
 		if d.off, err = 0, d.Parser.ParseMapValue(d.off-1); err != nil {
@@ -1086,7 +3265,9 @@ func (d Decoder) decodeMapImpl(t Type, f func(Decoder, Decoder) error) (err erro
 		return
 
 	case Map:
-		n, err = d.Parser.ParseMapBegin()
+		if err = d.nest(); err == nil {
+			n, err = d.Parser.ParseMapBegin()
+		}
 
 	default:
 		err = typeConversionError(t, Map)
@@ -1096,9 +3277,16 @@ func (d Decoder) decodeMapImpl(t Type, f func(Decoder, Decoder) error) (err erro
 		return
 	}
 
+	if d.Partial {
+		defer func() { err = d.truncated(err) }()
+	}
+
 	i := 0
 
 	for n < 0 || i < n {
+		if err = d.done(); err != nil {
+			return
+		}
 		if n < 0 || i != 0 {
 			if err = d.Parser.ParseMapNext(i); err != nil {
 				if err == End {
@@ -1136,10 +3324,104 @@ type StreamDecoder struct {
 	// there is not destination type (when decoding to an empty interface).
 	MapType reflect.Type
 
-	err error
-	typ Type
-	cnt int
-	max int
+	// Adapters sets the adapters used by the decoder, see Decoder.Adapters.
+	Adapters map[reflect.Type]Adapter
+
+	// MaxAllocBytes sets a limit on the estimated number of bytes that each
+	// call to Decode may allocate, see Decoder.MaxAllocBytes.
+	MaxAllocBytes int
+
+	// MaxDepth sets a limit on the nesting depth of arrays and maps, see
+	// Decoder.MaxDepth.
+	MaxDepth int
+
+	// MaxBytes sets a limit on the number of bytes that each call to Decode
+	// may read from the input, see Decoder.MaxBytes.
+	MaxBytes int64
+
+	// MSDates enables decoding time values from Microsoft JSON dates, see
+	// Decoder.MSDates.
+	MSDates bool
+
+	// TimeLayouts is the list of layouts used to parse time values, see
+	// Decoder.TimeLayouts.
+	TimeLayouts []string
+
+	// KeyPrefix and MatchUnprefixedKeys configure the prefix stripped from
+	// the keys matched against struct fields, see Decoder.KeyPrefix.
+	KeyPrefix           string
+	MatchUnprefixedKeys bool
+
+	// MultiMap enables accumulating the values of repeated keys in maps of
+	// slices, see Decoder.MultiMap.
+	MultiMap bool
+
+	// UintWraparound enables decoding negative integers into unsigned targets,
+	// see Decoder.UintWraparound.
+	UintWraparound bool
+
+	// SkipFunc is called to decide whether values should be skipped, see
+	// Decoder.SkipFunc.
+	SkipFunc func(path []string, t Type) bool
+
+	// CollectErrors enables collecting errors of nested values instead of
+	// aborting on the first one, see Decoder.CollectErrors.
+	CollectErrors bool
+
+	// Partial enables retaining values decoded from truncated inputs, see
+	// Decoder.Partial.
+	Partial bool
+
+	// Lenient enables lossy conversions of values, see Decoder.Lenient.
+	Lenient bool
+
+	// IntegralFloats enables decoding floats without a fractional part into
+	// integer types, see Decoder.IntegralFloats.
+	IntegralFloats bool
+
+	// DurationObjects enables decoding durations from maps, see
+	// Decoder.DurationObjects.
+	DurationObjects bool
+
+	// UnwrapArrays and EmptyArrayAsZero enable decoding arrays of one element
+	// into scalar values, see Decoder.UnwrapArrays.
+	UnwrapArrays     bool
+	EmptyArrayAsZero bool
+
+	// DurationUnit is the unit of numbers decoded into durations, see
+	// Decoder.DurationUnit.
+	DurationUnit time.Duration
+
+	// RecordSpans enables recording the range of bytes that nested values
+	// were decoded from, see Decoder.RecordSpans.
+	RecordSpans bool
+
+	// UseNumber enables decoding numbers into empty interfaces as Number
+	// values, see Decoder.UseNumber.
+	UseNumber bool
+
+	// MissingSliceAsEmpty enables initializing absent slice and map fields to
+	// empty values, see Decoder.MissingSliceAsEmpty.
+	MissingSliceAsEmpty bool
+
+	// DisallowUnknownFields enables returning an error on map keys matching
+	// no struct fields, see Decoder.DisallowUnknownFields.
+	DisallowUnknownFields bool
+
+	// Tee is a writer that the raw bytes of decoded values are written to,
+	// see Decoder.Tee.
+	Tee io.Writer
+
+	// Tag is the key of the struct tags read by the decoder, see Decoder.Tag.
+	Tag string
+
+	err   error
+	typ   Type
+	cnt   int
+	max   int
+	input *limitReader
+	warns *[]warning
+	spans *map[string][2]int
 }
 
 // NewStreamDecoder returns a new stream decoder that takes input from p.
@@ -1149,7 +3431,19 @@ func NewStreamDecoder(p Parser) *StreamDecoder {
 	if p == nil {
 		panic("objconv: the parser is nil")
 	}
-	return &StreamDecoder{Parser: p}
+	return &StreamDecoder{Parser: p, warns: new([]warning), spans: new(map[string][2]int)}
+}
+
+// Warnings returns the warnings reported by the lossy conversions performed
+// since the stream decoder was created, see Decoder.Warnings.
+func (d *StreamDecoder) Warnings() []string {
+	return Decoder{warns: d.warns}.Warnings()
+}
+
+// FieldSpans returns the spans recorded since the stream decoder was created,
+// see Decoder.FieldSpans.
+func (d *StreamDecoder) FieldSpans() map[string][2]int {
+	return Decoder{spans: d.spans}.FieldSpans()
 }
 
 // Err returns the last error returned by the Decode method.
@@ -1168,16 +3462,47 @@ func (d *StreamDecoder) Decode(v interface{}) error {
 		return d.err
 	}
 
+	if d.input != nil {
+		d.input.reset(d.MaxBytes)
+	}
+
 	err := error(nil)
 	cnt := d.cnt
 	max := d.max
 	dec := Decoder{
-		Parser:  d.Parser,
-		MapType: d.MapType,
+		Parser:                d.Parser,
+		MapType:               d.MapType,
+		Adapters:              d.Adapters,
+		MaxAllocBytes:         d.MaxAllocBytes,
+		MaxDepth:              d.MaxDepth,
+		MaxBytes:              d.MaxBytes,
+		MSDates:               d.MSDates,
+		TimeLayouts:           d.TimeLayouts,
+		KeyPrefix:             d.KeyPrefix,
+		MatchUnprefixedKeys:   d.MatchUnprefixedKeys,
+		MultiMap:              d.MultiMap,
+		UintWraparound:        d.UintWraparound,
+		SkipFunc:              d.SkipFunc,
+		CollectErrors:         d.CollectErrors,
+		Partial:               d.Partial,
+		Lenient:               d.Lenient,
+		IntegralFloats:        d.IntegralFloats,
+		DurationObjects:       d.DurationObjects,
+		UnwrapArrays:          d.UnwrapArrays,
+		EmptyArrayAsZero:      d.EmptyArrayAsZero,
+		DurationUnit:          d.DurationUnit,
+		RecordSpans:           d.RecordSpans,
+		UseNumber:             d.UseNumber,
+		MissingSliceAsEmpty:   d.MissingSliceAsEmpty,
+		DisallowUnknownFields: d.DisallowUnknownFields,
+		Tee:                   d.Tee,
+		Tag:                   d.Tag,
+		warns:                 d.warns,
+		spans:                 d.spans,
 	}
 
 	if d.typ == Unknown {
-		if d.typ, d.err = d.Parser.ParseType(); err != nil {
+		if d.typ, d.err = d.Parser.ParseType(); d.err != nil {
 			return d.err
 		}
 	}
@@ -1191,10 +3516,15 @@ func (d *StreamDecoder) Decode(v interface{}) error {
 		if cnt == 0 {
 			max, err = dec.Parser.ParseArrayBegin()
 		}
-		if cnt == max {
+		// Like when decoding arrays, ParseArrayNext is also called before the
+		// first element of arrays of unknown length to detect empty arrays.
+		if err == nil && cnt != max && (max < 0 || cnt != 0) {
+			if err = dec.Parser.ParseArrayNext(cnt); err == End {
+				err, max = nil, cnt
+			}
+		}
+		if err == nil && cnt == max {
 			err = dec.Parser.ParseArrayEnd(cnt)
-		} else if cnt != 0 {
-			err = dec.Parser.ParseArrayNext(cnt)
 		}
 	}
 
@@ -1218,6 +3548,74 @@ func (d *StreamDecoder) Decode(v interface{}) error {
 	return err
 }
 
+// All returns an iterator over the values of the stream, which are decoded one
+// after the other into v before their index in the stream is yielded. The
+// value pointed by v is set to its zero-value before each value is decoded.
+//
+// The iteration stops when the end of the stream is reached, or after yielding
+// the error that occurred if decoding a value failed. Breaking out of the loop
+// leaves the stream positioned after the last value that was yielded, so the
+// decoder can still be used to decode the following values.
+func (d *StreamDecoder) All(v interface{}) iter.Seq2[int, error] {
+	return func(yield func(int, error) bool) {
+		to := reflect.ValueOf(v).Elem()
+		zero := reflect.Zero(to.Type())
+
+		for {
+			i := d.cnt
+			to.Set(zero)
+
+			switch err := d.decodeNext(v); err {
+			case nil:
+				if !yield(i, nil) {
+					return
+				}
+			case End:
+				return
+			default:
+				yield(i, err)
+				return
+			}
+		}
+	}
+}
+
+// decodeNext decodes the next value of the stream into v, End is returned when
+// the stream ended or when the input was empty.
+func (d *StreamDecoder) decodeNext(v interface{}) (err error) {
+	empty := d.cnt == 0 && d.typ == Unknown
+
+	if err = d.Decode(v); err == io.EOF && empty {
+		err = End
+	}
+	return
+}
+
+// DecodeAll returns an iterator over the values of the stream decoded by d,
+// which are decoded into new values of type T, see StreamDecoder.All.
+//
+// When decoding a value fails, the error is yielded with the zero-value of T.
+func DecodeAll[T any](d *StreamDecoder) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for {
+			var v T
+
+			switch err := d.decodeNext(&v); err {
+			case nil:
+				if !yield(v, nil) {
+					return
+				}
+			case End:
+				return
+			default:
+				var zero T
+				yield(zero, err)
+				return
+			}
+		}
+	}
+}
+
 // Encoder returns a new StreamEncoder which can be used to re-encode the stream
 // decoded by d into e.
 //
@@ -1250,8 +3648,10 @@ type ValueDecoderFunc func(Decoder) error
 func (f ValueDecoderFunc) DecodeValue(d Decoder) error { return f(d) }
 
 type decodeFuncOpts struct {
-	recurse bool
-	structs map[reflect.Type]*structType
+	recurse  bool
+	adapters bool // check Decoder.Adapters, only set when it isn't empty
+	structs  map[reflect.Type]*structType
+	tag      string
 }
 
 type decodeFunc func(Decoder, reflect.Value) (Type, error)
@@ -1260,12 +3660,53 @@ func decodeFuncOf(t reflect.Type) decodeFunc {
 	return makeDecodeFunc(t, decodeFuncOpts{})
 }
 
+// decodeFuncOf returns a decoder function for t which checks the adapters set
+// on d, the functions returned when d has no adapters don't pay for it.
+func (d Decoder) decodeFuncOf(t reflect.Type) decodeFunc {
+	if len(d.Adapters) == 0 {
+		return decodeFuncOf(t)
+	}
+	return makeDecodeFunc(t, decodeFuncOpts{adapters: true})
+}
+
 func makeDecodeFunc(t reflect.Type, opts decodeFuncOpts) decodeFunc {
+	f := makeTypeDecodeFunc(t, opts)
+	if opts.adapters {
+		f = decodeWithAdapters(t, f)
+	}
+	return f
+}
+
+// decodeWithAdapters returns a decoder function which uses the adapter set for
+// t on the decoder if there is one, or f otherwise.
+func decodeWithAdapters(t reflect.Type, f decodeFunc) decodeFunc {
+	return func(d Decoder, v reflect.Value) (Type, error) {
+		if a, ok := d.Adapters[t]; ok && a.Decode != nil {
+			return d.decodeAdapter(v, a.Decode)
+		}
+		return f(d, v)
+	}
+}
+
+// decodeAdapter decodes v with the decoder function of an adapter. Nil is
+// returned when the input is null, so pointers to the types that adapters
+// decode are set to nil like other pointers.
+func (d Decoder) decodeAdapter(v reflect.Value, decode func(Decoder, reflect.Value) error) (t Type, err error) {
+	if t, err = d.Parser.ParseType(); err != nil {
+		return
+	}
+	if t != Nil {
+		t = Unknown
+	}
+	err = decode(d, v)
+	return
+}
+
+func makeTypeDecodeFunc(t reflect.Type, opts decodeFuncOpts) decodeFunc {
 	if a, ok := AdapterOf(t); ok {
 		decode := a.Decode
 		return func(d Decoder, v reflect.Value) (Type, error) {
-			err := decode(d, v)
-			return Unknown /* just needs to not be Nil */, err
+			return d.decodeAdapter(v, decode)
 		}
 	}
 
@@ -1286,6 +3727,9 @@ func makeDecodeFunc(t reflect.Type, opts decodeFuncOpts) decodeFunc {
 	case durationType:
 		return Decoder.decodeDuration
 
+	case numberType:
+		return Decoder.decodeNumber
+
 	case emptyInterface:
 		return Decoder.decodeInterface
 
@@ -1307,11 +3751,14 @@ func makeDecodeFunc(t reflect.Type, opts decodeFuncOpts) decodeFunc {
 	case p.Implements(valueDecoderInterface):
 		return Decoder.decodeDecoderPointer
 
+	case p.Implements(textUnmarshalerInterface):
+		return Decoder.decodeTextUnmarshaler
+
 	case t.Implements(errorInterface):
 		return Decoder.decodeError
 
-	case p.Implements(textUnmarshalerInterface):
-		return Decoder.decodeTextUnmarshaler
+	case p.Implements(scannerInterface):
+		return Decoder.decodeScanner
 	}
 
 	// check what kind is the type, potentially generate a decoder
@@ -1349,11 +3796,38 @@ func makeDecodeFunc(t reflect.Type, opts decodeFuncOpts) decodeFunc {
 	case reflect.String:
 		return Decoder.decodeString
 
+	case reflect.Interface:
+		return Decoder.decodeImplementor
+
 	default:
 		return Decoder.decodeUnsupported
 	}
 }
 
+func numericDecodeFuncOf(t reflect.Type) func(Decoder, Type, reflect.Value) error {
+	var decode func(Decoder, Type, reflect.Value) error
+
+	switch t {
+	case intType, int8Type, int16Type, int32Type, int64Type:
+		decode = Decoder.decodeIntFromType
+
+	case uintType, uint16Type, uint32Type, uint64Type, uintptrType:
+		decode = Decoder.decodeUintFromType
+
+	case float32Type, float64Type:
+		decode = Decoder.decodeFloatFromType
+
+	default:
+		return nil
+	}
+
+	if _, ok := AdapterOf(t); ok {
+		return nil
+	}
+
+	return decode
+}
+
 func makeDecodeSliceFunc(t reflect.Type, opts decodeFuncOpts) decodeFunc {
 	if !opts.recurse {
 		return Decoder.decodeSlice
@@ -1389,7 +3863,7 @@ func makeDecodeStructFunc(t reflect.Type, opts decodeFuncOpts) decodeFunc {
 	if !opts.recurse {
 		return Decoder.decodeStruct
 	}
-	s := newStructType(t, opts.structs)
+	s := newStructType(t, opts.tag, opts.adapters, opts.structs)
 	return func(d Decoder, v reflect.Value) (Type, error) {
 		return d.decodeStructWith(v, s)
 	}
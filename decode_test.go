@@ -1,9 +1,15 @@
 package objconv
 
 import (
+	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	"math"
 	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -306,6 +312,75 @@ func TestDecoderDecodeToEmptyInterface(t *testing.T) {
 	}
 }
 
+func TestStreamDecoderAll(t *testing.T) {
+	t.Run("values", func(t *testing.T) {
+		dec := NewStreamDecoder(NewValueParser([]interface{}{
+			map[string]int{"a": 1},
+			map[string]int{"b": 2},
+		}))
+
+		var v map[string]int
+		var out []map[string]int
+
+		for i, err := range dec.All(&v) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			if i != len(out) {
+				t.Error("bad index:", i)
+			}
+			out = append(out, v)
+		}
+
+		// The destination is reset between values, maps are not merged.
+		if !reflect.DeepEqual(out, []map[string]int{{"a": 1}, {"b": 2}}) {
+			t.Errorf("%v", out)
+		}
+	})
+
+	t.Run("break", func(t *testing.T) {
+		dec := NewStreamDecoder(NewValueParser([]int{1, 2, 3}))
+
+		var v int
+
+		for i := range dec.All(&v) {
+			if i == 1 {
+				break
+			}
+		}
+
+		// The decoder resumes after the last value that was yielded.
+		var rest []int
+
+		for v, err := range DecodeAll[int](dec) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			rest = append(rest, v)
+		}
+
+		if !reflect.DeepEqual(rest, []int{3}) {
+			t.Errorf("%v", rest)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		dec := NewStreamDecoder(NewValueParser([]interface{}{1, "x", 3}))
+
+		var values []int
+		var errs []error
+
+		for v, err := range DecodeAll[int](dec) {
+			values = append(values, v)
+			errs = append(errs, err)
+		}
+
+		if !reflect.DeepEqual(values, []int{1, 0}) || errs[0] != nil || errs[1] == nil {
+			t.Errorf("%v %v", values, errs)
+		}
+	})
+}
+
 func TestStreamDecoder(t *testing.T) {
 	tests := [][]interface{}{
 		{},
@@ -398,3 +473,2145 @@ func TestStreamRencode(t *testing.T) {
 		})
 	}
 }
+
+func TestDecoderMaxAllocBytes(t *testing.T) {
+	tests := []struct {
+		in    interface{}
+		out   interface{}
+		limit int
+		err   error
+	}{
+		{"Hello World!", "", 12, nil},
+		{"Hello World!", "", 11, ErrMaxAllocBytes},
+		{[]byte("Hello World!"), []byte(nil), 12, nil},
+		{[]byte("Hello World!"), []byte(nil), 11, ErrMaxAllocBytes},
+		{[]int{1, 2, 3}, []int(nil), 80, nil},
+		{[]int{1, 2, 3}, []int(nil), 79, ErrMaxAllocBytes},
+		{map[string]string{"A": "B"}, map[string]string(nil), 34, nil},
+		{map[string]string{"A": "B"}, map[string]string(nil), 33, ErrMaxAllocBytes},
+		{[]string{"A", "B", "C"}, []interface{}(nil), 1000, nil},
+		{[]string{"A", "B", "C"}, []interface{}(nil), 100, ErrMaxAllocBytes},
+		{"Hello World!", "", 0, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%T->%T:%d", test.in, test.out, test.limit), func(t *testing.T) {
+			dec := Decoder{
+				Parser:        NewValueParser(test.in),
+				MaxAllocBytes: test.limit,
+			}
+
+			if err := dec.Decode(reflect.New(reflect.TypeOf(test.out)).Interface()); err != test.err {
+				t.Errorf("bad error: %v != %v", err, test.err)
+			}
+		})
+	}
+}
+
+func TestDecoderMSDates(t *testing.T) {
+	tests := []struct {
+		in      string
+		out     time.Time
+		msdates bool
+		err     bool
+	}{
+		{"/Date(1609459200000)/", time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), true, false},
+		{"/Date(1609459200000+0100)/", time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), true, false},
+		{"2021-01-01T00:00:00Z", time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), true, false},
+		{"/Date(1609459200000)/", time.Time{}, false, true},
+		{"/Date(hello)/", time.Time{}, true, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			var v time.Time
+
+			dec := Decoder{
+				Parser:  NewValueParser(test.in),
+				MSDates: test.msdates,
+			}
+
+			err := dec.Decode(&v)
+
+			if test.err {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Error(err)
+			}
+
+			if !v.Equal(test.out) {
+				t.Errorf("%v != %v", v, test.out)
+			}
+		})
+	}
+}
+
+func TestDecoderTimeLayouts(t *testing.T) {
+	date := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		in      interface{}
+		layouts []string
+		out     time.Time
+		err     bool
+	}{
+		{"2021-01-01T00:00:00Z", nil, date, false},
+		{"2021-01-01", nil, time.Time{}, true},
+		{"2021-01-01", []string{time.RFC3339, "2006-01-02"}, date, false},
+		{"01/02/2021", []string{time.RFC3339, "2006-01-02"}, time.Time{}, true},
+		{int64(1609459200123), []string{TimeUnixMilli}, date.Add(123 * time.Millisecond), false},
+		{int64(-500), []string{TimeUnixMilli}, time.Unix(-1, 500e6).UTC(), false},
+		{uint64(1609459200), []string{time.RFC3339, TimeUnix}, date, false},
+		{1609459200.25, []string{TimeUnix}, date.Add(250 * time.Millisecond), false},
+		{"1609459200123", []string{time.RFC3339, TimeUnixMilli}, date.Add(123 * time.Millisecond), false},
+		{"2021-01-01T00:00:00Z", []string{TimeUnixMilli, time.RFC3339}, date, false},
+		{"hello", []string{TimeUnixMilli}, time.Time{}, true},
+		{int64(1609459200), nil, time.Time{}, true},
+		{int64(1609459200), []string{time.RFC3339}, time.Time{}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test.in, test.layouts), func(t *testing.T) {
+			var v time.Time
+
+			dec := Decoder{
+				Parser:      NewValueParser(test.in),
+				TimeLayouts: test.layouts,
+			}
+
+			err := dec.Decode(&v)
+
+			if test.err {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Error(err)
+			}
+
+			if !v.Equal(test.out) {
+				t.Errorf("%v != %v", v, test.out)
+			}
+		})
+	}
+}
+
+func TestDecoderUintWraparound(t *testing.T) {
+	tests := []struct {
+		in   int64
+		out  interface{}
+		wrap bool
+		err  bool
+	}{
+		{-1, uint64(0), false, true},
+		{-1, uint64(math.MaxUint64), true, false},
+		{-1, uint(math.MaxUint64), true, false},
+		{-1, uint8(math.MaxUint8), true, false},
+		{-2, uint16(math.MaxUint16 - 1), true, false},
+		{-128, uint8(128), true, false},
+		{-129, uint8(0), true, true},
+		{math.MinInt32, uint32(1 << 31), true, false},
+		{math.MinInt32 - 1, uint32(0), true, true},
+		{math.MinInt64, uint64(1 << 63), true, false},
+		{42, uint8(42), true, false},
+		{256, uint8(0), true, true},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%d->%T", test.in, test.out), func(t *testing.T) {
+			dec := Decoder{
+				Parser:         NewValueParser(test.in),
+				UintWraparound: test.wrap,
+			}
+			val := reflect.New(reflect.TypeOf(test.out))
+			err := dec.Decode(val.Interface())
+
+			if test.err {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Error(err)
+			}
+
+			if v := val.Elem().Interface(); v != test.out {
+				t.Errorf("%T => %v != %v", v, v, test.out)
+			}
+		})
+	}
+}
+
+func TestDecoderNumericSlice(t *testing.T) {
+	t.Run("reuse", func(t *testing.T) {
+		buf := make([]float64, 0, 4)
+		dec := NewDecoder(NewValueParser([]interface{}{int64(1), uint64(2), 3.5}))
+
+		if err := dec.Decode(&buf); err != nil {
+			t.Error(err)
+		}
+
+		if !reflect.DeepEqual(buf, []float64{1, 2, 3.5}) {
+			t.Error(buf)
+		}
+
+		if cap(buf) != 4 {
+			t.Error("the pre-allocated slice wasn't reused")
+		}
+	})
+
+	t.Run("grow", func(t *testing.T) {
+		in := make([]int, 100)
+		for i := range in {
+			in[i] = i
+		}
+
+		var out []int16
+		dec := NewDecoder(NewValueParser(in))
+
+		if err := dec.Decode(&out); err != nil {
+			t.Error(err)
+		}
+
+		for i := range in {
+			if int(out[i]) != in[i] {
+				t.Errorf("bad value at index %d: %d", i, out[i])
+			}
+		}
+	})
+
+	t.Run("overflow", func(t *testing.T) {
+		var out []int8
+		dec := NewDecoder(NewValueParser([]int{1, 2, 300}))
+
+		if err := dec.Decode(&out); err == nil {
+			t.Error("expected an overflow error")
+		}
+	})
+}
+
+func TestDecoderDecodeTee(t *testing.T) {
+	type T struct {
+		A int
+		B string
+	}
+
+	in := map[string]interface{}{"A": 42, "B": "Hello World!", "C": []int{1, 2, 3}}
+
+	var a T
+	var b map[string]interface{}
+
+	if err := NewDecoder(NewValueParser(in)).DecodeTee(&a, &b); err != nil {
+		t.Error(err)
+	}
+
+	if a != (T{A: 42, B: "Hello World!"}) {
+		t.Errorf("%#v", a)
+	}
+
+	if !reflect.DeepEqual(b, map[string]interface{}{
+		"A": int64(42),
+		"B": "Hello World!",
+		"C": []interface{}{int64(1), int64(2), int64(3)},
+	}) {
+		t.Errorf("%#v", b)
+	}
+}
+
+func TestDecodeInto(t *testing.T) {
+	type T struct {
+		A int `objconv:"a"`
+	}
+
+	in := map[string]interface{}{"a": 1}
+
+	v, err := DecodeInto[T](NewDecoder(NewValueParser(in)))
+	if err != nil || v != (T{A: 1}) {
+		t.Errorf("%#v %v", v, err)
+	}
+
+	p, err := DecodeInto[*T](NewDecoder(NewValueParser(in)))
+	if err != nil || p == nil || *p != (T{A: 1}) {
+		t.Errorf("%#v %v", p, err)
+	}
+
+	m, err := DecodeInto[map[string]int](NewDecoder(NewValueParser(in)))
+	if err != nil || !reflect.DeepEqual(m, map[string]int{"a": 1}) {
+		t.Errorf("%#v %v", m, err)
+	}
+
+	// The values decoded up to the error are discarded.
+	bad := map[string]interface{}{"a": 1, "b": "x"}
+
+	if m, err := DecodeInto[map[string]int](NewDecoder(NewValueParser(bad))); err == nil || m != nil {
+		t.Errorf("%#v %v", m, err)
+	}
+
+	if p, err := DecodeInto[*T](NewDecoder(NewValueParser("x"))); err == nil || p != nil {
+		t.Errorf("%#v %v", p, err)
+	}
+}
+
+func TestDecoderMaxDepth(t *testing.T) {
+	type T struct {
+		A [][]int `objconv:"a"`
+	}
+
+	in := map[string]interface{}{"a": [][]int{{1}}}
+
+	for _, test := range []struct {
+		maxDepth int
+		err      error
+	}{
+		{maxDepth: 3},
+		{maxDepth: 2, err: ErrMaxDepth},
+		{maxDepth: -1},
+	} {
+		var v T
+
+		if err := (Decoder{Parser: NewValueParser(in), MaxDepth: test.maxDepth}).Decode(&v); err != test.err {
+			t.Errorf("bad error (max depth = %d): %v", test.maxDepth, err)
+		}
+	}
+
+	// The depth of values decoded by DecodeArray callbacks accumulates.
+	dec := Decoder{Parser: NewValueParser([][]int{{1}}), MaxDepth: 1}
+
+	if err := dec.DecodeArray(func(d Decoder) error {
+		var v []int
+		return d.Decode(&v)
+	}); err != ErrMaxDepth {
+		t.Error("bad error:", err)
+	}
+}
+
+func TestDecoderDecodeOneOf(t *testing.T) {
+	type Circle struct {
+		Radius float64 `objconv:"radius"`
+	}
+
+	type Rect struct {
+		Width  float64 `objconv:"width"`
+		Height float64 `objconv:"height"`
+	}
+
+	types := []reflect.Type{reflect.TypeOf(Circle{}), reflect.TypeOf(Rect{}), reflect.TypeOf("")}
+
+	tests := []struct {
+		in  interface{}
+		out interface{}
+	}{
+		{in: map[string]interface{}{"radius": 1}, out: Circle{Radius: 1}},
+		{in: map[string]interface{}{"width": 2, "height": 3}, out: Rect{Width: 2, Height: 3}},
+		{in: "square", out: "square"},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test.out), func(t *testing.T) {
+			var v interface{}
+
+			if err := (Decoder{
+				Parser:                NewValueParser(test.in),
+				DisallowUnknownFields: true,
+			}).DecodeOneOf(&v, types); err != nil {
+				t.Fatal(err)
+			}
+
+			if v != test.out {
+				t.Errorf("%#v", v)
+			}
+		})
+	}
+
+	t.Run("no match", func(t *testing.T) {
+		var v interface{} = "unchanged"
+
+		err := (Decoder{
+			Parser:                NewValueParser(map[string]interface{}{"radius": 1, "width": 2}),
+			DisallowUnknownFields: true,
+		}).DecodeOneOf(&v, types)
+
+		e, ok := err.(*OneOfError)
+		if !ok {
+			t.Fatalf("bad error: %v", err)
+		}
+
+		if len(e.Errors) != len(types) {
+			t.Errorf("%d errors were returned, expected %d", len(e.Errors), len(types))
+		}
+
+		if !strings.HasPrefix(err.Error(), "objconv: cannot decode the value into any of the candidate types (objconv.Circle: unknown field") {
+			t.Error("bad error:", err)
+		}
+
+		if v != "unchanged" {
+			t.Errorf("the target was modified: %#v", v)
+		}
+	})
+
+	t.Run("not assignable", func(t *testing.T) {
+		var v int
+
+		if err := NewDecoder(NewValueParser(1)).DecodeOneOf(&v, types); err == nil {
+			t.Error("expected an error for types that aren't assignable to the target")
+		}
+	})
+}
+
+func TestDecoderSkipFunc(t *testing.T) {
+	type T struct {
+		A int                    `objconv:"a"`
+		B []int                  `objconv:"b"`
+		M map[string]interface{} `objconv:"m"`
+	}
+
+	in := map[string]interface{}{
+		"a": 1,
+		"b": []int{1, 2, 3},
+		"m": map[string]interface{}{
+			"debug": "secret",
+			"value": map[string]interface{}{"debug": true, "x": 1},
+		},
+	}
+
+	var paths []string
+	var v T
+
+	dec := Decoder{
+		Parser: NewValueParser(in),
+		SkipFunc: func(path []string, t Type) bool {
+			paths = append(paths, fmt.Sprintf("%v:%s", path, t))
+			return path[len(path)-1] == "debug" || (len(path) == 2 && path[0] == "b" && path[1] == "1")
+		},
+	}
+
+	if err := dec.Decode(&v); err != nil {
+		t.Error(err)
+	}
+
+	if !reflect.DeepEqual(v, T{
+		A: 1,
+		B: []int{1, 0, 3},
+		M: map[string]interface{}{"value": map[interface{}]interface{}{"x": int64(1)}},
+	}) {
+		t.Errorf("%#v", v)
+	}
+
+	for _, path := range []string{
+		"[a]:int",
+		"[b]:array",
+		"[b 0]:int",
+		"[b 2]:int",
+		"[m]:map",
+		"[m debug]:string",
+		"[m value]:map",
+		"[m value debug]:bool",
+		"[m value x]:int",
+	} {
+		found := false
+		for _, p := range paths {
+			if p == path {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("path not found: %s in %v", path, paths)
+		}
+	}
+}
+
+func TestDecoderSkipFuncQuotedNumbers(t *testing.T) {
+	type T struct {
+		A int     `objconv:"a"`
+		B float64 `objconv:"b"`
+		C uint    `objconv:"c"`
+	}
+
+	var quoted []string
+	var v T
+
+	dec := Decoder{
+		Parser:  NewValueParser(map[string]interface{}{"a": 1, "b": "0.5", "c": "2"}),
+		Lenient: true,
+		SkipFunc: func(path []string, t Type) bool {
+			if t == String {
+				quoted = append(quoted, path[len(path)-1])
+			}
+			return false
+		},
+	}
+
+	if err := dec.Decode(&v); err != nil {
+		t.Error(err)
+	}
+
+	if v != (T{A: 1, B: 0.5, C: 2}) {
+		t.Errorf("%#v", v)
+	}
+
+	sort.Strings(quoted)
+
+	if !reflect.DeepEqual(quoted, []string{"b", "c"}) {
+		t.Error("bad quoted fields:", quoted)
+	}
+}
+
+func TestDecoderCollectErrors(t *testing.T) {
+	type U struct {
+		D uint `objconv:"d"`
+	}
+
+	type T struct {
+		A int            `objconv:"a"`
+		B []int          `objconv:"b"`
+		C U              `objconv:"c"`
+		M map[string]int `objconv:"m"`
+		E int            `objconv:"e"`
+	}
+
+	type In struct {
+		A string                 `objconv:"a"`
+		B []interface{}          `objconv:"b"`
+		C map[string]interface{} `objconv:"c"`
+		M map[string]interface{} `objconv:"m"`
+		E int                    `objconv:"e"`
+	}
+
+	in := In{
+		A: "hello",
+		B: []interface{}{1, "two", 3},
+		C: map[string]interface{}{"d": -1},
+		M: map[string]interface{}{"x": true},
+		E: 42,
+	}
+
+	var v T
+	dec := Decoder{
+		Parser:        NewValueParser(in),
+		CollectErrors: true,
+	}
+
+	err := dec.Decode(&v)
+	errs, ok := err.(FieldErrors)
+
+	if !ok {
+		t.Fatalf("bad error: %#v", err)
+	}
+
+	paths := make([]string, len(errs))
+	for i, e := range errs {
+		paths[i] = strings.Join(e.Path, ".")
+
+		if len(e.Message) == 0 || strings.HasPrefix(e.Message, "objconv:") {
+			t.Errorf("bad error message at %s: %q", paths[i], e.Message)
+		}
+	}
+
+	if !reflect.DeepEqual(paths, []string{"a", "b.1", "c.d", "m.x"}) {
+		t.Errorf("bad error paths: %q", paths)
+	}
+
+	if !reflect.DeepEqual(v, T{B: []int{1, 0, 3}, M: map[string]int{}, E: 42}) {
+		t.Errorf("bad value: %#v", v)
+	}
+}
+
+func TestDecoderPointerSliceNil(t *testing.T) {
+	type Item struct {
+		A int `objconv:"a"`
+	}
+
+	intPtr := func(i int) *int { return &i }
+
+	tests := []struct {
+		in  interface{}
+		out interface{}
+	}{
+		{
+			in:  []interface{}{map[string]interface{}{"a": 1}, nil, map[string]interface{}{"a": 2}},
+			out: []*Item{{A: 1}, nil, {A: 2}},
+		},
+		{
+			in:  []interface{}{1, nil, 3},
+			out: []*int{intPtr(1), nil, intPtr(3)},
+		},
+		{
+			in:  []interface{}{[]interface{}{1, nil}, nil, []interface{}{nil, 3}},
+			out: [][]*int{{intPtr(1), nil}, nil, {nil, intPtr(3)}},
+		},
+		{
+			in:  []interface{}{nil, map[string]interface{}{"a": 1}},
+			out: [2]*Item{nil, {A: 1}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%T", test.out), func(t *testing.T) {
+			v := reflect.New(reflect.TypeOf(test.out))
+
+			if err := NewDecoder(NewValueParser(test.in)).Decode(v.Interface()); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(v.Elem().Interface(), test.out) {
+				t.Errorf("%#v", v.Elem().Interface())
+			}
+		})
+	}
+
+	t.Run("no aliasing", func(t *testing.T) {
+		item := &Item{A: 42}
+		items := []*Item{item}
+
+		if err := NewDecoder(NewValueParser([]interface{}{map[string]interface{}{"a": 1}, nil})).Decode(&items); err != nil {
+			t.Fatal(err)
+		}
+
+		if item.A != 42 || items[0] == item || items[0].A != 1 || items[1] != nil {
+			t.Errorf("%#v %#v", item, items)
+		}
+	})
+}
+
+func TestDecoderTimeParts(t *testing.T) {
+	type T struct {
+		TS   time.Time `objconv:"ts,datefield=date,timefield=time"`
+		US   time.Time `objconv:"us,datefield=d,timefield=t,datelayout=01/02/2006,timelayout=3:04PM"`
+		Name string    `objconv:"name"`
+	}
+
+	tests := []struct {
+		in  map[string]interface{}
+		out T
+		err bool
+	}{
+		{
+			in:  map[string]interface{}{"date": "2021-03-04", "time": "05:06:07", "name": "A"},
+			out: T{TS: time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC), Name: "A"},
+		},
+		{
+			in:  map[string]interface{}{"date": "2021-03-04"},
+			out: T{TS: time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			in:  map[string]interface{}{"d": "12/31/2020", "t": "11:30PM"},
+			out: T{US: time.Date(2020, 12, 31, 23, 30, 0, 0, time.UTC)},
+		},
+		{
+			in:  map[string]interface{}{"name": "B"},
+			out: T{Name: "B"},
+		},
+		{
+			in:  map[string]interface{}{"date": "2021-13-04"},
+			err: true,
+		},
+		{
+			in:  map[string]interface{}{"time": "05:06:07"},
+			err: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test.in), func(t *testing.T) {
+			var v T
+
+			err := NewDecoder(NewValueParser(test.in)).Decode(&v)
+
+			switch {
+			case test.err && err == nil:
+				t.Errorf("expected an error but decoded %#v", v)
+			case !test.err && err != nil:
+				t.Error(err)
+			case !v.TS.Equal(test.out.TS) || !v.US.Equal(test.out.US) || v.Name != test.out.Name:
+				t.Errorf("%#v != %#v", test.out, v)
+			}
+		})
+	}
+}
+
+func TestDecoderLenient(t *testing.T) {
+	type T struct {
+		A int            `objconv:"a"`
+		B []uint16       `objconv:"b"`
+		C map[string]int `objconv:"c"`
+	}
+
+	in := map[string]interface{}{
+		"a": 1.5,
+		"b": []interface{}{2.0, 3.75},
+		"c": map[string]interface{}{"x": -4.25},
+	}
+
+	t.Run("strict", func(t *testing.T) {
+		var v T
+		if err := NewDecoder(NewValueParser(in)).Decode(&v); err == nil {
+			t.Error("expected an error decoding floats into integers")
+		}
+	})
+
+	t.Run("lenient", func(t *testing.T) {
+		var v T
+		dec := NewDecoder(NewValueParser(in))
+		dec.Lenient = true
+
+		if err := dec.Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(v, T{A: 1, B: []uint16{2, 3}, C: map[string]int{"x": -4}}) {
+			t.Errorf("bad value: %#v", v)
+		}
+
+		warnings := dec.Warnings()
+		sort.Strings(warnings)
+
+		if !reflect.DeepEqual(warnings, []string{
+			"a: float 1.5 truncated to 1",
+			"b.1: float 3.75 truncated to 3",
+			"c.x: float -4.25 truncated to -4",
+		}) {
+			t.Errorf("bad warnings: %q", warnings)
+		}
+	})
+
+	t.Run("literal decoder", func(t *testing.T) {
+		var v T
+		var warnings []string
+
+		dec := Decoder{Parser: NewValueParser(in), Lenient: true}
+
+		if err := dec.Decode(ValueDecoderFunc(func(d Decoder) (err error) {
+			if err = d.Decode(&v); err == nil {
+				warnings = d.Warnings()
+			}
+			return
+		})); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(warnings) != 3 {
+			t.Errorf("bad warnings: %q", warnings)
+		}
+	})
+
+	t.Run("overflow", func(t *testing.T) {
+		var v uint8
+		dec := NewDecoder(NewValueParser(256.5))
+		dec.Lenient = true
+
+		if err := dec.Decode(&v); err == nil {
+			t.Error("expected an overflow error")
+		}
+	})
+
+	t.Run("lossless", func(t *testing.T) {
+		var v []int
+		dec := NewDecoder(NewValueParser([]interface{}{1.0, 2.0}))
+		dec.Lenient = true
+
+		if err := dec.Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+
+		if w := dec.Warnings(); w != nil {
+			t.Errorf("unexpected warnings: %q", w)
+		}
+	})
+
+	t.Run("integral floats", func(t *testing.T) {
+		var v T
+		dec := NewDecoder(NewValueParser(in))
+		dec.IntegralFloats = true
+
+		if err := dec.Decode(&v); err == nil {
+			t.Error("expected an error decoding floats with a fractional part")
+		}
+
+		var a []int
+		dec = NewDecoder(NewValueParser([]interface{}{1e3, -2.0}))
+		dec.IntegralFloats = true
+
+		if err := dec.Decode(&a); err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(a, []int{1000, -2}) {
+			t.Errorf("bad value: %#v", a)
+		}
+	})
+}
+
+func TestDecoderLenientStrings(t *testing.T) {
+	type T struct {
+		Active  bool    `objconv:"active,lenient"`
+		Count   uint8   `objconv:"count,lenient"`
+		Ratio   float64 `objconv:"ratio,lenient"`
+		Delta   int     `objconv:"delta,lenient"`
+		Enabled bool    `objconv:"enabled"`
+	}
+
+	t.Run("field", func(t *testing.T) {
+		var v T
+		dec := NewDecoder(NewValueParser(map[string]interface{}{
+			"active": "true",
+			"count":  "42",
+			"ratio":  "0.5",
+			"delta":  "-2.5",
+		}))
+
+		if err := dec.Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+
+		if v != (T{Active: true, Count: 42, Ratio: 0.5, Delta: -2}) {
+			t.Errorf("bad value: %#v", v)
+		}
+
+		if w := dec.Warnings(); !reflect.DeepEqual(w, []string{"delta: float -2.5 truncated to -2"}) {
+			t.Errorf("bad warnings: %q", w)
+		}
+	})
+
+	t.Run("correct types", func(t *testing.T) {
+		var v T
+		in := map[string]interface{}{"active": true, "count": 42, "ratio": 0.5, "delta": -2}
+
+		if err := NewDecoder(NewValueParser(in)).Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+
+		if v != (T{Active: true, Count: 42, Ratio: 0.5, Delta: -2}) {
+			t.Errorf("bad value: %#v", v)
+		}
+	})
+
+	t.Run("strict field", func(t *testing.T) {
+		var v T
+		if err := NewDecoder(NewValueParser(map[string]interface{}{"enabled": "true"})).Decode(&v); err == nil {
+			t.Error("expected an error decoding a string into a strict field")
+		}
+	})
+
+	t.Run("global", func(t *testing.T) {
+		var v T
+		dec := NewDecoder(NewValueParser(map[string]interface{}{"enabled": "false", "active": "1"}))
+		dec.Lenient = true
+
+		if err := dec.Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+
+		if v != (T{Active: true}) {
+			t.Errorf("bad value: %#v", v)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		tests := []struct {
+			in  map[string]interface{}
+			err string
+		}{
+			{map[string]interface{}{"active": "yes"}, `objconv: cannot convert string "yes" to bool`},
+			{map[string]interface{}{"count": "256"}, `objconv: 256 overflows the maximum value of 255 for uint8`},
+			{map[string]interface{}{"ratio": "abc"}, `objconv: cannot convert string "abc" to float`},
+		}
+
+		for _, test := range tests {
+			var v T
+			if err := NewDecoder(NewValueParser(test.in)).Decode(&v); err == nil {
+				t.Errorf("%v: expected an error", test.in)
+			} else if !strings.HasPrefix(err.Error(), "objconv: ") {
+				t.Errorf("%v: %s", test.in, err)
+			}
+		}
+	})
+}
+
+func TestDecoderDurationObjects(t *testing.T) {
+	tests := []struct {
+		in  interface{}
+		out time.Duration
+	}{
+		{map[string]interface{}{"value": 150, "unit": "ms"}, 150 * time.Millisecond},
+		{map[string]interface{}{"value": 42, "unit": "ns"}, 42 * time.Nanosecond},
+		{map[string]interface{}{"value": 3, "unit": "us"}, 3 * time.Microsecond},
+		{map[string]interface{}{"value": 0.3, "unit": "s"}, 300 * time.Millisecond},
+		{map[string]interface{}{"value": 2, "unit": "m"}, 2 * time.Minute},
+		{map[string]interface{}{"value": 1.5, "unit": "h"}, 90 * time.Minute},
+		{"1m30s", 90 * time.Second},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test.in), func(t *testing.T) {
+			var v time.Duration
+			dec := Decoder{Parser: NewValueParser(test.in), DurationObjects: true}
+
+			if err := dec.Decode(&v); err != nil {
+				t.Fatal(err)
+			}
+
+			if v != test.out {
+				t.Errorf("%s != %s", v, test.out)
+			}
+		})
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		for _, in := range []interface{}{
+			map[string]interface{}{"value": 1, "unit": "d"},
+			map[string]interface{}{"value": 1},
+			map[string]interface{}{"unit": "s"},
+			map[string]interface{}{"value": 1e300, "unit": "h"},
+		} {
+			var v time.Duration
+			dec := Decoder{Parser: NewValueParser(in), DurationObjects: true}
+
+			if err := dec.Decode(&v); err == nil {
+				t.Errorf("%v: expected an error", in)
+			}
+		}
+
+		var v time.Duration
+		dec := Decoder{Parser: NewValueParser(map[string]interface{}{"value": 1, "unit": "s"})}
+
+		if err := dec.Decode(&v); err == nil {
+			t.Error("expected an error when DurationObjects is not set")
+		}
+	})
+}
+
+func TestDecoderMapKeyField(t *testing.T) {
+	type Item struct {
+		Key   string `objconv:"-,mapkey"`
+		Value int    `objconv:"value"`
+	}
+
+	type Entry struct {
+		ID   int    `objconv:"-,mapkey"`
+		Name string `objconv:"name"`
+	}
+
+	t.Run("slice of structs", func(t *testing.T) {
+		var v []Item
+		in := map[string]interface{}{"a": map[string]interface{}{"value": 1}}
+
+		if err := NewDecoder(NewValueParser(in)).Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(v, []Item{{Key: "a", Value: 1}}) {
+			t.Errorf("bad value: %#v", v)
+		}
+	})
+
+	t.Run("slice of pointers with coerced keys", func(t *testing.T) {
+		var v []*Entry
+		in := map[string]interface{}{"42": map[string]interface{}{"name": "answer"}}
+
+		if err := NewDecoder(NewValueParser(in)).Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(v) != 1 || !reflect.DeepEqual(*v[0], Entry{ID: 42, Name: "answer"}) {
+			t.Errorf("bad value: %#v", v)
+		}
+	})
+
+	t.Run("struct", func(t *testing.T) {
+		var v Item
+		in := map[string]interface{}{"value": 2, "-": "ignored"}
+
+		if err := NewDecoder(NewValueParser(in)).Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+
+		if v != (Item{Value: 2}) {
+			t.Errorf("bad value: %#v", v)
+		}
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		var v []Entry
+		in := map[string]interface{}{"x": map[string]interface{}{}}
+		err := NewDecoder(NewValueParser(in)).Decode(&v)
+
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+
+		if s := err.Error(); s != `objconv: cannot decode map key x into field ID: cannot convert string "x" to int` {
+			t.Error(s)
+		}
+	})
+}
+
+func TestDecoderPositionalFields(t *testing.T) {
+	type attrs struct {
+		Name string `objconv:"name"`
+		Size int    `objconv:"size"`
+	}
+
+	type reply struct {
+		Cursor int               `objconv:",positional"`
+		Kind   string            `objconv:",positional"`
+		Attrs  attrs             `objconv:",rest"`
+		Extra  map[string]string `objconv:"extra"`
+	}
+
+	tests := []struct {
+		name string
+		in   interface{}
+		out  reply
+		err  string
+	}{
+		{
+			name: "positional and named",
+			in:   []interface{}{42, "file", []byte("name"), "a.txt", "size", 10},
+			out:  reply{Cursor: 42, Kind: "file", Attrs: attrs{Name: "a.txt", Size: 10}},
+		},
+		{
+			name: "positional only",
+			in:   []interface{}{1, "dir"},
+			out:  reply{Cursor: 1, Kind: "dir"},
+		},
+		{
+			name: "map",
+			in:   map[string]interface{}{"extra": map[string]string{"A": "B"}},
+			out:  reply{Extra: map[string]string{"A": "B"}},
+		},
+		{
+			name: "too short",
+			in:   []interface{}{1},
+			err:  "objconv: cannot decode array of 1 elements into objconv.reply which has 2 positional fields",
+		},
+		{
+			name: "odd pairs",
+			in:   []interface{}{1, "dir", "name"},
+			err:  "objconv: cannot decode the 1 elements following the positional fields of objconv.reply into field Attrs, they must be key/value pairs",
+		},
+		{
+			name: "bad positional value",
+			in:   []interface{}{"x", "dir"},
+			err:  "objconv: bad value for positional field Cursor: cannot convert from string to int",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var v reply
+			err := NewDecoder(NewValueParser(test.in)).Decode(&v)
+
+			if len(test.err) != 0 {
+				if err == nil || err.Error() != test.err {
+					t.Errorf("bad error: %v", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(v, test.out) {
+				t.Errorf("%#v != %#v", v, test.out)
+			}
+		})
+	}
+
+	t.Run("no rest field", func(t *testing.T) {
+		var v struct {
+			A int `objconv:",positional"`
+		}
+
+		err := NewDecoder(NewValueParser([]interface{}{1, "k", "v"})).Decode(&v)
+
+		if err == nil || !strings.Contains(err.Error(), "no field with the rest tag option") {
+			t.Errorf("bad error: %v", err)
+		}
+	})
+}
+
+func TestDecoderUnwrapArrays(t *testing.T) {
+	type T struct {
+		A int           `objconv:"a"`
+		B string        `objconv:"b"`
+		C bool          `objconv:"c"`
+		D float64       `objconv:"d"`
+		E []byte        `objconv:"e"`
+		F time.Duration `objconv:"f"`
+		G uint          `objconv:"g"`
+	}
+
+	in := map[string]interface{}{
+		"a": []interface{}{1},
+		"b": []interface{}{"hello"},
+		"c": []interface{}{true},
+		"d": []interface{}{0.5},
+		"e": []interface{}{[]byte("abc")},
+		"f": []interface{}{time.Second},
+		"g": []interface{}{[]interface{}{2}},
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		var v T
+
+		if err := NewDecoder(NewValueParser(in)).Decode(&v); err == nil {
+			t.Error("expected an error when decoding arrays into scalars")
+		}
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		var v T
+
+		if err := (Decoder{Parser: NewValueParser(in), UnwrapArrays: true}).Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(v, T{A: 1, B: "hello", C: true, D: 0.5, E: []byte("abc"), F: time.Second, G: 2}) {
+			t.Errorf("%#v", v)
+		}
+	})
+
+	t.Run("too many elements", func(t *testing.T) {
+		var v int
+		err := (Decoder{Parser: NewValueParser([]int{1, 2}), UnwrapArrays: true}).Decode(&v)
+
+		if err == nil || err.Error() != "objconv: cannot decode array of more than one element into int" {
+			t.Errorf("bad error: %v", err)
+		}
+	})
+
+	t.Run("empty array", func(t *testing.T) {
+		v := "hello"
+		err := (Decoder{Parser: NewValueParser([]int{}), UnwrapArrays: true}).Decode(&v)
+
+		if err == nil || err.Error() != "objconv: cannot decode empty array into string" {
+			t.Errorf("bad error: %v", err)
+		}
+
+		if err := (Decoder{Parser: NewValueParser([]int{}), UnwrapArrays: true, EmptyArrayAsZero: true}).Decode(&v); err != nil {
+			t.Error(err)
+		}
+
+		if v != "" {
+			t.Errorf("bad value: %q", v)
+		}
+	})
+}
+
+func TestDecoderDurationUnit(t *testing.T) {
+	tests := []struct {
+		in   interface{}
+		unit time.Duration
+		out  time.Duration
+		err  bool
+	}{
+		{in: 1500, out: 1500 * time.Nanosecond},
+		{in: 1500, unit: time.Millisecond, out: 1500 * time.Millisecond},
+		{in: uint64(3), unit: time.Second, out: 3 * time.Second},
+		{in: 1.5, unit: time.Second, out: 1500 * time.Millisecond},
+		{in: -0.25, unit: time.Second, out: -250 * time.Millisecond},
+		{in: int64(math.MaxInt64/1000 + 1), unit: time.Microsecond, err: true},
+		{in: 1e12, unit: time.Hour, err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%v/%s", test.in, test.unit), func(t *testing.T) {
+			var v time.Duration
+			err := (Decoder{Parser: NewValueParser(test.in), DurationUnit: test.unit}).Decode(&v)
+
+			if test.err {
+				if err == nil {
+					t.Error("expected an overflow error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if v != test.out {
+				t.Errorf("%s != %s", v, test.out)
+			}
+		})
+	}
+}
+
+func TestDecoderDecodeArray(t *testing.T) {
+	var sum int
+	var cnt int
+
+	d := NewDecoder(NewValueParser([]int{1, 2, 3, 4}))
+
+	if err := d.DecodeArray(func(d Decoder) error {
+		var v int
+		if err := d.Decode(&v); err != nil {
+			return err
+		}
+		sum += v
+		cnt++
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if sum != 10 || cnt != 4 {
+		t.Errorf("bad iteration: sum = %d, count = %d", sum, cnt)
+	}
+
+	stop := errors.New("stop")
+	cnt = 0
+
+	if err := NewDecoder(NewValueParser([]int{1, 2, 3, 4})).DecodeArray(func(d Decoder) error {
+		if cnt++; cnt == 2 {
+			return stop
+		}
+		return d.Decode(nil)
+	}); err != stop {
+		t.Error("expected the error returned by the callback but got", err)
+	}
+
+	if cnt != 2 {
+		t.Error("iteration didn't stop after the callback returned an error:", cnt)
+	}
+}
+
+func TestDecoderFieldConstraints(t *testing.T) {
+	type T struct {
+		Age   int      `objconv:"age,min=0,max=150"`
+		Score *float64 `objconv:"score,min=0.5"`
+		Name  string   `objconv:"name,minlen=2,maxlen=5"`
+		Tags  []string `objconv:"tags,maxlen=2"`
+		Code  string   `objconv:"code,omitempty,pattern=^[a-z]{2,3}$"`
+	}
+
+	tests := []struct {
+		in  map[string]interface{}
+		err string
+	}{
+		{
+			in: map[string]interface{}{"age": 42, "score": 0.5, "name": "héllo", "tags": []string{"a"}, "code": "abc"},
+		},
+		{
+			in: map[string]interface{}{"age": nil, "score": nil},
+		},
+		{
+			in:  map[string]interface{}{"age": 151},
+			err: "objconv: bad value for field age: 151 violates the max=150 constraint",
+		},
+		{
+			in:  map[string]interface{}{"age": -1},
+			err: "objconv: bad value for field age: -1 violates the min=0 constraint",
+		},
+		{
+			in:  map[string]interface{}{"score": 0.25},
+			err: "objconv: bad value for field score: 0.25 violates the min=0.5 constraint",
+		},
+		{
+			in:  map[string]interface{}{"name": "a"},
+			err: "objconv: bad value for field name: length 1 violates the minlen=2 constraint",
+		},
+		{
+			in:  map[string]interface{}{"tags": []string{"a", "b", "c"}},
+			err: "objconv: bad value for field tags: length 3 violates the maxlen=2 constraint",
+		},
+		{
+			in:  map[string]interface{}{"code": "ABC"},
+			err: `objconv: bad value for field code: "ABC" doesn't match the pattern ^[a-z]{2,3}$`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test.in), func(t *testing.T) {
+			var v T
+			err := NewDecoder(NewValueParser(test.in)).Decode(&v)
+
+			if len(test.err) == 0 {
+				if err != nil {
+					t.Error(err)
+				}
+			} else if err == nil || err.Error() != test.err {
+				t.Error("bad error:", err)
+			}
+		})
+	}
+
+	t.Run("lenient", func(t *testing.T) {
+		var v T
+		dec := NewDecoder(NewValueParser(map[string]interface{}{"age": 200, "code": "x"}))
+		dec.Lenient = true
+
+		if err := dec.Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+
+		if v.Age != 200 || v.Code != "x" {
+			t.Errorf("bad value: %#v", v)
+		}
+
+		warnings := dec.Warnings()
+		sort.Strings(warnings)
+
+		if !reflect.DeepEqual(warnings, []string{
+			"age: 200 violates the max=150 constraint",
+			`code: "x" doesn't match the pattern ^[a-z]{2,3}$`,
+		}) {
+			t.Errorf("bad warnings: %q", warnings)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		type U struct {
+			Name string `objconv:"name,min=1"`
+		}
+
+		var v U
+		err := NewDecoder(NewValueParser(map[string]interface{}{"name": "x"})).Decode(&v)
+
+		if err == nil || err.Error() != "objconv: the min constraint of field name can't be applied to values of type string" {
+			t.Error("bad error:", err)
+		}
+	})
+}
+
+func TestDecodeMapKeys(t *testing.T) {
+	type key struct {
+		A int `objconv:"a"`
+	}
+
+	type id uint16
+
+	tests := []struct {
+		in  interface{}
+		out interface{}
+	}{
+		{
+			in:  map[string]string{"1": "A", "-2": "B"},
+			out: map[int]string{1: "A", -2: "B"},
+		},
+		{
+			in:  map[string]string{"1": "A", "65535": "B"},
+			out: map[id]string{1: "A", 65535: "B"},
+		},
+		{
+			in:  map[string]string{"-128": "A", "127": "B", "+5": "C"},
+			out: map[int8]string{-128: "A", 127: "B", 5: "C"},
+		},
+		{
+			in:  map[string]string{"255": "A", "+5": "B"},
+			out: map[uint8]string{255: "A", 5: "B"},
+		},
+		{
+			in:  map[string]bool{"1.5": true},
+			out: map[float32]bool{1.5: true},
+		},
+		{
+			in:  map[string]int{"true": 1, "false": 0},
+			out: map[bool]int{true: 1, false: 0},
+		},
+		{
+			in:  map[int]string{1: "A", 2: "B"},
+			out: map[string]string{"1": "A", "2": "B"},
+		},
+		{
+			in:  map[float64]interface{}{1.5: nil},
+			out: map[string]interface{}{"1.5": nil},
+		},
+		{
+			in:  map[int]int{1: 2},
+			out: map[int64]int{1: 2},
+		},
+		{
+			in:  map[key]int{{A: 1}: 2},
+			out: map[key]int{{A: 1}: 2},
+		},
+		{
+			in:  map[string]time.Duration{"1s": time.Second},
+			out: map[time.Duration]time.Duration{time.Second: time.Second},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(reflect.TypeOf(test.out).String(), func(t *testing.T) {
+			v := reflect.New(reflect.TypeOf(test.out))
+
+			if err := NewDecoder(NewValueParser(test.in)).Decode(v.Interface()); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(v.Elem().Interface(), test.out) {
+				t.Errorf("%#v", v.Elem().Interface())
+			}
+		})
+	}
+}
+
+func TestDecodeMapKeysError(t *testing.T) {
+	tests := []struct {
+		in  interface{}
+		out interface{}
+		err string
+	}{
+		{
+			in:  map[string]int{"A": 1},
+			out: map[int]int{},
+			err: `objconv: malformed map key "A", expected an integer for int`,
+		},
+		{
+			in:  map[string]int{"300": 1},
+			out: map[uint8]int{},
+			err: `objconv: map key "300" overflows the maximum value of 255 for uint8`,
+		},
+		{
+			in:  map[string]int{"200": 1},
+			out: map[int8]int{},
+			err: `objconv: map key "200" overflows the maximum value of 127 for int8`,
+		},
+		{
+			in:  map[string]int{"-129": 1},
+			out: map[int8]int{},
+			err: `objconv: map key "-129" overflows the minimum value of -128 for int8`,
+		},
+		{
+			in:  map[string]int{"-5": 1},
+			out: map[uint16]int{},
+			err: `objconv: map key "-5" overflows the minimum value of 0 for uint16`,
+		},
+		{
+			in:  map[string]int{"99999999999999999999": 1},
+			out: map[int64]int{},
+			err: `objconv: map key "99999999999999999999" overflows the maximum value of 9223372036854775807 for int64`,
+		},
+		{
+			in:  map[string]int{"1.5": 1},
+			out: map[int8]int{},
+			err: `objconv: malformed map key "1.5", expected an integer for int8`,
+		},
+		{
+			in:  map[string]int{"-abc": 1},
+			out: map[uint8]int{},
+			err: `objconv: malformed map key "-abc", expected an integer for uint8`,
+		},
+		{
+			in:  map[[1]int]int{{1}: 2},
+			out: map[interface{}]interface{}{},
+			err: "objconv: cannot use a value of type []interface {} as a map key",
+		},
+		{
+			in:  map[[1]int]int{{1}: 2},
+			out: map[interface{}]int{},
+			err: "objconv: cannot use a value of type []interface {} as a map key",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.err, func(t *testing.T) {
+			v := reflect.New(reflect.TypeOf(test.out))
+
+			if err := NewDecoder(NewValueParser(test.in)).Decode(v.Interface()); err == nil || err.Error() != test.err {
+				t.Error("bad error:", err)
+			}
+		})
+	}
+}
+
+// cancelParser cancels a context after parsing a number of integers.
+type cancelParser struct {
+	*ValueParser
+	n      int
+	cancel func()
+}
+
+func (p *cancelParser) ParseInt() (int64, error) {
+	if p.n--; p.n == 0 {
+		p.cancel()
+	}
+	return p.ValueParser.ParseInt()
+}
+
+func TestDecoderDecodeContext(t *testing.T) {
+	values := make([]interface{}, 1000)
+	for i := range values {
+		values[i] = map[string]interface{}{"id": i, "tags": []string{"a", "b"}}
+	}
+
+	for _, collect := range []bool{false, true} {
+		t.Run(fmt.Sprintf("CollectErrors=%t", collect), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			p := &cancelParser{ValueParser: NewValueParser(values), n: 10, cancel: cancel}
+			d := Decoder{Parser: p, CollectErrors: collect}
+
+			var v []struct {
+				ID   int      `objconv:"id"`
+				Tags []string `objconv:"tags"`
+			}
+
+			if err := d.DecodeContext(ctx, &v); err != context.Canceled {
+				t.Error("bad error:", err)
+			}
+
+			if p.n != 0 {
+				t.Errorf("the decoder didn't stop after the cancellation (%d more values parsed)", -p.n)
+			}
+		})
+	}
+
+	t.Run("Cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		var v int
+
+		if err := NewDecoder(NewValueParser(1)).DecodeContext(ctx, &v); err != context.Canceled {
+			t.Error("bad error:", err)
+		}
+	})
+
+	t.Run("Completed", func(t *testing.T) {
+		var v []int
+
+		if err := NewDecoder(NewValueParser([]int{1, 2, 3})).DecodeContext(context.Background(), &v); err != nil {
+			t.Error(err)
+		} else if !reflect.DeepEqual(v, []int{1, 2, 3}) {
+			t.Errorf("%#v", v)
+		}
+	})
+}
+
+func TestDecodeDefaultTag(t *testing.T) {
+	type config struct {
+		Env     string        `objconv:"env,default=production"`
+		Port    int           `objconv:"port,default=8080,defaultempty"`
+		Debug   bool          `objconv:"debug,default=true"`
+		Ratio   *float64      `objconv:"ratio,default=0.5"`
+		Timeout time.Duration `objconv:"timeout,default=1s,defaultempty"`
+		Name    string        `objconv:"name"`
+	}
+
+	half := 0.5
+
+	tests := []struct {
+		name string
+		in   map[string]interface{}
+		out  config
+	}{
+		{
+			name: "absent",
+			in:   map[string]interface{}{"name": "api"},
+			out:  config{Env: "production", Port: 8080, Debug: true, Ratio: &half, Timeout: time.Second, Name: "api"},
+		},
+		{
+			name: "present",
+			in:   map[string]interface{}{"env": "dev", "port": 80, "debug": false, "ratio": 1.0, "timeout": time.Minute},
+			out:  config{Env: "dev", Port: 80, Ratio: new(float64), Timeout: time.Minute},
+		},
+		{
+			name: "empty",
+			in:   map[string]interface{}{"env": "", "port": 0, "ratio": nil, "timeout": nil},
+			out:  config{Port: 8080, Debug: true, Timeout: time.Second},
+		},
+	}
+	*tests[1].out.Ratio = 1.0
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var c config
+
+			if err := NewDecoder(NewValueParser(test.in)).Decode(&c); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(c, test.out) {
+				t.Errorf("%#v", c)
+			}
+		})
+	}
+
+	t.Run("copy", func(t *testing.T) {
+		var c1, c2 config
+
+		if err := NewDecoder(NewValueParser(map[string]interface{}{})).Decode(&c1); err != nil {
+			t.Fatal(err)
+		}
+		*c1.Ratio = 2
+
+		if err := NewDecoder(NewValueParser(map[string]interface{}{})).Decode(&c2); err != nil {
+			t.Fatal(err)
+		}
+
+		if *c2.Ratio != 0.5 {
+			t.Error("the default value was modified:", *c2.Ratio)
+		}
+	})
+}
+
+func TestDecodeDefaultTagError(t *testing.T) {
+	tests := []struct {
+		out interface{}
+		err string
+	}{
+		{
+			out: &struct {
+				Port int `objconv:"port,default=http"`
+			}{},
+			err: `objconv: invalid default value of field port: cannot convert string "http" to int`,
+		},
+		{
+			out: &struct {
+				Port int `objconv:"port,default=0,min=1"`
+			}{},
+			err: "objconv: invalid default value of field port: 0 violates the min=1 constraint",
+		},
+		{
+			out: &struct {
+				Tags []int `objconv:"tags,default=1"`
+			}{},
+			err: "objconv: invalid default value of field tags: cannot convert from string to array",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.err, func(t *testing.T) {
+			// The error is reported even if the field is present, the default
+			// value is checked when the struct type is looked up.
+			err := NewDecoder(NewValueParser(map[string]interface{}{"port": 42})).Decode(test.out)
+
+			if err == nil || err.Error() != test.err {
+				t.Error("bad error:", err)
+			}
+		})
+	}
+}
+
+func TestDecoderReset(t *testing.T) {
+	d := NewDecoder(NewValueParser("1.5"))
+	d.Lenient = true
+
+	var i int
+
+	if err := d.Decode(&i); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(d.Warnings()) != 1 {
+		t.Fatal("expected a warning:", d.Warnings())
+	}
+
+	d.Reset(NewValueParser("42"))
+
+	if w := d.Warnings(); w != nil {
+		t.Error("warnings were retained after the reset:", w)
+	}
+
+	if err := d.Decode(&i); err != nil {
+		t.Fatal(err)
+	}
+
+	if i != 42 {
+		t.Error(i)
+	}
+
+	if !d.Lenient {
+		t.Error("the configuration was not retained after the reset")
+	}
+}
+
+func BenchmarkDecoderReset(b *testing.B) {
+	type T struct {
+		A int    `objconv:"a"`
+		B string `objconv:"b"`
+	}
+
+	in := map[string]interface{}{"a": 1, "b": "hello"}
+
+	b.Run("NewDecoder", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i != b.N; i++ {
+			var v T
+			if err := NewDecoder(NewValueParser(in)).Decode(&v); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("Reset", func(b *testing.B) {
+		pool := sync.Pool{New: func() interface{} { return NewDecoder(NewValueParser(nil)) }}
+		b.ReportAllocs()
+		for i := 0; i != b.N; i++ {
+			var v T
+			d := pool.Get().(*Decoder)
+			d.Reset(NewValueParser(in))
+			if err := d.Decode(&v); err != nil {
+				b.Fatal(err)
+			}
+			pool.Put(d)
+		}
+	})
+}
+
+func TestDecodeSliceReuse(t *testing.T) {
+	type T struct {
+		A int `objconv:"a"`
+	}
+
+	prev := &T{A: 1}
+	s := make([]*T, 3, 4)
+	s[0], s[1], s[2] = prev, &T{A: 2}, &T{A: 3}
+	p := &s[0]
+
+	if err := NewDecoder(NewValueParser([]interface{}{
+		map[string]interface{}{"a": 10},
+		map[string]interface{}{},
+	})).Decode(&s); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s) != 2 || s[0].A != 10 || s[1].A != 0 {
+		t.Errorf("%#v", s)
+	}
+
+	if &s[0] != p {
+		t.Error("the backing array of the slice was not reused")
+	}
+
+	if prev.A != 1 {
+		t.Error("the value previously referenced by the slice was modified")
+	}
+
+	if s[:3][2] != nil {
+		t.Error("the element beyond the length of the slice retained a stale pointer")
+	}
+
+	m := map[string]interface{}{"a": 1}
+
+	if err := NewDecoder(NewValueParser([]interface{}{m, m, m, m, m})).Decode(&s); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s) != 5 || s[4].A != 1 {
+		t.Errorf("%#v", s)
+	}
+
+	var e []string
+
+	if err := NewDecoder(NewValueParser([]interface{}{})).Decode(&e); err != nil {
+		t.Fatal(err)
+	}
+
+	if e == nil || len(e) != 0 {
+		t.Errorf("%#v", e)
+	}
+}
+
+func TestDecodeMissingSliceAsEmpty(t *testing.T) {
+	type T struct {
+		A []int          `objconv:"a"`
+		B map[string]int `objconv:"b"`
+		C []string       `objconv:"c"`
+		D []int          `objconv:"d"`
+	}
+
+	v := T{D: []int{1}}
+
+	if err := (Decoder{
+		Parser:              NewValueParser(map[string]interface{}{"c": nil}),
+		MissingSliceAsEmpty: true,
+	}).Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(v, T{A: []int{}, B: map[string]int{}, D: []int{1}}) {
+		t.Errorf("%#v", v)
+	}
+
+	if v.C != nil {
+		t.Error("present field with a null value must be nil")
+	}
+
+	var w T
+
+	if err := NewDecoder(NewValueParser(map[string]interface{}{})).Decode(&w); err != nil {
+		t.Fatal(err)
+	}
+
+	if w.A != nil || w.B != nil {
+		t.Errorf("absent fields must be nil without the option: %#v", w)
+	}
+}
+
+func TestDecodeDisallowUnknownFields(t *testing.T) {
+	type N struct {
+		X int `objconv:"x"`
+	}
+
+	type T struct {
+		A int                    `objconv:"a"`
+		N N                      `objconv:"n"`
+		M map[string]interface{} `objconv:"m"`
+		S string                 `objconv:"-"`
+	}
+
+	tests := []struct {
+		in  map[string]interface{}
+		err string
+	}{
+		{
+			in: map[string]interface{}{"a": 1, "n": map[string]interface{}{"x": 2}, "m": map[string]interface{}{"any": 3}},
+		},
+		{
+			in:  map[string]interface{}{"a": 1, "b c": 2},
+			err: `objconv: unknown field "b c" in objconv.T`,
+		},
+		{
+			in:  map[string]interface{}{"n": map[string]interface{}{"y": 2}},
+			err: `objconv: unknown field "y" in objconv.N`,
+		},
+		{
+			in:  map[string]interface{}{"S": "ignored"},
+			err: `objconv: unknown field "S" in objconv.T`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.err, func(t *testing.T) {
+			var v T
+
+			err := (Decoder{
+				Parser:                NewValueParser(test.in),
+				DisallowUnknownFields: true,
+			}).Decode(&v)
+
+			switch {
+			case test.err == "" && err != nil:
+				t.Error(err)
+			case test.err != "" && (err == nil || err.Error() != test.err):
+				t.Error("bad error:", err)
+			}
+		})
+	}
+
+	var m map[string]interface{}
+
+	if err := (Decoder{
+		Parser:                NewValueParser(map[string]interface{}{"b": 2}),
+		DisallowUnknownFields: true,
+	}).Decode(&m); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDecodeReadonly(t *testing.T) {
+	type T struct {
+		ID      string    `objconv:"id"`
+		ETag    string    `objconv:"etag,readonly"`
+		Tags    []string  `objconv:"tags,readonly"`
+		Created time.Time `objconv:"created,readonly,default=2020-01-01T00:00:00Z"`
+	}
+
+	created := time.Date(2016, 12, 12, 0, 0, 0, 0, time.UTC)
+	in := T{ID: "1", ETag: "abc", Tags: []string{"a"}, Created: created}
+
+	e := NewValueEmitter()
+
+	if err := NewEncoder(e).Encode(in); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(e.Value(), map[interface{}]interface{}{
+		"id":      "1",
+		"etag":    "abc",
+		"tags":    []interface{}{"a"},
+		"created": created,
+	}) {
+		t.Errorf("bad encoding: %#v", e.Value())
+	}
+
+	for _, disallowUnknownFields := range []bool{false, true} {
+		t.Run(fmt.Sprint(disallowUnknownFields), func(t *testing.T) {
+			v := T{ETag: "server"}
+
+			if err := (Decoder{
+				Parser:                NewValueParser(e.Value()),
+				DisallowUnknownFields: disallowUnknownFields,
+				MissingSliceAsEmpty:   true,
+			}).Decode(&v); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(v, T{ID: "1", ETag: "server"}) {
+				t.Errorf("bad decoding: %#v", v)
+			}
+		})
+	}
+}
+
+func TestDecodeKeyPrefix(t *testing.T) {
+	type N struct {
+		X int `objconv:"x"`
+	}
+
+	type T struct {
+		A int            `objconv:"a"`
+		B string         `objconv:"b"`
+		N N              `objconv:"n"`
+		M map[string]int `objconv:"m"`
+	}
+
+	in := map[string]interface{}{
+		"myapp_a": 1,
+		"b":       "unprefixed",
+		"MYAPP_B": "other case",
+		"myapp_n": map[string]interface{}{"myapp_x": 2},
+		"myapp_m": map[string]interface{}{"myapp_k": 4},
+	}
+
+	tests := []struct {
+		unprefixed bool
+		out        T
+	}{
+		{false, T{A: 1, N: N{X: 2}, M: map[string]int{"myapp_k": 4}}},
+		{true, T{A: 1, B: "unprefixed", N: N{X: 2}, M: map[string]int{"myapp_k": 4}}},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test.unprefixed), func(t *testing.T) {
+			var v T
+
+			if err := (Decoder{
+				Parser:              NewValueParser(in),
+				KeyPrefix:           "myapp_",
+				MatchUnprefixedKeys: test.unprefixed,
+			}).Decode(&v); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(v, test.out) {
+				t.Errorf("%#v", v)
+			}
+		})
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		e := NewValueEmitter()
+		in := T{A: 1, B: "hello", M: map[string]int{"k": 2}}
+
+		if err := (Encoder{Emitter: e, KeyPrefix: "myapp_"}).Encode(in); err != nil {
+			t.Fatal(err)
+		}
+
+		m := e.Value().(map[interface{}]interface{})
+
+		if _, ok := m["myapp_a"]; !ok {
+			t.Errorf("missing prefixed key: %#v", m)
+		}
+
+		if !reflect.DeepEqual(m["myapp_m"], map[interface{}]interface{}{"k": int64(2)}) {
+			t.Errorf("map keys must not be prefixed: %#v", m["myapp_m"])
+		}
+
+		var out T
+
+		if err := (Decoder{Parser: NewValueParser(m), KeyPrefix: "myapp_"}).Decode(&out); err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(in, out) {
+			t.Errorf("%#v", out)
+		}
+	})
+}
+
+type failingScanner struct{}
+
+func (*failingScanner) Scan(interface{}) error { return errors.New("bad value") }
+
+func TestDecodeScanner(t *testing.T) {
+	type N struct {
+		F failingScanner `objconv:"f"`
+	}
+
+	type T struct {
+		S sql.NullString  `objconv:"s"`
+		I sql.NullInt64   `objconv:"i"`
+		F sql.NullFloat64 `objconv:"f"`
+		B sql.NullBool    `objconv:"b"`
+		Z sql.NullString  `objconv:"z"`
+		N N               `objconv:"n"`
+	}
+
+	var v T
+
+	if err := NewDecoder(NewValueParser(map[string]interface{}{
+		"s": "hello",
+		"i": uint64(42),
+		"f": 1.5,
+		"b": true,
+		"z": nil,
+	})).Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(v, T{
+		S: sql.NullString{String: "hello", Valid: true},
+		I: sql.NullInt64{Int64: 42, Valid: true},
+		F: sql.NullFloat64{Float64: 1.5, Valid: true},
+		B: sql.NullBool{Bool: true, Valid: true},
+	}) {
+		t.Errorf("%#v", v)
+	}
+
+	err := NewDecoder(NewValueParser(map[string]interface{}{"n": map[string]interface{}{"f": 1}})).Decode(&v)
+
+	if e, ok := err.(*ScanError); !ok || !reflect.DeepEqual(e.Path, []string{"n", "f"}) {
+		t.Errorf("bad error: %#v", err)
+	}
+
+	if err == nil || err.Error() != "objconv: n.f: cannot scan into objconv.failingScanner: bad value" {
+		t.Error("bad error message:", err)
+	}
+
+	if err := NewDecoder(NewValueParser([]int{1})).Decode(&v.S); err == nil {
+		t.Error("expected an error decoding an array into a scanner")
+	}
+}
+
+// textLevel implements encoding.TextMarshaler with a value receiver and
+// encoding.TextUnmarshaler with a pointer receiver.
+type textLevel int
+
+func (l textLevel) MarshalText() ([]byte, error) {
+	return []byte([]string{"low", "high"}[l]), nil
+}
+
+func (l *textLevel) UnmarshalText(b []byte) error {
+	switch string(b) {
+	case "low":
+		*l = 0
+	case "high":
+		*l = 1
+	default:
+		return fmt.Errorf("bad level: %q", b)
+	}
+	return nil
+}
+
+// textPointer implements both interfaces with pointer receivers.
+type textPointer struct{ s string }
+
+func (p *textPointer) MarshalText() ([]byte, error) { return []byte("<" + p.s + ">"), nil }
+
+func (p *textPointer) UnmarshalText(b []byte) error {
+	p.s = strings.Trim(string(b), "<>")
+	return nil
+}
+
+// textError also implements the error interface, the text methods take
+// precedence.
+type textError struct{ msg string }
+
+func (e textError) Error() string { return "error: " + e.msg }
+
+func (e textError) MarshalText() ([]byte, error) { return []byte(e.msg), nil }
+
+func (e *textError) UnmarshalText(b []byte) error {
+	e.msg = string(b)
+	return nil
+}
+
+func TestTextMarshaler(t *testing.T) {
+	type T struct {
+		Level    textLevel                 `objconv:"level"`
+		LevelPtr *textLevel                `objconv:"level_ptr"`
+		LevelNil *textLevel                `objconv:"level_nil"`
+		Levels   []textLevel               `objconv:"levels"`
+		Pointer  textPointer               `objconv:"pointer"`
+		Keys     map[textLevel]textPointer `objconv:"keys"`
+		Error    textError                 `objconv:"error"`
+	}
+
+	high := textLevel(1)
+
+	in := T{
+		Level:    1,
+		LevelPtr: &high,
+		Levels:   []textLevel{1, 0},
+		Pointer:  textPointer{"a"},
+		Keys:     map[textLevel]textPointer{1: {"b"}},
+		Error:    textError{"oops"},
+	}
+
+	e := NewValueEmitter()
+
+	if err := NewEncoder(e).Encode(in); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(e.Value(), map[interface{}]interface{}{
+		"level":     "high",
+		"level_ptr": "high",
+		"level_nil": nil,
+		"levels":    []interface{}{"high", "low"},
+		"pointer":   "<a>",
+		"keys":      map[interface{}]interface{}{"high": "<b>"},
+		"error":     "oops",
+	}) {
+		t.Errorf("bad encoding: %#v", e.Value())
+	}
+
+	// Values which aren't addressable are copied to call pointer receivers.
+	e = NewValueEmitter()
+
+	if err := NewEncoder(e).Encode(textPointer{"c"}); err != nil {
+		t.Fatal(err)
+	} else if e.Value() != "<c>" {
+		t.Errorf("bad encoding: %#v", e.Value())
+	}
+
+	var out T
+
+	if err := NewDecoder(NewValueParser(e.Value())).Decode(&out.Pointer); err != nil {
+		t.Fatal(err)
+	} else if out.Pointer.s != "c" {
+		t.Errorf("bad decoding: %#v", out.Pointer)
+	}
+
+	low := textLevel(0)
+	out = T{LevelNil: &low}
+
+	if err := NewDecoder(NewValueParser(map[interface{}]interface{}{
+		"level":     "high",
+		"level_ptr": "high",
+		"level_nil": nil,
+		"levels":    []interface{}{"high", "low"},
+		"pointer":   []byte("<a>"),
+		"keys":      map[interface{}]interface{}{"high": "<b>"},
+		"error":     "oops",
+	})).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("bad decoding: %#v", out)
+	}
+
+	if err := NewDecoder(NewValueParser(42)).Decode(&out.Level); err == nil {
+		t.Error("expected an error decoding a number into a text unmarshaler")
+	}
+
+	if err := NewDecoder(NewValueParser("medium")).Decode(&out.Level); err == nil || err.Error() != `bad level: "medium"` {
+		t.Error("bad error:", err)
+	}
+}
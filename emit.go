@@ -69,6 +69,15 @@ type PrettyEmitter interface {
 	PrettyEmitter() Emitter
 }
 
+// The numberEmitter interface may optionnaly be implemented by emitters which
+// can write numbers in the literal form of Number values, so they are encoded
+// without loss of precision.
+type numberEmitter interface {
+	// EmitNumber writes a number in its literal form, it returns an error if
+	// the string isn't a valid number in the format of the emitter.
+	EmitNumber(string) error
+}
+
 type discardEmitter struct{}
 
 func (e discardEmitter) EmitNil() error                     { return nil }
@@ -1,13 +1,23 @@
 package objconv
 
 import (
+	"database/sql/driver"
 	"encoding"
 	"fmt"
 	"io"
+	"iter"
 	"reflect"
+	"strconv"
 	"time"
 )
 
+// Layouts of Encoder.TimeLayout and Decoder.TimeLayouts representing time
+// values as numbers counting seconds or milliseconds since the Unix epoch.
+const (
+	TimeUnix      = "unix"
+	TimeUnixMilli = "unixmilli"
+)
+
 // An Encoder implements the high-level encoding algorithm that inspect encoded
 // values and drive the use of an Emitter to create a serialized representation
 // of the data.
@@ -16,7 +26,71 @@ import (
 type Encoder struct {
 	Emitter     Emitter // the emitter used by this encoder
 	SortMapKeys bool    // whether map keys should be sorted
-	key         bool
+	ErrorStacks bool    // whether stack traces of errors should be encoded
+
+	// ValueFunc is called with the path (map keys, field names, and array
+	// indexes) of each scalar value before it is encoded, and returns the
+	// value to encode in its place. When the function returns false the value
+	// is dropped, map entries and struct fields are omitted and array elements
+	// are removed. The values of struct fields with the `secret` tag option
+	// are wrapped in a Secret value, whatever their type.
+	//
+	// Scalars are all values which aren't arrays, slices, maps or structs, or
+	// which are encoded with an adapter or their own encoding methods. The
+	// values returned by the function are encoded as-is, ValueFunc isn't
+	// called on values nested in them.
+	//
+	// The path slice must not be retained by the function.
+	ValueFunc func(path []string, v interface{}) (interface{}, bool)
+
+	// KeepMonotonic disables stripping the monotonic clock reading of time
+	// values before they are encoded. By default the encoder behaves as if
+	// the values were rounded with Round(0), so equal wall clock times always
+	// produce the same output whatever the emitter or representation used.
+	KeepMonotonic bool
+
+	// DurationUnit, when set, makes the encoder emit durations as integers
+	// counting this unit (time.Millisecond, time.Second, ...) instead of
+	// letting the emitter choose their representation. Durations which are
+	// not a whole number of the unit are truncated toward zero, unless
+	// FractionalDurations is set, in which case they are emitted as floats.
+	DurationUnit        time.Duration
+	FractionalDurations bool
+
+	// TimeLayout, when set, makes the encoder emit time values in this layout
+	// instead of letting the emitter choose their representation. The value
+	// is either a layout of the time package, like time.RFC3339Nano, in which
+	// case times are emitted as formatted strings, or one of TimeUnix and
+	// TimeUnixMilli to emit them as integers counting seconds or milliseconds
+	// since the Unix epoch.
+	TimeLayout string
+
+	// KeyPrefix is prepended to the names of struct fields when they are
+	// encoded as map keys, it is the counterpart of Decoder.KeyPrefix. The
+	// keys of maps are not prefixed.
+	KeyPrefix string
+
+	// MapFilter is called with the key and value of each map entry before the
+	// map is encoded, entries for which the function returns false are
+	// omitted. The length of maps passed to the emitter is the number of
+	// entries that were kept. Struct fields are not passed to the function.
+	MapFilter func(key, value interface{}) bool
+
+	// Tag is the key of the struct tags that the encoder reads to configure
+	// the encoding of struct fields, "objconv" when empty. Setting it to
+	// "json" lets the encoder use the tags of types written for the standard
+	// encoding/json package, the omitempty and string options have the same
+	// meaning with both.
+	Tag string
+
+	// Adapters sets adapters used by this encoder for the types they are
+	// mapped to, in addition to the ones installed with Install, which they
+	// take precedence over. Adapters with a nil Encode function are ignored,
+	// so the same map can be shared with a decoder. The map must not be
+	// modified while values are being encoded.
+	Adapters map[reflect.Type]Adapter
+
+	key bool
 }
 
 // NewEncoder returns a new encoder that outputs values to e.
@@ -33,11 +107,26 @@ func NewEncoder(e Emitter) *Encoder {
 	return &Encoder{Emitter: e}
 }
 
+// Reset resets the encoder to emit values to emitter, the configuration of the
+// encoder is retained. It is meant to be used when encoders are kept in a
+// sync.Pool, in which case an encoder must only be used by one goroutine until
+// it is put back into the pool.
+func (e *Encoder) Reset(emitter Emitter) {
+	if emitter == nil {
+		panic("objconv: the emitter is nil")
+	}
+	e.Emitter = emitter
+	e.key = false
+}
+
 // Encode encodes the generic value v.
 func (e Encoder) Encode(v interface{}) (err error) {
 	if err = e.encodeMapValueMaybe(); err != nil {
 		return
 	}
+	if e.ValueFunc != nil {
+		return e.encodeWithValueFunc(reflect.ValueOf(v))
+	}
 	if v == nil {
 		return e.Emitter.EmitNil()
 	}
@@ -97,7 +186,7 @@ func (e Encoder) EncodeTime(v time.Time) (err error) {
 	if err = e.encodeMapValueMaybe(); err != nil {
 		return
 	}
-	return e.Emitter.EmitTime(v)
+	return e.emitTime(v)
 }
 
 // EncodeDuration uses e to encode the duration value v.
@@ -105,7 +194,7 @@ func (e Encoder) EncodeDuration(v time.Duration) (err error) {
 	if err = e.encodeMapValueMaybe(); err != nil {
 		return
 	}
-	return e.Emitter.EmitDuration(v)
+	return e.emitDuration(v)
 }
 
 // EncodeError uses e to encode the error value v.
@@ -113,7 +202,7 @@ func (e Encoder) EncodeError(v error) (err error) {
 	if err = e.encodeMapValueMaybe(); err != nil {
 		return
 	}
-	return e.Emitter.EmitError(v)
+	return e.emitError(v)
 }
 
 func (e *Encoder) encodeMapValueMaybe() (err error) {
@@ -124,7 +213,7 @@ func (e *Encoder) encodeMapValueMaybe() (err error) {
 }
 
 func (e Encoder) encode(v reflect.Value) error {
-	return encodeFuncOf(v.Type())(e, v)
+	return e.encodeFuncOf(v.Type())(e, v)
 }
 
 func (e Encoder) encodeBool(v reflect.Value) error {
@@ -212,19 +301,129 @@ func (e Encoder) encodeTime(v reflect.Value) error {
 		}
 	}
 
-	return e.Emitter.EmitTime(t)
+	return e.emitTime(t)
+}
+
+func (e Encoder) emitTime(t time.Time) error {
+	if !e.KeepMonotonic {
+		t = t.Round(0)
+	}
+	switch e.TimeLayout {
+	case "":
+		return e.Emitter.EmitTime(t)
+	case TimeUnix:
+		return e.Emitter.EmitInt(t.Unix(), 64)
+	case TimeUnixMilli:
+		return e.Emitter.EmitInt(t.Unix()*1000+int64(t.Nanosecond())/1e6, 64)
+	default:
+		return e.Emitter.EmitString(t.Format(e.TimeLayout))
+	}
+}
+
+// options returns an encoder with the same emitter and configuration as e,
+// except for ValueFunc which isn't propagated to the encoders of nested values
+// that aren't on the path of the value function.
+func (e Encoder) options() Encoder {
+	return Encoder{
+		Emitter:             e.Emitter,
+		SortMapKeys:         e.SortMapKeys,
+		ErrorStacks:         e.ErrorStacks,
+		KeepMonotonic:       e.KeepMonotonic,
+		DurationUnit:        e.DurationUnit,
+		FractionalDurations: e.FractionalDurations,
+		TimeLayout:          e.TimeLayout,
+		KeyPrefix:           e.KeyPrefix,
+		MapFilter:           e.MapFilter,
+		Tag:                 e.Tag,
+		Adapters:            e.Adapters,
+	}
+}
+
+func (e Encoder) withKey() Encoder {
+	e.key = true
+	return e
+}
+
+func (e Encoder) withEmitter(emitter Emitter) Encoder {
+	e.Emitter = emitter
+	return e
 }
 
 func (e Encoder) encodeDuration(v reflect.Value) error {
-	return e.Emitter.EmitDuration(time.Duration(v.Int()))
+	return e.emitDuration(time.Duration(v.Int()))
+}
+
+func (e Encoder) encodeNumber(v reflect.Value) error {
+	return e.emitNumber(Number(v.String()))
+}
+
+// emitNumber writes n in its literal form if the emitter supports it, or as
+// the integer or floating point value it represents otherwise. Integers that
+// don't fit in 64 bits are then written as floating point values.
+func (e Encoder) emitNumber(n Number) error {
+	if em, ok := e.Emitter.(numberEmitter); ok {
+		return em.EmitNumber(string(n))
+	}
+
+	if n.typ() == Int {
+		if v, err := n.Int64(); err == nil {
+			return e.Emitter.EmitInt(v, 64)
+		}
+		if v, err := n.Uint64(); err == nil {
+			return e.Emitter.EmitUint(v, 64)
+		}
+	}
+
+	v, err := n.Float64()
+	if err != nil {
+		return fmt.Errorf("objconv: invalid number %q", string(n))
+	}
+	return e.Emitter.EmitFloat(v, 64)
+}
+
+func (e Encoder) emitDuration(v time.Duration) error {
+	unit := e.DurationUnit
+
+	if unit <= 0 {
+		return e.Emitter.EmitDuration(v)
+	}
+
+	n, r := v/unit, v%unit
+
+	if r == 0 || !e.FractionalDurations {
+		return e.Emitter.EmitInt(int64(n), 64)
+	}
+
+	// The whole and fractional parts are converted separately so large
+	// durations don't lose the precision of their remainder.
+	return e.Emitter.EmitFloat(float64(n)+float64(r)/float64(unit), 64)
 }
 
 func (e Encoder) encodeError(v reflect.Value) error {
-	return e.Emitter.EmitError(v.Interface().(error))
+	return e.emitError(v.Interface().(error))
+}
+
+// emitError emits err, when ErrorStacks is set and err carries a stack trace
+// it is encoded as a map with a "message" and a "stack" field instead.
+func (e Encoder) emitError(err error) error {
+	if e.ErrorStacks {
+		if stack, ok := stackTraceOf(err); ok {
+			return e.encode(reflect.ValueOf(errorWithStack{
+				Message: err.Error(),
+				Stack:   stack,
+			}))
+		}
+	}
+	return e.Emitter.EmitError(err)
+}
+
+type errorWithStack struct {
+	Message string   `objconv:"message"`
+	Stack   []string `objconv:"stack"`
 }
 
 func (e Encoder) encodeArray(v reflect.Value) error {
-	return e.encodeArrayWith(v, encodeFuncOf(v.Type().Elem()))
+	return e.encodeArrayWith(v, e.encodeFuncOf(v.Type().Elem()))
 }
 
 func (e Encoder) encodeArrayWith(v reflect.Value, f encodeFunc) error {
@@ -236,17 +435,90 @@ func (e Encoder) encodeArrayWith(v reflect.Value, f encodeFunc) error {
 	})
 }
 
+func (e Encoder) encodeSeq(v reflect.Value) error {
+	return e.encodeSeqWith(v, e.encodeFuncOf(v.Type().In(0).In(0)))
+}
+
+// encodeSeqWith encodes a range-over-func iterator of values (iter.Seq) as an
+// array of unknown length, the elements are encoded as they are produced.
+// When encoding an element fails the iteration is stopped.
+func (e Encoder) encodeSeqWith(v reflect.Value, f encodeFunc) (err error) {
+	if v.IsNil() {
+		return e.Emitter.EmitNil()
+	}
+
+	if err = e.Emitter.EmitArrayBegin(-1); err != nil {
+		return
+	}
+
+	i := 0
+
+	for elem := range v.Seq() {
+		if i != 0 {
+			if err = e.Emitter.EmitArrayNext(); err != nil {
+				return
+			}
+		}
+		if err = f(e, elem); err != nil {
+			return
+		}
+		i++
+	}
+
+	return e.Emitter.EmitArrayEnd()
+}
+
+func (e Encoder) encodeSeq2(v reflect.Value) error {
+	yield := v.Type().In(0)
+	return e.encodeSeq2With(v, e.encodeFuncOf(yield.In(0)), e.encodeFuncOf(yield.In(1)))
+}
+
+// encodeSeq2With encodes a range-over-func iterator of key/value pairs
+// (iter.Seq2) as a map of unknown length, the entries are encoded as they are
+// produced. When encoding an entry fails the iteration is stopped.
+func (e Encoder) encodeSeq2With(v reflect.Value, kf encodeFunc, vf encodeFunc) (err error) {
+	if v.IsNil() {
+		return e.Emitter.EmitNil()
+	}
+
+	if err = e.Emitter.EmitMapBegin(-1); err != nil {
+		return
+	}
+
+	i := 0
+
+	for key, value := range v.Seq2() {
+		if i != 0 {
+			if err = e.Emitter.EmitMapNext(); err != nil {
+				return
+			}
+		}
+		if err = kf(e, key); err != nil {
+			return
+		}
+		if err = e.Emitter.EmitMapValue(); err != nil {
+			return
+		}
+		if err = vf(e, value); err != nil {
+			return
+		}
+		i++
+	}
+
+	return e.Emitter.EmitMapEnd()
+}
+
 func (e Encoder) encodeMap(v reflect.Value) error {
 	t := v.Type()
-	kf := encodeFuncOf(t.Key())
-	vf := encodeFuncOf(t.Elem())
+	kf := e.encodeFuncOf(t.Key())
+	vf := e.encodeFuncOf(t.Elem())
 	return e.encodeMapWith(v, kf, vf)
 }
 
 func (e Encoder) encodeMapWith(v reflect.Value, kf encodeFunc, vf encodeFunc) error {
 	t := v.Type()
 
-	if !e.SortMapKeys {
+	if !e.SortMapKeys && e.MapFilter == nil && len(e.Adapters) == 0 {
 		switch {
 		case t.ConvertibleTo(mapInterfaceInterfaceType):
 			return e.encodeMapInterfaceInterface(v.Convert(mapInterfaceInterfaceType))
@@ -264,7 +536,8 @@ func (e Encoder) encodeMapWith(v reflect.Value, kf encodeFunc, vf encodeFunc) er
 	var i = 0
 
 	if n != 0 {
-		k = v.MapKeys()
+		k = e.filterMapKeys(v, v.MapKeys())
+		n = len(k)
 
 		if e.SortMapKeys {
 			sortValues(t.Key(), k)
@@ -286,6 +559,24 @@ func (e Encoder) encodeMapWith(v reflect.Value, kf encodeFunc, vf encodeFunc) er
 	})
 }
 
+// filterMapKeys returns the keys of the entries of m that MapFilter keeps, the
+// keys slice is modified in place. All keys are returned when MapFilter is nil.
+func (e Encoder) filterMapKeys(m reflect.Value, keys []reflect.Value) []reflect.Value {
+	if e.MapFilter == nil {
+		return keys
+	}
+
+	kept := keys[:0]
+
+	for _, k := range keys {
+		if e.MapFilter(k.Interface(), m.MapIndex(k).Interface()) {
+			kept = append(kept, k)
+		}
+	}
+
+	return kept
+}
+
 func (e Encoder) encodeMapInterfaceInterface(v reflect.Value) (err error) {
 	m := v.Interface().(map[interface{}]interface{})
 	n := len(m)
@@ -377,19 +668,36 @@ func (e Encoder) encodeMapStringString(v reflect.Value) (err error) {
 }
 
 func (e Encoder) encodeStruct(v reflect.Value) error {
-	return e.encodeStructWith(v, structCache.lookup(v.Type()))
+	return e.encodeStructWith(v, structCache.lookup(v.Type(), e.Tag, len(e.Adapters) != 0))
 }
 
 func (e Encoder) encodeStructWith(v reflect.Value, s *structType) (err error) {
+	var mv []reflect.Value
 	n := 0
 
 	for i := range s.fields {
 		f := &s.fields[i]
-		if !f.omit(v.FieldByIndex(f.index)) {
+		if fv, ok := fieldByIndex(v, f.index); ok && !f.omit(fv) {
 			n++
 		}
 	}
 
+	if len(s.methods) != 0 {
+		// The methods are called before starting to encode the struct so
+		// their values can be omitted and counted like the other fields.
+		mv = make([]reflect.Value, len(s.methods))
+
+		for i := range s.methods {
+			m := &s.methods[i]
+			if mv[i], err = m.call(v); err != nil {
+				return
+			}
+			if !m.omit(mv[i]) {
+				n++
+			}
+		}
+	}
+
 	if err = e.Emitter.EmitMapBegin(n); err != nil {
 		return
 	}
@@ -397,13 +705,13 @@ func (e Encoder) encodeStructWith(v reflect.Value, s *structType) (err error) {
 
 	for i := range s.fields {
 		f := &s.fields[i]
-		if fv := v.FieldByIndex(f.index); !f.omit(fv) {
+		if fv, ok := fieldByIndex(v, f.index); ok && !f.omit(fv) {
 			if n != 0 {
 				if err = e.Emitter.EmitMapNext(); err != nil {
 					return
 				}
 			}
-			if err = e.Emitter.EmitString(f.name); err != nil {
+			if err = e.emitFieldName(f.name); err != nil {
 				return
 			}
 			if err = e.Emitter.EmitMapValue(); err != nil {
@@ -416,11 +724,41 @@ func (e Encoder) encodeStructWith(v reflect.Value, s *structType) (err error) {
 		}
 	}
 
+	for i := range s.methods {
+		m := &s.methods[i]
+		if !m.omit(mv[i]) {
+			if n != 0 {
+				if err = e.Emitter.EmitMapNext(); err != nil {
+					return
+				}
+			}
+			if err = e.emitFieldName(m.name); err != nil {
+				return
+			}
+			if err = e.Emitter.EmitMapValue(); err != nil {
+				return
+			}
+			if err = m.encode(e, mv[i]); err != nil {
+				return
+			}
+			n++
+		}
+	}
+
 	return e.Emitter.EmitMapEnd()
 }
 
+// emitFieldName emits the name of a struct field as a map key, prefixed with
+// KeyPrefix.
+func (e Encoder) emitFieldName(name string) error {
+	if len(e.KeyPrefix) != 0 {
+		name = e.KeyPrefix + name
+	}
+	return e.Emitter.EmitString(name)
+}
+
 func (e Encoder) encodePointer(v reflect.Value) error {
-	return e.encodePointerWith(v, encodeFuncOf(v.Type().Elem()))
+	return e.encodePointerWith(v, e.encodeFuncOf(v.Type().Elem()))
 }
 
 func (e Encoder) encodePointerWith(v reflect.Value, f encodeFunc) error {
@@ -441,7 +779,13 @@ func (e Encoder) encodeEncoder(v reflect.Value) error {
 	return v.Interface().(ValueEncoder).EncodeValue(e)
 }
 
+// encodeTextMarshaler encodes the text returned by the MarshalText method of
+// a type which implements encoding.TextMarshaler as a string, nil pointers are
+// encoded as nil values.
 func (e Encoder) encodeTextMarshaler(v reflect.Value) error {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return e.Emitter.EmitNil()
+	}
 	b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
 	if err == nil {
 		err = e.Emitter.EmitString(stringNoCopy(b))
@@ -449,10 +793,289 @@ func (e Encoder) encodeTextMarshaler(v reflect.Value) error {
 	return err
 }
 
+// encodeTextMarshalerPointer encodes values of types which implement
+// encoding.TextMarshaler with a pointer receiver, values that aren't
+// addressable are copied so the method can be called.
+func (e Encoder) encodeTextMarshalerPointer(v reflect.Value) error {
+	if !v.CanAddr() {
+		p := reflect.New(v.Type()).Elem()
+		p.Set(v)
+		v = p
+	}
+	return e.encodeTextMarshaler(v.Addr())
+}
+
+// encodeValuer encodes the value returned by the Value method of a type which
+// implements driver.Valuer, nil pointers are encoded as nil values.
+func (e Encoder) encodeValuer(v reflect.Value) error {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return e.Emitter.EmitNil()
+	}
+	x, err := v.Interface().(driver.Valuer).Value()
+	if err != nil {
+		return fmt.Errorf("objconv: cannot get the value of %s: %s", v.Type(), err)
+	}
+	if x == nil {
+		return e.Emitter.EmitNil()
+	}
+	return e.encode(reflect.ValueOf(x))
+}
+
 func (e Encoder) encodeUnsupported(v reflect.Value) error {
 	return fmt.Errorf("objconv: the encoder doesn't support values of type %s", v.Type())
 }
 
+// Secret wraps the values of struct fields with the `secret` tag option when
+// they are passed to Encoder.ValueFunc. When the function returns a Secret the
+// wrapped value is encoded.
+type Secret struct {
+	Value interface{}
+}
+
+// filteredValue is a value that went through Encoder.ValueFunc if it was a
+// scalar, or a container of values which are passed to the function.
+type filteredValue struct {
+	scalar bool
+	x      interface{}   // the value returned by ValueFunc
+	v      reflect.Value // the container
+	encode encodeFunc    // set for struct fields which must be encoded as-is
+}
+
+// filteredEntry is an element of a container encoded with ValueFunc.
+type filteredEntry struct {
+	elem  string        // path element
+	key   reflect.Value // the map key
+	value filteredValue
+}
+
+func (e Encoder) encodeWithValueFunc(v reflect.Value) error {
+	f, keep := e.filterValue(nil, v, false)
+
+	if !keep {
+		f = filteredValue{scalar: true}
+	}
+
+	return e.encodeFiltered(nil, f)
+}
+
+// filterValue calls ValueFunc if v is a scalar or the value of a secret field,
+// it returns the value to encode and false if it must be dropped.
+func (e Encoder) filterValue(path []string, v reflect.Value, secret bool) (f filteredValue, keep bool) {
+	if !secret && !isScalarValue(v) {
+		return filteredValue{v: v}, true
+	}
+
+	var x interface{}
+
+	if v.IsValid() && v.CanInterface() {
+		x = v.Interface()
+	}
+
+	if secret {
+		x = Secret{Value: x}
+	}
+
+	if x, keep = e.ValueFunc(path, x); keep {
+		if s, ok := x.(Secret); ok {
+			x = s.Value
+		}
+	}
+
+	return filteredValue{scalar: true, x: x}, keep
+}
+
+func (e Encoder) encodeFiltered(path []string, f filteredValue) error {
+	if f.scalar || f.encode != nil {
+		e.ValueFunc = nil
+
+		if f.encode != nil {
+			return f.encode(e, f.v)
+		}
+
+		return e.Encode(f.x)
+	}
+
+	v := f.v
+
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		v = v.Elem() // nil values are scalars
+	}
+
+	var entries []filteredEntry
+
+	switch v.Kind() {
+	case reflect.Struct:
+		s := structCache.lookup(v.Type(), e.Tag, len(e.Adapters) != 0)
+		entries = make([]filteredEntry, 0, len(s.fields))
+
+		for i := range s.fields {
+			sf := &s.fields[i]
+			fv, ok := fieldByIndex(v, sf.index)
+
+			if !ok || sf.omit(fv) {
+				continue
+			}
+
+			if sf.json && !sf.secret {
+				// Fields encoded as JSON strings are not inspected.
+				entries = append(entries, filteredEntry{elem: sf.name, value: filteredValue{v: fv, encode: sf.encode}})
+			} else if f, keep := e.filterValue(appendPath(path, sf.name), fv, sf.secret); keep {
+				// Values of the same type as the field keep the encoding
+				// configured by its tag (string, base64, format...).
+				if f.scalar && f.x != nil && reflect.TypeOf(f.x) == fv.Type() {
+					f = filteredValue{v: reflect.ValueOf(f.x), encode: sf.encode}
+				}
+				entries = append(entries, filteredEntry{elem: sf.name, value: f})
+			}
+		}
+
+		for i := range s.methods {
+			m := &s.methods[i]
+			mv, err := m.call(v)
+
+			if err != nil {
+				return err
+			}
+
+			if m.omit(mv) {
+				continue
+			}
+
+			if fv, keep := e.filterValue(appendPath(path, m.name), mv, false); keep {
+				entries = append(entries, filteredEntry{elem: m.name, value: fv})
+			}
+		}
+
+		return e.encodeFilteredMap(path, entries, false)
+
+	case reflect.Map:
+		keys := e.filterMapKeys(v, v.MapKeys())
+		entries = make([]filteredEntry, 0, len(keys))
+
+		if e.SortMapKeys {
+			sortValues(v.Type().Key(), keys)
+		}
+
+		for _, k := range keys {
+			var elem string
+
+			if k.Kind() == reflect.String {
+				elem = k.String()
+			} else {
+				elem = fmt.Sprint(k.Interface())
+			}
+
+			if fv, keep := e.filterValue(appendPath(path, elem), v.MapIndex(k), false); keep {
+				entries = append(entries, filteredEntry{elem: elem, key: k, value: fv})
+			}
+		}
+
+		return e.encodeFilteredMap(path, entries, true)
+
+	default: // array or slice
+		n := v.Len()
+		entries = make([]filteredEntry, 0, n)
+
+		for i := 0; i != n; i++ {
+			elem := strconv.Itoa(i)
+
+			if fv, keep := e.filterValue(appendPath(path, elem), v.Index(i), false); keep {
+				entries = append(entries, filteredEntry{elem: elem, value: fv})
+			}
+		}
+
+		i := 0
+		return e.EncodeArray(len(entries), func(e Encoder) (err error) {
+			err = e.encodeFiltered(appendPath(path, entries[i].elem), entries[i].value)
+			i++
+			return
+		})
+	}
+}
+
+func (e Encoder) encodeFilteredMap(path []string, entries []filteredEntry, keys bool) (err error) {
+	if err = e.Emitter.EmitMapBegin(len(entries)); err != nil {
+		return
+	}
+
+	for i, entry := range entries {
+		if i != 0 {
+			if err = e.Emitter.EmitMapNext(); err != nil {
+				return
+			}
+		}
+
+		if keys {
+			err = e.encodeFiltered(nil, filteredValue{scalar: true, x: entry.key.Interface()})
+		} else {
+			err = e.emitFieldName(entry.elem)
+		}
+
+		if err != nil {
+			return
+		}
+
+		if err = e.Emitter.EmitMapValue(); err != nil {
+			return
+		}
+
+		if err = e.encodeFiltered(appendPath(path, entry.elem), entry.value); err != nil {
+			return
+		}
+	}
+
+	return e.Emitter.EmitMapEnd()
+}
+
+// appendPath returns a copy of path with elem appended.
+func appendPath(path []string, elem string) []string {
+	return append(path[:len(path):len(path)], elem)
+}
+
+// isScalarValue returns true if v is a scalar value in the sense of
+// Encoder.ValueFunc.
+func isScalarValue(v reflect.Value) bool {
+	for v.IsValid() {
+		t := v.Type()
+
+		if _, ok := AdapterOf(t); ok {
+			return true
+		}
+
+		switch t {
+		case bytesType, timeType, timePtrType:
+			return true
+		}
+
+		if t.Implements(valueEncoderInterface) || t.Implements(textMarshalerInterface) || t.Implements(valuerInterface) || t.Implements(errorInterface) {
+			return true
+		}
+
+		if reflect.PtrTo(t).Implements(textMarshalerInterface) {
+			return true
+		}
+
+		switch t.Kind() {
+		case reflect.Struct, reflect.Map, reflect.Array:
+			return false
+
+		case reflect.Slice:
+			return t.Elem().Kind() == reflect.Uint8
+
+		case reflect.Ptr, reflect.Interface:
+			if v.IsNil() {
+				return true
+			}
+			v = v.Elem()
+
+		default:
+			return true
+		}
+	}
+
+	return true
+}
+
 // EncodeArray provides the implementation of the array encoding algorithm,
 // where n is the number of elements in the array, and f a function called to
 // encode each element.
@@ -523,8 +1146,8 @@ encodeMap:
 		}
 		e.key = true
 		err = f(
-			Encoder{Emitter: e.Emitter, SortMapKeys: e.SortMapKeys},
-			Encoder{Emitter: e.Emitter, SortMapKeys: e.SortMapKeys, key: true},
+			e.options(),
+			e.options().withKey(),
 		)
 		// Because internal calls don't use the exported methods they may not
 		// reset this flag to false when expected, forcing the value here.
@@ -542,12 +1165,91 @@ encodeMap:
 	return e.Emitter.EmitMapEnd()
 }
 
+// EncodeSeq encodes the values produced by seq as an array of n elements, the
+// values are encoded as they are produced so the sequence doesn't have to be
+// materialized.
+//
+// The n argument can be set to a negative value to indicate that the length of
+// the sequence is unknown, like with EncodeArray. When n is positive or zero
+// an error is returned if seq doesn't produce exactly n values, since the
+// length may already have been written to the output. A nil sequence is
+// treated as an empty one.
+func EncodeSeq[T any](e *Encoder, n int, seq iter.Seq[T]) (err error) {
+	enc := *e
+
+	if enc.key {
+		if enc.key, err = false, enc.Emitter.EmitMapValue(); err != nil {
+			return
+		}
+	}
+
+	if err = enc.Emitter.EmitArrayBegin(n); err != nil {
+		return
+	}
+
+	i := 0
+
+	if seq != nil {
+		for v := range seq {
+			if i == n {
+				return fmt.Errorf("objconv: the sequence produced more than the %d values it was declared to have", n)
+			}
+			if i != 0 {
+				if err = enc.Emitter.EmitArrayNext(); err != nil {
+					return
+				}
+			}
+			if err = enc.Encode(v); err != nil {
+				return
+			}
+			i++
+		}
+	}
+
+	if n >= 0 && i != n {
+		return fmt.Errorf("objconv: the sequence produced %d values but was declared to have %d", i, n)
+	}
+
+	return enc.Emitter.EmitArrayEnd()
+}
+
 // A StreamEncoder encodes and writes a stream of values to an output stream.
 //
 // Instances of StreamEncoder are not safe for use by multiple goroutines.
 type StreamEncoder struct {
 	Emitter     Emitter // the emiiter used by this encoder
 	SortMapKeys bool    // whether map keys should be sorted
+	ErrorStacks bool    // whether stack traces of errors should be encoded
+
+	// ValueFunc is called to transform scalar values before they are encoded,
+	// see Encoder.ValueFunc.
+	ValueFunc func(path []string, v interface{}) (interface{}, bool)
+
+	// KeepMonotonic disables stripping the monotonic clock reading of time
+	// values, see Encoder.KeepMonotonic.
+	KeepMonotonic bool
+
+	// DurationUnit and FractionalDurations configure the encoding of
+	// durations as numbers, see Encoder.DurationUnit.
+	DurationUnit        time.Duration
+	FractionalDurations bool
+
+	// TimeLayout configures the representation of time values, see
+	// Encoder.TimeLayout.
+	TimeLayout string
+
+	// KeyPrefix is prepended to the names of struct fields, see
+	// Encoder.KeyPrefix.
+	KeyPrefix string
+
+	// MapFilter is called to omit map entries, see Encoder.MapFilter.
+	MapFilter func(key, value interface{}) bool
+
+	// Tag is the key of the struct tags read by the encoder, see Encoder.Tag.
+	Tag string
+
+	// Adapters sets the adapters used by the encoder, see Encoder.Adapters.
+	Adapters map[reflect.Type]Adapter
 
 	err     error
 	max     int
@@ -628,8 +1330,18 @@ func (e *StreamEncoder) Encode(v interface{}) error {
 
 	if e.err == nil {
 		e.err = (Encoder{
-			Emitter:     e.Emitter,
-			SortMapKeys: e.SortMapKeys,
+			Emitter:             e.Emitter,
+			SortMapKeys:         e.SortMapKeys,
+			ErrorStacks:         e.ErrorStacks,
+			ValueFunc:           e.ValueFunc,
+			KeepMonotonic:       e.KeepMonotonic,
+			DurationUnit:        e.DurationUnit,
+			FractionalDurations: e.FractionalDurations,
+			TimeLayout:          e.TimeLayout,
+			KeyPrefix:           e.KeyPrefix,
+			MapFilter:           e.MapFilter,
+			Tag:                 e.Tag,
+			Adapters:            e.Adapters,
 		}).Encode(v)
 
 		if e.cnt++; e.max >= 0 && e.cnt >= e.max {
@@ -658,8 +1370,10 @@ func (f ValueEncoderFunc) EncodeValue(e Encoder) error { return f(e) }
 
 // encodeFuncOpts is used to configure how the encodeFuncOf behaves.
 type encodeFuncOpts struct {
-	recurse bool
-	structs map[reflect.Type]*structType
+	recurse  bool
+	adapters bool // check Encoder.Adapters, only set when it isn't empty
+	structs  map[reflect.Type]*structType
+	tag      string
 }
 
 // encodeFunc is the prototype of functions that encode values.
@@ -670,7 +1384,35 @@ func encodeFuncOf(t reflect.Type) encodeFunc {
 	return makeEncodeFunc(t, encodeFuncOpts{})
 }
 
+// encodeFuncOf returns an encoder function for t which checks the adapters set
+// on e, the functions returned when e has no adapters don't pay for it.
+func (e Encoder) encodeFuncOf(t reflect.Type) encodeFunc {
+	if len(e.Adapters) == 0 {
+		return encodeFuncOf(t)
+	}
+	return makeEncodeFunc(t, encodeFuncOpts{adapters: true})
+}
+
 func makeEncodeFunc(t reflect.Type, opts encodeFuncOpts) encodeFunc {
+	f := makeTypeEncodeFunc(t, opts)
+	if opts.adapters {
+		f = encodeWithAdapters(t, f)
+	}
+	return f
+}
+
+// encodeWithAdapters returns an encoder function which uses the adapter set
+// for t on the encoder if there is one, or f otherwise.
+func encodeWithAdapters(t reflect.Type, f encodeFunc) encodeFunc {
+	return func(e Encoder, v reflect.Value) error {
+		if a, ok := e.Adapters[t]; ok && a.Encode != nil {
+			return a.Encode(e, v)
+		}
+		return f(e, v)
+	}
+}
+
+func makeTypeEncodeFunc(t reflect.Type, opts encodeFuncOpts) encodeFunc {
 	if adapter, ok := AdapterOf(t); ok {
 		return adapter.Encode
 	}
@@ -691,6 +1433,9 @@ func makeEncodeFunc(t reflect.Type, opts encodeFuncOpts) encodeFunc {
 	case durationType:
 		return Encoder.encodeDuration
 
+	case numberType:
+		return Encoder.encodeNumber
+
 	case emptyInterface:
 		return Encoder.encodeInterface
 
@@ -741,6 +1486,12 @@ func makeEncodeFunc(t reflect.Type, opts encodeFuncOpts) encodeFunc {
 	case t.Implements(textMarshalerInterface):
 		return Encoder.encodeTextMarshaler
 
+	case reflect.PtrTo(t).Implements(textMarshalerInterface):
+		return Encoder.encodeTextMarshalerPointer
+
+	case t.Implements(valuerInterface):
+		return Encoder.encodeValuer
+
 	case t.Implements(errorInterface):
 		return Encoder.encodeError
 	}
@@ -764,6 +1515,15 @@ func makeEncodeFunc(t reflect.Type, opts encodeFuncOpts) encodeFunc {
 	case reflect.Array:
 		return makeEncodeArrayFunc(t, opts)
 
+	case reflect.Func:
+		switch {
+		case t.CanSeq():
+			return makeEncodeSeqFunc(t, opts)
+		case t.CanSeq2():
+			return makeEncodeSeq2Func(t, opts)
+		}
+		return Encoder.encodeUnsupported
+
 	case reflect.String:
 		return Encoder.encodeString
 
@@ -824,6 +1584,27 @@ func makeEncodeArrayFunc(t reflect.Type, opts encodeFuncOpts) encodeFunc {
 	}
 }
 
+func makeEncodeSeqFunc(t reflect.Type, opts encodeFuncOpts) encodeFunc {
+	if !opts.recurse {
+		return Encoder.encodeSeq
+	}
+	f := makeEncodeFunc(t.In(0).In(0), opts)
+	return func(e Encoder, v reflect.Value) error {
+		return e.encodeSeqWith(v, f)
+	}
+}
+
+func makeEncodeSeq2Func(t reflect.Type, opts encodeFuncOpts) encodeFunc {
+	if !opts.recurse {
+		return Encoder.encodeSeq2
+	}
+	kf := makeEncodeFunc(t.In(0).In(0), opts)
+	vf := makeEncodeFunc(t.In(0).In(1), opts)
+	return func(e Encoder, v reflect.Value) error {
+		return e.encodeSeq2With(v, kf, vf)
+	}
+}
+
 func makeEncodeMapFunc(t reflect.Type, opts encodeFuncOpts) encodeFunc {
 	if !opts.recurse {
 		return Encoder.encodeMap
@@ -839,7 +1620,7 @@ func makeEncodeStructFunc(t reflect.Type, opts encodeFuncOpts) encodeFunc {
 	if !opts.recurse {
 		return Encoder.encodeStruct
 	}
-	s := newStructType(t, opts.structs)
+	s := newStructType(t, opts.tag, opts.adapters, opts.structs)
 	return func(e Encoder, v reflect.Value) error {
 		return e.encodeStructWith(v, s)
 	}
@@ -1,9 +1,14 @@
 package objconv
 
 import (
+	"database/sql"
+	"database/sql/driver"
 	"errors"
 	"fmt"
+	"iter"
 	"reflect"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 )
@@ -84,7 +89,7 @@ func TestEncoder(t *testing.T) {
 		{TBytes("123"), []byte("123")},
 
 		// time
-		{now, now},
+		{now, now.Round(0)},
 
 		// duration
 		{time.Second, time.Second},
@@ -317,3 +322,745 @@ func TestStreamEncoderVar(t *testing.T) {
 		t.Error(x1, "!=", x2)
 	}
 }
+
+type stackTracerError struct{ stack []string }
+
+func (e stackTracerError) Error() string        { return "oops" }
+func (e stackTracerError) StackTrace() []string { return e.stack }
+
+// frame mimics the stack frames of github.com/pkg/errors.
+type frame string
+
+func (f frame) Format(s fmt.State, verb rune) { fmt.Fprintf(s, "%s\n\tfile.go:42", string(f)) }
+
+type frameStackError struct{}
+
+func (e *frameStackError) Error() string       { return "oops" }
+func (e *frameStackError) StackTrace() []frame { return []frame{"main.A", "main.B"} }
+
+func TestEncoderErrorStacks(t *testing.T) {
+	tests := []struct {
+		err    error
+		stacks bool
+		out    interface{}
+	}{
+		{
+			err:    stackTracerError{[]string{"main.A", "main.B"}},
+			stacks: false,
+			out:    errors.New("oops"),
+		},
+		{
+			err:    stackTracerError{[]string{"main.A", "main.B"}},
+			stacks: true,
+			out: map[interface{}]interface{}{
+				"message": "oops",
+				"stack":   []interface{}{"main.A", "main.B"},
+			},
+		},
+		{
+			err:    &frameStackError{},
+			stacks: true,
+			out: map[interface{}]interface{}{
+				"message": "oops",
+				"stack":   []interface{}{"main.A\n\tfile.go:42", "main.B\n\tfile.go:42"},
+			},
+		},
+		{
+			err:    errors.New("oops"),
+			stacks: true,
+			out:    errors.New("oops"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%T:%t", test.err, test.stacks), func(t *testing.T) {
+			e := NewValueEmitter()
+
+			if err := (Encoder{Emitter: e, ErrorStacks: test.stacks}).Encode(test.err); err != nil {
+				t.Fatal(err)
+			}
+
+			v := e.Value()
+
+			if err, ok := test.out.(error); ok {
+				if _, isMap := v.(map[interface{}]interface{}); isMap || v.(error).Error() != err.Error() {
+					t.Errorf("%#v", v)
+				}
+			} else if !reflect.DeepEqual(v, test.out) {
+				t.Errorf("%#v", v)
+			}
+		})
+	}
+}
+
+func TestEncoderMonotonicClock(t *testing.T) {
+	now := time.Now() // carries a monotonic clock reading
+
+	tests := []struct {
+		name string
+		v    interface{}
+		get  func(interface{}) time.Time
+	}{
+		{
+			name: "value",
+			v:    now,
+			get:  func(v interface{}) time.Time { return v.(time.Time) },
+		},
+		{
+			name: "pointer",
+			v:    &now,
+			get:  func(v interface{}) time.Time { return v.(time.Time) },
+		},
+		{
+			name: "nested",
+			v:    map[string][]time.Time{"t": {now}},
+			get: func(v interface{}) time.Time {
+				return v.(map[interface{}]interface{})["t"].([]interface{})[0].(time.Time)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, keep := range []bool{false, true} {
+				e := NewValueEmitter()
+
+				if err := (Encoder{Emitter: e, KeepMonotonic: keep}).Encode(test.v); err != nil {
+					t.Fatal(err)
+				}
+
+				v := test.get(e.Value())
+
+				if hasMonotonic := v != v.Round(0); hasMonotonic != keep {
+					t.Errorf("keep=%t: bad monotonic clock reading: %s", keep, v)
+				}
+
+				if !v.Equal(now) {
+					t.Errorf("keep=%t: %s != %s", keep, v, now)
+				}
+			}
+		})
+	}
+}
+
+func TestEncoderDurationUnit(t *testing.T) {
+	tests := []struct {
+		v          time.Duration
+		unit       time.Duration
+		fractional bool
+		out        interface{}
+	}{
+		{1500 * time.Millisecond, 0, false, 1500 * time.Millisecond},
+		{1500 * time.Millisecond, time.Millisecond, false, int64(1500)},
+		{1500 * time.Millisecond, time.Second, false, int64(1)},
+		{1500 * time.Millisecond, time.Second, true, 1.5},
+		{-1500 * time.Millisecond, time.Second, true, -1.5},
+		{2 * time.Second, time.Second, true, int64(2)},
+		{time.Microsecond, time.Nanosecond, false, int64(1000)},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s/%s/%t", test.v, test.unit, test.fractional), func(t *testing.T) {
+			e := NewValueEmitter()
+
+			if err := (Encoder{Emitter: e, DurationUnit: test.unit, FractionalDurations: test.fractional}).Encode(test.v); err != nil {
+				t.Fatal(err)
+			}
+
+			if v := e.Value(); !reflect.DeepEqual(v, test.out) {
+				t.Errorf("%#v != %#v", v, test.out)
+			}
+		})
+	}
+
+	t.Run("nested", func(t *testing.T) {
+		e := NewValueEmitter()
+		v := map[string]time.Duration{"timeout": 3 * time.Second}
+
+		if err := (Encoder{Emitter: e, DurationUnit: time.Second}).Encode(v); err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(e.Value(), map[interface{}]interface{}{"timeout": int64(3)}) {
+			t.Errorf("%#v", e.Value())
+		}
+	})
+}
+
+func TestEncoderTimeLayout(t *testing.T) {
+	date := time.Date(2021, 1, 1, 0, 0, 0, 123456789, time.UTC)
+
+	tests := []struct {
+		v      time.Time
+		layout string
+		out    interface{}
+	}{
+		{date, "", date},
+		{date, TimeUnix, int64(1609459200)},
+		{date, TimeUnixMilli, int64(1609459200123)},
+		{date.Add(-2 * time.Second), TimeUnixMilli, int64(1609459198123)},
+		{time.Unix(-1, 500e6), TimeUnixMilli, int64(-500)},
+		{date, time.RFC3339, "2021-01-01T00:00:00Z"},
+		{date, "2006-01-02", "2021-01-01"},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s/%s", test.v, test.layout), func(t *testing.T) {
+			e := NewValueEmitter()
+
+			if err := (Encoder{Emitter: e, TimeLayout: test.layout}).Encode(test.v); err != nil {
+				t.Fatal(err)
+			}
+
+			if v := e.Value(); !reflect.DeepEqual(v, test.out) {
+				t.Errorf("%#v != %#v", v, test.out)
+			}
+		})
+	}
+
+	t.Run("nested", func(t *testing.T) {
+		e := NewValueEmitter()
+		v := struct{ CreatedAt time.Time }{date}
+
+		if err := (Encoder{Emitter: e, TimeLayout: TimeUnixMilli}).Encode(v); err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(e.Value(), map[interface{}]interface{}{"CreatedAt": int64(1609459200123)}) {
+			t.Errorf("%#v", e.Value())
+		}
+	})
+}
+
+func TestEncoderValueFunc(t *testing.T) {
+	type Credentials struct {
+		User     string `objconv:"user"`
+		Password string `objconv:"password,secret"`
+	}
+
+	type Config struct {
+		Name   string            `objconv:"name"`
+		Creds  Credentials       `objconv:"creds"`
+		Tokens []string          `objconv:"tokens"`
+		Labels map[string]string `objconv:"labels"`
+		Debug  *bool             `objconv:"debug"`
+	}
+
+	debug := true
+	config := Config{
+		Name:   "test",
+		Creds:  Credentials{User: "admin", Password: "hunter2"},
+		Tokens: []string{"a", "drop", "b"},
+		Labels: map[string]string{"env": "prod", "key": "abc"},
+		Debug:  &debug,
+	}
+
+	var paths []string
+
+	valueFunc := func(path []string, v interface{}) (interface{}, bool) {
+		paths = append(paths, strings.Join(path, "."))
+
+		switch x := v.(type) {
+		case Secret:
+			return "***", true
+		case string:
+			if x == "drop" {
+				return nil, false
+			}
+			if len(path) == 2 && path[0] == "labels" && path[1] == "key" {
+				return "redacted", true
+			}
+		}
+
+		return v, true
+	}
+
+	e := NewValueEmitter()
+
+	if err := (Encoder{Emitter: e, SortMapKeys: true, ValueFunc: valueFunc}).Encode(config); err != nil {
+		t.Fatal(err)
+	}
+
+	out := map[interface{}]interface{}{
+		"name": "test",
+		"creds": map[interface{}]interface{}{
+			"user":     "admin",
+			"password": "***",
+		},
+		"tokens": []interface{}{"a", "b"},
+		"labels": map[interface{}]interface{}{
+			"env": "prod",
+			"key": "redacted",
+		},
+		"debug": true,
+	}
+
+	if v := e.Value(); !reflect.DeepEqual(v, out) {
+		t.Errorf("%#v != %#v", v, out)
+	}
+
+	sort.Strings(paths)
+
+	if !reflect.DeepEqual(paths, []string{
+		"creds.password",
+		"creds.user",
+		"debug",
+		"labels.env",
+		"labels.key",
+		"name",
+		"tokens.0",
+		"tokens.1",
+		"tokens.2",
+	}) {
+		t.Errorf("bad paths: %q", paths)
+	}
+}
+
+func TestEncoderValueFuncFieldEncoding(t *testing.T) {
+	type T struct {
+		Count int    `objconv:"count,string"`
+		Data  []byte `objconv:"data,base64"`
+		Name  string `objconv:"name"`
+	}
+
+	v := T{Count: 42, Data: []byte("hello"), Name: "A"}
+
+	identity := func(path []string, v interface{}) (interface{}, bool) { return v, true }
+
+	e1 := NewValueEmitter()
+	e2 := NewValueEmitter()
+
+	if err := NewEncoder(e1).Encode(v); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := (Encoder{Emitter: e2, ValueFunc: identity}).Encode(v); err != nil {
+		t.Fatal(err)
+	}
+
+	out := map[interface{}]interface{}{
+		"count": "42",
+		"data":  "aGVsbG8=",
+		"name":  "A",
+	}
+
+	if v := e1.Value(); !reflect.DeepEqual(v, out) {
+		t.Errorf("%#v != %#v", v, out)
+	}
+
+	if v := e2.Value(); !reflect.DeepEqual(v, out) {
+		t.Errorf("%#v != %#v", v, out)
+	}
+}
+
+// lengthRecorder is an emitter which records the lengths passed when arrays and
+// maps begin.
+type lengthRecorder struct {
+	*ValueEmitter
+	lengths []int
+}
+
+func (e *lengthRecorder) EmitArrayBegin(n int) error {
+	e.lengths = append(e.lengths, n)
+	return e.ValueEmitter.EmitArrayBegin(n)
+}
+
+func (e *lengthRecorder) EmitMapBegin(n int) error {
+	e.lengths = append(e.lengths, n)
+	return e.ValueEmitter.EmitMapBegin(n)
+}
+
+func TestEncoderIterators(t *testing.T) {
+	type T struct {
+		Values iter.Seq[int]             `objconv:"values"`
+		Pairs  iter.Seq2[string, string] `objconv:"pairs"`
+		None   iter.Seq[int]             `objconv:"none"`
+	}
+
+	v := T{
+		Values: func(yield func(int) bool) {
+			for i := 1; i <= 3; i++ {
+				if !yield(i) {
+					return
+				}
+			}
+		},
+		Pairs: func(yield func(string, string) bool) {
+			_ = yield("a", "A") && yield("b", "B")
+		},
+	}
+
+	e := &lengthRecorder{ValueEmitter: NewValueEmitter()}
+
+	if err := NewEncoder(e).Encode(v); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(e.Value(), map[interface{}]interface{}{
+		"values": []interface{}{int64(1), int64(2), int64(3)},
+		"pairs":  map[interface{}]interface{}{"a": "A", "b": "B"},
+		"none":   nil,
+	}) {
+		t.Errorf("bad value: %#v", e.Value())
+	}
+
+	if !reflect.DeepEqual(e.lengths, []int{3, -1, -1}) {
+		t.Errorf("bad lengths: %v", e.lengths)
+	}
+}
+
+func TestEncoderMapFilter(t *testing.T) {
+	blocked := map[interface{}]bool{"secret": true, "y": true, 2: true}
+	filter := func(k, v interface{}) bool { return !blocked[k] && v != "secret" }
+
+	tests := []struct {
+		in      interface{}
+		out     interface{}
+		lengths []int
+	}{
+		{
+			in:      map[string]interface{}{"a": 1, "b": "secret", "secret": 3},
+			out:     map[interface{}]interface{}{"a": int64(1)},
+			lengths: []int{1},
+		},
+		{
+			in:      map[string]string{"a": "A", "secret": "B"},
+			out:     map[interface{}]interface{}{"a": "A"},
+			lengths: []int{1},
+		},
+		{
+			in:      map[int][]int{1: {1}, 2: {2}},
+			out:     map[interface{}]interface{}{int64(1): []interface{}{int64(1)}},
+			lengths: []int{1, 1},
+		},
+		{
+			in: struct {
+				A map[string]int
+				B int
+			}{A: map[string]int{"x": 1, "y": 2}, B: 2},
+			out:     map[interface{}]interface{}{"A": map[interface{}]interface{}{"x": int64(1)}, "B": int64(2)},
+			lengths: []int{2, 1},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test.in), func(t *testing.T) {
+			e := &lengthRecorder{ValueEmitter: NewValueEmitter()}
+
+			if err := (Encoder{Emitter: e, MapFilter: filter, SortMapKeys: true}).Encode(test.in); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(e.Value(), test.out) {
+				t.Errorf("bad value: %#v", e.Value())
+			}
+
+			if !reflect.DeepEqual(e.lengths, test.lengths) {
+				t.Errorf("bad lengths: %v", e.lengths)
+			}
+		})
+	}
+
+	t.Run("ValueFunc", func(t *testing.T) {
+		e := &lengthRecorder{ValueEmitter: NewValueEmitter()}
+		enc := Encoder{
+			Emitter:   e,
+			MapFilter: filter,
+			ValueFunc: func(path []string, v interface{}) (interface{}, bool) { return v, true },
+		}
+
+		if err := enc.Encode(map[string]int{"a": 1, "secret": 2}); err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(e.Value(), map[interface{}]interface{}{"a": int64(1)}) || !reflect.DeepEqual(e.lengths, []int{1}) {
+			t.Errorf("bad value: %#v %v", e.Value(), e.lengths)
+		}
+	})
+}
+
+func TestEncoderIteratorError(t *testing.T) {
+	stopped := false
+	seq := func(yield func(interface{}) bool) {
+		if !yield(1) || !yield(make(chan int)) {
+			stopped = true
+			return
+		}
+		yield(3)
+	}
+
+	if err := NewEncoder(NewValueEmitter()).Encode(iter.Seq[interface{}](seq)); err == nil {
+		t.Error("expected an error encoding an unsupported value")
+	}
+
+	if !stopped {
+		t.Error("the iteration was not stopped after the error")
+	}
+}
+
+func TestEncodeSeq(t *testing.T) {
+	count := func(n int) iter.Seq[int] {
+		return func(yield func(int) bool) {
+			for i := 1; i <= n; i++ {
+				if !yield(i) {
+					return
+				}
+			}
+		}
+	}
+
+	tests := []struct {
+		name  string
+		n     int
+		seq   iter.Seq[int]
+		value interface{}
+		err   bool
+	}{
+		{
+			name:  "known length",
+			n:     3,
+			seq:   count(3),
+			value: []interface{}{int64(1), int64(2), int64(3)},
+		},
+		{
+			name:  "unknown length",
+			n:     -1,
+			seq:   count(2),
+			value: []interface{}{int64(1), int64(2)},
+		},
+		{
+			name:  "empty",
+			n:     0,
+			seq:   count(0),
+			value: []interface{}{},
+		},
+		{
+			name:  "nil",
+			n:     0,
+			value: []interface{}{},
+		},
+		{
+			name: "too few values",
+			n:    3,
+			seq:  count(2),
+			err:  true,
+		},
+		{
+			name: "too many values",
+			n:    3,
+			seq:  count(4),
+			err:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e := &lengthRecorder{ValueEmitter: NewValueEmitter()}
+			err := EncodeSeq(NewEncoder(e), test.n, test.seq)
+
+			if test.err {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(e.Value(), test.value) {
+				t.Errorf("bad value: %#v", e.Value())
+			}
+
+			if !reflect.DeepEqual(e.lengths, []int{test.n}) {
+				t.Errorf("bad lengths: %v", e.lengths)
+			}
+		})
+	}
+}
+
+type virtualPerson struct {
+	First string   `objconv:"first"`
+	Last  string   `objconv:"last"`
+	_     struct{} `objconv:"full_name,method=FullName"`
+	_     struct{} `objconv:"initials,method=Initials,omitempty"`
+}
+
+func (p virtualPerson) FullName() string {
+	return strings.TrimSpace(p.First + " " + p.Last)
+}
+
+func (p *virtualPerson) Initials() (string, error) {
+	if len(p.First) == 0 {
+		return "", nil
+	}
+	if len(p.Last) == 0 {
+		return "", errors.New("missing last name")
+	}
+	return p.First[:1] + p.Last[:1], nil
+}
+
+type virtualBadSignature struct {
+	_ struct{} `objconv:"greeting,method=Greet"`
+}
+
+func (virtualBadSignature) Greet(name string) string { return "hello " + name }
+
+type virtualPanic struct {
+	_ struct{} `objconv:"boom,method=Boom"`
+}
+
+func (virtualPanic) Boom() int { panic("boom") }
+
+func TestEncoderVirtualFields(t *testing.T) {
+	tests := []struct {
+		in  interface{}
+		out interface{}
+	}{
+		{
+			in: virtualPerson{First: "Luke", Last: "Skywalker"},
+			out: map[interface{}]interface{}{
+				"first":     "Luke",
+				"last":      "Skywalker",
+				"full_name": "Luke Skywalker",
+				"initials":  "LS",
+			},
+		},
+		{
+			in: &virtualPerson{Last: "Solo"},
+			out: map[interface{}]interface{}{
+				"first":     "",
+				"last":      "Solo",
+				"full_name": "Solo",
+			},
+		},
+		{
+			in: []virtualPerson{{First: "Leia", Last: "Organa"}},
+			out: []interface{}{map[interface{}]interface{}{
+				"first":     "Leia",
+				"last":      "Organa",
+				"full_name": "Leia Organa",
+				"initials":  "LO",
+			}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%+v", test.in), func(t *testing.T) {
+			e := NewValueEmitter()
+
+			if err := NewEncoder(e).Encode(test.in); err != nil {
+				t.Fatal(err)
+			}
+
+			if v := e.Value(); !reflect.DeepEqual(v, test.out) {
+				t.Errorf("%#v", v)
+			}
+		})
+	}
+
+	t.Run("value-func", func(t *testing.T) {
+		var paths []string
+		e := NewValueEmitter()
+		enc := Encoder{Emitter: e, ValueFunc: func(path []string, v interface{}) (interface{}, bool) {
+			paths = append(paths, strings.Join(path, "."))
+			return v, true
+		}}
+
+		if err := enc.Encode(virtualPerson{First: "Han", Last: "Solo"}); err != nil {
+			t.Fatal(err)
+		}
+
+		if m := e.Value().(map[interface{}]interface{}); m["full_name"] != "Han Solo" || m["initials"] != "HS" {
+			t.Errorf("%#v", m)
+		}
+
+		if !reflect.DeepEqual(paths, []string{"first", "last", "full_name", "initials"}) {
+			t.Error("bad paths:", paths)
+		}
+	})
+
+	t.Run("decode", func(t *testing.T) {
+		var p virtualPerson
+
+		in := map[string]interface{}{"first": "Rey", "full_name": "ignored", "initials": "R"}
+
+		if err := NewDecoder(NewValueParser(in)).Decode(&p); err != nil {
+			t.Fatal(err)
+		}
+
+		if p.First != "Rey" || p.Last != "" {
+			t.Errorf("%+v", p)
+		}
+	})
+
+	errs := []struct {
+		in  interface{}
+		err string
+	}{
+		{virtualPerson{First: "Obi-Wan"}, "missing last name"},
+		{virtualBadSignature{}, "objconv: method Greet of objconv.virtualBadSignature must take no arguments to be used for virtual field greeting"},
+		{virtualPanic{}, "objconv: method Boom called for virtual field boom panicked: boom"},
+	}
+
+	for _, test := range errs {
+		t.Run(test.err, func(t *testing.T) {
+			if err := NewEncoder(NewValueEmitter()).Encode(test.in); err == nil || err.Error() != test.err {
+				t.Error("bad error:", err)
+			}
+		})
+	}
+}
+
+func TestEncoderReset(t *testing.T) {
+	v1 := NewValueEmitter()
+	v2 := NewValueEmitter()
+
+	e := NewEncoder(v1)
+	e.SortMapKeys = true
+
+	if err := e.Encode(1); err != nil {
+		t.Fatal(err)
+	}
+
+	e.Reset(v2)
+
+	if err := e.Encode(2); err != nil {
+		t.Fatal(err)
+	}
+
+	if v1.Value() != int64(1) || v2.Value() != int64(2) {
+		t.Error(v1.Value(), v2.Value())
+	}
+
+	if !e.SortMapKeys {
+		t.Error("the configuration was not retained after the reset")
+	}
+}
+
+type failingValuer struct{}
+
+func (failingValuer) Value() (driver.Value, error) { return nil, errors.New("no value") }
+
+func TestEncoderValuer(t *testing.T) {
+	type T struct {
+		S sql.NullString  `objconv:"s"`
+		I sql.NullInt64   `objconv:"i"`
+		P *sql.NullString `objconv:"p"`
+	}
+
+	e := NewValueEmitter()
+
+	if err := NewEncoder(e).Encode(T{S: sql.NullString{String: "hello", Valid: true}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := e.Value(); !reflect.DeepEqual(v, map[interface{}]interface{}{"s": "hello", "i": nil, "p": nil}) {
+		t.Errorf("%#v", v)
+	}
+
+	if err := NewEncoder(NewValueEmitter()).Encode(failingValuer{}); err == nil || err.Error() != "objconv: cannot get the value of objconv.failingValuer: no value" {
+		t.Error("bad error:", err)
+	}
+}
@@ -0,0 +1,187 @@
+package objconv
+
+import (
+	"bytes"
+	"math"
+	"time"
+)
+
+// Equal compares a and b by their serialized representations, returning true
+// if both values would be encoded to semantically equivalent outputs.
+//
+// Unlike reflect.DeepEqual the comparison ignores differences that formats
+// don't preserve, like the order of map keys or the representation of numbers
+// (int(1), uint(1) and float64(1) are all considered equal).
+//
+// Nil values (like nil pointers or interfaces) and empty collections are
+// considered different, use a Comparer with NilEqualsEmpty set to true to relax
+// this behavior. Note that nil slices and maps are encoded as empty collections
+// and therefore always compare equal to empty values.
+func Equal(a interface{}, b interface{}) bool {
+	return Comparer{}.Equal(a, b)
+}
+
+// A Comparer carries the configuration used to compare values by their
+// serialized representations.
+type Comparer struct {
+	// NilEqualsEmpty makes nil values compare equal to empty arrays and maps.
+	NilEqualsEmpty bool
+}
+
+// Equal compares a and b, see the Equal function for details on the algorithm.
+func (c Comparer) Equal(a interface{}, b interface{}) bool {
+	va, err := serializedValueOf(a)
+	if err != nil {
+		return false
+	}
+
+	vb, err := serializedValueOf(b)
+	if err != nil {
+		return false
+	}
+
+	return c.equal(va, vb)
+}
+
+func (c Comparer) equal(a interface{}, b interface{}) bool {
+	switch va := a.(type) {
+	case nil:
+		return b == nil || (c.NilEqualsEmpty && isEmptyCollection(b))
+
+	case bool:
+		vb, ok := b.(bool)
+		return ok && va == vb
+
+	case int64, uint64, float64:
+		return equalNumbers(va, b)
+
+	case string:
+		switch vb := b.(type) {
+		case string:
+			return va == vb
+		case []byte:
+			return va == string(vb)
+		}
+
+	case []byte:
+		switch vb := b.(type) {
+		case string:
+			return string(va) == vb
+		case []byte:
+			return bytes.Equal(va, vb)
+		}
+
+	case time.Time:
+		vb, ok := b.(time.Time)
+		return ok && va.Equal(vb)
+
+	case time.Duration:
+		vb, ok := b.(time.Duration)
+		return ok && va == vb
+
+	case error:
+		vb, ok := b.(error)
+		return ok && va.Error() == vb.Error()
+
+	case []interface{}:
+		if b == nil {
+			return c.NilEqualsEmpty && len(va) == 0
+		}
+		if vb, ok := b.([]interface{}); ok && len(va) == len(vb) {
+			for i := range va {
+				if !c.equal(va[i], vb[i]) {
+					return false
+				}
+			}
+			return true
+		}
+
+	case map[interface{}]interface{}:
+		if b == nil {
+			return c.NilEqualsEmpty && len(va) == 0
+		}
+		if vb, ok := b.(map[interface{}]interface{}); ok && len(va) == len(vb) {
+			for k, v := range va {
+				if !c.equal(v, lookupEqualKey(c, vb, k)) {
+					return false
+				}
+			}
+			return true
+		}
+	}
+
+	return false
+}
+
+func serializedValueOf(v interface{}) (interface{}, error) {
+	e := NewValueEmitter()
+
+	if err := NewEncoder(e).Encode(v); err != nil {
+		return nil, err
+	}
+
+	return e.Value(), nil
+}
+
+func lookupEqualKey(c Comparer, m map[interface{}]interface{}, k interface{}) interface{} {
+	if v, ok := m[k]; ok {
+		return v
+	}
+
+	// The key may be represented differently in the other map (for example
+	// int64 vs float64), fallback to searching for an equivalent key.
+	for x, v := range m {
+		if c.equal(k, x) {
+			return v
+		}
+	}
+
+	return missingKey{}
+}
+
+func isEmptyCollection(v interface{}) bool {
+	switch x := v.(type) {
+	case []interface{}:
+		return len(x) == 0
+	case map[interface{}]interface{}:
+		return len(x) == 0
+	}
+	return false
+}
+
+func equalNumbers(a interface{}, b interface{}) bool {
+	switch va := a.(type) {
+	case int64:
+		switch vb := b.(type) {
+		case int64:
+			return va == vb
+		case uint64:
+			return va >= 0 && uint64(va) == vb
+		case float64:
+			return float64(va) == vb && vb >= math.MinInt64 && vb < math.MaxInt64 && int64(vb) == va
+		}
+
+	case uint64:
+		switch vb := b.(type) {
+		case int64:
+			return vb >= 0 && uint64(vb) == va
+		case uint64:
+			return va == vb
+		case float64:
+			return float64(va) == vb && vb >= 0 && vb < math.MaxUint64 && uint64(vb) == va
+		}
+
+	case float64:
+		switch b.(type) {
+		case int64, uint64:
+			return equalNumbers(b, a)
+		case float64:
+			return va == b.(float64)
+		}
+	}
+	return false
+}
+
+// missingKey is used to represent keys that were not found when comparing maps,
+// it never compares equal to any serialized value.
+type missingKey struct{}
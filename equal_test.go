@@ -0,0 +1,55 @@
+package objconv
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestEqual(t *testing.T) {
+	tests := []struct {
+		a     interface{}
+		b     interface{}
+		equal bool
+		relax bool
+	}{
+		{nil, nil, true, false},
+		{true, true, true, false},
+		{true, false, false, false},
+		{int(1), float64(1), true, false},
+		{int(1), uint8(1), true, false},
+		{int(-1), uint(1), false, false},
+		{float32(0.5), float64(0.5), true, false},
+		{float64(1.5), int(1), false, false},
+		{"hello", "hello", true, false},
+		{"hello", []byte("hello"), true, false},
+		{"hello", "world", false, false},
+		{[]int{1, 2, 3}, []float64{1, 2, 3}, true, false},
+		{[]int{1, 2, 3}, []int{3, 2, 1}, false, false},
+		{map[string]int{"A": 1, "B": 2}, map[string]float64{"B": 2, "A": 1}, true, false},
+		{map[int]string{1: "A"}, map[float64]string{1: "A"}, true, false},
+		{map[string]int{"A": 1}, map[string]int{"B": 1}, false, false},
+		{struct{ A, B int }{1, 2}, map[string]int{"A": 1, "B": 2}, true, false},
+
+		// nil vs empty collections
+		{nil, []int{}, false, false},
+		{nil, []int{}, true, true},
+		{(*[]int)(nil), map[string]int{}, false, false},
+		{(*[]int)(nil), map[string]int{}, true, true},
+		{nil, []int{1}, false, true},
+		{[]int(nil), []int{}, true, false}, // nil slices are encoded as empty arrays
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%#v==%#v", test.a, test.b), func(t *testing.T) {
+			c := Comparer{NilEqualsEmpty: test.relax}
+
+			if eq := c.Equal(test.a, test.b); eq != test.equal {
+				t.Errorf("%#v == %#v: expected %t but got %t", test.a, test.b, test.equal, eq)
+			}
+
+			if eq := c.Equal(test.b, test.a); eq != test.equal {
+				t.Errorf("%#v == %#v: expected %t but got %t", test.b, test.a, test.equal, eq)
+			}
+		})
+	}
+}
@@ -3,17 +3,178 @@ package objconv
 import (
 	"errors"
 	"fmt"
+	"reflect"
+	"strings"
 )
 
 func typeConversionError(from Type, to Type) error {
 	return fmt.Errorf("objconv: cannot convert from %s to %s", from, to)
 }
 
+// StackTracer is the interface implemented by errors which carry the stack
+// trace of where they were created, see Encoder.ErrorStacks.
+//
+// Errors created by github.com/pkg/errors are also supported, their StackTrace
+// method returns a slice of frames which are formatted with "%+v".
+type StackTracer interface {
+	StackTrace() []string
+}
+
+func stackTraceOf(err error) (stack []string, ok bool) {
+	if st, isStackTracer := err.(StackTracer); isStackTracer {
+		return st.StackTrace(), true
+	}
+
+	m := reflect.ValueOf(err).MethodByName("StackTrace")
+
+	if !m.IsValid() || m.Type().NumIn() != 0 || m.Type().NumOut() != 1 || m.Type().Out(0).Kind() != reflect.Slice {
+		return
+	}
+
+	frames := m.Call(nil)[0]
+	stack = make([]string, frames.Len())
+
+	for i := range stack {
+		stack[i] = fmt.Sprintf("%+v", frames.Index(i).Interface())
+	}
+
+	return stack, true
+}
+
+// FieldError represents an error that occurred while decoding a value nested
+// in arrays, maps or structs, see Decoder.CollectErrors.
+type FieldError struct {
+	// Path is the path to the value that failed to decode, made of map keys,
+	// field names and array indexes.
+	Path []string
+
+	// Message describes why the value failed to decode.
+	Message string
+}
+
+// Error satisfies the error interface.
+func (e FieldError) Error() string {
+	return "objconv: " + strings.Join(e.Path, ".") + ": " + e.Message
+}
+
+// FieldErrors is the error returned by decoders with CollectErrors set when
+// some values failed to decode, the errors are ordered by the position of the
+// values in the input.
+type FieldErrors []FieldError
+
+// Error satisfies the error interface.
+func (e FieldErrors) Error() string {
+	s := make([]string, len(e))
+	for i, err := range e {
+		s[i] = err.Error()
+	}
+	return strings.Join(s, "\n")
+}
+
+func (e *FieldErrors) add(path []string, err error) {
+	*e = append(*e, FieldError{
+		Path:    path,
+		Message: strings.TrimPrefix(err.Error(), "objconv: "),
+	})
+}
+
+// ScanError is returned by decoders when the Scan method of a value which
+// implements sql.Scanner fails.
+type ScanError struct {
+	// Path is the list of names of the struct fields that the value is nested
+	// in, it is empty if the value wasn't decoded into a struct field.
+	Path []string
+
+	// Type is the type of the value that failed to scan.
+	Type reflect.Type
+
+	// Err is the error returned by the Scan method.
+	Err error
+}
+
+// Error satisfies the error interface.
+func (e *ScanError) Error() string {
+	if len(e.Path) == 0 {
+		return fmt.Sprintf("objconv: cannot scan into %s: %s", e.Type, e.Err)
+	}
+	return fmt.Sprintf("objconv: %s: cannot scan into %s: %s", strings.Join(e.Path, "."), e.Type, e.Err)
+}
+
+// Unwrap returns the error returned by the Scan method.
+func (e *ScanError) Unwrap() error {
+	return e.Err
+}
+
+// prefixScanError prepends the name of a struct field to the path of err if it
+// is a ScanError.
+func prefixScanError(err error, name string) error {
+	if e, ok := err.(*ScanError); ok {
+		e.Path = append([]string{name}, e.Path...)
+	}
+	return err
+}
+
+// OneOfError is returned by DecodeOneOf when the value could not be decoded
+// into any of the candidate types.
+type OneOfError struct {
+	// Types is the list of candidate types, in the order they were tried.
+	Types []reflect.Type
+
+	// Errors holds the error returned when decoding into each of the types.
+	Errors []error
+}
+
+// Error satisfies the error interface.
+func (e *OneOfError) Error() string {
+	s := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		s[i] = e.Types[i].String() + ": " + strings.TrimPrefix(err.Error(), "objconv: ")
+	}
+	return "objconv: cannot decode the value into any of the candidate types (" + strings.Join(s, "; ") + ")"
+}
+
+// Unwrap returns the errors returned when decoding into each of the types.
+func (e *OneOfError) Unwrap() []error {
+	return e.Errors
+}
+
+// warning is a non-fatal issue reported by a decoder with Lenient set, see
+// Decoder.Warnings.
+type warning struct {
+	path []string
+	msg  string
+}
+
+func (w warning) String() string {
+	if len(w.path) == 0 {
+		return w.msg
+	}
+	return strings.Join(w.path, ".") + ": " + w.msg
+}
+
 var (
 	// End is expected to be returned to indicate that a function has completed
 	// its work, this is usually employed in generic algorithms.
 	End = errors.New("end")
 
+	// ErrMaxAllocBytes is returned by decoders when decoding a value would
+	// allocate more memory than allowed by the MaxAllocBytes limit. It reports
+	// a resource limit being reached, not that the input was malformed.
+	ErrMaxAllocBytes = errors.New("objconv: resource limit exceeded, decoding would allocate more than MaxAllocBytes")
+
+	// ErrMaxDepth is returned by decoders when the arrays and maps of a value
+	// are nested deeper than allowed by the MaxDepth limit.
+	ErrMaxDepth = errors.New("objconv: resource limit exceeded, values are nested deeper than MaxDepth")
+
+	// ErrTooLarge is returned by decoders when decoding a value reads more
+	// bytes from the input than allowed by the MaxBytes limit.
+	ErrTooLarge = errors.New("objconv: resource limit exceeded, decoding read more than MaxBytes from the input")
+
+	// ErrTruncated is returned by decoders with the Partial option set when the
+	// input ended in the middle of a value, the destination then holds the
+	// values that were decoded before the end of the input.
+	ErrTruncated = errors.New("objconv: truncated input, partial result")
+
 	// This error value is used as a building block for reflection and is never
 	// returned by the package.
 	errBase = errors.New("")
@@ -0,0 +1,523 @@
+package objconv
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultFlattenSeparator is the separator used to join the path segments of
+// flattened keys when none was configured.
+const DefaultFlattenSeparator = "."
+
+// A FlatEmitter is an emitter which flattens the values it receives into a
+// single level map before writing them to another emitter, the keys of the
+// map are the paths of the leaf values, with path segments joined by a
+// separator. For example:
+//
+//	{"database": {"host": "localhost", "ports": [5432, 5433]}}
+//
+// is written as:
+//
+//	{"database.host": "localhost", "database.ports.0": 5432, "database.ports.1": 5433}
+//
+// Array elements are keyed by their index, and empty arrays and maps are kept
+// as values of the flattened map so they aren't lost. Map keys must be strings
+// or scalar values, which are formatted as strings.
+//
+// The top-level values must be maps or arrays, they are buffered until they
+// are complete and written to the underlying emitter in the order their leaves
+// were received. Emitting a value fails when two leaves produce the same key,
+// for example with a "a.b" key next to an "a" map containing a "b" key.
+//
+// Instances of FlatEmitter are not safe for use by multiple goroutines.
+type FlatEmitter struct {
+	// Emitter receives the flattened maps.
+	Emitter Emitter
+
+	// Separator is used to join path segments, DefaultFlattenSeparator is
+	// used when it is empty.
+	Separator string
+
+	stack []flatFrame
+	keys  []string
+	vals  []func(Emitter) error
+	seen  map[string]struct{}
+}
+
+// flatFrame represents an array or map being flattened.
+type flatFrame struct {
+	typ    Type
+	path   string // path of the container
+	key    string // key of the current map element
+	inKey  bool   // whether the next value is a map key
+	index  int    // index of the current array element
+	length int    // number of elements received
+}
+
+// NewFlatEmitter returns a new emitter which writes flattened values to e.
+func NewFlatEmitter(e Emitter) *FlatEmitter {
+	return &FlatEmitter{Emitter: e}
+}
+
+func (e *FlatEmitter) EmitNil() error {
+	return e.emit(nil, func(x Emitter) error { return x.EmitNil() })
+}
+
+func (e *FlatEmitter) EmitBool(v bool) error {
+	return e.emit(strconv.FormatBool(v), func(x Emitter) error { return x.EmitBool(v) })
+}
+
+func (e *FlatEmitter) EmitInt(v int64, bitSize int) error {
+	return e.emit(strconv.FormatInt(v, 10), func(x Emitter) error { return x.EmitInt(v, bitSize) })
+}
+
+func (e *FlatEmitter) EmitUint(v uint64, bitSize int) error {
+	return e.emit(strconv.FormatUint(v, 10), func(x Emitter) error { return x.EmitUint(v, bitSize) })
+}
+
+func (e *FlatEmitter) EmitFloat(v float64, bitSize int) error {
+	return e.emit(strconv.FormatFloat(v, 'g', -1, bitSize), func(x Emitter) error { return x.EmitFloat(v, bitSize) })
+}
+
+func (e *FlatEmitter) EmitString(v string) error {
+	return e.emit(v, func(x Emitter) error { return x.EmitString(v) })
+}
+
+func (e *FlatEmitter) EmitBytes(v []byte) error {
+	b := append([]byte(nil), v...) // the emitter may reuse the buffer
+	return e.emit(string(b), func(x Emitter) error { return x.EmitBytes(b) })
+}
+
+func (e *FlatEmitter) EmitTime(v time.Time) error {
+	return e.emit(v.Format(time.RFC3339Nano), func(x Emitter) error { return x.EmitTime(v) })
+}
+
+func (e *FlatEmitter) EmitDuration(v time.Duration) error {
+	return e.emit(v.String(), func(x Emitter) error { return x.EmitDuration(v) })
+}
+
+func (e *FlatEmitter) EmitError(v error) error {
+	return e.emit(nil, func(x Emitter) error { return x.EmitError(v) })
+}
+
+func (e *FlatEmitter) EmitArrayBegin(n int) error { return e.begin(Array) }
+
+func (e *FlatEmitter) EmitArrayEnd() error { return e.end(Array) }
+
+func (e *FlatEmitter) EmitArrayNext() error {
+	if f := e.top(); f != nil {
+		f.index++
+	}
+	return nil
+}
+
+func (e *FlatEmitter) EmitMapBegin(n int) error { return e.begin(Map) }
+
+func (e *FlatEmitter) EmitMapEnd() error { return e.end(Map) }
+
+func (e *FlatEmitter) EmitMapValue() error {
+	if f := e.top(); f != nil {
+		f.inKey = false
+	}
+	return nil
+}
+
+func (e *FlatEmitter) EmitMapNext() error {
+	if f := e.top(); f != nil {
+		f.inKey = true
+	}
+	return nil
+}
+
+// emit records a leaf value, key is the string representation of the value
+// used when it is a map key, or nil if the value can't be used as a key.
+func (e *FlatEmitter) emit(key interface{}, emit func(Emitter) error) error {
+	f := e.top()
+
+	if f == nil {
+		return fmt.Errorf("objconv: FlatEmitter can only flatten maps and arrays")
+	}
+
+	if f.inKey {
+		s, ok := key.(string)
+		if !ok {
+			return e.errorf("objconv: FlatEmitter can't flatten maps with keys which aren't scalar values")
+		}
+		f.key = s
+		return nil
+	}
+
+	return e.leaf(e.path(), emit)
+}
+
+func (e *FlatEmitter) begin(t Type) error {
+	path := ""
+
+	if f := e.top(); f != nil {
+		if f.inKey {
+			return e.errorf("objconv: FlatEmitter can't flatten maps with keys which aren't scalar values")
+		}
+		path = e.path()
+		f.length++
+	}
+
+	e.stack = append(e.stack, flatFrame{typ: t, path: path, inKey: t == Map})
+	return nil
+}
+
+func (e *FlatEmitter) end(t Type) (err error) {
+	f := e.top()
+
+	if f == nil || f.typ != t {
+		return e.errorf("objconv: Emit%sEnd called without a matching Emit%sBegin", typeMethodName(t), typeMethodName(t))
+	}
+
+	frame := *f
+	e.stack = e.stack[:len(e.stack)-1]
+
+	if frame.length == 0 && len(e.stack) != 0 {
+		// Empty containers have no leaves, they are recorded as values so
+		// they aren't lost when flattening.
+		if err = e.leaf(frame.path, func(x Emitter) error { return emitEmpty(x, t) }); err != nil {
+			return
+		}
+	}
+
+	if len(e.stack) == 0 {
+		err = e.flush()
+	}
+
+	return
+}
+
+func (e *FlatEmitter) leaf(key string, emit func(Emitter) error) error {
+	if e.seen == nil {
+		e.seen = make(map[string]struct{})
+	}
+
+	if _, dup := e.seen[key]; dup {
+		return e.errorf("objconv: flattening produces the key %q more than once", key)
+	}
+
+	e.seen[key] = struct{}{}
+	e.keys = append(e.keys, key)
+	e.vals = append(e.vals, emit)
+
+	if f := e.top(); f != nil {
+		f.length++
+	}
+	return nil
+}
+
+func (e *FlatEmitter) flush() (err error) {
+	defer e.reset()
+
+	if err = e.Emitter.EmitMapBegin(len(e.keys)); err != nil {
+		return
+	}
+
+	for i, k := range e.keys {
+		if i != 0 {
+			if err = e.Emitter.EmitMapNext(); err != nil {
+				return
+			}
+		}
+
+		if err = e.Emitter.EmitString(k); err != nil {
+			return
+		}
+
+		if err = e.Emitter.EmitMapValue(); err != nil {
+			return
+		}
+
+		if err = e.vals[i](e.Emitter); err != nil {
+			return
+		}
+	}
+
+	return e.Emitter.EmitMapEnd()
+}
+
+// errorf discards the value being flattened and returns an error, so the
+// emitter can be reused after a failure.
+func (e *FlatEmitter) errorf(format string, args ...interface{}) error {
+	e.reset()
+	return fmt.Errorf(format, args...)
+}
+
+func (e *FlatEmitter) reset() {
+	e.stack = e.stack[:0]
+	e.keys = e.keys[:0]
+	e.vals = e.vals[:0]
+
+	for k := range e.seen {
+		delete(e.seen, k)
+	}
+}
+
+// path returns the flattened key of the current element of the container on
+// top of the stack.
+func (e *FlatEmitter) path() string {
+	f := e.top()
+	seg := f.key
+
+	if f.typ == Array {
+		seg = strconv.Itoa(f.index)
+	}
+
+	if len(e.stack) == 1 {
+		return seg
+	}
+
+	sep := e.Separator
+	if len(sep) == 0 {
+		sep = DefaultFlattenSeparator
+	}
+
+	return f.path + sep + seg
+}
+
+func (e *FlatEmitter) top() *flatFrame {
+	if len(e.stack) == 0 {
+		return nil
+	}
+	return &e.stack[len(e.stack)-1]
+}
+
+func emitEmpty(e Emitter, t Type) (err error) {
+	if t == Array {
+		if err = e.EmitArrayBegin(0); err == nil {
+			err = e.EmitArrayEnd()
+		}
+	} else {
+		if err = e.EmitMapBegin(0); err == nil {
+			err = e.EmitMapEnd()
+		}
+	}
+	return
+}
+
+// A FlatParser is a parser which un-flattens the maps it reads from another
+// parser, the keys of those maps are split on a separator and the values are
+// exposed as nested maps. For example:
+//
+//	{"database.host": "localhost", "database.ports.0": 5432, "database.ports.1": 5433}
+//
+// is parsed as:
+//
+//	{"database": {"host": "localhost", "ports": [5432, 5433]}}
+//
+// This is the reverse operation of a FlatEmitter, and is useful to decode
+// nested values from flat sources like environment variables or properties
+// files.
+//
+// Path segments which are indexes create arrays, when all the segments found
+// under a path are the indexes 0 to N-1 of the elements of an array. Other
+// segments create maps, which means that the elements of arrays and maps may
+// be arrays or maps themselves. Parsing a map fails when a flattened key is a
+// prefix of another, for example with the keys "a" and "a.b", since a path
+// can't have both a value and nested keys.
+//
+// Instances of FlatParser are not safe for use by multiple goroutines.
+type FlatParser struct {
+	// Parser provides the flattened maps.
+	Parser Parser
+
+	// Separator is used to split the keys in path segments,
+	// DefaultFlattenSeparator is used when it is empty.
+	Separator string
+
+	p     *ValueParser
+	depth int
+}
+
+// NewFlatParser returns a new parser which un-flattens the maps read from p.
+func NewFlatParser(p Parser) *FlatParser {
+	return &FlatParser{Parser: p}
+}
+
+func (p *FlatParser) ParseType() (Type, error) {
+	if p.p == nil {
+		if err := p.load(); err != nil {
+			return Nil, err
+		}
+	}
+	return p.p.ParseType()
+}
+
+func (p *FlatParser) ParseNil() error { return p.p.ParseNil() }
+
+func (p *FlatParser) ParseBool() (bool, error) { return p.p.ParseBool() }
+
+func (p *FlatParser) ParseInt() (int64, error) { return p.p.ParseInt() }
+
+func (p *FlatParser) ParseUint() (uint64, error) { return p.p.ParseUint() }
+
+func (p *FlatParser) ParseFloat() (float64, error) { return p.p.ParseFloat() }
+
+func (p *FlatParser) ParseString() ([]byte, error) { return p.p.ParseString() }
+
+func (p *FlatParser) ParseBytes() ([]byte, error) { return p.p.ParseBytes() }
+
+func (p *FlatParser) ParseTime() (time.Time, error) { return p.p.ParseTime() }
+
+func (p *FlatParser) ParseDuration() (time.Duration, error) { return p.p.ParseDuration() }
+
+func (p *FlatParser) ParseError() (error, error) { return p.p.ParseError() }
+
+func (p *FlatParser) ParseArrayBegin() (int, error) {
+	p.depth++
+	return p.p.ParseArrayBegin()
+}
+
+func (p *FlatParser) ParseArrayEnd(n int) error {
+	err := p.p.ParseArrayEnd(n)
+	p.end()
+	return err
+}
+
+func (p *FlatParser) ParseArrayNext(n int) error { return p.p.ParseArrayNext(n) }
+
+func (p *FlatParser) ParseMapBegin() (int, error) {
+	p.depth++
+	return p.p.ParseMapBegin()
+}
+
+func (p *FlatParser) ParseMapEnd(n int) error {
+	err := p.p.ParseMapEnd(n)
+	p.end()
+	return err
+}
+
+func (p *FlatParser) ParseMapValue(n int) error { return p.p.ParseMapValue(n) }
+
+func (p *FlatParser) ParseMapNext(n int) error { return p.p.ParseMapNext(n) }
+
+// end is called when the end of an array or map is reached, the next map is
+// loaded from the underlying parser after the end of the top-level value.
+func (p *FlatParser) end() {
+	if p.depth--; p.depth == 0 {
+		p.p = nil
+	}
+}
+
+func (p *FlatParser) load() (err error) {
+	var typ Type
+
+	if typ, err = p.Parser.ParseType(); err != nil {
+		return
+	}
+
+	if typ != Map {
+		return fmt.Errorf("objconv: FlatParser expects maps of flattened keys but found %s", typ)
+	}
+
+	sep := p.Separator
+	if len(sep) == 0 {
+		sep = DefaultFlattenSeparator
+	}
+
+	root := &flatNode{}
+
+	if err = (Decoder{Parser: p.Parser}).DecodeMap(func(kd Decoder, vd Decoder) (err error) {
+		var k string
+		var v interface{}
+
+		if err = kd.Decode(&k); err != nil {
+			return
+		}
+
+		if err = vd.Decode(&v); err != nil {
+			return
+		}
+
+		return root.insert(k, strings.Split(k, sep), v)
+	}); err != nil {
+		return
+	}
+
+	p.p = NewValueParser(root.value())
+	return
+}
+
+// flatNode is a node of the tree built when un-flattening a map.
+type flatNode struct {
+	key      string // flattened key which created the node
+	leaf     bool
+	val      interface{}
+	keys     []string // keys of the children, in the order they were found
+	children map[string]*flatNode
+}
+
+func (n *flatNode) insert(key string, path []string, v interface{}) error {
+	for _, seg := range path {
+		if n.leaf {
+			return fmt.Errorf("objconv: the flattened keys %q and %q conflict", n.key, key)
+		}
+
+		c := n.children[seg]
+
+		if c == nil {
+			if n.children == nil {
+				n.children = make(map[string]*flatNode)
+			}
+			c = &flatNode{key: key}
+			n.keys = append(n.keys, seg)
+			n.children[seg] = c
+		} else if c.leaf && c.key == key {
+			return fmt.Errorf("objconv: the flattened key %q was found more than once", key)
+		}
+
+		n = c
+	}
+
+	if n.leaf || n.children != nil {
+		return fmt.Errorf("objconv: the flattened keys %q and %q conflict", n.key, key)
+	}
+
+	n.leaf, n.val = true, v
+	return nil
+}
+
+func (n *flatNode) value() interface{} {
+	if n.leaf {
+		return n.val
+	}
+
+	if a, ok := n.array(); ok {
+		return a
+	}
+
+	m := make(map[string]interface{}, len(n.keys))
+
+	for _, k := range n.keys {
+		m[k] = n.children[k].value()
+	}
+
+	return m
+}
+
+// array returns the children of n as an array if their keys are the indexes
+// of the array elements.
+func (n *flatNode) array() ([]interface{}, bool) {
+	if len(n.keys) == 0 {
+		return nil, false
+	}
+
+	a := make([]interface{}, len(n.keys))
+
+	for k, c := range n.children {
+		i, err := strconv.Atoi(k)
+
+		if err != nil || i < 0 || i >= len(a) || strconv.Itoa(i) != k {
+			return nil, false
+		}
+
+		a[i] = c.value()
+	}
+
+	return a, true
+}
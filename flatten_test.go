@@ -0,0 +1,291 @@
+package objconv
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFlatEmitter(t *testing.T) {
+	type database struct {
+		Host  string   `objconv:"host"`
+		Ports []int    `objconv:"ports"`
+		Tags  []string `objconv:"tags"`
+	}
+
+	type config struct {
+		Name     string            `objconv:"name"`
+		Database database          `objconv:"database"`
+		Labels   map[string]string `objconv:"labels"`
+		Limits   map[int]bool      `objconv:"limits"`
+	}
+
+	tests := []struct {
+		name string
+		sep  string
+		in   interface{}
+		out  map[interface{}]interface{}
+		keys []interface{}
+	}{
+		{
+			name: "struct",
+			in: config{
+				Name:     "api",
+				Database: database{Host: "localhost", Ports: []int{5432, 5433}, Tags: []string{}},
+				Labels:   map[string]string{"env": "prod"},
+				Limits:   map[int]bool{10: true},
+			},
+			out: map[interface{}]interface{}{
+				"name":             "api",
+				"database.host":    "localhost",
+				"database.ports.0": int64(5432),
+				"database.ports.1": int64(5433),
+				"database.tags":    []interface{}{},
+				"labels.env":       "prod",
+				"limits.10":        true,
+			},
+			keys: []interface{}{"name", "database.host", "database.ports.0", "database.ports.1", "database.tags", "labels.env", "limits.10"},
+		},
+		{
+			name: "separator",
+			sep:  "_",
+			in:   map[string]interface{}{"db": map[string]interface{}{"host": "localhost", "opts": map[string]int{}}},
+			out: map[interface{}]interface{}{
+				"db_host": "localhost",
+				"db_opts": map[interface{}]interface{}{},
+			},
+		},
+		{
+			name: "array",
+			in:   []interface{}{"a", []int{1}, nil},
+			out: map[interface{}]interface{}{
+				"0":   "a",
+				"1.0": int64(1),
+				"2":   nil,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			v := NewValueEmitter()
+			e := NewFlatEmitter(v)
+			e.Separator = test.sep
+
+			if err := NewEncoder(e).Encode(test.in); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(v.Value(), test.out) {
+				t.Errorf("%#v", v.Value())
+			}
+
+			if test.keys != nil {
+				keys := []interface{}{}
+
+				if err := NewEncoder(NewFlatEmitter(&keysEmitter{keys: &keys})).Encode(test.in); err != nil {
+					t.Fatal(err)
+				}
+
+				if !reflect.DeepEqual(keys, test.keys) {
+					t.Errorf("bad key order: %#v", keys)
+				}
+			}
+		})
+	}
+}
+
+func TestFlatEmitterErrors(t *testing.T) {
+	tests := []struct {
+		in  interface{}
+		err string
+	}{
+		{
+			in:  map[string]interface{}{"a.b": 1, "a": map[string]int{"b": 2}},
+			err: `objconv: flattening produces the key "a.b" more than once`,
+		},
+		{
+			in:  42,
+			err: "objconv: FlatEmitter can only flatten maps and arrays",
+		},
+		{
+			in:  map[[1]int]int{{1}: 1},
+			err: "objconv: FlatEmitter can't flatten maps with keys which aren't scalar values",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.err, func(t *testing.T) {
+			v := NewValueEmitter()
+			e := NewFlatEmitter(v)
+
+			if err := NewEncoder(e).Encode(test.in); err == nil || err.Error() != test.err {
+				t.Error("bad error:", err)
+			}
+
+			// The emitter must be usable after an error.
+			if err := NewEncoder(e).Encode(map[string]int{"x": 1}); err != nil {
+				t.Error(err)
+			} else if !reflect.DeepEqual(v.Value(), map[interface{}]interface{}{"x": int64(1)}) {
+				t.Errorf("%#v", v.Value())
+			}
+		})
+	}
+}
+
+func TestFlatParser(t *testing.T) {
+	type host struct {
+		Name string `objconv:"name"`
+		Port int    `objconv:"port"`
+	}
+
+	type config struct {
+		Name   string            `objconv:"name"`
+		Hosts  []host            `objconv:"hosts"`
+		Tags   []string          `objconv:"tags"`
+		Labels map[string]string `objconv:"labels"`
+	}
+
+	tests := []struct {
+		name string
+		sep  string
+		in   map[string]interface{}
+		out  config
+	}{
+		{
+			name: "dot",
+			in: map[string]interface{}{
+				"name":         "api",
+				"hosts.0.name": "a",
+				"hosts.0.port": 80,
+				"hosts.1.name": "b",
+				"tags.1":       "y",
+				"tags.0":       "x",
+				"labels.env":   "prod",
+				"labels.0":     "zero",
+			},
+			out: config{
+				Name:   "api",
+				Hosts:  []host{{Name: "a", Port: 80}, {Name: "b"}},
+				Tags:   []string{"x", "y"},
+				Labels: map[string]string{"env": "prod", "0": "zero"},
+			},
+		},
+		{
+			name: "underscore",
+			sep:  "_",
+			in: map[string]interface{}{
+				"name":         "api",
+				"hosts_0_name": "a",
+				"labels_a.b":   "c",
+			},
+			out: config{
+				Name:   "api",
+				Hosts:  []host{{Name: "a"}},
+				Labels: map[string]string{"a.b": "c"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := NewFlatParser(NewValueParser(test.in))
+			p.Separator = test.sep
+
+			var c config
+
+			if err := NewDecoder(p).Decode(&c); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(c, test.out) {
+				t.Errorf("%#v", c)
+			}
+		})
+	}
+}
+
+func TestFlatParserInterface(t *testing.T) {
+	var v interface{}
+
+	p := NewFlatParser(NewValueParser(map[string]interface{}{
+		"a.0":   1,
+		"a.2":   2, // not an array because the indexes aren't contiguous
+		"b.0.c": true,
+	}))
+
+	if err := NewDecoder(p).Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(v, map[interface{}]interface{}{
+		"a": map[interface{}]interface{}{"0": int64(1), "2": int64(2)},
+		"b": []interface{}{map[interface{}]interface{}{"c": true}},
+	}) {
+		t.Errorf("%#v", v)
+	}
+}
+
+func TestFlatParserConflict(t *testing.T) {
+	// Structs are used so the keys are parsed in order and the errors are
+	// deterministic.
+	tests := []struct {
+		in  interface{}
+		err string
+	}{
+		{
+			in: struct {
+				A  int `objconv:"a"`
+				AB int `objconv:"a.b"`
+			}{},
+			err: `objconv: the flattened keys "a" and "a.b" conflict`,
+		},
+		{
+			in: struct {
+				ABC int `objconv:"a.b.c"`
+				AB  int `objconv:"a.b"`
+			}{},
+			err: `objconv: the flattened keys "a.b.c" and "a.b" conflict`,
+		},
+		{
+			in: struct {
+				A  int `objconv:"a"`
+				A2 int `objconv:"a"`
+			}{},
+			err: `objconv: the flattened key "a" was found more than once`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.err, func(t *testing.T) {
+			var v interface{}
+			err := NewDecoder(NewFlatParser(NewValueParser(test.in))).Decode(&v)
+
+			if err == nil || err.Error() != test.err {
+				t.Error("bad error:", err)
+			}
+		})
+	}
+}
+
+func TestFlatRoundTrip(t *testing.T) {
+	in := map[string]interface{}{
+		"a": []interface{}{int64(1), map[interface{}]interface{}{"b": "c"}, []interface{}{}},
+		"d": map[interface{}]interface{}{"e": nil},
+	}
+
+	e := NewValueEmitter()
+
+	if err := NewEncoder(NewFlatEmitter(e)).Encode(in); err != nil {
+		t.Fatal(err)
+	}
+
+	var out map[string]interface{}
+
+	if err := NewDecoder(NewFlatParser(NewValueParser(e.Value()))).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("%#v", out)
+	}
+}
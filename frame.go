@@ -0,0 +1,276 @@
+package objconv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"sync"
+)
+
+// A FramedEncoder encodes values as a sequence of frames, each frame starts
+// with the length of its body, by default encoded as a 32 bits big-endian
+// unsigned integer, followed by the body (the value encoded with the codec of
+// the encoder), and an optional checksum of the body.
+//
+// Bodies are buffered in memory before being written, the buffers are pooled
+// and shared by all framed encoders.
+//
+// Instances of FramedEncoder are not safe for use by multiple goroutines.
+type FramedEncoder struct {
+	// Codec used to encode the body of frames.
+	Codec Codec
+
+	// Checksum, if not nil, is called to create the hash used to compute the
+	// checksum of each frame body, which is written as a 32 bits big-endian
+	// unsigned integer after the body (for example crc32.NewIEEE).
+	Checksum func() hash.Hash32
+
+	// LengthSize is the number of bytes used to encode the length of frame
+	// bodies, it must be 1, 2, 4 or 8. Zero means 4.
+	LengthSize int
+
+	// ByteOrder is used to encode the length of frame bodies, when nil the
+	// lengths are big-endian.
+	ByteOrder binary.ByteOrder
+
+	// MaxBytes limits the size of frame bodies, encoding a value fails with
+	// ErrFrameTooLarge as soon as its body exceeds the limit, which bounds the
+	// memory used to buffer it. Zero means no limit other than the maximum
+	// length that can be represented with LengthSize bytes.
+	MaxBytes int
+
+	w io.Writer
+}
+
+// NewFramedEncoder returns a new framed encoder that outputs to w.
+func NewFramedEncoder(w io.Writer, c Codec) *FramedEncoder {
+	return &FramedEncoder{Codec: c, w: w}
+}
+
+// Encode writes a frame containing the encoded representation of v.
+func (e *FramedEncoder) Encode(v interface{}) (err error) {
+	var h [8]byte
+	var size int
+
+	if size, err = frameLengthSize(e.LengthSize); err != nil {
+		return
+	}
+
+	buf := frameBufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer frameBufferPool.Put(buf)
+	buf.Write(h[:size]) // placeholder for the frame length
+
+	var w io.Writer = buf
+
+	if e.MaxBytes > 0 {
+		w = &frameLimitWriter{b: buf, max: size + e.MaxBytes}
+	}
+
+	if err = NewEncoder(e.Codec.NewEmitter(w)).Encode(v); err != nil {
+		return
+	}
+
+	b := buf.Bytes()
+	n := uint64(len(b) - size)
+
+	if n > maxFrameLength(size) {
+		return fmt.Errorf("objconv: frame body of %d bytes is too large for a length of %d bytes", n, size)
+	}
+
+	putFrameLength(b[:size], byteOrderOr(e.ByteOrder), n)
+
+	if e.Checksum != nil {
+		c := e.Checksum()
+		c.Write(b[size:])
+		binary.BigEndian.PutUint32(h[:4], c.Sum32())
+		buf.Write(h[:4])
+	}
+
+	_, err = e.w.Write(buf.Bytes())
+	return
+}
+
+// A FramedDecoder decodes values from frames written by a FramedEncoder.
+//
+// Instances of FramedDecoder are not safe for use by multiple goroutines.
+type FramedDecoder struct {
+	// Codec used to decode the body of frames.
+	Codec Codec
+
+	// Checksum, if not nil, is called to create the hash used to verify the
+	// checksum written after each frame body. It must match the configuration
+	// of the encoder which produced the frames.
+	Checksum func() hash.Hash32
+
+	// LengthSize and ByteOrder configure the encoding of the length of frame
+	// bodies, they must match the configuration of the encoder which produced
+	// the frames, see FramedEncoder.
+	LengthSize int
+	ByteOrder  binary.ByteOrder
+
+	// MaxBytes limits the size of the frame bodies that the decoder accepts,
+	// Decode returns ErrFrameTooLarge without reading the body of frames which
+	// exceed the limit. Zero means no limit.
+	MaxBytes int
+
+	r io.Reader
+	b []byte
+}
+
+// NewFramedDecoder returns a new framed decoder that takes input from r.
+func NewFramedDecoder(r io.Reader, c Codec) *FramedDecoder {
+	return &FramedDecoder{Codec: c, r: r}
+}
+
+// Decode reads the next frame and decodes its body into v.
+//
+// The method returns io.EOF when there are no more frames to read, and
+// ErrFrameChecksum if the checksum of the frame didn't match its body, in which
+// case the body isn't decoded.
+func (d *FramedDecoder) Decode(v interface{}) (err error) {
+	var h [8]byte
+	var size int
+
+	if size, err = frameLengthSize(d.LengthSize); err != nil {
+		return
+	}
+
+	if _, err = io.ReadFull(d.r, h[:size]); err != nil {
+		return
+	}
+
+	length := getFrameLength(h[:size], byteOrderOr(d.ByteOrder))
+
+	if (d.MaxBytes > 0 && length > uint64(d.MaxBytes)) || length > maxInt {
+		return ErrFrameTooLarge
+	}
+
+	var b []byte
+
+	if b, err = d.readBody(int(length)); err != nil {
+		return d.truncated(err)
+	}
+
+	if d.Checksum != nil {
+		if _, err = io.ReadFull(d.r, h[:4]); err != nil {
+			return d.truncated(err)
+		}
+
+		c := d.Checksum()
+		c.Write(b)
+
+		if c.Sum32() != binary.BigEndian.Uint32(h[:4]) {
+			return ErrFrameChecksum
+		}
+	}
+
+	return NewDecoder(d.Codec.NewParser(bytes.NewReader(b))).Decode(v)
+}
+
+// readBody reads the n bytes of a frame body. The buffer is grown as the bytes
+// are read instead of being allocated upfront when it is too small, so a frame
+// length read from a corrupted or malicious input can't make the decoder
+// allocate more memory than the input holds.
+func (d *FramedDecoder) readBody(n int) (b []byte, err error) {
+	if cap(d.b) >= n {
+		b = d.b[:n]
+		_, err = io.ReadFull(d.r, b)
+		return
+	}
+
+	buf := bytes.NewBuffer(d.b[:0])
+	_, err = io.CopyN(buf, d.r, int64(n))
+	b = buf.Bytes()
+	d.b = b
+	return
+}
+
+func (d *FramedDecoder) truncated(err error) error {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// ErrFrameChecksum is returned by FramedDecoder when the checksum of a frame
+// doesn't match its body, indicating that the data was corrupted.
+var ErrFrameChecksum = errors.New("objconv: frame checksum mismatch")
+
+// ErrFrameTooLarge is returned by FramedEncoder and FramedDecoder when the body
+// of a frame exceeds their MaxBytes limit.
+var ErrFrameTooLarge = errors.New("objconv: frame body exceeds the maximum size")
+
+const maxInt = uint64(^uint(0) >> 1)
+
+var frameBufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// frameLimitWriter is used to stop encoding values when their size exceeds the
+// MaxBytes limit of a FramedEncoder.
+type frameLimitWriter struct {
+	b   *bytes.Buffer
+	max int
+}
+
+func (w *frameLimitWriter) Write(b []byte) (int, error) {
+	if w.b.Len()+len(b) > w.max {
+		return 0, ErrFrameTooLarge
+	}
+	return w.b.Write(b)
+}
+
+func frameLengthSize(size int) (int, error) {
+	switch size {
+	case 0:
+		return 4, nil
+	case 1, 2, 4, 8:
+		return size, nil
+	default:
+		return 0, fmt.Errorf("objconv: invalid frame length size of %d bytes, it must be 1, 2, 4 or 8", size)
+	}
+}
+
+func maxFrameLength(size int) uint64 {
+	if size == 8 {
+		return ^uint64(0)
+	}
+	return 1<<(8*uint(size)) - 1
+}
+
+func byteOrderOr(order binary.ByteOrder) binary.ByteOrder {
+	if order == nil {
+		return binary.BigEndian
+	}
+	return order
+}
+
+func putFrameLength(b []byte, order binary.ByteOrder, n uint64) {
+	switch len(b) {
+	case 1:
+		b[0] = byte(n)
+	case 2:
+		order.PutUint16(b, uint16(n))
+	case 4:
+		order.PutUint32(b, uint32(n))
+	default:
+		order.PutUint64(b, n)
+	}
+}
+
+func getFrameLength(b []byte, order binary.ByteOrder) uint64 {
+	switch len(b) {
+	case 1:
+		return uint64(b[0])
+	case 2:
+		return uint64(order.Uint16(b))
+	case 4:
+		return uint64(order.Uint32(b))
+	default:
+		return order.Uint64(b)
+	}
+}
@@ -0,0 +1,183 @@
+package objconv_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"hash/crc32"
+	"io"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/segmentio/objconv"
+	"github.com/segmentio/objconv/json"
+)
+
+func TestFramed(t *testing.T) {
+	values := []interface{}{
+		nil,
+		int64(42),
+		"Hello World!",
+		map[interface{}]interface{}{"answer": int64(42)},
+	}
+
+	for _, checksum := range []func() hash.Hash32{nil, crc32.NewIEEE} {
+		b := &bytes.Buffer{}
+		e := objconv.NewFramedEncoder(b, json.Codec)
+		e.Checksum = checksum
+
+		for _, v := range values {
+			if err := e.Encode(v); err != nil {
+				t.Error(err)
+			}
+		}
+
+		d := objconv.NewFramedDecoder(b, json.Codec)
+		d.Checksum = checksum
+
+		for _, v1 := range values {
+			var v2 interface{}
+
+			if err := d.Decode(&v2); err != nil {
+				t.Error(err)
+			}
+
+			if !reflect.DeepEqual(v1, v2) {
+				t.Errorf("%#v != %#v", v1, v2)
+			}
+		}
+
+		if err := d.Decode(nil); err != io.EOF {
+			t.Error("expected io.EOF but got", err)
+		}
+	}
+}
+
+func TestFramedChecksumMismatch(t *testing.T) {
+	b := &bytes.Buffer{}
+	e := objconv.NewFramedEncoder(b, json.Codec)
+	e.Checksum = crc32.NewIEEE
+
+	if err := e.Encode("Hello World!"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Corrupt one byte of the frame body, which starts after the 4 bytes of
+	// the length header.
+	b.Bytes()[5] = 'h'
+
+	var v string
+	d := objconv.NewFramedDecoder(b, json.Codec)
+	d.Checksum = crc32.NewIEEE
+
+	if err := d.Decode(&v); err != objconv.ErrFrameChecksum {
+		t.Error("expected a checksum error but got", err)
+	}
+}
+
+func TestFramedLengthPrefix(t *testing.T) {
+	tests := []struct {
+		size   int
+		order  binary.ByteOrder
+		header string
+	}{
+		{1, nil, "0e"},
+		{2, binary.LittleEndian, "0e00"},
+		{4, nil, "0000000e"},
+		{8, binary.LittleEndian, "0e00000000000000"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.header, func(t *testing.T) {
+			b := &bytes.Buffer{}
+			e := objconv.NewFramedEncoder(b, json.Codec)
+			e.LengthSize = test.size
+			e.ByteOrder = test.order
+
+			if err := e.Encode("Hello World!"); err != nil {
+				t.Fatal(err)
+			}
+
+			if h := hex.EncodeToString(b.Bytes()[:test.size]); h != test.header {
+				t.Error("bad frame header:", h)
+			}
+
+			if n := b.Len() - test.size; n != 14 {
+				t.Error("bad frame body length:", n)
+			}
+
+			var v string
+			d := objconv.NewFramedDecoder(b, json.Codec)
+			d.LengthSize = test.size
+			d.ByteOrder = test.order
+
+			if err := d.Decode(&v); err != nil {
+				t.Fatal(err)
+			}
+
+			if v != "Hello World!" {
+				t.Error("bad value:", v)
+			}
+		})
+	}
+}
+
+func TestFramedMaxBytes(t *testing.T) {
+	b := &bytes.Buffer{}
+	e := objconv.NewFramedEncoder(b, json.Codec)
+	e.MaxBytes = 10
+
+	if err := e.Encode("Hello World!"); err != objconv.ErrFrameTooLarge {
+		t.Error("expected ErrFrameTooLarge but got", err)
+	}
+
+	if b.Len() != 0 {
+		t.Error("no frame should have been written")
+	}
+
+	e.MaxBytes = 0
+	e.LengthSize = 1
+
+	if err := e.Encode(strings.Repeat("A", 300)); err == nil {
+		t.Error("expected an error encoding a body too large for the length prefix")
+	}
+
+	e.LengthSize = 4
+
+	if err := e.Encode("Hello World!"); err != nil {
+		t.Fatal(err)
+	}
+
+	var v string
+	d := objconv.NewFramedDecoder(b, json.Codec)
+	d.MaxBytes = 10
+
+	if err := d.Decode(&v); err != objconv.ErrFrameTooLarge {
+		t.Error("expected ErrFrameTooLarge but got", err)
+	}
+}
+
+func TestFramedTruncatedLargeFrame(t *testing.T) {
+	// The frame declares a body of 1 GiB but the input ends after a few bytes,
+	// the decoder must report the truncation without allocating the body.
+	b := []byte{0x40, 0x00, 0x00, 0x00, '"', 'A'}
+	d := objconv.NewFramedDecoder(bytes.NewReader(b), json.Codec)
+
+	var v string
+	var m1, m2 runtime.MemStats
+
+	runtime.ReadMemStats(&m1)
+	err := d.Decode(&v)
+	runtime.ReadMemStats(&m2)
+
+	if err != io.ErrUnexpectedEOF {
+		t.Error("expected io.ErrUnexpectedEOF but got", err)
+	}
+
+	if n := m2.TotalAlloc - m1.TotalAlloc; n > 1<<20 {
+		t.Error("too many bytes allocated:", n)
+	}
+}
@@ -0,0 +1,56 @@
+package hocon
+
+import (
+	"bytes"
+	"io"
+	"sync"
+
+	"github.com/segmentio/objconv"
+)
+
+// codec is used to create decoders, the package has no emitter.
+var codec = objconv.Codec{
+	NewParser: func(r io.Reader) objconv.Parser { return NewParser(r) },
+}
+
+// NewDecoder returns a new HOCON decoder that parses values from r.
+func NewDecoder(r io.Reader) *objconv.Decoder {
+	return codec.NewDecoder(r)
+}
+
+// NewStreamDecoder returns a new HOCON stream decoder that parses values from r.
+func NewStreamDecoder(r io.Reader) *objconv.StreamDecoder {
+	return codec.NewStreamDecoder(r)
+}
+
+// Unmarshal decodes a HOCON representation of v from b.
+func Unmarshal(b []byte, v interface{}) error {
+	u := unmarshalerPool.Get().(*unmarshaler)
+	u.reset(b)
+
+	err := (objconv.Decoder{Parser: u}).Decode(v)
+
+	u.reset(nil)
+	unmarshalerPool.Put(u)
+	return err
+}
+
+var unmarshalerPool = sync.Pool{
+	New: func() interface{} { return newUnmarshaler() },
+}
+
+type unmarshaler struct {
+	Parser
+	b bytes.Buffer
+}
+
+func newUnmarshaler() *unmarshaler {
+	u := &unmarshaler{}
+	u.r = &u.b
+	return u
+}
+
+func (u *unmarshaler) reset(b []byte) {
+	u.b = *bytes.NewBuffer(b)
+	u.Reset(&u.b)
+}
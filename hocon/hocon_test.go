@@ -0,0 +1,283 @@
+package hocon
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/segmentio/objconv"
+)
+
+const testConfig = `
+// Service configuration
+service {
+  name = my-service
+  port: 8080
+  "ratio" = 0.5
+  enabled = true
+}
+
+defaults { timeout = 10 seconds, retries = 3 }
+
+database = ${defaults} {
+  hosts = ["alpha", "omega",]
+  url = "postgres://"${database.hosts.0}
+  retries = 5
+}
+
+database.replica.lag = 1e3
+path = /usr/bin
+path = ${path}":/bin"
+tags = [a]
+tags += b
+`
+
+type testConfigType struct {
+	Service struct {
+		Name    string  `objconv:"name"`
+		Port    int     `objconv:"port"`
+		Ratio   float64 `objconv:"ratio"`
+		Enabled bool    `objconv:"enabled"`
+	} `objconv:"service"`
+	Database struct {
+		Hosts   []string           `objconv:"hosts"`
+		Timeout string             `objconv:"timeout"`
+		Retries int                `objconv:"retries"`
+		Replica map[string]float64 `objconv:"replica"`
+	} `objconv:"database"`
+	Path string   `objconv:"path"`
+	Tags []string `objconv:"tags"`
+}
+
+func TestUnmarshal(t *testing.T) {
+	var c testConfigType
+
+	if err := Unmarshal([]byte(strings.Replace(testConfig, "${database.hosts.0}", `"host"`, 1)), &c); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Service.Name != "my-service" || c.Service.Port != 8080 || c.Service.Ratio != 0.5 || !c.Service.Enabled {
+		t.Errorf("bad service: %+v", c.Service)
+	}
+
+	if !reflect.DeepEqual(c.Database.Hosts, []string{"alpha", "omega"}) || c.Database.Timeout != "10 seconds" || c.Database.Retries != 5 {
+		t.Errorf("bad database: %+v", c.Database)
+	}
+
+	if c.Database.Replica["lag"] != 1000 {
+		t.Error("bad replica:", c.Database.Replica)
+	}
+
+	if c.Path != "/usr/bin:/bin" {
+		t.Error("bad path:", c.Path)
+	}
+
+	if !reflect.DeepEqual(c.Tags, []string{"a", "b"}) {
+		t.Error("bad tags:", c.Tags)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var v interface{}
+
+	if err := Unmarshal([]byte(`{"a": [1, 2.5, "x\u00e9\n", true, null], "b": {"c": {}}}`), &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(v, map[interface{}]interface{}{
+		"a": []interface{}{int64(1), 2.5, "xé\n", true, nil},
+		"b": map[interface{}]interface{}{"c": map[interface{}]interface{}{}},
+	}) {
+		t.Errorf("%#v", v)
+	}
+}
+
+func TestValues(t *testing.T) {
+	env := map[string]string{"HOME": "/home/me", "a.b": "from env"}
+
+	tests := []struct {
+		in  string
+		out map[interface{}]interface{}
+	}{
+		{
+			in:  "a = 1\nb = -2.5e1\nc = 99999999999999999999\nd = 1.2.3\ne = null",
+			out: map[interface{}]interface{}{"a": int64(1), "b": -25.0, "c": 1e20, "d": "1.2.3", "e": nil},
+		},
+		{
+			in:  "a = \"\"\"multi\n\"line\" \"\"\"\"\nb = foo \"bar\"  baz # comment",
+			out: map[interface{}]interface{}{"a": "multi\n\"line\" \"", "b": "foo bar  baz"},
+		},
+		{
+			in:  "a { b = 1 }\na { c = 2 }\na.d = 3",
+			out: map[interface{}]interface{}{"a": map[interface{}]interface{}{"b": int64(1), "c": int64(2), "d": int64(3)}},
+		},
+		{
+			in:  "a { b = 1 }\na = 2\na.c = 3",
+			out: map[interface{}]interface{}{"a": map[interface{}]interface{}{"c": int64(3)}},
+		},
+		{
+			// Substitutions see the final value of the field they refer to,
+			// even when it is defined later in the document.
+			in:  "a = ${b}\nb = ${c}\nc = 1\nc = 2",
+			out: map[interface{}]interface{}{"a": int64(2), "b": int64(2), "c": int64(2)},
+		},
+		{
+			// Self-references see the previous value of the field.
+			in:  "a = 1\na = ${a}\" \"${a}\nb = ${a}",
+			out: map[interface{}]interface{}{"a": "1 1", "b": "1 1"},
+		},
+		{
+			in:  "a = [1]\na = ${a} [2]\na += 3",
+			out: map[interface{}]interface{}{"a": []interface{}{int64(1), int64(2), int64(3)}},
+		},
+		{
+			in:  "a { x = 1 }\na { x = ${a.x}0 }",
+			out: map[interface{}]interface{}{"a": map[interface{}]interface{}{"x": "10"}},
+		},
+		{
+			in: "base { x = 1, y { z = 2 } }\na = ${base} { y.w = 3 }\na.x = 4",
+			out: map[interface{}]interface{}{
+				"base": map[interface{}]interface{}{"x": int64(1), "y": map[interface{}]interface{}{"z": int64(2)}},
+				"a":    map[interface{}]interface{}{"x": int64(4), "y": map[interface{}]interface{}{"z": int64(2), "w": int64(3)}},
+			},
+		},
+		{
+			in:  "a { b = 1, c = ${a.b} }",
+			out: map[interface{}]interface{}{"a": map[interface{}]interface{}{"b": int64(1), "c": int64(1)}},
+		},
+		{
+			in:  "a = 1\na = ${?missing}\nb = ${?missing}\nc = [1, ${?missing}]",
+			out: map[interface{}]interface{}{"a": int64(1), "c": []interface{}{int64(1)}},
+		},
+		{
+			in:  "home = ${HOME}\nx = ${a.b}\nport = ${?PORT}",
+			out: map[interface{}]interface{}{"home": "/home/me", "x": "from env"},
+		},
+		{
+			in:  "a = [${b}]\nb = { c: 1 }",
+			out: map[interface{}]interface{}{"a": []interface{}{map[interface{}]interface{}{"c": int64(1)}}, "b": map[interface{}]interface{}{"c": int64(1)}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			p := NewParser(strings.NewReader(test.in))
+			p.LookupEnv = func(name string) (string, bool) {
+				v, ok := env[name]
+				return v, ok
+			}
+
+			var v interface{}
+
+			if err := objconv.NewDecoder(p).Decode(&v); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(v, test.out) {
+				t.Errorf("%#v", v)
+			}
+		})
+	}
+}
+
+type readCloser struct{ io.Reader }
+
+func (readCloser) Close() error { return nil }
+
+func TestInclude(t *testing.T) {
+	files := map[string]string{
+		"base.conf":  "a = 1\nb { c = 2 }",
+		"other.conf": "include \"base.conf\"\nd = ${a}",
+	}
+
+	p := NewParser(strings.NewReader("include file(\"other.conf\")\ninclude \"missing.conf\"\nb { e = 3 }"))
+	p.Include = func(kind string, name string) (io.ReadCloser, error) {
+		if kind != "" && kind != "file" {
+			t.Errorf("bad kind: %q", kind)
+		}
+		s, ok := files[name]
+		if !ok {
+			return nil, errors.New("not found")
+		}
+		return readCloser{strings.NewReader(s)}, nil
+	}
+
+	var v interface{}
+
+	if err := objconv.NewDecoder(p).Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(v, map[interface{}]interface{}{
+		"a": int64(1),
+		"b": map[interface{}]interface{}{"c": int64(2), "e": int64(3)},
+		"d": int64(1),
+	}) {
+		t.Errorf("%#v", v)
+	}
+
+	p = NewParser(strings.NewReader("include required(\"missing.conf\")"))
+	p.Include = func(kind string, name string) (io.ReadCloser, error) {
+		return nil, errors.New("not found")
+	}
+
+	if err := objconv.NewDecoder(p).Decode(&v); err == nil || err.Error() != `objconv/hocon: line 1: cannot include "missing.conf": not found` {
+		t.Error("bad error:", err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		in  string
+		err string
+	}{
+		{"a = ${b}", "objconv/hocon: line 1: undefined substitution ${b}"},
+		{"a = 1\nb {\n  c = ${x.y}\n}", "objconv/hocon: line 3: undefined substitution ${x.y}"},
+		{"a = ${b}\nb = ${a}", "objconv/hocon: line 2: the substitution ${a} is part of a cycle"},
+		{"a { b = ${a} }", "objconv/hocon: line 1: the substitution ${a} is part of a cycle"},
+		{"a = ${a}", "objconv/hocon: line 1: undefined substitution ${a}"},
+		{"a = [1] {b = 2}", "objconv/hocon: line 1: cannot concatenate an array and an object"},
+		{"a = \"abc", "objconv/hocon: line 1: unterminated string"},
+		{"a = \"\\q\"", `objconv/hocon: line 1: invalid escape sequence \q`},
+		{"a = [1 2] x", "objconv/hocon: line 1: cannot concatenate an array and a string"},
+		{"a 1", "objconv/hocon: line 1: expected '=', ':' or '{' after the key a but found '1'"},
+		{"a = 1 }", "objconv/hocon: line 1: expected ',' or a new line after the field but found '}'"},
+		{"{ a = 1", "objconv/hocon: line 1: expected '}' at the end of the object but found the end of the document"},
+		{"= 1", "objconv/hocon: line 1: expected a key but found '='"},
+		{"a =\n", "objconv/hocon: line 2: expected a value but found the end of the document"},
+		{"include \"x.conf\"", `objconv/hocon: line 1: cannot include "x.conf", the parser has no Include function`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			p := NewParser(strings.NewReader(test.in))
+			p.LookupEnv = func(string) (string, bool) { return "", false }
+
+			var v interface{}
+
+			if err := objconv.NewDecoder(p).Decode(&v); err == nil || err.Error() != test.err {
+				t.Errorf("bad error: %v", err)
+			}
+		})
+	}
+}
+
+func TestStreamDecoder(t *testing.T) {
+	d := NewStreamDecoder(strings.NewReader("a = 1"))
+
+	var m map[string]int
+
+	if err := d.Decode(&m); err != nil {
+		t.Fatal(err)
+	}
+
+	if m["a"] != 1 {
+		t.Error("bad value:", m)
+	}
+
+	if err := d.Decode(&m); err != objconv.End {
+		t.Error("expected the end of the stream after the document but got", err)
+	}
+}
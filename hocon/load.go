@@ -0,0 +1,878 @@
+package hocon
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// object is the representation of HOCON objects built by the loader, keys are
+// kept in the order they first appear in the document.
+type object struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newObject() *object {
+	return &object{values: make(map[string]interface{})}
+}
+
+func (o *object) get(k string) (v interface{}, ok bool) {
+	v, ok = o.values[k]
+	return
+}
+
+func (o *object) set(k string, v interface{}) {
+	if _, ok := o.values[k]; !ok {
+		o.keys = append(o.keys, k)
+	}
+	o.values[k] = v
+}
+
+func (o *object) remove(k string) {
+	if _, ok := o.values[k]; !ok {
+		return
+	}
+	delete(o.values, k)
+
+	for i, x := range o.keys {
+		if x == k {
+			o.keys = append(o.keys[:i:i], o.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// concat is a value made of multiple pieces, or of substitutions, which can
+// only be computed once the whole document was loaded.
+type concat struct {
+	pieces []interface{}
+	line   int
+}
+
+// hasSelfReference returns true if one of the pieces of c is a substitution
+// referring to the field that c is assigned to.
+func (c *concat) hasSelfReference() bool {
+	for _, p := range c.pieces {
+		if s, ok := p.(*substitution); ok && s.self {
+			return true
+		}
+	}
+	return false
+}
+
+// overlay is a value assigned to a field which already had one, when either
+// can't be computed before substitutions are resolved. If both values are
+// objects they are merged, otherwise the new value replaces the base, unless
+// it is undefined.
+type overlay struct {
+	base  interface{}
+	value interface{}
+}
+
+// substitution is a ${path} or ${?path} expression.
+type substitution struct {
+	path     []string
+	optional bool
+	line     int
+
+	// When the substitution refers to the field it is assigned to, it is
+	// resolved to the value that the field had before the assignment.
+	self     bool
+	bound    bool
+	prior    interface{}
+	hasPrior bool
+}
+
+func (s *substitution) String() string {
+	if s.optional {
+		return "${?" + strings.Join(s.path, ".") + "}"
+	}
+	return "${" + strings.Join(s.path, ".") + "}"
+}
+
+// space is the whitespace separating the pieces of a concatenation, it is only
+// retained when the pieces are concatenated as strings.
+type space string
+
+// unquoted is an unquoted string which is part of a concatenation, unquoted
+// strings which are values on their own are parsed as numbers, booleans or
+// null when they have the syntax of one.
+type unquoted string
+
+// undefined is the value of concatenations made of optional substitutions
+// which were not defined.
+type undefined struct{}
+
+// maxIncludeDepth is the maximum nesting of included documents.
+const maxIncludeDepth = 50
+
+// loader builds the tree of values of a HOCON document.
+type loader struct {
+	b    []byte
+	i    int
+	line int
+	name string // name of the included document, empty for the root
+
+	include func(kind string, name string) (io.ReadCloser, error)
+	depth   int
+}
+
+func (l *loader) errorf(format string, args ...interface{}) error {
+	if len(l.name) != 0 {
+		return fmt.Errorf("objconv/hocon: %s: line %d: %s", l.name, l.line, fmt.Sprintf(format, args...))
+	}
+	return fmt.Errorf("objconv/hocon: line %d: %s", l.line, fmt.Sprintf(format, args...))
+}
+
+func (l *loader) load() (v interface{}, err error) {
+	l.skipBlank()
+
+	switch {
+	case l.hasPrefix("["):
+		v, err = l.parseArray()
+
+	case l.hasPrefix("{"):
+		v, err = l.parseObject(true, []string{})
+
+	default:
+		v, err = l.parseObject(false, []string{})
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if l.skipBlank(); !l.eof() {
+		return nil, l.errorf("expected the end of the document but found %s", l.found())
+	}
+
+	return v, nil
+}
+
+// parseObject parses the fields of an object, path is the path of the object
+// in the document, or nil when the object is nested in an array.
+func (l *loader) parseObject(braced bool, path []string) (*object, error) {
+	o := newObject()
+
+	if braced {
+		l.i++ // '{'
+	}
+
+	for {
+		l.skipBlank()
+
+		switch {
+		case braced && l.hasPrefix("}"):
+			l.i++
+			return o, nil
+
+		case l.eof():
+			if braced {
+				return nil, l.errorf("expected '}' at the end of the object but found the end of the document")
+			}
+			return o, nil
+		}
+
+		if err := l.parseField(o, path); err != nil {
+			return nil, err
+		}
+
+		l.skipSpace()
+		l.skipComment()
+
+		switch {
+		case l.hasPrefix(","):
+			l.i++
+		case l.skipNewline():
+		case l.eof():
+		case braced && l.hasPrefix("}"):
+		default:
+			return nil, l.errorf("expected ',' or a new line after the field but found %s", l.found())
+		}
+	}
+}
+
+func (l *loader) parseField(o *object, path []string) error {
+	if l.isInclude() {
+		return l.parseInclude(o)
+	}
+
+	keys, err := l.parseKey()
+	if err != nil {
+		return err
+	}
+
+	var full []string
+	var add bool
+
+	if path != nil {
+		full = append(path[:len(path):len(path)], keys...)
+	}
+
+	l.skipSpace()
+
+	switch {
+	case l.hasPrefix("+="):
+		if full == nil {
+			return l.errorf("'+=' cannot be used in objects nested in arrays")
+		}
+		l.i += 2
+		add = true
+
+	case l.hasPrefix("=") || l.hasPrefix(":"):
+		l.i++
+
+	case l.hasPrefix("{"):
+		// The separator is optional before objects.
+
+	default:
+		return l.errorf("expected '=', ':' or '{' after the key %s but found %s", strings.Join(keys, "."), l.found())
+	}
+
+	l.skipBlank()
+	line := l.line
+
+	v, err := l.parseValue(full)
+	if err != nil {
+		return err
+	}
+
+	if add {
+		// a += v is a shorthand for a = ${?a} [v]
+		v = &concat{
+			pieces: []interface{}{
+				&substitution{path: full, optional: true, line: line, self: true},
+				[]interface{}{v},
+			},
+			line: line,
+		}
+	}
+
+	set(o, keys, v)
+	return nil
+}
+
+// set assigns v to the field at keys in o, merging it with the previous value
+// of the field when both are objects.
+func set(o *object, keys []string, v interface{}) {
+	for _, k := range keys[:len(keys)-1] {
+		switch x := o.values[k].(type) {
+		case *object:
+			o = x
+
+		case *concat, *overlay:
+			n := newObject()
+			o.set(k, &overlay{base: x, value: n})
+			o = n
+
+		default:
+			n := newObject()
+			o.set(k, n)
+			o = n
+		}
+	}
+
+	k := keys[len(keys)-1]
+	prev, exists := o.get(k)
+
+	if !exists {
+		o.set(k, v)
+		return
+	}
+
+	switch x := v.(type) {
+	case *object:
+		switch p := prev.(type) {
+		case *object:
+			for _, k := range x.keys {
+				set(p, []string{k}, x.values[k])
+			}
+			return
+
+		case *concat, *overlay:
+			o.set(k, &overlay{base: p, value: x})
+			return
+		}
+
+	case *concat:
+		if !x.hasSelfReference() {
+			o.set(k, &overlay{base: prev, value: x})
+			return
+		}
+
+		for _, p := range x.pieces {
+			if s, ok := p.(*substitution); ok && s.self && !s.bound {
+				s.bound, s.prior, s.hasPrior = true, prev, true
+			}
+		}
+	}
+
+	o.set(k, v)
+}
+
+func (l *loader) isInclude() bool {
+	if !l.hasPrefix("include") {
+		return false
+	}
+
+	j := l.i + len("include")
+
+	if c := l.byteAt(j); c != ' ' && c != '\t' {
+		return false
+	}
+
+	for l.byteAt(j) == ' ' || l.byteAt(j) == '\t' {
+		j++
+	}
+
+	s := string(l.b[j:])
+
+	for _, prefix := range [...]string{`"`, "file(", "url(", "classpath(", "required("} {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (l *loader) parseInclude(o *object) error {
+	l.i += len("include")
+	l.skipSpace()
+
+	required := false
+	closing := 0
+	kind := ""
+
+	if l.hasPrefix("required(") {
+		l.i += len("required(")
+		required = true
+		closing++
+		l.skipSpace()
+	}
+
+	for _, k := range [...]string{"file", "url", "classpath"} {
+		if l.hasPrefix(k + "(") {
+			l.i += len(k) + 1
+			kind = k
+			closing++
+			l.skipSpace()
+			break
+		}
+	}
+
+	if !l.hasPrefix(`"`) {
+		return l.errorf("expected the quoted name of the included document but found %s", l.found())
+	}
+
+	name, err := l.parseQuotedString()
+	if err != nil {
+		return err
+	}
+
+	for ; closing != 0; closing-- {
+		if err := l.expect(")"); err != nil {
+			return err
+		}
+	}
+
+	if l.include == nil {
+		return l.errorf("cannot include %q, the parser has no Include function", name)
+	}
+
+	if l.depth >= maxIncludeDepth {
+		return l.errorf("cannot include %q, includes are nested too deeply", name)
+	}
+
+	r, err := l.include(kind, name)
+	if err != nil {
+		if required {
+			return l.errorf("cannot include %q: %s", name, err)
+		}
+		return nil
+	}
+
+	b, err := ioutil.ReadAll(r)
+	r.Close()
+
+	if err != nil {
+		return l.errorf("cannot include %q: %s", name, err)
+	}
+
+	sub := &loader{b: b, line: 1, name: name, include: l.include, depth: l.depth + 1}
+
+	v, err := sub.load()
+	if err != nil {
+		return err
+	}
+
+	x, ok := v.(*object)
+	if !ok {
+		return l.errorf("the included document %q is not an object", name)
+	}
+
+	for _, k := range x.keys {
+		set(o, []string{k}, x.values[k])
+	}
+
+	return nil
+}
+
+// parseKey parses a path expression, unquoted dots separate its elements.
+func (l *loader) parseKey() (keys []string, err error) {
+	var b []byte
+	var ok bool
+
+	for !l.eof() {
+		switch c := l.b[l.i]; {
+		case c == '"' && !l.hasPrefix(`"""`):
+			var s string
+			if s, err = l.parseQuotedString(); err != nil {
+				return
+			}
+			b, ok = append(b, s...), true
+			continue
+
+		case c == '.':
+			if !ok {
+				return nil, l.errorf("expected a key before '.' but found %s", l.found())
+			}
+			keys = append(keys, string(b))
+			b, ok = nil, false
+			l.i++
+			continue
+
+		case isUnquotedChar(c) && !l.hasPrefix("//"):
+			i := l.i
+			for !l.eof() && l.b[l.i] != '.' && isUnquotedChar(l.b[l.i]) && !l.hasPrefix("//") {
+				l.i++
+			}
+			b, ok = append(b, l.b[i:l.i]...), true
+			continue
+		}
+		break
+	}
+
+	if !ok {
+		return nil, l.errorf("expected a key but found %s", l.found())
+	}
+
+	return append(keys, string(b)), nil
+}
+
+// parseValue parses the pieces of a value until the end of the line, or the
+// end of the array or object that contains it. full is the path of the field
+// the value is assigned to, or nil for elements of arrays.
+func (l *loader) parseValue(full []string) (interface{}, error) {
+	var pieces []interface{}
+	line := l.line
+
+	for {
+		i := l.i
+		l.skipSpace()
+
+		if l.atValueEnd() {
+			break
+		}
+
+		if l.i != i && len(pieces) != 0 {
+			pieces = append(pieces, space(l.b[i:l.i]))
+		}
+
+		p, err := l.parsePiece(full)
+		if err != nil {
+			return nil, err
+		}
+
+		pieces = append(pieces, p)
+	}
+
+	switch len(pieces) {
+	case 0:
+		return nil, l.errorf("expected a value but found %s", l.found())
+
+	case 1:
+		switch p := pieces[0].(type) {
+		case *substitution:
+		case unquoted:
+			return parseUnquoted(string(p)), nil
+		default:
+			return p, nil
+		}
+	}
+
+	for _, p := range pieces {
+		if s, ok := p.(*substitution); ok && full != nil && equalPaths(s.path, full) {
+			s.self = true
+		}
+	}
+
+	return &concat{pieces: pieces, line: line}, nil
+}
+
+func (l *loader) parsePiece(full []string) (interface{}, error) {
+	switch c := l.b[l.i]; {
+	case l.hasPrefix(`"""`):
+		return l.parseMultilineString()
+
+	case c == '"':
+		return l.parseQuotedString()
+
+	case l.hasPrefix("${"):
+		return l.parseSubstitution()
+
+	case c == '[':
+		return l.parseArray()
+
+	case c == '{':
+		return l.parseObject(true, full)
+
+	case isUnquotedChar(c):
+		i := l.i
+		for !l.eof() && isUnquotedChar(l.b[l.i]) && !l.hasPrefix("//") {
+			l.i++
+		}
+		return unquoted(l.b[i:l.i]), nil
+
+	default:
+		return nil, l.errorf("invalid character %s in value", l.found())
+	}
+}
+
+func (l *loader) parseSubstitution() (*substitution, error) {
+	s := &substitution{line: l.line}
+	l.i += 2 // "${"
+
+	if l.hasPrefix("?") {
+		s.optional = true
+		l.i++
+	}
+
+	l.skipSpace()
+
+	keys, err := l.parseKey()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = l.expect("}"); err != nil {
+		return nil, err
+	}
+
+	s.path = keys
+	return s, nil
+}
+
+func (l *loader) parseArray() ([]interface{}, error) {
+	a := []interface{}{}
+	l.i++ // '['
+
+	for {
+		l.skipBlank()
+
+		if l.hasPrefix("]") {
+			l.i++
+			return a, nil
+		}
+
+		v, err := l.parseValue(nil)
+		if err != nil {
+			return nil, err
+		}
+
+		a = append(a, v)
+		l.skipSpace()
+		l.skipComment()
+
+		switch {
+		case l.hasPrefix(","):
+			l.i++
+		case l.skipNewline():
+		case l.hasPrefix("]"):
+		default:
+			return nil, l.errorf("expected ',' or ']' after array element but found %s", l.found())
+		}
+	}
+}
+
+func (l *loader) parseQuotedString() (string, error) {
+	var b []byte
+	l.i++ // '"'
+
+	for {
+		if l.eof() {
+			return "", l.errorf("unterminated string")
+		}
+
+		switch c := l.b[l.i]; {
+		case c == '"':
+			l.i++
+			return string(b), nil
+
+		case c == '\\':
+			var err error
+			if b, err = l.parseEscape(b); err != nil {
+				return "", err
+			}
+
+		case c == '\n' || c == '\r':
+			return "", l.errorf("newline found in quoted string")
+
+		case c < 0x20 && c != '\t':
+			return "", l.errorf("invalid control character %q in string", c)
+
+		default:
+			b = append(b, c)
+			l.i++
+		}
+	}
+}
+
+// parseMultilineString parses a triple-quoted string, which has no escape
+// sequences. Quotes preceding the closing delimiter are part of the string.
+func (l *loader) parseMultilineString() (string, error) {
+	l.i += 3
+	i := l.i
+
+	for {
+		if l.eof() {
+			return "", l.errorf("unterminated multi-line string")
+		}
+
+		if l.hasPrefix(`"""`) {
+			n := 3
+			for l.byteAt(l.i+n) == '"' {
+				n++
+			}
+			s := string(l.b[i : l.i+n-3])
+			l.i += n
+			return s, nil
+		}
+
+		if l.b[l.i] == '\n' {
+			l.line++
+		}
+
+		l.i++
+	}
+}
+
+func (l *loader) parseEscape(b []byte) ([]byte, error) {
+	if l.i+1 >= len(l.b) {
+		return nil, l.errorf("unterminated escape sequence")
+	}
+
+	c := l.b[l.i+1]
+	l.i += 2
+
+	switch c {
+	case 'b':
+		return append(b, '\b'), nil
+	case 't':
+		return append(b, '\t'), nil
+	case 'n':
+		return append(b, '\n'), nil
+	case 'f':
+		return append(b, '\f'), nil
+	case 'r':
+		return append(b, '\r'), nil
+	case '"', '\\', '/':
+		return append(b, c), nil
+	case 'u':
+		r, err := l.parseUnicodeEscape()
+		if err != nil {
+			return nil, err
+		}
+
+		if utf16.IsSurrogate(r) && l.hasPrefix(`\u`) {
+			l.i += 2
+			r2, err := l.parseUnicodeEscape()
+			if err != nil {
+				return nil, err
+			}
+			r = utf16.DecodeRune(r, r2)
+		}
+
+		return utf8.AppendRune(b, r), nil
+
+	default:
+		return nil, l.errorf("invalid escape sequence \\%c", c)
+	}
+}
+
+func (l *loader) parseUnicodeEscape() (rune, error) {
+	if l.i+4 > len(l.b) {
+		return 0, l.errorf("unterminated unicode escape sequence")
+	}
+
+	s := string(l.b[l.i : l.i+4])
+	r, err := strconv.ParseUint(s, 16, 16)
+
+	if err != nil {
+		return 0, l.errorf("invalid unicode escape sequence \\u%s", s)
+	}
+
+	l.i += 4
+	return rune(r), nil
+}
+
+func (l *loader) expect(s string) error {
+	l.skipSpace()
+
+	if !l.hasPrefix(s) {
+		return l.errorf("expected '%s' but found %s", s, l.found())
+	}
+
+	l.i += len(s)
+	return nil
+}
+
+// atValueEnd returns true if the current position ends a value.
+func (l *loader) atValueEnd() bool {
+	if l.eof() {
+		return true
+	}
+	switch l.b[l.i] {
+	case '\n', '\r', ',', '}', ']', '#':
+		return true
+	}
+	return l.hasPrefix("//")
+}
+
+// skipBlank skips whitespace, newlines and comments.
+func (l *loader) skipBlank() {
+	for {
+		l.skipSpace()
+		l.skipComment()
+
+		if !l.skipNewline() {
+			return
+		}
+	}
+}
+
+func (l *loader) skipSpace() {
+	for !l.eof() && (l.b[l.i] == ' ' || l.b[l.i] == '\t') {
+		l.i++
+	}
+}
+
+func (l *loader) skipComment() {
+	if l.hasPrefix("#") || l.hasPrefix("//") {
+		for !l.eof() && l.b[l.i] != '\n' {
+			l.i++
+		}
+	}
+}
+
+func (l *loader) skipNewline() bool {
+	switch {
+	case l.hasPrefix("\n"):
+		l.i++
+	case l.hasPrefix("\r\n"):
+		l.i += 2
+	default:
+		return false
+	}
+	l.line++
+	return true
+}
+
+func (l *loader) eof() bool {
+	return l.i >= len(l.b)
+}
+
+func (l *loader) byteAt(i int) byte {
+	if i < len(l.b) {
+		return l.b[i]
+	}
+	return 0
+}
+
+func (l *loader) hasPrefix(s string) bool {
+	return strings.HasPrefix(string(l.b[l.i:]), s)
+}
+
+// found describes the input at the current position for error messages.
+func (l *loader) found() string {
+	if l.eof() {
+		return "the end of the document"
+	}
+	r, _ := utf8.DecodeRune(l.b[l.i:])
+	return strconv.QuoteRune(r)
+}
+
+// isUnquotedChar returns true if c may appear in unquoted strings.
+func isUnquotedChar(c byte) bool {
+	switch c {
+	case ' ', '\t', '\n', '\r',
+		'$', '"', '{', '}', '[', ']', ':', '=', ',', '+', '#', '`', '^', '?', '!', '@', '*', '&', '\\':
+		return false
+	}
+	return true
+}
+
+// parseUnquoted parses an unquoted string as a number, a boolean or null when
+// it has the syntax of one.
+func parseUnquoted(s string) interface{} {
+	switch s {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null":
+		return nil
+	}
+
+	if isNumber(s) {
+		if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return v
+		}
+		if v, err := strconv.ParseFloat(s, 64); err == nil {
+			return v
+		}
+	}
+
+	return s
+}
+
+// isNumber returns true if s only has characters of decimal numbers, and
+// starts with a digit or a minus sign followed by a digit.
+func isNumber(s string) bool {
+	if strings.HasPrefix(s, "-") {
+		s = s[1:]
+	}
+
+	if len(s) == 0 || !isDigit(s[0]) {
+		return false
+	}
+
+	for i := 0; i != len(s); i++ {
+		if !isDigit(s[i]) && strings.IndexByte(".eE-", s[i]) < 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func equalPaths(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
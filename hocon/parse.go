@@ -0,0 +1,324 @@
+// Package hocon implements a parser of HOCON (Human-Optimized Config Object
+// Notation) documents which satisfies the objconv.Parser interface, so
+// configuration files can be decoded into Go values with the objconv decoders.
+//
+// The parser supports the common subset of the format, which is a superset of
+// JSON: comments, unquoted keys and strings, dotted keys, '=' and ':'
+// separators (optional before objects), commas or newlines between fields,
+// merging of objects defined more than once, value concatenation, the '+='
+// operator, triple-quoted strings, includes, and substitutions of the form
+// ${path} and ${?path}.
+//
+// Substitutions are resolved after the whole document was loaded, so they see
+// the final value at their path, even if it is defined later in the document.
+// A substitution referring to the field it is assigned to, like
+// path = ${path}":/usr/bin", refers to the previous value of the field
+// instead. Substitutions which aren't defined in the document fall back to
+// environment variables, and an error is reported when neither exists, unless
+// the substitution is optional (${?path}), in which case the field is left
+// undefined.
+package hocon
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/segmentio/objconv"
+)
+
+// Parser implements a parser of HOCON documents.
+//
+// Numbers are parsed as Int values when they are integers that fit in 64 bits,
+// and as Float values otherwise.
+type Parser struct {
+	// Include is called to open the documents referenced by include
+	// statements, with the name that the statement was given, and the kind of
+	// resource which is "file", "url", "classpath" or an empty string when
+	// the statement didn't specify one. The included document is merged at
+	// the position of the statement, and the substitutions it contains are
+	// resolved against the root of the including document.
+	//
+	// When nil, include statements are reported as errors. Statements marked
+	// as required() must be included, errors opening the other ones are
+	// ignored.
+	Include func(kind string, name string) (io.ReadCloser, error)
+
+	// LookupEnv is called to resolve the substitutions that aren't defined in
+	// the document, with the path of the substitution. When nil, environment
+	// variables are looked up with os.LookupEnv.
+	LookupEnv func(name string) (string, bool)
+
+	r io.Reader // reader to load bytes from
+	s []byte    // string buffer
+	// This stack is used to iterate over the arrays and objects of the loaded
+	// document.
+	stack []parser
+}
+
+func NewParser(r io.Reader) *Parser {
+	return &Parser{r: r}
+}
+
+func (p *Parser) Reset(r io.Reader) {
+	p.r = r
+	p.s = nil
+	p.stack = nil
+}
+
+func (p *Parser) Buffered() io.Reader {
+	return bytes.NewReader(nil)
+}
+
+func (p *Parser) ParseType() (typ objconv.Type, err error) {
+	if p.stack == nil {
+		var b []byte
+		var v interface{}
+
+		if b, err = ioutil.ReadAll(p.r); err != nil {
+			return
+		}
+
+		l := &loader{b: b, line: 1, include: p.Include}
+
+		if v, err = l.load(); err != nil {
+			return
+		}
+
+		r := &resolver{lookupEnv: p.LookupEnv}
+
+		if v, err = r.resolveDocument(v); err != nil {
+			return
+		}
+
+		p.push(newParser(v))
+	}
+
+	switch v := p.value(); v.(type) {
+	case nil:
+		typ = objconv.Nil
+
+	case bool:
+		typ = objconv.Bool
+
+	case int64:
+		typ = objconv.Int
+
+	case float64:
+		typ = objconv.Float
+
+	case string:
+		typ = objconv.String
+
+	case *object:
+		typ = objconv.Map
+
+	case []interface{}:
+		typ = objconv.Array
+
+	case eof:
+		err = io.EOF
+
+	default:
+		err = fmt.Errorf("objconv/hocon: unsupported value of type %T", v)
+	}
+
+	return
+}
+
+func (p *Parser) ParseNil() (err error) {
+	p.pop()
+	return
+}
+
+func (p *Parser) ParseBool() (v bool, err error) {
+	v = p.pop().value().(bool)
+	return
+}
+
+func (p *Parser) ParseInt() (v int64, err error) {
+	v = p.pop().value().(int64)
+	return
+}
+
+func (p *Parser) ParseUint() (v uint64, err error) {
+	panic("objconv/hocon: ParseUint should never be called because HOCON has no unsigned integer type, this is likely a bug in the decoder code")
+}
+
+func (p *Parser) ParseFloat() (v float64, err error) {
+	v = p.pop().value().(float64)
+	return
+}
+
+func (p *Parser) ParseString() (v []byte, err error) {
+	s := p.pop().value().(string)
+	v = append(p.s[:0], s...)
+	p.s = v
+	return
+}
+
+func (p *Parser) ParseBytes() (v []byte, err error) {
+	panic("objconv/hocon: ParseBytes should never be called because HOCON has no bytes type, this is likely a bug in the decoder code")
+}
+
+func (p *Parser) ParseTime() (v time.Time, err error) {
+	panic("objconv/hocon: ParseTime should never be called because HOCON has no time type, this is likely a bug in the decoder code")
+}
+
+func (p *Parser) ParseDuration() (v time.Duration, err error) {
+	panic("objconv/hocon: ParseDuration should never be called because HOCON has no duration type, this is likely a bug in the decoder code")
+}
+
+func (p *Parser) ParseError() (v error, err error) {
+	panic("objconv/hocon: ParseError should never be called because HOCON has no error type, this is likely a bug in the decoder code")
+}
+
+func (p *Parser) ParseArrayBegin() (n int, err error) {
+	if n = p.top().len(); n != 0 {
+		p.push(newParser(p.top().next()))
+	}
+	return
+}
+
+func (p *Parser) ParseArrayEnd(n int) (err error) {
+	p.pop()
+	return
+}
+
+func (p *Parser) ParseArrayNext(n int) (err error) {
+	p.push(newParser(p.top().next()))
+	return
+}
+
+func (p *Parser) ParseMapBegin() (n int, err error) {
+	if n = p.top().len(); n != 0 {
+		p.push(newParser(p.top().next()))
+	}
+	return
+}
+
+func (p *Parser) ParseMapEnd(n int) (err error) {
+	p.pop()
+	return
+}
+
+func (p *Parser) ParseMapValue(n int) (err error) {
+	p.push(newParser(p.top().next()))
+	return
+}
+
+func (p *Parser) ParseMapNext(n int) (err error) {
+	p.push(newParser(p.top().next()))
+	return
+}
+
+func (p *Parser) push(v parser) {
+	p.stack = append(p.stack, v)
+}
+
+func (p *Parser) pop() parser {
+	i := len(p.stack) - 1
+	v := p.stack[i]
+	p.stack = p.stack[:i]
+	return v
+}
+
+func (p *Parser) top() parser {
+	return p.stack[len(p.stack)-1]
+}
+
+func (p *Parser) value() interface{} {
+	n := len(p.stack)
+	if n == 0 {
+		return eof{}
+	}
+	return p.stack[n-1].value()
+}
+
+type parser interface {
+	value() interface{}
+	next() interface{}
+	len() int
+}
+
+type valueParser struct {
+	self interface{}
+}
+
+func (p *valueParser) value() interface{} {
+	return p.self
+}
+
+func (p *valueParser) next() interface{} {
+	panic("objconv/hocon: invalid call of next method on simple value parser")
+}
+
+func (p *valueParser) len() int {
+	panic("objconv/hocon: invalid call of len method on simple value parser")
+}
+
+type arrayParser struct {
+	self []interface{}
+	off  int
+}
+
+func (p *arrayParser) value() interface{} {
+	return p.self
+}
+
+func (p *arrayParser) next() interface{} {
+	v := p.self[p.off]
+	p.off++
+	return v
+}
+
+func (p *arrayParser) len() int {
+	return len(p.self)
+}
+
+type objectParser struct {
+	self *object
+	off  int
+	val  bool
+}
+
+func (p *objectParser) value() interface{} {
+	return p.self
+}
+
+func (p *objectParser) next() (v interface{}) {
+	k := p.self.keys[p.off]
+
+	if p.val {
+		v = p.self.values[k]
+		p.val = false
+		p.off++
+	} else {
+		v = k
+		p.val = true
+	}
+	return
+}
+
+func (p *objectParser) len() int {
+	return len(p.self.keys)
+}
+
+func newParser(v interface{}) parser {
+	switch x := v.(type) {
+	case *object:
+		return &objectParser{self: x}
+
+	case []interface{}:
+		return &arrayParser{self: x}
+
+	default:
+		return &valueParser{self: x}
+	}
+}
+
+// eof values are returned by the top method to indicate that all values have
+// already been consumed.
+type eof struct{}
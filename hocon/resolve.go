@@ -0,0 +1,375 @@
+package hocon
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// errCycle is returned when resolving a value which is already being resolved,
+// it is reported as an error of the substitution that led to it.
+var errCycle = errors.New("cycle")
+
+// resolver computes the final values of a document by resolving the
+// substitutions and concatenations it contains.
+type resolver struct {
+	root      *object
+	lookupEnv func(string) (string, bool)
+	active    map[interface{}]bool
+}
+
+func (r *resolver) resolveDocument(v interface{}) (interface{}, error) {
+	if o, ok := v.(*object); ok {
+		r.root = o
+	} else {
+		r.root = newObject()
+	}
+	r.active = make(map[interface{}]bool)
+	return r.resolve(v)
+}
+
+// resolve returns the final value of v, the objects it contains are modified
+// in place. The returned value is undefined if v was made of optional
+// substitutions which were not defined.
+func (r *resolver) resolve(v interface{}) (interface{}, error) {
+	switch x := v.(type) {
+	case *object:
+		for i := 0; i < len(x.keys); {
+			f, err := r.resolveField(x, x.keys[i])
+			if err != nil {
+				return nil, err
+			}
+			if _, isUndefined := f.(undefined); !isUndefined {
+				i++
+			}
+		}
+		return x, nil
+
+	case []interface{}:
+		a := make([]interface{}, 0, len(x))
+
+		for _, e := range x {
+			e, err := r.resolve(e)
+			if err != nil {
+				return nil, err
+			}
+			if _, isUndefined := e.(undefined); !isUndefined {
+				a = append(a, e)
+			}
+		}
+
+		return a, nil
+
+	case *concat:
+		return r.resolveConcat(x)
+
+	case *overlay:
+		return r.resolveOverlay(x)
+
+	case unquoted:
+		return string(x), nil
+
+	default:
+		return v, nil
+	}
+}
+
+// resolveField resolves the value of the field k of o, and replaces it with
+// the result, undefined fields are removed from o.
+func (r *resolver) resolveField(o *object, k string) (interface{}, error) {
+	v, err := r.resolve(o.values[k])
+	if err != nil {
+		return nil, err
+	}
+
+	if _, isUndefined := v.(undefined); isUndefined {
+		o.remove(k)
+	} else {
+		o.values[k] = v
+	}
+
+	return v, nil
+}
+
+func (r *resolver) resolveConcat(c *concat) (interface{}, error) {
+	if r.active[c] {
+		return nil, errCycle
+	}
+
+	r.active[c] = true
+	defer delete(r.active, c)
+
+	values := make([]interface{}, 0, len(c.pieces))
+
+	for _, p := range c.pieces {
+		var v interface{}
+		var err error
+
+		switch x := p.(type) {
+		case *substitution:
+			v, err = r.resolveSubstitution(x)
+		case space, unquoted:
+			v = x
+		default:
+			v, err = r.resolve(x)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if _, isUndefined := v.(undefined); !isUndefined {
+			values = append(values, v)
+		}
+	}
+
+	return concatenate(c, values)
+}
+
+func (r *resolver) resolveOverlay(x *overlay) (interface{}, error) {
+	if r.active[x] {
+		return nil, errCycle
+	}
+
+	r.active[x] = true
+	defer delete(r.active, x)
+
+	v, err := r.resolve(x.value)
+	if err != nil {
+		return nil, err
+	}
+
+	switch o := v.(type) {
+	case undefined:
+		return r.resolve(x.base)
+
+	case *object:
+		b, err := r.resolve(x.base)
+		if err != nil {
+			return nil, err
+		}
+		if base, ok := b.(*object); ok {
+			return mergeObjects(base, o), nil
+		}
+	}
+
+	return v, nil
+}
+
+func (r *resolver) resolveSubstitution(s *substitution) (v interface{}, err error) {
+	var found bool
+
+	switch {
+	case !s.self:
+		v, found, err = r.lookup(s.path)
+
+	case s.hasPrior:
+		if v, err = r.resolve(s.prior); err == nil {
+			_, isUndefined := v.(undefined)
+			found = !isUndefined
+		}
+	}
+
+	switch {
+	case err == errCycle:
+		return nil, fmt.Errorf("objconv/hocon: line %d: the substitution %s is part of a cycle", s.line, s)
+
+	case err != nil:
+		return nil, err
+
+	case found:
+		return v, nil
+	}
+
+	if env, ok := r.getenv(strings.Join(s.path, ".")); ok {
+		return env, nil
+	}
+
+	if s.optional {
+		return undefined{}, nil
+	}
+
+	return nil, fmt.Errorf("objconv/hocon: line %d: undefined substitution %s", s.line, s)
+}
+
+// lookup returns the value at path in the document. Only the values on the
+// path are resolved, so the siblings of the fields that the path goes through
+// may refer to the value being looked up.
+func (r *resolver) lookup(path []string) (v interface{}, found bool, err error) {
+	v = r.root
+
+	for i, k := range path {
+		o, ok := v.(*object)
+		if !ok {
+			return nil, false, nil
+		}
+
+		if v, ok = o.get(k); !ok {
+			return nil, false, nil
+		}
+
+		switch v.(type) {
+		case *concat, *overlay, unquoted:
+		default:
+			if i != len(path)-1 {
+				continue
+			}
+		}
+
+		if v, err = r.resolveField(o, k); err != nil {
+			return nil, false, err
+		}
+
+		if _, isUndefined := v.(undefined); isUndefined {
+			return nil, false, nil
+		}
+	}
+
+	return v, true, nil
+}
+
+func (r *resolver) getenv(name string) (string, bool) {
+	if r.lookupEnv != nil {
+		return r.lookupEnv(name)
+	}
+	return os.LookupEnv(name)
+}
+
+// concatenate combines the resolved values of the pieces of c, objects are
+// merged, arrays are concatenated, and other values are concatenated as
+// strings with the whitespace that separates them.
+func concatenate(c *concat, values []interface{}) (interface{}, error) {
+	for len(values) != 0 && isSpace(values[0]) {
+		values = values[1:]
+	}
+
+	for len(values) != 0 && isSpace(values[len(values)-1]) {
+		values = values[:len(values)-1]
+	}
+
+	switch len(values) {
+	case 0:
+		return undefined{}, nil
+	case 1:
+		if s, ok := values[0].(unquoted); ok {
+			return string(s), nil
+		}
+		return values[0], nil
+	}
+
+	var kind string
+
+	for _, v := range values {
+		if isSpace(v) {
+			continue
+		}
+		switch k := kindOf(v); {
+		case len(kind) == 0:
+			kind = k
+		case k != kind:
+			return nil, fmt.Errorf("objconv/hocon: line %d: cannot concatenate %s and %s", c.line, article(kind), article(k))
+		}
+	}
+
+	switch kind {
+	case "object":
+		var o *object
+
+		for _, v := range values {
+			if x, ok := v.(*object); ok {
+				if o == nil {
+					o = x
+				} else {
+					o = mergeObjects(o, x)
+				}
+			}
+		}
+
+		return o, nil
+
+	case "array":
+		a := []interface{}{}
+
+		for _, v := range values {
+			if x, ok := v.([]interface{}); ok {
+				a = append(a, x...)
+			}
+		}
+
+		return a, nil
+
+	default:
+		var b []byte
+
+		for _, v := range values {
+			switch x := v.(type) {
+			case space:
+				b = append(b, x...)
+			case unquoted:
+				b = append(b, x...)
+			case string:
+				b = append(b, x...)
+			case int64:
+				b = strconv.AppendInt(b, x, 10)
+			case float64:
+				b = strconv.AppendFloat(b, x, 'g', -1, 64)
+			case bool:
+				b = strconv.AppendBool(b, x)
+			case nil:
+				b = append(b, "null"...)
+			}
+		}
+
+		return string(b), nil
+	}
+}
+
+// mergeObjects returns a new object with the fields of a and b, the fields of
+// b replace those of a unless both are objects, in which case they are merged.
+// Neither a nor b are modified.
+func mergeObjects(a *object, b *object) *object {
+	o := newObject()
+
+	for _, k := range a.keys {
+		o.set(k, a.values[k])
+	}
+
+	for _, k := range b.keys {
+		v := b.values[k]
+
+		if bo, ok := v.(*object); ok {
+			if ao, ok := o.values[k].(*object); ok {
+				v = mergeObjects(ao, bo)
+			}
+		}
+
+		o.set(k, v)
+	}
+
+	return o
+}
+
+func isSpace(v interface{}) bool {
+	_, ok := v.(space)
+	return ok
+}
+
+func kindOf(v interface{}) string {
+	switch v.(type) {
+	case *object:
+		return "object"
+	case []interface{}:
+		return "array"
+	default:
+		return "string"
+	}
+}
+
+func article(kind string) string {
+	if kind == "object" || kind == "array" {
+		return "an " + kind
+	}
+	return "a " + kind
+}
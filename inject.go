@@ -0,0 +1,237 @@
+package objconv
+
+import (
+	"sort"
+	"time"
+)
+
+// InjectingParser is a parser which wraps another parser and injects synthetic
+// key/value pairs in the maps found at a specific nesting level, as if they
+// were part of the input.
+//
+// The injected entries are appended after the last entry of each map, fields
+// that already exist in the input are never overwritten.
+type InjectingParser struct {
+	// Parser is the underlying parser that the values are read from.
+	Parser Parser
+
+	// Fields is the set of key/value pairs injected into the maps.
+	Fields map[string]interface{}
+
+	// Depth is the nesting level of the maps where fields are injected, zero
+	// means only top-level maps receive the fields.
+	Depth int
+
+	depth int // nesting level of the underlying parser
+
+	// state of the map currently receiving the injected fields
+	inMap  bool
+	getKey bool
+	size   int
+	count  int
+	seen   map[string]bool
+	keys   []string
+
+	// parser of the key or value being injected, and its own nesting level
+	cur      Parser
+	curDepth int
+}
+
+// NewInjectingParser returns a new parser which reads values from p and injects
+// fields into top-level maps.
+func NewInjectingParser(p Parser, fields map[string]interface{}) *InjectingParser {
+	return &InjectingParser{
+		Parser: p,
+		Fields: fields,
+	}
+}
+
+func (p *InjectingParser) parser() Parser {
+	if p.cur != nil {
+		return p.cur
+	}
+	return p.Parser
+}
+
+func (p *InjectingParser) ParseType() (Type, error) { return p.parser().ParseType() }
+
+func (p *InjectingParser) ParseNil() error { return p.parser().ParseNil() }
+
+func (p *InjectingParser) ParseBool() (bool, error) { return p.parser().ParseBool() }
+
+func (p *InjectingParser) ParseInt() (int64, error) { return p.parser().ParseInt() }
+
+func (p *InjectingParser) ParseUint() (uint64, error) { return p.parser().ParseUint() }
+
+func (p *InjectingParser) ParseFloat() (float64, error) { return p.parser().ParseFloat() }
+
+func (p *InjectingParser) ParseString() ([]byte, error) { return p.parseKey(p.parser().ParseString()) }
+
+func (p *InjectingParser) ParseBytes() ([]byte, error) { return p.parseKey(p.parser().ParseBytes()) }
+
+func (p *InjectingParser) ParseTime() (time.Time, error) { return p.parser().ParseTime() }
+
+func (p *InjectingParser) ParseDuration() (time.Duration, error) { return p.parser().ParseDuration() }
+
+func (p *InjectingParser) ParseError() (error, error) { return p.parser().ParseError() }
+
+func (p *InjectingParser) ParseArrayBegin() (n int, err error) {
+	if p.cur != nil {
+		p.curDepth++
+		return p.cur.ParseArrayBegin()
+	}
+	p.getKey = false
+	if n, err = p.Parser.ParseArrayBegin(); err == nil {
+		p.depth++
+	}
+	return
+}
+
+func (p *InjectingParser) ParseArrayEnd(n int) (err error) {
+	if p.cur != nil {
+		p.curDepth--
+		return p.cur.ParseArrayEnd(n)
+	}
+	if err = p.Parser.ParseArrayEnd(n); err == nil {
+		p.depth--
+	}
+	return
+}
+
+func (p *InjectingParser) ParseArrayNext(n int) error {
+	return p.parser().ParseArrayNext(n)
+}
+
+func (p *InjectingParser) ParseMapBegin() (n int, err error) {
+	if p.cur != nil {
+		p.curDepth++
+		return p.cur.ParseMapBegin()
+	}
+
+	p.getKey = false
+
+	if n, err = p.Parser.ParseMapBegin(); err != nil {
+		return
+	}
+
+	if p.depth++; p.depth != (p.Depth + 1) {
+		return
+	}
+
+	// This is a map that will receive the injected fields, the length is
+	// reported as unknown so the decoder keeps asking for more entries after
+	// the last one was read from the underlying parser.
+	p.inMap = true
+	p.size = n
+	p.count = -1
+	p.seen = make(map[string]bool)
+	p.keys = nil
+	n = -1
+	return
+}
+
+func (p *InjectingParser) ParseMapEnd(n int) (err error) {
+	if p.cur != nil && p.curDepth != 0 {
+		p.curDepth--
+		return p.cur.ParseMapEnd(n)
+	}
+
+	if p.inMap && p.depth == (p.Depth+1) {
+		n = p.count
+		p.inMap = false
+		p.cur = nil
+		p.seen = nil
+		p.keys = nil
+	}
+
+	if err = p.Parser.ParseMapEnd(n); err == nil {
+		p.depth--
+	}
+	return
+}
+
+func (p *InjectingParser) ParseMapValue(n int) error {
+	if p.cur != nil && p.curDepth != 0 {
+		return p.cur.ParseMapValue(n)
+	}
+
+	if p.count >= 0 && p.inMap && p.depth == (p.Depth+1) {
+		// Injecting entries, switch from the key to the value.
+		p.cur = NewValueParser(p.Fields[p.keys[n-p.count]])
+		return nil
+	}
+
+	p.getKey = false
+	return p.Parser.ParseMapValue(n)
+}
+
+func (p *InjectingParser) ParseMapNext(n int) (err error) {
+	if p.cur != nil && p.curDepth != 0 {
+		return p.cur.ParseMapNext(n)
+	}
+
+	if !p.inMap || p.depth != (p.Depth+1) {
+		return p.Parser.ParseMapNext(n)
+	}
+
+	if p.count < 0 {
+		switch {
+		case p.size < 0:
+			err = p.Parser.ParseMapNext(n)
+		case n == p.size:
+			err = End
+		case n != 0:
+			err = p.Parser.ParseMapNext(n)
+		}
+
+		if err == nil {
+			p.getKey = true
+			return
+		}
+
+		if err != End {
+			return
+		}
+
+		// The underlying map has no more entries, start injecting the fields
+		// that weren't found in the input.
+		p.count = n
+		p.keys = p.missingKeys()
+	}
+
+	if i := n - p.count; i < len(p.keys) {
+		p.cur = NewValueParser(p.keys[i])
+		return nil
+	}
+
+	p.cur = nil
+	return End
+}
+
+func (p *InjectingParser) parseKey(b []byte, err error) ([]byte, error) {
+	if err == nil && p.getKey && p.cur == nil {
+		p.getKey = false
+		p.seen[string(b)] = true
+	}
+	return b, err
+}
+
+func (p *InjectingParser) missingKeys() []string {
+	keys := make([]string, 0, len(p.Fields))
+
+	for k := range p.Fields {
+		if !p.seen[k] {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+func (p *InjectingParser) DecodeBytes(b []byte) ([]byte, error) {
+	if d, ok := p.Parser.(bytesDecoder); ok && p.cur == nil {
+		return d.DecodeBytes(b)
+	}
+	return b, nil
+}
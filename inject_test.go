@@ -0,0 +1,98 @@
+package objconv
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInjectingParser(t *testing.T) {
+	fields := map[string]interface{}{
+		"source": "test",
+		"tags":   []interface{}{"A", "B"},
+	}
+
+	tests := []struct {
+		name  string
+		in    interface{}
+		depth int
+		out   interface{}
+	}{
+		{
+			name: "empty map",
+			in:   map[string]interface{}{},
+			out: map[interface{}]interface{}{
+				"source": "test",
+				"tags":   []interface{}{"A", "B"},
+			},
+		},
+		{
+			name: "real keys are not clobbered",
+			in:   map[string]interface{}{"source": "input", "value": 42},
+			out: map[interface{}]interface{}{
+				"source": "input",
+				"value":  int64(42),
+				"tags":   []interface{}{"A", "B"},
+			},
+		},
+		{
+			name: "nested maps are left untouched",
+			in:   map[string]interface{}{"sub": map[string]interface{}{}},
+			out: map[interface{}]interface{}{
+				"sub":    map[interface{}]interface{}{},
+				"source": "test",
+				"tags":   []interface{}{"A", "B"},
+			},
+		},
+		{
+			name:  "inject at depth 1",
+			in:    []interface{}{map[string]interface{}{"value": 1}, map[string]interface{}{}},
+			depth: 1,
+			out: []interface{}{
+				map[interface{}]interface{}{"value": int64(1), "source": "test", "tags": []interface{}{"A", "B"}},
+				map[interface{}]interface{}{"source": "test", "tags": []interface{}{"A", "B"}},
+			},
+		},
+		{
+			name: "non-map values are left untouched",
+			in:   []interface{}{int64(1), int64(2)},
+			out:  []interface{}{int64(1), int64(2)},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := NewInjectingParser(NewValueParser(test.in), fields)
+			p.Depth = test.depth
+
+			var v interface{}
+
+			if err := NewDecoder(p).Decode(&v); err != nil {
+				t.Error(err)
+			}
+
+			if !reflect.DeepEqual(v, test.out) {
+				t.Errorf("%#v != %#v", v, test.out)
+			}
+		})
+	}
+}
+
+func TestInjectingParserStruct(t *testing.T) {
+	type T struct {
+		Value  int    `objconv:"value"`
+		Source string `objconv:"source"`
+	}
+
+	var v T
+	p := NewInjectingParser(NewValueParser(map[string]interface{}{"value": 42}), map[string]interface{}{
+		"source": "test",
+	})
+
+	if err := NewDecoder(p).Decode(&v); err != nil {
+		t.Error(err)
+	}
+
+	if v != (T{Value: 42, Source: "test"}) {
+		t.Errorf("%#v", v)
+	}
+}
@@ -16,6 +16,7 @@ import (
 	"io/ioutil"
 	"os"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"testing"
@@ -194,3 +195,40 @@ func BenchmarkIssue10335(b *testing.B) {
 		}
 	}
 }
+
+type benchFloat float64
+
+func BenchmarkUnmarshalNumericSlice(b *testing.B) {
+	buf := &bytes.Buffer{}
+	buf.WriteByte('[')
+	for i := 0; i != 100000; i++ {
+		if i != 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(strconv.FormatFloat(float64(i)/2, 'g', -1, 64))
+	}
+	buf.WriteByte(']')
+	data := buf.Bytes()
+
+	b.Run("fast", func(b *testing.B) {
+		out := make([]float64, 0, 100000)
+		b.SetBytes(int64(len(data)))
+
+		for i := 0; i != b.N; i++ {
+			if err := Unmarshal(data, &out); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("generic", func(b *testing.B) {
+		out := make([]benchFloat, 0, 100000)
+		b.SetBytes(int64(len(data)))
+
+		for i := 0; i != b.N; i++ {
+			if err := Unmarshal(data, &out); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
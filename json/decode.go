@@ -10,12 +10,12 @@ import (
 
 // NewDecoder returns a new JSON decoder that parses values from r.
 func NewDecoder(r io.Reader) *objconv.Decoder {
-	return objconv.NewDecoder(NewParser(r))
+	return Codec.NewDecoder(r)
 }
 
 // NewStreamDecoder returns a new JSON stream decoder that parses values from r.
 func NewStreamDecoder(r io.Reader) *objconv.StreamDecoder {
-	return objconv.NewStreamDecoder(NewParser(r))
+	return Codec.NewStreamDecoder(r)
 }
 
 // Unmarshal decodes a JSON representation of v from b.
@@ -2,6 +2,8 @@ package json
 
 import (
 	"encoding/base64"
+	"errors"
+	"fmt"
 	"io"
 	"strconv"
 	"time"
@@ -24,16 +26,20 @@ var (
 	comma  = [...]byte{','}
 	column = [...]byte{':'}
 
-	newline = [...]byte{'\n'}
-	spaces  = [...]byte{' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' '}
+	space = [...]byte{' '}
 )
 
 // Emitter implements a JSON emitter that satisfies the objconv.Emitter
 // interface.
+//
+// JSON objects only have string keys, map keys of other scalar types are
+// written as strings (for example 1 is written "1"), and emitting an array or
+// a map as a key returns an error.
 type Emitter struct {
-	w io.Writer
-	s []byte
-	a [128]byte
+	w   io.Writer
+	s   []byte
+	a   [128]byte
+	key bool // whether the next value is a map key
 }
 
 func NewEmitter(w io.Writer) *Emitter {
@@ -44,34 +50,58 @@ func NewEmitter(w io.Writer) *Emitter {
 
 func (e *Emitter) Reset(w io.Writer) {
 	e.w = w
+	e.key = false
 }
 
 func (e *Emitter) EmitNil() (err error) {
-	_, err = e.w.Write(nullBytes[:])
-	return
+	return e.writeScalar(append(e.scalarBegin(), nullBytes[:]...))
 }
 
 func (e *Emitter) EmitBool(v bool) (err error) {
 	if v {
-		_, err = e.w.Write(trueBytes[:])
-	} else {
-		_, err = e.w.Write(falseBytes[:])
+		return e.writeScalar(append(e.scalarBegin(), trueBytes[:]...))
 	}
-	return
+	return e.writeScalar(append(e.scalarBegin(), falseBytes[:]...))
 }
 
 func (e *Emitter) EmitInt(v int64, _ int) (err error) {
-	_, err = e.w.Write(strconv.AppendInt(e.s[:0], v, 10))
-	return
+	return e.writeScalar(strconv.AppendInt(e.scalarBegin(), v, 10))
 }
 
 func (e *Emitter) EmitUint(v uint64, _ int) (err error) {
-	_, err = e.w.Write(strconv.AppendUint(e.s[:0], v, 10))
-	return
+	return e.writeScalar(strconv.AppendUint(e.scalarBegin(), v, 10))
 }
 
 func (e *Emitter) EmitFloat(v float64, bitSize int) (err error) {
-	_, err = e.w.Write(strconv.AppendFloat(e.s[:0], v, 'g', -1, bitSize))
+	return e.writeScalar(strconv.AppendFloat(e.scalarBegin(), v, 'g', -1, bitSize))
+}
+
+// EmitNumber writes v as-is, it is used to encode objconv.Number values without
+// loss of precision.
+func (e *Emitter) EmitNumber(v string) (err error) {
+	if !isNumber(v) {
+		return fmt.Errorf("objconv/json: invalid number %q", v)
+	}
+	return e.writeScalar(append(e.scalarBegin(), v...))
+}
+
+// scalarBegin returns the buffer used to format scalar values which aren't
+// strings, with an opening quote if the value is a map key.
+func (e *Emitter) scalarBegin() []byte {
+	if e.key {
+		return append(e.s[:0], '"')
+	}
+	return e.s[:0]
+}
+
+// writeScalar writes a scalar value formatted in a buffer returned by
+// scalarBegin, closing the quote of map keys.
+func (e *Emitter) writeScalar(s []byte) (err error) {
+	if e.key {
+		s = append(s, '"')
+	}
+	e.s = s[:0] // in case the buffer was reallocated
+	_, err = e.w.Write(s)
 	return
 }
 
@@ -168,6 +198,9 @@ func (e *Emitter) EmitError(v error) (err error) {
 }
 
 func (e *Emitter) EmitArrayBegin(_ int) (err error) {
+	if e.key {
+		return errors.New("objconv/json: cannot emit an array as a map key, keys must be strings or scalar values")
+	}
 	_, err = e.w.Write(arrayOpen[:])
 	return
 }
@@ -183,22 +216,29 @@ func (e *Emitter) EmitArrayNext() (err error) {
 }
 
 func (e *Emitter) EmitMapBegin(_ int) (err error) {
+	if e.key {
+		return errors.New("objconv/json: cannot emit a map as a map key, keys must be strings or scalar values")
+	}
 	_, err = e.w.Write(mapOpen[:])
+	e.key = true
 	return
 }
 
 func (e *Emitter) EmitMapEnd() (err error) {
 	_, err = e.w.Write(mapClose[:])
+	e.key = false
 	return
 }
 
 func (e *Emitter) EmitMapValue() (err error) {
 	_, err = e.w.Write(column[:])
+	e.key = false
 	return
 }
 
 func (e *Emitter) EmitMapNext() (err error) {
 	_, err = e.w.Write(comma[:])
+	e.key = true
 	return
 }
 
@@ -213,42 +253,213 @@ func align(n int, a int) int {
 	return ((n / a) + 1) * a
 }
 
+// isNumber returns true if s is a number in the JSON syntax.
+func isNumber(s string) bool {
+	digits := func(i int) int {
+		j := i
+		for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+			j++
+		}
+		return j - i
+	}
+
+	i := 0
+
+	if i < len(s) && s[i] == '-' {
+		i++
+	}
+
+	switch n := digits(i); {
+	case n == 0, n > 1 && s[i] == '0':
+		return false
+	default:
+		i += n
+	}
+
+	if i < len(s) && s[i] == '.' {
+		n := digits(i + 1)
+		if n == 0 {
+			return false
+		}
+		i += n + 1
+	}
+
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		if i++; i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		n := digits(i)
+		if n == 0 {
+			return false
+		}
+		i += n
+	}
+
+	return i == len(s)
+}
+
+// EmitterConfig carries the configuration of pretty JSON emitters, see
+// NewPrettyEmitterWith.
+type EmitterConfig struct {
+	// Prefix is written at the beginning of each line of the output, except
+	// the first one.
+	Prefix string
+
+	// Indent is written after the prefix once per level of nesting of the
+	// array element or map entry that starts the line.
+	Indent string
+
+	// NewlineArrays enables writing the opening brackets of arrays that are
+	// map values on their own lines, aligned with the closing brackets, so the
+	// brackets and each element of arrays are all on separate lines. Empty
+	// arrays are still written as [] after the colon.
+	NewlineArrays bool
+}
+
+// PrettyEmitter is a JSON emitter which writes the elements of arrays and maps
+// on separate indented lines, like json.MarshalIndent does. Empty arrays and
+// maps are written as [] and {}.
 type PrettyEmitter struct {
 	Emitter
-	i int
-	s []int
-	a [8]int
+	line    []byte // newline, prefix, and the indentation of the deepest level seen
+	size    int    // length of the prefix of line, without indentation
+	step    int    // length of the indentation of one level
+	depth   int
+	open    bool // whether the newline before the first element of a container is pending
+	value   bool // whether the space after the colon of a map value is pending
+	lines   bool // whether arrays that are map values start on a new line, see NewlineArrays
+	bracket bool // whether the opening bracket of an array that is a map value is pending
+}
+
+// NewPrettyEmitter returns a new pretty emitter that writes to w, indenting
+// nested values with two spaces.
+func NewPrettyEmitter(w io.Writer) *PrettyEmitter {
+	return NewPrettyEmitterWith(w, EmitterConfig{Indent: "  "})
 }
 
-func NewPrettyEmitter(w io.Writer) *PrettyEmitter {
+// NewPrettyEmitterWith returns a new pretty emitter that writes to w, using
+// the prefix and indentation of config.
+func NewPrettyEmitterWith(w io.Writer, config EmitterConfig) *PrettyEmitter {
 	e := &PrettyEmitter{
 		Emitter: *NewEmitter(w),
+		line:    make([]byte, 0, 1+len(config.Prefix)+4*len(config.Indent)),
+		size:    1 + len(config.Prefix),
+		step:    len(config.Indent),
+		lines:   config.NewlineArrays,
 	}
-	e.s = e.a[:0]
+	e.line = append(e.line, '\n')
+	e.line = append(e.line, config.Prefix...)
+	e.line = append(e.line, config.Indent...)
 	return e
 }
 
 func (e *PrettyEmitter) Reset(w io.Writer) {
 	e.Emitter.Reset(w)
-	e.i = 0
-	e.s = e.s[:0]
+	e.depth = 0
+	e.open = false
+	e.value = false
+	e.bracket = false
+}
+
+func (e *PrettyEmitter) EmitNil() (err error) {
+	if err = e.elem(); err != nil {
+		return
+	}
+	return e.Emitter.EmitNil()
+}
+
+func (e *PrettyEmitter) EmitBool(v bool) (err error) {
+	if err = e.elem(); err != nil {
+		return
+	}
+	return e.Emitter.EmitBool(v)
+}
+
+func (e *PrettyEmitter) EmitInt(v int64, bitSize int) (err error) {
+	if err = e.elem(); err != nil {
+		return
+	}
+	return e.Emitter.EmitInt(v, bitSize)
+}
+
+func (e *PrettyEmitter) EmitUint(v uint64, bitSize int) (err error) {
+	if err = e.elem(); err != nil {
+		return
+	}
+	return e.Emitter.EmitUint(v, bitSize)
+}
+
+func (e *PrettyEmitter) EmitFloat(v float64, bitSize int) (err error) {
+	if err = e.elem(); err != nil {
+		return
+	}
+	return e.Emitter.EmitFloat(v, bitSize)
+}
+
+func (e *PrettyEmitter) EmitNumber(v string) (err error) {
+	if err = e.elem(); err != nil {
+		return
+	}
+	return e.Emitter.EmitNumber(v)
+}
+
+func (e *PrettyEmitter) EmitString(v string) (err error) {
+	if err = e.elem(); err != nil {
+		return
+	}
+	return e.Emitter.EmitString(v)
+}
+
+func (e *PrettyEmitter) EmitBytes(v []byte) (err error) {
+	if err = e.elem(); err != nil {
+		return
+	}
+	return e.Emitter.EmitBytes(v)
+}
+
+func (e *PrettyEmitter) EmitTime(v time.Time) (err error) {
+	if err = e.elem(); err != nil {
+		return
+	}
+	return e.Emitter.EmitTime(v)
+}
+
+func (e *PrettyEmitter) EmitDuration(v time.Duration) (err error) {
+	if err = e.elem(); err != nil {
+		return
+	}
+	return e.Emitter.EmitDuration(v)
+}
+
+func (e *PrettyEmitter) EmitError(v error) (err error) {
+	if err = e.elem(); err != nil {
+		return
+	}
+	return e.Emitter.EmitError(v)
 }
 
 func (e *PrettyEmitter) EmitArrayBegin(n int) (err error) {
-	if err = e.Emitter.EmitArrayBegin(n); err != nil {
+	if e.lines && e.value {
+		// Whether the bracket goes on a new line depends on the array having
+		// elements, it is written by elem or pop.
+		e.value = false
+		e.bracket = true
+		e.push()
 		return
 	}
-	if e.push(n) != 0 {
-		err = e.indent()
+	if err = e.elem(); err != nil {
+		return
 	}
+	if err = e.Emitter.EmitArrayBegin(n); err != nil {
+		return
+	}
+	e.push()
 	return
 }
 
 func (e *PrettyEmitter) EmitArrayEnd() (err error) {
-	if e.pop() != 0 {
-		if err = e.indent(); err != nil {
-			return
-		}
+	if err = e.pop(); err != nil {
+		return
 	}
 	return e.Emitter.EmitArrayEnd()
 }
@@ -257,24 +468,23 @@ func (e *PrettyEmitter) EmitArrayNext() (err error) {
 	if err = e.Emitter.EmitArrayNext(); err != nil {
 		return
 	}
-	return e.indent()
+	return e.newline()
 }
 
 func (e *PrettyEmitter) EmitMapBegin(n int) (err error) {
-	if err = e.Emitter.EmitMapBegin(n); err != nil {
+	if err = e.elem(); err != nil {
 		return
 	}
-	if e.push(n) != 0 {
-		err = e.indent()
+	if err = e.Emitter.EmitMapBegin(n); err != nil {
+		return
 	}
+	e.push()
 	return
 }
 
 func (e *PrettyEmitter) EmitMapEnd() (err error) {
-	if e.pop() != 0 {
-		if err = e.indent(); err != nil {
-			return
-		}
+	if err = e.pop(); err != nil {
+		return
 	}
 	return e.Emitter.EmitMapEnd()
 }
@@ -283,7 +493,7 @@ func (e *PrettyEmitter) EmitMapValue() (err error) {
 	if err = e.Emitter.EmitMapValue(); err != nil {
 		return
 	}
-	_, err = e.w.Write(spaces[:1])
+	e.value = true
 	return
 }
 
@@ -291,46 +501,73 @@ func (e *PrettyEmitter) EmitMapNext() (err error) {
 	if err = e.Emitter.EmitMapNext(); err != nil {
 		return
 	}
-	return e.indent()
-}
-
-func (e *PrettyEmitter) indent() (err error) {
-	if _, err = e.w.Write(newline[:]); err != nil {
-		return
+	return e.newline()
+}
+
+// elem writes the space after the colon of a map value, or the newline before
+// the first element of the array or map that was just opened. The newline is
+// delayed until then because the length of containers isn't always known when
+// they are opened, and empty ones are written without line breaks. For the same
+// reason, the opening bracket of arrays that start on a new line because of
+// NewlineArrays is only written here, or by pop when the array is empty.
+func (e *PrettyEmitter) elem() error {
+	if e.value {
+		e.value = false
+		_, err := e.w.Write(space[:])
+		return err
 	}
-
-	for n := 2 * e.i; n != 0; {
-		n1 := n
-		n2 := len(spaces)
-
-		if n1 > n2 {
-			n1 = n2
+	if !e.open {
+		return nil
+	}
+	e.open = false
+	if e.bracket {
+		e.bracket = false
+		e.depth--
+		err := e.newline()
+		e.depth++
+		if err != nil {
+			return err
 		}
-
-		if _, err = e.w.Write(spaces[:n1]); err != nil {
-			return
+		if _, err = e.w.Write(arrayOpen[:]); err != nil {
+			return err
 		}
-
-		n -= n1
 	}
+	return e.newline()
+}
 
-	return
+func (e *PrettyEmitter) push() {
+	e.depth++
+	e.open = true
 }
 
-func (e *PrettyEmitter) push(n int) int {
-	if n != 0 {
-		e.i++
+func (e *PrettyEmitter) pop() error {
+	e.depth--
+
+	if e.open {
+		e.open = false
+		if e.bracket {
+			e.bracket = false
+			if _, err := e.w.Write(space[:]); err != nil {
+				return err
+			}
+			_, err := e.w.Write(arrayOpen[:])
+			return err
+		}
+		return nil
 	}
-	e.s = append(e.s, n)
-	return n
+
+	return e.newline()
 }
 
-func (e *PrettyEmitter) pop() int {
-	i := len(e.s) - 1
-	n := e.s[i]
-	e.s = e.s[:i]
-	if n != 0 {
-		e.i--
+// newline writes a line break followed by the prefix and the indentation of
+// the current depth.
+func (e *PrettyEmitter) newline() (err error) {
+	n := e.size + e.depth*e.step
+
+	for len(e.line) < n {
+		e.line = append(e.line, e.line[e.size:e.size+e.step]...)
 	}
-	return n
+
+	_, err = e.w.Write(e.line[:n])
+	return
 }
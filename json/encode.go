@@ -32,6 +32,7 @@ func NewPrettyStreamEncoder(w io.Writer) *objconv.StreamEncoder {
 func Marshal(v interface{}) (b []byte, err error) {
 	m := marshalerPool.Get().(*marshaler)
 	m.b.Truncate(0)
+	m.key = false
 
 	if err = (objconv.Encoder{Emitter: m}).Encode(v); err == nil {
 		b = make([]byte, m.b.Len())
@@ -1,8 +1,21 @@
 package json
 
 import (
+	"bytes"
+	"io"
+	"math"
+	"math/big"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
 
+	"github.com/segmentio/objconv"
+	_ "github.com/segmentio/objconv/adapters/math/big"
+	_ "github.com/segmentio/objconv/msgpack"
 	"github.com/segmentio/objconv/objtests"
 )
 
@@ -45,3 +58,1140 @@ func TestUnicode(t *testing.T) {
 		})
 	}
 }
+
+func TestDecodeWithRaw(t *testing.T) {
+	d := NewDecoder(strings.NewReader(` {"A": 1, "B": [1, 2]}  "Hello" 42`))
+
+	tests := []struct {
+		raw string
+		val interface{}
+	}{
+		{`{"A": 1, "B": [1, 2]}`, map[interface{}]interface{}{"A": int64(1), "B": []interface{}{int64(1), int64(2)}}},
+		{`"Hello"`, "Hello"},
+		{`42`, int64(42)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.raw, func(t *testing.T) {
+			var v interface{}
+
+			raw, err := d.DecodeWithRaw(&v)
+			if err != nil {
+				t.Error(err)
+			}
+
+			if string(raw) != test.raw {
+				t.Errorf("bad raw bytes: %q", raw)
+			}
+
+			if !reflect.DeepEqual(v, test.val) {
+				t.Errorf("%#v", v)
+			}
+		})
+	}
+}
+
+func TestDecodeRemaining(t *testing.T) {
+	long := strings.Repeat("x", 300)
+
+	tests := []struct {
+		in   string
+		val  interface{}
+		rest string
+	}{
+		{`42`, int64(42), ``},
+		{"42 \n", int64(42), " \n"},
+		{" {\"A\": [1, 2]}\nmetadata", map[interface{}]interface{}{"A": []interface{}{int64(1), int64(2)}}, "\nmetadata"},
+		{`"Hello"` + long, "Hello", long},
+		{`"` + long + `"` + long, long, long},
+	}
+
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			var v interface{}
+
+			rest, err := NewDecoder(strings.NewReader(test.in)).DecodeRemaining(&v)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(rest) != test.rest {
+				t.Errorf("bad remaining bytes: %q", rest)
+			}
+
+			if !reflect.DeepEqual(v, test.val) {
+				t.Errorf("%#v", v)
+			}
+		})
+	}
+
+	t.Run("stream", func(t *testing.T) {
+		var v interface{}
+
+		d := NewDecoder(iotest.OneByteReader(strings.NewReader(`1 2`)))
+
+		if _, err := d.DecodeRemaining(&v); err == nil {
+			t.Error("expected an error returning the remaining bytes of a stream")
+		}
+
+		if err := d.Decode(&v); err != nil || v != int64(1) {
+			t.Error("the stream was consumed:", v, err)
+		}
+	})
+}
+
+func TestDecodeTee(t *testing.T) {
+	tee := &bytes.Buffer{}
+	d := NewDecoder(strings.NewReader(` {"A": 1}  "Hello" [1, "x"] 42`))
+	d.Tee = tee
+
+	var m map[string]int
+	if err := d.Decode(&m); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := tee.String(); s != `{"A": 1}` {
+		t.Errorf("the tee doesn't contain the bytes of the first value: %q", s)
+	}
+
+	var s string
+	if err := d.Decode(&s); err != nil {
+		t.Fatal(err)
+	}
+
+	var a []int
+	if err := d.Decode(&a); err == nil {
+		t.Error("expected an error decoding a string into an int")
+	}
+
+	if s := tee.String(); s != `{"A": 1}"Hello"[1, ` {
+		t.Errorf("the tee doesn't contain the bytes consumed up to the error: %q", s)
+	}
+
+	tee.Reset()
+	sd := NewStreamDecoder(strings.NewReader(`[1, 2 ,3]`))
+	sd.Tee = tee
+
+	for {
+		var v int
+		if sd.Decode(&v) != nil {
+			break
+		}
+		tee.WriteByte('\n')
+	}
+
+	if err := sd.Err(); err != nil {
+		t.Error(err)
+	}
+
+	if s := tee.String(); s != "1\n2\n3\n" {
+		t.Errorf("the tee doesn't contain the bytes of each element: %q", s)
+	}
+}
+
+func TestFieldSpans(t *testing.T) {
+	type point struct {
+		X int `objconv:"x"`
+		Y int `objconv:"y"`
+	}
+
+	type shape struct {
+		Name   string                 `objconv:"name"`
+		Points []point                `objconv:"points"`
+		Tags   map[string]interface{} `objconv:"tags"`
+	}
+
+	in := `{ "name" : "` + strings.Repeat("-", 200) + `",
+  "points": [ {"x": 1, "y": -2}, {"x":30,"y":4} ],
+  "tags": {"a": [true, null], "b": "c"} }`
+
+	readers := []struct {
+		name string
+		r    func() io.Reader
+	}{
+		{"buffered", func() io.Reader { return strings.NewReader(in) }},
+		{"one byte at a time", func() io.Reader { return iotest.OneByteReader(strings.NewReader(in)) }},
+	}
+
+	spans := map[string]string{
+		"name":       `"` + strings.Repeat("-", 200) + `"`,
+		"points":     `[ {"x": 1, "y": -2}, {"x":30,"y":4} ]`,
+		"points.0":   `{"x": 1, "y": -2}`,
+		"points.0.x": `1`,
+		"points.0.y": `-2`,
+		"points.1":   `{"x":30,"y":4}`,
+		"points.1.x": `30`,
+		"points.1.y": `4`,
+		"tags":       `{"a": [true, null], "b": "c"}`,
+		"tags.a":     `[true, null]`,
+		"tags.a.0":   `true`,
+		"tags.a.1":   `null`,
+		"tags.b":     `"c"`,
+	}
+
+	for _, test := range readers {
+		t.Run(test.name, func(t *testing.T) {
+			var v shape
+
+			d := NewDecoder(test.r())
+			d.RecordSpans = true
+
+			if err := d.Decode(&v); err != nil {
+				t.Fatal(err)
+			}
+
+			found := d.FieldSpans()
+
+			if len(found) != len(spans) {
+				t.Errorf("expected %d spans but found %d: %v", len(spans), len(found), found)
+			}
+
+			for path, raw := range spans {
+				span := found[path]
+
+				if s := in[span[0]:span[1]]; s != raw {
+					t.Errorf("bad span for %s: %q", path, s)
+				}
+			}
+		})
+	}
+}
+
+func TestFieldSpansUnsupportedParser(t *testing.T) {
+	var v interface{}
+
+	d := objconv.NewDecoder(objconv.NewValueParser(map[string]interface{}{"a": 1}))
+	d.RecordSpans = true
+
+	if err := d.Decode(&v); err == nil {
+		t.Error("expected an error when recording spans with a parser that doesn't support it")
+	}
+}
+
+func TestUseNumber(t *testing.T) {
+	const in = `{"id":123456789012345678901234567890,"ratio":0.10000000000000000555,"count":-3,"name":"x"}`
+
+	var v map[string]interface{}
+
+	d := NewDecoder(strings.NewReader(in))
+	d.UseNumber = true
+
+	if err := d.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := v["id"]; n != objconv.Number("123456789012345678901234567890") {
+		t.Errorf("bad id: %#v", n)
+	}
+
+	if n := v["ratio"]; n != objconv.Number("0.10000000000000000555") {
+		t.Errorf("bad ratio: %#v", n)
+	}
+
+	if n, err := v["count"].(objconv.Number).Int64(); n != -3 || err != nil {
+		t.Errorf("bad count: %d (%v)", n, err)
+	}
+
+	b, err := Marshal(struct {
+		ID    objconv.Number `objconv:"id"`
+		Ratio objconv.Number `objconv:"ratio"`
+	}{v["id"].(objconv.Number), v["ratio"].(objconv.Number)})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := string(b); s != `{"id":123456789012345678901234567890,"ratio":0.10000000000000000555}` {
+		t.Error(s)
+	}
+}
+
+func TestEmitNumber(t *testing.T) {
+	valid := []string{"0", "-0", "1", "-12", "1.5", "0.25e-3", "1E+10", "12e3"}
+	invalid := []string{"", "-", "01", "1.", ".5", "1e", "1e+", "+1", "0x10", "NaN", "1 "}
+
+	for _, s := range valid {
+		if b, err := Marshal(objconv.Number(s)); err != nil || string(b) != s {
+			t.Errorf("%q: bad encoding: %q (%v)", s, b, err)
+		}
+	}
+
+	for _, s := range invalid {
+		if _, err := Marshal(objconv.Number(s)); err == nil {
+			t.Errorf("%q: expected an error", s)
+		}
+	}
+}
+
+func TestIntegerOverflow(t *testing.T) {
+	var v interface{}
+
+	if err := Unmarshal([]byte(`123456789012345678901234567890`), &v); err == nil {
+		t.Errorf("expected an error when decoding an integer that doesn't fit in 64 bits but got %#v", v)
+	}
+}
+
+func TestDecodeArray(t *testing.T) {
+	const n = 10000
+
+	r, w := io.Pipe()
+
+	go func() {
+		w.Write([]byte("["))
+		for i := 0; i != n; i++ {
+			if i != 0 {
+				w.Write([]byte(","))
+			}
+			w.Write([]byte(`{"id":` + strconv.Itoa(i) + `}`))
+		}
+		w.Write([]byte("]"))
+		w.Close()
+	}()
+
+	var sum int
+	var cnt int
+
+	d := NewDecoder(r)
+
+	if err := d.DecodeArray(func(d objconv.Decoder) error {
+		var v struct {
+			ID int `objconv:"id"`
+		}
+		if err := d.Decode(&v); err != nil {
+			return err
+		}
+		sum += v.ID
+		cnt++
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if cnt != n || sum != n*(n-1)/2 {
+		t.Errorf("bad iteration: sum = %d, count = %d", sum, cnt)
+	}
+}
+
+func TestJSONTagOption(t *testing.T) {
+	type T struct {
+		Payload map[string]interface{} `objconv:"payload,json"`
+		Text    string                 `objconv:"text,json"`
+	}
+
+	v1 := T{
+		Payload: map[string]interface{}{"a": int64(1)},
+		Text:    `"quoted"`,
+	}
+
+	b, err := Marshal(v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := string(b); s != `{"payload":"{\"a\":1}","text":"\"\\\"quoted\\\"\""}` {
+		t.Error(s)
+	}
+
+	var v2 T
+	if err := Unmarshal(b, &v2); err != nil {
+		t.Error(err)
+	}
+
+	if !reflect.DeepEqual(v1, v2) {
+		t.Errorf("%#v != %#v", v1, v2)
+	}
+}
+
+func TestTransformTagOptions(t *testing.T) {
+	type T struct {
+		Payload map[string]interface{} `objconv:"payload,base64,gzip"`
+		Text    string                 `objconv:"text,base64"`
+		Data    []byte                 `objconv:"data,base64,base64"`
+	}
+
+	v1 := T{
+		Payload: map[string]interface{}{"a": int64(1)},
+		Text:    "hello",
+		Data:    []byte("world"),
+	}
+
+	b, err := Marshal(v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var m map[string]string
+	if err := Unmarshal(b, &m); err != nil {
+		t.Fatal(err)
+	}
+
+	if m["text"] != "aGVsbG8=" || m["data"] != "ZDI5eWJHUT0=" {
+		t.Error(string(b))
+	}
+
+	var v2 T
+	if err := Unmarshal(b, &v2); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(v1, v2) {
+		t.Errorf("%#v != %#v", v1, v2)
+	}
+
+	tests := []struct {
+		in  string
+		err string
+	}{
+		{
+			in:  `{"payload":"not base64!"}`,
+			err: "objconv: base64 decoding of field payload failed: illegal base64 data at input byte 3",
+		},
+		{
+			in:  `{"payload":"aGVsbG8="}`,
+			err: "objconv: gzip decoding of field payload failed: unexpected EOF",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			var v T
+			err := Unmarshal([]byte(test.in), &v)
+
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+
+			if s := err.Error(); s != test.err {
+				t.Error(s)
+			}
+		})
+	}
+}
+
+func TestFormatTagOption(t *testing.T) {
+	type T struct {
+		Blob map[string]int64 `objconv:"blob,format=msgpack,base64"`
+	}
+
+	v1 := T{Blob: map[string]int64{"a": 1}}
+
+	b, err := Marshal(v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// {"a":1} in msgpack is 0x81 0xa1 0x61 0x01
+	if s := string(b); s != `{"blob":"gaFhAQ=="}` {
+		t.Error(s)
+	}
+
+	var v2 T
+	if err := Unmarshal(b, &v2); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(v1, v2) {
+		t.Errorf("%#v != %#v", v1, v2)
+	}
+
+	tests := []struct {
+		in  string
+		err string
+	}{
+		{
+			in:  `{"blob":"gaFh"}`,
+			err: "objconv: msgpack decoding of field blob failed: EOF",
+		},
+		{
+			in:  `{"blob":"gaFhoWI="}`,
+			err: "objconv: msgpack decoding of field blob failed: cannot convert from string to int",
+		},
+		{
+			in:  `{"blob":"!"}`,
+			err: "objconv: base64 decoding of field blob failed: illegal base64 data at input byte 0",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			var v T
+			err := Unmarshal([]byte(test.in), &v)
+
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+
+			if s := err.Error(); s != test.err {
+				t.Error(s)
+			}
+		})
+	}
+}
+
+func TestDecodeCollectErrors(t *testing.T) {
+	type T struct {
+		A int    `objconv:"a"`
+		B []byte `objconv:"b"`
+		C bool   `objconv:"c"`
+	}
+
+	t.Run("recoverable", func(t *testing.T) {
+		var v T
+		d := NewDecoder(strings.NewReader(`{"a":"1","b":"aGVsbG8=","c":[true]}`))
+		d.CollectErrors = true
+
+		errs, ok := d.Decode(&v).(objconv.FieldErrors)
+		if !ok || len(errs) != 2 || errs[0].Path[0] != "a" || errs[1].Path[0] != "c" {
+			t.Errorf("bad errors: %v", errs)
+		}
+
+		if string(v.B) != "hello" {
+			t.Errorf("bad value: %#v", v)
+		}
+	})
+
+	t.Run("syntax error", func(t *testing.T) {
+		var v T
+		d := NewDecoder(strings.NewReader(`{"a":"1","b":`))
+		d.CollectErrors = true
+
+		if err := d.Decode(&v); err == nil {
+			t.Error("expected an error")
+		} else if _, ok := err.(objconv.FieldErrors); ok {
+			t.Errorf("syntax errors must not be collected: %v", err)
+		}
+	})
+}
+
+func TestTokenParser(t *testing.T) {
+	objtests.TestCodec(t, objconv.Codec{
+		NewEmitter: Codec.NewEmitter,
+		NewParser:  func(r io.Reader) objconv.Parser { return NewTokenParser(r) },
+	})
+}
+
+func TestBigIntMapKeys(t *testing.T) {
+	var m map[*big.Int]string
+
+	if err := Unmarshal([]byte(`{"123456789012345678901234567890":"A","-0x2a":"B"}`), &m); err != nil {
+		t.Fatal(err)
+	}
+
+	s := make(map[string]string, len(m))
+	for k, v := range m {
+		s[k.String()] = v
+	}
+
+	if !reflect.DeepEqual(s, map[string]string{"123456789012345678901234567890": "A", "-42": "B"}) {
+		t.Errorf("%#v", s)
+	}
+
+	if b, err := Marshal(map[*big.Int]string{big.NewInt(-42): "B"}); err != nil {
+		t.Error(err)
+	} else if string(b) != `{"-42":"B"}` {
+		t.Error(string(b))
+	}
+
+	if err := Unmarshal([]byte(`{"12a":"A"}`), &m); err == nil || !strings.Contains(err.Error(), "bad big integer: 12a") {
+		t.Errorf("bad error: %v", err)
+	}
+}
+
+func TestBigInt(t *testing.T) {
+	var v struct {
+		A *big.Int `objconv:"a"`
+		B *big.Int `objconv:"b"`
+		C *big.Int `objconv:"c"`
+	}
+
+	if err := Unmarshal([]byte(`{"a":123456789012345678901234567890,"b":"-123456789012345678901234567890","c":-42}`), &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := []string{v.A.String(), v.B.String(), v.C.String()}; !reflect.DeepEqual(s, []string{"123456789012345678901234567890", "-123456789012345678901234567890", "-42"}) {
+		t.Errorf("%#v", s)
+	}
+
+	if err := Unmarshal([]byte(`{"a":1e3}`), &v); err == nil || !strings.Contains(err.Error(), "bad big integer: 1e3") {
+		t.Errorf("bad error: %v", err)
+	}
+}
+
+func TestDecodePartial(t *testing.T) {
+	type T struct {
+		A int               `objconv:"a"`
+		B []int             `objconv:"b"`
+		C map[string]string `objconv:"c"`
+		D *T                `objconv:"d"`
+	}
+
+	tests := []struct {
+		in  string
+		out T
+		err error
+	}{
+		{
+			in:  `{"a":1,"b":[1,2]}`,
+			out: T{A: 1, B: []int{1, 2}},
+		},
+		{
+			in:  `{"a":1,"b":[1,2,`,
+			out: T{A: 1, B: []int{1, 2}},
+			err: objconv.ErrTruncated,
+		},
+		{
+			in:  `{"a":1,"c":{"x":"y","z":"`,
+			out: T{A: 1, C: map[string]string{"x": "y"}},
+			err: objconv.ErrTruncated,
+		},
+		{
+			in:  `{"a":1,"d":{"a":2,"b":[`,
+			out: T{A: 1, D: &T{A: 2, B: []int{}}},
+			err: objconv.ErrTruncated,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			var v T
+			d := NewDecoder(strings.NewReader(test.in))
+			d.Partial = true
+
+			if err := d.Decode(&v); err != test.err {
+				t.Errorf("bad error: %v", err)
+			}
+
+			if !reflect.DeepEqual(v, test.out) {
+				t.Errorf("%#v", v)
+			}
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		var v T
+
+		if err := Unmarshal([]byte(`{"a":1,"b":[1,2,`), &v); err == nil || err == objconv.ErrTruncated {
+			t.Errorf("bad error: %v", err)
+		}
+
+		if !reflect.DeepEqual(v, T{}) {
+			t.Errorf("%#v", v)
+		}
+	})
+}
+
+func TestAllowSpecialFloats(t *testing.T) {
+	const in = `{"a":1.5,"b":NaN,"c":Infinity,"d":-Infinity,"e":-2}`
+
+	t.Run("relaxed", func(t *testing.T) {
+		var m map[string]float64
+
+		p := NewParser(strings.NewReader(in))
+		p.AllowSpecialFloats = true
+
+		if err := objconv.NewDecoder(p).Decode(&m); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(m) != 5 || m["a"] != 1.5 || !math.IsNaN(m["b"]) || !math.IsInf(m["c"], 1) || !math.IsInf(m["d"], -1) || m["e"] != -2 {
+			t.Errorf("bad value: %v", m)
+		}
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		var m map[string]float64
+
+		if err := Unmarshal([]byte(in), &m); err == nil {
+			t.Error("expected an error when special floats are not allowed")
+		}
+	})
+}
+
+func TestNumberLiteralTypes(t *testing.T) {
+	tests := []struct {
+		in  string
+		out interface{}
+	}{
+		{`1`, int64(1)},
+		{`-0`, int64(0)},
+		{`1.0`, float64(1)},
+		{`1e3`, float64(1000)},
+		{`1E3`, float64(1000)},
+		{`-2.5e-1`, float64(-0.25)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			var v interface{}
+
+			if err := Unmarshal([]byte(test.in), &v); err != nil {
+				t.Fatal(err)
+			}
+
+			if v != test.out {
+				t.Errorf("%#v != %#v", v, test.out)
+			}
+		})
+	}
+}
+
+func TestInterfaceMapKeys(t *testing.T) {
+	m := map[interface{}]interface{}{
+		"s":      "A",
+		int64(1): map[interface{}]interface{}{uint64(2): "B"},
+		1.5:      "C",
+		true:     "D",
+		nil:      "E",
+	}
+
+	for _, test := range []struct {
+		emitter func(io.Writer) objconv.Emitter
+		out     string
+	}{
+		{
+			emitter: func(w io.Writer) objconv.Emitter { return NewEmitter(w) },
+			out:     `{"null":"E","true":"D","1":{"2":"B"},"1.5":"C","s":"A"}`,
+		},
+		{
+			emitter: func(w io.Writer) objconv.Emitter { return NewPrettyEmitter(w) },
+			out:     "{\n  \"null\": \"E\",\n  \"true\": \"D\",\n  \"1\": {\n    \"2\": \"B\"\n  },\n  \"1.5\": \"C\",\n  \"s\": \"A\"\n}",
+		},
+	} {
+		b := &strings.Builder{}
+		e := objconv.Encoder{Emitter: test.emitter(b), SortMapKeys: true}
+
+		if err := e.Encode(m); err != nil {
+			t.Fatal(err)
+		}
+
+		if s := b.String(); s != test.out {
+			t.Errorf("%s", s)
+		}
+
+		var v map[interface{}]interface{}
+
+		if err := Unmarshal([]byte(b.String()), &v); err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(v, map[interface{}]interface{}{
+			"s":    "A",
+			"1":    map[interface{}]interface{}{"2": "B"},
+			"1.5":  "C",
+			"true": "D",
+			"null": "E",
+		}) {
+			t.Errorf("%#v", v)
+		}
+	}
+
+	for _, k := range []interface{}{[2]int{1, 2}, struct{ A int }{1}} {
+		if _, err := Marshal(map[interface{}]interface{}{k: 1}); err == nil || !strings.Contains(err.Error(), "keys must be strings or scalar values") {
+			t.Errorf("bad error for key of type %T: %v", k, err)
+		}
+	}
+}
+
+func TestIntegerMapKeys(t *testing.T) {
+	var m map[int]string
+
+	// Duplicate keys overwrite the values of the previous ones.
+	if err := Unmarshal([]byte(`{"1":"A","2":"B","1":"C"}`), &m); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(m, map[int]string{1: "C", 2: "B"}) {
+		t.Errorf("%#v", m)
+	}
+
+	if b, err := Marshal(m); err != nil {
+		t.Error(err)
+	} else if s := string(b); s != `{"1":"C","2":"B"}` && s != `{"2":"B","1":"C"}` {
+		t.Error(s)
+	}
+}
+
+func TestTimeLayoutUnixMilli(t *testing.T) {
+	type T struct {
+		CreatedAt time.Time `objconv:"created_at"`
+	}
+
+	in := T{CreatedAt: time.Date(2021, 1, 1, 0, 0, 0, 123e6, time.UTC)}
+	b := &bytes.Buffer{}
+
+	if err := (objconv.Encoder{Emitter: NewEmitter(b), TimeLayout: objconv.TimeUnixMilli}).Encode(in); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := b.String(); s != `{"created_at":1609459200123}` {
+		t.Error("bad encoding:", s)
+	}
+
+	var out T
+
+	if err := (objconv.Decoder{Parser: NewParser(b), TimeLayouts: []string{objconv.TimeUnixMilli}}).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	if !out.CreatedAt.Equal(in.CreatedAt) {
+		t.Errorf("%v != %v", out.CreatedAt, in.CreatedAt)
+	}
+}
+
+func TestPrettyEmitterConfig(t *testing.T) {
+	type T struct {
+		A []int             `objconv:"a"`
+		B map[string]string `objconv:"b"`
+		C []interface{}     `objconv:"c"`
+	}
+
+	tests := []struct {
+		config EmitterConfig
+		v      interface{}
+		out    string
+	}{
+		{
+			config: EmitterConfig{Indent: "\t"},
+			v:      T{A: []int{1, 2}, B: map[string]string{}, C: []interface{}{[]int{}, map[string]int{"x": 1}}},
+			out:    "{\n\t\"a\": [\n\t\t1,\n\t\t2\n\t],\n\t\"b\": {},\n\t\"c\": [\n\t\t[],\n\t\t{\n\t\t\t\"x\": 1\n\t\t}\n\t]\n}",
+		},
+		{
+			config: EmitterConfig{Prefix: "// ", Indent: "  "},
+			v:      []interface{}{1, []interface{}{[]int{2}}},
+			out:    "[\n//   1,\n//   [\n//     [\n//       2\n//     ]\n//   ]\n// ]",
+		},
+		{
+			config: EmitterConfig{Prefix: "> "},
+			v:      map[string]int{"a": 1},
+			out:    "{\n> \"a\": 1\n> }",
+		},
+		{
+			config: EmitterConfig{Indent: "  "},
+			v:      []int{},
+			out:    "[]",
+		},
+		{
+			config: EmitterConfig{Indent: "  ", NewlineArrays: true},
+			v:      T{A: []int{1, 2}, B: map[string]string{"x": "y"}, C: []interface{}{[]int{}, []int{3, 4}, map[string]int{"x": 1}}},
+			out:    "{\n  \"a\":\n  [\n    1,\n    2\n  ],\n  \"b\": {\n    \"x\": \"y\"\n  },\n  \"c\":\n  [\n    [],\n    [\n      3,\n      4\n    ],\n    {\n      \"x\": 1\n    }\n  ]\n}",
+		},
+		{
+			config: EmitterConfig{Indent: "  ", NewlineArrays: true},
+			v:      T{A: []int{}, B: map[string]string{}, C: []interface{}{map[string][]int{"x": {}}}},
+			out:    "{\n  \"a\": [],\n  \"b\": {},\n  \"c\":\n  [\n    {\n      \"x\": []\n    }\n  ]\n}",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.out, func(t *testing.T) {
+			b := &strings.Builder{}
+
+			if err := objconv.NewEncoder(NewPrettyEmitterWith(b, test.config)).Encode(test.v); err != nil {
+				t.Fatal(err)
+			}
+
+			if s := b.String(); s != test.out {
+				t.Errorf("%q", s)
+			}
+
+			if len(test.config.Prefix) == 0 { // prefixes are usually comments
+				var v interface{}
+
+				if err := Unmarshal([]byte(b.String()), &v); err != nil {
+					t.Error("the output isn't valid JSON:", err)
+				}
+			}
+		})
+	}
+
+	t.Run("unknown length", func(t *testing.T) {
+		// Containers of unknown length, which may end up being empty, are
+		// written without line breaks when no elements were emitted.
+		b := &strings.Builder{}
+		e := NewPrettyEmitter(b)
+
+		e.EmitArrayBegin(-1)
+		e.EmitMapBegin(-1)
+		e.EmitMapEnd()
+		e.EmitArrayNext()
+		e.EmitArrayBegin(-1)
+		e.EmitArrayEnd()
+		e.EmitArrayEnd()
+
+		if s := b.String(); s != "[\n  {},\n  []\n]" {
+			t.Errorf("%q", s)
+		}
+
+		b.Reset()
+		e = NewPrettyEmitterWith(b, EmitterConfig{Indent: "  ", NewlineArrays: true})
+
+		e.EmitMapBegin(-1)
+		e.EmitString("a")
+		e.EmitMapValue()
+		e.EmitArrayBegin(-1)
+		e.EmitArrayEnd()
+		e.EmitMapNext()
+		e.EmitString("b")
+		e.EmitMapValue()
+		e.EmitArrayBegin(-1)
+		e.EmitInt(1, 64)
+		e.EmitArrayEnd()
+		e.EmitMapEnd()
+
+		if s := b.String(); s != "{\n  \"a\": [],\n  \"b\":\n  [\n    1\n  ]\n}" {
+			t.Errorf("%q", s)
+		}
+	})
+}
+
+func TestMaxDepth(t *testing.T) {
+	nested := func(n int) []byte {
+		return []byte(strings.Repeat(`[{"a":`, n) + "1" + strings.Repeat("}]", n))
+	}
+
+	tests := []struct {
+		in       []byte
+		maxDepth int
+		err      error
+	}{
+		{in: nested(3), maxDepth: 6},
+		{in: nested(3), maxDepth: 5, err: objconv.ErrMaxDepth},
+		{in: nested(objconv.DefaultMaxDepth / 2)},
+		{in: nested(objconv.DefaultMaxDepth/2 + 1), err: objconv.ErrMaxDepth},
+		{in: nested(objconv.DefaultMaxDepth), maxDepth: -1},
+	}
+
+	// Values are loaded before being decoded when errors are collected, which
+	// makes decoding deeply nested values quadratic.
+	collect := func(in []byte) []bool {
+		if len(in) > 100 {
+			return []bool{false}
+		}
+		return []bool{false, true}
+	}
+
+	for _, test := range tests {
+		t.Run(strconv.Itoa(len(test.in))+"/"+strconv.Itoa(test.maxDepth), func(t *testing.T) {
+			for _, collect := range collect(test.in) {
+				var v interface{}
+
+				err := (objconv.Decoder{
+					Parser:        NewParser(bytes.NewReader(test.in)),
+					MaxDepth:      test.maxDepth,
+					CollectErrors: collect,
+				}).Decode(&v)
+
+				if err != test.err {
+					t.Errorf("bad error (collect = %t): %v", collect, err)
+				}
+			}
+		})
+	}
+}
+
+func TestMaxBytes(t *testing.T) {
+	big := `"` + strings.Repeat("x", 100) + `"`
+
+	tests := []struct {
+		in  string
+		max int64
+		err error
+	}{
+		{in: `"hello"`, max: 7},
+		{in: `"hello"`, max: 6, err: objconv.ErrTooLarge},
+		{in: big, max: 10, err: objconv.ErrTooLarge},
+		{in: `[` + big + `]`, max: 10, err: objconv.ErrTooLarge},
+		{in: big},
+	}
+
+	for _, test := range tests {
+		t.Run(strconv.Itoa(len(test.in))+"/"+strconv.FormatInt(test.max, 10), func(t *testing.T) {
+			var v interface{}
+			d := NewDecoder(iotest.OneByteReader(strings.NewReader(test.in)))
+			d.MaxBytes = test.max
+
+			if err := d.Decode(&v); err != test.err {
+				t.Error("bad error:", err)
+			}
+		})
+	}
+
+	t.Run("reset", func(t *testing.T) {
+		// The limit applies to each call to Decode, a stream of values is
+		// decoded as long as none of them are too large.
+		d := NewStreamDecoder(iotest.OneByteReader(strings.NewReader(`[1,2,3,` + big + `]`)))
+		d.MaxBytes = 8
+
+		var n int
+
+		for {
+			var v interface{}
+			if d.Decode(&v) != nil {
+				break
+			}
+			n++
+		}
+
+		if n != 3 || d.Err() != objconv.ErrTooLarge {
+			t.Errorf("%d values were decoded, error: %v", n, d.Err())
+		}
+	})
+}
+
+func TestStreamDecoderAll(t *testing.T) {
+	tests := []struct {
+		in     string
+		values []int
+		err    bool
+	}{
+		{in: ``},
+		{in: `[]`},
+		{in: `42`, values: []int{42}},
+		{in: `[1, 2, 3]`, values: []int{1, 2, 3}},
+		{in: `[1, 2`, values: []int{1, 2}, err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			var values []int
+			var err error
+
+			for v, e := range objconv.DecodeAll[int](NewStreamDecoder(strings.NewReader(test.in))) {
+				if e != nil {
+					err = e
+					break
+				}
+				values = append(values, v)
+			}
+
+			if !reflect.DeepEqual(values, test.values) || (err != nil) != test.err {
+				t.Errorf("%v %v", values, err)
+			}
+		})
+	}
+}
+
+func TestMultiMap(t *testing.T) {
+	type named []string
+
+	tests := []struct {
+		in  string
+		out interface{}
+	}{
+		{
+			in:  `{"a":"x","b":"y","a":"z"}`,
+			out: map[string][]string{"a": {"x", "z"}, "b": {"y"}},
+		},
+		{
+			in:  `{"a":"x","a":["y","z"],"a":null,"b":[]}`,
+			out: map[string][]string{"a": {"x", "y", "z"}, "b": {}},
+		},
+		{
+			in:  `{"a":1,"a":"x"}`,
+			out: map[string]named{"a": {"1", "x"}},
+		},
+		{
+			in:  `{"a":1,"a":[true]}`,
+			out: map[string][]interface{}{"a": {int64(1), true}},
+		},
+		{
+			in:  `{"a":"aGVsbG8=","a":"d29ybGQ="}`,
+			out: map[string][]byte{"a": []byte("world")},
+		},
+		{
+			in:  `{"a":"x","a":"y"}`,
+			out: map[string]interface{}{"a": "y"},
+		},
+		{
+			in:  `{"A":"x","A":"y"}`,
+			out: struct{ A string }{A: "y"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			v := reflect.New(reflect.TypeOf(test.out))
+			d := NewDecoder(strings.NewReader(test.in))
+			d.MultiMap = true
+			d.Lenient = true
+
+			if err := d.Decode(v.Interface()); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(v.Elem().Interface(), test.out) {
+				t.Errorf("%#v", v.Elem().Interface())
+			}
+		})
+	}
+}
+
+func TestIntegralFloats(t *testing.T) {
+	tests := []struct {
+		in      string
+		out     interface{}
+		lenient bool
+		err     bool
+	}{
+		{in: `1e3`, out: int(1000)},
+		{in: `1000.0`, out: uint16(1000)},
+		{in: `-2.5e1`, out: int64(-25)},
+		{in: `9007199254740993e0`, out: int64(9007199254740993)},
+		{in: `9223372036854775807.0`, out: int64(9223372036854775807)},
+		{in: `18446744073709551615e0`, out: uint64(18446744073709551615)},
+		{in: `1.5`, out: int(0), err: true},
+		{in: `1.5`, out: int(1), lenient: true},
+		{in: `-0.5`, out: uint(0), lenient: true},
+		{in: `1e3`, out: int8(0), err: true},
+		{in: `9223372036854775808e0`, out: int64(0), err: true},
+		{in: `1e30`, out: uint64(0), err: true},
+		{in: `-1e0`, out: uint(0), err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			v := reflect.New(reflect.TypeOf(test.out))
+			d := NewDecoder(strings.NewReader(test.in))
+			d.IntegralFloats = true
+			d.Lenient = test.lenient
+
+			err := d.Decode(v.Interface())
+
+			if test.err {
+				if err == nil {
+					t.Errorf("expected an error but decoded %v", v.Elem().Interface())
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(v.Elem().Interface(), test.out) {
+				t.Errorf("%#v", v.Elem().Interface())
+			}
+		})
+	}
+}
+
+func TestTextUnmarshalerBytes(t *testing.T) {
+	// The text of types like net.IP, which are byte slices, must not be
+	// decoded as base64.
+	var v struct {
+		IP  net.IP  `objconv:"ip"`
+		Ptr *net.IP `objconv:"ptr"`
+	}
+
+	if err := Unmarshal([]byte(`{"ip": "10.0.0.1", "ptr": "::1"}`), &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if !v.IP.Equal(net.ParseIP("10.0.0.1")) || v.Ptr == nil || !v.Ptr.Equal(net.IPv6loopback) {
+		t.Errorf("%#v", v)
+	}
+
+	if b, err := Marshal(v); err != nil || string(b) != `{"ip":"10.0.0.1","ptr":"::1"}` {
+		t.Errorf("bad encoding: %s (%v)", b, err)
+	}
+}
@@ -0,0 +1,113 @@
+package json
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/segmentio/objconv"
+)
+
+// A LinesDecoder decodes values from newline-delimited JSON (NDJSON), where
+// each line of the input is an independent JSON document.
+//
+// Each call to Decode reads the next line and decodes it with a parser of its
+// own, so a malformed record is reported as an error without preventing the
+// following ones from being decoded. Blank lines between records are skipped.
+//
+// Instances of LinesDecoder are not safe for use by multiple goroutines.
+type LinesDecoder struct {
+	r    *bufio.Reader
+	b    bytes.Buffer
+	p    Parser
+	line int
+}
+
+// NewLinesDecoder returns a new lines decoder that reads records from r.
+func NewLinesDecoder(r io.Reader) *LinesDecoder {
+	d := &LinesDecoder{r: bufio.NewReader(r)}
+	d.p.s = d.p.c[:0]
+	return d
+}
+
+// Decode reads the next record and decodes it into v.
+//
+// The method returns io.EOF when there are no more records to read, errors
+// occurring while decoding a record are prefixed with its line number.
+func (d *LinesDecoder) Decode(v interface{}) error {
+	line, err := d.readLine()
+	if err != nil {
+		return err
+	}
+
+	d.b.Reset()
+	d.b.Write(line)
+	d.p.Reset(&d.b)
+
+	if err = (objconv.Decoder{Parser: &d.p}).Decode(v); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return fmt.Errorf("objconv/json: line %d: %s", d.line, err)
+	}
+
+	if _, err = d.p.ParseType(); err != io.EOF {
+		return fmt.Errorf("objconv/json: line %d: unexpected data after the end of the record", d.line)
+	}
+
+	return nil
+}
+
+// readLine returns the next line of the input which isn't blank.
+func (d *LinesDecoder) readLine() ([]byte, error) {
+	for {
+		line, err := d.r.ReadBytes('\n')
+
+		if len(line) != 0 {
+			d.line++
+		}
+
+		if len(bytes.TrimSpace(line)) != 0 {
+			return line, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// A LinesEncoder encodes values as newline-delimited JSON (NDJSON), each call
+// to Encode writes a record on a line of its own.
+//
+// Instances of LinesEncoder are not safe for use by multiple goroutines.
+type LinesEncoder struct {
+	w io.Writer
+	b bytes.Buffer
+	e *Emitter
+}
+
+// NewLinesEncoder returns a new lines encoder that writes records to w.
+func NewLinesEncoder(w io.Writer) *LinesEncoder {
+	e := &LinesEncoder{w: w}
+	e.e = NewEmitter(&e.b)
+	return e
+}
+
+// Encode writes v as a record on a new line.
+//
+// Each record is written with a single call to the Write method of the
+// underlying writer, nothing is written if v fails to encode.
+func (e *LinesEncoder) Encode(v interface{}) (err error) {
+	e.b.Reset()
+	e.e.Reset(&e.b) // clears the state left by records that failed to encode
+
+	if err = objconv.NewEncoder(e.e).Encode(v); err != nil {
+		return
+	}
+
+	e.b.WriteByte('\n')
+	_, err = e.w.Write(e.b.Bytes())
+	return
+}
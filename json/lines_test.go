@@ -0,0 +1,118 @@
+package json
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLinesDecoder(t *testing.T) {
+	type record struct {
+		Level string `objconv:"level"`
+		Msg   string `objconv:"msg"`
+	}
+
+	d := NewLinesDecoder(strings.NewReader(`{"level":"info","msg":"starting"}
+
+{"level":"error","msg":
+{"level":"warn","msg":"retrying"} {}
+  
+{"level":"info","msg":"done"}`))
+
+	tests := []struct {
+		rec record
+		err string
+	}{
+		{rec: record{"info", "starting"}},
+		{err: "objconv/json: line 3: unexpected EOF"},
+		{err: "objconv/json: line 4: unexpected data after the end of the record"},
+		{rec: record{"info", "done"}},
+	}
+
+	for _, test := range tests {
+		var r record
+		err := d.Decode(&r)
+
+		if len(test.err) != 0 {
+			if err == nil || err.Error() != test.err {
+				t.Error("bad error:", err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Error(err)
+		} else if r != test.rec {
+			t.Errorf("bad record: %+v", r)
+		}
+	}
+
+	var r record
+
+	if err := d.Decode(&r); err != io.EOF {
+		t.Error("expected io.EOF at the end of the stream but got", err)
+	}
+}
+
+func TestLinesEncoder(t *testing.T) {
+	b := &bytes.Buffer{}
+	e := NewLinesEncoder(b)
+
+	for _, v := range []interface{}{
+		map[string]int{"a": 1},
+		[]string{"b"},
+		"c",
+	} {
+		if err := e.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := e.Encode(func() {}); err == nil {
+		t.Error("expected an error when encoding a function")
+	}
+
+	if s := b.String(); s != "{\"a\":1}\n[\"b\"]\n\"c\"\n" {
+		t.Errorf("%q", s)
+	}
+
+	var values []interface{}
+	d := NewLinesDecoder(b)
+
+	for {
+		var v interface{}
+		if err := d.Decode(&v); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		values = append(values, v)
+	}
+
+	if !reflect.DeepEqual(values, []interface{}{
+		map[interface{}]interface{}{"a": int64(1)},
+		[]interface{}{"b"},
+		"c",
+	}) {
+		t.Errorf("%#v", values)
+	}
+}
+
+func TestLinesEncoderAfterEncodeError(t *testing.T) {
+	b := &bytes.Buffer{}
+	e := NewLinesEncoder(b)
+
+	if err := e.Encode(map[interface{}]int{make(chan int): 1}); err == nil {
+		t.Error("expected an error encoding an unsupported map key")
+	}
+
+	if err := e.Encode(5); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := b.String(); s != "5\n" {
+		t.Errorf("%q", s)
+	}
+}
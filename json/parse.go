@@ -16,13 +16,28 @@ import (
 	"github.com/segmentio/objconv/objutil"
 )
 
+// Parser implements a JSON parser which satisfies the objconv.Parser interface.
+//
+// Numbers are reported with a type matching their literal form: a number with
+// a fraction or an exponent (a '.', 'e' or 'E' appears in the literal, like in
+// 1.0 or 1e3) is a Float, any other number is an Int. Decoding into an empty
+// interface therefore produces float64 or int64 values, respecting the form
+// that the author of the document chose.
 type Parser struct {
+	// AllowSpecialFloats enables a relaxed mode where the NaN, Infinity and
+	// -Infinity tokens, which are not part of the JSON standard but are
+	// produced by some encoders, are parsed as floating point values.
+	AllowSpecialFloats bool
+
 	r io.Reader // reader to load bytes from
 	s []byte    // buffer used for building strings
 	i int       // offset of the first byte in b
 	j int       // offset of the last byte in b
+	n int       // offset in the input of the first byte in b
 	b [128]byte // buffer where bytes are loaded from the reader
 	c [128]byte // initial backend array for s
+
+	raw objutil.RawRecorder // records raw bytes between BeginRaw and EndRaw
 }
 
 func NewParser(r io.Reader) *Parser {
@@ -35,12 +50,45 @@ func (p *Parser) Reset(r io.Reader) {
 	p.r = r
 	p.i = 0
 	p.j = 0
+	p.n = 0
 }
 
 func (p *Parser) Buffered() io.Reader {
 	return bytes.NewReader(p.b[p.i:p.j])
 }
 
+// InMemory returns true if the parser reads from a buffer held in memory.
+func (p *Parser) InMemory() bool {
+	return objutil.InMemory(p.r)
+}
+
+// Remaining returns the bytes of the input that haven't been parsed yet, the
+// parser has reached the end of its input after the method returns.
+func (p *Parser) Remaining() (rest []byte, err error) {
+	if rest, err = objutil.Remaining(p.r, p.b[p.i:p.j]); err == nil {
+		p.Reset(p.r)
+	}
+	return
+}
+
+// BeginRaw starts recording the raw bytes of the values being parsed.
+func (p *Parser) BeginRaw() {
+	p.r = p.raw.Begin(p.r, p.b[p.i:p.j])
+}
+
+// EndRaw stops recording and returns the raw bytes of the values parsed since
+// BeginRaw was called.
+func (p *Parser) EndRaw() (raw []byte) {
+	raw, p.r = p.raw.End(p.j - p.i)
+	return
+}
+
+// Offset returns the offset of the next byte to be parsed from the beginning of
+// the input, it satisfies the objconv.Positioner interface.
+func (p *Parser) Offset() int {
+	return p.n + p.i
+}
+
 func (p *Parser) ParseType() (t objconv.Type, err error) {
 	var b byte
 
@@ -71,6 +119,9 @@ func (p *Parser) ParseType() (t objconv.Type, err error) {
 	case b == 'f':
 		t = objconv.Bool
 
+	case (b == 'N' || b == 'I' || b == '-') && p.AllowSpecialFloats && p.peekSpecialFloat():
+		t = objconv.Float
+
 	case b == '-' || (b >= '0' && b <= '9'):
 		t = objconv.Int
 
@@ -139,6 +190,15 @@ func (p *Parser) ParseFloat() (v float64, err error) {
 	return
 }
 
+// ParseNumber returns the literal form of the number that ParseType returned
+// Int or Float for, it is used to decode objconv.Number values without loss of
+// precision.
+func (p *Parser) ParseNumber() (v []byte, err error) {
+	v = p.s
+	p.i += len(p.s)
+	return
+}
+
 func (p *Parser) ParseString() (v []byte, err error) {
 	if p.i == p.j {
 		if err = p.fill(); err != nil {
@@ -326,6 +386,10 @@ func (p *Parser) ParseMapNext(n int) (err error) {
 }
 
 func (p *Parser) DecodeBytes(b []byte) (v []byte, err error) {
+	return decodeBytes(b)
+}
+
+func decodeBytes(b []byte) (v []byte, err error) {
 	var n int
 	if n, err = base64.StdEncoding.Decode(b, b); err != nil {
 		return
@@ -387,6 +451,19 @@ func (p *Parser) peekNumber() (b []byte, err error) {
 	return
 }
 
+// peekSpecialFloat returns true if the next token is one of the special float
+// values allowed by AllowSpecialFloats, the token is cached for the following
+// call to ParseFloat.
+func (p *Parser) peekSpecialFloat() bool {
+	for _, token := range [...]string{"NaN", "Infinity", "-Infinity"} {
+		if chunk, err := p.peek(len(token)); err == nil && string(chunk) == token {
+			p.s = append(p.s[:0], token...)
+			return true
+		}
+	}
+	return false
+}
+
 func (p *Parser) readByte(b byte) (err error) {
 	var c byte
 
@@ -458,6 +535,7 @@ func (p *Parser) skipSpaces() (err error) {
 		}
 
 		// all trailing bytes in the read buffer were spaces, clear and refill.
+		p.n += p.j
 		p.i = 0
 		p.j = 0
 	}
@@ -466,6 +544,7 @@ func (p *Parser) skipSpaces() (err error) {
 func (p *Parser) fill() (err error) {
 	n := p.j - p.i
 	copy(p.b[:n], p.b[p.i:p.j])
+	p.n += p.i
 	p.i = 0
 	p.j = n
 
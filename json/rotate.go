@@ -0,0 +1,150 @@
+package json
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/segmentio/objconv"
+)
+
+// A RotatingEncoder encodes a stream of values across multiple outputs, moving
+// on to a new output when the current one would exceed a size limit. It is
+// meant to export large sequences of values in chunks, the way log files are
+// rotated.
+//
+// Each output is a standalone document, either a JSON array of the values or
+// newline-delimited JSON (NDJSON), which can be parsed independently of the
+// others. Values are never split across outputs, a value which is larger than
+// the limit is written alone to an output of its own.
+//
+// Instances of RotatingEncoder are not safe for use by multiple goroutines.
+type RotatingEncoder struct {
+	open  func() (io.WriteCloser, error)
+	max   int64
+	lines bool
+
+	w   io.WriteCloser
+	n   int64 // number of bytes written to w
+	b   bytes.Buffer
+	r   []byte
+	e   *Emitter
+	err error
+}
+
+// NewRotatingEncoder returns a new rotating encoder that writes arrays of at
+// most maxBytes bytes to the outputs returned by open.
+func NewRotatingEncoder(open func() (io.WriteCloser, error), maxBytes int64) *RotatingEncoder {
+	return newRotatingEncoder(open, maxBytes, false)
+}
+
+// NewRotatingLinesEncoder returns a new rotating encoder that writes records of
+// newline-delimited JSON to the outputs returned by open, each output holding
+// at most maxBytes bytes.
+func NewRotatingLinesEncoder(open func() (io.WriteCloser, error), maxBytes int64) *RotatingEncoder {
+	return newRotatingEncoder(open, maxBytes, true)
+}
+
+func newRotatingEncoder(open func() (io.WriteCloser, error), maxBytes int64, lines bool) *RotatingEncoder {
+	e := &RotatingEncoder{open: open, max: maxBytes, lines: lines}
+	e.e = NewEmitter(&e.b)
+	return e
+}
+
+// Encode writes v to the current output, or to a new one if writing it would
+// make the current output exceed the size limit.
+//
+// Nothing is written if v fails to encode, and the encoder can still be used.
+// Errors returned by the outputs are sticky, the following calls to Encode and
+// Close return the same error.
+func (e *RotatingEncoder) Encode(v interface{}) (err error) {
+	if e.err != nil {
+		return e.err
+	}
+
+	e.b.Reset()
+	e.e.Reset(&e.b) // clears the state left by values that failed to encode
+
+	if err = objconv.NewEncoder(e.e).Encode(v); err != nil {
+		return
+	}
+
+	// Values are preceded by '[' or ',' in arrays, and room is kept for the
+	// closing ']', while records of lines are followed by a newline.
+	size := int64(e.b.Len()) + 2
+
+	if e.lines {
+		size--
+	}
+
+	if e.w != nil && e.n+size > e.max {
+		if err = e.rotate(); err != nil {
+			return
+		}
+	}
+
+	r := e.r[:0]
+
+	switch {
+	case e.lines:
+	case e.w == nil:
+		r = append(r, '[')
+	default:
+		r = append(r, ',')
+	}
+
+	r = append(r, e.b.Bytes()...)
+
+	if e.lines {
+		r = append(r, '\n')
+	}
+
+	e.r = r
+
+	if e.w == nil {
+		if e.w, e.err = e.open(); e.err != nil {
+			e.w = nil
+			return e.err
+		}
+		e.n = 0
+	}
+
+	if _, e.err = e.w.Write(r); e.err == nil {
+		e.n += int64(len(r))
+	}
+
+	return e.err
+}
+
+// Close terminates the current output and closes it.
+//
+// No outputs are created when no values were encoded. The current output is
+// closed even if encoding failed, the error returned is then the first one that
+// occurred.
+func (e *RotatingEncoder) Close() error {
+	switch {
+	case e.w == nil:
+	case e.err == nil:
+		e.rotate()
+	default:
+		e.w.Close()
+		e.w = nil
+	}
+	return e.err
+}
+
+// rotate terminates the current output and closes it, the next call to Encode
+// opens a new output.
+func (e *RotatingEncoder) rotate() error {
+	w := e.w
+	e.w = nil
+
+	if !e.lines {
+		_, e.err = w.Write([]byte{']'})
+	}
+
+	if err := w.Close(); e.err == nil {
+		e.err = err
+	}
+
+	return e.err
+}
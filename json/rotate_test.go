@@ -0,0 +1,193 @@
+package json
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type rotatingOutput struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (out *rotatingOutput) Close() error {
+	out.closed = true
+	return nil
+}
+
+func TestRotatingEncoder(t *testing.T) {
+	values := []interface{}{
+		"hello",
+		1,
+		2,
+		strings.Repeat("x", 40),
+		map[string]int{"a": 1},
+		3,
+	}
+
+	tests := []struct {
+		newEncoder func(func() (io.WriteCloser, error), int64) *RotatingEncoder
+		outputs    []string
+	}{
+		{
+			newEncoder: NewRotatingEncoder,
+			outputs: []string{
+				`["hello",1,2]`,
+				`["` + strings.Repeat("x", 40) + `"]`,
+				`[{"a":1},3]`,
+			},
+		},
+		{
+			newEncoder: NewRotatingLinesEncoder,
+			outputs: []string{
+				"\"hello\"\n1\n2\n",
+				`"` + strings.Repeat("x", 40) + "\"\n",
+				"{\"a\":1}\n3\n",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.outputs[0], func(t *testing.T) {
+			var outputs []*rotatingOutput
+
+			e := test.newEncoder(func() (io.WriteCloser, error) {
+				out := &rotatingOutput{}
+				outputs = append(outputs, out)
+				return out, nil
+			}, 16)
+
+			for _, v := range values {
+				if err := e.Encode(v); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := e.Encode(func() {}); err == nil {
+				t.Error("expected an error encoding a function")
+			}
+
+			if err := e.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			var found []string
+			var decoded []interface{}
+
+			for _, out := range outputs {
+				if !out.closed {
+					t.Error("output was not closed")
+				}
+
+				found = append(found, out.String())
+
+				if !strings.HasSuffix(out.String(), "\n") {
+					var a []interface{}
+					if err := Unmarshal(out.Bytes(), &a); err != nil {
+						t.Fatal(err)
+					}
+					decoded = append(decoded, a...)
+					continue
+				}
+
+				d := NewLinesDecoder(&out.Buffer)
+
+				for {
+					var v interface{}
+					if err := d.Decode(&v); err != nil {
+						if err != io.EOF {
+							t.Fatal(err)
+						}
+						break
+					}
+					decoded = append(decoded, v)
+				}
+			}
+
+			if !reflect.DeepEqual(found, test.outputs) {
+				t.Errorf("%q", found)
+			}
+
+			if len(decoded) != len(values) {
+				t.Errorf("%d values were decoded from the outputs, expected %d", len(decoded), len(values))
+			}
+		})
+	}
+}
+
+func TestRotatingEncoderEmpty(t *testing.T) {
+	e := NewRotatingEncoder(func() (io.WriteCloser, error) {
+		t.Error("no outputs must be opened")
+		return nil, nil
+	}, 16)
+
+	if err := e.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRotatingEncoderError(t *testing.T) {
+	e := NewRotatingEncoder(func() (io.WriteCloser, error) {
+		return nil, errors.New("cannot open")
+	}, 16)
+
+	for i := 0; i != 2; i++ {
+		if err := e.Encode(1); err == nil || err.Error() != "cannot open" {
+			t.Error("bad error:", err)
+		}
+	}
+
+	if err := e.Close(); err == nil || err.Error() != "cannot open" {
+		t.Error("bad error:", err)
+	}
+}
+
+func TestRotatingEncoderAfterEncodeError(t *testing.T) {
+	out := &rotatingOutput{}
+	e := NewRotatingEncoder(func() (io.WriteCloser, error) { return out, nil }, 1024)
+
+	if err := e.Encode(map[interface{}]int{make(chan int): 1}); err == nil {
+		t.Error("expected an error encoding an unsupported map key")
+	}
+
+	if err := e.Encode(5); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := out.String(); s != "[5]" {
+		t.Errorf("bad output: %q", s)
+	}
+}
+
+type failingOutput struct {
+	rotatingOutput
+}
+
+func (out *failingOutput) Write(b []byte) (int, error) {
+	return 0, errors.New("cannot write")
+}
+
+func TestRotatingEncoderCloseAfterError(t *testing.T) {
+	out := &failingOutput{}
+	e := NewRotatingEncoder(func() (io.WriteCloser, error) { return out, nil }, 16)
+
+	if err := e.Encode(1); err == nil || err.Error() != "cannot write" {
+		t.Error("bad error:", err)
+	}
+
+	if err := e.Close(); err == nil || err.Error() != "cannot write" {
+		t.Error("bad error:", err)
+	}
+
+	if !out.closed {
+		t.Error("the output wasn't closed")
+	}
+}
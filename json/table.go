@@ -0,0 +1,188 @@
+package json
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/segmentio/objconv"
+)
+
+// A TableEncoder encodes values as JSON Lines sharing a header. The first line
+// is an array of the column names, and each value is written on the following
+// lines as an array of the values of its fields, in the order of the columns.
+//
+// Values must be structs or maps, they are encoded like the Encoder would and
+// their fields are matched with the columns by name. Missing fields are
+// written as null.
+//
+// Instances of TableEncoder are not safe for use by multiple goroutines.
+type TableEncoder struct {
+	// Columns is the list of column names written in the header. When nil,
+	// the columns are the fields of the first encoded value, in the order the
+	// encoder emits them.
+	Columns []string
+
+	w      io.Writer
+	b      bytes.Buffer
+	header bool
+}
+
+// NewTableEncoder returns a new table encoder that writes to w.
+func NewTableEncoder(w io.Writer) *TableEncoder {
+	return &TableEncoder{w: w}
+}
+
+// Encode writes v as a line of the table, the header is written before the
+// first value.
+func (e *TableEncoder) Encode(v interface{}) (err error) {
+	var keys []string
+	var values []interface{}
+
+	if keys, values, err = tableFields(v); err != nil {
+		return
+	}
+
+	e.b.Reset()
+
+	if !e.header {
+		if e.Columns == nil {
+			e.Columns = keys
+		}
+
+		if err = e.writeLine(e.Columns); err != nil {
+			return
+		}
+	}
+
+	row := make([]interface{}, len(e.Columns))
+
+	for i, k := range keys {
+		j := indexOfColumn(e.Columns, k)
+
+		if j < 0 {
+			return fmt.Errorf("objconv/json: field %q is not a column of the table", k)
+		}
+
+		row[j] = values[i]
+	}
+
+	if err = e.writeLine(row); err != nil {
+		return
+	}
+
+	if _, err = e.w.Write(e.b.Bytes()); err == nil {
+		e.header = true
+	}
+	return
+}
+
+func (e *TableEncoder) writeLine(v interface{}) error {
+	if err := objconv.NewEncoder(NewEmitter(&e.b)).Encode(v); err != nil {
+		return err
+	}
+	e.b.WriteByte('\n')
+	return nil
+}
+
+// tableFields returns the names and values of the fields of v, in the order
+// they are emitted by the encoder.
+func tableFields(v interface{}) (keys []string, values []interface{}, err error) {
+	var b []byte
+
+	if b, err = Marshal(v); err != nil {
+		return
+	}
+
+	err = (objconv.Decoder{Parser: NewParser(bytes.NewReader(b))}).DecodeMap(func(kd objconv.Decoder, vd objconv.Decoder) (err error) {
+		var k string
+		var x interface{}
+
+		if err = kd.Decode(&k); err != nil {
+			return
+		}
+
+		if err = vd.Decode(&x); err != nil {
+			return
+		}
+
+		keys = append(keys, k)
+		values = append(values, x)
+		return
+	})
+
+	if err != nil {
+		err = fmt.Errorf("objconv/json: cannot encode %T as a table row: %s", v, err)
+	}
+	return
+}
+
+func indexOfColumn(columns []string, name string) int {
+	for i, c := range columns {
+		if c == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// A TableDecoder decodes values from JSON Lines written by a TableEncoder, the
+// values are reconstructed as maps of the column names to the values of each
+// line before being decoded.
+//
+// Instances of TableDecoder are not safe for use by multiple goroutines.
+type TableDecoder struct {
+	d       objconv.Decoder
+	columns []string
+	header  bool
+}
+
+// NewTableDecoder returns a new table decoder that parses values from r.
+func NewTableDecoder(r io.Reader) *TableDecoder {
+	return &TableDecoder{d: objconv.Decoder{Parser: NewParser(r)}}
+}
+
+// Columns returns the column names read from the header, or an error if the
+// header could not be read.
+func (d *TableDecoder) Columns() ([]string, error) {
+	if err := d.readHeader(); err != nil {
+		return nil, err
+	}
+	return d.columns, nil
+}
+
+// Decode reads the next line of the table and decodes it into v.
+//
+// The method returns io.EOF when there are no more lines to read.
+func (d *TableDecoder) Decode(v interface{}) (err error) {
+	var row []interface{}
+
+	if err = d.readHeader(); err != nil {
+		return
+	}
+
+	if err = d.d.Decode(&row); err != nil {
+		return
+	}
+
+	if len(row) > len(d.columns) {
+		return fmt.Errorf("objconv/json: table row has %d values but the header has %d columns", len(row), len(d.columns))
+	}
+
+	m := make(map[string]interface{}, len(row))
+
+	for i, x := range row {
+		m[d.columns[i]] = x
+	}
+
+	return objconv.NewDecoder(objconv.NewValueParser(m)).Decode(v)
+}
+
+func (d *TableDecoder) readHeader() (err error) {
+	if !d.header {
+		if err = d.d.Decode(&d.columns); err == nil {
+			d.header = true
+		}
+	}
+	return
+}
@@ -0,0 +1,97 @@
+package json
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestTable(t *testing.T) {
+	type row struct {
+		ID   int     `objconv:"id"`
+		Name string  `objconv:"name"`
+		Tags []int   `objconv:"tags,omitempty"`
+		Rate float64 `objconv:"rate"`
+	}
+
+	rows := []row{
+		{ID: 1, Name: "A", Tags: []int{1, 2}, Rate: 0.5},
+		{ID: 2, Name: "B", Rate: 1},
+	}
+
+	b := &bytes.Buffer{}
+	e := NewTableEncoder(b)
+	e.Columns = []string{"id", "name", "tags", "rate"}
+
+	for _, r := range rows {
+		if err := e.Encode(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if s := b.String(); s != `["id","name","tags","rate"]
+[1,"A",[1,2],0.5]
+[2,"B",null,1]
+` {
+		t.Errorf("bad output:\n%s", s)
+	}
+
+	d := NewTableDecoder(b)
+
+	columns, err := d.Columns()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(columns, e.Columns) {
+		t.Error("bad columns:", columns)
+	}
+
+	var out []row
+	var r row
+
+	for err = d.Decode(&r); err == nil; err = d.Decode(&r) {
+		out = append(out, r)
+		r = row{}
+	}
+
+	if err != io.EOF {
+		t.Error(err)
+	}
+
+	if !reflect.DeepEqual(out, rows) {
+		t.Errorf("%#v != %#v", out, rows)
+	}
+}
+
+func TestTableColumnsFromFirstValue(t *testing.T) {
+	b := &bytes.Buffer{}
+	e := NewTableEncoder(b)
+
+	if err := e.Encode(struct{ B, A int }{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.Encode(map[string]int{"A": 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.Encode(map[string]int{"C": 3}); err == nil {
+		t.Error("expected an error when encoding a field which is not a column")
+	}
+
+	if s := b.String(); s != "[\"B\",\"A\"]\n[1,2]\n[null,3]\n" {
+		t.Errorf("bad output:\n%s", s)
+	}
+
+	var m map[string]interface{}
+
+	if err := NewTableDecoder(b).Decode(&m); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(m, map[string]interface{}{"B": int64(1), "A": int64(2)}) {
+		t.Errorf("%#v", m)
+	}
+}
@@ -0,0 +1,233 @@
+package json
+
+import (
+	stdjson "encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/segmentio/objconv"
+)
+
+// TokenParser is a parser which reads values from the token stream produced by
+// the decoder of the standard encoding/json package.
+//
+// It is slower than Parser, but can be used as a reference implementation or
+// to compare the behavior of both tokenizers.
+type TokenParser struct {
+	d   *stdjson.Decoder
+	tok stdjson.Token // token peeked by ParseType
+	ok  bool          // true if tok is set
+}
+
+// NewTokenParser returns a new token parser that takes input from r.
+func NewTokenParser(r io.Reader) *TokenParser {
+	d := stdjson.NewDecoder(r)
+	d.UseNumber()
+	return &TokenParser{d: d}
+}
+
+func (p *TokenParser) peek() (tok stdjson.Token, err error) {
+	if !p.ok {
+		if p.tok, err = p.d.Token(); err != nil {
+			return
+		}
+		p.ok = true
+	}
+	tok = p.tok
+	return
+}
+
+func (p *TokenParser) next() (tok stdjson.Token, err error) {
+	if tok, err = p.peek(); err == nil {
+		p.tok, p.ok = nil, false
+	}
+	return
+}
+
+func (p *TokenParser) nextDelim(d stdjson.Delim) (err error) {
+	var tok stdjson.Token
+
+	if tok, err = p.next(); err != nil {
+		return
+	}
+
+	if tok != d {
+		err = fmt.Errorf("objconv/json: expected '%s' but found %v", d, tok)
+	}
+	return
+}
+
+func (p *TokenParser) nextNumber() (n stdjson.Number, err error) {
+	var tok stdjson.Token
+	var ok bool
+
+	if tok, err = p.next(); err != nil {
+		return
+	}
+
+	if n, ok = tok.(stdjson.Number); !ok {
+		err = fmt.Errorf("objconv/json: expected number but found %v", tok)
+	}
+	return
+}
+
+func (p *TokenParser) ParseType() (t objconv.Type, err error) {
+	var tok stdjson.Token
+
+	if tok, err = p.peek(); err != nil {
+		return
+	}
+
+	switch v := tok.(type) {
+	case nil:
+		t = objconv.Nil
+
+	case bool:
+		t = objconv.Bool
+
+	case string:
+		t = objconv.String
+
+	case stdjson.Number:
+		if strings.ContainsAny(string(v), ".eE") {
+			t = objconv.Float
+		} else {
+			t = objconv.Int
+		}
+
+	case stdjson.Delim:
+		switch v {
+		case '[':
+			t = objconv.Array
+		case '{':
+			t = objconv.Map
+		default:
+			err = fmt.Errorf("objconv/json: expected token but found '%s'", v)
+		}
+	}
+
+	return
+}
+
+func (p *TokenParser) ParseNil() (err error) {
+	var tok stdjson.Token
+
+	if tok, err = p.next(); err == nil && tok != nil {
+		err = fmt.Errorf("objconv/json: expected null but found %v", tok)
+	}
+	return
+}
+
+func (p *TokenParser) ParseBool() (v bool, err error) {
+	var tok stdjson.Token
+	var ok bool
+
+	if tok, err = p.next(); err != nil {
+		return
+	}
+
+	if v, ok = tok.(bool); !ok {
+		err = fmt.Errorf("objconv/json: expected boolean but found %v", tok)
+	}
+	return
+}
+
+func (p *TokenParser) ParseInt() (v int64, err error) {
+	var n stdjson.Number
+
+	if n, err = p.nextNumber(); err == nil {
+		v, err = strconv.ParseInt(string(n), 10, 64)
+	}
+	return
+}
+
+func (p *TokenParser) ParseUint() (v uint64, err error) {
+	panic("objconv/json: ParseUint should never be called because JSON has no unsigned integer type, this is likely a bug in the decoder code")
+}
+
+func (p *TokenParser) ParseFloat() (v float64, err error) {
+	var n stdjson.Number
+
+	if n, err = p.nextNumber(); err == nil {
+		v, err = n.Float64()
+	}
+	return
+}
+
+func (p *TokenParser) ParseString() (v []byte, err error) {
+	var tok stdjson.Token
+
+	if tok, err = p.next(); err != nil {
+		return
+	}
+
+	if s, ok := tok.(string); !ok {
+		err = fmt.Errorf("objconv/json: expected string but found %v", tok)
+	} else {
+		v = []byte(s)
+	}
+	return
+}
+
+func (p *TokenParser) ParseBytes() (v []byte, err error) {
+	panic("objconv/json: ParseBytes should never be called because JSON has no bytes, this is likely a bug in the decoder code")
+}
+
+func (p *TokenParser) ParseTime() (v time.Time, err error) {
+	panic("objconv/json: ParseTime should never be called because JSON has no time type, this is likely a bug in the decoder code")
+}
+
+func (p *TokenParser) ParseDuration() (v time.Duration, err error) {
+	panic("objconv/json: ParseDuration should never be called because JSON has no duration type, this is likely a bug in the decoder code")
+}
+
+func (p *TokenParser) ParseError() (v error, err error) {
+	panic("objconv/json: ParseError should never be called because JSON has no error type, this is likely a bug in the decoder code")
+}
+
+func (p *TokenParser) ParseArrayBegin() (n int, err error) {
+	return -1, p.nextDelim('[')
+}
+
+func (p *TokenParser) ParseArrayEnd(n int) error {
+	return p.nextDelim(']')
+}
+
+func (p *TokenParser) ParseArrayNext(n int) (err error) {
+	var tok stdjson.Token
+
+	// The standard decoder consumes the commas between values, the end of the
+	// array is detected by looking at the next token.
+	if tok, err = p.peek(); err == nil && tok == stdjson.Delim(']') {
+		err = objconv.End
+	}
+	return
+}
+
+func (p *TokenParser) ParseMapBegin() (n int, err error) {
+	return -1, p.nextDelim('{')
+}
+
+func (p *TokenParser) ParseMapEnd(n int) error {
+	return p.nextDelim('}')
+}
+
+func (p *TokenParser) ParseMapValue(n int) error {
+	return nil // the standard decoder consumes the colons after keys
+}
+
+func (p *TokenParser) ParseMapNext(n int) (err error) {
+	var tok stdjson.Token
+
+	if tok, err = p.peek(); err == nil && tok == stdjson.Delim('}') {
+		err = objconv.End
+	}
+	return
+}
+
+func (p *TokenParser) DecodeBytes(b []byte) ([]byte, error) {
+	return decodeBytes(b)
+}
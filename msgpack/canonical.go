@@ -0,0 +1,87 @@
+package msgpack
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"sort"
+)
+
+// NewCanonicalEmitter returns a new emitter that outputs a deterministic
+// encoding of values: integers use their shortest form (non-negative values
+// are always encoded as unsigned integers), floating point numbers are encoded
+// in single precision when it preserves their value, and map entries are
+// sorted by the bytewise lexicographic order of the encoding of their keys.
+//
+// The content of maps is cached in memory until the end of the map is reached.
+func NewCanonicalEmitter(w io.Writer) *Emitter {
+	e := NewEmitter(w)
+	e.canonical = true
+	return e
+}
+
+func (e *Emitter) beginCanonicalMap() {
+	c := contextPool.Get().(*context)
+	c.b.Truncate(0)
+	c.n = 0
+	c.keys = append(c.keys[:0], 0)
+	c.values = c.values[:0]
+	c.w = e.w
+	e.w = &c.b
+	e.stack = append(e.stack, c)
+}
+
+func (e *Emitter) endCanonicalMap() (err error) {
+	i := len(e.stack) - 1
+	c := e.stack[i]
+	e.stack = e.stack[:i]
+	e.w = c.w
+
+	b := c.b.Bytes()
+	n := len(c.values)
+	entries := make([]mapEntry, n)
+
+	for i := range entries {
+		end := len(b)
+		if i+1 < n {
+			end = c.keys[i+1]
+		}
+		entries[i] = mapEntry{
+			key:   b[c.keys[i]:c.values[i]],
+			entry: b[c.keys[i]:end],
+		}
+	}
+
+	sort.Slice(entries, func(i int, j int) bool {
+		return bytes.Compare(entries[i].key, entries[j].key) < 0
+	})
+
+	if err = e.emitMap(n); err == nil {
+		for _, entry := range entries {
+			if _, err = e.w.Write(entry.entry); err != nil {
+				break
+			}
+		}
+	}
+
+	contextPool.Put(c)
+	return
+}
+
+type mapEntry struct {
+	key   []byte
+	entry []byte
+}
+
+func (e *Emitter) emitShortestFloat(v float64) (err error) {
+	if f := float32(v); float64(f) == v || math.IsNaN(v) {
+		e.b[0] = Float32
+		putUint32(e.b[1:], math.Float32bits(f))
+		_, err = e.w.Write(e.b[:5])
+	} else {
+		e.b[0] = Float64
+		putUint64(e.b[1:], math.Float64bits(v))
+		_, err = e.w.Write(e.b[:9])
+	}
+	return
+}
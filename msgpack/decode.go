@@ -10,12 +10,12 @@ import (
 
 // NewDecoder returns a new MessagePack decoder that parses values from r.
 func NewDecoder(r io.Reader) *objconv.Decoder {
-	return objconv.NewDecoder(NewParser(r))
+	return Codec.NewDecoder(r)
 }
 
 // NewStreamDecoder returns a new MessagePack stream decoder that parses values from r.
 func NewStreamDecoder(r io.Reader) *objconv.StreamDecoder {
-	return objconv.NewStreamDecoder(NewParser(r))
+	return Codec.NewStreamDecoder(r)
 }
 
 // Unmarshal decodes a MessagePack representation of v from b.
@@ -25,12 +25,18 @@ type Emitter struct {
 	// sback is used as the initial backing array for the stack slice to avoid
 	// dynamic memory allocations for the most common use cases.
 	sback [8]*context
+
+	// In canonical mode maps are also cached on the stack so their entries
+	// can be sorted, see NewCanonicalEmitter.
+	canonical bool
 }
 
 type context struct {
-	b bytes.Buffer // buffer where the array elements are cached
-	w io.Writer    // the previous writer where b will be flushed
-	n int          // the number of elements written to the array
+	b      bytes.Buffer // buffer where the array elements are cached
+	w      io.Writer    // the previous writer where b will be flushed
+	n      int          // the number of elements written to the array
+	keys   []int        // offsets of the map keys in b (canonical mode only)
+	values []int        // offsets of the map values in b (canonical mode only)
 }
 
 func NewEmitter(w io.Writer) *Emitter {
@@ -63,6 +69,17 @@ func (e *Emitter) EmitBool(v bool) (err error) {
 func (e *Emitter) EmitInt(v int64, _ int) (err error) {
 	n := 0
 
+	if e.canonical {
+		if v >= 0 {
+			return e.EmitUint(uint64(v), 64)
+		}
+		if v == -32 {
+			e.b[0] = byte(v) | NegativeFixintTag
+			_, err = e.w.Write(e.b[:1])
+			return
+		}
+	}
+
 	if v >= 0 {
 		switch {
 		case v <= objutil.Int8Max:
@@ -121,6 +138,10 @@ func (e *Emitter) EmitUint(v uint64, _ int) (err error) {
 	n := 0
 
 	switch {
+	case e.canonical && v <= objutil.Int8Max:
+		e.b[0] = byte(v) | PositiveFixintTag
+		n = 1
+
 	case v <= objutil.Uint8Max:
 		e.b[0] = Uint8
 		e.b[1] = byte(v)
@@ -147,6 +168,10 @@ func (e *Emitter) EmitUint(v uint64, _ int) (err error) {
 }
 
 func (e *Emitter) EmitFloat(v float64, bitSize int) (err error) {
+	if e.canonical && bitSize != 32 {
+		return e.emitShortestFloat(v)
+	}
+
 	switch bitSize {
 	case 32:
 		e.b[0] = Float32
@@ -274,6 +299,63 @@ func (e *Emitter) EmitTime(v time.Time) (err error) {
 	return
 }
 
+// EmitExtension writes an extension of type typ with data as payload, the
+// payload is written with a fixext format when its length allows it.
+//
+// Extensions are not part of the objconv.Emitter interface, programs emitting
+// them must call this method directly.
+func (e *Emitter) EmitExtension(typ int8, data []byte) (err error) {
+	n := len(data)
+
+	switch n {
+	case 1, 2, 4, 8, 16:
+		e.b[0] = Fixext1 + byte(bits(n))
+		e.b[1] = byte(typ)
+		n = 2
+
+	default:
+		switch {
+		case n <= objutil.Uint8Max:
+			e.b[0] = Ext8
+			e.b[1] = byte(n)
+			e.b[2] = byte(typ)
+			n = 3
+
+		case n <= objutil.Uint16Max:
+			e.b[0] = Ext16
+			putUint16(e.b[1:], uint16(n))
+			e.b[3] = byte(typ)
+			n = 4
+
+		case n <= objutil.Uint32Max:
+			e.b[0] = Ext32
+			putUint32(e.b[1:], uint32(n))
+			e.b[5] = byte(typ)
+			n = 6
+
+		default:
+			err = fmt.Errorf("objconv/msgpack: extension of length %d is too long to be encoded", n)
+			return
+		}
+	}
+
+	if _, err = e.w.Write(e.b[:n]); err != nil {
+		return
+	}
+
+	_, err = e.w.Write(data)
+	return
+}
+
+// bits returns the base 2 logarithm of n, which must be a power of two.
+func bits(n int) (b int) {
+	for n > 1 {
+		n >>= 1
+		b++
+	}
+	return
+}
+
 func (e *Emitter) EmitDuration(v time.Duration) (err error) {
 	return e.EmitString(string(objutil.AppendDuration(e.b[:0], v)))
 }
@@ -329,11 +411,20 @@ func (e *Emitter) EmitArrayNext() (err error) {
 }
 
 func (e *Emitter) EmitMapBegin(n int) (err error) {
+	if e.canonical {
+		e.beginCanonicalMap()
+		return
+	}
+
 	if n < 0 {
 		err = fmt.Errorf("objconv/msgpack: encoding maps of unknown length is not supported (n = %d)", n)
 		return
 	}
 
+	return e.emitMap(n)
+}
+
+func (e *Emitter) emitMap(n int) (err error) {
 	switch {
 	case n <= 15:
 		e.b[0] = byte(n) | FixmapTag
@@ -359,14 +450,25 @@ func (e *Emitter) EmitMapBegin(n int) (err error) {
 }
 
 func (e *Emitter) EmitMapEnd() (err error) {
+	if e.canonical {
+		err = e.endCanonicalMap()
+	}
 	return
 }
 
 func (e *Emitter) EmitMapValue() (err error) {
+	if e.canonical {
+		c := e.stack[len(e.stack)-1]
+		c.values = append(c.values, c.b.Len())
+	}
 	return
 }
 
 func (e *Emitter) EmitMapNext() (err error) {
+	if e.canonical {
+		c := e.stack[len(e.stack)-1]
+		c.keys = append(c.keys, c.b.Len())
+	}
 	return
 }
 
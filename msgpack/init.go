@@ -12,6 +12,13 @@ var Codec = objconv.Codec{
 	NewParser:  func(r io.Reader) objconv.Parser { return NewParser(r) },
 }
 
+// CanonicalCodec for the deterministic encoding of the MessagePack format, see
+// NewCanonicalEmitter.
+var CanonicalCodec = objconv.Codec{
+	NewEmitter: func(w io.Writer) objconv.Emitter { return NewCanonicalEmitter(w) },
+	NewParser:  func(r io.Reader) objconv.Parser { return NewParser(r) },
+}
+
 func init() {
 	for _, name := range [...]string{
 		"application/msgpack",
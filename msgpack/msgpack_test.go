@@ -1,8 +1,16 @@
 package msgpack
 
 import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"reflect"
+	"runtime"
+	"strings"
 	"testing"
 
+	"github.com/segmentio/objconv"
 	"github.com/segmentio/objconv/objtests"
 )
 
@@ -13,3 +21,181 @@ func TestCodec(t *testing.T) {
 func BenchmarkCodec(b *testing.B) {
 	objtests.BenchmarkCodec(b, Codec)
 }
+
+func TestCanonicalCodec(t *testing.T) {
+	objtests.TestCodec(t, CanonicalCodec)
+}
+
+func TestCanonicalEmitter(t *testing.T) {
+	tests := []struct {
+		v interface{}
+		s string
+	}{
+		{int64(1), "01"},
+		{int64(200), "ccc8"},
+		{int64(-32), "e0"},
+		{uint64(300), "cd012c"},
+		{1.5, "ca3fc00000"},
+		{1.1, "cb3ff199999999999a"},
+		{
+			map[interface{}]interface{}{false: 0, "aa": 1, "z": 2, -1: 3, 100: 4, 10: 5},
+			"86" + "0a05" + "6404" + "a17a02" + "a2616101" + "c200" + "ff03",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.s, func(t *testing.T) {
+			b := &bytes.Buffer{}
+
+			if err := objconv.NewEncoder(NewCanonicalEmitter(b)).Encode(test.v); err != nil {
+				t.Fatal(err)
+			}
+
+			if s := hex.EncodeToString(b.Bytes()); s != test.s {
+				t.Error(s)
+			}
+		})
+	}
+}
+
+func TestExtension(t *testing.T) {
+	tests := []struct {
+		typ  int8
+		data []byte
+		s    string
+	}{
+		{1, []byte{0xAA}, "d401aa"},
+		{2, []byte{1, 2, 3, 4, 5, 6, 7, 8}, "d702" + "0102030405060708"},
+		{3, []byte{}, "c70003"},
+		{-2, []byte{1, 2, 3}, "c703fe010203"},
+		{4, bytes.Repeat([]byte{0}, 300), "c8012c04" + strings.Repeat("00", 300)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.s[:6], func(t *testing.T) {
+			b := &bytes.Buffer{}
+
+			if err := NewEmitter(b).EmitExtension(test.typ, test.data); err != nil {
+				t.Fatal(err)
+			}
+
+			if s := hex.EncodeToString(b.Bytes()); s != test.s {
+				t.Error(s)
+			}
+
+			// Extensions are decoded as Extension values by default.
+			var v Extension
+
+			if err := objconv.NewDecoder(NewParser(b)).Decode(&v); err != nil {
+				t.Fatal(err)
+			}
+
+			if v.Type != test.typ || !bytes.Equal(v.Data, test.data) {
+				t.Errorf("%#v", v)
+			}
+		})
+	}
+}
+
+func TestParserExtension(t *testing.T) {
+	b := &bytes.Buffer{}
+	e := NewEmitter(b)
+
+	e.EmitArrayBegin(3)
+	e.EmitExtension(1, []byte{1, 2})
+	e.EmitArrayNext()
+	e.EmitExtension(2, []byte("hello"))
+	e.EmitArrayNext()
+	e.EmitInt(42, 64)
+	e.EmitArrayEnd()
+	e.EmitExtension(3, nil)
+
+	p := NewParser(bytes.NewReader(b.Bytes()))
+	p.Extension = func(typ int8, data []byte) (interface{}, error) {
+		switch typ {
+		case 1:
+			return map[string]int{"x": int(data[0]), "y": int(data[1])}, nil
+		case 2:
+			return string(data), nil
+		}
+		return nil, errors.New("unsupported extension")
+	}
+
+	var v []interface{}
+	d := objconv.NewDecoder(p)
+
+	if err := d.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(v, []interface{}{
+		map[interface{}]interface{}{"x": int64(1), "y": int64(2)},
+		"hello",
+		int64(42),
+	}) {
+		t.Errorf("%#v", v)
+	}
+
+	var x interface{}
+
+	if err := d.Decode(&x); err == nil || err.Error() != "unsupported extension" {
+		t.Error("bad error:", err)
+	}
+}
+
+func TestDecodeRemaining(t *testing.T) {
+	b, err := Marshal(map[string]int{"answer": 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var m map[string]int
+
+	rest, err := NewDecoder(bytes.NewReader(append(b, "\x00trailer"...))).DecodeRemaining(&m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m["answer"] != 42 {
+		t.Error("bad value:", m)
+	}
+
+	if string(rest) != "\x00trailer" {
+		t.Errorf("bad remaining bytes: %q", rest)
+	}
+}
+
+func TestDecodeLargeLength(t *testing.T) {
+	// The bin 32 value declares a length of 2 GiB, the parser must not allocate it
+	// before the bytes are read from the input.
+	header := []byte{0xc6, 0x7f, 0xff, 0xff, 0xff}
+
+	tests := []struct {
+		in  []byte
+		max int64
+		err error
+	}{
+		{in: append(header, 'A'), err: io.ErrUnexpectedEOF},
+		{in: append(header, make([]byte, 4096)...), max: 1024, err: objconv.ErrTooLarge},
+	}
+
+	for _, test := range tests {
+		var v []byte
+		var m1, m2 runtime.MemStats
+
+		d := NewDecoder(bytes.NewReader(test.in))
+		d.MaxBytes = test.max
+
+		runtime.ReadMemStats(&m1)
+		err := d.Decode(&v)
+		runtime.ReadMemStats(&m2)
+
+		if err != test.err {
+			t.Errorf("expected %v but got %v", test.err, err)
+		}
+
+		if n := m2.TotalAlloc - m1.TotalAlloc; n > 1<<20 {
+			t.Error("too many bytes allocated:", n)
+		}
+	}
+}
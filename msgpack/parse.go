@@ -8,14 +8,42 @@ import (
 	"time"
 
 	"github.com/segmentio/objconv"
+	"github.com/segmentio/objconv/objutil"
 )
 
+// Parser implements a MessagePack parser that satisfies the objconv.Parser
+// interface.
+//
+// The timestamp extension type is parsed as time values, other extension types
+// are passed to the Extension function of the parser.
 type Parser struct {
+	// Extension is called with the type and payload of extension types that
+	// aren't supported natively, the value it returns is exposed by the parser
+	// in place of the extension. When nil, extensions are exposed as Extension
+	// values.
+	//
+	// The payload is a copy which the function may retain.
+	Extension func(typ int8, data []byte) (interface{}, error)
+
 	r io.Reader // reader to load bytes from
 	i int       // offset of the first unread byte in b
 	j int       // offset + 1 of the last unread byte in b
 	s []byte    // string buffer
 	b [240]byte // read buffer
+
+	raw objutil.RawRecorder // records raw bytes between BeginRaw and EndRaw
+
+	// The value returned for an extension is parsed by ext until the end of
+	// the value is reached, depth is the nesting level of arrays and maps.
+	ext   *objconv.ValueParser
+	depth int
+}
+
+// Extension represents a MessagePack extension type which the parser doesn't
+// support natively, see Parser.Extension.
+type Extension struct {
+	Type int8   `objconv:"type"`
+	Data []byte `objconv:"data"`
 }
 
 func NewParser(r io.Reader) *Parser {
@@ -26,13 +54,45 @@ func (p *Parser) Reset(r io.Reader) {
 	p.r = r
 	p.i = 0
 	p.j = 0
+	p.ext = nil
+	p.depth = 0
 }
 
 func (p *Parser) Buffered() io.Reader {
 	return bytes.NewReader(p.b[p.i:p.j])
 }
 
+// InMemory returns true if the parser reads from a buffer held in memory.
+func (p *Parser) InMemory() bool {
+	return objutil.InMemory(p.r)
+}
+
+// Remaining returns the bytes of the input that haven't been parsed yet, the
+// parser has reached the end of its input after the method returns.
+func (p *Parser) Remaining() (rest []byte, err error) {
+	if rest, err = objutil.Remaining(p.r, p.b[p.i:p.j]); err == nil {
+		p.Reset(p.r)
+	}
+	return
+}
+
+// BeginRaw starts recording the raw bytes of the values being parsed.
+func (p *Parser) BeginRaw() {
+	p.r = p.raw.Begin(p.r, p.b[p.i:p.j])
+}
+
+// EndRaw stops recording and returns the raw bytes of the values parsed since
+// BeginRaw was called.
+func (p *Parser) EndRaw() (raw []byte) {
+	raw, p.r = p.raw.End(p.j - p.i)
+	return
+}
+
 func (p *Parser) ParseType() (objconv.Type, error) {
+	if p.ext != nil {
+		return p.ext.ParseType()
+	}
+
 	b, err := p.peek(1)
 	if err != nil {
 		return objconv.Unknown, err
@@ -90,12 +150,12 @@ func (p *Parser) ParseType() (objconv.Type, error) {
 			return objconv.Unknown, err
 		}
 
-		switch tag = b[1]; int8(tag) {
+		switch int8(b[1]) {
 		case ExtTime:
 			return objconv.Time, nil
 
 		default:
-			return objconv.Unknown, fmt.Errorf("objconv/msgpack: unsupported extension '%d'", tag)
+			return p.parseExtension(2, fixextLength(tag), int8(b[1]))
 		}
 
 	case Ext8, Ext16, Ext32: // continue after the switch
@@ -116,26 +176,89 @@ func (p *Parser) ParseType() (objconv.Type, error) {
 		return objconv.Unknown, err
 	}
 
-	switch tag = b[len(b)-1]; int8(tag) {
-	case ExtTime:
-		return objconv.Time, nil
+	if typ := int8(b[len(b)-1]); typ != ExtTime {
+		var n int
+
+		switch len(b) {
+		case 3:
+			n = int(b[1])
+		case 4:
+			n = int(getUint16(b[1:]))
+		default:
+			n = int(getUint32(b[1:]))
+		}
+
+		return p.parseExtension(len(b), n, typ)
+	}
+
+	return objconv.Time, nil
+}
+
+// parseExtension reads an extension with a header of size h and a payload of
+// n bytes, and prepares the parser to expose the value that it represents.
+func (p *Parser) parseExtension(h int, n int, typ int8) (objconv.Type, error) {
+	p.i += h
+
+	b, err := p.read(n)
+	if err != nil {
+		return objconv.Unknown, err
+	}
+
+	var v interface{}
+	data := append(make([]byte, 0, n), b...)
+
+	if p.Extension == nil {
+		v = Extension{Type: typ, Data: data}
+	} else if v, err = p.Extension(typ, data); err != nil {
+		return objconv.Unknown, err
+	}
+
+	p.ext = objconv.NewValueParser(v)
+	return p.ext.ParseType()
+}
+
+// next is called after a value was parsed from an extension, the parser
+// returns to reading its input after the end of the top-level value.
+func (p *Parser) next() {
+	if p.depth == 0 {
+		p.ext = nil
 	}
+}
 
-	return objconv.Unknown, fmt.Errorf("objconv/msgpack: unknown extension '%d'", tag)
+func fixextLength(tag byte) int {
+	return 1 << (tag - Fixext1)
 }
 
 func (p *Parser) ParseNil() (err error) {
+	if p.ext != nil {
+		err = p.ext.ParseNil()
+		p.next()
+		return
+	}
+
 	p.i++
 	return
 }
 
 func (p *Parser) ParseBool() (v bool, err error) {
+	if p.ext != nil {
+		v, err = p.ext.ParseBool()
+		p.next()
+		return
+	}
+
 	v = p.b[p.i] == True
 	p.i++
 	return
 }
 
 func (p *Parser) ParseInt() (v int64, err error) {
+	if p.ext != nil {
+		v, err = p.ext.ParseInt()
+		p.next()
+		return
+	}
+
 	tag := p.b[p.i]
 	p.i++
 
@@ -180,6 +303,12 @@ func (p *Parser) ParseInt() (v int64, err error) {
 }
 
 func (p *Parser) ParseUint() (v uint64, err error) {
+	if p.ext != nil {
+		v, err = p.ext.ParseUint()
+		p.next()
+		return
+	}
+
 	tag := p.b[p.i]
 	p.i++
 
@@ -217,6 +346,12 @@ func (p *Parser) ParseUint() (v uint64, err error) {
 }
 
 func (p *Parser) ParseFloat() (v float64, err error) {
+	if p.ext != nil {
+		v, err = p.ext.ParseFloat()
+		p.next()
+		return
+	}
+
 	tag := p.b[p.i]
 	p.i++
 
@@ -246,6 +381,12 @@ func (p *Parser) ParseFloat() (v float64, err error) {
 }
 
 func (p *Parser) ParseString() (v []byte, err error) {
+	if p.ext != nil {
+		v, err = p.ext.ParseString()
+		p.next()
+		return
+	}
+
 	tag := p.b[p.i]
 	p.i++
 
@@ -284,6 +425,12 @@ func (p *Parser) ParseString() (v []byte, err error) {
 }
 
 func (p *Parser) ParseBytes() (v []byte, err error) {
+	if p.ext != nil {
+		v, err = p.ext.ParseBytes()
+		p.next()
+		return
+	}
+
 	tag := p.b[p.i]
 	p.i++
 
@@ -317,6 +464,12 @@ func (p *Parser) ParseBytes() (v []byte, err error) {
 }
 
 func (p *Parser) ParseTime() (v time.Time, err error) {
+	if p.ext != nil {
+		v, err = p.ext.ParseTime()
+		p.next()
+		return
+	}
+
 	tag := p.b[p.i]
 	p.i++
 
@@ -367,14 +520,31 @@ func (p *Parser) ParseTime() (v time.Time, err error) {
 }
 
 func (p *Parser) ParseDuration() (v time.Duration, err error) {
+	if p.ext != nil {
+		v, err = p.ext.ParseDuration()
+		p.next()
+		return
+	}
+
 	panic("objconv/msgpack: ParseDuration should never be called because MessagePack has no duration type, this is likely a bug in the decoder code")
 }
 
 func (p *Parser) ParseError() (v error, err error) {
+	if p.ext != nil {
+		v, err = p.ext.ParseError()
+		p.next()
+		return
+	}
+
 	panic("objconv/msgpack: ParseError should never be called because MessagePack has no error type, this is likely a bug in the decoder code")
 }
 
 func (p *Parser) ParseArrayBegin() (n int, err error) {
+	if p.ext != nil {
+		p.depth++
+		return p.ext.ParseArrayBegin()
+	}
+
 	tag := p.b[p.i]
 	p.i++
 
@@ -408,14 +578,27 @@ func (p *Parser) ParseArrayBegin() (n int, err error) {
 }
 
 func (p *Parser) ParseArrayEnd(n int) (err error) {
+	if p.ext != nil {
+		err = p.ext.ParseArrayEnd(n)
+		p.depth--
+		p.next()
+	}
 	return
 }
 
 func (p *Parser) ParseArrayNext(n int) (err error) {
+	if p.ext != nil {
+		err = p.ext.ParseArrayNext(n)
+	}
 	return
 }
 
 func (p *Parser) ParseMapBegin() (n int, err error) {
+	if p.ext != nil {
+		p.depth++
+		return p.ext.ParseMapBegin()
+	}
+
 	tag := p.b[p.i]
 	p.i++
 
@@ -449,14 +632,25 @@ func (p *Parser) ParseMapBegin() (n int, err error) {
 }
 
 func (p *Parser) ParseMapEnd(n int) (err error) {
+	if p.ext != nil {
+		err = p.ext.ParseMapEnd(n)
+		p.depth--
+		p.next()
+	}
 	return
 }
 
 func (p *Parser) ParseMapValue(n int) (err error) {
+	if p.ext != nil {
+		err = p.ext.ParseMapValue(n)
+	}
 	return
 }
 
 func (p *Parser) ParseMapNext(n int) (err error) {
+	if p.ext != nil {
+		err = p.ext.ParseMapNext(n)
+	}
 	return
 }
 
@@ -475,18 +669,17 @@ func (p *Parser) read(n int) (b []byte, err error) {
 		return
 	}
 
-	if cap(p.s) < n {
-		p.s = make([]byte, n, align(n, 1024))
-	} else {
-		p.s = p.s[:n]
-	}
-
-	copy(p.s, p.b[p.i:p.j])
-	n = p.j - p.i
+	// The length comes from the input, the buffer grows as bytes are read
+	// instead of being allocated upfront.
+	p.s = append(p.s[:0], p.b[p.i:p.j]...)
+	n -= p.j - p.i
 	p.i = 0
 	p.j = 0
 
-	if _, err = io.ReadFull(p.r, p.s[n:]); err != nil {
+	if p.s, err = objutil.AppendFull(p.s, p.r, n); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
 		return
 	}
 
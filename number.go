@@ -0,0 +1,47 @@
+package objconv
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Number represents a number in the literal form it had in the input of a
+// parser. Numbers are decoded into empty interfaces as values of this type
+// when Decoder.UseNumber is set, and it can also be used as the type of struct
+// fields or other destinations to decode numbers without losing precision.
+//
+// When encoded, emitters that support it write the number as-is, others
+// receive it as an integer or a floating point value.
+type Number string
+
+// String returns the literal form of the number.
+func (n Number) String() string {
+	return string(n)
+}
+
+// Int64 returns the number as an int64, or an error if it is not an integer or
+// doesn't fit in an int64.
+func (n Number) Int64() (int64, error) {
+	return strconv.ParseInt(string(n), 10, 64)
+}
+
+// Uint64 returns the number as a uint64, or an error if it is not a positive
+// integer or doesn't fit in a uint64.
+func (n Number) Uint64() (uint64, error) {
+	return strconv.ParseUint(string(n), 10, 64)
+}
+
+// Float64 returns the number as a float64, or an error if it is not a valid
+// number. Large integers may lose precision.
+func (n Number) Float64() (float64, error) {
+	return strconv.ParseFloat(string(n), 64)
+}
+
+// typ returns the type of the number, Float if it has a decimal point or an
+// exponent, Int otherwise.
+func (n Number) typ() Type {
+	if strings.ContainsAny(string(n), ".eE") {
+		return Float
+	}
+	return Int
+}
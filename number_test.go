@@ -0,0 +1,106 @@
+package objconv
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNumberDecode(t *testing.T) {
+	tests := []struct {
+		in  interface{}
+		out interface{}
+	}{
+		{Number("42"), int64(42)},
+		{Number("-1.5e3"), -1500.0},
+		{int64(-7), Number("-7")},
+		{uint64(7), Number("7")},
+		{0.25, Number("0.25")},
+		{Number("0.1"), Number("0.1")},
+	}
+
+	for _, test := range tests {
+		t.Run(reflect.ValueOf(test.in).String(), func(t *testing.T) {
+			v := reflect.New(reflect.TypeOf(test.out))
+			d := NewDecoder(NewValueParser(test.in))
+
+			if err := d.Decode(v.Interface()); err != nil {
+				t.Fatal(err)
+			}
+
+			if x := v.Elem().Interface(); !reflect.DeepEqual(x, test.out) {
+				t.Errorf("%#v != %#v", x, test.out)
+			}
+		})
+	}
+}
+
+func TestNumberDecodeErrors(t *testing.T) {
+	var n Number
+
+	if err := NewDecoder(NewValueParser("42")).Decode(&n); err == nil {
+		t.Error("expected an error when decoding a string into a number")
+	}
+
+	var i int64
+
+	if err := NewDecoder(NewValueParser(Number("1e400"))).Decode(&i); err == nil {
+		t.Error("expected an error when decoding a float number into an integer")
+	}
+}
+
+func TestDecoderUseNumber(t *testing.T) {
+	in := map[string]interface{}{
+		"a": int64(1),
+		"b": []interface{}{uint64(2), 3.5},
+		"c": "4",
+	}
+
+	var v interface{}
+
+	d := NewDecoder(NewValueParser(in))
+	d.UseNumber = true
+
+	if err := d.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	out := map[interface{}]interface{}{
+		"a": Number("1"),
+		"b": []interface{}{Number("2"), Number("3.5")},
+		"c": "4",
+	}
+
+	if !reflect.DeepEqual(v, out) {
+		t.Errorf("%#v", v)
+	}
+}
+
+func TestNumberEncode(t *testing.T) {
+	tests := []struct {
+		in  Number
+		out interface{}
+	}{
+		{"42", int64(42)},
+		{"18446744073709551615", uint64(18446744073709551615)},
+		{"1e3", 1000.0},
+		{"100000000000000000000", 1e20},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.in), func(t *testing.T) {
+			e := NewValueEmitter()
+
+			if err := NewEncoder(e).Encode(test.in); err != nil {
+				t.Fatal(err)
+			}
+
+			if v := e.Value(); !reflect.DeepEqual(v, test.out) {
+				t.Errorf("%#v != %#v", v, test.out)
+			}
+		})
+	}
+
+	if err := NewEncoder(NewValueEmitter()).Encode(Number("abc")); err == nil {
+		t.Error("expected an error when encoding an invalid number")
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math/big"
 	"net"
+	"net/http"
 	"net/mail"
 	"net/url"
 	"reflect"
@@ -146,11 +148,23 @@ var TestValues = [...]interface{}{
 		Zone: "zone",
 	},
 	net.IPv4(127, 0, 0, 1),
+	net.HardwareAddr{0x01, 0x23, 0x45, 0x67, 0x89, 0xab},
+	net.HardwareAddr{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef},
 
 	// url
 	parseURL("http://localhost:4242/hello/world?answer=42#question"),
 	parseQuery("answer=42&message=Hello+World"),
 
+	// http
+	http.Header{
+		"Content-Type":    {"application/json"},
+		"X-Forwarded-For": {"127.0.0.1", "127.0.0.2"},
+	},
+
+	// big
+	parseBigInt("-1234567890123456789012345678901234567890"),
+	parseBigInt("42"),
+
 	// mail
 	parseEmail("git@github.com"),
 	parseEmailList("Alice <alice@example.com>, Bob <bob@example.com>, Eve <eve@example.com>"),
@@ -179,6 +193,7 @@ func testName(v interface{}) string {
 func TestCodec(t *testing.T, codec objconv.Codec) {
 	t.Run("Values", func(t *testing.T) { testCodecValues(t, codec) })
 	t.Run("Stream", func(t *testing.T) { testCodecStream(t, codec) })
+	t.Run("Raw", func(t *testing.T) { testCodecRaw(t, codec) })
 }
 
 func newValue(model interface{}) reflect.Value {
@@ -220,6 +235,58 @@ func testCodecValues(t *testing.T, codec objconv.Codec) {
 	}
 }
 
+func testCodecRaw(t *testing.T, codec objconv.Codec) {
+	b := &bytes.Buffer{}
+	b.Grow(1024)
+
+	if _, ok := codec.NewParser(b).(interface {
+		BeginRaw()
+		EndRaw() []byte
+	}); !ok {
+		t.Skip("the parser doesn't support capturing raw bytes")
+	}
+
+	for _, v1 := range TestValues {
+		t.Run(testName(v1), func(t *testing.T) {
+			b.Reset()
+			e := objconv.NewEncoder(codec.NewEmitter(b))
+			d := objconv.NewDecoder(codec.NewParser(b))
+			v2 := newValue(v1)
+			v3 := newValue(v1)
+
+			if err := e.Encode(v1); err != nil {
+				t.Error(err)
+				return
+			}
+
+			raw, err := d.DecodeWithRaw(v2.Interface())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			d = objconv.NewDecoder(codec.NewParser(bytes.NewReader(raw)))
+
+			if err := d.Decode(v3.Interface()); err != nil {
+				t.Error(err)
+				return
+			}
+
+			x1 := v1
+			x2 := v2.Elem().Interface()
+			x3 := v3.Elem().Interface()
+
+			if !reflect.DeepEqual(x1, x2) {
+				t.Errorf("%#v", x2)
+			}
+
+			if !reflect.DeepEqual(x1, x3) {
+				t.Errorf("%#v (raw = %q)", x3, raw)
+			}
+		})
+	}
+}
+
 func testCodecStream(t *testing.T, codec objconv.Codec) {
 	r, w := io.Pipe()
 	defer r.Close()
@@ -381,6 +448,11 @@ func parseQuery(s string) url.Values {
 	return v
 }
 
+func parseBigInt(s string) big.Int {
+	i, _ := new(big.Int).SetString(s, 10)
+	return *i
+}
+
 func parseEmail(s string) mail.Address {
 	a, _ := mail.ParseAddress(s)
 	return *a
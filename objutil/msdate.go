@@ -0,0 +1,82 @@
+package objutil
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+)
+
+// IsMSDate returns true if b has the shape of a Microsoft JSON date, which is
+// a string of the form "/Date(<milliseconds>)/" optionally followed by a
+// timezone offset inside the parenthesis, like "/Date(1609459200000+0100)/".
+func IsMSDate(b []byte) bool {
+	return bytes.HasPrefix(b, msDatePrefix) && bytes.HasSuffix(b, msDateSuffix)
+}
+
+// ParseMSDate parses a Microsoft JSON date from b.
+//
+// The milliseconds are always relative to the unix epoch in UTC, the optional
+// timezone offset only sets the location of the returned time.
+func ParseMSDate(b []byte) (t time.Time, err error) {
+	if !IsMSDate(b) {
+		err = errorInvalidMSDate(b)
+		return
+	}
+
+	s := b[len(msDatePrefix) : len(b)-len(msDateSuffix)]
+	z := []byte(nil)
+
+	// The first character may be a minus sign for dates before the epoch, so
+	// the search for the timezone offset starts at index 1.
+	if len(s) != 0 {
+		if i := bytes.IndexAny(s[1:], "+-"); i >= 0 {
+			s, z = s[:i+1], s[i+1:]
+		}
+	}
+
+	var ms int64
+	var tz *time.Location
+
+	if ms, err = ParseInt(s); err != nil {
+		err = errorInvalidMSDate(b)
+		return
+	}
+
+	if tz = time.UTC; z != nil {
+		if len(z) != 5 {
+			err = errorInvalidMSDate(b)
+			return
+		}
+
+		var hh, mm int64
+
+		if hh, err = ParseInt(z[1:3]); err != nil {
+			err = errorInvalidMSDate(b)
+			return
+		}
+
+		if mm, err = ParseInt(z[3:5]); err != nil || mm >= 60 {
+			err = errorInvalidMSDate(b)
+			return
+		}
+
+		off := int(hh*3600 + mm*60)
+		if z[0] == '-' {
+			off = -off
+		}
+
+		tz = time.FixedZone("", off)
+	}
+
+	t = time.Unix(ms/1000, (ms%1000)*int64(time.Millisecond)).In(tz)
+	return
+}
+
+func errorInvalidMSDate(b []byte) error {
+	return fmt.Errorf("objconv: %#v is not a valid Microsoft JSON date", string(b))
+}
+
+var (
+	msDatePrefix = []byte("/Date(")
+	msDateSuffix = []byte(")/")
+)
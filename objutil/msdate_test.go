@@ -0,0 +1,62 @@
+package objutil
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseMSDate(t *testing.T) {
+	tests := []struct {
+		s string
+		t time.Time
+	}{
+		{"/Date(0)/", time.Unix(0, 0).UTC()},
+		{"/Date(1609459200000)/", time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"/Date(1609459200123)/", time.Date(2021, 1, 1, 0, 0, 0, 123e6, time.UTC)},
+		{"/Date(-86400000)/", time.Date(1969, 12, 31, 0, 0, 0, 0, time.UTC)},
+		{"/Date(1609459200000+0100)/", time.Date(2021, 1, 1, 1, 0, 0, 0, time.FixedZone("", 3600))},
+		{"/Date(1609459200000-0530)/", time.Date(2020, 12, 31, 18, 30, 0, 0, time.FixedZone("", -19800))},
+	}
+
+	for _, test := range tests {
+		t.Run(test.s, func(t *testing.T) {
+			v, err := ParseMSDate([]byte(test.s))
+
+			if err != nil {
+				t.Error(err)
+			}
+
+			if !v.Equal(test.t) {
+				t.Errorf("%v != %v", v, test.t)
+			}
+
+			_, off1 := v.Zone()
+			_, off2 := test.t.Zone()
+
+			if off1 != off2 {
+				t.Errorf("bad timezone offset: %d != %d", off1, off2)
+			}
+		})
+	}
+}
+
+func TestParseMSDateInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"Date(0)",
+		"/Date()/",
+		"/Date(abc)/",
+		"/Date(0+01)/",
+		"/Date(0+01AB)/",
+		"/Date(0+0160)/",
+		"2021-01-01T00:00:00Z",
+	}
+
+	for _, test := range tests {
+		t.Run(test, func(t *testing.T) {
+			if _, err := ParseMSDate([]byte(test)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package objutil
+
+import "io"
+
+// RawRecorder is a helper type that parsers can use to capture the raw bytes
+// of the values they parse.
+//
+// The recorder wraps the reader that the parser loads bytes from, it expects
+// to be told how many bytes were buffered by the parser when the recording
+// starts and when it ends, so the bytes that were read ahead can be removed
+// from the output.
+type RawRecorder struct {
+	r io.Reader
+	b []byte
+}
+
+// Begin starts recording the bytes read from r, buffered are the bytes loaded
+// in the parser's memory buffer that haven't been consumed yet.
+//
+// The method returns the reader that the parser must use until End is called.
+func (rec *RawRecorder) Begin(r io.Reader, buffered []byte) io.Reader {
+	rec.r = r
+	rec.b = append(make([]byte, 0, 2*len(buffered)), buffered...)
+	return rec
+}
+
+// End stops the recording and returns the raw bytes consumed by the parser,
+// buffered is the number of bytes loaded in the parser's memory buffer that
+// haven't been consumed yet.
+//
+// The method returns the reader that was passed to Begin, which the parser
+// must use to continue reading. The returned byte slice is owned by the
+// caller.
+func (rec *RawRecorder) End(buffered int) (raw []byte, r io.Reader) {
+	raw, r = rec.b[:len(rec.b)-buffered], rec.r
+	rec.r, rec.b = nil, nil
+	return
+}
+
+// Read satisfies the io.Reader interface.
+func (rec *RawRecorder) Read(b []byte) (n int, err error) {
+	n, err = rec.r.Read(b)
+	rec.b = append(rec.b, b[:n]...)
+	return
+}
@@ -0,0 +1,31 @@
+package objutil
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestRawRecorder(t *testing.T) {
+	rec := RawRecorder{}
+	src := bytes.NewReader([]byte("World!, How are you?"))
+
+	r := rec.Begin(src, []byte("Hello "))
+	b := make([]byte, 12)
+
+	if _, err := io.ReadFull(r, b); err != nil {
+		t.Error(err)
+	}
+
+	// Simulates a parser which consumed "Hello World!" and has ", How " left
+	// in its read buffer.
+	raw, r := rec.End(len(", How "))
+
+	if string(raw) != "Hello World!" {
+		t.Errorf("bad raw bytes: %q", raw)
+	}
+
+	if r != src {
+		t.Error("the original reader wasn't returned")
+	}
+}
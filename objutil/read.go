@@ -0,0 +1,32 @@
+package objutil
+
+import (
+	"bytes"
+	"io"
+)
+
+// AppendFull reads exactly n bytes from r and appends them to b, returning the
+// extended byte slice.
+//
+// Unlike io.ReadFull the memory isn't allocated upfront when b doesn't have the
+// capacity to hold the n bytes, the slice grows as bytes are read. Parsers use
+// it to load values whose length is declared by the input, so a short input
+// announcing a large length doesn't cause a large allocation. The errors are
+// the same as io.ReadFull, io.EOF if no bytes were read, io.ErrUnexpectedEOF if
+// r ended before n bytes were read.
+func AppendFull(b []byte, r io.Reader, n int) ([]byte, error) {
+	if i := len(b); n <= cap(b)-i {
+		b = b[:i+n]
+		c, err := io.ReadFull(r, b[i:])
+		return b[:i+c], err
+	}
+
+	buf := bytes.NewBuffer(b)
+	c, err := io.CopyN(buf, r, int64(n))
+
+	if err == io.EOF && c != 0 {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return buf.Bytes(), err
+}
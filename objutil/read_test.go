@@ -0,0 +1,52 @@
+package objutil
+
+import (
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestAppendFull(t *testing.T) {
+	tests := []struct {
+		b   []byte
+		in  string
+		n   int
+		out string
+		err error
+	}{
+		{nil, "Hello World!", 5, "Hello", nil},
+		{[]byte("Hello "), "World!", 6, "Hello World!", nil},
+		{make([]byte, 0, 64), "Hello World!", 12, "Hello World!", nil},
+		{nil, "Hello", 6, "Hello", io.ErrUnexpectedEOF},
+		{make([]byte, 0, 64), "Hello", 6, "Hello", io.ErrUnexpectedEOF},
+		{nil, "", 1, "", io.EOF},
+	}
+
+	for _, test := range tests {
+		b, err := AppendFull(test.b, strings.NewReader(test.in), test.n)
+
+		if err != test.err {
+			t.Errorf("%q: bad error: %v", test.in, err)
+		}
+
+		if string(b) != test.out {
+			t.Errorf("%q: bad bytes: %q", test.in, b)
+		}
+	}
+}
+
+func TestAppendFullLargeLength(t *testing.T) {
+	var m1, m2 runtime.MemStats
+	runtime.ReadMemStats(&m1)
+
+	if _, err := AppendFull(nil, strings.NewReader("Hello"), 1<<31-1); err != io.ErrUnexpectedEOF {
+		t.Error("bad error:", err)
+	}
+
+	runtime.ReadMemStats(&m2)
+
+	if n := m2.TotalAlloc - m1.TotalAlloc; n > 1<<20 {
+		t.Errorf("too many bytes were allocated: %d", n)
+	}
+}
@@ -0,0 +1,37 @@
+package objutil
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// InMemory returns true if r reads from a buffer held in memory, which is the
+// case of *bytes.Reader, *bytes.Buffer and *strings.Reader values, and of
+// readers that wrap one of those and expose it with an Unwrap method.
+func InMemory(r io.Reader) bool {
+	for {
+		switch x := r.(type) {
+		case *bytes.Reader, *bytes.Buffer, *strings.Reader:
+			return true
+		case interface{ Unwrap() io.Reader }:
+			r = x.Unwrap()
+		default:
+			return false
+		}
+	}
+}
+
+// Remaining returns the bytes that a parser hasn't consumed yet, which are the
+// buffered bytes it has loaded in memory followed by the bytes left in r.
+//
+// The function reads r until the end, it should only be used with readers for
+// which InMemory returns true. The returned byte slice is owned by the caller.
+func Remaining(r io.Reader, buffered []byte) ([]byte, error) {
+	rest, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return append(append(make([]byte, 0, len(buffered)+len(rest)), buffered...), rest...), nil
+}
@@ -0,0 +1,50 @@
+package objutil
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+type unwrapReader struct{ io.Reader }
+
+func (r unwrapReader) Unwrap() io.Reader { return r.Reader }
+
+func TestInMemory(t *testing.T) {
+	tests := []struct {
+		r  io.Reader
+		ok bool
+	}{
+		{bytes.NewReader(nil), true},
+		{bytes.NewBufferString(""), true},
+		{strings.NewReader(""), true},
+		{unwrapReader{strings.NewReader("")}, true},
+		{unwrapReader{iotest.OneByteReader(strings.NewReader(""))}, false},
+		{iotest.OneByteReader(strings.NewReader("")), false},
+	}
+
+	for _, test := range tests {
+		if ok := InMemory(test.r); ok != test.ok {
+			t.Errorf("InMemory(%T): expected %t but got %t", test.r, test.ok, ok)
+		}
+	}
+}
+
+func TestRemaining(t *testing.T) {
+	r := strings.NewReader("World!")
+
+	rest, err := Remaining(r, []byte("Hello "))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(rest) != "Hello World!" {
+		t.Errorf("bad remaining bytes: %q", rest)
+	}
+
+	if r.Len() != 0 {
+		t.Error("the reader wasn't consumed")
+	}
+}
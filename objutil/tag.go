@@ -12,6 +12,91 @@ type Tag struct {
 
 	// Omitzero is true if the tag had `omitzero` set.
 	Omitzero bool
+
+	// AsString is true if the tag had `string` set, booleans and numbers are
+	// then serialized as strings, like with the standard encoding/json
+	// package.
+	AsString bool
+
+	// JSON is true if the tag had `json` set, the field is then serialized as
+	// a string containing its JSON representation.
+	JSON bool
+
+	// Format is the name of the format set by the `format` option, the field
+	// is then serialized as a byte slice containing its representation in
+	// this format.
+	Format string
+
+	// Transforms lists the `base64` and `gzip` options in the order they were
+	// found in the tag, the field is then serialized as a string or byte slice
+	// which must be decoded with these transforms, in this order, to obtain its
+	// representation.
+	Transforms []string
+
+	// Secret is true if the tag had `secret` set, the values of the field are
+	// then marked as secrets when passed to the value function of encoders.
+	Secret bool
+
+	// MapKey is true if the tag had `mapkey` set, the field then receives the
+	// key of the map entry that its struct was decoded from when a map is
+	// decoded into a slice of structs.
+	MapKey bool
+
+	// Positional is true if the tag had `positional` set, the field then
+	// receives one of the leading elements of arrays that its struct is
+	// decoded from, in the order of the positional fields.
+	Positional bool
+
+	// Rest is true if the tag had `rest` set, the field then receives the
+	// elements following the positional ones as a map built from key/value
+	// pairs.
+	Rest bool
+
+	// Lenient is true if the tag had `lenient` set, the field is then decoded
+	// as if the Lenient option of the decoder was enabled.
+	Lenient bool
+
+	// Readonly is true if the tag had `readonly` set, the field is then
+	// encoded but never decoded, the values found at its key in decoded maps
+	// are discarded.
+	Readonly bool
+
+	// DateField and TimeField are the keys set by the `datefield` and
+	// `timefield` options, a time value is then decoded by combining the
+	// date and time found at these keys.
+	DateField string
+	TimeField string
+
+	// DateLayout and TimeLayout are the layouts set by the `datelayout` and
+	// `timelayout` options, used to parse the values of DateField and
+	// TimeField.
+	DateLayout string
+	TimeLayout string
+
+	// Method is the name of the method set by the `method` option on a blank
+	// field, the struct is then encoded with a virtual field holding the
+	// value returned by this method.
+	Method string
+
+	// Min and Max are the bounds set by the `min` and `max` options, MinLen
+	// and MaxLen the length bounds set by the `minlen` and `maxlen` options,
+	// and Pattern the regular expression set by the `pattern` option. Decoded
+	// values of the field are validated against these constraints.
+	//
+	// The pattern option must be the last one of the tag since regular
+	// expressions may contain commas, the rest of the tag is the pattern.
+	Min     string
+	Max     string
+	MinLen  string
+	MaxLen  string
+	Pattern string
+
+	// Default is the value set by the `default` option, which is assigned to
+	// the field when it is absent from the decoded map. DefaultEmpty is true if
+	// the tag had `defaultempty` set, the default value is then also assigned
+	// when the decoded value is empty. Default values cannot contain commas.
+	Default      string
+	DefaultEmpty bool
 }
 
 // ParseTag parses a raw tag obtained from a struct field, returning the results
@@ -20,24 +105,123 @@ func ParseTag(s string) Tag {
 	var name string
 	var omitzero bool
 	var omitempty bool
+	var asString bool
+	var json bool
+	var secret bool
+	var transforms []string
+	var lenient bool
+	var readonly bool
+	var mapKey bool
+	var positional bool
+	var rest bool
+	var format string
+	var dateField, timeField string
+	var dateLayout, timeLayout string
+	var method string
+	var min, max, minLen, maxLen, pattern string
+	var dflt string
+	var dfltEmpty bool
 
 	name, s = parseNextTagToken(s)
 
 	for len(s) != 0 {
 		var token string
+
+		if strings.HasPrefix(s, "pattern=") {
+			pattern, s = s[len("pattern="):], ""
+			break
+		}
+
 		switch token, s = parseNextTagToken(s); token {
 		case "omitempty":
 			omitempty = true
 		case "omitzero":
 			omitzero = true
+		case "string":
+			asString = true
+		case "json":
+			json = true
+		case "secret":
+			secret = true
+		case "lenient":
+			lenient = true
+		case "readonly":
+			readonly = true
+		case "mapkey":
+			mapKey = true
+		case "positional":
+			positional = true
+		case "rest":
+			rest = true
+		case "defaultempty":
+			dfltEmpty = true
+		case "base64", "gzip":
+			transforms = append(transforms, token)
+		default:
+			switch key, value := parseTagOption(token); key {
+			case "datefield":
+				dateField = value
+			case "timefield":
+				timeField = value
+			case "datelayout":
+				dateLayout = value
+			case "timelayout":
+				timeLayout = value
+			case "format":
+				format = value
+			case "method":
+				method = value
+			case "min":
+				min = value
+			case "max":
+				max = value
+			case "minlen":
+				minLen = value
+			case "maxlen":
+				maxLen = value
+			case "default":
+				dflt = value
+			}
 		}
 	}
 
 	return Tag{
-		Name:      name,
-		Omitempty: omitempty,
-		Omitzero:  omitzero,
+		Name:       name,
+		Omitempty:  omitempty,
+		Omitzero:   omitzero,
+		AsString:   asString,
+		JSON:       json,
+		Format:     format,
+		Transforms: transforms,
+		Secret:     secret,
+		MapKey:     mapKey,
+		Positional: positional,
+		Rest:       rest,
+		Lenient:    lenient,
+		Readonly:   readonly,
+		DateField:  dateField,
+		TimeField:  timeField,
+		DateLayout: dateLayout,
+		TimeLayout: timeLayout,
+		Method:     method,
+		Min:        min,
+		Max:        max,
+		MinLen:     minLen,
+		MaxLen:     maxLen,
+		Pattern:    pattern,
+
+		Default:      dflt,
+		DefaultEmpty: dfltEmpty,
+	}
+}
+
+func parseTagOption(token string) (key string, value string) {
+	if split := strings.IndexByte(token, '='); split < 0 {
+		key = token
+	} else {
+		key, value = token[:split], token[split+1:]
 	}
+	return
 }
 
 func parseNextTagToken(s string) (token string, next string) {
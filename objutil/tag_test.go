@@ -1,6 +1,9 @@
 package objutil
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+)
 
 func TestParseTag(t *testing.T) {
 	tests := []struct {
@@ -31,11 +34,83 @@ func TestParseTag(t *testing.T) {
 			tag: "-,omitempty",
 			res: Tag{Name: "-", Omitempty: true},
 		},
+		{
+			tag: "payload,json",
+			res: Tag{Name: "payload", JSON: true},
+		},
+		{
+			tag: "payload,omitempty,json",
+			res: Tag{Name: "payload", Omitempty: true, JSON: true},
+		},
+		{
+			tag: "payload,base64,gzip",
+			res: Tag{Name: "payload", Transforms: []string{"base64", "gzip"}},
+		},
+		{
+			tag: "payload,gzip,json,base64",
+			res: Tag{Name: "payload", JSON: true, Transforms: []string{"gzip", "base64"}},
+		},
+		{
+			tag: "blob,format=msgpack,base64",
+			res: Tag{Name: "blob", Format: "msgpack", Transforms: []string{"base64"}},
+		},
+		{
+			tag: "-,mapkey",
+			res: Tag{Name: "-", MapKey: true},
+		},
+		{
+			tag: "cursor,positional",
+			res: Tag{Name: "cursor", Positional: true},
+		},
+		{
+			tag: "full_name,method=FullName,omitempty",
+			res: Tag{Name: "full_name", Method: "FullName", Omitempty: true},
+		},
+		{
+			tag: "age,min=0,max=150",
+			res: Tag{Name: "age", Min: "0", Max: "150"},
+		},
+		{
+			tag: "code,minlen=2,maxlen=8,omitempty,pattern=^[a-z]{2,8}$",
+			res: Tag{Name: "code", MinLen: "2", MaxLen: "8", Omitempty: true, Pattern: "^[a-z]{2,8}$"},
+		},
+		{
+			tag: "env,default=production",
+			res: Tag{Name: "env", Default: "production"},
+		},
+		{
+			tag: "port,default=8080,defaultempty",
+			res: Tag{Name: "port", Default: "8080", DefaultEmpty: true},
+		},
+		{
+			tag: "attrs,rest",
+			res: Tag{Name: "attrs", Rest: true},
+		},
+		{
+			tag: "active,lenient",
+			res: Tag{Name: "active", Lenient: true},
+		},
+		{
+			tag: "etag,readonly",
+			res: Tag{Name: "etag", Readonly: true},
+		},
+		{
+			tag: "password,secret",
+			res: Tag{Name: "password", Secret: true},
+		},
+		{
+			tag: "ts,datefield=date,timefield=time",
+			res: Tag{Name: "ts", DateField: "date", TimeField: "time"},
+		},
+		{
+			tag: "ts,datefield=d,datelayout=01/02/2006,timelayout=15:04",
+			res: Tag{Name: "ts", DateField: "d", DateLayout: "01/02/2006", TimeLayout: "15:04"},
+		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.tag, func(t *testing.T) {
-			if res := ParseTag(test.tag); res != test.res {
+			if res := ParseTag(test.tag); !reflect.DeepEqual(res, test.res) {
 				t.Errorf("%s: %#v != %#v", test.tag, test.res, res)
 			}
 		})
@@ -21,12 +21,21 @@ type Parser interface {
 	ParseBool() (bool, error)
 
 	// ParseInt parses an integer value.
+	//
+	// The integer types are 64 bits wide, integers of the input that don't
+	// fit must be reported as errors by ParseInt and ParseUint instead of
+	// being truncated. Parsers may let decoders retrieve such numbers in their
+	// literal form by implementing ParseNumber, see Decoder.UseNumber.
 	ParseInt() (int64, error)
 
 	// ParseBool parses an unsigned integer value.
 	ParseUint() (uint64, error)
 
 	// ParseBool parses a floating point value.
+	//
+	// Numbers that have more precision than a float64 are rounded to the
+	// nearest value, and numbers beyond the range of float64 are reported as
+	// errors.
 	ParseFloat() (float64, error)
 
 	// ParseBool parses a string value.
@@ -123,3 +132,49 @@ type bytesDecoder interface {
 	// before the value is stored.
 	DecodeBytes([]byte) ([]byte, error)
 }
+
+// The rawParser interface may optionnaly be implemented by a Parser to support
+// capturing the raw bytes of the values it parses, which is used to implement
+// Decoder.DecodeWithRaw.
+type rawParser interface {
+	// BeginRaw starts recording the raw bytes of the values being parsed.
+	BeginRaw()
+
+	// EndRaw stops recording and returns the raw bytes of the values parsed
+	// since the call to BeginRaw, the returned slice is owned by the caller.
+	EndRaw() []byte
+}
+
+// The numberParser interface may optionnaly be implemented by a Parser to
+// expose numbers in the literal form they have in the input, which is used to
+// decode Number values without loss of precision.
+type numberParser interface {
+	// ParseNumber is called instead of ParseInt, ParseUint or ParseFloat when
+	// ParseType returned one of Int, Uint or Float and the value is decoded
+	// into a Number. The returned byte slice may be pointing at an internal
+	// memory buffer, the decoder will make a copy of the value.
+	ParseNumber() ([]byte, error)
+}
+
+// The Positioner interface may optionnaly be implemented by a Parser to report
+// the position of the values it parses in its input, which is used to
+// implement Decoder.RecordSpans.
+type Positioner interface {
+	// Offset returns the offset of the next byte to be parsed from the
+	// beginning of the input.
+	Offset() int
+}
+
+// The remainderParser interface may optionnaly be implemented by a Parser to
+// expose the bytes of its input that follow the values it parsed, which is used
+// to implement Decoder.DecodeRemaining.
+type remainderParser interface {
+	// InMemory returns true if the input of the parser is held in memory,
+	// Remaining must only be called when it is.
+	InMemory() bool
+
+	// Remaining returns the bytes of the input that haven't been parsed yet,
+	// the whole input is consumed after the method returns. The returned slice
+	// is owned by the caller.
+	Remaining() ([]byte, error)
+}
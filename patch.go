@@ -0,0 +1,507 @@
+package objconv
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PatchOp represents an operation of a JSON Patch (RFC 6902).
+//
+// Values are represented by the generic types produced by a ValueEmitter, and
+// paths are JSON Pointers (RFC 6901), see Decoder.DecodePointer.
+type PatchOp struct {
+	// Op is the name of the operation, one of "add", "remove", "replace",
+	// "move", "copy" or "test".
+	Op string `objconv:"op"`
+
+	// Path is the location that the operation applies to.
+	Path string `objconv:"path"`
+
+	// From is the location that the value is taken from by "move" and "copy"
+	// operations.
+	From string `objconv:"from"`
+
+	// Value is the value used by "add", "replace" and "test" operations.
+	Value interface{} `objconv:"value"`
+}
+
+// EncodeValue satisfies the ValueEncoder interface, only the members that the
+// operation uses are encoded.
+func (op PatchOp) EncodeValue(e Encoder) error {
+	keys := []string{"op", "path"}
+	values := []interface{}{op.Op, op.Path}
+
+	switch op.Op {
+	case "move", "copy":
+		keys, values = append(keys, "from"), append(values, op.From)
+	case "add", "replace", "test":
+		keys, values = append(keys, "value"), append(values, op.Value)
+	}
+
+	i := 0
+	return e.EncodeMap(len(keys), func(k Encoder, v Encoder) (err error) {
+		if err = k.Encode(keys[i]); err != nil {
+			return
+		}
+		if err = v.Encode(values[i]); err != nil {
+			return
+		}
+		i++
+		return
+	})
+}
+
+// Diff returns the JSON Patch operations which transform a into b.
+//
+// The values are compared by their serialized representations, the same way
+// Equal does. Maps are compared key by key, and arrays element by element,
+// with elements added or removed at the end when their lengths differ, which
+// produces valid but not always minimal patches (inserting an element at the
+// front of an array replaces all the elements after it, for example).
+//
+// Operations on map keys are ordered by key so the result is deterministic.
+func Diff(a interface{}, b interface{}) ([]PatchOp, error) {
+	va, err := serializedValueOf(a)
+	if err != nil {
+		return nil, err
+	}
+
+	vb, err := serializedValueOf(b)
+	if err != nil {
+		return nil, err
+	}
+
+	return diff(nil, "", va, vb), nil
+}
+
+func diff(ops []PatchOp, path string, a interface{}, b interface{}) []PatchOp {
+	if (Comparer{}).equal(a, b) {
+		return ops
+	}
+
+	switch va := a.(type) {
+	case map[interface{}]interface{}:
+		if vb, ok := b.(map[interface{}]interface{}); ok {
+			return diffMaps(ops, path, va, vb)
+		}
+
+	case []interface{}:
+		if vb, ok := b.([]interface{}); ok {
+			return diffArrays(ops, path, va, vb)
+		}
+	}
+
+	return append(ops, PatchOp{Op: "replace", Path: path, Value: b})
+}
+
+func diffMaps(ops []PatchOp, path string, a map[interface{}]interface{}, b map[interface{}]interface{}) []PatchOp {
+	ka := pointerKeys(a)
+	kb := pointerKeys(b)
+
+	for _, k := range sortedPointerKeys(ka) {
+		p := path + "/" + escapePointerToken(k)
+
+		if vb, ok := kb[k]; ok {
+			ops = diff(ops, p, ka[k], vb)
+		} else {
+			ops = append(ops, PatchOp{Op: "remove", Path: p})
+		}
+	}
+
+	for _, k := range sortedPointerKeys(kb) {
+		if _, ok := ka[k]; !ok {
+			ops = append(ops, PatchOp{Op: "add", Path: path + "/" + escapePointerToken(k), Value: kb[k]})
+		}
+	}
+
+	return ops
+}
+
+func diffArrays(ops []PatchOp, path string, a []interface{}, b []interface{}) []PatchOp {
+	n := len(a)
+
+	if n > len(b) {
+		n = len(b)
+	}
+
+	for i := 0; i != n; i++ {
+		ops = diff(ops, path+"/"+strconv.Itoa(i), a[i], b[i])
+	}
+
+	// Elements are removed starting from the end so the indexes of the ones
+	// that remain to be removed don't change.
+	for i := len(a) - 1; i >= n; i-- {
+		ops = append(ops, PatchOp{Op: "remove", Path: path + "/" + strconv.Itoa(i)})
+	}
+
+	for i := n; i < len(b); i++ {
+		ops = append(ops, PatchOp{Op: "add", Path: path + "/" + strconv.Itoa(i), Value: b[i]})
+	}
+
+	return ops
+}
+
+// pointerKeys returns the values of m indexed by the representation of their
+// keys in JSON Pointers.
+func pointerKeys(m map[interface{}]interface{}) map[string]interface{} {
+	keys := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		keys[pointerKey(k)] = v
+	}
+	return keys
+}
+
+func sortedPointerKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var pointerTokenEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+func escapePointerToken(s string) string {
+	return pointerTokenEscaper.Replace(s)
+}
+
+// ApplyPatch applies the JSON Patch operations to doc and returns the result.
+//
+// The document is not modified, operations are applied to its serialized
+// representation, which is what the returned value is made of. Operations are
+// applied in order, if one of them fails (including "test" operations) the
+// whole patch fails and no value is returned.
+func ApplyPatch(doc interface{}, patch []PatchOp) (interface{}, error) {
+	v, err := serializedValueOf(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, op := range patch {
+		if v, err = applyPatchOp(v, op); err != nil {
+			return nil, fmt.Errorf("objconv: patch operation %d (%s %s): %s", i, op.Op, op.Path, strings.TrimPrefix(err.Error(), "objconv: "))
+		}
+	}
+
+	return v, nil
+}
+
+func applyPatchOp(doc interface{}, op PatchOp) (interface{}, error) {
+	tokens, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		value, err := serializedValueOf(op.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		switch op.Op {
+		case "add":
+			return patchAdd(doc, op.Path, tokens, value)
+		case "replace":
+			return patchReplace(doc, op.Path, tokens, value)
+		}
+
+		v, err := patchGet(doc, op.Path, tokens)
+		if err != nil {
+			return nil, err
+		}
+
+		if !(Comparer{}).equal(v, value) {
+			return nil, fmt.Errorf("objconv: test failed, the value at %q is different", op.Path)
+		}
+
+		return doc, nil
+
+	case "remove":
+		doc, _, err = patchRemove(doc, op.Path, tokens)
+		return doc, err
+
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+
+		var v interface{}
+
+		if op.Op == "move" {
+			if op.From == op.Path {
+				return doc, nil
+			}
+
+			if strings.HasPrefix(op.Path, op.From+"/") {
+				return nil, fmt.Errorf("objconv: cannot move %q into one of its children", op.From)
+			}
+
+			// The value is removed before being added back, so the path
+			// is resolved against the document without the value.
+			if doc, v, err = patchRemove(doc, op.From, from); err != nil {
+				return nil, err
+			}
+		} else {
+			if v, err = patchGet(doc, op.From, from); err != nil {
+				return nil, err
+			}
+			v = copyValue(v)
+		}
+
+		return patchAdd(doc, op.Path, tokens, v)
+
+	default:
+		return nil, fmt.Errorf("objconv: unknown patch operation %q", op.Op)
+	}
+}
+
+func patchGet(doc interface{}, ptr string, tokens []string) (v interface{}, err error) {
+	v = doc
+
+	for i := range tokens {
+		if v, err = patchLookup(v, ptr, tokens, i); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func patchAdd(doc interface{}, ptr string, tokens []string, value interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+
+	return patchParent(doc, ptr, tokens, 0, func(parent interface{}, i int) (interface{}, error) {
+		switch p := parent.(type) {
+		case map[interface{}]interface{}:
+			p[patchMapKey(p, tokens[i])] = value
+			return p, nil
+
+		case []interface{}:
+			j := len(p)
+
+			if tokens[i] != "-" {
+				var ok bool
+
+				if j, ok = pointerIndex(tokens[i]); !ok || j > len(p) {
+					return nil, pointerNotFound(ptr, i, fmt.Sprintf("cannot insert at %q in array of length %d", tokens[i], len(p)))
+				}
+			}
+
+			p = append(p, nil)
+			copy(p[j+1:], p[j:])
+			p[j] = value
+			return p, nil
+		}
+
+		return nil, pointerNotFound(ptr, i, "cannot add a value to "+typeOfValue(parent))
+	})
+}
+
+func patchReplace(doc interface{}, ptr string, tokens []string, value interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+
+	return patchParent(doc, ptr, tokens, 0, func(parent interface{}, i int) (interface{}, error) {
+		if _, err := patchLookup(parent, ptr, tokens, i); err != nil {
+			return nil, err
+		}
+		return patchSet(parent, tokens[i], value), nil
+	})
+}
+
+func patchRemove(doc interface{}, ptr string, tokens []string) (_ interface{}, removed interface{}, err error) {
+	if len(tokens) == 0 {
+		return nil, nil, fmt.Errorf("objconv: cannot remove the whole document")
+	}
+
+	doc, err = patchParent(doc, ptr, tokens, 0, func(parent interface{}, i int) (interface{}, error) {
+		var err error
+
+		if removed, err = patchLookup(parent, ptr, tokens, i); err != nil {
+			return nil, err
+		}
+
+		switch p := parent.(type) {
+		case map[interface{}]interface{}:
+			delete(p, patchMapKey(p, tokens[i]))
+			return p, nil
+
+		default: // []interface{}, patchLookup validated the index
+			a := p.([]interface{})
+			j, _ := pointerIndex(tokens[i])
+			return append(a[:j], a[j+1:]...), nil
+		}
+	})
+
+	return doc, removed, err
+}
+
+// patchParent calls f with the parent of the value that tokens refer to, and
+// the index of the last token. Since f may return a different value (arrays
+// are reallocated when growing) the values are set back in their parent on
+// the way out.
+func patchParent(v interface{}, ptr string, tokens []string, i int, f func(interface{}, int) (interface{}, error)) (interface{}, error) {
+	if i == len(tokens)-1 {
+		return f(v, i)
+	}
+
+	child, err := patchLookup(v, ptr, tokens, i)
+	if err != nil {
+		return nil, err
+	}
+
+	if child, err = patchParent(child, ptr, tokens, i+1, f); err != nil {
+		return nil, err
+	}
+
+	return patchSet(v, tokens[i], child), nil
+}
+
+// patchLookup returns the value that the token at index i refers to in v.
+func patchLookup(v interface{}, ptr string, tokens []string, i int) (interface{}, error) {
+	tok := tokens[i]
+
+	switch x := v.(type) {
+	case map[interface{}]interface{}:
+		for k, v := range x {
+			if pointerKey(k) == tok {
+				return v, nil
+			}
+		}
+		return nil, pointerNotFound(ptr, i, fmt.Sprintf("no key %q in map", tok))
+
+	case []interface{}:
+		j, ok := pointerIndex(tok)
+
+		if !ok {
+			return nil, pointerNotFound(ptr, i, fmt.Sprintf("%q is not an array index", tok))
+		}
+
+		if j >= len(x) {
+			return nil, pointerNotFound(ptr, i, fmt.Sprintf("index %d out of range of array of length %d", j, len(x)))
+		}
+
+		return x[j], nil
+	}
+
+	return nil, pointerNotFound(ptr, i, fmt.Sprintf("cannot look up %q in %s", tok, typeOfValue(v)))
+}
+
+// patchSet sets the value that tok refers to in v, which must exist.
+func patchSet(v interface{}, tok string, value interface{}) interface{} {
+	switch x := v.(type) {
+	case map[interface{}]interface{}:
+		x[patchMapKey(x, tok)] = value
+	case []interface{}:
+		j, _ := pointerIndex(tok)
+		x[j] = value
+	}
+	return v
+}
+
+// patchMapKey returns the key of m that tok refers to, or tok itself if there
+// are none.
+func patchMapKey(m map[interface{}]interface{}, tok string) interface{} {
+	for k := range m {
+		if pointerKey(k) == tok {
+			return k
+		}
+	}
+	return tok
+}
+
+// typeOfValue returns a description of the type of a serialized value, used in
+// error messages.
+func typeOfValue(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "nil value"
+	case map[interface{}]interface{}:
+		return "map"
+	case []interface{}:
+		return "array"
+	default:
+		return fmt.Sprintf("%T value", v)
+	}
+}
+
+// copyValue returns a deep copy of the serialized value v.
+func copyValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[interface{}]interface{}, len(x))
+		for k, v := range x {
+			m[k] = copyValue(v)
+		}
+		return m
+
+	case []interface{}:
+		a := make([]interface{}, len(x))
+		for i, v := range x {
+			a[i] = copyValue(v)
+		}
+		return a
+	}
+
+	return v
+}
+
+// ApplyMergePatch applies a JSON Merge Patch (RFC 7386) to doc and returns the
+// result.
+//
+// Maps of the patch are merged recursively into the maps of the document, nil
+// values meaning that the keys must be removed. Any other value of the patch,
+// including arrays, replaces the value of the document. Like ApplyPatch, the
+// document is not modified and the returned value is made of its serialized
+// representation.
+func ApplyMergePatch(doc interface{}, patch interface{}) (interface{}, error) {
+	v, err := serializedValueOf(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := serializedValueOf(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	return mergePatch(v, p), nil
+}
+
+func mergePatch(v interface{}, patch interface{}) interface{} {
+	p, ok := patch.(map[interface{}]interface{})
+
+	if !ok {
+		return patch
+	}
+
+	m, ok := v.(map[interface{}]interface{})
+
+	if !ok {
+		m = make(map[interface{}]interface{}, len(p))
+	}
+
+	for k, x := range p {
+		key := patchMapKey(m, pointerKey(k))
+
+		if _, exists := m[key]; !exists {
+			key = k
+		}
+
+		if x == nil {
+			delete(m, key)
+		} else {
+			m[key] = mergePatch(m[key], x)
+		}
+	}
+
+	return m
+}
@@ -0,0 +1,304 @@
+package objconv
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a    interface{}
+		b    interface{}
+		ops  []PatchOp
+	}{
+		{
+			name: "equal",
+			a:    map[string]int{"a": 1},
+			b:    map[string]float64{"a": 1},
+			ops:  nil,
+		},
+		{
+			name: "replace root",
+			a:    1,
+			b:    "hello",
+			ops:  []PatchOp{{Op: "replace", Path: "", Value: "hello"}},
+		},
+		{
+			name: "maps",
+			a:    map[string]interface{}{"a": 1, "b": 2, "c": map[string]interface{}{"d": true}},
+			b:    map[string]interface{}{"a": 1, "c": map[string]interface{}{"d": false}, "e/f": nil},
+			ops: []PatchOp{
+				{Op: "remove", Path: "/b"},
+				{Op: "replace", Path: "/c/d", Value: false},
+				{Op: "add", Path: "/e~1f", Value: nil},
+			},
+		},
+		{
+			name: "shorter array",
+			a:    []int{1, 2, 3, 4},
+			b:    []int{1, 5},
+			ops: []PatchOp{
+				{Op: "replace", Path: "/1", Value: int64(5)},
+				{Op: "remove", Path: "/3"},
+				{Op: "remove", Path: "/2"},
+			},
+		},
+		{
+			name: "longer array",
+			a:    map[string][]string{"~": {"a"}},
+			b:    map[string][]string{"~": {"a", "b", "c"}},
+			ops: []PatchOp{
+				{Op: "add", Path: "/~0/1", Value: "b"},
+				{Op: "add", Path: "/~0/2", Value: "c"},
+			},
+		},
+		{
+			name: "type change",
+			a:    map[string]interface{}{"a": []int{1}},
+			b:    map[string]interface{}{"a": map[string]int{"x": 1}},
+			ops: []PatchOp{
+				{Op: "replace", Path: "/a", Value: map[interface{}]interface{}{"x": int64(1)}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ops, err := Diff(test.a, test.b)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(ops, test.ops) {
+				t.Errorf("%#v != %#v", ops, test.ops)
+			}
+		})
+	}
+}
+
+func TestPatchOpEncodeValue(t *testing.T) {
+	tests := []struct {
+		op  PatchOp
+		out interface{}
+	}{
+		{
+			op:  PatchOp{Op: "remove", Path: "/a"},
+			out: map[interface{}]interface{}{"op": "remove", "path": "/a"},
+		},
+		{
+			op:  PatchOp{Op: "add", Path: "/a", Value: nil},
+			out: map[interface{}]interface{}{"op": "add", "path": "/a", "value": nil},
+		},
+		{
+			op:  PatchOp{Op: "move", Path: "/a", From: "/b"},
+			out: map[interface{}]interface{}{"op": "move", "path": "/a", "from": "/b"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.op.Op, func(t *testing.T) {
+			v, err := serializedValueOf(test.op)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(v, test.out) {
+				t.Errorf("%#v != %#v", v, test.out)
+			}
+		})
+	}
+}
+
+func TestApplyPatch(t *testing.T) {
+	doc := map[string]interface{}{
+		"a": map[string]interface{}{"b": []int{1, 2, 3}},
+		"c": "hello",
+	}
+
+	tests := []struct {
+		name  string
+		patch []PatchOp
+		out   interface{}
+	}{
+		{
+			name:  "add to map",
+			patch: []PatchOp{{Op: "add", Path: "/d", Value: true}},
+			out:   map[interface{}]interface{}{"a": map[interface{}]interface{}{"b": []interface{}{int64(1), int64(2), int64(3)}}, "c": "hello", "d": true},
+		},
+		{
+			name: "insert in array",
+			patch: []PatchOp{
+				{Op: "add", Path: "/a/b/0", Value: 0},
+				{Op: "add", Path: "/a/b/-", Value: 4},
+			},
+			out: map[interface{}]interface{}{"a": map[interface{}]interface{}{"b": []interface{}{int64(0), int64(1), int64(2), int64(3), int64(4)}}, "c": "hello"},
+		},
+		{
+			name: "remove and replace",
+			patch: []PatchOp{
+				{Op: "remove", Path: "/a/b/1"},
+				{Op: "replace", Path: "/c", Value: "world"},
+			},
+			out: map[interface{}]interface{}{"a": map[interface{}]interface{}{"b": []interface{}{int64(1), int64(3)}}, "c": "world"},
+		},
+		{
+			name: "move",
+			patch: []PatchOp{
+				{Op: "move", Path: "/a/b/0", From: "/a/b/2"},
+				{Op: "move", Path: "/d", From: "/c"},
+			},
+			out: map[interface{}]interface{}{"a": map[interface{}]interface{}{"b": []interface{}{int64(3), int64(1), int64(2)}}, "d": "hello"},
+		},
+		{
+			name: "copy",
+			patch: []PatchOp{
+				{Op: "copy", Path: "/d", From: "/a"},
+				{Op: "remove", Path: "/d/b/0"},
+			},
+			out: map[interface{}]interface{}{"a": map[interface{}]interface{}{"b": []interface{}{int64(1), int64(2), int64(3)}}, "c": "hello", "d": map[interface{}]interface{}{"b": []interface{}{int64(2), int64(3)}}},
+		},
+		{
+			name: "test",
+			patch: []PatchOp{
+				{Op: "test", Path: "/a/b", Value: []float64{1, 2, 3}},
+				{Op: "replace", Path: "", Value: 42},
+			},
+			out: int64(42),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			v, err := ApplyPatch(doc, test.patch)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(v, test.out) {
+				t.Errorf("%#v != %#v", v, test.out)
+			}
+		})
+	}
+}
+
+func TestApplyPatchErrors(t *testing.T) {
+	doc := map[string]interface{}{"a": []int{1, 2}, "b": "hello"}
+
+	tests := []struct {
+		name  string
+		patch []PatchOp
+		err   string
+	}{
+		{
+			name:  "failed test",
+			patch: []PatchOp{{Op: "add", Path: "/c", Value: 1}, {Op: "test", Path: "/b", Value: "world"}},
+			err:   `objconv: patch operation 1 (test /b): test failed, the value at "/b" is different`,
+		},
+		{
+			name:  "missing key",
+			patch: []PatchOp{{Op: "replace", Path: "/c", Value: 1}},
+			err:   `objconv: patch operation 0 (replace /c): JSON pointer "/c" not found: no key "c" in map at ""`,
+		},
+		{
+			name:  "out of range",
+			patch: []PatchOp{{Op: "add", Path: "/a/3", Value: 1}},
+			err:   `objconv: patch operation 0 (add /a/3): JSON pointer "/a/3" not found: cannot insert at "3" in array of length 2 at "/a"`,
+		},
+		{
+			name:  "move into child",
+			patch: []PatchOp{{Op: "move", Path: "/a/0", From: "/a"}},
+			err:   `objconv: patch operation 0 (move /a/0): cannot move "/a" into one of its children`,
+		},
+		{
+			name:  "unknown operation",
+			patch: []PatchOp{{Op: "merge", Path: "/a"}},
+			err:   `objconv: patch operation 0 (merge /a): unknown patch operation "merge"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			v, err := ApplyPatch(doc, test.patch)
+
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+
+			if v != nil {
+				t.Error("expected no value when the patch fails:", v)
+			}
+
+			if s := err.Error(); s != test.err {
+				t.Error(s)
+			}
+		})
+	}
+}
+
+func TestDiffApplyPatch(t *testing.T) {
+	a := map[string]interface{}{"a": []int{1, 2, 3, 4}, "b": map[string]string{"c": "d"}, "e": 1}
+	b := map[string]interface{}{"a": []int{0, 2}, "b": map[string]string{"c": "x", "y": "z"}, "f": []int{}}
+
+	ops, err := Diff(a, b)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := ApplyPatch(a, ops)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !Equal(v, b) {
+		t.Errorf("%#v != %#v", v, b)
+	}
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	tests := []struct {
+		doc   interface{}
+		patch interface{}
+		out   interface{}
+	}{
+		{
+			doc:   map[string]interface{}{"a": "b", "c": map[string]interface{}{"d": "e", "f": "g"}},
+			patch: map[string]interface{}{"a": "z", "c": map[string]interface{}{"f": nil}},
+			out:   map[interface{}]interface{}{"a": "z", "c": map[interface{}]interface{}{"d": "e"}},
+		},
+		{
+			doc:   map[string]interface{}{"a": []int{1, 2}},
+			patch: map[string]interface{}{"a": []int{3}, "b": nil},
+			out:   map[interface{}]interface{}{"a": []interface{}{int64(3)}},
+		},
+		{
+			doc:   []int{1},
+			patch: map[string]interface{}{"a": map[string]interface{}{"b": nil, "c": 1}},
+			out:   map[interface{}]interface{}{"a": map[interface{}]interface{}{"c": int64(1)}},
+		},
+		{
+			doc:   map[string]interface{}{"a": 1},
+			patch: "hello",
+			out:   "hello",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			v, err := ApplyMergePatch(test.doc, test.patch)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(v, test.out) {
+				t.Errorf("%#v != %#v", v, test.out)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package objconv
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// DecodePointer decodes the value located at ptr in the next value of the
+// input into v, where ptr is a JSON Pointer (RFC 6901) like "/a/b/0/c".
+//
+// The whole value is consumed from the input, but only the value that the
+// pointer refers to is decoded, the others are discarded as they are parsed.
+// Each token of the pointer is matched against the keys of maps, or must be an
+// index when the value is an array. An empty pointer refers to the whole
+// value.
+//
+// The method returns an error if ptr isn't a valid JSON Pointer, or if the
+// value it refers to doesn't exist in the input.
+func (d Decoder) DecodePointer(ptr string, v interface{}) (err error) {
+	var tokens []string
+
+	if tokens, err = parsePointer(ptr); err != nil {
+		return
+	}
+
+	d.initAlloc()
+
+	if d.initErrors() {
+		defer func() { err = d.collectedErrors(err) }()
+	}
+
+	return d.decodePointerTokens(ptr, tokens, 0, v)
+}
+
+func (d Decoder) decodePointerTokens(ptr string, tokens []string, i int, v interface{}) (err error) {
+	var t Type
+
+	if i == len(tokens) {
+		return d.Decode(v)
+	}
+
+	if d.off != 0 {
+		if d.off, err = 0, d.Parser.ParseMapValue(d.off-1); err != nil {
+			return
+		}
+	}
+
+	if t, err = d.Parser.ParseType(); err != nil {
+		return
+	}
+
+	tok := tokens[i]
+	found := false
+
+	switch t {
+	case Map:
+		err = d.decodeMapImpl(t, func(kd Decoder, vd Decoder) (err error) {
+			var k interface{}
+
+			if err = kd.Decode(&k); err != nil {
+				return
+			}
+
+			if !found && pointerKey(k) == tok {
+				found = true
+				return vd.decodePointerTokens(ptr, tokens, i+1, v)
+			}
+
+			return vd.Decode(nil)
+		})
+
+		if err == nil && !found {
+			err = pointerNotFound(ptr, i, fmt.Sprintf("no key %q in map", tok))
+		}
+
+	case Array:
+		index, ok := pointerIndex(tok)
+		j := 0
+
+		err = d.decodeArrayImpl(t, func(ed Decoder) (err error) {
+			if ok && j == index {
+				found = true
+				err = ed.decodePointerTokens(ptr, tokens, i+1, v)
+			} else {
+				err = ed.Decode(nil)
+			}
+			j++
+			return
+		})
+
+		if err == nil && !found {
+			if ok {
+				err = pointerNotFound(ptr, i, fmt.Sprintf("index %d out of range of array of length %d", index, j))
+			} else {
+				err = pointerNotFound(ptr, i, fmt.Sprintf("%q is not an array index", tok))
+			}
+		}
+
+	default:
+		if err = d.decodeInterfaceFromType(t, reflect.Value{}); err == nil {
+			err = pointerNotFound(ptr, i, fmt.Sprintf("cannot look up %q in %s value", tok, t))
+		}
+	}
+
+	return
+}
+
+// parsePointer splits ptr into its unescaped reference tokens.
+func parsePointer(ptr string) ([]string, error) {
+	if len(ptr) == 0 {
+		return nil, nil
+	}
+
+	if ptr[0] != '/' {
+		return nil, fmt.Errorf("objconv: invalid JSON pointer %q, it must be empty or start with a '/'", ptr)
+	}
+
+	tokens := strings.Split(ptr[1:], "/")
+
+	for i, tok := range tokens {
+		if strings.IndexByte(tok, '~') < 0 {
+			continue
+		}
+
+		b := make([]byte, 0, len(tok))
+
+		for j := 0; j < len(tok); j++ {
+			if c := tok[j]; c != '~' {
+				b = append(b, c)
+				continue
+			}
+
+			if j++; j == len(tok) || (tok[j] != '0' && tok[j] != '1') {
+				return nil, fmt.Errorf("objconv: invalid JSON pointer %q, '~' must be followed by '0' or '1'", ptr)
+			}
+
+			// "~1" must be unescaped before "~0" so "~01" becomes "~1",
+			// decoding the sequences from left to right has the same effect.
+			if tok[j] == '0' {
+				b = append(b, '~')
+			} else {
+				b = append(b, '/')
+			}
+		}
+
+		tokens[i] = string(b)
+	}
+
+	return tokens, nil
+}
+
+// pointerIndex returns the array index represented by tok, which must not have
+// leading zeros. The "-" token, which refers to the element after the last one,
+// never matches an element.
+func pointerIndex(tok string) (int, bool) {
+	if len(tok) == 0 || (len(tok) > 1 && tok[0] == '0') {
+		return 0, false
+	}
+
+	for i := 0; i != len(tok); i++ {
+		if tok[i] < '0' || tok[i] > '9' {
+			return 0, false
+		}
+	}
+
+	i, err := strconv.Atoi(tok)
+	return i, err == nil
+}
+
+// pointerKey returns the string representation of map keys which are compared
+// with the tokens of pointers, keys of other types than strings are formatted
+// with their default format.
+func pointerKey(k interface{}) string {
+	switch x := k.(type) {
+	case string:
+		return x
+	case []byte:
+		return string(x)
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+// pointerNotFound returns the error reported when the i-th token of ptr could
+// not be found, reason describes why.
+func pointerNotFound(ptr string, i int, reason string) error {
+	// The prefix referring to the value where the look up failed is made of
+	// the first i tokens, which are still escaped in ptr.
+	prefix := strings.Join(strings.SplitN(ptr, "/", i+2)[:i+1], "/")
+	return fmt.Errorf("objconv: JSON pointer %q not found: %s at %q", ptr, reason, prefix)
+}
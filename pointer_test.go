@@ -0,0 +1,99 @@
+package objconv
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodePointer(t *testing.T) {
+	doc := map[string]interface{}{
+		"a": map[string]interface{}{
+			"b": []interface{}{
+				map[string]interface{}{"c": 42},
+				"x",
+			},
+		},
+		"m~n": "tilde",
+		"a/b": "slash",
+		"":    "empty",
+		"~01": "escapes",
+	}
+
+	tests := []struct {
+		ptr string
+		out interface{}
+	}{
+		{"/a/b/0/c", int64(42)},
+		{"/a/b/1", "x"},
+		{"/m~0n", "tilde"},
+		{"/a~1b", "slash"},
+		{"/", "empty"},
+		{"/~001", "escapes"},
+		{"/a/b", []interface{}{map[interface{}]interface{}{"c": int64(42)}, "x"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.ptr, func(t *testing.T) {
+			var v interface{}
+
+			if err := NewDecoder(NewValueParser(doc)).DecodePointer(test.ptr, &v); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(v, test.out) {
+				t.Errorf("%#v != %#v", v, test.out)
+			}
+		})
+	}
+
+	t.Run("whole value", func(t *testing.T) {
+		var v []int
+
+		if err := NewDecoder(NewValueParser([]int{1, 2, 3})).DecodePointer("", &v); err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(v, []int{1, 2, 3}) {
+			t.Error(v)
+		}
+	})
+}
+
+func TestDecodePointerErrors(t *testing.T) {
+	doc := map[string]interface{}{
+		"a": map[string]interface{}{
+			"b": []interface{}{1, 2},
+		},
+	}
+
+	tests := []struct {
+		ptr string
+		err string
+	}{
+		{"a", `objconv: invalid JSON pointer "a", it must be empty or start with a '/'`},
+		{"/a~2", `objconv: invalid JSON pointer "/a~2", '~' must be followed by '0' or '1'`},
+		{"/a~", `objconv: invalid JSON pointer "/a~", '~' must be followed by '0' or '1'`},
+		{"/x", `objconv: JSON pointer "/x" not found: no key "x" in map at ""`},
+		{"/a/c/d", `objconv: JSON pointer "/a/c/d" not found: no key "c" in map at "/a"`},
+		{"/a/b/2", `objconv: JSON pointer "/a/b/2" not found: index 2 out of range of array of length 2 at "/a/b"`},
+		{"/a/b/-", `objconv: JSON pointer "/a/b/-" not found: "-" is not an array index at "/a/b"`},
+		{"/a/b/01", `objconv: JSON pointer "/a/b/01" not found: "01" is not an array index at "/a/b"`},
+		{"/a/b/0/c", `objconv: JSON pointer "/a/b/0/c" not found: cannot look up "c" in int value at "/a/b/0"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.ptr, func(t *testing.T) {
+			var v interface{}
+			err := NewDecoder(NewValueParser(doc)).DecodePointer(test.ptr, &v)
+
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+
+			if s := err.Error(); !strings.HasPrefix(s, test.err) {
+				t.Error(s)
+			}
+		})
+	}
+}
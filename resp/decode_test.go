@@ -31,6 +31,8 @@ var respDecodeTests = []struct {
 	{errors.New(""), "-\r\n"},
 	{errors.New("oops"), "-oops\r\n"},
 	{errors.New("A"), "-A\r\n"},
+	{errors.New("oops something"), "-oops something\r\n"},
+	{&Error{Code: "WRONGTYPE", Message: "Operation against a key"}, "-WRONGTYPE Operation against a key\r\n"},
 
 	{[]int{}, "*0\r\n"},
 	{[]int{1, 2, 3}, "*3\r\n:1\r\n:2\r\n:3\r\n"},
@@ -117,3 +119,43 @@ func BenchmarkDecoder(b *testing.B) {
 		})
 	}
 }
+
+func TestDecodeErrorCode(t *testing.T) {
+	const s = "*2\r\n-ERR unknown command 'foo'\r\n-ERR unknown command 'bar'\r\n"
+
+	t.Run("string", func(t *testing.T) {
+		var v string
+
+		if err := Unmarshal([]byte("-ERR unknown command 'foo'\r\n"), &v); err != nil {
+			t.Fatal(err)
+		}
+
+		if v != "ERR unknown command 'foo'" {
+			t.Errorf("bad string: %q", v)
+		}
+	})
+
+	t.Run("slice", func(t *testing.T) {
+		var v []error
+
+		if err := Unmarshal([]byte(s), &v); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(v) != 2 || v[1].Error() != "ERR unknown command 'bar'" {
+			t.Errorf("bad errors: %v", v)
+		}
+
+		if e, ok := v[0].(*Error); !ok || e.Code != "ERR" || e.Message != "unknown command 'foo'" {
+			t.Errorf("bad error: %#v", v[0])
+		}
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		var v *Error
+
+		if err := Unmarshal([]byte("-oops\r\n"), &v); err == nil {
+			t.Error("expected an error when decoding an error without code into *Error")
+		}
+	})
+}
@@ -0,0 +1,41 @@
+package resp
+
+import "strings"
+
+// Error is the type of errors returned by the parser for RESP error values
+// which start with an error code, like "-WRONGTYPE Operation against a key
+// holding the wrong kind of value". By convention the code is the first word
+// of the error, made of upper-case letters, digits or underscores.
+//
+// RESP errors which don't have a code are returned as errors created by
+// errors.New.
+type Error struct {
+	// Code is the error code, like "ERR" or "WRONGTYPE".
+	Code string
+
+	// Message is the text of the error following the code.
+	Message string
+}
+
+// Error satisfies the error interface, it returns the full error text.
+func (e *Error) Error() string {
+	return e.Code + " " + e.Message
+}
+
+// parseError returns the error represented by the text of a RESP error value,
+// and whether it had an error code.
+func parseError(s string) (*Error, bool) {
+	i := strings.IndexByte(s, ' ')
+
+	if i <= 0 {
+		return nil, false
+	}
+
+	for _, c := range s[:i] {
+		if !((c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_') {
+			return nil, false
+		}
+	}
+
+	return &Error{Code: s[:i], Message: s[i+1:]}, true
+}
@@ -22,6 +22,8 @@ type Parser struct {
 	s []byte    // buffer used for building strings
 	a [128]byte // initial backend array for s
 	b [128]byte // buffer where bytes are loaded from the reader
+
+	raw objutil.RawRecorder // records raw bytes between BeginRaw and EndRaw
 }
 
 func NewParser(r io.Reader) *Parser {
@@ -30,6 +32,7 @@ func NewParser(r io.Reader) *Parser {
 
 func (p *Parser) Reset(r io.Reader) {
 	p.r = r
+	p.i = 0
 	p.n = 0
 	p.s = nil
 }
@@ -38,6 +41,32 @@ func (p *Parser) Buffered() io.Reader {
 	return bytes.NewReader(p.s[p.n:])
 }
 
+// InMemory returns true if the parser reads from a buffer held in memory.
+func (p *Parser) InMemory() bool {
+	return objutil.InMemory(p.r)
+}
+
+// Remaining returns the bytes of the input that haven't been parsed yet, the
+// parser has reached the end of its input after the method returns.
+func (p *Parser) Remaining() (rest []byte, err error) {
+	if rest, err = objutil.Remaining(p.r, p.s[p.n:]); err == nil {
+		p.Reset(p.r)
+	}
+	return
+}
+
+// BeginRaw starts recording the raw bytes of the values being parsed.
+func (p *Parser) BeginRaw() {
+	p.r = p.raw.Begin(p.r, p.s[p.n:])
+}
+
+// EndRaw stops recording and returns the raw bytes of the values parsed since
+// BeginRaw was called.
+func (p *Parser) EndRaw() (raw []byte) {
+	raw, p.r = p.raw.End(len(p.s) - p.n)
+	return
+}
+
 func (p *Parser) ParseType() (t objconv.Type, err error) {
 	var line []byte
 
@@ -229,7 +258,12 @@ func (p *Parser) ParseError() (v error, err error) {
 		goto failure
 	}
 
-	v = errors.New(string(line[1:]))
+	if e, ok := parseError(string(line[1:])); ok {
+		v = e
+	} else {
+		v = errors.New(string(line[1:]))
+	}
+
 	p.skipLine()
 	return
 failure:
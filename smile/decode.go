@@ -0,0 +1,23 @@
+package smile
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/segmentio/objconv"
+)
+
+// NewDecoder returns a new Smile decoder that parses values from r.
+func NewDecoder(r io.Reader) *objconv.Decoder {
+	return Codec.NewDecoder(r)
+}
+
+// NewStreamDecoder returns a new Smile stream decoder that parses values from r.
+func NewStreamDecoder(r io.Reader) *objconv.StreamDecoder {
+	return Codec.NewStreamDecoder(r)
+}
+
+// Unmarshal decodes a Smile representation of v from b.
+func Unmarshal(b []byte, v interface{}) error {
+	return (objconv.Decoder{Parser: NewParser(bytes.NewReader(b))}).Decode(v)
+}
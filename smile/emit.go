@@ -0,0 +1,384 @@
+package smile
+
+import (
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/objconv/objutil"
+)
+
+// Emitter implements a Smile emitter that satisfies the objconv.Emitter
+// interface.
+type Emitter struct {
+	// SharedKeys enables writing references to the map keys that were already
+	// written, this is the default with emitters returned by NewEmitter.
+	SharedKeys bool
+
+	// SharedValues enables writing references to the short string values
+	// that were already written.
+	SharedValues bool
+
+	// RawBinary enables writing byte slices as-is instead of using the 7 bits
+	// encoding, the output then contains bytes which are reserved markers in
+	// Smile, which may not be supported by all readers.
+	RawBinary bool
+
+	w io.Writer
+	b []byte
+
+	// The header is written before the first value, the flags are then
+	// copied from the configuration of the emitter.
+	header bool
+	flags  byte
+
+	// key is true when the next value is a map key.
+	key bool
+
+	// keys and values are the shared tables of strings that can be
+	// referenced, nkeys and nvalues are the number of strings that were added
+	// to the tables (including the ones that can't be referenced).
+	keys    map[string]int
+	values  map[string]int
+	nkeys   int
+	nvalues int
+}
+
+// NewEmitter returns a new emitter that writes to w, with the SharedKeys option
+// enabled.
+func NewEmitter(w io.Writer) *Emitter {
+	return &Emitter{
+		SharedKeys: true,
+		w:          w,
+		b:          make([]byte, 0, 64),
+	}
+}
+
+func (e *Emitter) Reset(w io.Writer) {
+	e.w = w
+	e.header = false
+	e.key = false
+	e.resetKeys()
+	e.resetValues()
+}
+
+func (e *Emitter) EmitNil() error {
+	if e.key {
+		return e.emitKey("null")
+	}
+	return e.write(append(e.begin(), tokenNull))
+}
+
+func (e *Emitter) EmitBool(v bool) error {
+	if e.key {
+		return e.emitKey(strconv.FormatBool(v))
+	}
+	if v {
+		return e.write(append(e.begin(), tokenTrue))
+	}
+	return e.write(append(e.begin(), tokenFalse))
+}
+
+func (e *Emitter) EmitInt(v int64, _ int) error {
+	if e.key {
+		return e.emitKey(strconv.FormatInt(v, 10))
+	}
+
+	b := e.begin()
+
+	switch {
+	case v >= -16 && v <= 15:
+		b = append(b, tokenSmallInt|byte(zigzagEncode(v)))
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		b = appendVInt(append(b, tokenInt32), zigzagEncode(v))
+	default:
+		b = appendVInt(append(b, tokenInt64), zigzagEncode(v))
+	}
+
+	return e.write(b)
+}
+
+func (e *Emitter) EmitUint(v uint64, _ int) error {
+	if v <= math.MaxInt64 {
+		return e.EmitInt(int64(v), 0)
+	}
+
+	if e.key {
+		return e.emitKey(strconv.FormatUint(v, 10))
+	}
+
+	// Integers which don't fit in an int64 are written as big integers, the
+	// two's complement representation needs a leading zero byte to keep them
+	// positive.
+	var a [9]byte
+	a[1] = byte(v >> 56)
+	a[2] = byte(v >> 48)
+	a[3] = byte(v >> 40)
+	a[4] = byte(v >> 32)
+	a[5] = byte(v >> 24)
+	a[6] = byte(v >> 16)
+	a[7] = byte(v >> 8)
+	a[8] = byte(v)
+
+	b := appendVInt(append(e.begin(), tokenBigInt), uint64(len(a)))
+	return e.write(append7Bit(b, a[:]))
+}
+
+func (e *Emitter) EmitFloat(v float64, bitSize int) error {
+	if e.key {
+		return e.emitKey(strconv.FormatFloat(v, 'g', -1, bitSize))
+	}
+
+	b := e.begin()
+
+	if bitSize == 32 {
+		b = appendFloatBits(append(b, tokenFloat32), uint64(math.Float32bits(float32(v))), 5)
+	} else {
+		b = appendFloatBits(append(b, tokenFloat64), math.Float64bits(v), 10)
+	}
+
+	return e.write(b)
+}
+
+func (e *Emitter) EmitString(v string) error {
+	if e.key {
+		return e.emitKey(v)
+	}
+	return e.emitString(v)
+}
+
+func (e *Emitter) EmitBytes(v []byte) error {
+	if e.key {
+		return e.emitKey(string(v))
+	}
+
+	b := e.begin()
+
+	if e.flags&flagRawBinary != 0 {
+		b = appendVInt(append(b, tokenRawBinary), uint64(len(v)))
+		b = append(b, v...)
+	} else {
+		b = appendVInt(append(b, tokenBinary7Bit), uint64(len(v)))
+		b = append7Bit(b, v)
+	}
+
+	return e.write(b)
+}
+
+func (e *Emitter) EmitTime(v time.Time) error {
+	return e.EmitString(v.Format(time.RFC3339Nano))
+}
+
+func (e *Emitter) EmitDuration(v time.Duration) error {
+	return e.EmitString(string(objutil.AppendDuration(nil, v)))
+}
+
+func (e *Emitter) EmitError(v error) error {
+	return e.EmitString(v.Error())
+}
+
+func (e *Emitter) EmitArrayBegin(_ int) error {
+	if e.key {
+		return errors.New("objconv/smile: cannot emit an array as a map key, keys must be strings or scalar values")
+	}
+	return e.write(append(e.begin(), tokenArrayBegin))
+}
+
+func (e *Emitter) EmitArrayEnd() error {
+	return e.write(append(e.b[:0], tokenArrayEnd))
+}
+
+func (e *Emitter) EmitArrayNext() error {
+	return nil
+}
+
+func (e *Emitter) EmitMapBegin(_ int) error {
+	if e.key {
+		return errors.New("objconv/smile: cannot emit a map as a map key, keys must be strings or scalar values")
+	}
+	e.key = true
+	return e.write(append(e.begin(), tokenMapBegin))
+}
+
+func (e *Emitter) EmitMapEnd() error {
+	e.key = false
+	return e.write(append(e.b[:0], tokenMapEnd))
+}
+
+func (e *Emitter) EmitMapValue() error {
+	e.key = false
+	return nil
+}
+
+func (e *Emitter) EmitMapNext() error {
+	e.key = true
+	return nil
+}
+
+// begin returns the buffer to write the next value to, starting with the header
+// if it wasn't written yet.
+func (e *Emitter) begin() []byte {
+	b := e.b[:0]
+
+	if !e.header {
+		e.header = true
+		e.flags = 0
+
+		if e.SharedKeys {
+			e.flags |= flagSharedKeys
+		}
+		if e.SharedValues {
+			e.flags |= flagSharedValues
+		}
+		if e.RawBinary {
+			e.flags |= flagRawBinary
+		}
+
+		b = append(append(b, Header...), e.flags)
+	}
+
+	return b
+}
+
+func (e *Emitter) emitKey(s string) error {
+	b := e.b[:0]
+
+	if len(s) == 0 {
+		return e.write(append(b, keyEmpty))
+	}
+
+	if e.flags&flagSharedKeys != 0 {
+		if i, ok := e.keys[s]; ok {
+			if i <= maxShortKeyRef {
+				b = append(b, keyShortRef|byte(i))
+			} else {
+				b = append(b, keyLongRef|byte(i>>8), byte(i))
+			}
+			return e.write(b)
+		}
+		e.addKey(s)
+	}
+
+	switch n := len(s); {
+	case n <= 64 && isASCII(s):
+		b = append(b, keyASCII|byte(n-1))
+		b = append(b, s...)
+	case n >= 2 && n <= 57 && !isASCII(s):
+		b = append(b, keyUTF8|byte(n-2))
+		b = append(b, s...)
+	default:
+		b = append(b, keyLongUTF8)
+		b = append(b, s...)
+		b = append(b, tokenEndString)
+	}
+
+	return e.write(b)
+}
+
+func (e *Emitter) emitString(s string) error {
+	b := e.begin()
+	n := len(s)
+
+	if n == 0 {
+		return e.write(append(b, tokenEmptyString))
+	}
+
+	ascii := isASCII(s)
+	short := (ascii && n <= 64) || (!ascii && n <= 65)
+
+	if short && e.flags&flagSharedValues != 0 {
+		if i, ok := e.values[s]; ok {
+			if i <= maxShortValueRef {
+				b = append(b, byte(i+1))
+			} else {
+				b = append(b, tokenLongRef|byte(i>>8), byte(i))
+			}
+			return e.write(b)
+		}
+		e.addValue(s)
+	}
+
+	switch {
+	case ascii && n <= 32:
+		b = append(b, tokenTinyASCII|byte(n-1))
+	case ascii && n <= 64:
+		b = append(b, tokenSmallASCII|byte(n-33))
+	case !ascii && n <= 33:
+		b = append(b, tokenTinyUTF8|byte(n-2))
+	case !ascii && n <= 65:
+		b = append(b, tokenSmallUTF8|byte(n-34))
+	case ascii:
+		b = append(b, tokenLongASCII)
+	default:
+		b = append(b, tokenLongUTF8)
+	}
+
+	b = append(b, s...)
+
+	if !short {
+		b = append(b, tokenEndString)
+	}
+
+	return e.write(b)
+}
+
+// addKey adds s to the table of shared keys, the table is emptied when it is
+// full, like the parser does.
+func (e *Emitter) addKey(s string) {
+	if e.nkeys == maxSharedStrings {
+		e.resetKeys()
+	}
+	if e.keys == nil {
+		e.keys = make(map[string]int)
+	}
+	if validRef(e.nkeys) {
+		e.keys[s] = e.nkeys
+	}
+	e.nkeys++
+}
+
+// addValue adds s to the table of shared values, the table is emptied when it
+// is full, like the parser does.
+func (e *Emitter) addValue(s string) {
+	if e.nvalues == maxSharedStrings {
+		e.resetValues()
+	}
+	if e.values == nil {
+		e.values = make(map[string]int)
+	}
+	if validRef(e.nvalues) {
+		e.values[s] = e.nvalues
+	}
+	e.nvalues++
+}
+
+func (e *Emitter) resetKeys() {
+	for k := range e.keys {
+		delete(e.keys, k)
+	}
+	e.nkeys = 0
+}
+
+func (e *Emitter) resetValues() {
+	for k := range e.values {
+		delete(e.values, k)
+	}
+	e.nvalues = 0
+}
+
+func (e *Emitter) write(b []byte) (err error) {
+	_, err = e.w.Write(b)
+	e.b = b[:0]
+	return
+}
+
+// appendFloatBits appends the n least significant groups of 7 bits of u to b,
+// most significant first.
+func appendFloatBits(b []byte, u uint64, n int) []byte {
+	for i := n - 1; i >= 0; i-- {
+		b = append(b, byte(u>>(7*uint(i)))&0x7F)
+	}
+	return b
+}
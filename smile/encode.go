@@ -0,0 +1,29 @@
+package smile
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/segmentio/objconv"
+)
+
+// NewEncoder returns a new Smile encoder that writes to w.
+func NewEncoder(w io.Writer) *objconv.Encoder {
+	return objconv.NewEncoder(NewEmitter(w))
+}
+
+// NewStreamEncoder returns a new Smile stream encoder that writes to w.
+func NewStreamEncoder(w io.Writer) *objconv.StreamEncoder {
+	return objconv.NewStreamEncoder(NewEmitter(w))
+}
+
+// Marshal writes the Smile representation of v to a byte slice returned in b.
+func Marshal(v interface{}) (b []byte, err error) {
+	var buf bytes.Buffer
+
+	if err = (objconv.Encoder{Emitter: NewEmitter(&buf)}).Encode(v); err == nil {
+		b = buf.Bytes()
+	}
+
+	return
+}
@@ -0,0 +1,618 @@
+package smile
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/segmentio/objconv"
+	"github.com/segmentio/objconv/objutil"
+)
+
+// Parser implements a Smile parser that satisfies the objconv.Parser
+// interface.
+type Parser struct {
+	r *bufio.Reader
+	s []byte // string buffer
+
+	// tag is the first byte of the value being parsed, it is read by ParseType
+	// and valid while ok is true.
+	tag byte
+	ok  bool
+
+	// key is true when the next value is a map key.
+	key bool
+
+	// flags of the last header that was read.
+	flags byte
+
+	// Big integers and decimals are decoded by ParseType to determine the type
+	// they are exposed as.
+	num objconv.Type
+	i   int64
+	u   uint64
+	f   float64
+
+	// keys and values are the shared tables of strings, references found in
+	// the input are indexes in these tables.
+	keys   []string
+	values []string
+}
+
+// NewParser returns a new parser that reads from r.
+func NewParser(r io.Reader) *Parser {
+	return &Parser{r: bufio.NewReader(r), flags: flagSharedKeys}
+}
+
+func (p *Parser) Reset(r io.Reader) {
+	p.r.Reset(r)
+	p.ok = false
+	p.key = false
+	p.flags = flagSharedKeys
+	p.keys = p.keys[:0]
+	p.values = p.values[:0]
+}
+
+func (p *Parser) ParseType() (objconv.Type, error) {
+	if err := p.peek(); err != nil {
+		return objconv.Unknown, err
+	}
+
+	if p.key {
+		return p.keyType()
+	}
+
+	switch tag := p.tag; {
+	case tag >= 0x01 && tag <= 0x1F, tag == tokenEmptyString:
+		return objconv.String, nil
+	case tag == tokenNull:
+		return objconv.Nil, nil
+	case tag == tokenFalse, tag == tokenTrue:
+		return objconv.Bool, nil
+	case tag == tokenInt32, tag == tokenInt64, tag&0xE0 == tokenSmallInt:
+		return objconv.Int, nil
+	case tag == tokenBigInt:
+		return p.parseBigInt()
+	case tag == tokenFloat32, tag == tokenFloat64:
+		return objconv.Float, nil
+	case tag == tokenBigDecimal:
+		return p.parseBigDecimal()
+	case tag >= tokenTinyASCII && tag < tokenSmallInt:
+		return objconv.String, nil
+	case tag == tokenLongASCII, tag == tokenLongUTF8:
+		return objconv.String, nil
+	case tag >= tokenLongRef && tag <= tokenLongRef|0x03:
+		return objconv.String, nil
+	case tag == tokenBinary7Bit, tag == tokenRawBinary:
+		return objconv.Bytes, nil
+	case tag == tokenArrayBegin:
+		return objconv.Array, nil
+	case tag == tokenMapBegin:
+		return objconv.Map, nil
+	case tag == tokenArrayEnd:
+		// Reported when an empty array is consumed by a stream decoder, which
+		// doesn't call ParseArrayNext before the first element.
+		return objconv.Unknown, objconv.End
+	default:
+		return objconv.Unknown, errorf("invalid token: %#02x", tag)
+	}
+}
+
+func (p *Parser) keyType() (objconv.Type, error) {
+	switch tag := p.tag; {
+	case tag == keyEmpty, tag == keyLongUTF8:
+		return objconv.String, nil
+	case tag >= keyLongRef && tag <= keyLongRef|0x03:
+		return objconv.String, nil
+	case tag >= keyShortRef && tag <= 0xF7:
+		return objconv.String, nil
+	default:
+		return objconv.Unknown, errorf("invalid key token: %#02x", tag)
+	}
+}
+
+func (p *Parser) ParseNil() error {
+	p.ok = false
+	return nil
+}
+
+func (p *Parser) ParseBool() (bool, error) {
+	p.ok = false
+	return p.tag == tokenTrue, nil
+}
+
+func (p *Parser) ParseInt() (v int64, err error) {
+	var u uint64
+	p.ok = false
+
+	switch tag := p.tag; {
+	case tag == tokenBigInt:
+		v = p.i
+	case tag&0xE0 == tokenSmallInt:
+		v = zigzagDecode(uint64(tag & 0x1F))
+	default:
+		if u, err = p.readVInt(); err == nil {
+			v = zigzagDecode(u)
+		}
+	}
+
+	return
+}
+
+func (p *Parser) ParseUint() (v uint64, err error) {
+	p.ok = false
+	return p.u, nil
+}
+
+func (p *Parser) ParseFloat() (v float64, err error) {
+	var u uint64
+	p.ok = false
+
+	switch p.tag {
+	case tokenBigDecimal:
+		v = p.f
+	case tokenFloat32:
+		if u, err = p.readFloatBits(5); err == nil {
+			v = float64(math.Float32frombits(uint32(u)))
+		}
+	default:
+		if u, err = p.readFloatBits(10); err == nil {
+			v = math.Float64frombits(u)
+		}
+	}
+
+	return
+}
+
+func (p *Parser) ParseString() ([]byte, error) {
+	p.ok = false
+
+	if p.key {
+		return p.parseKey()
+	}
+
+	return p.parseString()
+}
+
+func (p *Parser) ParseBytes() (b []byte, err error) {
+	var n int
+	p.ok = false
+
+	if n, err = p.readLength(); err != nil {
+		return
+	}
+
+	if p.tag == tokenRawBinary {
+		return p.read(n)
+	}
+
+	var src []byte
+
+	if src, err = p.read(len7Bit(n)); err != nil {
+		return
+	}
+
+	// The decoded bytes are written to a new slice since src is pointing at
+	// the string buffer.
+	b = make([]byte, n)
+	decode7Bit(b, src)
+	return
+}
+
+func (p *Parser) ParseTime() (time.Time, error) {
+	panic("objconv/smile: ParseTime should never be called because Smile has no time type, this is likely a bug in the decoder code")
+}
+
+func (p *Parser) ParseDuration() (time.Duration, error) {
+	panic("objconv/smile: ParseDuration should never be called because Smile has no duration type, this is likely a bug in the decoder code")
+}
+
+func (p *Parser) ParseError() (error, error) {
+	panic("objconv/smile: ParseError should never be called because Smile has no error type, this is likely a bug in the decoder code")
+}
+
+func (p *Parser) ParseArrayBegin() (int, error) {
+	p.ok = false
+	return -1, nil
+}
+
+func (p *Parser) ParseArrayEnd(n int) error {
+	return p.parseEnd(tokenArrayEnd)
+}
+
+func (p *Parser) ParseArrayNext(n int) error {
+	return p.parseNext(tokenArrayEnd)
+}
+
+func (p *Parser) ParseMapBegin() (int, error) {
+	p.ok = false
+	p.key = true
+	return -1, nil
+}
+
+func (p *Parser) ParseMapEnd(n int) error {
+	p.key = false
+	return p.parseEnd(tokenMapEnd)
+}
+
+func (p *Parser) ParseMapValue(n int) error {
+	p.key = false
+	return nil
+}
+
+func (p *Parser) ParseMapNext(n int) error {
+	p.key = true
+	return p.parseNext(tokenMapEnd)
+}
+
+func (p *Parser) parseNext(end byte) error {
+	if err := p.peek(); err != nil {
+		return unexpectedEOF(err)
+	}
+	if p.tag == end {
+		return objconv.End
+	}
+	return nil
+}
+
+func (p *Parser) parseEnd(end byte) error {
+	if err := p.peek(); err != nil {
+		return unexpectedEOF(err)
+	}
+	if p.tag != end {
+		return errorf("expected the end of an array or map but found %#02x", p.tag)
+	}
+	p.ok = false
+	return nil
+}
+
+func (p *Parser) parseKey() (b []byte, err error) {
+	switch tag := p.tag; {
+	case tag == keyEmpty:
+		b = p.s[:0]
+
+	case tag == keyLongUTF8:
+		if b, err = p.readUntilEnd(); err == nil {
+			p.keys = addShared(p.keys, b)
+		}
+
+	case tag <= keyLongRef|0x03:
+		var i int
+		if i, err = p.readLongRef(tag); err == nil {
+			b, err = p.ref(i, p.keys, flagSharedKeys)
+		}
+
+	case tag < keyASCII:
+		b, err = p.ref(int(tag&0x3F), p.keys, flagSharedKeys)
+
+	default:
+		n := int(tag&0x3F) + 1
+		if tag >= keyUTF8 {
+			n++
+		}
+		if b, err = p.read(n); err == nil {
+			p.keys = addShared(p.keys, b)
+		}
+	}
+
+	return
+}
+
+func (p *Parser) parseString() (b []byte, err error) {
+	switch tag := p.tag; {
+	case tag == tokenEmptyString:
+		b = p.s[:0]
+
+	case tag <= 0x1F:
+		b, err = p.ref(int(tag)-1, p.values, flagSharedValues)
+
+	case tag == tokenLongASCII, tag == tokenLongUTF8:
+		b, err = p.readUntilEnd()
+
+	case tag >= tokenLongRef:
+		var i int
+		if i, err = p.readLongRef(tag); err == nil {
+			b, err = p.ref(i, p.values, flagSharedValues)
+		}
+
+	default:
+		var n int
+		switch tag & 0xE0 {
+		case tokenTinyASCII:
+			n = int(tag&0x1F) + 1
+		case tokenSmallASCII:
+			n = int(tag&0x1F) + 33
+		case tokenTinyUTF8:
+			n = int(tag&0x1F) + 2
+		default:
+			n = int(tag&0x1F) + 34
+		}
+		if b, err = p.read(n); err == nil {
+			p.values = addShared(p.values, b)
+		}
+	}
+
+	return
+}
+
+// parseBigInt reads a big integer, which must fit in 64 bits to be exposed as
+// an Int or a Uint.
+func (p *Parser) parseBigInt() (objconv.Type, error) {
+	if p.num != objconv.Unknown {
+		return p.num, nil
+	}
+
+	i, err := p.readBigInt()
+	if err != nil {
+		return objconv.Unknown, err
+	}
+
+	switch {
+	case i.IsInt64():
+		p.num, p.i = objconv.Int, i.Int64()
+	case i.IsUint64():
+		p.num, p.u = objconv.Uint, i.Uint64()
+	default:
+		return objconv.Unknown, errorf("the big integer %s doesn't fit in 64 bits", i)
+	}
+
+	return p.num, nil
+}
+
+// maxDecimalExp is the magnitude of the decimal exponents beyond which big
+// decimals are out of the range of float64 values, with some margin.
+const maxDecimalExp = 400
+
+// parseBigDecimal reads a big decimal, which is exposed as a Float rounded to
+// the nearest float64.
+func (p *Parser) parseBigDecimal() (objconv.Type, error) {
+	if p.num != objconv.Unknown {
+		return p.num, nil
+	}
+
+	u, err := p.readVInt()
+	if err != nil {
+		return objconv.Unknown, err
+	}
+
+	i, err := p.readBigInt()
+	if err != nil {
+		return objconv.Unknown, err
+	}
+
+	scale := zigzagDecode(u)
+
+	if scale > math.MaxInt32 || scale < math.MinInt32 {
+		return objconv.Unknown, errorf("invalid scale of big decimal: %d", scale)
+	}
+
+	// The decimal exponent of the value is approximated from the number of
+	// bits of the unscaled value, values whose exponent is far beyond the
+	// range of float64 are rounded to zero or infinity without computing the
+	// power of ten, which could exhaust the memory with large scales.
+	p.num = objconv.Float
+
+	switch exp := int64(float64(i.BitLen())*math.Log10(2)) - scale; {
+	case i.Sign() == 0 || exp < -maxDecimalExp:
+		p.f = math.Copysign(0, float64(i.Sign()))
+		return p.num, nil
+	case exp > maxDecimalExp:
+		p.f = math.Inf(i.Sign())
+		return p.num, nil
+	}
+
+	r := new(big.Rat).SetInt(i)
+	x := new(big.Int).Exp(big.NewInt(10), big.NewInt(abs(scale)), nil)
+
+	if scale >= 0 {
+		r.Quo(r, new(big.Rat).SetInt(x))
+	} else {
+		r.Mul(r, new(big.Rat).SetInt(x))
+	}
+
+	p.f, _ = r.Float64()
+	return p.num, nil
+}
+
+// peek reads the first byte of the next value, skipping the headers and end
+// of content markers found between top-level values.
+func (p *Parser) peek() (err error) {
+	for !p.ok {
+		if p.tag, err = p.r.ReadByte(); err != nil {
+			return
+		}
+
+		if !p.key {
+			switch p.tag {
+			case Header[0]:
+				if err = p.readHeader(); err != nil {
+					return
+				}
+				continue
+			case tokenEndContent:
+				continue
+			}
+		}
+
+		p.ok = true
+		p.num = objconv.Unknown
+	}
+	return
+}
+
+func (p *Parser) readHeader() error {
+	var h [3]byte
+
+	if _, err := io.ReadFull(p.r, h[:]); err != nil {
+		return unexpectedEOF(err)
+	}
+
+	if h[0] != Header[1] || h[1] != Header[2] {
+		return errorf("invalid header: %q", append([]byte{Header[0]}, h[:]...))
+	}
+
+	if v := h[2] & versionMask; v != 0 {
+		return errorf("unsupported version: %d", v>>4)
+	}
+
+	// The shared tables are not carried over to the next document.
+	p.flags = h[2]
+	p.keys = p.keys[:0]
+	p.values = p.values[:0]
+	return nil
+}
+
+// ref returns the string at index i of the shared table, flag is the flag of
+// the header which enables the table.
+func (p *Parser) ref(i int, table []string, flag byte) ([]byte, error) {
+	what := "key"
+	if flag == flagSharedValues {
+		what = "value"
+	}
+
+	if p.flags&flag == 0 {
+		return nil, errorf("invalid reference to a shared %s, the header doesn't enable shared %ss", what, what)
+	}
+
+	if i < 0 || i >= len(table) {
+		return nil, errorf("invalid reference to the shared %s %d, %d %ss were seen", what, i, len(table), what)
+	}
+
+	p.s = append(p.s[:0], table[i]...)
+	return p.s, nil
+}
+
+func (p *Parser) readLongRef(tag byte) (int, error) {
+	c, err := p.r.ReadByte()
+	if err != nil {
+		return 0, unexpectedEOF(err)
+	}
+	return int(tag&0x03)<<8 | int(c), nil
+}
+
+func (p *Parser) readVInt() (u uint64, err error) {
+	var c byte
+
+	for i := 0; ; i++ {
+		if c, err = p.r.ReadByte(); err != nil {
+			err = unexpectedEOF(err)
+			return
+		}
+
+		if c&0x80 != 0 {
+			if u>>58 != 0 {
+				break
+			}
+			u = u<<6 | uint64(c&0x3F)
+			return
+		}
+
+		if i == 9 || u>>57 != 0 {
+			break
+		}
+
+		u = u<<7 | uint64(c)
+	}
+
+	err = errorf("variable length integer overflows 64 bits")
+	return
+}
+
+func (p *Parser) readLength() (int, error) {
+	u, err := p.readVInt()
+	if err != nil {
+		return 0, err
+	}
+	if u > math.MaxInt32 {
+		return 0, errorf("invalid length: %d", u)
+	}
+	return int(u), nil
+}
+
+func (p *Parser) readBigInt() (*big.Int, error) {
+	n, err := p.readLength()
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := p.read(len7Bit(n))
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, n)
+	decode7Bit(b, src)
+
+	// The bytes are the two's complement representation of the integer.
+	i := new(big.Int).SetBytes(b)
+
+	if n != 0 && b[0]&0x80 != 0 {
+		i.Sub(i, new(big.Int).Lsh(big.NewInt(1), uint(8*n)))
+	}
+
+	return i, nil
+}
+
+func (p *Parser) readFloatBits(n int) (u uint64, err error) {
+	var b []byte
+
+	if b, err = p.read(n); err != nil {
+		return
+	}
+
+	for _, c := range b {
+		u = u<<7 | uint64(c&0x7F)
+	}
+
+	return
+}
+
+func (p *Parser) read(n int) (b []byte, err error) {
+	// The length comes from the input, the buffer grows as bytes are read
+	// instead of being allocated upfront.
+	p.s, err = objutil.AppendFull(p.s[:0], p.r, n)
+	b = p.s
+	err = unexpectedEOF(err)
+	return
+}
+
+func (p *Parser) readUntilEnd() (b []byte, err error) {
+	b = p.s[:0]
+
+	for {
+		var chunk []byte
+		chunk, err = p.r.ReadSlice(tokenEndString)
+		b = append(b, chunk...)
+
+		if err != bufio.ErrBufferFull {
+			break
+		}
+	}
+
+	if err != nil {
+		err = unexpectedEOF(err)
+		return
+	}
+
+	p.s = b
+	b = b[:len(b)-1]
+	return
+}
+
+// addShared adds s to a table of shared strings, the table is emptied when it
+// is full, like the emitter does.
+func addShared(table []string, s []byte) []string {
+	if len(table) == maxSharedStrings {
+		table = table[:0]
+	}
+	return append(table, string(s))
+}
+
+func abs(i int64) int64 {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
@@ -0,0 +1,197 @@
+// Package smile implements a parser and an emitter for the Smile format, the
+// binary JSON format of the Jackson library
+// (https://github.com/FasterXML/smile-format-specification).
+//
+// Documents start with a four bytes header, the last byte holding the version
+// of the format and flags that tell whether the shared key and value tables are
+// in use. The emitter writes the header before the first value, and the parser
+// reads it when it is present (documents without a header are parsed with the
+// default settings of Jackson).
+//
+// The shared tables let the emitter write back-references to the keys and short
+// string values that it already wrote, instead of repeating them. The parser
+// keeps the same tables to resolve the references, which also means the strings
+// that were seen once are interned and not allocated again.
+//
+// Smile has no time, duration or error types, these values are emitted as
+// strings, like the JSON emitter does.
+package smile
+
+import (
+	"fmt"
+	"io"
+)
+
+// Header is the prefix of the header of Smile documents, the fourth byte of the
+// header holds the version and flags.
+const Header = ":)\n"
+
+const ( // header flags
+	flagSharedKeys   byte = 0x01
+	flagSharedValues byte = 0x02
+	flagRawBinary    byte = 0x04
+	versionMask      byte = 0xF0
+)
+
+const ( // value mode tokens
+	tokenEmptyString byte = 0x20
+	tokenNull        byte = 0x21
+	tokenFalse       byte = 0x22
+	tokenTrue        byte = 0x23
+	tokenInt32       byte = 0x24
+	tokenInt64       byte = 0x25
+	tokenBigInt      byte = 0x26
+	tokenFloat32     byte = 0x28
+	tokenFloat64     byte = 0x29
+	tokenBigDecimal  byte = 0x2A
+	tokenTinyASCII   byte = 0x40 // 1 to 32 bytes
+	tokenSmallASCII  byte = 0x60 // 33 to 64 bytes
+	tokenTinyUTF8    byte = 0x80 // 2 to 33 bytes
+	tokenSmallUTF8   byte = 0xA0 // 34 to 65 bytes
+	tokenSmallInt    byte = 0xC0 // 5 bits zigzag integers
+	tokenLongASCII   byte = 0xE0 // terminated by tokenEndString
+	tokenLongUTF8    byte = 0xE4 // terminated by tokenEndString
+	tokenBinary7Bit  byte = 0xE8
+	tokenLongRef     byte = 0xEC // 10 bits index of a shared value
+	tokenArrayBegin  byte = 0xF8
+	tokenArrayEnd    byte = 0xF9
+	tokenMapBegin    byte = 0xFA
+	tokenMapEnd      byte = 0xFB
+	tokenEndString   byte = 0xFC
+	tokenRawBinary   byte = 0xFD
+	tokenEndContent  byte = 0xFF
+)
+
+const ( // key mode tokens
+	keyEmpty    byte = 0x20
+	keyLongRef  byte = 0x30 // 10 bits index of a shared key
+	keyLongUTF8 byte = 0x34 // terminated by tokenEndString
+	keyShortRef byte = 0x40 // 6 bits index of a shared key
+	keyASCII    byte = 0x80 // 1 to 64 bytes
+	keyUTF8     byte = 0xC0 // 2 to 57 bytes
+)
+
+const (
+	// maxSharedStrings is the size of the shared key and value tables, they
+	// are emptied when they are full.
+	maxSharedStrings = 1024
+
+	// maxShortValueRef is the largest index of a shared value which can be
+	// referenced with a single byte.
+	maxShortValueRef = 30
+
+	// maxShortKeyRef is the largest index of a shared key which can be
+	// referenced with a single byte.
+	maxShortKeyRef = 63
+)
+
+// validRef returns true if i is the index of a shared string that can be
+// referenced, indexes which would produce the reserved bytes 0xFE and 0xFF in
+// a long reference are never used.
+func validRef(i int) bool {
+	return (i & 0xFF) < 0xFE
+}
+
+// isASCII returns true if s contains only ASCII characters.
+func isASCII(s string) bool {
+	for i := 0; i != len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// appendVInt appends the Smile representation of the variable length unsigned
+// integer u to b, the last byte has its high bit set and holds 6 bits while the
+// other ones hold 7 bits.
+func appendVInt(b []byte, u uint64) []byte {
+	var a [10]byte
+	i := len(a) - 1
+	a[i] = 0x80 | byte(u&0x3F)
+
+	for u >>= 6; u != 0; u >>= 7 {
+		i--
+		a[i] = byte(u & 0x7F)
+	}
+
+	return append(b, a[i:]...)
+}
+
+// append7Bit appends the 7 bits encoding of data to b, each byte of the output
+// holds 7 bits of the input and the last one holds the remaining bits aligned
+// to the right.
+func append7Bit(b []byte, data []byte) []byte {
+	var acc uint
+	var n uint
+
+	for _, c := range data {
+		acc, n = acc<<8|uint(c), n+8
+
+		for n >= 7 {
+			n -= 7
+			b = append(b, byte(acc>>n)&0x7F)
+		}
+
+		acc &= 1<<n - 1
+	}
+
+	if n != 0 {
+		b = append(b, byte(acc))
+	}
+
+	return b
+}
+
+// len7Bit returns the length of the 7 bits encoding of n bytes.
+func len7Bit(n int) int {
+	return n + (n+6)/7
+}
+
+// decode7Bit decodes the 7 bits encoding src of len(dst) bytes into dst, src
+// must have a length of len7Bit(len(dst)).
+func decode7Bit(dst []byte, src []byte) {
+	var acc uint
+	var k uint
+	var i int
+
+	last := len(src) - 1
+	rest := uint(len(dst) % 7)
+
+	for j, c := range src {
+		w := uint(7)
+
+		if j == last && rest != 0 {
+			w = rest
+		}
+
+		acc, k = acc<<w|uint(c)&(1<<w-1), k+w
+
+		for k >= 8 {
+			k -= 8
+			dst[i] = byte(acc >> k)
+			i++
+		}
+
+		acc &= 1<<k - 1
+	}
+}
+
+func zigzagEncode(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+func zigzagDecode(u uint64) int64 {
+	return int64(u>>1) ^ -int64(u&1)
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("objconv/smile: "+format, args...)
+}
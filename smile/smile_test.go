@@ -0,0 +1,316 @@
+package smile
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"math"
+	"reflect"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/segmentio/objconv"
+	"github.com/segmentio/objconv/objtests"
+)
+
+func TestCodec(t *testing.T) {
+	objtests.TestCodec(t, Codec)
+}
+
+func BenchmarkCodec(b *testing.B) {
+	objtests.BenchmarkCodec(b, Codec)
+}
+
+func TestEmitter(t *testing.T) {
+	tests := []struct {
+		v      interface{}
+		values bool
+		s      string
+	}{
+		{
+			v: map[string]int{"a": 1},
+			s: "3a290a01" + "fa" + "8061" + "c2" + "fb",
+		},
+		{
+			v: []map[string]int{{"a": 1}, {"a": 2}},
+			s: "3a290a01" + "f8" + "fa8061c2fb" + "fa40c4fb" + "f9",
+		},
+		{
+			v:      []string{"abc", "abc", ""},
+			values: true,
+			s:      "3a290a03" + "f8" + "42616263" + "01" + "20" + "f9",
+		},
+		{
+			v: []int64{-16, 15, 16, math.MaxInt32 + 1},
+			s: "3a290a01" + "f8" + "df" + "de" + "24a0" + "252000000080" + "f9",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.s, func(t *testing.T) {
+			b := &bytes.Buffer{}
+			e := NewEmitter(b)
+			e.SharedValues = test.values
+
+			if err := objconv.NewEncoder(e).Encode(test.v); err != nil {
+				t.Fatal(err)
+			}
+
+			if s := hex.EncodeToString(b.Bytes()); s != test.s {
+				t.Error("bad encoding:", s)
+			}
+
+			v := reflect.New(reflect.TypeOf(test.v))
+
+			if err := objconv.NewDecoder(NewParser(b)).Decode(v.Interface()); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(v.Elem().Interface(), test.v) {
+				t.Errorf("bad decoding: %#v", v.Elem().Interface())
+			}
+		})
+	}
+}
+
+func TestSharedStrings(t *testing.T) {
+	// More strings than the shared tables can hold are used so the tables are
+	// emptied, and each string is repeated so references are written.
+	var in []map[string]string
+
+	for i := 0; i != 3000; i++ {
+		k := "key-" + strconv.Itoa(i%1500)
+		v := "value-" + strconv.Itoa(i%1200)
+		in = append(in, map[string]string{k: v, "name": v})
+	}
+
+	var shared, unshared bytes.Buffer
+
+	e := NewEmitter(&shared)
+	e.SharedValues = true
+
+	if err := objconv.NewEncoder(e).Encode(in); err != nil {
+		t.Fatal(err)
+	}
+
+	e = NewEmitter(&unshared)
+	e.SharedKeys = false
+
+	if err := objconv.NewEncoder(e).Encode(in); err != nil {
+		t.Fatal(err)
+	}
+
+	if shared.Len() >= unshared.Len() {
+		t.Errorf("sharing strings didn't reduce the size of the output: %d >= %d", shared.Len(), unshared.Len())
+	}
+
+	for _, b := range []*bytes.Buffer{&shared, &unshared} {
+		var out []map[string]string
+
+		if err := objconv.NewDecoder(NewParser(b)).Decode(&out); err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(in, out) {
+			t.Error("bad decoding")
+		}
+	}
+}
+
+func TestBinary(t *testing.T) {
+	for _, raw := range []bool{false, true} {
+		for n := 0; n != 20; n++ {
+			in := make([]byte, n)
+			for i := range in {
+				in[i] = byte(0xFF - i*13)
+			}
+
+			b := &bytes.Buffer{}
+			e := NewEmitter(b)
+			e.RawBinary = raw
+
+			if err := objconv.NewEncoder(e).Encode(in); err != nil {
+				t.Fatal(err)
+			}
+
+			if !raw {
+				// Skip the header, the token and the length.
+				for _, c := range b.Bytes()[6:] {
+					if c >= 0x80 {
+						t.Errorf("the 7 bits encoding of %d bytes contains the byte %#02x", n, c)
+					}
+				}
+			}
+
+			var out []byte
+
+			if err := objconv.NewDecoder(NewParser(b)).Decode(&out); err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(in, out) {
+				t.Errorf("bad decoding of %d bytes (raw = %t): %x", n, raw, out)
+			}
+		}
+	}
+}
+
+func TestParserBigNumbers(t *testing.T) {
+	tests := []struct {
+		s string
+		v interface{}
+	}{
+		{
+			// BigInteger of the bytes 0x01 0x00, 7 bits encoded.
+			s: "26" + "82" + "004000",
+			v: int64(256),
+		},
+		{
+			// BigInteger of the byte 0xFF (-1), 7 bits encoded.
+			s: "26" + "81" + "7f01",
+			v: int64(-1),
+		},
+		{
+			// BigDecimal 15 with a scale of 1 (1.5).
+			s: "2a" + "82" + "81" + "0701",
+			v: 1.5,
+		},
+		{
+			// BigDecimal 15 with a scale of 1e9, which underflows.
+			s: "2a" + "0e732c5080" + "81" + "0701",
+			v: 0.0,
+		},
+		{
+			// BigDecimal 15 with a scale of -1e9, which overflows.
+			s: "2a" + "0e732c4fbf" + "81" + "0701",
+			v: math.Inf(1),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.s, func(t *testing.T) {
+			b, _ := hex.DecodeString(test.s)
+			var v interface{}
+
+			if err := objconv.NewDecoder(NewParser(bytes.NewReader(b))).Decode(&v); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(v, test.v) {
+				t.Errorf("%#v", v)
+			}
+		})
+	}
+}
+
+func TestParserErrors(t *testing.T) {
+	tests := []struct {
+		s   string
+		err string
+	}{
+		{
+			s:   "3a290a10" + "21",
+			err: "objconv/smile: unsupported version: 1",
+		},
+		{
+			s:   "3a290a00" + "f8" + "01" + "f9",
+			err: "objconv/smile: invalid reference to a shared value, the header doesn't enable shared values",
+		},
+		{
+			s:   "3a290a03" + "f8" + "05" + "f9",
+			err: "objconv/smile: invalid reference to the shared value 4, 0 values were seen",
+		},
+		{
+			s:   "3a290a01" + "fa" + "41" + "21" + "fb",
+			err: "objconv/smile: invalid reference to the shared key 1, 0 keys were seen",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.err, func(t *testing.T) {
+			b, _ := hex.DecodeString(test.s)
+			var v interface{}
+
+			if err := objconv.NewDecoder(NewParser(bytes.NewReader(b))).Decode(&v); err == nil || err.Error() != test.err {
+				t.Error("bad error:", err)
+			}
+		})
+	}
+}
+
+func TestParserTruncated(t *testing.T) {
+	var b bytes.Buffer
+
+	if err := NewEncoder(&b).Encode(map[string]interface{}{
+		"a": []interface{}{int64(1), -2.5, "hello", []byte("world"), nil, true},
+		"b": map[string]interface{}{"c": "a much longer string value"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	big, _ := hex.DecodeString("3a290a00" + "2a" + "82" + "81" + "0701")
+
+	for _, in := range [][]byte{b.Bytes(), big} {
+		for n := 0; n < len(in); n++ {
+			var v interface{}
+
+			if err := objconv.NewDecoder(NewParser(bytes.NewReader(in[:n]))).Decode(&v); err == nil {
+				t.Errorf("no error decoding the first %d bytes of %x", n, in)
+			}
+		}
+	}
+}
+
+func TestParserTruncatedLargeLength(t *testing.T) {
+	// The values declare lengths of 2 GiB but the input ends after a few
+	// bytes, the parser must report the truncation without allocating them.
+	header := []byte{0x3a, 0x29, 0x0a, 0x00}
+
+	for _, tag := range []byte{tokenRawBinary, tokenBinary7Bit, tokenBigInt} {
+		in := append(appendVInt(append(header, tag), math.MaxInt32), 'A')
+
+		var v interface{}
+		var m1, m2 runtime.MemStats
+
+		runtime.ReadMemStats(&m1)
+		err := objconv.NewDecoder(NewParser(bytes.NewReader(in))).Decode(&v)
+		runtime.ReadMemStats(&m2)
+
+		if err != io.ErrUnexpectedEOF {
+			t.Errorf("%x: expected io.ErrUnexpectedEOF but got %v", in, err)
+		}
+
+		if n := m2.TotalAlloc - m1.TotalAlloc; n > 1<<20 {
+			t.Errorf("%x: too many bytes allocated: %d", in, n)
+		}
+	}
+}
+
+func TestStreamDecoder(t *testing.T) {
+	for _, in := range [][]int{{}, {1, 2, 3}} {
+		b, err := Marshal(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var out []int
+		d := NewStreamDecoder(bytes.NewReader(b))
+
+		for {
+			var v int
+			if d.Decode(&v) != nil {
+				break
+			}
+			out = append(out, v)
+		}
+
+		if err := d.Err(); err != nil {
+			t.Error(err)
+		}
+
+		if len(out) != len(in) || (len(in) != 0 && !reflect.DeepEqual(in, out)) {
+			t.Errorf("%v", out)
+		}
+	}
+}
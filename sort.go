@@ -2,6 +2,7 @@ package objconv
 
 import (
 	"bytes"
+	"math"
 	"reflect"
 	"sort"
 )
@@ -22,7 +23,7 @@ type sortFloatValues []reflect.Value
 
 func (s sortFloatValues) Len() int               { return len(s) }
 func (s sortFloatValues) Swap(i int, j int)      { s[i], s[j] = s[j], s[i] }
-func (s sortFloatValues) Less(i int, j int) bool { return s[i].Float() < s[j].Float() }
+func (s sortFloatValues) Less(i int, j int) bool { return lessFloat(s[i].Float(), s[j].Float()) }
 
 type sortStringValues []reflect.Value
 
@@ -38,6 +39,123 @@ func (s sortBytesValues) Less(i int, j int) bool {
 	return bytes.Compare(s[i].Bytes(), s[j].Bytes()) < 0
 }
 
+type sortInterfaceValues []reflect.Value
+
+func (s sortInterfaceValues) Len() int          { return len(s) }
+func (s sortInterfaceValues) Swap(i int, j int) { s[i], s[j] = s[j], s[i] }
+func (s sortInterfaceValues) Less(i int, j int) bool {
+	return lessValue(s[i].Elem(), s[j].Elem())
+}
+
+// lessFloat compares two floating point numbers, NaN values are ordered before
+// all other numbers so the ordering remains consistent.
+func lessFloat(a float64, b float64) bool {
+	return a < b || (math.IsNaN(a) && !math.IsNaN(b))
+}
+
+// lessValue is used to sort dynamically typed values, values are grouped by
+// kind (nil, booleans, numbers, strings and byte slices) and sorted within
+// their group. Numbers of different types are compared by their numeric value.
+func lessValue(a reflect.Value, b reflect.Value) bool {
+	ra, rb := sortRank(a), sortRank(b)
+
+	if ra != rb {
+		return ra < rb
+	}
+
+	switch ra {
+	case sortRankBool:
+		return !a.Bool() && b.Bool()
+
+	case sortRankNumber:
+		return lessNumber(a, b)
+
+	case sortRankString:
+		return a.String() < b.String()
+
+	case sortRankBytes:
+		return bytes.Compare(a.Bytes(), b.Bytes()) < 0
+	}
+
+	return false
+}
+
+func lessNumber(a reflect.Value, b reflect.Value) bool {
+	switch ka, kb := numberKind(a), numberKind(b); {
+	case ka == reflect.Int && kb == reflect.Int:
+		return a.Int() < b.Int()
+
+	case ka == reflect.Uint && kb == reflect.Uint:
+		return a.Uint() < b.Uint()
+
+	case ka == reflect.Int && kb == reflect.Uint:
+		return a.Int() < 0 || uint64(a.Int()) < b.Uint()
+
+	case ka == reflect.Uint && kb == reflect.Int:
+		return b.Int() >= 0 && a.Uint() < uint64(b.Int())
+
+	default:
+		return lessFloat(numberFloat(a), numberFloat(b))
+	}
+}
+
+func numberKind(v reflect.Value) reflect.Kind {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return reflect.Int
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return reflect.Uint
+	default:
+		return reflect.Float64
+	}
+}
+
+func numberFloat(v reflect.Value) float64 {
+	switch numberKind(v) {
+	case reflect.Int:
+		return float64(v.Int())
+	case reflect.Uint:
+		return float64(v.Uint())
+	default:
+		return v.Float()
+	}
+}
+
+const (
+	sortRankNil = iota
+	sortRankBool
+	sortRankNumber
+	sortRankString
+	sortRankBytes
+	sortRankOther
+)
+
+func sortRank(v reflect.Value) int {
+	if !v.IsValid() {
+		return sortRankNil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return sortRankBool
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return sortRankNumber
+
+	case reflect.String:
+		return sortRankString
+
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return sortRankBytes
+		}
+	}
+
+	return sortRankOther
+}
+
 func sortValues(typ reflect.Type, v []reflect.Value) {
 	switch typ.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
@@ -52,6 +170,9 @@ func sortValues(typ reflect.Type, v []reflect.Value) {
 	case reflect.String:
 		sort.Sort(sortStringValues(v))
 
+	case reflect.Interface:
+		sort.Sort(sortInterfaceValues(v))
+
 	case reflect.Slice:
 		if typ.Elem().Kind() == reflect.Uint8 {
 			sort.Sort(sortBytesValues(v))
@@ -0,0 +1,105 @@
+package objconv
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestEncoderSortMapKeys(t *testing.T) {
+	tests := []struct {
+		in  interface{}
+		out []interface{}
+	}{
+		{
+			in:  map[int]bool{10: true, 2: true, 1: true, -1: true},
+			out: []interface{}{int64(-1), int64(1), int64(2), int64(10)},
+		},
+		{
+			in:  map[uint64]bool{10: true, 2: true, 1: true, math.MaxUint64: true},
+			out: []interface{}{uint64(1), uint64(2), uint64(10), uint64(math.MaxUint64)},
+		},
+		{
+			in:  map[float64]bool{10: true, 2.5: true, -1: true},
+			out: []interface{}{float64(-1), float64(2.5), float64(10)},
+		},
+		{
+			in:  map[string]bool{"10": true, "2": true, "1": true},
+			out: []interface{}{"1", "10", "2"},
+		},
+		{
+			in: map[interface{}]bool{
+				"A":             true,
+				int64(-1):       true,
+				uint64(2):       true,
+				float64(1.5):    true,
+				int64(10):       true,
+				nil:             true,
+				true:            true,
+				uint64(1 << 63): true,
+			},
+			out: []interface{}{nil, true, int64(-1), float64(1.5), uint64(2), int64(10), uint64(1 << 63), "A"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(reflect.TypeOf(test.in).String(), func(t *testing.T) {
+			keys := []interface{}{}
+			e := Encoder{Emitter: &keysEmitter{keys: &keys}, SortMapKeys: true}
+
+			if err := e.Encode(test.in); err != nil {
+				t.Error(err)
+			}
+
+			if len(keys) != len(test.out) {
+				t.Fatalf("%#v", keys)
+			}
+
+			for i := range keys {
+				if !reflect.DeepEqual(keys[i], test.out[i]) {
+					t.Errorf("bad key at index %d: %#v != %#v", i, keys[i], test.out[i])
+				}
+			}
+		})
+	}
+}
+
+// keysEmitter records the keys of the top-level map being emitted.
+type keysEmitter struct {
+	discardEmitter
+	keys  *[]interface{}
+	key   bool
+	depth int
+}
+
+func (e *keysEmitter) add(v interface{}) error {
+	if e.key && e.depth == 1 {
+		*e.keys = append(*e.keys, v)
+		e.key = false
+	}
+	return nil
+}
+
+func (e *keysEmitter) EmitNil() error                   { return e.add(nil) }
+func (e *keysEmitter) EmitBool(v bool) error            { return e.add(v) }
+func (e *keysEmitter) EmitInt(v int64, _ int) error     { return e.add(v) }
+func (e *keysEmitter) EmitUint(v uint64, _ int) error   { return e.add(v) }
+func (e *keysEmitter) EmitFloat(v float64, _ int) error { return e.add(v) }
+func (e *keysEmitter) EmitString(v string) error        { return e.add(v) }
+func (e *keysEmitter) EmitMapBegin(n int) error {
+	e.depth++
+	e.key = true
+	return nil
+}
+func (e *keysEmitter) EmitMapEnd() error   { e.depth--; return nil }
+func (e *keysEmitter) EmitMapValue() error { e.key = false; return nil }
+func (e *keysEmitter) EmitMapNext() error  { e.key = true; return nil }
+
+func TestLessFloatNaN(t *testing.T) {
+	if !lessFloat(math.NaN(), math.Inf(-1)) {
+		t.Error("NaN must be ordered before all other numbers")
+	}
+	if lessFloat(math.Inf(-1), math.NaN()) || lessFloat(math.NaN(), math.NaN()) {
+		t.Error("numbers must not be ordered before NaN")
+	}
+}
@@ -1,8 +1,20 @@
 package objconv
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
 	"sync"
+	"unicode/utf8"
 
 	"github.com/segmentio/objconv/objutil"
 )
@@ -16,6 +28,11 @@ type structField struct {
 	// The name of the field in the structure.
 	name string
 
+	// Tagged is set to true when the name of the field was set by its tag, it
+	// takes precedence over untagged fields of the same name promoted from
+	// embedded structs at the same depth.
+	tagged bool
+
 	// Omitempty is set to true when the field should be omitted if it has an
 	// empty value.
 	omitempty bool
@@ -24,96 +41,1068 @@ type structField struct {
 	// value.
 	omitzero bool
 
+	// JSON is set to true when the field is serialized as a string containing
+	// its JSON representation.
+	json bool
+
+	// Format is the name of the codec used to serialize the field to a byte
+	// slice, see the format tag option.
+	format string
+
+	// Transforms applied to decode the string or byte slice that the field is
+	// serialized as, see the base64 and gzip tag options.
+	transforms []string
+
+	// Secret is set to true when the field values are marked as secrets when
+	// passed to Encoder.ValueFunc.
+	secret bool
+
+	// Lenient is set to true when the field is decoded as if the Lenient
+	// option of the decoder was enabled.
+	lenient bool
+
+	// Readonly is set to true when the field is never decoded, see the
+	// readonly tag option.
+	readonly bool
+
+	// Keys and layouts of the date and time parts that the field is composed
+	// from when it is decoded, see the datefield and timefield tag options.
+	dateField  string
+	timeField  string
+	dateLayout string
+	timeLayout string
+
+	// Default value assigned to the field when it is absent from the decoded
+	// map, see the default tag option.
+	dflt *fieldDefault
+
+	// Type reported by ValueParser.ParseType for the values of the field, or
+	// Unknown when it depends on the values, see valueTypeOf.
+	typ Type
+
 	// cache for the encoder and decoder methods
 	encode encodeFunc
 	decode decodeFunc
 }
 
-func makeStructField(f reflect.StructField, c map[reflect.Type]*structType) structField {
-	t := objutil.ParseTag(f.Tag.Get("objconv"))
+func makeStructField(f reflect.StructField, tag string, adapters bool, c map[reflect.Type]*structType) structField {
+	t := objutil.ParseTag(f.Tag.Get(tag))
 	s := structField{
-		index:     f.Index,
-		name:      f.Name,
-		omitempty: t.Omitempty,
-		omitzero:  t.Omitzero,
+		index:      f.Index,
+		name:       f.Name,
+		omitempty:  t.Omitempty,
+		omitzero:   t.Omitzero,
+		json:       t.JSON,
+		format:     t.Format,
+		transforms: t.Transforms,
+		secret:     t.Secret,
+		lenient:    t.Lenient,
+		readonly:   t.Readonly,
+
+		dateField:  t.DateField,
+		timeField:  t.TimeField,
+		dateLayout: t.DateLayout,
+		timeLayout: t.TimeLayout,
+
+		typ: valueTypeOf(f.Type),
 
 		encode: makeEncodeFunc(f.Type, encodeFuncOpts{
-			recurse: true,
-			structs: c,
+			recurse:  true,
+			adapters: adapters,
+			structs:  c,
+			tag:      tag,
 		}),
 
 		decode: makeDecodeFunc(f.Type, decodeFuncOpts{
-			recurse: true,
-			structs: c,
+			recurse:  true,
+			adapters: adapters,
+			structs:  c,
+			tag:      tag,
 		}),
 	}
 
 	if len(t.Name) != 0 {
 		s.name = t.Name
+		s.tagged = true
+	}
+
+	s.dflt = makeFieldDefault(s.decode, f.Type, s.name, t)
+
+	if t.AsString && isStringableKind(f.Type) {
+		s.encode = encodeFieldAsString(s.encode)
+		s.decode = decodeFieldFromString(s.decode, s.name, f.Type)
+	}
+
+	if len(s.transforms) != 0 && len(s.format) == 0 && !isStringOrBytes(f.Type) {
+		// Transforms are applied to strings or byte slices, values of other
+		// types are serialized to their JSON representation first.
+		s.json = true
+	}
+
+	if len(s.format) != 0 {
+		s.encode = encodeFieldAsFormat(s.encode, s.name, s.format)
+		s.decode = decodeFieldAsFormat(s.decode, s.name, s.format)
+	} else if s.json {
+		s.encode = encodeFieldAsJSON(s.encode)
+		s.decode = decodeFieldAsJSON(s.decode)
+	}
+
+	if len(s.transforms) != 0 {
+		s.encode = encodeFieldWithTransforms(s.encode, s.name, s.transforms)
+		s.decode = decodeFieldWithTransforms(s.decode, s.name, s.transforms)
+	}
+
+	if c, err := makeFieldConstraints(f.Type, s.name, t); c != nil || err != nil {
+		s.decode = decodeFieldWithConstraints(s.decode, s.name, c, err)
+	}
+
+	if s.lenient {
+		s.decode = decodeFieldLeniently(s.decode, s.name)
 	}
 
 	return s
 }
 
+// isStringableKind returns true if t, or the type it points to, is a boolean
+// or numeric type, which the string tag option applies to.
+func isStringableKind(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func encodeFieldAsString(encode encodeFunc) encodeFunc {
+	return func(e Encoder, v reflect.Value) (err error) {
+		x := NewValueEmitter()
+
+		if err = encode(e.options().withEmitter(x), v); err != nil {
+			return
+		}
+
+		switch value := x.Value().(type) {
+		case nil:
+			return e.Emitter.EmitNil()
+		case float64:
+			return e.Emitter.EmitString(strconv.FormatFloat(value, 'g', -1, 64))
+		default:
+			return e.Emitter.EmitString(fmt.Sprint(value))
+		}
+	}
+}
+
+func decodeFieldFromString(decode decodeFunc, name string, t reflect.Type) decodeFunc {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return func(d Decoder, v reflect.Value) (typ Type, err error) {
+		var b []byte
+		var x interface{}
+
+		if typ, b, err = d.decodeTypeAndString(); err != nil {
+			if typ != Unknown && typ != Nil && typ != String && typ != Bytes {
+				err = fmt.Errorf("objconv: bad value for field %s: the string tag option expects a string but found %s", name, typ)
+			}
+			return
+		}
+
+		if typ == Nil {
+			d.Parser = NewValueParser(nil)
+			return decode(d, v)
+		}
+
+		s := string(b)
+
+		switch t.Kind() {
+		case reflect.Bool:
+			x, err = strconv.ParseBool(s)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			x, err = strconv.ParseInt(s, 10, 64)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			x, err = strconv.ParseUint(s, 10, 64)
+		default:
+			x, err = strconv.ParseFloat(s, 64)
+		}
+
+		if err != nil {
+			err = fmt.Errorf("objconv: bad value for field %s: %q is not a valid %s", name, s, t)
+			return
+		}
+
+		d.Parser = NewValueParser(x)
+		_, err = decode(d, v)
+		return
+	}
+}
+
+// adapterFieldError adds the name of the field to err if the field has the
+// type t and its value was decoded by an adapter. Adapters usually validate the
+// values they decode, but their errors lack the context of where the invalid
+// value was found.
+//
+// Errors reporting conditions which aren't caused by the value of the field,
+// like reaching the end of the input or the limits of the decoder, are
+// returned unchanged.
+func (d Decoder) adapterFieldError(err error, name string, t reflect.Type) error {
+	if err == nil || !d.adapted(t) {
+		return err
+	}
+
+	switch err {
+	case io.EOF, io.ErrUnexpectedEOF, ErrTruncated, ErrMaxDepth, ErrMaxAllocBytes, ErrTooLarge:
+		return err
+	}
+
+	if _, ok := err.(*ScanError); ok || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	return &adapterError{field: name, err: err}
+}
+
+// adapted returns true if values of type t are decoded by an adapter.
+func (d Decoder) adapted(t reflect.Type) bool {
+	if a, ok := d.Adapters[t]; ok && a.Decode != nil {
+		return true
+	}
+	_, ok := AdapterOf(t)
+	return ok
+}
+
+// adapterError wraps the errors returned by adapters decoding the value of a
+// struct field, see adapterFieldError.
+type adapterError struct {
+	field string
+	err   error
+}
+
+func (e *adapterError) Error() string {
+	return "objconv: bad value for field " + e.field + ": " + strings.TrimPrefix(e.err.Error(), "objconv: ")
+}
+
+func (e *adapterError) Unwrap() error { return e.err }
+
+// fieldConstraints are the constraints that the decoded values of a field are
+// validated against, see the min, max, minlen, maxlen and pattern tag options.
+type fieldConstraints struct {
+	tag     objutil.Tag // used to report the constraints in error messages
+	min     *float64
+	max     *float64
+	minLen  *int
+	maxLen  *int
+	pattern *regexp.Regexp
+}
+
+func makeFieldConstraints(t reflect.Type, name string, tag objutil.Tag) (c *fieldConstraints, err error) {
+	if len(tag.Min) == 0 && len(tag.Max) == 0 && len(tag.MinLen) == 0 && len(tag.MaxLen) == 0 && len(tag.Pattern) == 0 {
+		return
+	}
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	c = &fieldConstraints{tag: tag}
+
+	parseFloat := func(option string, value string) (*float64, error) {
+		if len(value) == 0 {
+			return nil, nil
+		}
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+			reflect.Float32, reflect.Float64, reflect.Interface:
+		default:
+			return nil, fmt.Errorf("objconv: the %s constraint of field %s can't be applied to values of type %s", option, name, t)
+		}
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("objconv: invalid %s constraint on field %s: %q is not a number", option, name, value)
+		}
+		return &f, nil
+	}
+
+	parseInt := func(option string, value string) (*int, error) {
+		if len(value) == 0 {
+			return nil, nil
+		}
+		switch t.Kind() {
+		case reflect.String, reflect.Slice, reflect.Map, reflect.Array, reflect.Interface:
+		default:
+			return nil, fmt.Errorf("objconv: the %s constraint of field %s can't be applied to values of type %s", option, name, t)
+		}
+		i, err := strconv.Atoi(value)
+		if err != nil || i < 0 {
+			return nil, fmt.Errorf("objconv: invalid %s constraint on field %s: %q is not a length", option, name, value)
+		}
+		return &i, nil
+	}
+
+	if c.min, err = parseFloat("min", tag.Min); err != nil {
+		return
+	}
+	if c.max, err = parseFloat("max", tag.Max); err != nil {
+		return
+	}
+	if c.minLen, err = parseInt("minlen", tag.MinLen); err != nil {
+		return
+	}
+	if c.maxLen, err = parseInt("maxlen", tag.MaxLen); err != nil {
+		return
+	}
+
+	if len(tag.Pattern) != 0 {
+		if k := t.Kind(); k != reflect.String && k != reflect.Interface {
+			err = fmt.Errorf("objconv: the pattern constraint of field %s can't be applied to values of type %s", name, t)
+			return
+		}
+		if c.pattern, err = regexp.Compile(tag.Pattern); err != nil {
+			err = fmt.Errorf("objconv: invalid pattern constraint on field %s: %s", name, err)
+			return
+		}
+	}
+
+	return
+}
+
+// check returns a description of the first constraint that v violates, or an
+// empty string if v satisfies all the constraints. Nil values are not checked.
+func (c *fieldConstraints) check(v reflect.Value) string {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return c.checkNumber(float64(v.Int()), v.Interface())
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return c.checkNumber(float64(v.Uint()), v.Interface())
+
+	case reflect.Float32, reflect.Float64:
+		return c.checkNumber(v.Float(), v.Interface())
+
+	case reflect.String:
+		s := v.String()
+
+		if msg := c.checkLength(utf8.RuneCountInString(s)); len(msg) != 0 {
+			return msg
+		}
+
+		if c.pattern != nil && !c.pattern.MatchString(s) {
+			return fmt.Sprintf("%q doesn't match the pattern %s", s, c.tag.Pattern)
+		}
+
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return c.checkLength(v.Len())
+	}
+
+	return ""
+}
+
+func (c *fieldConstraints) checkNumber(f float64, v interface{}) string {
+	switch {
+	case c.min != nil && f < *c.min:
+		return fmt.Sprintf("%v violates the min=%s constraint", v, c.tag.Min)
+	case c.max != nil && f > *c.max:
+		return fmt.Sprintf("%v violates the max=%s constraint", v, c.tag.Max)
+	}
+	return ""
+}
+
+func (c *fieldConstraints) checkLength(n int) string {
+	switch {
+	case c.minLen != nil && n < *c.minLen:
+		return fmt.Sprintf("length %d violates the minlen=%s constraint", n, c.tag.MinLen)
+	case c.maxLen != nil && n > *c.maxLen:
+		return fmt.Sprintf("length %d violates the maxlen=%s constraint", n, c.tag.MaxLen)
+	}
+	return ""
+}
+
+// decodeFieldWithConstraints validates the values decoded by decode against
+// the constraints of the field. Violations are reported as warnings when the
+// Lenient option is set, the value is then retained.
+func decodeFieldWithConstraints(decode decodeFunc, name string, c *fieldConstraints, cerr error) decodeFunc {
+	return func(d Decoder, v reflect.Value) (t Type, err error) {
+		if cerr != nil {
+			return Nil, cerr
+		}
+
+		if t, err = decode(d, v); err != nil || t == Nil || !v.IsValid() {
+			return
+		}
+
+		if msg := c.check(v); len(msg) != 0 {
+			if d.Lenient {
+				d.warn("%s", msg)
+			} else {
+				err = fmt.Errorf("objconv: bad value for field %s: %s", name, msg)
+			}
+		}
+		return
+	}
+}
+
+// fieldDefault is the default value of a field, the literal found in the tag is
+// parsed once when the struct type is looked up.
+type fieldDefault struct {
+	value reflect.Value
+	empty bool  // also assigned to decoded values which are empty
+	err   error // reported when decoding the struct if the default is invalid
+}
+
+// makeFieldDefault parses the default value set on the tag of a field, it
+// returns nil if the tag had no default option. The literal is decoded as if
+// the Lenient option was set, so strings like "42" or "true" can be used as
+// the default values of fields which hold numbers or booleans.
+func makeFieldDefault(decode decodeFunc, t reflect.Type, name string, tag objutil.Tag) *fieldDefault {
+	if len(tag.Default) == 0 {
+		return nil
+	}
+
+	v := reflect.New(t).Elem()
+	d := Decoder{Parser: NewValueParser(tag.Default), Lenient: true}
+
+	if _, err := decode(d, v); err != nil {
+		return &fieldDefault{err: fmt.Errorf("objconv: invalid default value of field %s: %s", name, strings.TrimPrefix(err.Error(), "objconv: "))}
+	}
+
+	c, err := makeFieldConstraints(t, name, tag)
+
+	if err == nil && c != nil {
+		if msg := c.check(v); len(msg) != 0 {
+			err = fmt.Errorf("objconv: invalid default value of field %s: %s", name, msg)
+		}
+	}
+
+	if err != nil {
+		return &fieldDefault{err: err}
+	}
+
+	return &fieldDefault{value: v, empty: tag.DefaultEmpty}
+}
+
+// assign sets v to the default value, pointers and slices are copied so the
+// decoded values don't share memory with the cached default.
+func (f *fieldDefault) assign(v reflect.Value) {
+	switch x := f.value; x.Kind() {
+	case reflect.Ptr:
+		if x.IsNil() {
+			v.Set(x)
+		} else {
+			p := reflect.New(x.Type().Elem())
+			p.Elem().Set(x.Elem())
+			v.Set(p)
+		}
+
+	case reflect.Slice:
+		if x.IsNil() {
+			v.Set(x)
+		} else {
+			s := reflect.MakeSlice(x.Type(), x.Len(), x.Len())
+			reflect.Copy(s, x)
+			v.Set(s)
+		}
+
+	default:
+		v.Set(x)
+	}
+}
+
+func decodeFieldLeniently(decode decodeFunc, name string) decodeFunc {
+	return func(d Decoder, v reflect.Value) (Type, error) {
+		if !d.Lenient && d.warns != nil {
+			// The path of the field wasn't tracked by the struct decoder since
+			// the decoder isn't lenient, so it is prefixed to the warnings
+			// reported here.
+			defer d.prefixWarnings(len(*d.warns), name)
+		}
+		d.Lenient = true
+		return decode(d, v)
+	}
+}
+
+func encodeFieldAsJSON(encode encodeFunc) encodeFunc {
+	return func(e Encoder, v reflect.Value) (err error) {
+		var c Codec
+		var b bytes.Buffer
+
+		if c, err = jsonCodec(); err != nil {
+			return
+		}
+
+		if err = encode(e.options().withEmitter(c.NewEmitter(&b)), v); err != nil {
+			return
+		}
+
+		return e.Emitter.EmitString(b.String())
+	}
+}
+
+func decodeFieldAsJSON(decode decodeFunc) decodeFunc {
+	return func(d Decoder, v reflect.Value) (t Type, err error) {
+		var c Codec
+		var b []byte
+
+		if t, b, err = d.decodeTypeAndString(); err != nil || t == Nil {
+			return
+		}
+
+		if c, err = jsonCodec(); err != nil {
+			return
+		}
+
+		d.Parser = c.NewParser(bytes.NewReader(b))
+		_, err = decode(d, v)
+		return
+	}
+}
+
+func encodeFieldAsFormat(encode encodeFunc, name string, format string) encodeFunc {
+	return func(e Encoder, v reflect.Value) (err error) {
+		var c Codec
+		var b bytes.Buffer
+
+		if c, err = fieldCodec(name, format); err != nil {
+			return
+		}
+
+		if err = encode(e.options().withEmitter(c.NewEmitter(&b)), v); err != nil {
+			return fmt.Errorf("objconv: %s encoding of field %s failed: %s", format, name, strings.TrimPrefix(err.Error(), "objconv: "))
+		}
+
+		return e.Emitter.EmitBytes(b.Bytes())
+	}
+}
+
+func decodeFieldAsFormat(decode decodeFunc, name string, format string) decodeFunc {
+	return func(d Decoder, v reflect.Value) (t Type, err error) {
+		var c Codec
+		var b []byte
+
+		if t, b, err = d.decodeTypeAndString(); err != nil || t == Nil {
+			return
+		}
+
+		if c, err = fieldCodec(name, format); err != nil {
+			return
+		}
+
+		// The decoder is copied so the value is decoded with the same
+		// options.
+		d.Parser = c.NewParser(bytes.NewReader(b))
+
+		if _, err = decode(d, v); err != nil {
+			err = fmt.Errorf("objconv: %s decoding of field %s failed: %s", format, name, strings.TrimPrefix(err.Error(), "objconv: "))
+		}
+		return
+	}
+}
+
+func fieldCodec(name string, format string) (Codec, error) {
+	c, ok := Lookup(format)
+	if !ok {
+		return c, fmt.Errorf("objconv: field %s uses the %s format but no codec was registered for it", name, format)
+	}
+	return c, nil
+}
+
+func encodeFieldWithTransforms(encode encodeFunc, name string, transforms []string) encodeFunc {
+	return func(e Encoder, v reflect.Value) (err error) {
+		var b []byte
+		var x = NewValueEmitter()
+
+		if err = encode(e.options().withEmitter(x), v); err != nil {
+			return
+		}
+
+		switch value := x.Value().(type) {
+		case string:
+			b = []byte(value)
+		case []byte:
+			b = value
+		default:
+			return e.Emitter.EmitNil()
+		}
+
+		// The transforms are listed in the order they must be applied to
+		// decode the value, so they are applied in reverse when encoding.
+		for i := len(transforms) - 1; i >= 0; i-- {
+			if b, err = encodeTransform(transforms[i], b); err != nil {
+				return fmt.Errorf("objconv: %s encoding of field %s failed: %s", transforms[i], name, err)
+			}
+		}
+
+		if transforms[0] == "base64" {
+			return e.Emitter.EmitString(string(b))
+		}
+
+		return e.Emitter.EmitBytes(b)
+	}
+}
+
+func decodeFieldWithTransforms(decode decodeFunc, name string, transforms []string) decodeFunc {
+	return func(d Decoder, v reflect.Value) (t Type, err error) {
+		var b []byte
+
+		if t, b, err = d.decodeTypeAndString(); err != nil || t == Nil {
+			return
+		}
+
+		for _, transform := range transforms {
+			if b, err = decodeTransform(transform, b); err != nil {
+				err = fmt.Errorf("objconv: %s decoding of field %s failed: %s", transform, name, err)
+				return
+			}
+		}
+
+		d.Parser = NewValueParser(b)
+		_, err = decode(d, v)
+		return
+	}
+}
+
+func encodeTransform(transform string, b []byte) ([]byte, error) {
+	switch transform {
+	case "base64":
+		return []byte(base64.StdEncoding.EncodeToString(b)), nil
+
+	default: // gzip
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+
+		if _, err := w.Write(b); err != nil {
+			return nil, err
+		}
+
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+
+		return buf.Bytes(), nil
+	}
+}
+
+func decodeTransform(transform string, b []byte) ([]byte, error) {
+	switch transform {
+	case "base64":
+		return base64.StdEncoding.DecodeString(string(b))
+
+	default: // gzip
+		r, err := gzip.NewReader(bytes.NewReader(b))
+
+		if err != nil {
+			return nil, err
+		}
+
+		return ioutil.ReadAll(r)
+	}
+}
+
+func isStringOrBytes(t reflect.Type) bool {
+	return t.Kind() == reflect.String || (t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8)
+}
+
+func jsonCodec() (Codec, error) {
+	c, ok := Lookup("application/json")
+	if !ok {
+		return c, errors.New("objconv: fields with the json tag option require the JSON codec to be registered (import github.com/segmentio/objconv/json)")
+	}
+	return c, nil
+}
+
 func (f *structField) omit(v reflect.Value) bool {
 	return (f.omitempty && objutil.IsEmptyValue(v)) || (f.omitzero && objutil.IsZeroValue(v))
 }
 
+// structMethod represents a virtual field of a struct, which is encoded with
+// the value returned by a method, see the method tag option. Virtual fields
+// are ignored when decoding.
+type structMethod struct {
+	// The name of the virtual field.
+	name string
+
+	// The method called to obtain the value of the field, and whether it has
+	// a pointer receiver.
+	method reflect.Method
+	ptr    bool
+
+	// Whether the method returns an error as second value.
+	withError bool
+
+	// Omitempty and omitzero have the same meaning as for struct fields.
+	omitempty bool
+	omitzero  bool
+
+	// The error reported when encoding if the method doesn't exist or has an
+	// unsupported signature.
+	err error
+
+	// cache for the encoder method
+	encode encodeFunc
+}
+
+func makeStructMethod(t reflect.Type, tag objutil.Tag, tagName string, adapters bool, c map[reflect.Type]*structType) structMethod {
+	m := structMethod{
+		name:      tag.Name,
+		omitempty: tag.Omitempty,
+		omitzero:  tag.Omitzero,
+	}
+
+	if len(m.name) == 0 {
+		m.name = tag.Method
+	}
+
+	var ok bool
+
+	if m.method, ok = t.MethodByName(tag.Method); !ok {
+		if m.method, ok = reflect.PtrTo(t).MethodByName(tag.Method); !ok {
+			m.err = fmt.Errorf("objconv: %s has no exported method named %s for virtual field %s", t, tag.Method, m.name)
+			return m
+		}
+		m.ptr = true
+	}
+
+	mt := m.method.Type // the receiver is the first argument
+
+	switch {
+	case mt.NumIn() != 1:
+		m.err = fmt.Errorf("objconv: method %s of %s must take no arguments to be used for virtual field %s", tag.Method, t, m.name)
+
+	case mt.NumOut() == 1:
+
+	case mt.NumOut() == 2 && mt.Out(1) == errorInterface:
+		m.withError = true
+
+	default:
+		m.err = fmt.Errorf("objconv: method %s of %s must return a value, optionally followed by an error, to be used for virtual field %s", tag.Method, t, m.name)
+	}
+
+	if m.err == nil {
+		m.encode = makeEncodeFunc(mt.Out(0), encodeFuncOpts{
+			recurse:  true,
+			adapters: adapters,
+			structs:  c,
+			tag:      tagName,
+		})
+	}
+
+	return m
+}
+
+// call returns the value of the virtual field for the struct value v, panics
+// raised by the method are returned as errors.
+func (m *structMethod) call(v reflect.Value) (r reflect.Value, err error) {
+	if m.err != nil {
+		err = m.err
+		return
+	}
+
+	if m.ptr {
+		if v.CanAddr() {
+			v = v.Addr()
+		} else {
+			p := reflect.New(v.Type())
+			p.Elem().Set(v)
+			v = p
+		}
+	}
+
+	defer func() {
+		if x := recover(); x != nil {
+			err = fmt.Errorf("objconv: method %s called for virtual field %s panicked: %v", m.method.Name, m.name, x)
+		}
+	}()
+
+	out := m.method.Func.Call([]reflect.Value{v})
+
+	if m.withError && !out[1].IsNil() {
+		err = out[1].Interface().(error)
+		return
+	}
+
+	r = out[0]
+	return
+}
+
+func (m *structMethod) omit(v reflect.Value) bool {
+	return (m.omitempty && objutil.IsEmptyValue(v)) || (m.omitzero && objutil.IsZeroValue(v))
+}
+
 // structType is used to represent a Go structure in internal data structures
 // that cache meta information to make field lookups faster and avoid having to
 // use reflection to lookup the same type information over and over again.
 type structType struct {
 	fields       []structField           // the serializable fields of the struct
 	fieldsByName map[string]*structField // cache of fields by name
+	partsByName  map[string]*structField // fields composed from date and time parts
+	mapKey       *structField            // field receiving map keys, see the mapkey tag option
+	positional   []*structField          // fields receiving the leading elements of arrays
+	rest         *structField            // field receiving the elements following the positional ones
+	methods      []structMethod          // virtual fields encoded with the values returned by methods
+	defaults     []*structField          // fields with a default value, see the default tag option
+	containers   []*structField          // fields holding slices or maps, see Decoder.MissingSliceAsEmpty
 }
 
-// newStructType takes a Go type as argument and extract information to make a
-// new structType value.
+// newStructType takes a Go type and the key of the struct tags to read as
+// arguments and extract information to make a new structType value.
 // The type has to be a struct type or a panic will be raised.
-func newStructType(t reflect.Type, c map[reflect.Type]*structType) *structType {
+//
+// The fields of embedded structs are promoted to the struct the way
+// encoding/json does, unless the embedded field has a name set by its tag, in
+// which case it is a regular field.
+func newStructType(t reflect.Type, tag string, adapters bool, c map[reflect.Type]*structType) *structType {
 	if s := c[t]; s != nil {
 		return s
 	}
 
 	n := t.NumField()
 	s := &structType{
-		fields:       make([]structField, 0, n),
 		fieldsByName: make(map[string]*structField),
 	}
 	c[t] = s
 
+	fields := make([]structField, 0, n)
+
 	for i := 0; i != n; i++ {
 		ft := t.Field(i)
+		ftag := objutil.ParseTag(ft.Tag.Get(tag))
+
+		if ft.Name == "_" && len(ftag.Method) != 0 {
+			s.methods = append(s.methods, makeStructMethod(t, ftag, tag, adapters, c))
+			continue
+		}
 
-		if ft.Anonymous || len(ft.PkgPath) != 0 { // anonymous or non-exported
+		if ft.Anonymous && len(ftag.Name) == 0 {
+			if et := embeddedStructType(ft); et != nil {
+				for _, f := range newStructType(et, tag, adapters, c).fields {
+					f.index = append([]int{i}, f.index...)
+					fields = append(fields, f)
+				}
+				continue
+			}
+		}
+
+		if len(ft.PkgPath) != 0 { // non-exported
 			continue
 		}
 
-		sf := makeStructField(ft, c)
+		sf := makeStructField(ft, tag, adapters, c)
+
+		if ftag.MapKey {
+			// The field is usually not serialized and named "-", so the Go
+			// name is used to report errors.
+			mapKey := sf
+			mapKey.name = ft.Name
+			s.mapKey = &mapKey
+		}
+
+		if ftag.Positional || ftag.Rest {
+			f := sf
+			f.name = ft.Name // used to report errors, like the mapkey field
+
+			if ftag.Positional {
+				s.positional = append(s.positional, &f)
+			} else {
+				s.rest = &f
+			}
+		}
 
 		if sf.name == "-" { // skip
 			continue
 		}
 
-		s.fields = append(s.fields, sf)
-		s.fieldsByName[sf.name] = &s.fields[len(s.fields)-1]
+		fields = append(fields, sf)
+	}
+
+	s.fields = dominantFields(fields)
+
+	for i := range s.fields {
+		f := &s.fields[i]
+		s.fieldsByName[f.name] = f
+
+		if f.readonly {
+			// The decoder only needs to recognize the name of the field, its
+			// value is never assigned.
+			continue
+		}
+
+		if f.dflt != nil {
+			s.defaults = append(s.defaults, f)
+		}
+
+		if k := t.FieldByIndex(f.index).Type.Kind(); k == reflect.Slice || k == reflect.Map {
+			s.containers = append(s.containers, f)
+		}
+
+		for _, part := range [...]string{f.dateField, f.timeField} {
+			if len(part) != 0 {
+				if s.partsByName == nil {
+					s.partsByName = make(map[string]*structField)
+				}
+				s.partsByName[part] = f
+			}
+		}
 	}
 
 	return s
 }
 
+// embeddedStructType returns the struct type that the embedded field f holds
+// or points to, or nil if f isn't a struct or a pointer to a struct.
+//
+// Pointers to non-exported struct types are not promoted since the decoder
+// would be unable to allocate them.
+func embeddedStructType(f reflect.StructField) reflect.Type {
+	t := f.Type
+
+	if t.Kind() == reflect.Ptr {
+		if len(f.PkgPath) != 0 {
+			return nil
+		}
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	return t
+}
+
+// dominantFields returns the list of fields without the promoted fields hidden
+// by other fields of the same name, following the rules of encoding/json: the
+// field nested in the fewest embedded structs wins, then the one named by its
+// tag, and when a name remains ambiguous all its promoted fields are
+// discarded. Fields declared directly in the struct are always retained.
+func dominantFields(fields []structField) []structField {
+	byName := make(map[string][]*structField, len(fields))
+
+	for i := range fields {
+		f := &fields[i]
+		byName[f.name] = append(byName[f.name], f)
+	}
+
+	dominant := make([]structField, 0, len(fields))
+
+	for i := range fields {
+		if f := &fields[i]; isDominantField(f, byName[f.name]) {
+			dominant = append(dominant, *f)
+		}
+	}
+
+	return dominant
+}
+
+func isDominantField(f *structField, others []*structField) bool {
+	if len(f.index) == 1 {
+		return true
+	}
+	for _, g := range others {
+		if g == f {
+			continue
+		}
+		if len(g.index) < len(f.index) {
+			return false
+		}
+		if len(g.index) == len(f.index) && (g.tagged || !f.tagged) {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldByIndex returns the field of the struct value v at index, and false if
+// the field is nested in an embedded struct pointer which is nil.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i != 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// fieldByIndexAlloc returns the field of the struct value v at index, the nil
+// embedded struct pointers on the path to the field are allocated.
+func fieldByIndexAlloc(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i != 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// mapKeyFieldOf returns the field of the struct type t, or the struct type that
+// t points to, which receives map keys when decoding maps into slices of t.
+func mapKeyFieldOf(t reflect.Type, tag string) *structField {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	return structCache.lookup(t, tag, false).mapKey
+}
+
+// defaultStructTag is the key of the struct tags read by encoders and decoders
+// when their Tag field is empty.
+const defaultStructTag = "objconv"
+
+// structTagOrDefault returns tag, or defaultStructTag if it is empty.
+func structTagOrDefault(tag string) string {
+	if len(tag) == 0 {
+		return defaultStructTag
+	}
+	return tag
+}
+
+// structTypeKey is the key of structTypeCache, struct types are cached for
+// each key of struct tags they were built from, and separately for encoders
+// and decoders that have adapters set, see Encoder.Adapters.
+type structTypeKey struct {
+	typ      reflect.Type
+	tag      string
+	adapters bool
+}
+
 // structTypeCache is a simple cache for mapping Go types to Struct values.
 type structTypeCache struct {
 	mutex sync.RWMutex
-	store map[reflect.Type]*structType
+	store map[structTypeKey]*structType
 }
 
-// lookup takes a Go type as argument and returns the matching structType value,
-// potentially creating it if it didn't already exist.
+// lookup takes a Go type and the key of the struct tags to read as arguments
+// and returns the matching structType value, potentially creating it if it
+// didn't already exist. When adapters is true the encoder and decoder
+// functions of the fields check the adapters set on encoders and decoders.
 // This method is safe to call from multiple goroutines.
-func (cache *structTypeCache) lookup(t reflect.Type) (s *structType) {
+func (cache *structTypeCache) lookup(t reflect.Type, tag string, adapters bool) (s *structType) {
+	k := structTypeKey{typ: t, tag: structTagOrDefault(tag), adapters: adapters}
+
 	cache.mutex.RLock()
-	s = cache.store[t]
+	s = cache.store[k]
 	cache.mutex.RUnlock()
 
 	if s == nil {
@@ -123,9 +1112,9 @@ func (cache *structTypeCache) lookup(t reflect.Type) (s *structType) {
 		// often, we take the approach of keeping the logic simple and avoid
 		// a more complex synchronization logic required to solve this edge
 		// case.
-		s = newStructType(t, map[reflect.Type]*structType{})
+		s = newStructType(t, k.tag, adapters, map[reflect.Type]*structType{})
 		cache.mutex.Lock()
-		cache.store[t] = s
+		cache.store[k] = s
 		cache.mutex.Unlock()
 	}
 
@@ -135,8 +1124,8 @@ func (cache *structTypeCache) lookup(t reflect.Type) (s *structType) {
 // clear empties the cache.
 func (cache *structTypeCache) clear() {
 	cache.mutex.Lock()
-	for typ := range cache.store {
-		delete(cache.store, typ)
+	for k := range cache.store {
+		delete(cache.store, k)
 	}
 	cache.mutex.Unlock()
 }
@@ -146,6 +1135,6 @@ var (
 	// the objconv functions are called. The performance improvements on iterating
 	// over struct fields are huge, this is a really important optimization:
 	structCache = structTypeCache{
-		store: make(map[reflect.Type]*structType),
+		store: make(map[structTypeKey]*structType),
 	}
 )
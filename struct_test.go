@@ -1,6 +1,7 @@
 package objconv
 
 import (
+	"errors"
 	"reflect"
 	"testing"
 )
@@ -20,6 +21,7 @@ func TestMakeStructField(t *testing.T) {
 			f: structField{
 				index: []int{0},
 				name:  "A",
+				typ:   Int,
 			},
 		},
 
@@ -28,6 +30,7 @@ func TestMakeStructField(t *testing.T) {
 			f: structField{
 				index: []int{0},
 				name:  "a",
+				typ:   Int,
 			},
 		},
 
@@ -36,6 +39,7 @@ func TestMakeStructField(t *testing.T) {
 			f: structField{
 				index: []int{0},
 				name:  "A",
+				typ:   Map,
 			},
 		},
 
@@ -44,13 +48,14 @@ func TestMakeStructField(t *testing.T) {
 			f: structField{
 				index: []int{0},
 				name:  "a",
+				typ:   Map,
 			},
 		},
 	}
 
 	for _, test := range tests {
 		t.Run("", func(t *testing.T) {
-			f := makeStructField(test.s, map[reflect.Type]*structType{})
+			f := makeStructField(test.s, defaultStructTag, false, map[reflect.Type]*structType{})
 			f.decode = nil // function types are not comparable
 			f.encode = nil
 
@@ -60,3 +65,285 @@ func TestMakeStructField(t *testing.T) {
 		})
 	}
 }
+
+func TestStructEmbeddedFields(t *testing.T) {
+	type base struct {
+		ID   int    `objconv:"id"`
+		Name string `objconv:"name"`
+	}
+
+	type Meta struct {
+		Name    string `objconv:"name"`
+		Version int    `objconv:"version"`
+	}
+
+	type Extra struct {
+		Version int `objconv:"version"`
+	}
+
+	type private struct {
+		Hidden string `objconv:"hidden"`
+	}
+
+	type T struct {
+		base
+		*Meta
+		Extra `objconv:"extra"`
+		*private
+		Name string `objconv:"title"`
+	}
+
+	s := structCache.lookup(reflect.TypeOf(T{}), defaultStructTag, false)
+
+	var names []string
+	for _, f := range s.fields {
+		names = append(names, f.name)
+	}
+
+	// "name" is ambiguous between base and Meta, Extra is a regular field
+	// since its tag sets a name, and the pointer to a non-exported struct type
+	// isn't promoted.
+	if !reflect.DeepEqual(names, []string{"id", "version", "extra", "title"}) {
+		t.Errorf("bad fields: %q", names)
+	}
+
+	if f := s.fieldsByName["version"]; !reflect.DeepEqual(f.index, []int{1, 1}) {
+		t.Errorf("bad index: %v", f.index)
+	}
+
+	t.Run("encode", func(t *testing.T) {
+		e := NewValueEmitter()
+
+		if err := NewEncoder(e).Encode(T{base: base{ID: 1}, Name: "A"}); err != nil {
+			t.Fatal(err)
+		}
+
+		// Fields of nil embedded pointers are omitted.
+		if v := e.Value(); !reflect.DeepEqual(v, map[interface{}]interface{}{
+			"id":    int64(1),
+			"extra": map[interface{}]interface{}{"version": int64(0)},
+			"title": "A",
+		}) {
+			t.Errorf("%#v", v)
+		}
+	})
+
+	t.Run("decode", func(t *testing.T) {
+		var v T
+
+		if err := NewDecoder(NewValueParser(map[string]interface{}{
+			"id":      1,
+			"name":    "ignored",
+			"version": 2,
+			"title":   "A",
+		})).Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+
+		if v.ID != 1 || v.Name != "A" || v.base.Name != "" {
+			t.Errorf("%+v", v)
+		}
+
+		if v.Meta == nil || *v.Meta != (Meta{Version: 2}) {
+			t.Errorf("the embedded pointer was not allocated: %+v", v.Meta)
+		}
+	})
+}
+
+func TestStructEmbeddedDepth(t *testing.T) {
+	type Inner struct {
+		A int `objconv:"a"`
+		B int `objconv:"B"`
+	}
+
+	type Middle struct {
+		Inner
+		A string `objconv:"a"`
+	}
+
+	type Tagged struct {
+		B int `objconv:"B"`
+	}
+
+	type Untagged struct {
+		B int
+	}
+
+	type Outer struct {
+		*Middle
+		Tagged
+		Untagged
+	}
+
+	var v Outer
+
+	if err := NewDecoder(NewValueParser(map[string]interface{}{"a": "x", "B": 1})).Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	// Middle.A is shallower than Inner.A, Tagged.B is at the same depth as
+	// Untagged.B which has the same name but isn't tagged, and Inner.B is
+	// deeper.
+	if v.Middle == nil || v.Middle.A != "x" || v.Middle.Inner != (Inner{}) || v.Tagged.B != 1 || v.Untagged.B != 0 {
+		t.Errorf("%+v %+v", v, v.Middle)
+	}
+}
+
+type adaptedValue string
+
+func TestStructFieldAdapterError(t *testing.T) {
+	Install(reflect.TypeOf(adaptedValue("")), Adapter{
+		Encode: func(e Encoder, v reflect.Value) error { return e.Encode(v.String()) },
+		Decode: func(d Decoder, v reflect.Value) error { return errors.New("objconv: bad adapted value") },
+	})
+
+	var v struct {
+		Value adaptedValue `objconv:"value"`
+	}
+
+	err := NewDecoder(NewValueParser(map[string]string{"value": "?"})).Decode(&v)
+
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	if s := err.Error(); s != "objconv: bad value for field value: bad adapted value" {
+		t.Error(s)
+	}
+
+	t.Run("decoder adapters", func(t *testing.T) {
+		errBad := errors.New("bad local value")
+
+		var v struct {
+			Value adaptedLocalValue `objconv:"value"`
+		}
+
+		d := NewDecoder(NewValueParser(map[string]string{"value": "?"}))
+		d.Adapters = map[reflect.Type]Adapter{
+			reflect.TypeOf(adaptedLocalValue("")): {
+				Decode: func(d Decoder, v reflect.Value) error { return errBad },
+			},
+		}
+
+		err := d.Decode(&v)
+
+		if err == nil || err.Error() != "objconv: bad value for field value: bad local value" {
+			t.Error("bad error:", err)
+		}
+
+		if !errors.Is(err, errBad) {
+			t.Error("the error of the adapter isn't wrapped:", err)
+		}
+	})
+
+	t.Run("sentinel errors", func(t *testing.T) {
+		var v struct {
+			Value adaptedLocalValue `objconv:"value"`
+		}
+
+		d := NewDecoder(NewValueParser(map[string]string{"value": "?"}))
+		d.Adapters = map[reflect.Type]Adapter{
+			reflect.TypeOf(adaptedLocalValue("")): {
+				Decode: func(d Decoder, v reflect.Value) error { return ErrMaxAllocBytes },
+			},
+		}
+
+		if err := d.Decode(&v); err != ErrMaxAllocBytes {
+			t.Error("bad error:", err)
+		}
+	})
+}
+
+type adaptedLocalValue string
+
+func TestStructTag(t *testing.T) {
+	type Address struct {
+		City string `json:"city" objconv:"town"`
+	}
+
+	type Account struct {
+		ID      int64    `json:"id,string"`
+		Name    string   `json:"name"`
+		Email   string   `json:"email,omitempty"`
+		Score   *float64 `json:"score,string,omitempty"`
+		Active  bool     `json:"active,string"`
+		Address Address  `json:"address"`
+		Secret  string   `json:"-"`
+	}
+
+	score := 0.5
+	account := Account{
+		ID:      12345678901234,
+		Name:    "Luke",
+		Score:   &score,
+		Active:  true,
+		Address: Address{City: "Tatooine"},
+		Secret:  "hidden",
+	}
+
+	encoded := map[interface{}]interface{}{
+		"id":      "12345678901234",
+		"name":    "Luke",
+		"score":   "0.5",
+		"active":  "true",
+		"address": map[interface{}]interface{}{"city": "Tatooine"},
+	}
+
+	t.Run("encode", func(t *testing.T) {
+		e := NewValueEmitter()
+
+		if err := (Encoder{Emitter: e, Tag: "json"}).Encode(account); err != nil {
+			t.Fatal(err)
+		}
+
+		if v := e.Value(); !reflect.DeepEqual(v, encoded) {
+			t.Errorf("%#v", v)
+		}
+	})
+
+	t.Run("decode", func(t *testing.T) {
+		var v Account
+
+		if err := (Decoder{Parser: NewValueParser(encoded), Tag: "json"}).Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+
+		account.Secret = ""
+
+		if !reflect.DeepEqual(v, account) {
+			t.Errorf("%#v", v)
+		}
+	})
+
+	t.Run("default", func(t *testing.T) {
+		// The struct types built for the json tags must not be reused when
+		// encoding with the default tags.
+		e := NewValueEmitter()
+
+		if err := NewEncoder(e).Encode(Address{City: "Naboo"}); err != nil {
+			t.Fatal(err)
+		}
+
+		if v := e.Value(); !reflect.DeepEqual(v, map[interface{}]interface{}{"town": "Naboo"}) {
+			t.Errorf("%#v", v)
+		}
+	})
+
+	t.Run("string-errors", func(t *testing.T) {
+		var v Account
+
+		tests := []struct {
+			in  map[string]interface{}
+			err string
+		}{
+			{map[string]interface{}{"id": 42}, "objconv: bad value for field id: the string tag option expects a string but found int"},
+			{map[string]interface{}{"id": "4x"}, `objconv: bad value for field id: "4x" is not a valid int64`},
+		}
+
+		for _, test := range tests {
+			if err := (Decoder{Parser: NewValueParser(test.in), Tag: "json"}).Decode(&v); err == nil || err.Error() != test.err {
+				t.Error("bad error:", err)
+			}
+		}
+	})
+}
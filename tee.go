@@ -0,0 +1,302 @@
+package objconv
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// TeeParser returns a parser which forwards all calls to p, and writes a trace
+// of the calls and the values or errors they returned to w, one line per call.
+// It is meant to help understanding why decoding an input fails, by showing
+// the exact sequence of calls that the decoder made.
+//
+// Lines are indented by the nesting level of the arrays and maps being parsed.
+// The values and errors returned by p are passed through unchanged, errors
+// writing to w are ignored.
+//
+// The returned parser supports the same optional features as p (like
+// recording spans of values, capturing raw bytes or returning the remaining
+// bytes of the input).
+func TeeParser(p Parser, w io.Writer) Parser {
+	t := &teeParser{p: p, w: w}
+	_, pos := p.(Positioner)
+	_, raw := p.(rawParser)
+	_, rem := p.(remainderParser)
+
+	switch {
+	case pos && raw && rem:
+		return teeRawRemainderPositioner{t, teePositioner{t}, teeRawParser{t}, teeRemainderParser{t}}
+	case pos && raw:
+		return teeRawPositioner{t, teePositioner{t}, teeRawParser{t}}
+	case pos && rem:
+		return teeRemainderPositioner{t, teePositioner{t}, teeRemainderParser{t}}
+	case raw && rem:
+		return teeRawRemainderParser{t, teeRawParser{t}, teeRemainderParser{t}}
+	case pos:
+		return teePositioner{t}
+	case raw:
+		return teeRawParser{t}
+	case rem:
+		return teeRemainderParser{t}
+	default:
+		return t
+	}
+}
+
+type teeParser struct {
+	p     Parser
+	w     io.Writer
+	depth int
+	b     []byte
+}
+
+func (t *teeParser) ParseType() (v Type, err error) {
+	v, err = t.p.ParseType()
+	t.trace("ParseType", -1, v, err)
+	return
+}
+
+func (t *teeParser) ParseNil() (err error) {
+	err = t.p.ParseNil()
+	t.trace("ParseNil", -1, nil, err)
+	return
+}
+
+func (t *teeParser) ParseBool() (v bool, err error) {
+	v, err = t.p.ParseBool()
+	t.trace("ParseBool", -1, v, err)
+	return
+}
+
+func (t *teeParser) ParseInt() (v int64, err error) {
+	v, err = t.p.ParseInt()
+	t.trace("ParseInt", -1, v, err)
+	return
+}
+
+func (t *teeParser) ParseUint() (v uint64, err error) {
+	v, err = t.p.ParseUint()
+	t.trace("ParseUint", -1, v, err)
+	return
+}
+
+func (t *teeParser) ParseFloat() (v float64, err error) {
+	v, err = t.p.ParseFloat()
+	t.trace("ParseFloat", -1, v, err)
+	return
+}
+
+func (t *teeParser) ParseString() (v []byte, err error) {
+	v, err = t.p.ParseString()
+	t.trace("ParseString", -1, v, err)
+	return
+}
+
+func (t *teeParser) ParseBytes() (v []byte, err error) {
+	v, err = t.p.ParseBytes()
+	t.trace("ParseBytes", -1, v, err)
+	return
+}
+
+func (t *teeParser) ParseTime() (v time.Time, err error) {
+	v, err = t.p.ParseTime()
+	t.trace("ParseTime", -1, v, err)
+	return
+}
+
+func (t *teeParser) ParseDuration() (v time.Duration, err error) {
+	v, err = t.p.ParseDuration()
+	t.trace("ParseDuration", -1, v, err)
+	return
+}
+
+func (t *teeParser) ParseError() (v error, err error) {
+	v, err = t.p.ParseError()
+	t.trace("ParseError", -1, v, err)
+	return
+}
+
+func (t *teeParser) ParseArrayBegin() (n int, err error) {
+	n, err = t.p.ParseArrayBegin()
+	t.trace("ParseArrayBegin", -1, n, err)
+	if err == nil {
+		t.depth++
+	}
+	return
+}
+
+func (t *teeParser) ParseArrayEnd(n int) (err error) {
+	t.depth--
+	err = t.p.ParseArrayEnd(n)
+	t.trace("ParseArrayEnd", n, nil, err)
+	return
+}
+
+func (t *teeParser) ParseArrayNext(n int) (err error) {
+	err = t.p.ParseArrayNext(n)
+	t.trace("ParseArrayNext", n, nil, err)
+	return
+}
+
+func (t *teeParser) ParseMapBegin() (n int, err error) {
+	n, err = t.p.ParseMapBegin()
+	t.trace("ParseMapBegin", -1, n, err)
+	if err == nil {
+		t.depth++
+	}
+	return
+}
+
+func (t *teeParser) ParseMapEnd(n int) (err error) {
+	t.depth--
+	err = t.p.ParseMapEnd(n)
+	t.trace("ParseMapEnd", n, nil, err)
+	return
+}
+
+func (t *teeParser) ParseMapValue(n int) (err error) {
+	err = t.p.ParseMapValue(n)
+	t.trace("ParseMapValue", n, nil, err)
+	return
+}
+
+func (t *teeParser) ParseMapNext(n int) (err error) {
+	err = t.p.ParseMapNext(n)
+	t.trace("ParseMapNext", n, nil, err)
+	return
+}
+
+// DecodeBytes forwards the call to the underlying parser if it transforms byte
+// slices, b is returned unchanged otherwise, which is what decoders do with
+// parsers that don't implement the method.
+func (t *teeParser) DecodeBytes(b []byte) (v []byte, err error) {
+	bd, ok := t.p.(bytesDecoder)
+	if !ok {
+		return b, nil
+	}
+	v, err = bd.DecodeBytes(b)
+	t.trace("DecodeBytes", -1, v, err)
+	return
+}
+
+// ParseNumber forwards the call to the underlying parser if it exposes the
+// literal form of numbers, otherwise the number is parsed with ParseInt,
+// ParseUint or ParseFloat and formatted, which is what decoders do with
+// parsers that don't implement the method.
+func (t *teeParser) ParseNumber() (v []byte, err error) {
+	if np, ok := t.p.(numberParser); ok {
+		v, err = np.ParseNumber()
+		t.trace("ParseNumber", -1, v, err)
+		return
+	}
+
+	var typ Type
+
+	if typ, err = t.p.ParseType(); err != nil {
+		return
+	}
+
+	switch typ {
+	case Int:
+		var i int64
+		if i, err = t.ParseInt(); err == nil {
+			v = strconv.AppendInt(nil, i, 10)
+		}
+	case Uint:
+		var u uint64
+		if u, err = t.ParseUint(); err == nil {
+			v = strconv.AppendUint(nil, u, 10)
+		}
+	default:
+		var f float64
+		if f, err = t.ParseFloat(); err == nil {
+			v = strconv.AppendFloat(nil, f, 'g', -1, 64)
+		}
+	}
+
+	return
+}
+
+func (t *teeParser) trace(call string, n int, v interface{}, err error) {
+	b := t.b[:0]
+
+	for i := 0; i != t.depth; i++ {
+		b = append(b, "  "...)
+	}
+
+	b = append(b, call...)
+
+	if n >= 0 {
+		b = append(b, '(')
+		b = strconv.AppendInt(b, int64(n), 10)
+		b = append(b, ')')
+	}
+
+	switch {
+	case err == End:
+		b = append(b, ": end"...)
+	case err != nil:
+		b = append(b, ": error: "...)
+		b = append(b, err.Error()...)
+	case v != nil:
+		b = append(b, ": "...)
+		switch x := v.(type) {
+		case []byte:
+			b = strconv.AppendQuote(b, string(x))
+		case time.Time:
+			b = x.AppendFormat(b, time.RFC3339Nano)
+		default:
+			b = append(b, fmt.Sprint(x)...)
+		}
+	}
+
+	b = append(b, '\n')
+	t.b = b
+	t.w.Write(b)
+}
+
+// The optional methods of the underlying parser are exposed by the following
+// types, which are combined to only implement the interfaces that it does.
+
+type teePositioner struct{ *teeParser }
+
+func (t teePositioner) Offset() int { return t.p.(Positioner).Offset() }
+
+type teeRawParser struct{ *teeParser }
+
+func (t teeRawParser) BeginRaw() { t.p.(rawParser).BeginRaw() }
+
+func (t teeRawParser) EndRaw() []byte { return t.p.(rawParser).EndRaw() }
+
+type teeRemainderParser struct{ *teeParser }
+
+func (t teeRemainderParser) InMemory() bool { return t.p.(remainderParser).InMemory() }
+
+func (t teeRemainderParser) Remaining() ([]byte, error) { return t.p.(remainderParser).Remaining() }
+
+type teeRawPositioner struct {
+	*teeParser
+	teePositioner
+	teeRawParser
+}
+
+type teeRemainderPositioner struct {
+	*teeParser
+	teePositioner
+	teeRemainderParser
+}
+
+type teeRawRemainderParser struct {
+	*teeParser
+	teeRawParser
+	teeRemainderParser
+}
+
+type teeRawRemainderPositioner struct {
+	*teeParser
+	teePositioner
+	teeRawParser
+	teeRemainderParser
+}
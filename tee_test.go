@@ -0,0 +1,133 @@
+package objconv
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTeeParser(t *testing.T) {
+	var v struct {
+		A []interface{} `objconv:"a"`
+	}
+
+	b := &strings.Builder{}
+	p := TeeParser(NewValueParser(map[string]interface{}{"a": []interface{}{1, "x", nil}}), b)
+
+	if err := NewDecoder(p).Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(v.A, []interface{}{int64(1), "x", nil}) {
+		t.Errorf("%#v", v.A)
+	}
+
+	if s := b.String(); s != `ParseType: map
+ParseMapBegin: 1
+  ParseType: string
+  ParseString: "a"
+  ParseMapValue(0)
+  ParseType: array
+  ParseArrayBegin: 3
+    ParseType: int
+    ParseInt: 1
+    ParseArrayNext(1)
+    ParseType: string
+    ParseString: "x"
+    ParseArrayNext(2)
+    ParseType: nil
+    ParseNil
+  ParseArrayEnd(3)
+ParseMapEnd(1)
+` {
+		t.Error(s)
+	}
+}
+
+func TestTeeParserError(t *testing.T) {
+	var v int
+
+	b := &strings.Builder{}
+	err1 := NewDecoder(TeeParser(NewValueParser("hello"), b)).Decode(&v)
+	err2 := NewDecoder(NewValueParser("hello")).Decode(&v)
+
+	if err1 == nil || err2 == nil || err1.Error() != err2.Error() {
+		t.Errorf("the errors differ: %v != %v", err1, err2)
+	}
+
+	if s := b.String(); s != "ParseType: string\n" {
+		t.Error(s)
+	}
+}
+
+type positionParser struct {
+	*ValueParser
+}
+
+func (p positionParser) Offset() int { return 0 }
+
+// remainderValueParser is a parser which reports rest as the bytes remaining
+// after the decoded value.
+type remainderValueParser struct {
+	*ValueParser
+	rest []byte
+}
+
+func (p remainderValueParser) InMemory() bool { return true }
+
+func (p remainderValueParser) Remaining() ([]byte, error) { return p.rest, nil }
+
+type remainderPositionParser struct {
+	remainderValueParser
+}
+
+func (p remainderPositionParser) Offset() int { return 0 }
+
+func TestTeeParserInterfaces(t *testing.T) {
+	tests := []struct {
+		p   Parser
+		pos bool
+		rem bool
+	}{
+		{p: NewValueParser(nil)},
+		{p: positionParser{NewValueParser(nil)}, pos: true},
+		{p: remainderValueParser{ValueParser: NewValueParser(nil)}, rem: true},
+		{p: remainderPositionParser{remainderValueParser{ValueParser: NewValueParser(nil)}}, pos: true, rem: true},
+	}
+
+	for _, test := range tests {
+		p := TeeParser(test.p, nil)
+
+		if _, ok := p.(Positioner); ok != test.pos {
+			t.Errorf("%T: the tee parser must implement Positioner only when the underlying parser does", test.p)
+		}
+
+		if _, ok := p.(remainderParser); ok != test.rem {
+			t.Errorf("%T: the tee parser must implement remainderParser only when the underlying parser does", test.p)
+		}
+	}
+}
+
+func TestTeeParserDecodeRemaining(t *testing.T) {
+	var v int
+
+	b := &strings.Builder{}
+	p := TeeParser(remainderValueParser{ValueParser: NewValueParser(42), rest: []byte("trailer")}, b)
+
+	rest, err := NewDecoder(p).DecodeRemaining(&v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v != 42 {
+		t.Error("bad value:", v)
+	}
+
+	if string(rest) != "trailer" {
+		t.Errorf("bad remaining bytes: %q", rest)
+	}
+
+	if s := b.String(); s != "ParseType: int\nParseInt: 42\n" {
+		t.Errorf("bad trace: %q", s)
+	}
+}
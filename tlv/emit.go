@@ -0,0 +1,155 @@
+package tlv
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Emitter implements a TLV emitter that satisfies the objconv.Emitter
+// interface.
+type Emitter struct {
+	// LengthPrefix configures the encoding of the length of strings, byte
+	// slices, times and errors. The parser must be configured with the same
+	// value to read them back.
+	LengthPrefix LengthPrefix
+
+	w io.Writer
+	b []byte
+
+	// stack records whether the arrays and maps being emitted have an unknown
+	// length and must be terminated by an End tag.
+	stack []bool
+}
+
+// NewEmitter returns a new emitter that writes to w, using varints to encode
+// the length of strings.
+func NewEmitter(w io.Writer) *Emitter {
+	return &Emitter{w: w, b: make([]byte, 0, 64)}
+}
+
+func (e *Emitter) Reset(w io.Writer) {
+	e.w = w
+	e.stack = e.stack[:0]
+}
+
+func (e *Emitter) EmitNil() error {
+	return e.write(append(e.b[:0], Nil))
+}
+
+func (e *Emitter) EmitBool(v bool) error {
+	if v {
+		return e.write(append(e.b[:0], True))
+	}
+	return e.write(append(e.b[:0], False))
+}
+
+func (e *Emitter) EmitInt(v int64, _ int) error {
+	return e.write(binary.AppendVarint(append(e.b[:0], Int), v))
+}
+
+func (e *Emitter) EmitUint(v uint64, _ int) error {
+	return e.write(binary.AppendUvarint(append(e.b[:0], Uint), v))
+}
+
+func (e *Emitter) EmitFloat(v float64, _ int) error {
+	return e.write(binary.BigEndian.AppendUint64(append(e.b[:0], Float), math.Float64bits(v)))
+}
+
+func (e *Emitter) EmitString(v string) error {
+	return e.emitString(String, v)
+}
+
+func (e *Emitter) EmitBytes(v []byte) error {
+	return e.emitString(Bytes, string(v))
+}
+
+func (e *Emitter) EmitTime(v time.Time) error {
+	return e.emitString(Time, v.Format(time.RFC3339Nano))
+}
+
+func (e *Emitter) EmitDuration(v time.Duration) error {
+	return e.write(binary.AppendVarint(append(e.b[:0], Duration), int64(v)))
+}
+
+func (e *Emitter) EmitError(v error) error {
+	return e.emitString(Error, v.Error())
+}
+
+func (e *Emitter) EmitArrayBegin(n int) error {
+	return e.emitBegin(Array, ArrayStream, n)
+}
+
+func (e *Emitter) EmitArrayEnd() error {
+	return e.emitEnd()
+}
+
+func (e *Emitter) EmitArrayNext() error {
+	return nil
+}
+
+func (e *Emitter) EmitMapBegin(n int) error {
+	return e.emitBegin(Map, MapStream, n)
+}
+
+func (e *Emitter) EmitMapEnd() error {
+	return e.emitEnd()
+}
+
+func (e *Emitter) EmitMapValue() error {
+	return nil
+}
+
+func (e *Emitter) EmitMapNext() error {
+	return nil
+}
+
+func (e *Emitter) emitString(tag byte, v string) error {
+	n := uint64(len(v))
+	b := append(e.b[:0], tag)
+
+	if max := e.LengthPrefix.max(); n > max {
+		return fmt.Errorf("objconv/tlv: value of length %d exceeds the maximum length of %d for a %s length prefix", n, max, e.LengthPrefix)
+	}
+
+	switch e.LengthPrefix {
+	case Uint16:
+		b = binary.BigEndian.AppendUint16(b, uint16(n))
+	case Uint32:
+		b = binary.BigEndian.AppendUint32(b, uint32(n))
+	default:
+		b = binary.AppendUvarint(b, n)
+	}
+
+	return e.write(append(b, v...))
+}
+
+func (e *Emitter) emitBegin(tag byte, stream byte, n int) error {
+	e.stack = append(e.stack, n < 0)
+
+	if n < 0 {
+		return e.write(append(e.b[:0], stream))
+	}
+
+	return e.write(binary.AppendUvarint(append(e.b[:0], tag), uint64(n)))
+}
+
+func (e *Emitter) emitEnd() error {
+	i := len(e.stack) - 1
+	stream := e.stack[i]
+	e.stack = e.stack[:i]
+
+	if stream {
+		return e.write(append(e.b[:0], End))
+	}
+
+	return nil
+}
+
+func (e *Emitter) write(b []byte) (err error) {
+	_, err = e.w.Write(b)
+	e.b = b[:0]
+	return
+}
@@ -0,0 +1,36 @@
+package tlv
+
+import (
+	"io"
+
+	"github.com/segmentio/objconv"
+)
+
+// Codec for the TLV format, using varints to encode the length of strings.
+var Codec = NewCodec(Varint)
+
+// NewCodec returns a codec for the TLV format where the length of strings is
+// encoded with prefix.
+func NewCodec(prefix LengthPrefix) objconv.Codec {
+	return objconv.Codec{
+		NewEmitter: func(w io.Writer) objconv.Emitter {
+			e := NewEmitter(w)
+			e.LengthPrefix = prefix
+			return e
+		},
+		NewParser: func(r io.Reader) objconv.Parser {
+			p := NewParser(r)
+			p.LengthPrefix = prefix
+			return p
+		},
+	}
+}
+
+func init() {
+	for _, name := range [...]string{
+		"application/tlv",
+		"tlv",
+	} {
+		objconv.Register(name, Codec)
+	}
+}
@@ -0,0 +1,280 @@
+package tlv
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/segmentio/objconv"
+	"github.com/segmentio/objconv/objutil"
+)
+
+// Parser implements a TLV parser that satisfies the objconv.Parser interface.
+type Parser struct {
+	// LengthPrefix configures the encoding of the length of strings, byte
+	// slices, times and errors, it must match the configuration of the
+	// emitter that produced the input.
+	LengthPrefix LengthPrefix
+
+	r *bufio.Reader
+	s []byte // string buffer
+
+	// stack records whether the arrays and maps being parsed have an unknown
+	// length and are terminated by an End tag.
+	stack []bool
+}
+
+// NewParser returns a new parser that reads from r, expecting the length of
+// strings to be encoded as varints.
+func NewParser(r io.Reader) *Parser {
+	return &Parser{r: bufio.NewReader(r)}
+}
+
+func (p *Parser) Reset(r io.Reader) {
+	p.r.Reset(r)
+	p.stack = p.stack[:0]
+}
+
+func (p *Parser) ParseType() (objconv.Type, error) {
+	b, err := p.r.Peek(1)
+
+	if err != nil {
+		return objconv.Unknown, err
+	}
+
+	switch tag := b[0]; tag {
+	case Nil:
+		return objconv.Nil, nil
+	case False, True:
+		return objconv.Bool, nil
+	case Int:
+		return objconv.Int, nil
+	case Uint:
+		return objconv.Uint, nil
+	case Float:
+		return objconv.Float, nil
+	case String:
+		return objconv.String, nil
+	case Bytes:
+		return objconv.Bytes, nil
+	case Time:
+		return objconv.Time, nil
+	case Duration:
+		return objconv.Duration, nil
+	case Error:
+		return objconv.Error, nil
+	case Array, ArrayStream:
+		return objconv.Array, nil
+	case Map, MapStream:
+		return objconv.Map, nil
+	default:
+		return objconv.Unknown, fmt.Errorf("objconv/tlv: invalid tag: %#02x", tag)
+	}
+}
+
+func (p *Parser) ParseNil() error {
+	_, err := p.readTag()
+	return err
+}
+
+func (p *Parser) ParseBool() (bool, error) {
+	tag, err := p.readTag()
+	return tag == True, err
+}
+
+func (p *Parser) ParseInt() (v int64, err error) {
+	if _, err = p.readTag(); err == nil {
+		v, err = binary.ReadVarint(p.r)
+	}
+	return v, p.unexpectedEOF(err)
+}
+
+func (p *Parser) ParseUint() (v uint64, err error) {
+	if _, err = p.readTag(); err == nil {
+		v, err = binary.ReadUvarint(p.r)
+	}
+	return v, p.unexpectedEOF(err)
+}
+
+func (p *Parser) ParseFloat() (v float64, err error) {
+	var b [8]byte
+
+	if _, err = p.readTag(); err == nil {
+		if _, err = io.ReadFull(p.r, b[:]); err == nil {
+			v = math.Float64frombits(binary.BigEndian.Uint64(b[:]))
+		}
+	}
+
+	return v, p.unexpectedEOF(err)
+}
+
+func (p *Parser) ParseString() ([]byte, error) {
+	return p.readString()
+}
+
+func (p *Parser) ParseBytes() ([]byte, error) {
+	return p.readString()
+}
+
+func (p *Parser) ParseTime() (v time.Time, err error) {
+	var b []byte
+
+	if b, err = p.readString(); err == nil {
+		v, err = time.Parse(time.RFC3339Nano, string(b))
+	}
+
+	return
+}
+
+func (p *Parser) ParseDuration() (v time.Duration, err error) {
+	var d int64
+
+	if _, err = p.readTag(); err == nil {
+		d, err = binary.ReadVarint(p.r)
+	}
+
+	return time.Duration(d), p.unexpectedEOF(err)
+}
+
+func (p *Parser) ParseError() (v error, err error) {
+	var b []byte
+
+	if b, err = p.readString(); err == nil {
+		v = errors.New(string(b))
+	}
+
+	return
+}
+
+func (p *Parser) ParseArrayBegin() (int, error) {
+	return p.parseBegin(ArrayStream)
+}
+
+func (p *Parser) ParseArrayEnd(n int) error {
+	p.stack = p.stack[:len(p.stack)-1]
+	return nil
+}
+
+func (p *Parser) ParseArrayNext(n int) error {
+	return p.parseNext()
+}
+
+func (p *Parser) ParseMapBegin() (int, error) {
+	return p.parseBegin(MapStream)
+}
+
+func (p *Parser) ParseMapEnd(n int) error {
+	p.stack = p.stack[:len(p.stack)-1]
+	return nil
+}
+
+func (p *Parser) ParseMapValue(n int) error {
+	return nil
+}
+
+func (p *Parser) ParseMapNext(n int) error {
+	return p.parseNext()
+}
+
+func (p *Parser) parseBegin(stream byte) (n int, err error) {
+	var tag byte
+	var u uint64
+
+	if tag, err = p.readTag(); err != nil {
+		return
+	}
+
+	if tag == stream {
+		p.stack = append(p.stack, true)
+		return -1, nil
+	}
+
+	if u, err = binary.ReadUvarint(p.r); err != nil {
+		err = p.unexpectedEOF(err)
+		return
+	}
+
+	if u > math.MaxInt32 {
+		err = fmt.Errorf("objconv/tlv: invalid length of array or map: %d", u)
+		return
+	}
+
+	p.stack = append(p.stack, false)
+	return int(u), nil
+}
+
+func (p *Parser) parseNext() error {
+	if !p.stack[len(p.stack)-1] {
+		return nil
+	}
+
+	b, err := p.r.Peek(1)
+
+	if err != nil {
+		return p.unexpectedEOF(err)
+	}
+
+	if b[0] == End {
+		p.r.ReadByte()
+		return objconv.End
+	}
+
+	return nil
+}
+
+func (p *Parser) readTag() (byte, error) {
+	return p.r.ReadByte()
+}
+
+func (p *Parser) readString() (b []byte, err error) {
+	var n uint64
+
+	if _, err = p.readTag(); err != nil {
+		return
+	}
+
+	switch p.LengthPrefix {
+	case Uint16:
+		var x [2]byte
+		if _, err = io.ReadFull(p.r, x[:]); err == nil {
+			n = uint64(binary.BigEndian.Uint16(x[:]))
+		}
+
+	case Uint32:
+		var x [4]byte
+		if _, err = io.ReadFull(p.r, x[:]); err == nil {
+			n = uint64(binary.BigEndian.Uint32(x[:]))
+		}
+
+	default:
+		n, err = binary.ReadUvarint(p.r)
+	}
+
+	if err != nil {
+		err = p.unexpectedEOF(err)
+		return
+	}
+
+	if n > math.MaxInt32 {
+		err = fmt.Errorf("objconv/tlv: invalid length of string: %d", n)
+		return
+	}
+
+	// The buffer grows as bytes are read instead of being allocated from the
+	// length found in the input.
+	p.s, err = objutil.AppendFull(p.s[:0], p.r, int(n))
+	b = p.s
+	err = p.unexpectedEOF(err)
+	return
+}
+
+func (p *Parser) unexpectedEOF(err error) error {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
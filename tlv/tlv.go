@@ -0,0 +1,71 @@
+// Package tlv implements a compact tag-length-value binary format.
+//
+// Each value starts with a one byte tag identifying its type, followed by its
+// length when the type has a variable size, and the value itself. Integers and
+// the lengths of arrays and maps are encoded as varints, while the width of the
+// length prefix of strings, byte slices, times and errors is configurable to
+// interoperate with readers expecting fixed-width lengths, see LengthPrefix.
+package tlv
+
+import "fmt"
+
+// Tags of the values in the TLV format.
+const (
+	Nil         byte = 0x00
+	False       byte = 0x01
+	True        byte = 0x02
+	Int         byte = 0x03 // zigzag varint
+	Uint        byte = 0x04 // varint
+	Float       byte = 0x05 // 64 bits big-endian IEEE 754
+	String      byte = 0x06 // length-prefixed
+	Bytes       byte = 0x07 // length-prefixed
+	Time        byte = 0x08 // length-prefixed RFC 3339 string
+	Duration    byte = 0x09 // zigzag varint of nanoseconds
+	Error       byte = 0x0A // length-prefixed
+	Array       byte = 0x0B // varint number of elements
+	Map         byte = 0x0C // varint number of entries
+	ArrayStream byte = 0x0D // array of unknown length, terminated by End
+	MapStream   byte = 0x0E // map of unknown length, terminated by End
+	End         byte = 0x0F
+)
+
+// LengthPrefix represents the encoding of the length of strings, byte slices,
+// times and errors.
+type LengthPrefix int
+
+const (
+	// Varint prefixes lengths with an unsigned varint, this is the default.
+	Varint LengthPrefix = iota
+
+	// Uint16 prefixes lengths with a 16 bits big-endian unsigned integer.
+	Uint16
+
+	// Uint32 prefixes lengths with a 32 bits big-endian unsigned integer.
+	Uint32
+)
+
+// String satisfies the fmt.Stringer interface.
+func (p LengthPrefix) String() string {
+	switch p {
+	case Varint:
+		return "varint"
+	case Uint16:
+		return "uint16"
+	case Uint32:
+		return "uint32"
+	default:
+		return fmt.Sprintf("LengthPrefix(%d)", int(p))
+	}
+}
+
+// max returns the maximum length that can be encoded with the prefix.
+func (p LengthPrefix) max() uint64 {
+	switch p {
+	case Uint16:
+		return 1<<16 - 1
+	case Uint32:
+		return 1<<32 - 1
+	default:
+		return 1<<63 - 1
+	}
+}
@@ -0,0 +1,114 @@
+package tlv
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/segmentio/objconv"
+	"github.com/segmentio/objconv/objtests"
+)
+
+func TestCodec(t *testing.T) {
+	objtests.TestCodec(t, Codec)
+}
+
+func TestCodecUint32(t *testing.T) {
+	objtests.TestCodec(t, NewCodec(Uint32))
+}
+
+func BenchmarkCodec(b *testing.B) {
+	objtests.BenchmarkCodec(b, Codec)
+}
+
+func TestLengthPrefix(t *testing.T) {
+	tests := []struct {
+		prefix LengthPrefix
+		s      string
+	}{
+		{Varint, "0603616263"},
+		{Uint16, "060003616263"},
+		{Uint32, "0600000003616263"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.prefix.String(), func(t *testing.T) {
+			b := &bytes.Buffer{}
+			e := NewEmitter(b)
+			e.LengthPrefix = test.prefix
+
+			if err := objconv.NewEncoder(e).Encode("abc"); err != nil {
+				t.Fatal(err)
+			}
+
+			if s := hex.EncodeToString(b.Bytes()); s != test.s {
+				t.Error("bad encoding:", s)
+			}
+
+			var v string
+			p := NewParser(b)
+			p.LengthPrefix = test.prefix
+
+			if err := objconv.NewDecoder(p).Decode(&v); err != nil {
+				t.Fatal(err)
+			}
+
+			if v != "abc" {
+				t.Error("bad decoding:", v)
+			}
+		})
+	}
+}
+
+func TestLengthPrefixOverflow(t *testing.T) {
+	e := NewEmitter(&bytes.Buffer{})
+	e.LengthPrefix = Uint16
+
+	if err := objconv.NewEncoder(e).Encode(strings.Repeat("x", 1<<16)); err == nil {
+		t.Error("expected an error encoding a string too long for a uint16 length prefix")
+	}
+
+	e.LengthPrefix = Uint32
+
+	if err := objconv.NewEncoder(e).Encode(strings.Repeat("x", 1<<16)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParserTruncatedLargeLength(t *testing.T) {
+	// The string declares a length of 2 GiB but the input ends right after,
+	// the parser must report the truncation without allocating it.
+	tests := []struct {
+		prefix LengthPrefix
+		s      string
+	}{
+		{Varint, "06ffffffff07"},
+		{Uint32, "067fffffff"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.prefix.String(), func(t *testing.T) {
+			b, _ := hex.DecodeString(test.s)
+			p := NewParser(bytes.NewReader(b))
+			p.LengthPrefix = test.prefix
+
+			var v string
+			var m1, m2 runtime.MemStats
+
+			runtime.ReadMemStats(&m1)
+			err := objconv.NewDecoder(p).Decode(&v)
+			runtime.ReadMemStats(&m2)
+
+			if err != io.ErrUnexpectedEOF {
+				t.Error("expected io.ErrUnexpectedEOF but got", err)
+			}
+
+			if n := m2.TotalAlloc - m1.TotalAlloc; n > 1<<20 {
+				t.Error("too many bytes allocated:", n)
+			}
+		})
+	}
+}
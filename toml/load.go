@@ -0,0 +1,868 @@
+package toml
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// table is the representation of TOML tables built by the loader, keys are
+// kept in the order they appear in the document.
+type table struct {
+	keys   []string
+	values map[string]interface{}
+	kind   tableKind
+}
+
+type tableKind int
+
+const (
+	implicitTable tableKind = iota // created as the parent of another table
+	explicitTable                  // defined by a [table] header
+	dottedTable                    // created by a dotted key
+	inlineTable                    // defined by an inline table, can't be extended
+)
+
+func newTable(kind tableKind) *table {
+	return &table{values: make(map[string]interface{}), kind: kind}
+}
+
+func (t *table) get(k string) (v interface{}, ok bool) {
+	v, ok = t.values[k]
+	return
+}
+
+func (t *table) set(k string, v interface{}) {
+	t.keys = append(t.keys, k)
+	t.values[k] = v
+}
+
+// tableArray is the representation of arrays of tables, defined by [[table]]
+// headers.
+type tableArray struct {
+	tables []*table
+}
+
+// loader builds the tree of values of a TOML document.
+type loader struct {
+	b    []byte
+	i    int
+	line int
+
+	location      *time.Location
+	localAsString bool
+}
+
+func (l *loader) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("objconv/toml: line %d: %s", l.line, fmt.Sprintf(format, args...))
+}
+
+func (l *loader) load() (root *table, err error) {
+	root = newTable(explicitTable)
+	current := root
+
+	for {
+		l.skipBlank()
+
+		if l.eof() {
+			return
+		}
+
+		switch {
+		case l.hasPrefix("[["):
+			l.i += 2
+			current, err = l.loadArrayTableHeader(root)
+
+		case l.hasPrefix("["):
+			l.i++
+			current, err = l.loadTableHeader(root)
+
+		default:
+			err = l.loadKeyValue(current)
+		}
+
+		if err == nil {
+			err = l.endOfLine()
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (l *loader) loadTableHeader(root *table) (*table, error) {
+	keys, err := l.parseKey()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = l.expect("]"); err != nil {
+		return nil, err
+	}
+
+	t, err := l.descend(root, keys[:len(keys)-1], implicitTable)
+	if err != nil {
+		return nil, err
+	}
+
+	k := keys[len(keys)-1]
+
+	switch v, ok := t.get(k); x := v.(type) {
+	case *table:
+		if x.kind != implicitTable {
+			return nil, l.errorf("table %s defined more than once", strings.Join(keys, "."))
+		}
+		x.kind = explicitTable
+		return x, nil
+
+	default:
+		if ok {
+			return nil, l.errorf("key %s is already defined", strings.Join(keys, "."))
+		}
+		n := newTable(explicitTable)
+		t.set(k, n)
+		return n, nil
+	}
+}
+
+func (l *loader) loadArrayTableHeader(root *table) (*table, error) {
+	keys, err := l.parseKey()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = l.expect("]]"); err != nil {
+		return nil, err
+	}
+
+	t, err := l.descend(root, keys[:len(keys)-1], implicitTable)
+	if err != nil {
+		return nil, err
+	}
+
+	k := keys[len(keys)-1]
+	x := newTable(explicitTable)
+
+	switch v, ok := t.get(k); a := v.(type) {
+	case *tableArray:
+		a.tables = append(a.tables, x)
+
+	default:
+		if ok {
+			return nil, l.errorf("key %s is already defined and is not an array of tables", strings.Join(keys, "."))
+		}
+		t.set(k, &tableArray{tables: []*table{x}})
+	}
+
+	return x, nil
+}
+
+func (l *loader) loadKeyValue(t *table) error {
+	keys, err := l.parseKey()
+	if err != nil {
+		return err
+	}
+
+	if err = l.expect("="); err != nil {
+		return err
+	}
+
+	l.skipSpace()
+	v, err := l.parseValue()
+	if err != nil {
+		return err
+	}
+
+	if t, err = l.descend(t, keys[:len(keys)-1], dottedTable); err != nil {
+		return err
+	}
+
+	k := keys[len(keys)-1]
+
+	if _, ok := t.get(k); ok {
+		return l.errorf("key %s is already defined", strings.Join(keys, "."))
+	}
+
+	t.set(k, v)
+	return nil
+}
+
+// descend walks the tables at keys starting from t, creating the missing ones
+// with the given kind. The last table of arrays of tables is selected.
+//
+// Dotted keys can only extend the tables that were created by other dotted
+// keys, tables defined by headers must not be reopened this way.
+func (l *loader) descend(t *table, keys []string, kind tableKind) (*table, error) {
+	for i, k := range keys {
+		switch v, ok := t.get(k); x := v.(type) {
+		case *table:
+			if x.kind == inlineTable {
+				return nil, l.errorf("inline table %s cannot be extended", strings.Join(keys[:i+1], "."))
+			}
+			if kind == dottedTable && x.kind != dottedTable {
+				return nil, l.errorf("table %s defined more than once", strings.Join(keys[:i+1], "."))
+			}
+			t = x
+
+		case *tableArray:
+			if kind == dottedTable {
+				return nil, l.errorf("array of tables %s cannot be extended with dotted keys", strings.Join(keys[:i+1], "."))
+			}
+			t = x.tables[len(x.tables)-1]
+
+		default:
+			if ok {
+				return nil, l.errorf("key %s is already defined and is not a table", strings.Join(keys[:i+1], "."))
+			}
+			n := newTable(kind)
+			t.set(k, n)
+			t = n
+		}
+	}
+	return t, nil
+}
+
+func (l *loader) parseKey() (keys []string, err error) {
+	for {
+		var k string
+		l.skipSpace()
+
+		if k, err = l.parseSimpleKey(); err != nil {
+			return
+		}
+
+		keys = append(keys, k)
+		l.skipSpace()
+
+		if !l.hasPrefix(".") {
+			return
+		}
+
+		l.i++
+	}
+}
+
+func (l *loader) parseSimpleKey() (string, error) {
+	switch {
+	case l.hasPrefix(`"`):
+		return l.parseBasicString()
+
+	case l.hasPrefix("'"):
+		return l.parseLiteralString()
+	}
+
+	i := l.i
+
+	for !l.eof() && isBareKeyChar(l.b[l.i]) {
+		l.i++
+	}
+
+	if i == l.i {
+		return "", l.errorf("expected a key but found %s", l.found())
+	}
+
+	return string(l.b[i:l.i]), nil
+}
+
+func (l *loader) parseValue() (interface{}, error) {
+	if l.eof() {
+		return nil, l.errorf("expected a value but found the end of the document")
+	}
+
+	switch c := l.b[l.i]; {
+	case l.hasPrefix(`"""`):
+		return l.parseMultilineBasicString()
+
+	case c == '"':
+		return l.parseBasicString()
+
+	case l.hasPrefix("'''"):
+		return l.parseMultilineLiteralString()
+
+	case c == '\'':
+		return l.parseLiteralString()
+
+	case c == '[':
+		return l.parseArray()
+
+	case c == '{':
+		return l.parseInlineTable()
+
+	case c == 't' || c == 'f':
+		return l.parseBool()
+
+	case l.isDateTime():
+		return l.parseDateTime()
+
+	default:
+		return l.parseNumber()
+	}
+}
+
+func (l *loader) parseBool() (bool, error) {
+	for _, b := range [...]bool{true, false} {
+		if s := strconv.FormatBool(b); l.hasPrefix(s) && !l.isBareKeyCharAt(l.i+len(s)) {
+			l.i += len(s)
+			return b, nil
+		}
+	}
+	return false, l.errorf("invalid value %s", l.found())
+}
+
+func (l *loader) parseNumber() (interface{}, error) {
+	i := l.i
+
+	for !l.eof() && (isBareKeyChar(l.b[l.i]) || l.b[l.i] == '+' || l.b[l.i] == '.') {
+		l.i++
+	}
+
+	s := string(l.b[i:l.i])
+
+	switch strings.TrimLeft(s, "+-") {
+	case "inf":
+		if s[0] == '-' {
+			return math.Inf(-1), nil
+		}
+		return math.Inf(1), nil
+
+	case "nan":
+		return math.NaN(), nil
+	}
+
+	if len(s) > 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'o' || s[1] == 'b') {
+		base := map[byte]int{'x': 16, 'o': 8, 'b': 2}[s[1]]
+
+		if digits, ok := removeUnderscores(s[2:]); ok {
+			if v, err := strconv.ParseInt(digits, base, 64); err == nil {
+				return v, nil
+			}
+		}
+
+		return nil, l.errorf("invalid integer %q", s)
+	}
+
+	digits, ok := removeUnderscores(strings.TrimLeft(s, "+-"))
+
+	if !ok || len(digits) == 0 {
+		return nil, l.errorf("invalid value %q", s)
+	}
+
+	if strings.ContainsAny(digits, ".eE") {
+		if !isValidFloat(digits) {
+			return nil, l.errorf("invalid float %q", s)
+		}
+
+		v, err := strconv.ParseFloat(s[:len(s)-len(strings.TrimLeft(s, "+-"))]+digits, 64)
+		if err != nil {
+			return nil, l.errorf("invalid float %q", s)
+		}
+		return v, nil
+	}
+
+	if len(digits) > 1 && digits[0] == '0' {
+		return nil, l.errorf("invalid integer %q, leading zeros are not allowed", s)
+	}
+
+	v, err := strconv.ParseInt(s[:len(s)-len(strings.TrimLeft(s, "+-"))]+digits, 10, 64)
+	if err != nil {
+		return nil, l.errorf("invalid integer %q", s)
+	}
+	return v, nil
+}
+
+// isDateTime returns true if the next value is a date or a time, which start
+// with four digits followed by a dash, or two digits followed by a colon.
+func (l *loader) isDateTime() bool {
+	isDigits := func(i, n int) bool {
+		for j := i; j != i+n; j++ {
+			if j >= len(l.b) || l.b[j] < '0' || l.b[j] > '9' {
+				return false
+			}
+		}
+		return true
+	}
+	return (isDigits(l.i, 4) && l.byteAt(l.i+4) == '-') || (isDigits(l.i, 2) && l.byteAt(l.i+2) == ':')
+}
+
+func (l *loader) parseDateTime() (interface{}, error) {
+	i := l.i
+
+	for !l.eof() {
+		c := l.b[l.i]
+
+		if c == ' ' && l.i-i == 10 && l.byteAt(l.i+1) >= '0' && l.byteAt(l.i+1) <= '9' {
+			l.i++ // space separating the date and time
+			continue
+		}
+
+		if !((c >= '0' && c <= '9') || strings.IndexByte("-:.+TtZz", c) >= 0) {
+			break
+		}
+
+		l.i++
+	}
+
+	s := string(l.b[i:l.i])
+	t := []byte(s)
+
+	if len(t) > 10 && (t[10] == ' ' || t[10] == 't') {
+		t[10] = 'T'
+	}
+
+	if n := len(t) - 1; t[n] == 'z' {
+		t[n] = 'Z'
+	}
+
+	if v, err := time.Parse(time.RFC3339Nano, string(t)); err == nil {
+		return v, nil
+	}
+
+	loc := l.location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	for _, layout := range [...]string{
+		"2006-01-02T15:04:05.999999999",
+		"2006-01-02",
+		"15:04:05.999999999",
+	} {
+		if v, err := time.ParseInLocation(layout, string(t), loc); err == nil {
+			if l.localAsString {
+				return s, nil
+			}
+			return v, nil
+		}
+	}
+
+	return nil, l.errorf("invalid date-time %q", s)
+}
+
+func (l *loader) parseArray() ([]interface{}, error) {
+	a := []interface{}{}
+	l.i++ // '['
+
+	for {
+		l.skipBlank()
+
+		if l.hasPrefix("]") {
+			l.i++
+			return a, nil
+		}
+
+		v, err := l.parseValue()
+		if err != nil {
+			return nil, err
+		}
+
+		a = append(a, v)
+		l.skipBlank()
+
+		switch {
+		case l.hasPrefix(","):
+			l.i++
+
+		case l.hasPrefix("]"):
+			l.i++
+			return a, nil
+
+		default:
+			return nil, l.errorf("expected ',' or ']' after array element but found %s", l.found())
+		}
+	}
+}
+
+func (l *loader) parseInlineTable() (*table, error) {
+	t := newTable(inlineTable)
+	l.i++ // '{'
+	l.skipSpace()
+
+	if l.hasPrefix("}") {
+		l.i++
+		return t, nil
+	}
+
+	// The table isn't marked as inline while it is built so dotted keys can
+	// add values to the tables they create.
+	t.kind = dottedTable
+
+	for {
+		if err := l.loadKeyValue(t); err != nil {
+			return nil, err
+		}
+
+		l.skipSpace()
+
+		switch {
+		case l.hasPrefix(","):
+			l.i++
+
+		case l.hasPrefix("}"):
+			l.i++
+			markInline(t)
+			return t, nil
+
+		default:
+			return nil, l.errorf("expected ',' or '}' after inline table value but found %s", l.found())
+		}
+	}
+}
+
+func markInline(t *table) {
+	t.kind = inlineTable
+
+	for _, v := range t.values {
+		if x, ok := v.(*table); ok {
+			markInline(x)
+		}
+	}
+}
+
+func (l *loader) parseBasicString() (string, error) {
+	var b []byte
+	l.i++ // '"'
+
+	for {
+		if l.eof() {
+			return "", l.errorf("unterminated string")
+		}
+
+		switch c := l.b[l.i]; {
+		case c == '"':
+			l.i++
+			return string(b), nil
+
+		case c == '\\':
+			var err error
+			if b, err = l.parseEscape(b); err != nil {
+				return "", err
+			}
+
+		case c == '\n' || c == '\r':
+			return "", l.errorf("newline found in single-line string")
+
+		case c < 0x20 && c != '\t':
+			return "", l.errorf("invalid control character %q in string", c)
+
+		default:
+			b = append(b, c)
+			l.i++
+		}
+	}
+}
+
+func (l *loader) parseMultilineBasicString() (string, error) {
+	var b []byte
+	l.i += 3
+	l.skipNewline()
+
+	for {
+		if l.eof() {
+			return "", l.errorf("unterminated multi-line string")
+		}
+
+		switch c := l.b[l.i]; {
+		case l.hasPrefix(`"""`):
+			return string(append(b, l.closeMultiline('"')...)), nil
+
+		case c == '\\':
+			if j := l.lineEndingBackslash(); j != 0 {
+				l.i = j
+				l.skipBlankNoComments()
+				continue
+			}
+
+			var err error
+			if b, err = l.parseEscape(b); err != nil {
+				return "", err
+			}
+
+		case c == '\n':
+			b = append(b, c)
+			l.i++
+			l.line++
+
+		case c < 0x20 && c != '\t' && c != '\r':
+			return "", l.errorf("invalid control character %q in string", c)
+
+		default:
+			b = append(b, c)
+			l.i++
+		}
+	}
+}
+
+func (l *loader) parseLiteralString() (string, error) {
+	l.i++ // '\''
+	i := l.i
+
+	for {
+		if l.eof() {
+			return "", l.errorf("unterminated literal string")
+		}
+
+		switch c := l.b[l.i]; {
+		case c == '\'':
+			l.i++
+			return string(l.b[i : l.i-1]), nil
+
+		case c == '\n' || c == '\r':
+			return "", l.errorf("newline found in single-line literal string")
+
+		default:
+			l.i++
+		}
+	}
+}
+
+func (l *loader) parseMultilineLiteralString() (string, error) {
+	l.i += 3
+	l.skipNewline()
+	i := l.i
+
+	for {
+		if l.eof() {
+			return "", l.errorf("unterminated multi-line literal string")
+		}
+
+		if l.hasPrefix("'''") {
+			s := string(l.b[i:l.i])
+			return s + l.closeMultiline('\''), nil
+		}
+
+		if l.b[l.i] == '\n' {
+			l.line++
+		}
+
+		l.i++
+	}
+}
+
+// closeMultiline consumes the delimiter of a multi-line string, which may be
+// preceded by up to two quotes that are part of the string, and returns them.
+func (l *loader) closeMultiline(quote byte) string {
+	n := 0
+
+	for n < 5 && l.byteAt(l.i+n) == quote {
+		n++
+	}
+
+	l.i += n
+	return strings.Repeat(string(quote), n-3)
+}
+
+// lineEndingBackslash returns the position following the newline if the
+// backslash at the current position is only followed by whitespace on its
+// line, or zero otherwise.
+func (l *loader) lineEndingBackslash() int {
+	for j := l.i + 1; j < len(l.b); j++ {
+		switch l.b[j] {
+		case ' ', '\t', '\r':
+		case '\n':
+			return j
+		default:
+			return 0
+		}
+	}
+	return 0
+}
+
+func (l *loader) parseEscape(b []byte) ([]byte, error) {
+	if l.i+1 >= len(l.b) {
+		return nil, l.errorf("unterminated escape sequence")
+	}
+
+	c := l.b[l.i+1]
+	l.i += 2
+
+	switch c {
+	case 'b':
+		return append(b, '\b'), nil
+	case 't':
+		return append(b, '\t'), nil
+	case 'n':
+		return append(b, '\n'), nil
+	case 'f':
+		return append(b, '\f'), nil
+	case 'r':
+		return append(b, '\r'), nil
+	case '"':
+		return append(b, '"'), nil
+	case '\\':
+		return append(b, '\\'), nil
+	case 'u', 'U':
+		n := 4
+		if c == 'U' {
+			n = 8
+		}
+
+		if l.i+n > len(l.b) {
+			return nil, l.errorf("unterminated unicode escape sequence")
+		}
+
+		s := string(l.b[l.i : l.i+n])
+		r, err := strconv.ParseUint(s, 16, 32)
+
+		if err != nil || !utf8.ValidRune(rune(r)) {
+			return nil, l.errorf("invalid unicode escape sequence \\%c%s", c, s)
+		}
+
+		l.i += n
+		return utf8.AppendRune(b, rune(r)), nil
+
+	default:
+		return nil, l.errorf("invalid escape sequence \\%c", c)
+	}
+}
+
+func (l *loader) expect(s string) error {
+	l.skipSpace()
+
+	if !l.hasPrefix(s) {
+		return l.errorf("expected '%s' but found %s", s, l.found())
+	}
+
+	l.i += len(s)
+	return nil
+}
+
+// endOfLine consumes the rest of the current line, which may only contain
+// whitespace and a comment.
+func (l *loader) endOfLine() error {
+	l.skipSpace()
+	l.skipComment()
+
+	if l.eof() {
+		return nil
+	}
+
+	if !l.skipNewline() {
+		return l.errorf("expected a new line but found %s", l.found())
+	}
+
+	return nil
+}
+
+// skipBlank skips whitespace, newlines and comments.
+func (l *loader) skipBlank() {
+	for {
+		l.skipSpace()
+		l.skipComment()
+
+		if !l.skipNewline() {
+			return
+		}
+	}
+}
+
+// skipBlankNoComments skips whitespace and newlines.
+func (l *loader) skipBlankNoComments() {
+	for {
+		l.skipSpace()
+
+		if !l.skipNewline() {
+			return
+		}
+	}
+}
+
+func (l *loader) skipSpace() {
+	for !l.eof() && (l.b[l.i] == ' ' || l.b[l.i] == '\t') {
+		l.i++
+	}
+}
+
+func (l *loader) skipComment() {
+	if l.hasPrefix("#") {
+		for !l.eof() && l.b[l.i] != '\n' {
+			l.i++
+		}
+	}
+}
+
+func (l *loader) skipNewline() bool {
+	switch {
+	case l.hasPrefix("\n"):
+		l.i++
+	case l.hasPrefix("\r\n"):
+		l.i += 2
+	default:
+		return false
+	}
+	l.line++
+	return true
+}
+
+func (l *loader) eof() bool {
+	return l.i >= len(l.b)
+}
+
+func (l *loader) byteAt(i int) byte {
+	if i < len(l.b) {
+		return l.b[i]
+	}
+	return 0
+}
+
+func (l *loader) hasPrefix(s string) bool {
+	return strings.HasPrefix(string(l.b[l.i:]), s)
+}
+
+func (l *loader) isBareKeyCharAt(i int) bool {
+	return i < len(l.b) && isBareKeyChar(l.b[i])
+}
+
+// found describes the input at the current position for error messages.
+func (l *loader) found() string {
+	if l.eof() {
+		return "the end of the document"
+	}
+	r, _ := utf8.DecodeRune(l.b[l.i:])
+	return strconv.QuoteRune(r)
+}
+
+func isBareKeyChar(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_' || c == '-'
+}
+
+// removeUnderscores removes the underscores separating digits of numbers, it
+// returns false if an underscore isn't surrounded by digits.
+func removeUnderscores(s string) (string, bool) {
+	if strings.IndexByte(s, '_') < 0 {
+		return s, true
+	}
+
+	b := make([]byte, 0, len(s))
+
+	for i := 0; i != len(s); i++ {
+		if s[i] != '_' {
+			b = append(b, s[i])
+			continue
+		}
+
+		if i == 0 || i == len(s)-1 || !isHexDigit(s[i-1]) || !isHexDigit(s[i+1]) {
+			return "", false
+		}
+	}
+
+	return string(b), true
+}
+
+// isValidFloat checks the constraints that TOML adds to the syntax accepted by
+// strconv.ParseFloat, the decimal point must be surrounded by digits.
+func isValidFloat(s string) bool {
+	i := strings.IndexByte(s, '.')
+	return i < 0 || (i > 0 && i < len(s)-1 && isDigit(s[i-1]) && isDigit(s[i+1]))
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
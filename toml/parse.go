@@ -0,0 +1,312 @@
+// Package toml implements a parser of TOML documents which satisfies the
+// objconv.Parser interface, so configuration files can be decoded into Go
+// values with the objconv decoders.
+//
+// TOML documents can't be parsed incrementally because tables may be defined
+// anywhere in the document, so the parser loads the whole input and exposes
+// the values it contains. Tables and inline tables are parsed as maps, and
+// arrays of tables as arrays of maps.
+package toml
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/segmentio/objconv"
+)
+
+// Parser implements a parser of TOML documents.
+//
+// Offset date-times are parsed as time values. TOML also has local date-times,
+// dates and times which have no offset, they are parsed as time values in
+// Location as well, unless LocalAsString is set. Local dates have no time
+// component, and local times are on January 1st of year 0.
+//
+// Integers and floats are parsed as Int and Float values, following the
+// distinction made by the document.
+type Parser struct {
+	// Location is the location of local date-times, dates and times, when nil
+	// they are in UTC.
+	Location *time.Location
+
+	// LocalAsString makes the parser expose local date-times, dates and times
+	// as strings in the form they have in the document, which preserves the
+	// distinction between them and offset date-times.
+	LocalAsString bool
+
+	r io.Reader // reader to load bytes from
+	s []byte    // string buffer
+	// This stack is used to iterate over the arrays and tables of the loaded
+	// document.
+	stack []parser
+}
+
+func NewParser(r io.Reader) *Parser {
+	return &Parser{r: r}
+}
+
+func (p *Parser) Reset(r io.Reader) {
+	p.r = r
+	p.s = nil
+	p.stack = nil
+}
+
+func (p *Parser) Buffered() io.Reader {
+	return bytes.NewReader(nil)
+}
+
+func (p *Parser) ParseType() (typ objconv.Type, err error) {
+	if p.stack == nil {
+		var b []byte
+		var t *table
+
+		if b, err = ioutil.ReadAll(p.r); err != nil {
+			return
+		}
+
+		l := &loader{b: b, line: 1, location: p.Location, localAsString: p.LocalAsString}
+
+		if t, err = l.load(); err != nil {
+			return
+		}
+
+		p.push(newParser(t))
+	}
+
+	switch v := p.value(); v.(type) {
+	case bool:
+		typ = objconv.Bool
+
+	case int64:
+		typ = objconv.Int
+
+	case float64:
+		typ = objconv.Float
+
+	case string:
+		typ = objconv.String
+
+	case time.Time:
+		typ = objconv.Time
+
+	case *table:
+		typ = objconv.Map
+
+	case []interface{}, *tableArray:
+		typ = objconv.Array
+
+	case eof:
+		err = io.EOF
+
+	default:
+		err = fmt.Errorf("objconv/toml: unsupported value of type %T", v)
+	}
+
+	return
+}
+
+func (p *Parser) ParseNil() (err error) {
+	panic("objconv/toml: ParseNil should never be called because TOML has no null type, this is likely a bug in the decoder code")
+}
+
+func (p *Parser) ParseBool() (v bool, err error) {
+	v = p.pop().value().(bool)
+	return
+}
+
+func (p *Parser) ParseInt() (v int64, err error) {
+	v = p.pop().value().(int64)
+	return
+}
+
+func (p *Parser) ParseUint() (v uint64, err error) {
+	panic("objconv/toml: ParseUint should never be called because TOML has no unsigned integer type, this is likely a bug in the decoder code")
+}
+
+func (p *Parser) ParseFloat() (v float64, err error) {
+	v = p.pop().value().(float64)
+	return
+}
+
+func (p *Parser) ParseString() (v []byte, err error) {
+	s := p.pop().value().(string)
+	v = append(p.s[:0], s...)
+	p.s = v
+	return
+}
+
+func (p *Parser) ParseBytes() (v []byte, err error) {
+	panic("objconv/toml: ParseBytes should never be called because TOML has no bytes type, this is likely a bug in the decoder code")
+}
+
+func (p *Parser) ParseTime() (v time.Time, err error) {
+	v = p.pop().value().(time.Time)
+	return
+}
+
+func (p *Parser) ParseDuration() (v time.Duration, err error) {
+	panic("objconv/toml: ParseDuration should never be called because TOML has no duration type, this is likely a bug in the decoder code")
+}
+
+func (p *Parser) ParseError() (v error, err error) {
+	panic("objconv/toml: ParseError should never be called because TOML has no error type, this is likely a bug in the decoder code")
+}
+
+func (p *Parser) ParseArrayBegin() (n int, err error) {
+	if n = p.top().len(); n != 0 {
+		p.push(newParser(p.top().next()))
+	}
+	return
+}
+
+func (p *Parser) ParseArrayEnd(n int) (err error) {
+	p.pop()
+	return
+}
+
+func (p *Parser) ParseArrayNext(n int) (err error) {
+	p.push(newParser(p.top().next()))
+	return
+}
+
+func (p *Parser) ParseMapBegin() (n int, err error) {
+	if n = p.top().len(); n != 0 {
+		p.push(newParser(p.top().next()))
+	}
+	return
+}
+
+func (p *Parser) ParseMapEnd(n int) (err error) {
+	p.pop()
+	return
+}
+
+func (p *Parser) ParseMapValue(n int) (err error) {
+	p.push(newParser(p.top().next()))
+	return
+}
+
+func (p *Parser) ParseMapNext(n int) (err error) {
+	p.push(newParser(p.top().next()))
+	return
+}
+
+func (p *Parser) push(v parser) {
+	p.stack = append(p.stack, v)
+}
+
+func (p *Parser) pop() parser {
+	i := len(p.stack) - 1
+	v := p.stack[i]
+	p.stack = p.stack[:i]
+	return v
+}
+
+func (p *Parser) top() parser {
+	return p.stack[len(p.stack)-1]
+}
+
+func (p *Parser) value() interface{} {
+	n := len(p.stack)
+	if n == 0 {
+		return eof{}
+	}
+	return p.stack[n-1].value()
+}
+
+type parser interface {
+	value() interface{}
+	next() interface{}
+	len() int
+}
+
+type valueParser struct {
+	self interface{}
+}
+
+func (p *valueParser) value() interface{} {
+	return p.self
+}
+
+func (p *valueParser) next() interface{} {
+	panic("objconv/toml: invalid call of next method on simple value parser")
+}
+
+func (p *valueParser) len() int {
+	panic("objconv/toml: invalid call of len method on simple value parser")
+}
+
+type arrayParser struct {
+	self interface{}
+	list []interface{}
+	off  int
+}
+
+func (p *arrayParser) value() interface{} {
+	return p.self
+}
+
+func (p *arrayParser) next() interface{} {
+	v := p.list[p.off]
+	p.off++
+	return v
+}
+
+func (p *arrayParser) len() int {
+	return len(p.list)
+}
+
+type tableParser struct {
+	self *table
+	off  int
+	val  bool
+}
+
+func (p *tableParser) value() interface{} {
+	return p.self
+}
+
+func (p *tableParser) next() (v interface{}) {
+	k := p.self.keys[p.off]
+
+	if p.val {
+		v = p.self.values[k]
+		p.val = false
+		p.off++
+	} else {
+		v = k
+		p.val = true
+	}
+	return
+}
+
+func (p *tableParser) len() int {
+	return len(p.self.keys)
+}
+
+func newParser(v interface{}) parser {
+	switch x := v.(type) {
+	case *table:
+		return &tableParser{self: x}
+
+	case *tableArray:
+		list := make([]interface{}, len(x.tables))
+		for i, t := range x.tables {
+			list[i] = t
+		}
+		return &arrayParser{self: x, list: list}
+
+	case []interface{}:
+		return &arrayParser{self: x, list: x}
+
+	default:
+		return &valueParser{self: x}
+	}
+}
+
+// eof values are returned by the top method to indicate that all values have
+// already been consumed.
+type eof struct{}
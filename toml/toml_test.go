@@ -0,0 +1,336 @@
+package toml
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/segmentio/objconv"
+)
+
+const testConfig = `
+# Service configuration
+title = "TOML \"example\""
+port = 8_080
+ratio = 0.5
+enabled = true
+started = 1979-05-27T07:32:00-08:00
+
+[database]
+hosts = [
+  "alpha", # first
+  "omega",
+]
+limits = { max = 100, timeout.seconds = 3 }
+path = 'C:\Users\nodejs'
+
+[database.replica]
+lag = 1e3
+
+[[servers]]
+name = "a"
+ip.v4 = "10.0.0.1"
+
+[[servers]]
+name = "b"
+ip.v4 = "10.0.0.2"
+`
+
+type testConfigType struct {
+	Title    string    `objconv:"title"`
+	Port     int       `objconv:"port"`
+	Ratio    float64   `objconv:"ratio"`
+	Enabled  bool      `objconv:"enabled"`
+	Started  time.Time `objconv:"started"`
+	Database struct {
+		Hosts  []string `objconv:"hosts"`
+		Limits struct {
+			Max     int            `objconv:"max"`
+			Timeout map[string]int `objconv:"timeout"`
+		} `objconv:"limits"`
+		Path    string             `objconv:"path"`
+		Replica map[string]float64 `objconv:"replica"`
+	} `objconv:"database"`
+	Servers []struct {
+		Name string `objconv:"name"`
+		IP   struct {
+			V4 string `objconv:"v4"`
+		} `objconv:"ip"`
+	} `objconv:"servers"`
+}
+
+func TestUnmarshal(t *testing.T) {
+	var c testConfigType
+
+	if err := Unmarshal([]byte(testConfig), &c); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Title != `TOML "example"` || c.Port != 8080 || c.Ratio != 0.5 || !c.Enabled {
+		t.Errorf("bad scalar values: %+v", c)
+	}
+
+	if !c.Started.Equal(time.Date(1979, 5, 27, 15, 32, 0, 0, time.UTC)) {
+		t.Error("bad time:", c.Started)
+	}
+
+	if !reflect.DeepEqual(c.Database.Hosts, []string{"alpha", "omega"}) {
+		t.Error("bad hosts:", c.Database.Hosts)
+	}
+
+	if c.Database.Limits.Max != 100 || c.Database.Limits.Timeout["seconds"] != 3 {
+		t.Errorf("bad limits: %+v", c.Database.Limits)
+	}
+
+	if c.Database.Path != `C:\Users\nodejs` {
+		t.Error("bad path:", c.Database.Path)
+	}
+
+	if c.Database.Replica["lag"] != 1000 {
+		t.Error("bad replica:", c.Database.Replica)
+	}
+
+	if len(c.Servers) != 2 || c.Servers[0].Name != "a" || c.Servers[1].IP.V4 != "10.0.0.2" {
+		t.Errorf("bad servers: %+v", c.Servers)
+	}
+}
+
+func TestValues(t *testing.T) {
+	tests := []struct {
+		in  string
+		out interface{}
+	}{
+		{`1`, int64(1)},
+		{`+17`, int64(17)},
+		{`-17`, int64(-17)},
+		{`1_000`, int64(1000)},
+		{`0xDEAD_BEEF`, int64(0xdeadbeef)},
+		{`0o755`, int64(0755)},
+		{`0b1101`, int64(13)},
+		{`1.0`, 1.0},
+		{`-2e-2`, -0.02},
+		{`5e+22`, 5e22},
+		{`inf`, math.Inf(1)},
+		{`-inf`, math.Inf(-1)},
+		{`false`, false},
+		{`"\u00e9\t\\"`, "é\t\\"},
+		{`'<\i\c*\s*>'`, `<\i\c*\s*>`},
+		{"\"\"\"\nRoses\nViolets\"\"\"", "Roses\nViolets"},
+		{"\"\"\"\nThe quick \\\n\n  brown fox\"\"\"", "The quick brown fox"},
+		{`""""quoted"""""`, `"quoted""`},
+		{"'''\nraw\\n'''", `raw\n`},
+		{`1979-05-27T07:32:00Z`, time.Date(1979, 5, 27, 7, 32, 0, 0, time.UTC)},
+		{`1979-05-27 07:32:00.5+01:00`, time.Date(1979, 5, 27, 6, 32, 0, 500000000, time.UTC)},
+		{`1979-05-27T07:32:00`, time.Date(1979, 5, 27, 7, 32, 0, 0, time.UTC)},
+		{`1979-05-27`, time.Date(1979, 5, 27, 0, 0, 0, 0, time.UTC)},
+		{`07:32:00`, time.Date(0, 1, 1, 7, 32, 0, 0, time.UTC)},
+		{`[1, [2.5, "a"], {x = 1}]`, []interface{}{int64(1), []interface{}{2.5, "a"}, map[interface{}]interface{}{"x": int64(1)}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			var m map[string]interface{}
+
+			if err := Unmarshal([]byte("v = "+test.in), &m); err != nil {
+				t.Fatal(err)
+			}
+
+			v := m["v"]
+
+			if tm, ok := v.(time.Time); ok {
+				if !tm.Equal(test.out.(time.Time)) {
+					t.Errorf("%s != %s", tm, test.out)
+				}
+			} else if !reflect.DeepEqual(v, test.out) {
+				t.Errorf("%#v != %#v", v, test.out)
+			}
+		})
+	}
+}
+
+func TestLocalAsString(t *testing.T) {
+	p := NewParser(strings.NewReader("a = 1979-05-27\nb = 07:32:00.25\nc = 1979-05-27 07:32:00\nd = 1979-05-27T07:32:00Z\n"))
+	p.LocalAsString = true
+
+	var m map[string]interface{}
+
+	if err := objconv.NewDecoder(p).Decode(&m); err != nil {
+		t.Fatal(err)
+	}
+
+	if m["a"] != "1979-05-27" || m["b"] != "07:32:00.25" || m["c"] != "1979-05-27 07:32:00" {
+		t.Errorf("bad local values: %#v", m)
+	}
+
+	if _, ok := m["d"].(time.Time); !ok {
+		t.Errorf("bad offset date-time: %#v", m["d"])
+	}
+}
+
+func TestLocation(t *testing.T) {
+	loc := time.FixedZone("test", 3600)
+	p := NewParser(strings.NewReader("t = 1979-05-27T07:32:00"))
+	p.Location = loc
+
+	var m map[string]time.Time
+
+	if err := objconv.NewDecoder(p).Decode(&m); err != nil {
+		t.Fatal(err)
+	}
+
+	if !m["t"].Equal(time.Date(1979, 5, 27, 7, 32, 0, 0, loc)) {
+		t.Error("bad time:", m["t"])
+	}
+}
+
+func TestKeyOrder(t *testing.T) {
+	var keys []string
+
+	d := NewDecoder(strings.NewReader("c = 1\na = 2\nb = 3"))
+	err := d.DecodeMap(func(kd, vd objconv.Decoder) error {
+		var k string
+		if err := kd.Decode(&k); err != nil {
+			return err
+		}
+		keys = append(keys, k)
+		return vd.Decode(nil)
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(keys, []string{"c", "a", "b"}) {
+		t.Error("bad key order:", keys)
+	}
+}
+
+func TestDottedKeys(t *testing.T) {
+	tests := []struct {
+		in  string
+		out interface{}
+	}{
+		{
+			in:  "a.b.c = 1\na.b.d = 2\na.e = 3",
+			out: map[interface{}]interface{}{"a": map[interface{}]interface{}{"b": map[interface{}]interface{}{"c": int64(1), "d": int64(2)}, "e": int64(3)}},
+		},
+		{
+			in:  "\"a.b\" = 1\nsite . \"google.com\" = true",
+			out: map[interface{}]interface{}{"a.b": int64(1), "site": map[interface{}]interface{}{"google.com": true}},
+		},
+		{
+			in:  "[fruit]\napple.color = \"red\"\n[fruit.apple.texture]\nsmooth = true",
+			out: map[interface{}]interface{}{"fruit": map[interface{}]interface{}{"apple": map[interface{}]interface{}{"color": "red", "texture": map[interface{}]interface{}{"smooth": true}}}},
+		},
+		{
+			in:  "[a.b.c]\nd = 1\n[a]\ne.f = 2",
+			out: map[interface{}]interface{}{"a": map[interface{}]interface{}{"b": map[interface{}]interface{}{"c": map[interface{}]interface{}{"d": int64(1)}}, "e": map[interface{}]interface{}{"f": int64(2)}}},
+		},
+		{
+			in:  "[[a]]\nb.c = 1\n[[a]]\nb.c = 2",
+			out: map[interface{}]interface{}{"a": []interface{}{map[interface{}]interface{}{"b": map[interface{}]interface{}{"c": int64(1)}}, map[interface{}]interface{}{"b": map[interface{}]interface{}{"c": int64(2)}}}},
+		},
+		{
+			in:  "a = {b.c = 1, b.d = 2}",
+			out: map[interface{}]interface{}{"a": map[interface{}]interface{}{"b": map[interface{}]interface{}{"c": int64(1), "d": int64(2)}}},
+		},
+		{
+			in:  "1.2 = 3",
+			out: map[interface{}]interface{}{"1": map[interface{}]interface{}{"2": int64(3)}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			var v interface{}
+
+			if err := Unmarshal([]byte(test.in), &v); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(v, test.out) {
+				t.Errorf("%#v", v)
+			}
+		})
+	}
+
+	// Dotted keys and headers must decode to the same nested structs.
+	var a, b struct {
+		A struct {
+			B struct {
+				C int `objconv:"c"`
+			} `objconv:"b"`
+			D int `objconv:"d"`
+		} `objconv:"a"`
+	}
+
+	if err := Unmarshal([]byte("a.b.c = 1\na.d = 2"), &a); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Unmarshal([]byte("[a]\nd = 2\n[a.b]\nc = 1"), &b); err != nil {
+		t.Fatal(err)
+	}
+
+	if a != b || a.A.B.C != 1 || a.A.D != 2 {
+		t.Errorf("%+v != %+v", a, b)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		in  string
+		err string
+	}{
+		{"a = 1\na = 2", "objconv/toml: line 2: key a is already defined"},
+		{"[a]\n[a]", "objconv/toml: line 2: table a defined more than once"},
+		{"a = 1\n[a.b]", "objconv/toml: line 2: key a is already defined and is not a table"},
+		{"a = {x = 1}\n[a.y]", "objconv/toml: line 2: inline table a cannot be extended"},
+		{"a = [1]\n[[a]]", "objconv/toml: line 2: key a is already defined and is not an array of tables"},
+		{"a = 1 b = 2", "objconv/toml: line 1: expected a new line but found 'b'"},
+		{"a = 01", `objconv/toml: line 1: invalid integer "01", leading zeros are not allowed`},
+		{"a = 1__0", `objconv/toml: line 1: invalid value "1__0"`},
+		{"a = .5", `objconv/toml: line 1: invalid float ".5"`},
+		{"a = 1.", `objconv/toml: line 1: invalid float "1."`},
+		{"a = \"abc", "objconv/toml: line 1: unterminated string"},
+		{"a = \"\\q\"", `objconv/toml: line 1: invalid escape sequence \q`},
+		{"a = [1 2]", "objconv/toml: line 1: expected ',' or ']' after array element but found '2'"},
+		{"a = {x = 1,\ny = 2}", "objconv/toml: line 1: expected a key but found '\\n'"},
+		{"a = 1979-13-01", `objconv/toml: line 1: invalid date-time "1979-13-01"`},
+		{"= 1", "objconv/toml: line 1: expected a key but found '='"},
+		{"a.b = 1\n[a]", "objconv/toml: line 2: table a defined more than once"},
+		{"a.b = 1\na.b.c = 2", "objconv/toml: line 2: key a.b is already defined and is not a table"},
+		{"a.b.c = 1\na.b = 2", "objconv/toml: line 2: key a.b is already defined"},
+		{"[a]\nb.c = 1\n[a.b]", "objconv/toml: line 3: table a.b defined more than once"},
+		{"[a.b]\nc = 1\n[a]\nb.d = 2", "objconv/toml: line 4: table b defined more than once"},
+		{"[a.b.c]\n[a]\nb.c.d = 1", "objconv/toml: line 3: table b defined more than once"},
+		{"[[a.b]]\n[a]\nb.c = 1", "objconv/toml: line 3: array of tables b cannot be extended with dotted keys"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			var v interface{}
+
+			if err := Unmarshal([]byte(test.in), &v); err == nil || err.Error() != test.err {
+				t.Errorf("bad error: %v", err)
+			}
+		})
+	}
+}
+
+func TestStreamDecoder(t *testing.T) {
+	d := NewStreamDecoder(bytes.NewReader([]byte("a = 1")))
+
+	var m map[string]int
+
+	if err := d.Decode(&m); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.Decode(&m); err != objconv.End {
+		t.Error("expected the end of the stream after the document but got", err)
+	}
+}
@@ -0,0 +1,97 @@
+package objconv
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RegisterType registers the concrete type t, which the decoder may select
+// when decoding values into interface types.
+//
+// When the destination of a decoded value is a non-empty interface type which
+// has no adapter installed (like the ones installed by RegisterVersioned), the
+// decoder looks for the registered types implementing the interface, either
+// directly or through a pointer. If exactly one type matches, the value is
+// decoded into a new value of this type (or a pointer to it), otherwise the
+// decoding fails, reporting the ambiguity when multiple types match.
+//
+// The function panics if t is an interface type.
+func RegisterType(t reflect.Type) {
+	if t.Kind() == reflect.Interface {
+		panic(fmt.Sprintf("objconv: cannot register the interface type %s, only concrete types can be registered", t))
+	}
+
+	typesMutex.Lock()
+	typesStore[t] = struct{}{}
+	typesMutex.Unlock()
+}
+
+// implementorsOf returns the registered types implementing the interface type
+// t, the types are returned as they need to be instantiated (pointer types are
+// returned when only the pointer implements the interface).
+func implementorsOf(t reflect.Type) (types []reflect.Type) {
+	typesMutex.RLock()
+
+	for typ := range typesStore {
+		switch {
+		case typ.Implements(t):
+			types = append(types, typ)
+		case reflect.PtrTo(typ).Implements(t):
+			types = append(types, reflect.PtrTo(typ))
+		}
+	}
+
+	typesMutex.RUnlock()
+	return
+}
+
+func (d Decoder) decodeImplementor(to reflect.Value) (t Type, err error) {
+	if t, err = d.Parser.ParseType(); err != nil {
+		return
+	}
+
+	if t == Nil {
+		err = d.decodeInterfaceFromNil(to)
+		return
+	}
+
+	typ := to.Type()
+	types := implementorsOf(typ)
+
+	switch len(types) {
+	case 0:
+		err = fmt.Errorf("objconv: the decoder doesn't support values of type %s, no registered type implements it", typ)
+		return
+	case 1:
+	default:
+		names := make([]string, len(types))
+		for i, t := range types {
+			names[i] = t.String()
+		}
+		sort.Strings(names)
+		err = fmt.Errorf("objconv: cannot decode into %s, multiple registered types implement it: %s", typ, strings.Join(names, ", "))
+		return
+	}
+
+	v := reflect.New(types[0]).Elem()
+
+	if v.Kind() == reflect.Ptr {
+		v.Set(reflect.New(types[0].Elem()))
+		_, err = d.decode(v.Elem())
+	} else {
+		_, err = d.decode(v)
+	}
+
+	if err == nil {
+		to.Set(v)
+	}
+	return
+}
+
+var (
+	typesMutex sync.RWMutex
+	typesStore = make(map[reflect.Type]struct{})
+)
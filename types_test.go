@@ -0,0 +1,108 @@
+package objconv
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testShape interface {
+	Area() float64
+}
+
+type testSquare struct {
+	Side float64 `objconv:"side"`
+}
+
+func (s testSquare) Area() float64 { return s.Side * s.Side }
+
+type testNamed interface {
+	Name() string
+}
+
+type testPerson struct {
+	FirstName string `objconv:"first_name"`
+}
+
+func (p *testPerson) Name() string { return p.FirstName }
+
+type testPet struct {
+	Nickname string `objconv:"nickname"`
+}
+
+func (p testPet) Name() string { return p.Nickname }
+
+func TestRegisterType(t *testing.T) {
+	RegisterType(reflect.TypeOf(testSquare{}))
+	RegisterType(reflect.TypeOf(testPerson{}))
+
+	t.Run("value", func(t *testing.T) {
+		var v struct {
+			Shape testShape `objconv:"shape"`
+		}
+
+		in := map[string]interface{}{"shape": map[string]interface{}{"side": 2}}
+
+		if err := NewDecoder(NewValueParser(in)).Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(v.Shape, testSquare{Side: 2}) {
+			t.Errorf("bad value: %#v", v.Shape)
+		}
+	})
+
+	t.Run("pointer", func(t *testing.T) {
+		var v testNamed
+
+		if err := NewDecoder(NewValueParser(map[string]interface{}{"first_name": "Luke"})).Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+
+		if p, ok := v.(*testPerson); !ok || p.FirstName != "Luke" {
+			t.Errorf("bad value: %#v", v)
+		}
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		var v testShape = testSquare{}
+
+		if err := NewDecoder(NewValueParser(nil)).Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+
+		if v != nil {
+			t.Errorf("bad value: %#v", v)
+		}
+	})
+
+	t.Run("ambiguous", func(t *testing.T) {
+		RegisterType(reflect.TypeOf(testPet{}))
+		defer func() {
+			typesMutex.Lock()
+			delete(typesStore, reflect.TypeOf(testPet{}))
+			typesMutex.Unlock()
+		}()
+
+		var v testNamed
+		err := NewDecoder(NewValueParser(map[string]interface{}{})).Decode(&v)
+
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+
+		if s := err.Error(); s != "objconv: cannot decode into objconv.testNamed, multiple registered types implement it: *objconv.testPerson, objconv.testPet" {
+			t.Error(s)
+		}
+	})
+
+	t.Run("none", func(t *testing.T) {
+		var v interface {
+			Unimplemented()
+		}
+
+		if err := NewDecoder(NewValueParser(1)).Decode(&v); err == nil || !strings.Contains(err.Error(), "no registered type implements it") {
+			t.Error("bad error:", err)
+		}
+	})
+}
@@ -1,9 +1,13 @@
 package objconv
 
 import (
+	"database/sql"
+	"database/sql/driver"
 	"encoding"
 	"errors"
+	"fmt"
 	"reflect"
+	"strconv"
 	"sync"
 	"time"
 	"unsafe"
@@ -103,6 +107,7 @@ var (
 	bytesType          = reflect.TypeOf([]byte(nil))
 	timeType           = reflect.TypeOf(time.Time{})
 	durationType       = reflect.TypeOf(time.Duration(0))
+	numberType         = reflect.TypeOf(Number(""))
 	sliceInterfaceType = reflect.TypeOf(([]interface{})(nil))
 	timePtrType        = reflect.PtrTo(timeType)
 
@@ -112,6 +117,8 @@ var (
 	valueDecoderInterface    = elemTypeOf((*ValueDecoder)(nil))
 	textMarshalerInterface   = elemTypeOf((*encoding.TextMarshaler)(nil))
 	textUnmarshalerInterface = elemTypeOf((*encoding.TextUnmarshaler)(nil))
+	scannerInterface         = elemTypeOf((*sql.Scanner)(nil))
+	valuerInterface          = elemTypeOf((*driver.Valuer)(nil))
 	emptyInterface           = elemTypeOf((*interface{})(nil))
 
 	// common map types, used for optimization for map encoding algorithms
@@ -148,6 +155,17 @@ type valueParserContext struct {
 	value  reflect.Value
 	keys   []reflect.Value
 	fields []structField
+
+	// The types of the keys and elements of arrays and maps, computed once from
+	// their static types (see valueTypeOf), and the type of the key or element
+	// currently at the top of the stack, which ParseType returns without
+	// inspecting the value when it isn't Unknown.
+	key  Type
+	elem Type
+	typ  Type
+
+	// Length of the stack when the keys or elements are at the top.
+	depth int
 }
 
 // NewValueParser creates a new parser that exposes the value v.
@@ -158,13 +176,24 @@ func NewValueParser(v interface{}) *ValueParser {
 }
 
 func (p *ValueParser) ParseType() (Type, error) {
+	if len(p.ctx) != 0 {
+		// Skip inspecting keys and elements when their type is known from the
+		// static type of their container.
+		if ctx := p.context(); ctx.typ != Unknown && len(p.stack) == ctx.depth {
+			return ctx.typ, nil
+		}
+	}
+
 	v := p.value()
 
 	if !v.IsValid() {
 		return Nil, nil
 	}
 
-	switch v.Interface().(type) {
+	switch x := v.Interface().(type) {
+	case Number:
+		return x.typ(), nil
+
 	case time.Time:
 		return Time, nil
 
@@ -215,6 +244,55 @@ func (p *ValueParser) ParseType() (Type, error) {
 	return Nil, errors.New("objconv: unsupported type found in value parser: " + v.Type().String())
 }
 
+// valueTypeOf returns the type that ValueParser.ParseType reports for all
+// values of type t, or Unknown if it depends on the values, like for pointers,
+// interfaces (where elements of []interface{} may all have different types) and
+// Number values.
+func valueTypeOf(t reflect.Type) Type {
+	switch {
+	case t.Kind() == reflect.Interface, t.Kind() == reflect.Ptr, t == numberType:
+		return Unknown
+	case t == timeType:
+		return Time
+	case t == durationType:
+		return Duration
+	case t.Implements(errorInterface):
+		return Error
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return Bool
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Int
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return Uint
+
+	case reflect.Float32, reflect.Float64:
+		return Float
+
+	case reflect.String:
+		return String
+
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return Bytes
+		}
+		return Array
+
+	case reflect.Array:
+		return Array
+
+	case reflect.Map, reflect.Struct:
+		return Map
+
+	default:
+		return Unknown
+	}
+}
+
 func (p *ValueParser) ParseNil() (err error) {
 	return
 }
@@ -225,20 +303,55 @@ func (p *ValueParser) ParseBool() (v bool, err error) {
 }
 
 func (p *ValueParser) ParseInt() (v int64, err error) {
+	if n, ok := p.number(); ok {
+		return n.Int64()
+	}
 	v = p.value().Int()
 	return
 }
 
 func (p *ValueParser) ParseUint() (v uint64, err error) {
+	if n, ok := p.number(); ok {
+		return n.Uint64()
+	}
 	v = p.value().Uint()
 	return
 }
 
 func (p *ValueParser) ParseFloat() (v float64, err error) {
+	if n, ok := p.number(); ok {
+		return n.Float64()
+	}
 	v = p.value().Float()
 	return
 }
 
+// ParseNumber returns the literal form of Number values, or the formatted
+// value of other numbers.
+func (p *ValueParser) ParseNumber() (v []byte, err error) {
+	if n, ok := p.number(); ok {
+		v = []byte(n)
+		return
+	}
+
+	switch x := p.value(); x.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v = strconv.AppendInt(nil, x.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		v = strconv.AppendUint(nil, x.Uint(), 10)
+	default:
+		v = strconv.AppendFloat(nil, x.Float(), 'g', -1, 64)
+	}
+	return
+}
+
+func (p *ValueParser) number() (n Number, ok bool) {
+	if v := p.value(); v.IsValid() && v.Type() == numberType {
+		n, ok = Number(v.String()), true
+	}
+	return
+}
+
 func (p *ValueParser) ParseString() (v []byte, err error) {
 	v = []byte(p.value().String())
 	return
@@ -267,7 +380,8 @@ func (p *ValueParser) ParseError() (v error, err error) {
 func (p *ValueParser) ParseArrayBegin() (n int, err error) {
 	v := p.value()
 	n = v.Len()
-	p.pushContext(valueParserContext{value: v})
+	t := valueTypeOf(v.Type().Elem())
+	p.pushContext(valueParserContext{value: v, elem: t, typ: t})
 
 	if n != 0 {
 		p.push(v.Index(0))
@@ -297,16 +411,19 @@ func (p *ValueParser) ParseMapBegin() (n int, err error) {
 	if v.Kind() == reflect.Map {
 		n = v.Len()
 		k := v.MapKeys()
-		p.pushContext(valueParserContext{value: v, keys: k})
+		t := v.Type()
+		c := valueParserContext{value: v, keys: k, key: valueTypeOf(t.Key()), elem: valueTypeOf(t.Elem())}
+		c.typ = c.key
+		p.pushContext(c)
 		if n != 0 {
 			p.push(k[0])
 		}
 	} else {
-		c := valueParserContext{value: v}
-		s := structCache.lookup(v.Type())
+		c := valueParserContext{value: v, key: String, typ: String}
+		s := structCache.lookup(v.Type(), defaultStructTag, false)
 
 		for _, f := range s.fields {
-			if !f.omit(v.FieldByIndex(f.index)) {
+			if fv, ok := fieldByIndex(v, f.index); ok && !f.omit(fv) {
 				c.fields = append(c.fields, f)
 				n++
 			}
@@ -335,8 +452,10 @@ func (p *ValueParser) ParseMapValue(n int) (err error) {
 
 	if ctx.keys != nil {
 		p.push(ctx.value.MapIndex(ctx.keys[n]))
+		ctx.typ = ctx.elem
 	} else {
 		p.push(ctx.value.FieldByIndex(ctx.fields[n].index))
+		ctx.typ = ctx.fields[n].typ
 	}
 
 	return
@@ -352,6 +471,8 @@ func (p *ValueParser) ParseMapNext(n int) (err error) {
 		p.push(reflect.ValueOf(ctx.fields[n].name))
 	}
 
+	ctx.typ = ctx.key
+
 	return
 }
 
@@ -388,6 +509,7 @@ func (p *ValueParser) pop() {
 }
 
 func (p *ValueParser) pushContext(ctx valueParserContext) {
+	ctx.depth = len(p.stack) + 1
 	p.ctx = append(p.ctx, ctx)
 }
 
@@ -404,9 +526,19 @@ func (p *ValueParser) context() *valueParserContext {
 //
 // This is useful for testing the high-level API of the package without actually
 // having to generate a serialized representation.
+//
+// The emitter returns errors when the calls to its Begin and End methods don't
+// match, for example when EmitArrayEnd is called to terminate a map.
 type ValueEmitter struct {
 	stack []interface{}
-	marks []int
+	marks []valueEmitterMark
+}
+
+// valueEmitterMark records the position on the stack where the elements of an
+// array or map begin.
+type valueEmitterMark struct {
+	offset int
+	typ    Type // Array or Map
 }
 
 // NewValueEmitter returns a pointer to a new ValueEmitter object.
@@ -414,8 +546,13 @@ func NewValueEmitter() *ValueEmitter {
 	return &ValueEmitter{}
 }
 
-// Value returns the value built in the emitter.
-func (e *ValueEmitter) Value() interface{} { return e.stack[0] }
+// Value returns the value built in the emitter, or nil if no value was emitted.
+func (e *ValueEmitter) Value() interface{} {
+	if len(e.stack) == 0 {
+		return nil
+	}
+	return e.stack[0]
+}
 
 func (e *ValueEmitter) EmitNil() error { return e.push(nil) }
 
@@ -437,10 +574,14 @@ func (e *ValueEmitter) EmitDuration(v time.Duration) error { return e.push(v) }
 
 func (e *ValueEmitter) EmitError(v error) error { return e.push(v) }
 
-func (e *ValueEmitter) EmitArrayBegin(v int) error { return e.pushMark() }
+func (e *ValueEmitter) EmitArrayBegin(v int) error { return e.pushMark(Array) }
 
 func (e *ValueEmitter) EmitArrayEnd() error {
-	v := e.pop(e.popMark())
+	n, err := e.popMark(Array)
+	if err != nil {
+		return err
+	}
+	v := e.pop(n)
 	a := make([]interface{}, len(v))
 	copy(a, v)
 	return e.push(a)
@@ -448,11 +589,21 @@ func (e *ValueEmitter) EmitArrayEnd() error {
 
 func (e *ValueEmitter) EmitArrayNext() error { return nil }
 
-func (e *ValueEmitter) EmitMapBegin(v int) error { return e.pushMark() }
+func (e *ValueEmitter) EmitMapBegin(v int) error { return e.pushMark(Map) }
 
 func (e *ValueEmitter) EmitMapEnd() error {
-	v := e.pop(e.popMark())
+	i, err := e.popMark(Map)
+	if err != nil {
+		return err
+	}
+
+	v := e.pop(i)
 	n := len(v)
+
+	if n%2 != 0 {
+		return errors.New("objconv: EmitMapEnd called after a map key with no value")
+	}
+
 	m := make(map[interface{}]interface{}, n/2)
 
 	for i := 0; i != n; i += 2 {
@@ -477,14 +628,38 @@ func (e *ValueEmitter) pop(n int) []interface{} {
 	return v
 }
 
-func (e *ValueEmitter) pushMark() error {
-	e.marks = append(e.marks, len(e.stack))
+func (e *ValueEmitter) pushMark(t Type) error {
+	e.marks = append(e.marks, valueEmitterMark{offset: len(e.stack), typ: t})
 	return nil
 }
 
-func (e *ValueEmitter) popMark() int {
+func (e *ValueEmitter) popMark(t Type) (int, error) {
 	n := len(e.marks) - 1
+
+	if n < 0 {
+		return 0, fmt.Errorf("objconv: Emit%sEnd called without a matching Emit%sBegin", typeMethodName(t), typeMethodName(t))
+	}
+
 	m := e.marks[n]
+
+	if m.typ != t {
+		return 0, fmt.Errorf("objconv: Emit%sEnd called to terminate %s %s", typeMethodName(t), articleOf(m.typ), m.typ)
+	}
+
 	e.marks = e.marks[:n]
-	return m
+	return m.offset, nil
+}
+
+func typeMethodName(t Type) string {
+	if t == Array {
+		return "Array"
+	}
+	return "Map"
+}
+
+func articleOf(t Type) string {
+	if t == Array {
+		return "an"
+	}
+	return "a"
 }
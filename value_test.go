@@ -0,0 +1,185 @@
+package objconv
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestValueEmitterRoundTrip(t *testing.T) {
+	type T struct {
+		A []int                  `objconv:"a"`
+		B map[string]float64     `objconv:"b"`
+		C time.Time              `objconv:"c"`
+		D time.Duration          `objconv:"d"`
+		E error                  `objconv:"e"`
+		F []map[string][]string  `objconv:"f"`
+		G map[string]interface{} `objconv:"g"`
+	}
+
+	v1 := T{
+		A: []int{1, 2, 3},
+		B: map[string]float64{"x": 0.5},
+		C: time.Date(2016, 12, 12, 1, 1, 1, 0, time.UTC),
+		D: time.Second,
+		E: errors.New("oops"),
+		F: []map[string][]string{{"k": {"v"}}},
+		G: map[string]interface{}{"nil": nil},
+	}
+
+	e := NewValueEmitter()
+
+	if err := NewEncoder(e).Encode(v1); err != nil {
+		t.Fatal(err)
+	}
+
+	var v2 T
+
+	if err := NewDecoder(NewValueParser(e.Value())).Decode(&v2); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(v1, v2) {
+		t.Errorf("%#v != %#v", v1, v2)
+	}
+}
+
+func TestValueEmitterErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		emit func(*ValueEmitter) error
+		err  string
+	}{
+		{
+			name: "array end without begin",
+			emit: func(e *ValueEmitter) error { return e.EmitArrayEnd() },
+			err:  "objconv: EmitArrayEnd called without a matching EmitArrayBegin",
+		},
+		{
+			name: "map end without begin",
+			emit: func(e *ValueEmitter) error { return e.EmitMapEnd() },
+			err:  "objconv: EmitMapEnd called without a matching EmitMapBegin",
+		},
+		{
+			name: "array end of map",
+			emit: func(e *ValueEmitter) error {
+				e.EmitMapBegin(0)
+				return e.EmitArrayEnd()
+			},
+			err: "objconv: EmitArrayEnd called to terminate a map",
+		},
+		{
+			name: "map end of array",
+			emit: func(e *ValueEmitter) error {
+				e.EmitArrayBegin(0)
+				return e.EmitMapEnd()
+			},
+			err: "objconv: EmitMapEnd called to terminate an array",
+		},
+		{
+			name: "map key without value",
+			emit: func(e *ValueEmitter) error {
+				e.EmitMapBegin(1)
+				e.EmitString("key")
+				return e.EmitMapEnd()
+			},
+			err: "objconv: EmitMapEnd called after a map key with no value",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.emit(NewValueEmitter()); err == nil || err.Error() != test.err {
+				t.Errorf("bad error: %v", err)
+			}
+		})
+	}
+}
+
+func TestValueParserTypes(t *testing.T) {
+	type S struct {
+		A int               `objconv:"a"`
+		B *string           `objconv:"b"`
+		C interface{}       `objconv:"c"`
+		D []byte            `objconv:"d"`
+		E error             `objconv:"e"`
+		F Number            `objconv:"f"`
+		G map[int8][]uint16 `objconv:"g"`
+		H [2]float32        `objconv:"h"`
+	}
+
+	str := "x"
+	err := errors.New("oops")
+
+	in := []interface{}{
+		[]interface{}{1, "a", nil, []int{2}, err, Number("1.5")},
+		[]Number{"1", "-1", "0.5"},
+		[]time.Duration{time.Second},
+		[]*string{&str},
+		[]S{
+			{A: 1, B: &str, C: true, D: []byte("d"), E: err, F: "2", G: map[int8][]uint16{-1: {1}}, H: [2]float32{0.5, 1}},
+			{B: &str, C: []interface{}{uint(1), "b"}, F: "-3"},
+		},
+	}
+
+	var out interface{}
+
+	if err := NewDecoder(NewValueParser(in)).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(out, []interface{}{
+		[]interface{}{int64(1), "a", nil, []interface{}{int64(2)}, err, 1.5},
+		[]interface{}{int64(1), int64(-1), 0.5},
+		[]interface{}{time.Second},
+		[]interface{}{"x"},
+		[]interface{}{
+			map[interface{}]interface{}{
+				"a": int64(1),
+				"b": "x",
+				"c": true,
+				"d": []byte("d"),
+				"e": err,
+				"f": int64(2),
+				"g": map[interface{}]interface{}{int64(-1): []interface{}{uint64(1)}},
+				"h": []interface{}{0.5, 1.0},
+			},
+			map[interface{}]interface{}{
+				"a": int64(0),
+				"b": "x",
+				"c": []interface{}{uint64(1), "b"},
+				"d": []byte{},
+				"e": nil,
+				"f": int64(-3),
+				"g": map[interface{}]interface{}{},
+				"h": []interface{}{0.0, 0.0},
+			},
+		},
+	}) {
+		t.Errorf("%#v", out)
+	}
+}
+
+func BenchmarkValueParser(b *testing.B) {
+	type T struct {
+		A int     `objconv:"a"`
+		B string  `objconv:"b"`
+		C float64 `objconv:"c"`
+		D []int   `objconv:"d"`
+	}
+
+	in := make([]T, 1000)
+
+	for i := range in {
+		in[i] = T{A: i, B: "hello", C: 0.5, D: []int{1, 2, 3}}
+	}
+
+	var out []T
+
+	for i := 0; i != b.N; i++ {
+		if err := NewDecoder(NewValueParser(in)).Decode(&out); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
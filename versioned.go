@@ -0,0 +1,106 @@
+package objconv
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// RegisterVersioned installs an adapter for base, which is usually an interface
+// type, where the type of the decoded values is selected by the version found
+// in the input.
+//
+// Values are decoded from maps, the value associated with versionKey is read as
+// an integer and used to look up the type to decode into in types. The version
+// may appear anywhere in the map, which is fully loaded before being decoded.
+// When the version is missing the type registered for version zero is used, if
+// there is none, or if the version has no entry in types, decoding fails.
+//
+// Values are encoded from their dynamic type, which is expected to hold the
+// version field.
+//
+// The function panics if one of the types isn't assignable to base.
+func RegisterVersioned(base reflect.Type, versionKey string, types map[int64]reflect.Type) {
+	versions := make(map[int64]reflect.Type, len(types))
+
+	for version, typ := range types {
+		if !typ.AssignableTo(base) {
+			panic(fmt.Sprintf("objconv: %s registered for version %d is not assignable to %s", typ, version, base))
+		}
+		versions[version] = typ
+	}
+
+	Install(base, Adapter{
+		Encode: encodeVersioned,
+		Decode: func(d Decoder, to reflect.Value) error {
+			return decodeVersioned(d, to, versionKey, versions)
+		},
+	})
+}
+
+func encodeVersioned(e Encoder, v reflect.Value) error {
+	if v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return e.Emitter.EmitNil()
+		}
+		v = v.Elem()
+	}
+	return e.Encode(v.Interface())
+}
+
+func decodeVersioned(d Decoder, to reflect.Value, versionKey string, types map[int64]reflect.Type) (err error) {
+	var m interface{}
+	var version int64
+	var found bool
+
+	if err = d.Decode(&m); err != nil {
+		return
+	}
+
+	if m == nil {
+		if to.IsValid() {
+			to.Set(zeroValueOf(to.Type()))
+		}
+		return
+	}
+
+	mv := reflect.ValueOf(m)
+
+	if mv.Kind() != reflect.Map {
+		return fmt.Errorf("objconv: cannot decode a versioned value from %T", m)
+	}
+
+	if v := mv.MapIndex(reflect.ValueOf(versionKey)); v.IsValid() {
+		found = true
+		vd := d
+		vd.Parser = NewValueParser(v.Interface())
+		vd.off = 0
+
+		if err = vd.Decode(&version); err != nil {
+			return fmt.Errorf("objconv: bad version in field %s: %s", versionKey, strings.TrimPrefix(err.Error(), "objconv: "))
+		}
+	}
+
+	typ := types[version]
+
+	switch {
+	case typ != nil:
+	case !found:
+		return fmt.Errorf("objconv: missing version field %s", versionKey)
+	default:
+		return fmt.Errorf("objconv: unsupported version %d in field %s", version, versionKey)
+	}
+
+	v := reflect.New(typ)
+	d.Parser = newValueParserFor(d.Parser, m)
+	d.off = 0
+
+	if err = d.Decode(v.Interface()); err != nil {
+		return
+	}
+
+	if to.IsValid() {
+		to.Set(v.Elem())
+	}
+	return
+}
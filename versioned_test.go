@@ -0,0 +1,79 @@
+package objconv
+
+import (
+	"reflect"
+	"testing"
+)
+
+type versionedMessage interface{}
+
+type versionedMessageV0 struct {
+	Name string `objconv:"name"`
+}
+
+type versionedMessageV1 struct {
+	Version int    `objconv:"version"`
+	Name    string `objconv:"name"`
+}
+
+type versionedMessageV2 struct {
+	Version   int    `objconv:"version"`
+	FirstName string `objconv:"first_name"`
+	LastName  string `objconv:"last_name"`
+}
+
+func TestRegisterVersioned(t *testing.T) {
+	type T struct {
+		M versionedMessage `objconv:"m"`
+	}
+
+	RegisterVersioned(reflect.TypeOf((*versionedMessage)(nil)).Elem(), "version", map[int64]reflect.Type{
+		0: reflect.TypeOf(versionedMessageV0{}),
+		1: reflect.TypeOf(versionedMessageV1{}),
+		2: reflect.TypeOf(versionedMessageV2{}),
+	})
+
+	tests := []struct {
+		in  map[string]interface{}
+		out versionedMessage
+		err bool
+	}{
+		{
+			in:  map[string]interface{}{"name": "Luke"},
+			out: versionedMessageV0{Name: "Luke"},
+		},
+		{
+			in:  map[string]interface{}{"name": "Luke", "version": 1},
+			out: versionedMessageV1{Version: 1, Name: "Luke"},
+		},
+		{
+			in:  map[string]interface{}{"last_name": "Skywalker", "first_name": "Luke", "version": 2},
+			out: versionedMessageV2{Version: 2, FirstName: "Luke", LastName: "Skywalker"},
+		},
+		{
+			in:  map[string]interface{}{"name": "Luke", "version": 3},
+			err: true,
+		},
+		{
+			in:  map[string]interface{}{"name": "Luke", "version": "1"},
+			err: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			var v T
+
+			err := (Decoder{Parser: NewValueParser(map[string]interface{}{"m": test.in})}).Decode(&v)
+
+			switch {
+			case test.err && err == nil:
+				t.Errorf("expected an error but decoded %#v", v.M)
+			case !test.err && err != nil:
+				t.Error(err)
+			case !reflect.DeepEqual(v.M, test.out):
+				t.Errorf("%#v != %#v", test.out, v.M)
+			}
+		})
+	}
+}
@@ -10,12 +10,17 @@ import (
 
 // NewDecoder returns a new YAML decoder that parses values from r.
 func NewDecoder(r io.Reader) *objconv.Decoder {
-	return objconv.NewDecoder(NewParser(r))
+	return Codec.NewDecoder(r)
 }
 
 // NewStreamDecoder returns a new YAML stream decoder that parses values from r.
+//
+// When r contains multiple documents, each of them is decoded as a value of
+// the stream.
 func NewStreamDecoder(r io.Reader) *objconv.StreamDecoder {
-	return objconv.NewStreamDecoder(NewParser(r))
+	d := Codec.NewStreamDecoder(r)
+	d.Parser.(*Parser).stream = true
+	return d
 }
 
 // Unmarshal decodes a YAML representation of v from b.
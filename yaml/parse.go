@@ -13,12 +13,25 @@ import (
 	"github.com/segmentio/objconv"
 )
 
+// Parser implements objconv.Parser for YAML inputs.
+//
+// Inputs containing multiple documents separated by "---" markers are parsed
+// one document at a time, each call to the Decode method of a decoder decodes
+// the next document, and io.EOF is returned after the last one. Stream
+// decoders created by NewStreamDecoder decode each document of such inputs as
+// an element of the stream.
 type Parser struct {
 	r io.Reader // reader to load bytes from
 	s []byte    // string buffer
 	// This stack is used to iterate over the arrays and maps that get loaded in
 	// the value field.
 	stack []parser
+
+	docs   [][]byte // documents of the input that haven't been parsed yet
+	loaded bool     // whether the input was read
+	stream bool     // whether multiple documents are parsed as an array
+
+	raw interface{} // value recorded by BeginRaw
 }
 
 func NewParser(r io.Reader) *Parser {
@@ -29,23 +42,47 @@ func (p *Parser) Reset(r io.Reader) {
 	p.r = r
 	p.s = nil
 	p.stack = nil
+	p.docs = nil
+	p.loaded = false
 }
 
 func (p *Parser) Buffered() io.Reader {
 	return bytes.NewReader(nil)
 }
 
-func (p *Parser) ParseType() (typ objconv.Type, err error) {
-	if p.stack == nil {
-		var b []byte
-		var v interface{}
+// BeginRaw starts recording the raw bytes of the value being parsed.
+//
+// The parser doesn't keep track of the input bytes, instead EndRaw re-encodes
+// the value that was recorded when BeginRaw was called.
+func (p *Parser) BeginRaw() {
+	p.raw = p.value()
+}
 
-		if b, err = ioutil.ReadAll(p.r); err != nil {
+// EndRaw stops recording and returns the YAML representation of the value
+// parsed since BeginRaw was called.
+func (p *Parser) EndRaw() (raw []byte) {
+	if _, isEOF := p.raw.(eof); !isEOF {
+		raw, _ = yaml.Marshal(p.raw)
+	}
+	p.raw = nil
+	return
+}
+
+func (p *Parser) ParseType() (typ objconv.Type, err error) {
+	if !p.loaded {
+		if err = p.load(); err != nil {
 			return
 		}
-		if err = yaml.Unmarshal(b, &v); err != nil {
+	}
+
+	if len(p.stack) == 0 && len(p.docs) != 0 {
+		var v interface{}
+
+		if err = yaml.Unmarshal(p.docs[0], &v); err != nil {
 			return
 		}
+
+		p.docs = p.docs[1:]
 		p.push(newParser(v))
 	}
 
@@ -191,6 +228,35 @@ func (p *Parser) DecodeBytes(b []byte) (v []byte, err error) {
 	return
 }
 
+// load reads the input and splits it into documents. When the parser is used
+// by a stream decoder and the input has more than one document, they are all
+// parsed and exposed as a single array.
+func (p *Parser) load() (err error) {
+	var b []byte
+
+	if b, err = ioutil.ReadAll(p.r); err != nil {
+		return
+	}
+
+	p.loaded = true
+	p.docs = splitDocuments(b)
+
+	if p.stream && len(p.docs) > 1 {
+		docs := make([]interface{}, len(p.docs))
+
+		for i, doc := range p.docs {
+			if err = yaml.Unmarshal(doc, &docs[i]); err != nil {
+				return
+			}
+		}
+
+		p.docs = nil
+		p.push(newParser(docs))
+	}
+
+	return
+}
+
 func (p *Parser) push(v parser) {
 	p.stack = append(p.stack, v)
 }
@@ -307,6 +373,72 @@ func makeMapSlice(m map[interface{}]interface{}) yaml.MapSlice {
 	return s
 }
 
+// splitDocuments splits b on the "---" and "..." markers that start and end
+// YAML documents. Text which isn't preceded by a "---" marker is only retained
+// as a document if it contains more than comments and directives, and at least
+// one (empty) document is always returned.
+func splitDocuments(b []byte) (docs [][]byte) {
+	start := 0      // offset of the current document
+	marked := false // whether the current document starts with "---"
+
+	for off := 0; off < len(b); {
+		end := bytes.IndexByte(b[off:], '\n')
+		if end < 0 {
+			end = len(b)
+		} else {
+			end += off + 1
+		}
+
+		switch line := b[off:end]; {
+		case isDocumentMarker(line, "---"):
+			docs = appendDocument(docs, b[start:off], marked)
+			start, marked = off, true
+
+		case isDocumentMarker(line, "..."):
+			docs = appendDocument(docs, b[start:off], marked)
+			start, marked = end, false
+		}
+
+		off = end
+	}
+
+	docs = appendDocument(docs, b[start:], marked)
+
+	if len(docs) == 0 {
+		docs = append(docs, nil)
+	}
+
+	return
+}
+
+func appendDocument(docs [][]byte, doc []byte, marked bool) [][]byte {
+	if marked || hasContent(doc) {
+		docs = append(docs, doc)
+	}
+	return docs
+}
+
+// hasContent returns true if b has lines which aren't blank, comments, or
+// directives.
+func hasContent(b []byte) bool {
+	for _, line := range bytes.Split(b, []byte{'\n'}) {
+		if line = bytes.TrimSpace(line); len(line) != 0 && line[0] != '#' && line[0] != '%' {
+			return true
+		}
+	}
+	return false
+}
+
+// isDocumentMarker returns true if line starts with the marker, followed by a
+// space or the end of the line.
+func isDocumentMarker(line []byte, marker string) bool {
+	if !bytes.HasPrefix(line, []byte(marker)) {
+		return false
+	}
+	rest := line[len(marker):]
+	return len(rest) == 0 || rest[0] == ' ' || rest[0] == '\t' || rest[0] == '\r' || rest[0] == '\n'
+}
+
 // eof values are returned by the top method to indicate that all values have
 // already been consumed.
 type eof struct{}
@@ -1,6 +1,9 @@
 package yaml
 
 import (
+	"io"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/segmentio/objconv/objtests"
@@ -13,3 +16,98 @@ func TestCodec(t *testing.T) {
 func BenchmarkCodec(b *testing.B) {
 	objtests.BenchmarkCodec(b, Codec)
 }
+
+func TestInterfaceMapKeys(t *testing.T) {
+	m := map[interface{}]interface{}{
+		"s":      "A",
+		int64(1): map[interface{}]interface{}{int64(-2): "B"},
+		1.5:      "C",
+		true:     "D",
+	}
+
+	b, err := Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var v interface{}
+
+	if err := Unmarshal(b, &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(v, m) {
+		t.Errorf("%#v", v)
+	}
+}
+
+func TestDocuments(t *testing.T) {
+	tests := []struct {
+		in   string
+		docs []interface{}
+	}{
+		{
+			in:   "",
+			docs: []interface{}{nil},
+		},
+		{
+			in:   "a: 1\n",
+			docs: []interface{}{map[interface{}]interface{}{"a": int64(1)}},
+		},
+		{
+			in:   "# comment\n%YAML 1.1\n--- 1\n---\n- 2\n- 3\n...\n# end\n--- [&x hello, *x]\n",
+			docs: []interface{}{int64(1), []interface{}{int64(2), int64(3)}, []interface{}{"hello", "hello"}},
+		},
+		{
+			in:   "a: 1\n---\n---\nb: true\n",
+			docs: []interface{}{map[interface{}]interface{}{"a": int64(1)}, nil, map[interface{}]interface{}{"b": true}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			var docs []interface{}
+			d := NewDecoder(strings.NewReader(test.in))
+
+			for {
+				var v interface{}
+				if err := d.Decode(&v); err != nil {
+					if err != io.EOF {
+						t.Fatal(err)
+					}
+					break
+				}
+				docs = append(docs, v)
+			}
+
+			if !reflect.DeepEqual(docs, test.docs) {
+				t.Errorf("bad documents: %#v", docs)
+			}
+
+			var values []interface{}
+			s := NewStreamDecoder(strings.NewReader(test.in))
+
+			for {
+				var v interface{}
+				if s.Decode(&v) != nil {
+					break
+				}
+				values = append(values, v)
+			}
+
+			if err := s.Err(); err != nil {
+				t.Fatal(err)
+			}
+
+			// A single document which is an array is decoded as a stream of
+			// its elements, like with other formats.
+			if len(test.docs) == 1 {
+				return
+			}
+
+			if !reflect.DeepEqual(values, test.docs) {
+				t.Errorf("bad stream values: %#v", values)
+			}
+		})
+	}
+}